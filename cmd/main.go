@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
@@ -11,9 +14,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/server"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/utils"
 	"github.com/mfbonfigli/gocesiumtiler/v2/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
 	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/mutator"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/validator"
 	"github.com/mfbonfigli/gocesiumtiler/v2/version"
 	"github.com/urfave/cli/v2"
 )
@@ -23,6 +30,22 @@ var tilerProvider func() (tiler.Tiler, error) = func() (tiler.Tiler, error) {
 	return tiler.NewGoCesiumTiler()
 }
 
+// this global variable controls how the serve command listens for requests. Useful to inject mocks during tests.
+var listenAndServe func(addr string, h http.Handler, ctx context.Context) error = func(addr string, h http.Handler, ctx context.Context) error {
+	srv := &http.Server{Addr: addr, Handler: h}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// this global variable controls how the process exits with a non zero code. Useful to inject mocks during tests.
+var exit func(code int) = os.Exit
+
 var cmdVersion = "2.0.1"
 
 // GitCommit is injected dynamically at build time via `go build -ldflags "-X main.GitCommit=XYZ"`
@@ -54,12 +77,12 @@ func getVersion() string {
 func getCli(c *cliOpts) *cli.App {
 	return &cli.App{
 		Name:    "gocesiumtiler",
-		Usage:   "transforms LAS files into Cesium.JS 3D Tiles",
+		Usage:   "transforms LAS/LAZ, PLY, E57 and XYZ/CSV/TXT files into Cesium.JS 3D Tiles",
 		Version: getVersion(),
 		Commands: []*cli.Command{
 			{
 				Name:  "file",
-				Usage: "convert a LAS file into 3D tiles",
+				Usage: "convert a LAS, LAZ, PLY, E57 or XYZ/CSV/TXT file into 3D tiles",
 				Flags: getFileFlags(c),
 				Action: func(cCtx *cli.Context) error {
 					fileCommand(c, cCtx.Args().First())
@@ -68,13 +91,31 @@ func getCli(c *cliOpts) *cli.App {
 			},
 			{
 				Name:  "folder",
-				Usage: "convert all LAS files in a folder file into 3D tiles",
+				Usage: "convert all LAS, LAZ, PLY and E57 files, or XYZ/CSV/TXT files if text-columns is set, in a folder into 3D tiles",
 				Flags: getFolderFlags(c),
 				Action: func(cCtx *cli.Context) error {
 					folderCommand(c, cCtx.Args().First())
 					return nil
 				},
 			},
+			{
+				Name:  "serve",
+				Usage: "serve a tileset folder or .3tz archive over HTTP together with a minimal CesiumJS viewer",
+				Flags: getServeFlags(c),
+				Action: func(cCtx *cli.Context) error {
+					serveCommand(c, cCtx.Args().First())
+					return nil
+				},
+			},
+			{
+				Name:  "validate",
+				Usage: "validate a tileset folder, tileset.json file or .3tz archive, reporting all the issues found",
+				Flags: getValidateFlags(c),
+				Action: func(cCtx *cli.Context) error {
+					validateCommand(c, cCtx.Args().First())
+					return nil
+				},
+			},
 		},
 		EnableBashCompletion: true,
 	}
@@ -90,10 +131,16 @@ func getFolderFlags(c *cliOpts) []cli.Flag {
 		Name:        "join",
 		Aliases:     []string{"j"},
 		Value:       c.join,
-		Usage:       "merge the input LAS files in the folder into a single cloud. The LAS files must have the same properties (CRS etc)",
+		Usage:       "merge the input LAS files in the folder into a single cloud. The LAS files must have the same attributes, while each can have its own CRS",
 		Destination: &c.join,
 	}
-	return append(stdFlags, joinFlag)
+	manifestFlag := &cli.StringFlag{
+		Name:        "crs-manifest",
+		Value:       c.crsManifest,
+		Usage:       "path to a CSV file listing on each row an input file, relative to the CSV file folder, and its CRS, e.g. tile1.las,EPSG:32632. The CRS of the listed files overrides the crs flag and the CRS in their metadata",
+		Destination: &c.crsManifest,
+	}
+	return append(stdFlags, joinFlag, manifestFlag)
 }
 
 func getFlags(c *cliOpts) []cli.Flag {
@@ -102,16 +149,22 @@ func getFlags(c *cliOpts) []cli.Flag {
 			Name:        "out",
 			Aliases:     []string{"o"},
 			Value:       c.output,
-			Usage:       "full path of the output folder where to save the resulting Cesium tilesets",
+			Usage:       "full path of the output folder where to save the resulting Cesium tilesets, or an s3://bucket/prefix URL to upload them to an S3 compatible object store configured via the AWS_* environment variables",
 			Destination: &c.output,
 		},
 		&cli.StringFlag{
 			Name:        "crs",
 			Aliases:     []string{"e", "epsg"},
 			Value:       c.crs,
-			Usage:       "String representing the input CRS. For example, EPSG:4326 or a generic Proj4 string. Bare numbers will be interpreted as EPSG codes. If empty the system will attempt to autodetect the CRS from the LAS metadata. In case of multiple LAS files, the CRS must be consistent else an error will be thrown.",
+			Usage:       "String representing the input CRS. For example, EPSG:4326 or a generic Proj4 string. Bare numbers will be interpreted as EPSG codes. If empty the system will attempt to autodetect the CRS from the LAS metadata of each file, or else from a .prj or .wkt sidecar file next to it. Joined files with different CRSs are converted each from its own CRS.",
 			Destination: &c.crs,
 		},
+		&cli.StringFlag{
+			Name:        "crs-engine",
+			Value:       c.crsEngine,
+			Usage:       "implementation used to convert the coordinates: native, a built-in converter that does not require PROJ supporting EPSG:4326, EPSG:4979, EPSG:4978, EPSG:3857 and the WGS84 and ETRS89 UTM zones, proj, to always use PROJ, or auto, to use the native converter if it supports the input CRS and PROJ otherwise. Binaries built without cgo or with the noproj tag only support the native converter",
+			Destination: &c.crsEngine,
+		},
 		&cli.Float64Flag{
 			Name:        "resolution",
 			Aliases:     []string{"r"},
@@ -126,6 +179,24 @@ func getFlags(c *cliOpts) []cli.Flag {
 			Usage:       "z offset to apply to the point, in meters. only use it if the input elevation is referred to the WGS84 ellipsoid or geoid",
 			Destination: &c.zOffset,
 		},
+		&cli.StringFlag{
+			Name:        "geoid",
+			Value:       c.geoid,
+			Usage:       "path to a geoid grid, in .gtx, GeographicLib .pgm or GeoTIFF .tif format, used to convert the orthometric heights of the input to heights above the WGS84 ellipsoid, like the EGM96 or EGM2008 models or a national geoid model. Points outside the grid are left unchanged and reported with a warning",
+			Destination: &c.geoid,
+		},
+		&cli.StringFlag{
+			Name:        "transform",
+			Value:       c.transform,
+			Usage:       "affine transformation to apply to the input coordinates before the CRS conversion, as 12 or 16 comma separated values of the 4x4 matrix in row-major order, e.g. a rigid roto-translation, a Helmert transformation or a site calibration. The transformed coordinates must be expressed in the input CRS",
+			Destination: &c.transform,
+		},
+		&cli.StringFlag{
+			Name:        "control-points",
+			Value:       c.controlPoints,
+			Usage:       "path to a CSV file listing on each row the source and target coordinates of a control point, as sx,sy,sz,tx,ty,tz. The 7-parameter Helmert transformation best fitting the control points is applied to the input coordinates before the CRS conversion, and its residuals are printed. The target coordinates must be expressed in the input CRS",
+			Destination: &c.controlPoints,
+		},
 		&cli.IntFlag{
 			Name:        "depth",
 			Aliases:     []string{"d"},
@@ -152,6 +223,138 @@ func getFlags(c *cliOpts) []cli.Flag {
 			Usage:       "Approximate percent of points to keep in the final point cloud, between 0.01 (1%) and 1 (100%)",
 			Destination: &c.subsamplePct,
 		},
+		&cli.StringFlag{
+			Name:        "copc-bbox",
+			Value:       c.copcBBox,
+			Usage:       "only read the octree nodes of COPC input files that intersect the given bounding box, expressed in the source CRS as minx,miny,maxx,maxy or minx,miny,minz,maxx,maxy,maxz",
+			Destination: &c.copcBBox,
+		},
+		&cli.IntFlag{
+			Name:        "copc-level",
+			Value:       c.copcLevel,
+			Usage:       "only read the octree nodes of COPC input files up to the given level, where 0 is the root node. negative values read all levels",
+			Destination: &c.copcLevel,
+		},
+		&cli.StringFlag{
+			Name:        "text-columns",
+			Value:       c.textColumns,
+			Usage:       "read the input as XYZ/CSV/TXT text files with the given column layout, made of the identifiers x, y, z, r, g, b, i (intensity), c (classification) and _ (ignored column), e.g. x,y,z,r,g,b. The CRS must be set with the crs or crs-manifest flags or with sidecar .prj/.wkt files. The color depth is set with the text-color-bits flag",
+			Destination: &c.textColumns,
+		},
+		&cli.IntFlag{
+			Name:        "text-color-bits",
+			Value:       c.textColorBits,
+			Usage:       "bit depth of the colors of the text input files, 8 or 16. if 0 colors are 16 bit unless the 8-bit flag is set",
+			Destination: &c.textColorBits,
+		},
+		&cli.StringFlag{
+			Name:        "text-delimiter",
+			Value:       c.textDelimiter,
+			Usage:       "column delimiter of the text input files, e.g. ',' or 'tab'. if empty columns are separated by whitespaces",
+			Destination: &c.textDelimiter,
+		},
+		&cli.IntFlag{
+			Name:        "text-skip-rows",
+			Value:       c.textSkipRows,
+			Usage:       "number of header rows to skip at the beginning of the text input files",
+			Destination: &c.textSkipRows,
+		},
+		&cli.StringFlag{
+			Name:        "las-attributes",
+			Value:       c.lasAttrs,
+			Usage:       "comma separated list of the standard LAS point attributes to store in the tiles, among gps-time, return-number, number-of-returns, scan-angle, point-source-id, nir, user-data and intensity-16 (full 16 bit intensity)",
+			Destination: &c.lasAttrs,
+		},
+		&cli.StringFlag{
+			Name:        "extra-dims",
+			Value:       c.extraDims,
+			Usage:       "comma separated names of the LAS extra bytes dimensions to store in the tiles, e.g. Amplitude,Reflectance. All input files must declare them",
+			Destination: &c.extraDims,
+		},
+		&cli.BoolFlag{
+			Name:        "draco",
+			Value:       c.draco,
+			Usage:       "compress the tiles with Draco, using the 3DTILES_draco_point_compression extension for 1.0 tilesets and KHR_draco_mesh_compression for 1.1 tilesets",
+			Destination: &c.draco,
+		},
+		&cli.IntFlag{
+			Name:        "draco-position-bits",
+			Value:       c.dracoPositionBits,
+			Usage:       "number of bits used to quantize the point coordinates when draco is enabled, between 1 and 30",
+			Destination: &c.dracoPositionBits,
+		},
+		&cli.IntFlag{
+			Name:        "draco-color-bits",
+			Value:       c.dracoColorBits,
+			Usage:       "number of bits retained for each color component when draco is enabled, between 1 and 8",
+			Destination: &c.dracoColorBits,
+		},
+		&cli.IntFlag{
+			Name:        "draco-attribute-bits",
+			Value:       c.dracoAttributeBits,
+			Usage:       "number of bits used to quantize the floating point attributes when draco is enabled, between 1 and 30",
+			Destination: &c.dracoAttributeBits,
+		},
+		&cli.BoolFlag{
+			Name:        "quantize-positions",
+			Value:       c.quantize,
+			Usage:       "store the point coordinates as 16 bit integers relative to the tile bounds, using POSITION_QUANTIZED for 1.0 tilesets and KHR_mesh_quantization for 1.1 tilesets",
+			Destination: &c.quantize,
+		},
+		&cli.BoolFlag{
+			Name:        "rgb565",
+			Value:       c.rgb565,
+			Usage:       "store the point colors of 1.0 tilesets as RGB565, using 16 bits per point",
+			Destination: &c.rgb565,
+		},
+		&cli.BoolFlag{
+			Name:        "meshopt",
+			Value:       c.meshopt,
+			Usage:       "compress the vertex buffers of 1.1 tilesets with the EXT_meshopt_compression extension",
+			Destination: &c.meshopt,
+		},
+		&cli.BoolFlag{
+			Name:        "implicit",
+			Value:       c.implicit,
+			Usage:       "write 1.1 tilesets using implicit tiling, with subtree availability files instead of a tileset.json for each non leaf tile",
+			Destination: &c.implicit,
+		},
+		&cli.IntFlag{
+			Name:        "subtree-levels",
+			Value:       c.subtreeLevels,
+			Usage:       "number of levels of each subtree when implicit tiling is enabled, between 1 and 7",
+			Destination: &c.subtreeLevels,
+		},
+		&cli.StringFlag{
+			Name:        "format",
+			Value:       c.format,
+			Usage:       "output format of each tileset, either folder, to write a tree of folders and files, or 3tz, to write a single tileset.3tz 3D Tiles archive",
+			Destination: &c.format,
+		},
+		&cli.StringFlag{
+			Name:        "gzip",
+			Value:       c.gzip,
+			Usage:       "gzip compress the tileset.json and tile files, either replacing them (replace), to serve them with the Content-Encoding: gzip header, or alongside them as .gz files (sidecar). Brotli compression is not supported",
+			Destination: &c.gzip,
+		},
+		&cli.IntFlag{
+			Name:        "memory-limit",
+			Value:       c.memoryLimit,
+			Usage:       "approximate maximum memory in MB to use to store the points, spilling the others to temporary files to process clouds larger than the RAM. 0 processes the clouds fully in memory",
+			Destination: &c.memoryLimit,
+		},
+		&cli.StringFlag{
+			Name:        "temp-dir",
+			Value:       c.tempDir,
+			Usage:       "folder where to store the temporary files when the memory-limit flag is set, defaults to the system temporary folder",
+			Destination: &c.tempDir,
+		},
+		&cli.BoolFlag{
+			Name:        "parallel-build",
+			Value:       c.parallelBuild,
+			Usage:       "build the octree nodes concurrently, building the deeper levels while the shallower ones are exported. With memory-limit the subtrees that fit in memory are built concurrently",
+			Destination: &c.parallelBuild,
+		},
 		&cli.StringFlag{
 			Name:        "version",
 			Aliases:     []string{"v"},
@@ -162,30 +365,132 @@ func getFlags(c *cliOpts) []cli.Flag {
 	}
 }
 
+func getServeFlags(c *cliOpts) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "address",
+			Aliases:     []string{"a"},
+			Value:       c.address,
+			Usage:       "address to listen on, in the host:port form",
+			Destination: &c.address,
+		},
+		&cli.StringFlag{
+			Name:        "cesium",
+			Value:       c.cesiumURL,
+			Usage:       "location of the CesiumJS build folder containing Cesium.js loaded by the viewer. Can be an http(s) URL or a local folder, to run the viewer offline",
+			Destination: &c.cesiumURL,
+		},
+	}
+}
+
+func getValidateFlags(c *cliOpts) []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "json",
+			Value:       c.jsonReport,
+			Usage:       "write the report in JSON form instead of the human readable one",
+			Destination: &c.jsonReport,
+		},
+		&cli.StringFlag{
+			Name:        "report",
+			Aliases:     []string{"r"},
+			Value:       c.reportPath,
+			Usage:       "file where the report is written. If not set the report is written to the standard output",
+			Destination: &c.reportPath,
+		},
+	}
+}
+
 type cliOpts struct {
-	output       string
-	crs          string
-	maxDepth     int
-	minPoints    int
-	resolution   float64
-	zOffset      float64
-	subsamplePct float64
-	eightBit     bool
-	join         bool
-	version      string
+	output        string
+	crs           string
+	crsEngine     string
+	crsManifest   string
+	maxDepth      int
+	minPoints     int
+	resolution    float64
+	zOffset       float64
+	geoid         string
+	transform     string
+	controlPoints string
+	subsamplePct  float64
+	eightBit      bool
+	join          bool
+	version       string
+	copcBBox      string
+	copcLevel     int
+	textColumns   string
+	textDelimiter string
+	textSkipRows  int
+	textColorBits int
+	lasAttrs      string
+	extraDims     string
+	draco         bool
+	// draco quantization bits
+	dracoPositionBits  int
+	dracoColorBits     int
+	dracoAttributeBits int
+	quantize           bool
+	rgb565             bool
+	meshopt            bool
+	implicit           bool
+	subtreeLevels      int
+	format             string
+	gzip               string
+	memoryLimit        int
+	tempDir            string
+	parallelBuild      bool
+	// serve command
+	address   string
+	cesiumURL string
+	// validate command
+	jsonReport bool
+	reportPath string
 }
 
 func defaultCliOptions() *cliOpts {
 	return &cliOpts{
-		crs:          "",
-		maxDepth:     10,
-		minPoints:    5000,
-		resolution:   20,
-		subsamplePct: 1,
-		zOffset:      0,
-		eightBit:     false,
-		join:         false,
-		version:      "1.0",
+		crs:           "",
+		crsEngine:     "auto",
+		crsManifest:   "",
+		maxDepth:      10,
+		minPoints:     5000,
+		resolution:    20,
+		subsamplePct:  1,
+		zOffset:       0,
+		geoid:         "",
+		transform:     "",
+		controlPoints: "",
+		eightBit:      false,
+		join:          false,
+		version:       "1.0",
+		copcBBox:      "",
+		copcLevel:     -1,
+		textColumns:   "",
+		textDelimiter: "",
+		textSkipRows:  0,
+		textColorBits: 0,
+		lasAttrs:      "",
+		extraDims:     "",
+		draco:         false,
+		// draco quantization bits
+		dracoPositionBits:  14,
+		dracoColorBits:     8,
+		dracoAttributeBits: 16,
+		quantize:           false,
+		rgb565:             false,
+		meshopt:            false,
+		implicit:           false,
+		subtreeLevels:      4,
+		format:             "folder",
+		gzip:               "",
+		memoryLimit:        0,
+		tempDir:            "",
+		parallelBuild:      false,
+		address:            "localhost:8080",
+		cesiumURL:          server.DefaultCesiumURL,
+		jsonReport:         false,
+		reportPath:         "",
 	}
 }
 
@@ -208,24 +513,211 @@ func (c *cliOpts) validate() {
 	if _, ok := version.Parse(c.version); !ok {
 		log.Fatal("invalid tileset version, the only allowed values are '1.0' and '1.1'")
 	}
+	if _, _, err := c.parseCopcBBox(); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := c.parseTransform(); err != nil {
+		log.Fatal(err)
+	}
+	if c.transform != "" && c.controlPoints != "" {
+		log.Fatal("transform and control-points flags cannot be used together")
+	}
+	if c.textColumns != "" {
+		if _, err := las.NewTextFormat(c.textColumns, c.textDelimiter, c.textSkipRows, c.textColorBits); err != nil {
+			log.Fatal(fmt.Errorf("invalid text format: %w", err))
+		}
+		if c.extraDims != "" || c.lasAttrs != "" {
+			log.Fatal("extra-dims and las-attributes flags are not supported when reading text files")
+		}
+	}
+	for _, a := range splitList(c.lasAttrs) {
+		if !las.IsLasAttribute(a) {
+			log.Fatalf("unknown LAS attribute %s", a)
+		}
+	}
+	if c.dracoPositionBits < 1 || c.dracoPositionBits > 30 {
+		log.Fatal("draco-position-bits should be between 1 and 30")
+	}
+	if c.dracoColorBits < 1 || c.dracoColorBits > 8 {
+		log.Fatal("draco-color-bits should be between 1 and 8")
+	}
+	if c.dracoAttributeBits < 1 || c.dracoAttributeBits > 30 {
+		log.Fatal("draco-attribute-bits should be between 1 and 30")
+	}
+	if c.rgb565 && c.version != "1.0" {
+		log.Fatal("rgb565 flag is only supported for 1.0 tilesets")
+	}
+	if c.meshopt && c.version != "1.1" {
+		log.Fatal("meshopt flag is only supported for 1.1 tilesets")
+	}
+	if c.implicit && c.version != "1.1" {
+		log.Fatal("implicit flag is only supported for 1.1 tilesets")
+	}
+	if c.subtreeLevels < 1 || c.subtreeLevels > 7 {
+		log.Fatal("subtree-levels should be between 1 and 7")
+	}
+	if c.format != "folder" && c.format != "3tz" {
+		log.Fatal("format should be either folder or 3tz")
+	}
+	if c.crsEngine != "auto" && c.crsEngine != "native" && c.crsEngine != "proj" {
+		log.Fatal("crs-engine should be either auto, native or proj")
+	}
+	if c.gzip != "" && c.gzip != "replace" && c.gzip != "sidecar" {
+		log.Fatal("gzip should be either replace or sidecar")
+	}
+	if c.gzip != "" && c.format == "3tz" {
+		log.Fatal("gzip flag cannot be used together with the 3tz format")
+	}
+	if c.memoryLimit < 0 {
+		log.Fatal("memory-limit should be a positive number of MB or 0 to process the clouds in memory")
+	}
+	if c.draco && (c.quantize || c.rgb565 || c.meshopt) {
+		log.Fatal("quantize-positions, rgb565 and meshopt flags cannot be used together with draco")
+	}
 }
 
 func (c *cliOpts) print() {
 	crsMsg := c.crs
 	if c.crs == "" {
-		crsMsg = "(autodetect from LAS metadata)"
+		crsMsg = "(autodetect from LAS metadata or sidecar .prj/.wkt files)"
 	}
 	fmt.Printf(`*** Execution settings:
 - Source CRS: %s,
+- CRS Engine: %s
+- CRS Manifest: %s
 - Max Depth: %d,
 - Resolution: %f meters,
 - Min Points per tile: %d
 - Z-Offset: %f meters,
+- Geoid: %s
+- Transform: %s
+- Control Points: %s
 - 8Bit Color: %v
 - Join Clouds: %v
 - Tileset Version: %v
+- COPC BBox: %s
+- COPC Max Level: %d
+- Text Columns: %s
+- Text Delimiter: %q
+- Text Rows to Skip: %d
+- Text Color Bits: %d
+- LAS Attributes: %s
+- Extra Dimensions: %s
+- Draco: %v (position bits: %d, color bits: %d, attribute bits: %d)
+- Quantized Positions: %v
+- RGB565 Colors: %v
+- Meshopt: %v
+- Implicit Tiling: %v (subtree levels: %d)
+- Output Format: %s
+- Gzip: %s
+- Memory Limit: %d MB (0 = no limit)
+- Parallel Build: %v
+
+`, crsMsg, c.crsEngine, c.crsManifest, c.maxDepth, c.resolution, c.minPoints, c.zOffset, c.geoid, c.transform, c.controlPoints, c.eightBit, c.join, c.version, c.copcBBox, c.copcLevel,
+		c.textColumns, c.textDelimiter, c.textSkipRows, c.textColorBits, c.lasAttrs, c.extraDims,
+		c.draco, c.dracoPositionBits, c.dracoColorBits, c.dracoAttributeBits, c.quantize, c.rgb565, c.meshopt, c.implicit, c.subtreeLevels, c.format, c.gzip, c.memoryLimit, c.parallelBuild)
+}
+
+// splitList returns the non empty items of a comma separated list flag
+func splitList(list string) []string {
+	var items []string
+	for _, i := range strings.Split(list, ",") {
+		if i = strings.TrimSpace(i); i != "" {
+			items = append(items, i)
+		}
+	}
+	return items
+}
 
-`, crsMsg, c.maxDepth, c.resolution, c.minPoints, c.zOffset, c.eightBit, c.join, c.version)
+// parseCopcBBox parses the copc-bbox flag, either in the 2D or 3D form. In the 2D form the Z
+// range is unbounded. Returns nil vectors if the flag is not set.
+func (c *cliOpts) parseCopcBBox() (*model.Vector, *model.Vector, error) {
+	if c.copcBBox == "" {
+		return nil, nil, nil
+	}
+	parts := strings.Split(c.copcBBox, ",")
+	if len(parts) != 4 && len(parts) != 6 {
+		return nil, nil, fmt.Errorf("copc-bbox should have either 4 or 6 comma separated values, got %d", len(parts))
+	}
+	vals := make([]float64, len(parts))
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid copc-bbox value %q", p)
+		}
+		vals[i] = v
+	}
+	min := &model.Vector{X: vals[0], Y: vals[1], Z: math.Inf(-1)}
+	max := &model.Vector{X: vals[2], Y: vals[3], Z: math.Inf(1)}
+	if len(vals) == 6 {
+		min = &model.Vector{X: vals[0], Y: vals[1], Z: vals[2]}
+		max = &model.Vector{X: vals[3], Y: vals[4], Z: vals[5]}
+	}
+	if min.X > max.X || min.Y > max.Y || min.Z > max.Z {
+		return nil, nil, fmt.Errorf("invalid copc-bbox, min values should not exceed max values")
+	}
+	return min, max, nil
+}
+
+// parseTransform parses the transform flag, given as the first 3 or all 4 rows of the 4x4 affine transformation
+// matrix. Returns nil if the flag is not set.
+func (c *cliOpts) parseTransform() (*model.Transform, error) {
+	if c.transform == "" {
+		return nil, nil
+	}
+	parts := strings.Split(c.transform, ",")
+	if len(parts) != 12 && len(parts) != 16 {
+		return nil, fmt.Errorf("transform should have either 12 or 16 comma separated values, got %d", len(parts))
+	}
+	m := [4][4]float64{3: {0, 0, 0, 1}}
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform value %q", p)
+		}
+		m[i/4][i%4] = v
+	}
+	t, err := model.NewAffineTransform(m)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transform: %w", err)
+	}
+	return &t, nil
+}
+
+// fitControlPoints fits the Helmert transformation to the control points of the control-points flag and
+// prints its parameters and residuals
+func (c *cliOpts) fitControlPoints() (model.Transform, error) {
+	points, err := mutator.ReadControlPoints(c.controlPoints)
+	if err != nil {
+		return model.Transform{}, err
+	}
+	fit, err := mutator.FitHelmert(points)
+	if err != nil {
+		return model.Transform{}, fmt.Errorf("unable to fit the control points: %w", err)
+	}
+	rx, ry, rz := fit.RotationAngles()
+	fmt.Printf(`*** Control points transformation:
+- Translation: %f, %f, %f
+- Rotation: %f, %f, %f degrees
+- Scale: %f ppm
+- Residuals:
+`, fit.Translation.X, fit.Translation.Y, fit.Translation.Z, rx, ry, rz, (fit.Scale-1)*1e6)
+	for i, r := range fit.Residuals {
+		fmt.Printf("  %d: %f, %f, %f (%f)\n", i+1, r.X, r.Y, r.Z, r.Norm())
+	}
+	fmt.Printf("- RMS: %f\n\n", fit.RMS)
+	return fit.Transform, nil
+}
+
+// converterEngine returns the coordinate converter implementation selected by the crs-engine flag
+func (c *cliOpts) converterEngine() tiler.ConverterEngine {
+	switch c.crsEngine {
+	case "native":
+		return tiler.ConverterNative
+	case "proj":
+		return tiler.ConverterProj
+	}
+	return tiler.ConverterAuto
 }
 
 func (c *cliOpts) getTilerOptions() *tiler.TilerOptions {
@@ -234,13 +726,30 @@ func (c *cliOpts) getTilerOptions() *tiler.TilerOptions {
 	if !ok {
 		log.Fatal("unrecongnized tileset version")
 	}
-	mutators := []mutator.Mutator{
-		mutator.NewZOffset(float32(c.zOffset)),
+	mutators := []mutator.Mutator{}
+	if t, _ := c.parseTransform(); t != nil {
+		mutators = append(mutators, mutator.NewAffine(*t))
+	}
+	if c.controlPoints != "" {
+		t, err := c.fitControlPoints()
+		if err != nil {
+			log.Fatal(err)
+		}
+		mutators = append(mutators, mutator.NewAffine(t))
+	}
+	if c.geoid != "" {
+		// convert the heights to ellipsoidal ones before any other manipulation
+		g, err := mutator.NewGeoid(c.geoid)
+		if err != nil {
+			log.Fatal(err)
+		}
+		mutators = append(mutators, g)
 	}
+	mutators = append(mutators, mutator.NewZOffset(float32(c.zOffset)))
 	if c.subsamplePct < 1 {
 		mutators = append(mutators, mutator.NewSubsampler(c.subsamplePct))
 	}
-	return tiler.NewTilerOptions(
+	opts := tiler.NewTilerOptions(
 		tiler.WithEightBitColors(c.eightBit),
 		tiler.WithMutators(mutators),
 		tiler.WithGridSize(c.resolution),
@@ -248,7 +757,41 @@ func (c *cliOpts) getTilerOptions() *tiler.TilerOptions {
 		tiler.WithMinPointsPerTile(c.minPoints),
 		tiler.WithCallback(eventListener),
 		tiler.WithTilesetVersion(v),
+		tiler.WithCopcMaxLevel(c.copcLevel),
+		tiler.WithTextFormat(c.textColumns, c.textDelimiter, c.textSkipRows),
+		tiler.WithTextColorBits(c.textColorBits),
+		tiler.WithLasAttributes(splitList(c.lasAttrs)...),
+		tiler.WithExtraDimensions(splitList(c.extraDims)...),
+		tiler.WithQuantizedPositions(c.quantize),
+		tiler.WithRGB565(c.rgb565),
+		tiler.WithMeshopt(c.meshopt),
+		tiler.WithArchive(c.format == "3tz"),
+		tiler.WithParallelBuild(c.parallelBuild),
+		tiler.WithConverterEngine(c.converterEngine()),
 	)
+	if min, max, _ := c.parseCopcBBox(); min != nil {
+		tiler.WithCopcBounds(*min, *max)(opts)
+	}
+	if c.crsManifest != "" {
+		crss, err := utils.ReadCRSManifest(c.crsManifest)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tiler.WithFileCRS(crss)(opts)
+	}
+	if c.implicit {
+		tiler.WithImplicitTiling(c.subtreeLevels)(opts)
+	}
+	if c.gzip != "" {
+		tiler.WithGzip(c.gzip == "sidecar")(opts)
+	}
+	if c.memoryLimit > 0 {
+		tiler.WithMemoryLimit(c.memoryLimit, c.tempDir)(opts)
+	}
+	if c.draco {
+		tiler.WithDraco(c.dracoPositionBits, c.dracoColorBits, c.dracoAttributeBits)(opts)
+	}
+	return opts
 }
 
 func fileCommand(opts *cliOpts, filepath string) {
@@ -256,7 +799,7 @@ func fileCommand(opts *cliOpts, filepath string) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Printf("*** Mode: File, process LAS file at %s\n", filepath)
+	fmt.Printf("*** Mode: File, process point cloud file at %s\n", filepath)
 	opts.print()
 	tilerOpts := opts.getTilerOptions()
 	crs := opts.crs
@@ -283,7 +826,11 @@ func folderCommand(opts *cliOpts, folderpath string) {
 	}
 	runnable := func(ctx context.Context) error {
 		if opts.join {
-			files, err := utils.FindLasFilesInFolder(folderpath)
+			findFiles := utils.FindLasFilesInFolder
+			if opts.textColumns != "" {
+				findFiles = utils.FindTextFilesInFolder
+			}
+			files, err := findFiles(folderpath)
 			if err != nil {
 				return err
 			}
@@ -294,6 +841,53 @@ func folderCommand(opts *cliOpts, folderpath string) {
 	launch(runnable)
 }
 
+func serveCommand(opts *cliOpts, path string) {
+	if path == "" {
+		log.Fatal("the tileset folder or .3tz archive to serve must be set")
+	}
+	s, err := server.NewServer(path, server.WithCesiumURL(opts.cesiumURL))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer s.Close()
+	fmt.Printf("*** Mode: Serve, serving %s\n", path)
+	fmt.Printf("*** Viewer available at http://%s/\n", opts.address)
+	fmt.Printf("*** Tilesets available at http://%s/tiles/\n", opts.address)
+	runnable := func(ctx context.Context) error {
+		return listenAndServe(opts.address, s, ctx)
+	}
+	launch(runnable)
+}
+
+func validateCommand(opts *cliOpts, path string) {
+	if path == "" {
+		log.Fatal("the tileset folder, tileset.json file or .3tz archive to validate must be set")
+	}
+	fmt.Printf("*** Mode: Validate, validating %s\n", path)
+	report, err := validator.ValidatePath(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out := os.Stdout
+	if opts.reportPath != "" {
+		if out, err = os.Create(opts.reportPath); err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+	}
+	if opts.jsonReport {
+		err = report.WriteJSON(out)
+	} else {
+		err = report.WriteText(out)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !report.Valid() {
+		exit(1)
+	}
+}
+
 func launch(function func(ctx context.Context) error) {
 	ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt)
 	wg := &sync.WaitGroup{}
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/utils"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/writer"
 	"github.com/mfbonfigli/gocesiumtiler/v2/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
 	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/mutator"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/validator"
 	"github.com/mfbonfigli/gocesiumtiler/v2/version"
 )
 
@@ -38,6 +49,13 @@ func TestMainProcessFile(t *testing.T) {
 		"-subsample", "0.57",
 		"-min-points-per-tile", "1200",
 		"-8-bit",
+		"-copc-bbox", "1,2,3,4",
+		"-copc-level", "5",
+		"-las-attributes", "gps-time,intensity-16",
+		"-extra-dims", "Amplitude, Reflectance",
+		"-draco",
+		"-draco-position-bits", "12",
+		"-draco-color-bits", "6",
 		"myfile.las"}
 	main()
 	if mockTiler.ProcessFilesCalled != true {
@@ -76,6 +94,56 @@ func TestMainProcessFile(t *testing.T) {
 	if actual := mockTiler.Version; actual != version.TilesetVersion_1_0 {
 		t.Errorf("expected tiler to be called with Version %v but got %v", "1.0", actual)
 	}
+	expectedBounds := geom.NewBoundingBox(1, 3, 2, 4, math.Inf(-1), math.Inf(1))
+	if actual := mockTiler.CopcBounds; actual == nil || actual.Xmin != expectedBounds.Xmin || actual.Xmax != expectedBounds.Xmax ||
+		actual.Ymin != expectedBounds.Ymin || actual.Ymax != expectedBounds.Ymax || actual.Zmin != expectedBounds.Zmin || actual.Zmax != expectedBounds.Zmax {
+		t.Errorf("expected tiler to be called with CopcBounds %v but got %v", expectedBounds, actual)
+	}
+	if actual := mockTiler.CopcMaxLevel; actual != 5 {
+		t.Errorf("expected tiler to be called with CopcMaxLevel %v but got %v", 5, actual)
+	}
+	if actual := mockTiler.LasAttrs; !reflect.DeepEqual(actual, []string{"gps-time", "intensity-16"}) {
+		t.Errorf("expected tiler to be called with LasAttrs %v but got %v", []string{"gps-time", "intensity-16"}, actual)
+	}
+	if actual := mockTiler.ExtraDims; !reflect.DeepEqual(actual, []string{"Amplitude", "Reflectance"}) {
+		t.Errorf("expected tiler to be called with ExtraDims %v but got %v", []string{"Amplitude", "Reflectance"}, actual)
+	}
+	expectedDraco := writer.DracoOptions{PositionBits: 12, ColorBits: 6, AttributeBits: 16}
+	if actual := mockTiler.Draco; actual == nil || *actual != expectedDraco {
+		t.Errorf("expected tiler to be called with Draco %v but got %v", expectedDraco, actual)
+	}
+}
+
+func TestMainProcessFileGeoid(t *testing.T) {
+	// 2 x 2 gtx geoid grid with a constant undulation
+	gtx := &bytes.Buffer{}
+	binary.Write(gtx, binary.BigEndian, []float64{40, 10, 1, 1})
+	binary.Write(gtx, binary.BigEndian, []int32{2, 2})
+	binary.Write(gtx, binary.BigEndian, []float32{50, 50, 50, 50})
+	path := filepath.Join(t.TempDir(), "geoid.gtx")
+	if err := os.WriteFile(path, gtx.Bytes(), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mockTiler := &tiler.MockTiler{}
+	tilerProvider = func() (tiler.Tiler, error) {
+		return mockTiler, nil
+	}
+	os.Args = []string{"gocesiumtiler", "file",
+		"-out", ".\\abc",
+		"-crs", "EPSG:4979",
+		"-geoid", path,
+		"myfile.las"}
+	main()
+	if actual := len(mockTiler.Mutators); actual != 2 {
+		t.Fatalf("expected 2 mutators but got %v", actual)
+	}
+	if _, ok := mockTiler.Mutators[0].(*mutator.Geoid); !ok {
+		t.Errorf("expected tiler to be called with a Geoid mutator first but got %T", mockTiler.Mutators[0])
+	}
+	if actual := mockTiler.Mutators[1].(*mutator.ZOffset).Offset; actual != 0 {
+		t.Errorf("expected tiler to be called with ZOffset mutator with offset %v but got %v", 0, actual)
+	}
 }
 
 func TestMainProcessFolder(t *testing.T) {
@@ -92,6 +160,13 @@ func TestMainProcessFolder(t *testing.T) {
 		"-min-points-per-tile", "1200",
 		"-8-bit",
 		"-v", "1.0",
+		"-quantize-positions",
+		"-rgb565",
+		"-gzip", "sidecar",
+		"-memory-limit", "2048",
+		"-temp-dir", "/tmp/spill",
+		"-parallel-build",
+		"-crs-engine", "native",
 		"myfolder"}
 	main()
 	if mockTiler.ProcessFolderCalled != true {
@@ -127,6 +202,42 @@ func TestMainProcessFolder(t *testing.T) {
 	if actual := mockTiler.Version; actual != version.TilesetVersion_1_0 {
 		t.Errorf("expected tiler to be called with Version %v but got %v", "1.0", actual)
 	}
+	if actual := mockTiler.CopcBounds; actual != nil {
+		t.Errorf("expected tiler to be called with nil CopcBounds but got %v", actual)
+	}
+	if actual := mockTiler.CopcMaxLevel; actual != -1 {
+		t.Errorf("expected tiler to be called with CopcMaxLevel %v but got %v", -1, actual)
+	}
+	if actual := mockTiler.Draco; actual != nil {
+		t.Errorf("expected tiler to be called with nil Draco but got %v", actual)
+	}
+	if mockTiler.Quantize != true || mockTiler.RGB565 != true {
+		t.Errorf("expected tiler to be called with Quantize and RGB565 but got %v %v", mockTiler.Quantize, mockTiler.RGB565)
+	}
+	if actual := mockTiler.SubtreeLevels; actual != 0 {
+		t.Errorf("expected tiler to be called with SubtreeLevels %v but got %v", 0, actual)
+	}
+	if mockTiler.Archive {
+		t.Errorf("expected tiler not to be called with Archive")
+	}
+	if actual := mockTiler.Gzip; actual != writer.GzipSidecar {
+		t.Errorf("expected tiler to be called with Gzip %v but got %v", writer.GzipSidecar, actual)
+	}
+	if actual := mockTiler.MemoryLimit; actual != 2048<<20 {
+		t.Errorf("expected tiler to be called with MemoryLimit %v but got %v", 2048<<20, actual)
+	}
+	if actual := mockTiler.TempDir; actual != "/tmp/spill" {
+		t.Errorf("expected tiler to be called with TempDir %v but got %v", "/tmp/spill", actual)
+	}
+	if !mockTiler.ParallelBuild {
+		t.Errorf("expected tiler to be called with ParallelBuild")
+	}
+	if actual := mockTiler.Converter; actual != tiler.ConverterNative {
+		t.Errorf("expected tiler to be called with Converter %v but got %v", tiler.ConverterNative, actual)
+	}
+	if actual := mockTiler.FileCRS; actual != nil {
+		t.Errorf("expected tiler to be called with nil FileCRS but got %v", actual)
+	}
 }
 
 func TestMainProcessFolderJoin(t *testing.T) {
@@ -142,6 +253,10 @@ func TestMainProcessFolderJoin(t *testing.T) {
 	utils.TouchFile(filepath.Join(tmp, "test0.xyz"))
 	utils.TouchFile(filepath.Join(tmp, "test1.LAS"))
 	utils.TouchFile(filepath.Join(tmp, "test2.LAS"))
+	manifest := filepath.Join(t.TempDir(), "crs.csv")
+	if err := os.WriteFile(manifest, []byte("file,crs\n"+filepath.Join(tmp, "test1.LAS")+",32632\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
 
 	mockTiler := &tiler.MockTiler{}
 	tilerProvider = func() (tiler.Tiler, error) {
@@ -155,7 +270,13 @@ func TestMainProcessFolderJoin(t *testing.T) {
 		"-depth", "13",
 		"-min-points-per-tile", "1200",
 		"-8-bit",
+		"-crs-manifest", manifest,
 		"-v", "1.1",
+		"-quantize-positions",
+		"-meshopt",
+		"-implicit",
+		"-subtree-levels", "3",
+		"-format", "3tz",
 		"-join",
 		tmp}
 	main()
@@ -200,4 +321,239 @@ func TestMainProcessFolderJoin(t *testing.T) {
 	if actual := mockTiler.Version; actual != version.TilesetVersion_1_1 {
 		t.Errorf("expected tiler to be called with Version %v but got %v", "1.1", actual)
 	}
+	if mockTiler.Quantize != true || mockTiler.Meshopt != true {
+		t.Errorf("expected tiler to be called with Quantize and Meshopt but got %v %v", mockTiler.Quantize, mockTiler.Meshopt)
+	}
+	if actual := mockTiler.SubtreeLevels; actual != 3 {
+		t.Errorf("expected tiler to be called with SubtreeLevels %v but got %v", 3, actual)
+	}
+	if !mockTiler.Archive {
+		t.Errorf("expected tiler to be called with Archive")
+	}
+	if actual := mockTiler.Gzip; actual != writer.GzipNone {
+		t.Errorf("expected tiler to be called with Gzip %v but got %v", writer.GzipNone, actual)
+	}
+	if actual := mockTiler.MemoryLimit; actual != 0 {
+		t.Errorf("expected tiler to be called with MemoryLimit %v but got %v", 0, actual)
+	}
+	if mockTiler.ParallelBuild {
+		t.Errorf("expected tiler not to be called with ParallelBuild")
+	}
+	if actual := mockTiler.Converter; actual != tiler.ConverterAuto {
+		t.Errorf("expected tiler to be called with Converter %v but got %v", tiler.ConverterAuto, actual)
+	}
+	if expected := map[string]string{filepath.Join(tmp, "test1.LAS"): "EPSG:32632"}; !reflect.DeepEqual(mockTiler.FileCRS, expected) {
+		t.Errorf("expected tiler to be called with FileCRS %v but got %v", expected, mockTiler.FileCRS)
+	}
+}
+
+func TestMainProcessFileControlPoints(t *testing.T) {
+	// local coordinates rotated by 90 degrees and translated
+	path := filepath.Join(t.TempDir(), "gcp.csv")
+	content := "sx,sy,sz,tx,ty,tz\n0,0,0,500000,4000000,100\n10,0,0,500000,4000010,100\n0,10,0,499990,4000000,100\n0,0,10,500000,4000000,110\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mockTiler := &tiler.MockTiler{}
+	tilerProvider = func() (tiler.Tiler, error) {
+		return mockTiler, nil
+	}
+	os.Args = []string{"gocesiumtiler", "file",
+		"-out", ".\\abc",
+		"-crs", "EPSG:32633",
+		"-control-points", path,
+		"myfile.las"}
+	main()
+	if actual := len(mockTiler.Mutators); actual != 2 {
+		t.Fatalf("expected 2 mutators but got %v", actual)
+	}
+	a, ok := mockTiler.Mutators[0].(*mutator.Affine)
+	if !ok {
+		t.Fatalf("expected tiler to be called with an Affine mutator first but got %T", mockTiler.Mutators[0])
+	}
+	expected := model.Vector{X: 499995, Y: 4000005, Z: 105}
+	if actual := a.MutateSource(model.Vector{X: 5, Y: 5, Z: 5}); actual.Subtract(expected).Norm() > 1e-6 {
+		t.Errorf("expected transformed coordinates %v but got %v", expected, actual)
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	tcs := []struct {
+		transform string
+		expected  *model.Vector
+		err       bool
+	}{
+		{transform: ""},
+		{transform: "1,0,0,10,0,1,0,20,0,0,1,30", expected: &model.Vector{X: 11, Y: 21, Z: 31}},
+		{transform: "2, 0, 0, 0, 0, 2, 0, 0, 0, 0, 2, 0, 0, 0, 0, 1", expected: &model.Vector{X: 2, Y: 2, Z: 2}},
+		{transform: "1,0,0,10,0,1,0,20,0,0,1", err: true},
+		{transform: "1,0,0,10,0,1,0,20,0,0,a,30", err: true},
+		{transform: "0,0,0,10,0,1,0,20,0,0,1,30", err: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.transform, func(t *testing.T) {
+			c := defaultCliOptions()
+			c.transform = tc.transform
+			tr, err := c.parseTransform()
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tc.expected == nil {
+				if tr != nil {
+					t.Errorf("expected no transform got %v", tr)
+				}
+				return
+			}
+			if actual := tr.Forward(model.Vector{X: 1, Y: 1, Z: 1}); actual != *tc.expected {
+				t.Errorf("expected %v got %v", *tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseCopcBBox(t *testing.T) {
+	tcs := []struct {
+		bbox        string
+		expectedMin *model.Vector
+		expectedMax *model.Vector
+		err         bool
+	}{
+		{bbox: ""},
+		{bbox: "1,2,3,4", expectedMin: &model.Vector{X: 1, Y: 2, Z: math.Inf(-1)}, expectedMax: &model.Vector{X: 3, Y: 4, Z: math.Inf(1)}},
+		{bbox: "1, 2, 3, 4, 5, 6", expectedMin: &model.Vector{X: 1, Y: 2, Z: 3}, expectedMax: &model.Vector{X: 4, Y: 5, Z: 6}},
+		{bbox: "1,2,3", err: true},
+		{bbox: "1,2,a,4", err: true},
+		{bbox: "3,2,1,4", err: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.bbox, func(t *testing.T) {
+			c := defaultCliOptions()
+			c.copcBBox = tc.bbox
+			min, max, err := c.parseCopcBBox()
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(min, tc.expectedMin) || !reflect.DeepEqual(max, tc.expectedMax) {
+				t.Errorf("expected %v %v got %v %v", tc.expectedMin, tc.expectedMax, min, max)
+			}
+		})
+	}
+}
+
+func TestMainProcessFolderJoinText(t *testing.T) {
+	tmp := t.TempDir()
+	utils.TouchFile(filepath.Join(tmp, "test0.las"))
+	utils.TouchFile(filepath.Join(tmp, "test1.xyz"))
+	utils.TouchFile(filepath.Join(tmp, "test2.csv"))
+
+	mockTiler := &tiler.MockTiler{}
+	tilerProvider = func() (tiler.Tiler, error) {
+		return mockTiler, nil
+	}
+	os.Args = []string{"gocesiumtiler", "folder",
+		"-out", ".\\abc",
+		"-epsg", "4979",
+		"-text-columns", "x,y,z,_,r,g,b",
+		"-text-delimiter", ",",
+		"-text-skip-rows", "1",
+		"-text-color-bits", "8",
+		"-join",
+		tmp}
+	main()
+	if mockTiler.ProcessFilesCalled != true {
+		t.Error("expected processFiles called but was not")
+	}
+	expected := []string{
+		filepath.Join(tmp, "test1.xyz"),
+		filepath.Join(tmp, "test2.csv"),
+	}
+	if actual := mockTiler.InputFiles; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected tiler to be called with %v but got %v", expected, actual)
+	}
+	if actual := mockTiler.TextColumns; actual != "x,y,z,_,r,g,b" {
+		t.Errorf("expected tiler to be called with TextColumns %v but got %v", "x,y,z,_,r,g,b", actual)
+	}
+	if actual := mockTiler.TextColorBits; actual != 8 {
+		t.Errorf("expected tiler to be called with TextColorBits %v but got %v", 8, actual)
+	}
+}
+
+func TestMainServe(t *testing.T) {
+	tmp := t.TempDir()
+	os.WriteFile(filepath.Join(tmp, "tileset.json"), []byte("{}"), 0o644)
+	cesium := t.TempDir()
+
+	var actualAddr string
+	var status int
+	listenAndServe = func(addr string, h http.Handler, ctx context.Context) error {
+		actualAddr = addr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tiles/tileset.json", nil))
+		status = rec.Code
+		return nil
+	}
+	os.Args = []string{"gocesiumtiler", "serve",
+		"-address", "127.0.0.1:9000",
+		"-cesium", cesium,
+		tmp}
+	main()
+	if actualAddr != "127.0.0.1:9000" {
+		t.Errorf("expected server to listen on %s but got %s", "127.0.0.1:9000", actualAddr)
+	}
+	if status != http.StatusOK {
+		t.Errorf("expected tileset.json to be served with status 200 but got %d", status)
+	}
+}
+
+func TestMainValidate(t *testing.T) {
+	tmp := t.TempDir()
+	os.WriteFile(filepath.Join(tmp, "tileset.json"), []byte(`{
+		"asset": {"version": "1.0"},
+		"geometricError": 10,
+		"root": {
+			"boundingVolume": {"box": [0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1]},
+			"geometricError": 10,
+			"refine": "ADD",
+			"content": {"uri": "content.pnts"}
+		}
+	}`), 0o644)
+	report := filepath.Join(tmp, "report.json")
+
+	exitCode := 0
+	exit = func(code int) {
+		exitCode = code
+	}
+	defer func() { exit = os.Exit }()
+	os.Args = []string{"gocesiumtiler", "validate",
+		"-json",
+		"-report", report,
+		tmp}
+	main()
+	if exitCode != 1 {
+		t.Errorf("expected exit code 1 but got %d", exitCode)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	actual := validator.Report{}
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(actual.Issues) != 1 || actual.Issues[0].Location != "root.content.uri" {
+		t.Errorf("expected one issue about the missing content, got %v", actual.Issues)
+	}
 }
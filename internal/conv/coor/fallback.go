@@ -0,0 +1,65 @@
+package coor
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+const epsg4978crs = "EPSG:4978"
+
+// fallbackConverter converts the coordinates with the primary converter when it supports both the source and the
+// target CRS, and with the fallback converter otherwise
+type fallbackConverter struct {
+	primary         Converter
+	supports        func(crs string) bool
+	fallbackFactory ConverterFactory
+	fallback        Converter
+}
+
+// NewFallbackConverter returns a Converter that uses the primary converter for the CRSs for which supports returns
+// true and a converter created with the fallback factory for all other CRSs. The fallback converter is only created
+// the first time it is needed, so that its initialization cost, and its failures, only affect unsupported CRSs.
+func NewFallbackConverter(primary Converter, supports func(crs string) bool, fallback ConverterFactory) Converter {
+	return &fallbackConverter{
+		primary:         primary,
+		supports:        supports,
+		fallbackFactory: fallback,
+	}
+}
+
+func (c *fallbackConverter) Transform(sourceCRS string, targetCRS string, coord model.Vector) (model.Vector, error) {
+	conv, err := c.converter(sourceCRS, targetCRS)
+	if err != nil {
+		return model.Vector{}, err
+	}
+	return conv.Transform(sourceCRS, targetCRS, coord)
+}
+
+func (c *fallbackConverter) ToWGS84Cartesian(sourceCRS string, coord model.Vector) (model.Vector, error) {
+	conv, err := c.converter(sourceCRS, epsg4978crs)
+	if err != nil {
+		return model.Vector{}, err
+	}
+	return conv.ToWGS84Cartesian(sourceCRS, coord)
+}
+
+func (c *fallbackConverter) Cleanup() {
+	c.primary.Cleanup()
+	if c.fallback != nil {
+		c.fallback.Cleanup()
+	}
+}
+
+// converter returns the converter to use to convert between the given CRSs
+func (c *fallbackConverter) converter(sourceCRS string, targetCRS string) (Converter, error) {
+	if c.supports(sourceCRS) && c.supports(targetCRS) {
+		return c.primary, nil
+	}
+	if c.fallback == nil {
+		conv, err := c.fallbackFactory()
+		if err != nil {
+			return nil, err
+		}
+		c.fallback = conv
+	}
+	return c.fallback, nil
+}
@@ -0,0 +1,80 @@
+package coor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// mockConverter returns the given coordinates, with X set to its id, and counts the calls
+type mockConverter struct {
+	id       float64
+	calls    int
+	cleanups int
+}
+
+func (m *mockConverter) Transform(sourceCRS string, targetCRS string, coord model.Vector) (model.Vector, error) {
+	m.calls++
+	coord.X = m.id
+	return coord, nil
+}
+
+func (m *mockConverter) ToWGS84Cartesian(sourceCRS string, coord model.Vector) (model.Vector, error) {
+	return m.Transform(sourceCRS, epsg4978crs, coord)
+}
+
+func (m *mockConverter) Cleanup() {
+	m.cleanups++
+}
+
+func TestFallbackConverter(t *testing.T) {
+	primary := &mockConverter{id: 1}
+	fallback := &mockConverter{id: 2}
+	created := 0
+	supports := func(crs string) bool {
+		return crs == "EPSG:4326" || crs == epsg4978crs
+	}
+	c := NewFallbackConverter(primary, supports, func() (Converter, error) {
+		created++
+		return fallback, nil
+	})
+
+	if out, err := c.ToWGS84Cartesian("EPSG:4326", model.Vector{}); err != nil || out.X != 1 {
+		t.Errorf("expected the primary converter to be used, got %v %v", out, err)
+	}
+	if out, err := c.Transform("EPSG:4978", "EPSG:4326", model.Vector{}); err != nil || out.X != 1 {
+		t.Errorf("expected the primary converter to be used, got %v %v", out, err)
+	}
+	if created != 0 {
+		t.Errorf("expected the fallback converter not to be created")
+	}
+	if out, err := c.ToWGS84Cartesian("EPSG:3124", model.Vector{}); err != nil || out.X != 2 {
+		t.Errorf("expected the fallback converter to be used, got %v %v", out, err)
+	}
+	if out, err := c.Transform("EPSG:4326", "EPSG:3124", model.Vector{}); err != nil || out.X != 2 {
+		t.Errorf("expected the fallback converter to be used, got %v %v", out, err)
+	}
+	if created != 1 || primary.calls != 2 || fallback.calls != 2 {
+		t.Errorf("unexpected calls: created %d, primary %d, fallback %d", created, primary.calls, fallback.calls)
+	}
+	c.Cleanup()
+	if primary.cleanups != 1 || fallback.cleanups != 1 {
+		t.Errorf("expected both converters to be cleaned up")
+	}
+}
+
+func TestFallbackConverterError(t *testing.T) {
+	primary := &mockConverter{id: 1}
+	c := NewFallbackConverter(primary, func(string) bool { return false }, func() (Converter, error) {
+		return nil, errors.New("unavailable")
+	})
+	if _, err := c.ToWGS84Cartesian("EPSG:3124", model.Vector{}); err == nil {
+		t.Errorf("expected error got none")
+	}
+	// the primary converter is always cleaned up
+	c.Cleanup()
+	if primary.cleanups != 1 {
+		t.Errorf("expected the primary converter to be cleaned up")
+	}
+}
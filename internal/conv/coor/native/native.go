@@ -0,0 +1,162 @@
+// Package native implements a pure Go coordinate converter for the most common CRSs, that does not depend on PROJ
+// nor on its database and grids. Supported CRSs are WGS84 geographic (EPSG:4326 and EPSG:4979), WGS84 geocentric
+// (EPSG:4978), Web Mercator (EPSG:3857) and the UTM zones on WGS84 (EPSG:32601-32660 and EPSG:32701-32760) and
+// on ETRS89 (EPSG:25828-25838). ETRS89 coordinates are considered coincident with WGS84 ones, as PROJ does when no
+// time dependent transformation is requested, which is accurate at the meter level.
+package native
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+const epsg4978crs = "EPSG:4978"
+
+// crs converts coordinates expressed in a given CRS from and to WGS84 geodetic coordinates: latitude and longitude
+// in degrees and height above the ellipsoid in meters
+type crs interface {
+	toGeodetic(coord model.Vector) (lat, lon, h float64)
+	fromGeodetic(lat, lon, h float64) model.Vector
+}
+
+// geographic coordinates store the longitude in X, the latitude in Y and the ellipsoidal height in Z,
+// as PROJ does when normalizing the axis order for visualization
+type geographic struct{}
+
+func (geographic) toGeodetic(coord model.Vector) (lat, lon, h float64) {
+	return coord.Y, coord.X, coord.Z
+}
+
+func (geographic) fromGeodetic(lat, lon, h float64) model.Vector {
+	return model.Vector{X: lon, Y: lat, Z: h}
+}
+
+// geocentric coordinates are earth centered earth fixed cartesian coordinates
+type geocentric struct{}
+
+func (geocentric) toGeodetic(coord model.Vector) (lat, lon, h float64) {
+	return geom.ECEFToGeodetic(coord.X, coord.Y, coord.Z)
+}
+
+func (geocentric) fromGeodetic(lat, lon, h float64) model.Vector {
+	return geom.GeodeticToECEF(lat, lon, h)
+}
+
+// webMercator is the spherical Mercator projection of WGS84 geodetic coordinates used by web maps
+type webMercator struct{}
+
+func (webMercator) toGeodetic(coord model.Vector) (lat, lon, h float64) {
+	return math.Atan(math.Sinh(coord.Y/wgs84.a)) * 180 / math.Pi, coord.X / wgs84.a * 180 / math.Pi, coord.Z
+}
+
+func (webMercator) fromGeodetic(lat, lon, h float64) model.Vector {
+	return model.Vector{
+		X: wgs84.a * lon * math.Pi / 180,
+		Y: wgs84.a * math.Asinh(math.Tan(lat*math.Pi/180)),
+		Z: h,
+	}
+}
+
+// projected coordinates store the easting in X, the northing in Y and the ellipsoidal height in Z
+type projected struct {
+	*transverseMercator
+}
+
+func (p projected) toGeodetic(coord model.Vector) (lat, lon, h float64) {
+	lat, lon = p.inverse(coord.X, coord.Y)
+	return lat, lon, coord.Z
+}
+
+func (p projected) fromGeodetic(lat, lon, h float64) model.Vector {
+	x, y := p.forward(lat, lon)
+	return model.Vector{X: x, Y: y, Z: h}
+}
+
+// parseCRS returns the CRS corresponding to the given EPSG:XYZ code, if supported
+func parseCRS(code string) (crs, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !strings.HasPrefix(code, "EPSG:") {
+		return nil, false
+	}
+	epsg, err := strconv.Atoi(strings.TrimPrefix(code, "EPSG:"))
+	if err != nil {
+		return nil, false
+	}
+	switch {
+	case epsg == 4326 || epsg == 4979:
+		return geographic{}, true
+	case epsg == 4978:
+		return geocentric{}, true
+	case epsg == 3857:
+		return webMercator{}, true
+	case epsg >= 32601 && epsg <= 32660:
+		return projected{newUTM(wgs84, epsg-32600, false)}, true
+	case epsg >= 32701 && epsg <= 32760:
+		return projected{newUTM(wgs84, epsg-32700, true)}, true
+	case epsg >= 25828 && epsg <= 25838:
+		return projected{newUTM(grs80, epsg-25800, false)}, true
+	}
+	return nil, false
+}
+
+// Supports returns true if the given CRS can be converted by the native converter
+func Supports(crs string) bool {
+	_, ok := parseCRS(crs)
+	return ok
+}
+
+type nativeCoordinateConverter struct {
+	crss map[string]crs
+}
+
+// NewNativeCoordinateConverter returns a converter between the supported CRSs. The converter caches the parsed
+// CRSs and is not designed for concurrent usage by multiple goroutines.
+func NewNativeCoordinateConverter() *nativeCoordinateConverter {
+	return &nativeCoordinateConverter{
+		crss: make(map[string]crs),
+	}
+}
+
+// Converts the given coordinate from the given source crs to the given target crs.
+func (cc *nativeCoordinateConverter) Transform(sourceCRS string, targetCRS string, coord model.Vector) (model.Vector, error) {
+	if sourceCRS == targetCRS {
+		return coord, nil
+	}
+	source, err := cc.getCRS(sourceCRS)
+	if err != nil {
+		return model.Vector{}, err
+	}
+	target, err := cc.getCRS(targetCRS)
+	if err != nil {
+		return model.Vector{}, err
+	}
+	return target.fromGeodetic(source.toGeodetic(coord)), nil
+}
+
+// Converts the input coordinate from the given CRS to EPSG:4978 srid
+func (cc *nativeCoordinateConverter) ToWGS84Cartesian(sourceCRS string, coord model.Vector) (model.Vector, error) {
+	return cc.Transform(sourceCRS, epsg4978crs, coord)
+}
+
+// Cleanup releases the cached CRSs
+func (cc *nativeCoordinateConverter) Cleanup() {
+	cc.crss = make(map[string]crs)
+}
+
+// getCRS returns the CRS corresponding to the given code, caching it internally to be reused
+func (cc *nativeCoordinateConverter) getCRS(code string) (crs, error) {
+	if c, ok := cc.crss[code]; ok {
+		return c, nil
+	}
+	c, ok := parseCRS(code)
+	if !ok {
+		return nil, fmt.Errorf("crs %s is not supported by the built-in converter", code)
+	}
+	cc.crss[code] = c
+	return c, nil
+}
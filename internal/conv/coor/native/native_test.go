@@ -0,0 +1,190 @@
+package native
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/utils"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+var coordTolerance = 0.001
+
+func TestTransform(t *testing.T) {
+	c := NewNativeCoordinateConverter()
+	defer c.Cleanup()
+
+	cases := []struct {
+		name     string
+		source   string
+		target   string
+		input    model.Vector
+		expected model.Vector
+	}{
+		{
+			name:     "4326 to 4978",
+			source:   "EPSG:4326",
+			target:   "EPSG:4978",
+			input:    model.Vector{X: 123.474003, Y: 8.099314, Z: 0},
+			expected: model.Vector{X: -3483057.5277292132, Y: 5267517.241803079, Z: 892655.4197953615},
+		},
+		{
+			name:     "4978 to 4979",
+			source:   "EPSG:4978",
+			target:   "EPSG:4979",
+			input:    model.Vector{X: -3483057.5277292132, Y: 5267517.241803079, Z: 892655.4197953615},
+			expected: model.Vector{X: 123.474003, Y: 8.099314, Z: 0},
+		},
+		{
+			// GeographicLib GeoConvert documentation example
+			name:     "4326 to UTM 38N",
+			source:   "EPSG:4326",
+			target:   "EPSG:32638",
+			input:    model.Vector{X: 44.4, Y: 33.3, Z: 10},
+			expected: model.Vector{X: 444140.54, Y: 3684706.36, Z: 10},
+		},
+		{
+			// north pole: the meridian quadrant of WGS84 scaled by the UTM scale factor
+			name:     "4326 to UTM 33N",
+			source:   "EPSG:4326",
+			target:   "epsg:32633",
+			input:    model.Vector{X: 15, Y: 90, Z: 0},
+			expected: model.Vector{X: 500000, Y: 0.9996 * 10001965.7293, Z: 0},
+		},
+		{
+			name:     "4326 to UTM 33S",
+			source:   "EPSG:4326",
+			target:   "EPSG:32733",
+			input:    model.Vector{X: 15, Y: -90, Z: 0},
+			expected: model.Vector{X: 500000, Y: 10000000 - 0.9996*10001965.7293, Z: 0},
+		},
+		{
+			name:     "4326 to 3857",
+			source:   "EPSG:4326",
+			target:   "EPSG:3857",
+			input:    model.Vector{X: 180, Y: 85.05112877980659, Z: 5},
+			expected: model.Vector{X: 20037508.342789244, Y: 20037508.342789244, Z: 5},
+		},
+		{
+			name:     "3857 to 4326",
+			source:   "EPSG:3857",
+			target:   "EPSG:4326",
+			input:    model.Vector{X: -20037508.342789244, Y: 0, Z: 5},
+			expected: model.Vector{X: -180, Y: 0, Z: 5},
+		},
+		{
+			name:     "same crs",
+			source:   "EPSG:3124",
+			target:   "EPSG:3124",
+			input:    model.Vector{X: 1, Y: 2, Z: 3},
+			expected: model.Vector{X: 1, Y: 2, Z: 3},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := c.Transform(tc.source, tc.target, tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err := utils.CompareCoord(actual, tc.expected, 0.01); err != nil {
+				t.Errorf("expected coordinate %v, got %v. Err: %v", tc.expected, actual, err)
+			}
+		})
+	}
+}
+
+// meridianArc returns the distance along the meridian from the equator to the given latitude, in degrees,
+// numerically integrating the meridian radius of curvature
+func meridianArc(el ellipsoid, lat float64) float64 {
+	e2 := el.f * (2 - el.f)
+	radius := func(phi float64) float64 {
+		return el.a * (1 - e2) / math.Pow(1-e2*math.Sin(phi)*math.Sin(phi), 1.5)
+	}
+	// Simpson's rule
+	steps := 1000
+	h := lat * math.Pi / 180 / float64(steps)
+	sum := radius(0) + radius(lat*math.Pi/180)
+	for i := 1; i < steps; i++ {
+		w := 2.0
+		if i%2 == 1 {
+			w = 4
+		}
+		sum += w * radius(float64(i)*h)
+	}
+	return sum * h / 3
+}
+
+func TestUTMCentralMeridian(t *testing.T) {
+	c := NewNativeCoordinateConverter()
+	for lat := -80.0; lat <= 84; lat += 8 {
+		for _, utm := range []struct {
+			crs string
+			el  ellipsoid
+		}{{"EPSG:32631", wgs84}, {"EPSG:25831", grs80}} {
+			actual, err := c.Transform("EPSG:4326", utm.crs, model.Vector{X: 3, Y: lat})
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			expected := model.Vector{X: 500000, Y: 0.9996 * meridianArc(utm.el, lat)}
+			if err := utils.CompareCoord(actual, expected, coordTolerance); err != nil {
+				t.Errorf("%s at latitude %f expected coordinate %v, got %v. Err: %v", utm.crs, lat, expected, actual, err)
+			}
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	c := NewNativeCoordinateConverter()
+	for _, code := range []string{"EPSG:32632", "EPSG:32755", "EPSG:25832", "EPSG:3857", "EPSG:4978"} {
+		center := 9.0
+		if code == "EPSG:32755" {
+			center = 147
+		}
+		for lat := -75.0; lat <= 75; lat += 5 {
+			// up to 6 degrees from the central meridian, twice the UTM zone half width
+			for lon := center - 6; lon <= center+6; lon += 1.5 {
+				input := model.Vector{X: lon, Y: lat, Z: 100}
+				projected, err := c.Transform("EPSG:4979", code, input)
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				actual, err := c.Transform(code, "EPSG:4979", projected)
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if err := utils.CompareCoord(actual, input, 1e-6); err != nil {
+					t.Errorf("%s expected coordinate %v, got %v. Err: %v", code, input, actual, err)
+				}
+				// the projected to geocentric conversion goes through the geodetic coordinates
+				expected, err := c.ToWGS84Cartesian("EPSG:4326", input)
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				ecef, err := c.ToWGS84Cartesian(code, projected)
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if err := utils.CompareCoord(ecef, expected, coordTolerance); err != nil {
+					t.Errorf("%s expected coordinate %v, got %v. Err: %v", code, expected, ecef, err)
+				}
+			}
+		}
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	c := NewNativeCoordinateConverter()
+	for _, code := range []string{"EPSG:3124", "EPSG:32633+5773", "EPSG:32661", "+proj=utm +zone=33", "PROJCS[\"WGS 84 / UTM zone 33N\"]", ""} {
+		if Supports(code) {
+			t.Errorf("expected %q not to be supported", code)
+		}
+		if _, err := c.ToWGS84Cartesian(code, model.Vector{}); err == nil {
+			t.Errorf("expected error for %q got none", code)
+		}
+	}
+	for _, code := range []string{"EPSG:4326", " epsg:4979 ", "EPSG:4978", "EPSG:3857", "EPSG:32601", "EPSG:32760", "EPSG:25828", "EPSG:25838"} {
+		if !Supports(code) {
+			t.Errorf("expected %q to be supported", code)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package native
+
+import "math"
+
+// ellipsoid defines a reference ellipsoid by its semi major axis, in meters, and flattening
+type ellipsoid struct {
+	a, f float64
+}
+
+var (
+	wgs84 = ellipsoid{a: 6378137, f: 1 / 298.257223563}
+	grs80 = ellipsoid{a: 6378137, f: 1 / 298.257222101}
+)
+
+// transverseMercator implements the Transverse Mercator projection using the Krüger series to the sixth order in
+// the third flattening, as described by C. F. F. Karney in "Transverse Mercator with an accuracy of a few
+// nanometers" (2011). Within the UTM zones the error is well below a millimeter.
+type transverseMercator struct {
+	// lon0 is the longitude of the central meridian, in degrees
+	lon0 float64
+	// k0 is the scale factor on the central meridian
+	k0 float64
+	// falseEasting and falseNorthing are added to the projected coordinates, in meters
+	falseEasting, falseNorthing float64
+	// e is the eccentricity of the ellipsoid
+	e float64
+	// ka is the scale factor multiplied by the rectifying radius of the ellipsoid
+	ka float64
+	// alpha and beta are the coefficients of the forward and inverse series
+	alpha, beta [6]float64
+}
+
+// newTransverseMercator returns the Transverse Mercator projection with the given parameters on the given ellipsoid
+func newTransverseMercator(el ellipsoid, lon0, k0, falseEasting, falseNorthing float64) *transverseMercator {
+	n := el.f / (2 - el.f)
+	n2 := n * n
+	n3 := n2 * n
+	n4 := n3 * n
+	n5 := n4 * n
+	n6 := n5 * n
+	return &transverseMercator{
+		lon0:          lon0,
+		k0:            k0,
+		falseEasting:  falseEasting,
+		falseNorthing: falseNorthing,
+		e:             math.Sqrt(el.f * (2 - el.f)),
+		ka:            k0 * el.a / (1 + n) * (1 + n2/4 + n4/64 + n6/256),
+		alpha: [6]float64{
+			n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180 - 127*n5/288 + 7891*n6/37800,
+			13*n2/48 - 3*n3/5 + 557*n4/1440 + 281*n5/630 - 1983433*n6/1935360,
+			61*n3/240 - 103*n4/140 + 15061*n5/26880 + 167603*n6/181440,
+			49561*n4/161280 - 179*n5/168 + 6601661*n6/7257600,
+			34729*n5/80640 - 3418889*n6/1995840,
+			212378941 * n6 / 319334400,
+		},
+		beta: [6]float64{
+			n/2 - 2*n2/3 + 37*n3/96 - n4/360 - 81*n5/512 + 96199*n6/604800,
+			n2/48 + n3/15 - 437*n4/1440 + 46*n5/105 - 1118711*n6/3870720,
+			17*n3/480 - 37*n4/840 - 209*n5/4480 + 5569*n6/90720,
+			4397*n4/161280 - 11*n5/504 - 830251*n6/7257600,
+			4583*n5/161280 - 108847*n6/3991680,
+			20648693 * n6 / 638668800,
+		},
+	}
+}
+
+// newUTM returns the projection of the given UTM zone, between 1 and 60, on the given ellipsoid
+func newUTM(el ellipsoid, zone int, south bool) *transverseMercator {
+	falseNorthing := 0.0
+	if south {
+		falseNorthing = 10000000
+	}
+	return newTransverseMercator(el, float64(zone)*6-183, 0.9996, 500000, falseNorthing)
+}
+
+// conformalTan returns the tangent of the conformal latitude given the tangent of the geodetic latitude
+func (tm *transverseMercator) conformalTan(tau float64) float64 {
+	sigma := math.Sinh(tm.e * math.Atanh(tm.e*tau/math.Hypot(1, tau)))
+	return tau*math.Hypot(1, sigma) - sigma*math.Hypot(1, tau)
+}
+
+// forward projects the given latitude and longitude, in degrees, returning the easting and northing in meters
+func (tm *transverseMercator) forward(lat, lon float64) (x, y float64) {
+	lambda := normalizeLongitude(lon-tm.lon0) * math.Pi / 180
+	tauP := tm.conformalTan(math.Tan(lat * math.Pi / 180))
+	xiP := math.Atan2(tauP, math.Cos(lambda))
+	etaP := math.Asinh(math.Sin(lambda) / math.Hypot(tauP, math.Cos(lambda)))
+	xi, eta := xiP, etaP
+	for j, a := range tm.alpha {
+		k := 2 * float64(j+1)
+		xi += a * math.Sin(k*xiP) * math.Cosh(k*etaP)
+		eta += a * math.Cos(k*xiP) * math.Sinh(k*etaP)
+	}
+	return tm.falseEasting + tm.ka*eta, tm.falseNorthing + tm.ka*xi
+}
+
+// inverse returns the latitude and longitude, in degrees, of the given easting and northing, in meters
+func (tm *transverseMercator) inverse(x, y float64) (lat, lon float64) {
+	xi := (y - tm.falseNorthing) / tm.ka
+	eta := (x - tm.falseEasting) / tm.ka
+	xiP, etaP := xi, eta
+	for j, b := range tm.beta {
+		k := 2 * float64(j+1)
+		xiP -= b * math.Sin(k*xi) * math.Cosh(k*eta)
+		etaP -= b * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+	tauP := math.Sin(xiP) / math.Hypot(math.Sinh(etaP), math.Cos(xiP))
+	lambda := math.Atan2(math.Sinh(etaP), math.Cos(xiP))
+	// solve the conformal latitude equation for the geodetic latitude with the Newton method
+	e2m := 1 - tm.e*tm.e
+	tau := tauP
+	for i := 0; i < 10; i++ {
+		t := tm.conformalTan(tau)
+		d := (tauP - t) * (1 + e2m*tau*tau) / (e2m * math.Hypot(1, t) * math.Hypot(1, tau))
+		tau += d
+		if math.Abs(d) < 1e-14*math.Max(1, math.Abs(tau)) {
+			break
+		}
+	}
+	return math.Atan(tau) * 180 / math.Pi, normalizeLongitude(tm.lon0 + lambda*180/math.Pi)
+}
+
+// normalizeLongitude returns the given longitude, in degrees, in the -180, 180 range
+func normalizeLongitude(lon float64) float64 {
+	lon = math.Mod(lon, 360)
+	if lon > 180 {
+		lon -= 360
+	} else if lon < -180 {
+		lon += 360
+	}
+	return lon
+}
@@ -0,0 +1,31 @@
+//go:build !cgo || noproj
+
+package proj
+
+import (
+	"errors"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// ErrNotAvailable is returned when the binary has been built without PROJ, either because cgo is disabled or
+// because the noproj build tag is set
+var ErrNotAvailable = errors.New("PROJ not available: the binary has been built without cgo or with the noproj tag, only the CRSs supported by the built-in converter can be used")
+
+// projCoordinateConverter is a placeholder for the PROJ converter in builds without PROJ
+type projCoordinateConverter struct{}
+
+// NewProjCoordinateConverter always returns ErrNotAvailable in builds without PROJ
+func NewProjCoordinateConverter() (*projCoordinateConverter, error) {
+	return nil, ErrNotAvailable
+}
+
+func (cc *projCoordinateConverter) Transform(sourceCRS string, targetCRS string, coord model.Vector) (model.Vector, error) {
+	return model.Vector{}, ErrNotAvailable
+}
+
+func (cc *projCoordinateConverter) ToWGS84Cartesian(sourceCRS string, coord model.Vector) (model.Vector, error) {
+	return model.Vector{}, ErrNotAvailable
+}
+
+func (cc *projCoordinateConverter) Cleanup() {}
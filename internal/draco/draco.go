@@ -0,0 +1,305 @@
+// Package draco implements an encoder of point clouds into the Draco bitstream format, version 2.2.
+//
+// Points are encoded with the sequential encoding method. Float attributes are quantized, all the attributes
+// are delta encoded in the point order and the resulting values are entropy coded using rANS.
+// Sorting the points in a spatially coherent order, e.g. Morton order, before encoding them improves the compression.
+package draco
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	versionMajor = 2
+	versionMinor = 2
+)
+
+// GeometryType is the type of geometry stored in the bitstream
+type GeometryType uint8
+
+const (
+	// PointCloud geometries are used by the 3DTILES_draco_point_compression extension
+	PointCloud GeometryType = 0
+	// TriangularMesh geometries are used by the KHR_draco_mesh_compression glTF extension. Points are
+	// encoded as a mesh with no faces.
+	TriangularMesh GeometryType = 1
+)
+
+// AttributeType is the semantic of an attribute
+type AttributeType uint8
+
+const (
+	Position AttributeType = iota
+	Normal
+	Color
+	TexCoord
+	Generic
+)
+
+// DataType is the data type of the components of an attribute, as decoded
+type DataType uint8
+
+const (
+	Int8 DataType = iota + 1
+	Uint8
+	Int16
+	Uint16
+	Int32
+	Uint32
+	Int64
+	Uint64
+	Float32
+	Float64
+)
+
+// encoding methods of the attribute values
+const (
+	sequentialEncoderInteger      = 1
+	sequentialEncoderQuantization = 2
+)
+
+// prediction schemes and transforms, stored as signed bytes
+const (
+	predictionNone       = 0xfe
+	predictionDifference = 0
+	transformWrap        = 1
+)
+
+// attribute holds the values of an attribute to encode
+type attribute struct {
+	attributeType AttributeType
+	dataType      DataType
+	components    int
+	normalized    bool
+	// values are the integer values to encode, in the portable format
+	values []int32
+	// quantization parameters, used by float attributes only
+	quantizationBits int
+	min              []float32
+	rangeValue       float32
+}
+
+// Encoder encodes point attributes into a Draco bitstream
+type Encoder struct {
+	geometry   GeometryType
+	numPoints  int
+	attributes []*attribute
+}
+
+// NewEncoder returns an encoder of the given number of points
+func NewEncoder(numPoints int, opts ...func(*Encoder)) *Encoder {
+	e := &Encoder{
+		geometry:  PointCloud,
+		numPoints: numPoints,
+	}
+	for _, optFn := range opts {
+		optFn(e)
+	}
+	return e
+}
+
+// WithGeometryType sets the type of geometry to write in the bitstream
+func WithGeometryType(t GeometryType) func(*Encoder) {
+	return func(e *Encoder) {
+		e.geometry = t
+	}
+}
+
+// AddFloatAttribute adds an attribute with float32 values, interleaved by component, quantized using the
+// given number of bits. Returns the unique id of the attribute.
+func (e *Encoder) AddFloatAttribute(t AttributeType, components int, values []float32, quantizationBits int) (int, error) {
+	if err := e.checkValues(components, len(values)); err != nil {
+		return 0, err
+	}
+	if quantizationBits < 1 || quantizationBits > 30 {
+		return 0, fmt.Errorf("invalid quantization bits %d, must be between 1 and 30", quantizationBits)
+	}
+	a := &attribute{
+		attributeType:    t,
+		dataType:         Float32,
+		components:       components,
+		quantizationBits: quantizationBits,
+		min:              make([]float32, components),
+		values:           make([]int32, len(values)),
+	}
+	maxValues := make([]float32, components)
+	if len(values) > 0 {
+		copy(a.min, values[:components])
+		copy(maxValues, values[:components])
+	}
+	for i, v := range values {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return 0, errors.New("attribute values must be finite")
+		}
+		c := i % components
+		a.min[c] = min(a.min[c], v)
+		maxValues[c] = max(maxValues[c], v)
+	}
+	for c := range a.min {
+		a.rangeValue = max(a.rangeValue, maxValues[c]-a.min[c])
+	}
+	if a.rangeValue == 0 {
+		a.rangeValue = 1
+	}
+	// same quantization as the reference encoder
+	inverseDelta := float32(uint32(1)<<quantizationBits-1) / a.rangeValue
+	for i, v := range values {
+		a.values[i] = int32(math.Floor(float64((v-a.min[i%components])*inverseDelta + 0.5)))
+	}
+	e.attributes = append(e.attributes, a)
+	return len(e.attributes) - 1, nil
+}
+
+// AddIntAttribute adds an attribute with integer values, interleaved by component, encoded losslessly.
+// The data type is the one of the decoded values and must be an integer type of 32 bits at most.
+// Returns the unique id of the attribute.
+func (e *Encoder) AddIntAttribute(t AttributeType, dataType DataType, components int, normalized bool, values []int32) (int, error) {
+	if err := e.checkValues(components, len(values)); err != nil {
+		return 0, err
+	}
+	if dataType < Int8 || dataType > Uint32 {
+		return 0, fmt.Errorf("unsupported integer data type %d", dataType)
+	}
+	e.attributes = append(e.attributes, &attribute{
+		attributeType: t,
+		dataType:      dataType,
+		components:    components,
+		normalized:    normalized,
+		values:        values,
+	})
+	return len(e.attributes) - 1, nil
+}
+
+func (e *Encoder) checkValues(components int, numValues int) error {
+	if components < 1 || components > 255 {
+		return fmt.Errorf("invalid number of components %d", components)
+	}
+	if numValues != components*e.numPoints {
+		return fmt.Errorf("expected %d values got %d", components*e.numPoints, numValues)
+	}
+	return nil
+}
+
+// Encode returns the Draco bitstream encoding all the attributes added so far
+func (e *Encoder) Encode() ([]byte, error) {
+	if len(e.attributes) == 0 {
+		return nil, errors.New("no attributes to encode")
+	}
+	b := []byte("DRACO")
+	// header: version, encoder type, encoder method (sequential), flags
+	b = append(b, versionMajor, versionMinor, byte(e.geometry), 0)
+	b = binary.LittleEndian.AppendUint16(b, 0)
+
+	// geometry data
+	if e.geometry == TriangularMesh {
+		// no faces, the number of points and the connectivity method (raw indices)
+		b = binary.AppendUvarint(b, 0)
+		b = binary.AppendUvarint(b, uint64(e.numPoints))
+		b = append(b, 1)
+	} else {
+		b = binary.LittleEndian.AppendUint32(b, uint32(e.numPoints))
+	}
+
+	// a single attributes encoder encoding all the attributes in the point order
+	b = append(b, 1)
+	b = binary.AppendUvarint(b, uint64(len(e.attributes)))
+	for i, a := range e.attributes {
+		normalized := byte(0)
+		if a.normalized {
+			normalized = 1
+		}
+		b = append(b, byte(a.attributeType), byte(a.dataType), byte(a.components), normalized)
+		b = binary.AppendUvarint(b, uint64(i))
+	}
+	for _, a := range e.attributes {
+		if a.dataType == Float32 {
+			b = append(b, sequentialEncoderQuantization)
+		} else {
+			b = append(b, sequentialEncoderInteger)
+		}
+	}
+
+	// attribute values
+	for _, a := range e.attributes {
+		b = encodeIntegerValues(b, a.values, a.components)
+	}
+	// data needed to revert the quantization
+	for _, a := range e.attributes {
+		if a.dataType != Float32 {
+			continue
+		}
+		for _, m := range a.min {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(m))
+		}
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(a.rangeValue))
+		b = append(b, byte(a.quantizationBits))
+	}
+	return b, nil
+}
+
+// encodeIntegerValues encodes the values of an attribute predicting each value from the previous one and
+// entropy coding the corrections
+func encodeIntegerValues(b []byte, values []int32, components int) []byte {
+	minValue, maxValue := int64(0), int64(0)
+	if len(values) > 0 {
+		minValue, maxValue = int64(values[0]), int64(values[0])
+	}
+	for _, v := range values {
+		minValue = min(minValue, int64(v))
+		maxValue = max(maxValue, int64(v))
+	}
+	if maxValue-minValue >= math.MaxInt32 {
+		// the range of the values is too large to wrap the corrections
+		b = append(b, predictionNone)
+		return encodeSymbols(b, toSymbols(values))
+	}
+	b = append(b, predictionDifference, transformWrap)
+	corrections := deltaCorrections(values, components, int32(minValue), int32(maxValue))
+	b = encodeSymbols(b, toSymbols(corrections))
+	// wrap transform data
+	b = binary.LittleEndian.AppendUint32(b, uint32(int32(minValue)))
+	return binary.LittleEndian.AppendUint32(b, uint32(int32(maxValue)))
+}
+
+// deltaCorrections computes the difference of each value from the same component of the previous one,
+// wrapping the corrections around the range of the values so that they always lie within half the range
+func deltaCorrections(values []int32, components int, minValue, maxValue int32) []int32 {
+	maxDif := 1 + maxValue - minValue
+	maxCorrection := maxDif / 2
+	minCorrection := -maxCorrection
+	if maxDif&1 == 0 {
+		maxCorrection--
+	}
+	corrections := make([]int32, len(values))
+	for i, v := range values {
+		// the first value is predicted from zero, clamped to the values range
+		predicted := min(max(0, minValue), maxValue)
+		if i >= components {
+			predicted = values[i-components]
+		}
+		c := v - predicted
+		if c < minCorrection {
+			c += maxDif
+		} else if c > maxCorrection {
+			c -= maxDif
+		}
+		corrections[i] = c
+	}
+	return corrections
+}
+
+// toSymbols maps signed values to unsigned symbols, interleaving positive and negative values
+func toSymbols(values []int32) []uint32 {
+	symbols := make([]uint32, len(values))
+	for i, v := range values {
+		if v >= 0 {
+			symbols[i] = uint32(v) << 1
+		} else {
+			symbols[i] = uint32(-(v+1))<<1 | 1
+		}
+	}
+	return symbols
+}
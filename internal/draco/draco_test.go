@@ -0,0 +1,542 @@
+package draco
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// decoder is a minimal Draco decoder supporting the subset of the bitstream written by the Encoder, used to
+// verify the encoded data following the same steps of the reference decoder
+type decoder struct {
+	b   []byte
+	pos int
+}
+
+type decodedAttribute struct {
+	attributeType AttributeType
+	dataType      DataType
+	components    int
+	normalized    bool
+	uniqueID      uint64
+	encoder       byte
+	values        []int32
+	floats        []float32
+}
+
+func (d *decoder) u8() byte {
+	if d.pos >= len(d.b) {
+		panic(errors.New("unexpected end of data"))
+	}
+	d.pos++
+	return d.b[d.pos-1]
+}
+
+func (d *decoder) u32() uint32 {
+	return binary.LittleEndian.Uint32(d.bytes(4))
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.pos+n > len(d.b) {
+		panic(errors.New("unexpected end of data"))
+	}
+	d.pos += n
+	return d.b[d.pos-n : d.pos]
+}
+
+func (d *decoder) varint() uint64 {
+	v, n := binary.Uvarint(d.b[d.pos:])
+	if n <= 0 {
+		panic(errors.New("invalid varint"))
+	}
+	d.pos += n
+	return v
+}
+
+func decode(b []byte) (geometry GeometryType, numPoints int, attrs []*decodedAttribute, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	d := &decoder{b: b}
+	if string(d.bytes(5)) != "DRACO" || d.u8() != versionMajor || d.u8() != versionMinor {
+		return 0, 0, nil, errors.New("invalid header")
+	}
+	geometry = GeometryType(d.u8())
+	if method := d.u8(); method != 0 {
+		return 0, 0, nil, errors.New("unsupported encoding method")
+	}
+	d.bytes(2)
+	if geometry == TriangularMesh {
+		if faces := d.varint(); faces != 0 {
+			return 0, 0, nil, errors.New("unexpected faces")
+		}
+		numPoints = int(d.varint())
+		if d.u8() != 1 {
+			return 0, 0, nil, errors.New("unexpected connectivity method")
+		}
+	} else {
+		numPoints = int(d.u32())
+	}
+	if d.u8() != 1 {
+		return 0, 0, nil, errors.New("expected a single attributes decoder")
+	}
+	numAttributes := int(d.varint())
+	for i := 0; i < numAttributes; i++ {
+		a := &decodedAttribute{
+			attributeType: AttributeType(d.u8()),
+			dataType:      DataType(d.u8()),
+			components:    int(d.u8()),
+			normalized:    d.u8() == 1,
+			uniqueID:      d.varint(),
+		}
+		attrs = append(attrs, a)
+	}
+	for _, a := range attrs {
+		a.encoder = d.u8()
+	}
+	for _, a := range attrs {
+		a.values = d.integerValues(numPoints*a.components, a.components)
+	}
+	for _, a := range attrs {
+		if a.encoder != sequentialEncoderQuantization {
+			continue
+		}
+		minValues := make([]float32, a.components)
+		for c := range minValues {
+			minValues[c] = math.Float32frombits(d.u32())
+		}
+		rangeValue := math.Float32frombits(d.u32())
+		bits := d.u8()
+		delta := rangeValue / float32(uint32(1)<<bits-1)
+		a.floats = make([]float32, len(a.values))
+		for i, v := range a.values {
+			a.floats[i] = float32(v)*delta + minValues[i%a.components]
+		}
+	}
+	if d.pos != len(b) {
+		return 0, 0, nil, fmt.Errorf("%d trailing bytes", len(b)-d.pos)
+	}
+	return geometry, numPoints, attrs, nil
+}
+
+func (d *decoder) integerValues(n int, components int) []int32 {
+	prediction := d.u8()
+	if prediction != predictionNone && prediction != predictionDifference {
+		panic(errors.New("unsupported prediction"))
+	}
+	if prediction == predictionDifference && d.u8() != transformWrap {
+		panic(errors.New("unsupported transform"))
+	}
+	var symbols []uint32
+	if d.u8() == 1 {
+		symbols = d.symbols(n)
+	} else {
+		numBytes := int(d.u8())
+		for i := 0; i < n; i++ {
+			v := uint32(0)
+			for j, c := range d.bytes(numBytes) {
+				v |= uint32(c) << (8 * j)
+			}
+			symbols = append(symbols, v)
+		}
+	}
+	values := make([]int32, n)
+	for i, s := range symbols {
+		if s&1 == 0 {
+			values[i] = int32(s >> 1)
+		} else {
+			values[i] = -int32(s>>1) - 1
+		}
+	}
+	if prediction != predictionDifference {
+		return values
+	}
+	// revert the delta prediction with the wrap transform
+	minValue, maxValue := int32(d.u32()), int32(d.u32())
+	maxDif := 1 + maxValue - minValue
+	for i := range values {
+		predicted := min(max(0, minValue), maxValue)
+		if i >= components {
+			predicted = values[i-components]
+		}
+		v := predicted + values[i]
+		if v > maxValue {
+			v -= maxDif
+		} else if v < minValue {
+			v += maxDif
+		}
+		values[i] = v
+	}
+	return values
+}
+
+func (d *decoder) symbols(n int) []uint32 {
+	if n == 0 {
+		return nil
+	}
+	if scheme := d.u8(); scheme != symbolCodingRaw {
+		panic(errors.New("unsupported symbol coding"))
+	}
+	bitLength := int(d.u8())
+	if bitLength < 1 || bitLength > maxUniqueSymbolsBitLength {
+		panic(errors.New("invalid unique symbols bit length"))
+	}
+	precision := uint32(1) << min(max(3*bitLength/2, 12), 20)
+	numSymbols := int(d.varint())
+	probabilities := make([]uint32, numSymbols)
+	for i := 0; i < numSymbols; i++ {
+		data := d.u8()
+		if data&3 == 3 {
+			offset := int(data >> 2)
+			if i+offset >= numSymbols {
+				panic(errors.New("invalid zero run"))
+			}
+			i += offset
+			continue
+		}
+		p := uint32(data >> 2)
+		for j := 0; j < int(data&3); j++ {
+			p |= uint32(d.u8()) << (8*(j+1) - 2)
+		}
+		probabilities[i] = p
+	}
+	cumulative := make([]uint32, numSymbols)
+	lookup := make([]uint32, precision)
+	sum := uint32(0)
+	for i, p := range probabilities {
+		cumulative[i] = sum
+		if sum+p > precision {
+			panic(errors.New("probabilities exceed the precision"))
+		}
+		for j := sum; j < sum+p; j++ {
+			lookup[j] = uint32(i)
+		}
+		sum += p
+	}
+	if sum != precision {
+		panic(fmt.Errorf("probabilities sum to %d instead of %d", sum, precision))
+	}
+	size := int(d.varint())
+	data := d.bytes(size)
+	lowerBound := 4 * precision
+	offset := size
+	var state uint32
+	switch data[offset-1] >> 6 {
+	case 0:
+		offset--
+		state = uint32(data[offset]) & 0x3f
+	case 1:
+		offset -= 2
+		state = uint32(binary.LittleEndian.Uint16(data[offset:])) & 0x3fff
+	case 2:
+		offset -= 3
+		state = (uint32(data[offset]) | uint32(data[offset+1])<<8 | uint32(data[offset+2])<<16) & 0x3fffff
+	case 3:
+		offset -= 4
+		state = binary.LittleEndian.Uint32(data[offset:]) & 0x3fffffff
+	}
+	state += lowerBound
+	if state >= lowerBound*ransIOBase {
+		panic(errors.New("invalid final state"))
+	}
+	symbols := make([]uint32, n)
+	for i := range symbols {
+		for state < lowerBound && offset > 0 {
+			offset--
+			state = state*ransIOBase + uint32(data[offset])
+		}
+		quo, rem := state/precision, state%precision
+		s := lookup[rem]
+		state = quo*probabilities[s] + rem - cumulative[s]
+		symbols[i] = s
+	}
+	if offset != 0 || state != lowerBound {
+		panic(errors.New("rANS data not fully consumed"))
+	}
+	return symbols
+}
+
+func TestEncodePointCloud(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	n := 1000
+	positions := make([]float32, 3*n)
+	colors := make([]int32, 3*n)
+	intensities := make([]int32, n)
+	for i := 0; i < n; i++ {
+		positions[3*i] = float32(i) * 0.01
+		positions[3*i+1] = r.Float32()*100 - 50
+		positions[3*i+2] = 10
+		for c := 0; c < 3; c++ {
+			colors[3*i+c] = int32(r.Intn(256))
+		}
+		intensities[i] = int32(r.Intn(5)) - 2
+	}
+	e := NewEncoder(n)
+	if id, err := e.AddFloatAttribute(Position, 3, positions, 14); err != nil || id != 0 {
+		t.Fatalf("unexpected result %d %v", id, err)
+	}
+	if id, err := e.AddIntAttribute(Color, Uint8, 3, true, colors); err != nil || id != 1 {
+		t.Fatalf("unexpected result %d %v", id, err)
+	}
+	if id, err := e.AddIntAttribute(Generic, Int8, 1, false, intensities); err != nil || id != 2 {
+		t.Fatalf("unexpected result %d %v", id, err)
+	}
+	b, err := e.Encode()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	geometry, numPoints, attrs, err := decode(b)
+	if err != nil {
+		t.Fatalf("unable to decode: %v", err)
+	}
+	if geometry != PointCloud || numPoints != n || len(attrs) != 3 {
+		t.Fatalf("unexpected geometry %d, points %d or attributes %d", geometry, numPoints, len(attrs))
+	}
+	if a := attrs[1]; a.attributeType != Color || a.dataType != Uint8 || a.components != 3 || !a.normalized || a.uniqueID != 1 {
+		t.Errorf("unexpected color attribute %+v", a)
+	}
+	// the maximum error is half the quantization step, computed over the largest range
+	tolerance := 100.0/(1<<14-1)/2 + 1e-5
+	for i, v := range positions {
+		if math.Abs(float64(attrs[0].floats[i]-v)) > tolerance {
+			t.Fatalf("expected position %v got %v", v, attrs[0].floats[i])
+		}
+	}
+	for i, v := range colors {
+		if attrs[1].values[i] != v {
+			t.Fatalf("expected color %d got %d", v, attrs[1].values[i])
+		}
+	}
+	for i, v := range intensities {
+		if attrs[2].values[i] != v {
+			t.Fatalf("expected intensity %d got %d", v, attrs[2].values[i])
+		}
+	}
+	if raw := 3*4*n + 3*n + n; len(b) >= raw {
+		t.Errorf("expected compressed size lower than %d got %d", raw, len(b))
+	}
+}
+
+func TestEncodeMesh(t *testing.T) {
+	e := NewEncoder(2, WithGeometryType(TriangularMesh))
+	if _, err := e.AddFloatAttribute(Position, 3, []float32{1, 2, 3, 1, 2, 3}, 11); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b, err := e.Encode()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	geometry, numPoints, attrs, err := decode(b)
+	if err != nil {
+		t.Fatalf("unable to decode: %v", err)
+	}
+	if geometry != TriangularMesh || numPoints != 2 {
+		t.Fatalf("unexpected geometry %d or points %d", geometry, numPoints)
+	}
+	// a zero range is stored as 1 and all the values are quantized to zero
+	if expected := []float32{1, 2, 3, 1, 2, 3}; !slices.Equal(attrs[0].floats, expected) {
+		t.Errorf("expected positions %v got %v", expected, attrs[0].floats)
+	}
+}
+
+// goldenEncoder returns an encoder of a small point cloud whose encoding is stored in the golden files of testdata.
+// The golden files are decoded with the reference Draco decoder by TestEncodeGoldenReferenceDecoder when it is
+// installed, e.g. draco_decoder -i testdata/points.drc -o points.ply must list the same points, the positions
+// differing at most by half the quantization step.
+func goldenEncoder(t *testing.T, geometry GeometryType) *Encoder {
+	positions := []float32{
+		0, 0, 0,
+		10, 0, 0,
+		0, 5, 0,
+		10, 5, 2.5,
+		5, 2.5, 1.25,
+	}
+	colors := []int32{
+		255, 0, 0,
+		0, 255, 0,
+		0, 0, 255,
+		255, 255, 255,
+		128, 128, 128,
+	}
+	e := NewEncoder(5, WithGeometryType(geometry))
+	if _, err := e.AddFloatAttribute(Position, 3, positions, 11); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := e.AddIntAttribute(Color, Uint8, 3, true, colors); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := e.AddIntAttribute(Generic, Uint16, 1, false, []int32{0, 1000, 65535, 3, 3}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return e
+}
+
+func TestEncodeGolden(t *testing.T) {
+	tests := map[string]GeometryType{
+		"points.drc": PointCloud,
+		"mesh.drc":   TriangularMesh,
+	}
+	// headers as defined by the Draco bitstream specification: magic, version 2.2, encoder type (point cloud or
+	// triangular mesh), sequential encoding method and no flags
+	headers := map[string][]byte{
+		"points.drc": {'D', 'R', 'A', 'C', 'O', 2, 2, 0, 0, 0, 0},
+		"mesh.drc":   {'D', 'R', 'A', 'C', 'O', 2, 2, 1, 0, 0, 0},
+	}
+	for file, geometry := range tests {
+		t.Run(file, func(t *testing.T) {
+			expected, err := os.ReadFile(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !bytes.HasPrefix(expected, headers[file]) {
+				t.Errorf("expected header %v got %v", headers[file], expected[:min(len(expected), len(headers[file]))])
+			}
+			actual, err := goldenEncoder(t, geometry).Encode()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !bytes.Equal(expected, actual) {
+				t.Errorf("encoded data differs from %s", file)
+			}
+			g, numPoints, attrs, err := decode(expected)
+			if err != nil {
+				t.Fatalf("unable to decode: %v", err)
+			}
+			if g != geometry || numPoints != 5 || len(attrs) != 3 {
+				t.Fatalf("unexpected geometry %d, points %d or attributes %d", g, numPoints, len(attrs))
+			}
+			if expected := []int32{0, 1000, 65535, 3, 3}; !slices.Equal(attrs[2].values, expected) {
+				t.Errorf("expected values %v got %v", expected, attrs[2].values)
+			}
+		})
+	}
+}
+
+// TestEncodeGoldenReferenceDecoder decodes the golden files with the reference Draco decoder, if available in the PATH
+func TestEncodeGoldenReferenceDecoder(t *testing.T) {
+	decoder, err := exec.LookPath("draco_decoder")
+	if err != nil {
+		t.Skip("draco_decoder not found in the PATH")
+	}
+	for _, file := range []string{"points.drc", "mesh.drc"} {
+		t.Run(file, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out.ply")
+			if msg, err := exec.Command(decoder, "-i", filepath.Join("testdata", file), "-o", out).CombinedOutput(); err != nil {
+				t.Fatalf("unable to decode %s: %v %s", file, err, msg)
+			}
+			ply, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !bytes.Contains(ply, []byte("element vertex 5\n")) {
+				t.Errorf("expected 5 decoded vertices in %s", ply)
+			}
+		})
+	}
+}
+
+func TestEncodeLargeValues(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	tests := map[string]func(i int) int32{
+		// values whose range is too large for the wrap transform
+		"full range": func(i int) int32 { return int32(r.Uint32()) },
+		// too many unique symbols for the rANS coder
+		"unique symbols": func(i int) int32 { return int32(r.Intn(1 << 24)) },
+		"constant":       func(i int) int32 { return -7 },
+		// large probabilities along with many rare symbols
+		"skewed": func(i int) int32 {
+			if i%4 == 0 {
+				return int32(r.Intn(1 << 17))
+			}
+			return 0
+		},
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			n := 300000
+			values := make([]int32, n)
+			for i := range values {
+				values[i] = value(i)
+			}
+			e := NewEncoder(n)
+			if _, err := e.AddIntAttribute(Generic, Int32, 1, false, values); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			b, err := e.Encode()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			_, _, attrs, err := decode(b)
+			if err != nil {
+				t.Fatalf("unable to decode: %v", err)
+			}
+			if !slices.Equal(attrs[0].values, values) {
+				t.Errorf("decoded values differ from the encoded ones")
+			}
+		})
+	}
+}
+
+func TestEncodeNoPoints(t *testing.T) {
+	e := NewEncoder(0)
+	if _, err := e.AddFloatAttribute(Position, 3, nil, 11); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b, err := e.Encode()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, numPoints, _, err := decode(b); err != nil || numPoints != 0 {
+		t.Errorf("unexpected result %d %v", numPoints, err)
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	e := NewEncoder(2)
+	if _, err := e.Encode(); err == nil {
+		t.Errorf("expected error for no attributes, got none")
+	}
+	if _, err := e.AddFloatAttribute(Position, 3, []float32{1, 2, 3}, 11); err == nil {
+		t.Errorf("expected error for wrong number of values, got none")
+	}
+	if _, err := e.AddFloatAttribute(Position, 1, []float32{1, 2}, 31); err == nil {
+		t.Errorf("expected error for invalid quantization bits, got none")
+	}
+	if _, err := e.AddFloatAttribute(Position, 1, []float32{1, float32(math.NaN())}, 11); err == nil {
+		t.Errorf("expected error for NaN values, got none")
+	}
+	if _, err := e.AddIntAttribute(Generic, Float64, 1, false, []int32{1, 2}); err == nil {
+		t.Errorf("expected error for non integer data type, got none")
+	}
+	if _, err := e.AddIntAttribute(Generic, Int8, 0, false, nil); err == nil {
+		t.Errorf("expected error for zero components, got none")
+	}
+}
+
+func TestNormalizeFrequencies(t *testing.T) {
+	frequencies := map[uint32]uint64{0: 1000000, 3: 1, 5: 1, 200: 1}
+	for i := uint32(10); i < 100; i++ {
+		frequencies[i] = 2
+	}
+	p := normalizeFrequencies(frequencies, 200, 1<<12)
+	sum := uint32(0)
+	for s, v := range p {
+		if _, ok := frequencies[uint32(s)]; ok != (v > 0) {
+			t.Errorf("unexpected probability %d for symbol %d", v, s)
+		}
+		sum += v
+	}
+	if sum != 1<<12 {
+		t.Errorf("expected probabilities to sum to %d got %d", 1<<12, sum)
+	}
+}
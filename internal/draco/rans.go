@@ -0,0 +1,161 @@
+package draco
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// symbol coding schemes
+	symbolCodingRaw = 1
+	// maximum bit length of the number of unique symbols supported by the rANS coder
+	maxUniqueSymbolsBitLength = 18
+	ransIOBase                = 256
+)
+
+// encodeSymbols writes the given symbols, entropy coded with rANS when possible, or raw otherwise
+func encodeSymbols(b []byte, symbols []uint32) []byte {
+	if len(symbols) == 0 {
+		// compressed flag and no symbols data
+		return append(b, 1)
+	}
+	frequencies := map[uint32]uint64{}
+	maxSymbol := uint32(0)
+	for _, s := range symbols {
+		frequencies[s]++
+		maxSymbol = max(maxSymbol, s)
+	}
+	uniqueSymbolsBitLength := bits.Len(uint(len(frequencies)))
+	if uniqueSymbolsBitLength > maxUniqueSymbolsBitLength {
+		return encodeRawValues(b, symbols, maxSymbol)
+	}
+	// compressed flag, coding scheme and the bit length of the number of unique symbols
+	b = append(b, 1, symbolCodingRaw, byte(uniqueSymbolsBitLength))
+	precisionBits := min(max(3*uniqueSymbolsBitLength/2, 12), 20)
+	probabilities := normalizeFrequencies(frequencies, maxSymbol, uint32(1)<<precisionBits)
+	b = encodeProbabilities(b, probabilities)
+	return encodeRans(b, symbols, probabilities, uint32(1)<<precisionBits)
+}
+
+// encodeRawValues writes the symbols uncompressed, using the minimum number of bytes per value
+func encodeRawValues(b []byte, symbols []uint32, maxSymbol uint32) []byte {
+	numBytes := max(1, (bits.Len32(maxSymbol)+7)/8)
+	b = append(b, 0, byte(numBytes))
+	for _, s := range symbols {
+		for i := 0; i < numBytes; i++ {
+			b = append(b, byte(s>>(8*i)))
+		}
+	}
+	return b
+}
+
+// normalizeFrequencies scales the symbol frequencies to probabilities summing up to the given precision,
+// assigning at least a probability of 1 to every symbol that occurs
+func normalizeFrequencies(frequencies map[uint32]uint64, maxSymbol uint32, precision uint32) []uint32 {
+	total := uint64(0)
+	for _, f := range frequencies {
+		total += f
+	}
+	probabilities := make([]uint32, maxSymbol+1)
+	sum := uint32(0)
+	for s, f := range frequencies {
+		probabilities[s] = uint32(max(1, (f*uint64(precision)+total/2)/total))
+		sum += probabilities[s]
+	}
+	// fix the rounding errors starting from the most probable symbols
+	order := make([]uint32, 0, len(frequencies))
+	for s := range frequencies {
+		order = append(order, s)
+	}
+	slices.SortFunc(order, func(a, b uint32) int {
+		if probabilities[a] != probabilities[b] {
+			return int(probabilities[b]) - int(probabilities[a])
+		}
+		return int(a) - int(b)
+	})
+	if sum < precision {
+		probabilities[order[0]] += precision - sum
+	}
+	for sum > precision {
+		excess := sum - precision
+		for _, s := range order {
+			if probabilities[s] <= 1 || excess == 0 {
+				continue
+			}
+			d := min(excess, probabilities[s]-1, max(1, uint32(uint64(probabilities[s])*uint64(excess)/uint64(sum))))
+			probabilities[s] -= d
+			excess -= d
+			sum -= d
+		}
+	}
+	return probabilities
+}
+
+// encodeProbabilities writes the probability table, using 1 to 4 bytes per probability and run length
+// encoding the symbols with zero probability
+func encodeProbabilities(b []byte, probabilities []uint32) []byte {
+	b = binary.AppendUvarint(b, uint64(len(probabilities)))
+	for i := 0; i < len(probabilities); i++ {
+		p := probabilities[i]
+		if p == 0 {
+			offset := 0
+			for offset < 63 && i+offset+1 < len(probabilities) && probabilities[i+offset+1] == 0 {
+				offset++
+			}
+			b = append(b, byte(offset<<2|3))
+			i += offset
+			continue
+		}
+		extraBytes := 0
+		switch {
+		case p >= 1<<22:
+			extraBytes = 3
+		case p >= 1<<14:
+			extraBytes = 2
+		case p >= 1<<6:
+			extraBytes = 1
+		}
+		b = append(b, byte(p<<2)|byte(extraBytes))
+		for j := 0; j < extraBytes; j++ {
+			b = append(b, byte(p>>(8*(j+1)-2)))
+		}
+	}
+	return b
+}
+
+// encodeRans writes the rANS encoded symbols prefixed by the length of the encoded data
+func encodeRans(b []byte, symbols []uint32, probabilities []uint32, precision uint32) []byte {
+	cumulative := make([]uint32, len(probabilities))
+	for i := 1; i < len(probabilities); i++ {
+		cumulative[i] = cumulative[i-1] + probabilities[i-1]
+	}
+	lowerBound := 4 * precision
+	state := lowerBound
+	var data []byte
+	// symbols are encoded in reverse order so that they can be decoded in the original one
+	for i := len(symbols) - 1; i >= 0; i-- {
+		s := symbols[i]
+		p := probabilities[s]
+		for uint64(state) >= uint64(lowerBound/precision)*ransIOBase*uint64(p) {
+			data = append(data, byte(state))
+			state /= ransIOBase
+		}
+		state = (state/p)*precision + state%p + cumulative[s]
+	}
+	// write the final state, using the two most significant bits to store its length in bytes
+	s := state - lowerBound
+	switch {
+	case s < 1<<6:
+		data = append(data, byte(s))
+	case s < 1<<14:
+		data = binary.LittleEndian.AppendUint16(data, uint16(s|1<<14))
+	case s < 1<<22:
+		v := s | 2<<22
+		data = append(data, byte(v), byte(v>>8), byte(v>>16))
+	default:
+		data = binary.LittleEndian.AppendUint32(data, s|3<<30)
+	}
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
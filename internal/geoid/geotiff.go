@@ -0,0 +1,408 @@
+package geoid
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TIFF tags used to read the geoid grids
+const (
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagStripOffsets    = 273
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagStripByteCounts = 279
+	tagPlanarConfig    = 284
+	tagPredictor       = 317
+	tagTileWidth       = 322
+	tagTileLength      = 323
+	tagTileOffsets     = 324
+	tagTileByteCounts  = 325
+	tagSampleFormat    = 339
+	tagModelPixelScale = 33550
+	tagModelTiepoint   = 33922
+	tagGeoKeyDirectory = 34735
+	tagGDALMetadata    = 42112
+	tagGDALNoData      = 42113
+)
+
+// GeoTIFF keys and values used to read the geoid grids
+const (
+	keyModelType          = 1024
+	keyRasterType         = 1025
+	modelTypeGeographic   = 2
+	rasterPixelIsPoint    = 2
+	sampleFormatUint      = 1
+	sampleFormatInt       = 2
+	sampleFormatFloat     = 3
+	compressionNone       = 1
+	compressionDeflate    = 8
+	compressionDeflateOld = 32946
+	predictorNone         = 1
+	predictorHorizontal   = 2
+	predictorFloat        = 3
+)
+
+// tiffTypeSizes stores the size in bytes of the TIFF field types
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 16: 8, 17: 8}
+
+// tiffField is a field of a TIFF image file directory
+type tiffField struct {
+	typ   uint16
+	count int
+	data  []byte
+}
+
+// tiffReader reads the first image of a classic TIFF file
+type tiffReader struct {
+	r      io.ReaderAt
+	order  binary.ByteOrder
+	fields map[uint16]tiffField
+}
+
+// ReadGeoTIFF reads a geoid grid stored in the first band of the first image of a GeoTIFF file in geographic
+// coordinates, like the ones distributed by PROJ. Uncompressed and Deflate compressed, stripped or tiled,
+// integer or floating point images are supported, with or without predictors. The GDAL no data value and the
+// GDAL scale and offset metadata are honored.
+func ReadGeoTIFF(r io.ReaderAt) (*Grid, error) {
+	t, err := newTiffReader(r)
+	if err != nil {
+		return nil, err
+	}
+	width, height := t.uint(tagImageWidth, 0), t.uint(tagImageLength, 0)
+	if width < 2 || height < 2 || int64(width)*int64(height) > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid geotiff size %d x %d", width, height)
+	}
+
+	// georeferencing
+	if keys := t.geoKeys(); keys[keyModelType] != 0 && keys[keyModelType] != modelTypeGeographic {
+		return nil, fmt.Errorf("the geotiff grid should be in geographic coordinates")
+	}
+	scale, tiepoint := t.floats(tagModelPixelScale), t.floats(tagModelTiepoint)
+	if len(scale) < 2 || len(tiepoint) < 6 {
+		return nil, fmt.Errorf("the geotiff lacks the ModelPixelScale and ModelTiepoint tags")
+	}
+	// coordinates of the center of the top left pixel
+	lon0 := tiepoint[3] - tiepoint[0]*scale[0]
+	lat0 := tiepoint[4] + tiepoint[1]*scale[1]
+	if t.geoKeys()[keyRasterType] != rasterPixelIsPoint {
+		lon0 += scale[0] / 2
+		lat0 -= scale[1] / 2
+	}
+
+	noData := math.NaN()
+	if s := strings.Trim(t.string(tagGDALNoData), "\x00 "); s != "" {
+		if noData, err = strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("invalid geotiff no data value %q", s)
+		}
+	}
+	offset, factor := gdalScaleOffset(t.string(tagGDALMetadata))
+	values, err := t.readBand(width, height, func(v float64) float32 {
+		if v == noData || math.IsNaN(v) {
+			return float32(math.NaN())
+		}
+		return float32(offset + factor*v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewGrid(lat0-float64(height-1)*scale[1], lon0, scale[1], scale[0], height, width, values)
+}
+
+func newTiffReader(r io.ReaderAt) (*tiffReader, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("unable to read the tiff header: %w", err)
+	}
+	t := &tiffReader{r: r, fields: map[uint16]tiffField{}}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("not a tiff file")
+	}
+	if magic := t.order.Uint16(header[2:]); magic != 42 {
+		if magic == 43 {
+			return nil, fmt.Errorf("BigTIFF files are not supported")
+		}
+		return nil, fmt.Errorf("not a tiff file")
+	}
+
+	// read the first image file directory
+	ifd := int64(t.order.Uint32(header[4:]))
+	countBuf := make([]byte, 2)
+	if _, err := r.ReadAt(countBuf, ifd); err != nil {
+		return nil, fmt.Errorf("unable to read the tiff directory: %w", err)
+	}
+	entries := make([]byte, 12*int(t.order.Uint16(countBuf)))
+	if _, err := r.ReadAt(entries, ifd+2); err != nil {
+		return nil, fmt.Errorf("unable to read the tiff directory: %w", err)
+	}
+	for i := 0; i < len(entries); i += 12 {
+		e := entries[i : i+12]
+		tag, typ, count := t.order.Uint16(e), t.order.Uint16(e[2:]), int(t.order.Uint32(e[4:]))
+		size, ok := tiffTypeSizes[typ]
+		if !ok {
+			// unknown types can be safely skipped
+			continue
+		}
+		f := tiffField{typ: typ, count: count}
+		if n := size * count; n > 1<<28 {
+			return nil, fmt.Errorf("invalid tiff tag %d with %d values", tag, count)
+		} else if n <= 4 {
+			f.data = e[8 : 8+n]
+		} else {
+			f.data = make([]byte, n)
+			if _, err := r.ReadAt(f.data, int64(t.order.Uint32(e[8:]))); err != nil {
+				return nil, fmt.Errorf("unable to read the tiff tag %d: %w", tag, err)
+			}
+		}
+		t.fields[tag] = f
+	}
+	return t, nil
+}
+
+// uints returns the values of an integer field
+func (t *tiffReader) uints(tag uint16) []int {
+	f, ok := t.fields[tag]
+	if !ok {
+		return nil
+	}
+	v := make([]int, f.count)
+	for i := range v {
+		switch f.typ {
+		case 1, 7:
+			v[i] = int(f.data[i])
+		case 3:
+			v[i] = int(t.order.Uint16(f.data[2*i:]))
+		case 4:
+			v[i] = int(t.order.Uint32(f.data[4*i:]))
+		case 16:
+			v[i] = int(t.order.Uint64(f.data[8*i:]))
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// uint returns the first value of an integer field, or the default value if missing
+func (t *tiffReader) uint(tag uint16, def int) int {
+	if v := t.uints(tag); len(v) > 0 {
+		return v[0]
+	}
+	return def
+}
+
+// floats returns the values of a double field
+func (t *tiffReader) floats(tag uint16) []float64 {
+	f, ok := t.fields[tag]
+	if !ok || f.typ != 12 {
+		return nil
+	}
+	v := make([]float64, f.count)
+	for i := range v {
+		v[i] = math.Float64frombits(t.order.Uint64(f.data[8*i:]))
+	}
+	return v
+}
+
+// string returns the value of an ASCII field
+func (t *tiffReader) string(tag uint16) string {
+	f, ok := t.fields[tag]
+	if !ok || f.typ != 2 {
+		return ""
+	}
+	return string(f.data)
+}
+
+// geoKeys returns the short valued keys of the GeoKeyDirectory
+func (t *tiffReader) geoKeys() map[int]int {
+	keys := map[int]int{}
+	dir := t.uints(tagGeoKeyDirectory)
+	for i := 4; i+3 < len(dir); i += 4 {
+		if dir[i+1] == 0 && dir[i+2] == 1 {
+			keys[dir[i]] = dir[i+3]
+		}
+	}
+	return keys
+}
+
+// readBand reads the values of the first sample of each pixel, converted with the given function, and returns them
+// row by row from the bottom of the image
+func (t *tiffReader) readBand(width, height int, convert func(float64) float32) ([]float32, error) {
+	bits := t.uint(tagBitsPerSample, 1)
+	format := t.uint(tagSampleFormat, sampleFormatUint)
+	if bits%8 != 0 {
+		return nil, fmt.Errorf("unsupported geotiff bits per sample %d", bits)
+	}
+	bytesPerSample := bits / 8
+	decode, err := sampleDecoder(format, bytesPerSample)
+	if err != nil {
+		return nil, err
+	}
+	compression := t.uint(tagCompression, compressionNone)
+	if compression != compressionNone && compression != compressionDeflate && compression != compressionDeflateOld {
+		return nil, fmt.Errorf("unsupported geotiff compression %d, only uncompressed and deflate are supported", compression)
+	}
+	predictor := t.uint(tagPredictor, predictorNone)
+	samples := t.uint(tagSamplesPerPixel, 1)
+	if t.uint(tagPlanarConfig, 1) == 2 {
+		// planar: the segments of the first band come first and contain one sample per pixel
+		samples = 1
+	}
+
+	// each segment, strip or tile, is a block of segmentWidth x segmentHeight pixels
+	segmentWidth, segmentHeight := width, t.uint(tagRowsPerStrip, height)
+	offsets, counts := t.uints(tagStripOffsets), t.uints(tagStripByteCounts)
+	if _, tiled := t.fields[tagTileWidth]; tiled {
+		segmentWidth, segmentHeight = t.uint(tagTileWidth, 0), t.uint(tagTileLength, 0)
+		offsets, counts = t.uints(tagTileOffsets), t.uints(tagTileByteCounts)
+	}
+	if segmentWidth <= 0 || segmentHeight <= 0 || len(offsets) == 0 || len(offsets) != len(counts) {
+		return nil, fmt.Errorf("invalid geotiff image layout")
+	}
+	segmentHeight = min(segmentHeight, height)
+	across := (width + segmentWidth - 1) / segmentWidth
+	down := (height + segmentHeight - 1) / segmentHeight
+	if len(offsets) < across*down {
+		return nil, fmt.Errorf("expected %d geotiff image segments got %d", across*down, len(offsets))
+	}
+
+	values := make([]float32, width*height)
+	rowSize := segmentWidth * samples * bytesPerSample
+	for s := 0; s < across*down; s++ {
+		data := make([]byte, counts[s])
+		if _, err := t.r.ReadAt(data, int64(offsets[s])); err != nil {
+			return nil, fmt.Errorf("unable to read the geotiff image data: %w", err)
+		}
+		if compression != compressionNone {
+			if data, err = inflate(data); err != nil {
+				return nil, fmt.Errorf("unable to decompress the geotiff image data: %w", err)
+			}
+		}
+		x0, y0 := (s%across)*segmentWidth, (s/across)*segmentHeight
+		rows := min(segmentHeight, height-y0)
+		if len(data) < rows*rowSize {
+			return nil, fmt.Errorf("truncated geotiff image data")
+		}
+		for r := 0; r < rows; r++ {
+			row := data[r*rowSize : (r+1)*rowSize]
+			var order binary.ByteOrder = t.order
+			switch predictor {
+			case predictorNone:
+			case predictorHorizontal:
+				undoHorizontalPredictor(row, samples, bytesPerSample, t.order)
+			case predictorFloat:
+				row = undoFloatPredictor(row, samples, bytesPerSample)
+				order = binary.BigEndian
+			default:
+				return nil, fmt.Errorf("unsupported geotiff predictor %d", predictor)
+			}
+			// the tiff rows go from north to south, reverse them
+			dst := values[(height-1-y0-r)*width:]
+			for c := 0; c < segmentWidth && x0+c < width; c++ {
+				dst[x0+c] = convert(decode(row[c*samples*bytesPerSample:], order))
+			}
+		}
+	}
+	return values, nil
+}
+
+// sampleDecoder returns a function decoding a sample of the given format and size
+func sampleDecoder(format int, size int) (func(b []byte, order binary.ByteOrder) float64, error) {
+	switch {
+	case format == sampleFormatFloat && size == 4:
+		return func(b []byte, o binary.ByteOrder) float64 { return float64(math.Float32frombits(o.Uint32(b))) }, nil
+	case format == sampleFormatFloat && size == 8:
+		return func(b []byte, o binary.ByteOrder) float64 { return math.Float64frombits(o.Uint64(b)) }, nil
+	case format == sampleFormatInt && size == 1:
+		return func(b []byte, o binary.ByteOrder) float64 { return float64(int8(b[0])) }, nil
+	case format == sampleFormatInt && size == 2:
+		return func(b []byte, o binary.ByteOrder) float64 { return float64(int16(o.Uint16(b))) }, nil
+	case format == sampleFormatInt && size == 4:
+		return func(b []byte, o binary.ByteOrder) float64 { return float64(int32(o.Uint32(b))) }, nil
+	case format == sampleFormatUint && size == 1:
+		return func(b []byte, o binary.ByteOrder) float64 { return float64(b[0]) }, nil
+	case format == sampleFormatUint && size == 2:
+		return func(b []byte, o binary.ByteOrder) float64 { return float64(o.Uint16(b)) }, nil
+	case format == sampleFormatUint && size == 4:
+		return func(b []byte, o binary.ByteOrder) float64 { return float64(o.Uint32(b)) }, nil
+	}
+	return nil, fmt.Errorf("unsupported geotiff sample format %d with %d bits per sample", format, size*8)
+}
+
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// undoHorizontalPredictor reverts the horizontal differencing of the integer samples of a row
+func undoHorizontalPredictor(row []byte, samples int, size int, order binary.ByteOrder) {
+	stride := samples * size
+	for i := stride; i+size <= len(row); i += size {
+		switch size {
+		case 1:
+			row[i] += row[i-stride]
+		case 2:
+			order.PutUint16(row[i:], order.Uint16(row[i:])+order.Uint16(row[i-stride:]))
+		case 4:
+			order.PutUint32(row[i:], order.Uint32(row[i:])+order.Uint32(row[i-stride:]))
+		case 8:
+			order.PutUint64(row[i:], order.Uint64(row[i:])+order.Uint64(row[i-stride:]))
+		}
+	}
+}
+
+// undoFloatPredictor reverts the floating point predictor of a row, which differences the bytes of the row after
+// grouping them by significance, and returns the samples as big endian values
+func undoFloatPredictor(row []byte, samples int, size int) []byte {
+	for i := samples; i < len(row); i++ {
+		row[i] += row[i-samples]
+	}
+	count := len(row) / size
+	out := make([]byte, len(row))
+	for i := 0; i < count; i++ {
+		for b := 0; b < size; b++ {
+			out[i*size+b] = row[b*count+i]
+		}
+	}
+	return out
+}
+
+// gdalScaleRegexp matches the scale and offset items of the GDAL metadata
+var gdalScaleRegexp = regexp.MustCompile(`<Item name="(SCALE|OFFSET)"[^>]*sample="0"[^>]*>\s*([^<\s]+)\s*</Item>`)
+
+// gdalScaleOffset returns the offset and scale to apply to the raw values of the first band according to the
+// GDAL metadata
+func gdalScaleOffset(metadata string) (float64, float64) {
+	offset, scale := 0.0, 1.0
+	for _, m := range gdalScaleRegexp.FindAllStringSubmatch(metadata, -1) {
+		v, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			continue
+		}
+		if m[1] == "SCALE" {
+			scale = v
+		} else {
+			offset = v
+		}
+	}
+	return offset, scale
+}
@@ -0,0 +1,330 @@
+package geoid
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"sort"
+	"testing"
+)
+
+// testTiff describes a single band geoid grid tiff to be written by writeTiff
+type testTiff struct {
+	order binary.ByteOrder
+	// width and height of the image, lat0 and lon0 the coordinates of the center of the top left pixel
+	width, height int
+	lat0, lon0    float64
+	dLat, dLon    float64
+	pixelIsPoint  bool
+	// segment layout: tiles of segmentWidth x segmentHeight if tiled, else strips of segmentHeight rows
+	tiled                       bool
+	segmentWidth, segmentHeight int
+	// sample encoding
+	format, bytesPerSample int
+	predictor              int
+	deflate                bool
+	// value converts an undulation to the raw sample value
+	value    func(n float64) float64
+	metadata string
+	noData   string
+	// missing lists the pixels, as row * width + col, to set to the no data value
+	missing []int
+}
+
+type testTag struct {
+	tag, typ uint16
+	count    int
+	data     []byte
+}
+
+func (tt testTiff) encodeSample(order binary.ByteOrder, v float64) []byte {
+	b := make([]byte, tt.bytesPerSample)
+	switch {
+	case tt.format == sampleFormatFloat && tt.bytesPerSample == 4:
+		order.PutUint32(b, math.Float32bits(float32(v)))
+	case tt.format == sampleFormatFloat && tt.bytesPerSample == 8:
+		order.PutUint64(b, math.Float64bits(v))
+	case tt.bytesPerSample == 2:
+		order.PutUint16(b, uint16(int16(math.Round(v))))
+	case tt.bytesPerSample == 4:
+		order.PutUint32(b, uint32(int32(math.Round(v))))
+	}
+	return b
+}
+
+// segment returns the encoded data of the segment with top left pixel x0, y0
+func (tt testTiff) segment(x0, y0, w, h int) []byte {
+	data := []byte{}
+	for r := 0; r < h; r++ {
+		// samples of the row, padded with zeroes outside of the image
+		row := make([]float64, w)
+		for c := range row {
+			x, y := x0+c, y0+r
+			if x >= tt.width || y >= tt.height {
+				continue
+			}
+			row[c] = tt.value(plane(tt.lat0-float64(y)*tt.dLat, tt.lon0+float64(x)*tt.dLon))
+			for _, m := range tt.missing {
+				if m == y*tt.width+x {
+					row[c] = -9999
+				}
+			}
+		}
+		var encoded []byte
+		switch tt.predictor {
+		case predictorFloat:
+			// bytes grouped by significance, then differenced
+			planes := make([]byte, w*tt.bytesPerSample)
+			for c, v := range row {
+				for b, byt := range tt.encodeSample(binary.BigEndian, v) {
+					planes[b*w+c] = byt
+				}
+			}
+			for i := len(planes) - 1; i > 0; i-- {
+				planes[i] -= planes[i-1]
+			}
+			encoded = planes
+		case predictorHorizontal:
+			for c := len(row) - 1; c > 0; c-- {
+				row[c] = float64(int16(math.Round(row[c])) - int16(math.Round(row[c-1])))
+			}
+			fallthrough
+		default:
+			for _, v := range row {
+				encoded = append(encoded, tt.encodeSample(tt.order, v)...)
+			}
+		}
+		data = append(data, encoded...)
+	}
+	if tt.deflate {
+		b := &bytes.Buffer{}
+		w := zlib.NewWriter(b)
+		w.Write(data)
+		w.Close()
+		return b.Bytes()
+	}
+	return data
+}
+
+func (tt testTiff) bytes() []byte {
+	o := tt.order
+	shorts := func(v ...int) []byte {
+		b := make([]byte, 2*len(v))
+		for i, x := range v {
+			o.PutUint16(b[2*i:], uint16(x))
+		}
+		return b
+	}
+	longs := func(v ...int) []byte {
+		b := make([]byte, 4*len(v))
+		for i, x := range v {
+			o.PutUint32(b[4*i:], uint32(x))
+		}
+		return b
+	}
+	doubles := func(v ...float64) []byte {
+		b := make([]byte, 8*len(v))
+		for i, x := range v {
+			o.PutUint64(b[8*i:], math.Float64bits(x))
+		}
+		return b
+	}
+
+	segments := [][]byte{}
+	across := (tt.width + tt.segmentWidth - 1) / tt.segmentWidth
+	down := (tt.height + tt.segmentHeight - 1) / tt.segmentHeight
+	for s := 0; s < across*down; s++ {
+		x0, y0 := (s%across)*tt.segmentWidth, (s/across)*tt.segmentHeight
+		h := tt.segmentHeight
+		if !tt.tiled {
+			h = min(h, tt.height-y0)
+		}
+		segments = append(segments, tt.segment(x0, y0, tt.segmentWidth, h))
+	}
+
+	compression := compressionNone
+	if tt.deflate {
+		compression = compressionDeflate
+	}
+	rasterType := 1
+	tiepoint := []float64{0, 0, 0, tt.lon0 - tt.dLon/2, tt.lat0 + tt.dLat/2, 0}
+	if tt.pixelIsPoint {
+		rasterType = rasterPixelIsPoint
+		tiepoint = []float64{1, 1, 0, tt.lon0 + tt.dLon, tt.lat0 - tt.dLat, 0}
+	}
+	tags := []testTag{
+		{tagImageWidth, 3, 1, shorts(tt.width)},
+		{tagImageLength, 3, 1, shorts(tt.height)},
+		{tagBitsPerSample, 3, 1, shorts(8 * tt.bytesPerSample)},
+		{tagCompression, 3, 1, shorts(compression)},
+		{tagSamplesPerPixel, 3, 1, shorts(1)},
+		{tagPredictor, 3, 1, shorts(max(tt.predictor, predictorNone))},
+		{tagSampleFormat, 3, 1, shorts(tt.format)},
+		{tagModelPixelScale, 12, 3, doubles(tt.dLon, tt.dLat, 0)},
+		{tagModelTiepoint, 12, 6, doubles(tiepoint...)},
+		{tagGeoKeyDirectory, 3, 12, shorts(1, 1, 0, 2, keyModelType, 0, 1, modelTypeGeographic, keyRasterType, 0, 1, rasterType)},
+	}
+	if tt.metadata != "" {
+		tags = append(tags, testTag{tagGDALMetadata, 2, len(tt.metadata) + 1, append([]byte(tt.metadata), 0)})
+	}
+	if tt.noData != "" {
+		tags = append(tags, testTag{tagGDALNoData, 2, len(tt.noData) + 1, append([]byte(tt.noData), 0)})
+	}
+	offsetsTag, countsTag := uint16(tagStripOffsets), uint16(tagStripByteCounts)
+	if tt.tiled {
+		offsetsTag, countsTag = tagTileOffsets, tagTileByteCounts
+		tags = append(tags, testTag{tagTileWidth, 3, 1, shorts(tt.segmentWidth)}, testTag{tagTileLength, 3, 1, shorts(tt.segmentHeight)})
+	} else {
+		tags = append(tags, testTag{tagRowsPerStrip, 3, 1, shorts(tt.segmentHeight)})
+	}
+	// placeholders, the offsets are computed below
+	tags = append(tags, testTag{offsetsTag, 4, len(segments), longs(make([]int, len(segments))...)})
+	counts := []int{}
+	for _, s := range segments {
+		counts = append(counts, len(s))
+	}
+	tags = append(tags, testTag{countsTag, 4, len(segments), longs(counts...)})
+	sort.Slice(tags, func(i, j int) bool { return tags[i].tag < tags[j].tag })
+
+	// layout: header, directory, tag values, segments
+	extra := 8 + 2 + 12*len(tags) + 4
+	for _, t := range tags {
+		if len(t.data) > 4 {
+			extra += len(t.data)
+		}
+	}
+	offset := extra
+	offsets := []int{}
+	for _, s := range segments {
+		offsets = append(offsets, offset)
+		offset += len(s)
+	}
+	for i := range tags {
+		if tags[i].tag == offsetsTag {
+			tags[i].data = longs(offsets...)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	if o == binary.LittleEndian {
+		out.WriteString("II")
+	} else {
+		out.WriteString("MM")
+	}
+	out.Write(shorts(42))
+	out.Write(longs(8))
+	out.Write(shorts(len(tags)))
+	valueOffset := 8 + 2 + 12*len(tags) + 4
+	values := &bytes.Buffer{}
+	for _, t := range tags {
+		out.Write(shorts(int(t.tag), int(t.typ)))
+		out.Write(longs(t.count))
+		if len(t.data) > 4 {
+			out.Write(longs(valueOffset + values.Len()))
+			values.Write(t.data)
+		} else {
+			out.Write(append(t.data, make([]byte, 4-len(t.data))...))
+		}
+	}
+	out.Write(longs(0))
+	out.Write(values.Bytes())
+	for _, s := range segments {
+		out.Write(s)
+	}
+	return out.Bytes()
+}
+
+func TestReadGeoTIFF(t *testing.T) {
+	identity := func(n float64) float64 { return n }
+	tcs := []struct {
+		name string
+		tiff testTiff
+	}{
+		{
+			name: "uncompressed strips",
+			tiff: testTiff{
+				order: binary.LittleEndian, width: 9, height: 7, lat0: 46, lon0: 6, dLat: 0.25, dLon: 0.5,
+				segmentWidth: 9, segmentHeight: 3, format: sampleFormatFloat, bytesPerSample: 4, value: identity,
+			},
+		},
+		{
+			name: "deflate tiles with floating point predictor",
+			tiff: testTiff{
+				order: binary.BigEndian, width: 20, height: 18, lat0: -30, lon0: -75, dLat: 0.1, dLon: 0.2, pixelIsPoint: true,
+				tiled: true, segmentWidth: 16, segmentHeight: 16, format: sampleFormatFloat, bytesPerSample: 4,
+				predictor: predictorFloat, deflate: true, value: identity,
+			},
+		},
+		{
+			name: "deflate strips of doubles with floating point predictor",
+			tiff: testTiff{
+				order: binary.LittleEndian, width: 5, height: 4, lat0: 10, lon0: 100, dLat: 1, dLon: 1,
+				segmentWidth: 5, segmentHeight: 1, format: sampleFormatFloat, bytesPerSample: 8,
+				predictor: predictorFloat, deflate: true, value: identity,
+			},
+		},
+		{
+			name: "scaled integers with horizontal predictor",
+			tiff: testTiff{
+				order: binary.LittleEndian, width: 6, height: 5, lat0: 60, lon0: 10, dLat: 0.5, dLon: 0.5,
+				segmentWidth: 6, segmentHeight: 5, format: sampleFormatInt, bytesPerSample: 2,
+				predictor: predictorHorizontal, deflate: true, value: func(n float64) float64 { return (n - 5) / 0.001 },
+				metadata: `<GDALMetadata><Item name="OFFSET" sample="0" role="offset">5</Item><Item name="SCALE" sample="0" role="scale">0.001</Item></GDALMetadata>`,
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			tt := tc.tiff
+			g, err := ReadGeoTIFF(bytes.NewReader(tt.bytes()))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			latMin := tt.lat0 - float64(tt.height-1)*tt.dLat
+			lonMax := tt.lon0 + float64(tt.width-1)*tt.dLon
+			checkGrid(t, g, latMin, tt.lat0, tt.lon0, lonMax)
+			if _, ok := g.Undulation(tt.lat0+tt.dLat, tt.lon0); ok {
+				t.Errorf("expected the position to be outside the grid")
+			}
+		})
+	}
+}
+
+func TestReadGeoTIFFNoData(t *testing.T) {
+	tt := testTiff{
+		order: binary.LittleEndian, width: 4, height: 4, lat0: 46, lon0: 6, dLat: 1, dLon: 1,
+		segmentWidth: 4, segmentHeight: 4, format: sampleFormatFloat, bytesPerSample: 4,
+		value: func(n float64) float64 { return n }, noData: "-9999", missing: []int{0},
+	}
+	g, err := ReadGeoTIFF(bytes.NewReader(tt.bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the top left pixel is missing
+	if _, ok := g.Undulation(45.5, 6.5); ok {
+		t.Errorf("expected the undulation to be missing")
+	}
+	if _, ok := g.Undulation(43.5, 8.5); !ok {
+		t.Errorf("expected the undulation to be available")
+	}
+}
+
+func TestReadGeoTIFFInvalid(t *testing.T) {
+	valid := testTiff{
+		order: binary.LittleEndian, width: 4, height: 4, lat0: 46, lon0: 6, dLat: 1, dLon: 1,
+		segmentWidth: 4, segmentHeight: 4, format: sampleFormatFloat, bytesPerSample: 4,
+		value: func(n float64) float64 { return n },
+	}.bytes()
+	if _, err := ReadGeoTIFF(bytes.NewReader([]byte("not a tiff"))); err == nil {
+		t.Errorf("expected error got none")
+	}
+	if _, err := ReadGeoTIFF(bytes.NewReader(valid[:len(valid)-10])); err == nil {
+		t.Errorf("expected error got none")
+	}
+	bigTiff := append([]byte{}, valid...)
+	bigTiff[2] = 43
+	if _, err := ReadGeoTIFF(bytes.NewReader(bigTiff)); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
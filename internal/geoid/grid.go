@@ -0,0 +1,129 @@
+// Package geoid reads geoid models, grids of geoid undulations used to convert the orthometric heights referred to
+// the geoid into heights above the WGS84 ellipsoid, and interpolates them.
+package geoid
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// boundaryTolerance is the distance, as a fraction of the grid spacing, by which the positions can fall outside
+// of the grid due to rounding errors and still be considered inside
+const boundaryTolerance = 1e-6
+
+// Grid is a geoid model defined by the undulations, i.e. the heights of the geoid above the WGS84 ellipsoid,
+// sampled on a regular grid of latitudes and longitudes
+type Grid struct {
+	// lat0 and lon0 are the latitude and longitude, in degrees, of the south west node of the grid
+	lat0, lon0 float64
+	// dLat and dLon are the spacing of the grid nodes, in degrees
+	dLat, dLon float64
+	rows, cols int
+	// values stores the undulations in meters row by row, from south to north and from west to east.
+	// Missing values are NaN.
+	values []float32
+	// wraps is true if the grid covers all longitudes, the last column being followed by the first one
+	wraps bool
+}
+
+// NewGrid returns a grid of rows x cols undulations, in meters, given row by row from south to north and from west
+// to east. lat0 and lon0 are the coordinates of the south west node and dLat and dLon the grid spacing, in degrees.
+// Missing values must be NaN.
+func NewGrid(lat0, lon0, dLat, dLon float64, rows, cols int, values []float32) (*Grid, error) {
+	if rows < 2 || cols < 2 {
+		return nil, fmt.Errorf("the geoid grid should have at least 2 rows and 2 columns, got %d x %d", rows, cols)
+	}
+	if !(dLat > 0) || !(dLon > 0) {
+		return nil, fmt.Errorf("invalid geoid grid spacing %f x %f", dLat, dLon)
+	}
+	if len(values) != rows*cols {
+		return nil, fmt.Errorf("expected %d geoid grid values got %d", rows*cols, len(values))
+	}
+	return &Grid{
+		lat0:   lat0,
+		lon0:   lon0,
+		dLat:   dLat,
+		dLon:   dLon,
+		rows:   rows,
+		cols:   cols,
+		values: values,
+		wraps:  math.Abs(float64(cols)*dLon-360) < dLon*1e-6,
+	}, nil
+}
+
+// Load reads the geoid grid stored in the given file. Supported formats are the NOAA/PROJ .gtx grids,
+// the GeographicLib .pgm grids and single band GeoTIFF .tif grids, like the ones distributed by PROJ.
+func Load(path string) (*Grid, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var g *Grid
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".gtx":
+		g, err = ReadGTX(f)
+	case ".pgm":
+		g, err = ReadPGM(f)
+	case ".tif", ".tiff":
+		g, err = ReadGeoTIFF(f)
+	default:
+		return nil, fmt.Errorf("unsupported geoid grid format %s, expected a .gtx, .pgm or .tif file", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read geoid grid %s: %w", path, err)
+	}
+	return g, nil
+}
+
+// Undulation returns the height in meters of the geoid above the WGS84 ellipsoid at the given latitude and longitude,
+// in degrees, bilinearly interpolating the grid. Returns false if the position is not covered by the grid or if any
+// of the surrounding grid values is missing.
+func (g *Grid) Undulation(lat, lon float64) (float64, bool) {
+	y := (lat - g.lat0) / g.dLat
+	// tolerate rounding errors on the grid boundaries
+	if y < -boundaryTolerance || y > float64(g.rows-1)+boundaryTolerance {
+		return 0, false
+	}
+	y = math.Min(math.Max(y, 0), float64(g.rows-1))
+	r0 := min(int(y), g.rows-2)
+
+	x := math.Mod(lon-g.lon0, 360)
+	if x < 0 {
+		x += 360
+	}
+	x /= g.dLon
+	if x > 360/g.dLon-boundaryTolerance {
+		// just west of the first column due to rounding errors
+		x = 0
+	}
+	var c0, c1 int
+	var fx float64
+	if g.wraps {
+		c0 = int(x) % g.cols
+		c1 = (c0 + 1) % g.cols
+		fx = x - math.Floor(x)
+	} else {
+		if x > float64(g.cols-1)+boundaryTolerance {
+			return 0, false
+		}
+		x = math.Min(x, float64(g.cols-1))
+		c0 = min(int(x), g.cols-2)
+		c1 = c0 + 1
+		fx = x - float64(c0)
+	}
+	fy := y - float64(r0)
+
+	v00 := float64(g.values[r0*g.cols+c0])
+	v01 := float64(g.values[r0*g.cols+c1])
+	v10 := float64(g.values[(r0+1)*g.cols+c0])
+	v11 := float64(g.values[(r0+1)*g.cols+c1])
+	n := v00*(1-fx)*(1-fy) + v01*fx*(1-fy) + v10*(1-fx)*fy + v11*fx*fy
+	if math.IsNaN(n) {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,190 @@
+package geoid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// plane is the undulation used to fill the test grids, linear so that it is exactly interpolated
+func plane(lat, lon float64) float64 {
+	return 0.5*lat + 0.25*lon - 10
+}
+
+// checkGrid verifies the undulations of the grid match the plane function in the given area
+func checkGrid(t *testing.T, g *Grid, latMin, latMax, lonMin, lonMax float64) {
+	for lat := latMin; lat <= latMax; lat += (latMax - latMin) / 7 {
+		for lon := lonMin; lon <= lonMax; lon += (lonMax - lonMin) / 7 {
+			n, ok := g.Undulation(lat, lon)
+			if !ok {
+				t.Fatalf("expected %f %f to be covered by the grid", lat, lon)
+			}
+			if expected := plane(lat, lon); math.Abs(n-expected) > 1e-3 {
+				t.Errorf("at %f %f expected undulation %f got %f", lat, lon, expected, n)
+			}
+		}
+	}
+}
+
+func TestGridUndulation(t *testing.T) {
+	// 3 x 4 grid with south west node in 10, 20 and a spacing of 0.5 x 1 degrees
+	values := []float32{}
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 4; c++ {
+			values = append(values, float32(plane(10+float64(r)*0.5, 20+float64(c))))
+		}
+	}
+	g, err := NewGrid(10, 20, 0.5, 1, 3, 4, values)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkGrid(t, g, 10, 11, 20, 23)
+	// longitudes are normalized
+	if n, ok := g.Undulation(10.5, 21.5-360); !ok || math.Abs(n-plane(10.5, 21.5)) > 1e-6 {
+		t.Errorf("expected undulation %f got %f %v", plane(10.5, 21.5), n, ok)
+	}
+	for _, p := range [][2]float64{{9.9, 21}, {11.1, 21}, {10.5, 19.9}, {10.5, 23.1}} {
+		if _, ok := g.Undulation(p[0], p[1]); ok {
+			t.Errorf("expected %v to be outside the grid", p)
+		}
+	}
+
+	// missing values
+	values[5] = float32(math.NaN())
+	if _, ok := g.Undulation(10.25, 21.5); ok {
+		t.Errorf("expected the undulation to be missing")
+	}
+	if _, ok := g.Undulation(10.25, 22.5); !ok {
+		t.Errorf("expected the undulation to be available")
+	}
+}
+
+func TestGridUndulationWraps(t *testing.T) {
+	// global grid with a 90 degrees spacing, without a duplicated column at 360
+	values := []float32{
+		0, 1, 2, 3,
+		4, 5, 6, 7,
+		8, 9, 10, 11,
+	}
+	g, err := NewGrid(-90, 0, 90, 90, 3, 4, values)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// between the last and the first column
+	if n, ok := g.Undulation(0, 315); !ok || n != 5.5 {
+		t.Errorf("expected undulation %f got %f %v", 5.5, n, ok)
+	}
+	if n, ok := g.Undulation(0, -45); !ok || n != 5.5 {
+		t.Errorf("expected undulation %f got %f %v", 5.5, n, ok)
+	}
+	if n, ok := g.Undulation(90, 180); !ok || n != 10 {
+		t.Errorf("expected undulation %f got %f %v", 10.0, n, ok)
+	}
+}
+
+func TestNewGridInvalid(t *testing.T) {
+	if _, err := NewGrid(0, 0, 1, 1, 1, 2, []float32{1, 2}); err == nil {
+		t.Errorf("expected error got none")
+	}
+	if _, err := NewGrid(0, 0, 0, 1, 2, 2, []float32{1, 2, 3, 4}); err == nil {
+		t.Errorf("expected error got none")
+	}
+	if _, err := NewGrid(0, 0, 1, 1, 2, 2, []float32{1, 2, 3}); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
+
+// gtxGrid returns a .gtx file with south west node in lat0, lon0 filled with the plane function
+func gtxGrid(lat0, lon0, dLat, dLon float64, rows, cols int) []byte {
+	b := &bytes.Buffer{}
+	binary.Write(b, binary.BigEndian, []float64{lat0, lon0, dLat, dLon})
+	binary.Write(b, binary.BigEndian, []int32{int32(rows), int32(cols)})
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			binary.Write(b, binary.BigEndian, float32(plane(lat0+float64(r)*dLat, lon0+float64(c)*dLon)))
+		}
+	}
+	return b.Bytes()
+}
+
+func TestReadGTX(t *testing.T) {
+	data := gtxGrid(40, 350, 0.25, 0.5, 5, 9)
+	g, err := ReadGTX(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// longitudes in the 0-360 range are supported
+	if n, ok := g.Undulation(40.5, -9); !ok || math.Abs(n-plane(40.5, 351)) > 1e-4 {
+		t.Errorf("expected undulation %f got %f %v", plane(40.5, 351), n, ok)
+	}
+
+	data = gtxGrid(40, 10, 0.25, 0.5, 5, 9)
+	// no data values
+	binary.BigEndian.PutUint32(data[40:], math.Float32bits(-88.8888))
+	g, err = ReadGTX(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkGrid(t, g, 40.25, 41, 10.5, 14)
+	if _, ok := g.Undulation(40.1, 10.1); ok {
+		t.Errorf("expected the undulation to be missing")
+	}
+
+	if _, err := ReadGTX(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Errorf("expected error got none")
+	}
+	if _, err := ReadGTX(bytes.NewReader(data[:20])); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
+
+func TestReadPGM(t *testing.T) {
+	// 90 degrees grid: 4 columns from longitude 0 and 3 rows from latitude 90
+	b := &bytes.Buffer{}
+	b.WriteString("P5\n# Geoid file in PGM format for the GeographicLib::Geoid class\n# Offset -100\n# Scale 0.25\n4    3\n65535\n")
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 4; c++ {
+			raw := (plane(90-float64(r)*90, float64(c)*90) + 100) / 0.25
+			binary.Write(b, binary.BigEndian, uint16(math.Round(raw)))
+		}
+	}
+	g, err := ReadPGM(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkGrid(t, g, -90, 90, 0, 270)
+
+	if _, err := ReadPGM(bytes.NewReader([]byte("P5\n4 3\n65535\n"))); err == nil {
+		t.Errorf("expected error got none")
+	}
+	if _, err := ReadPGM(bytes.NewReader([]byte("P2\n# Offset 1\n# Scale 1\n4 3\n65535\n"))); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "geoid.GTX")
+	if err := os.WriteFile(path, gtxGrid(40, 10, 0.25, 0.5, 5, 9), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkGrid(t, g, 40, 41, 10, 14)
+
+	if _, err := Load(filepath.Join(dir, "missing.gtx")); err == nil {
+		t.Errorf("expected error got none")
+	}
+	unsupported := filepath.Join(dir, "geoid.bin")
+	if err := os.WriteFile(unsupported, []byte{1}, 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := Load(unsupported); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
@@ -0,0 +1,39 @@
+package geoid
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// gtxNoData is the value marking the missing undulations in .gtx grids
+const gtxNoData = -88.8888
+
+// ReadGTX reads a geoid grid in the NOAA .gtx format: a big endian header with the latitude and longitude of the
+// south west node, the latitude and longitude spacing, in degrees, and the number of rows and columns, followed by
+// the float32 undulations row by row from south to north.
+func ReadGTX(r io.Reader) (*Grid, error) {
+	br := bufio.NewReader(r)
+	var header struct {
+		Lat0, Lon0, DLat, DLon float64
+		Rows, Cols             int32
+	}
+	if err := binary.Read(br, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("unable to read the gtx header: %w", err)
+	}
+	if header.Rows < 2 || header.Cols < 2 || int64(header.Rows)*int64(header.Cols) > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid gtx grid size %d x %d", header.Rows, header.Cols)
+	}
+	values := make([]float32, int(header.Rows)*int(header.Cols))
+	if err := binary.Read(br, binary.BigEndian, values); err != nil {
+		return nil, fmt.Errorf("unable to read the gtx values: %w", err)
+	}
+	for i, v := range values {
+		if math.Abs(float64(v)-gtxNoData) < 1e-3 {
+			values[i] = float32(math.NaN())
+		}
+	}
+	return NewGrid(header.Lat0, header.Lon0, header.DLat, header.DLon, int(header.Rows), int(header.Cols), values)
+}
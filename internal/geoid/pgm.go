@@ -0,0 +1,81 @@
+package geoid
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ReadPGM reads a geoid grid in the GeographicLib .pgm format: a binary 16 bit portable graymap covering the whole
+// globe, from latitude 90 to -90 and from longitude 0 eastwards, whose raw values are converted to undulations
+// using the Offset and Scale stored in the header comments.
+func ReadPGM(r io.Reader) (*Grid, error) {
+	br := bufio.NewReader(r)
+	offset, scale := math.NaN(), math.NaN()
+	// the header is made of the magic number, width, height and max value, separated by whitespaces and comments
+	fields := []int{}
+	magic := ""
+	for len(fields) < 3 {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the pgm header: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			parts := strings.Fields(strings.TrimPrefix(line, "#"))
+			if len(parts) == 2 && (parts[0] == "Offset" || parts[0] == "Scale") {
+				v, err := strconv.ParseFloat(parts[1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid pgm %s: %w", parts[0], err)
+				}
+				if parts[0] == "Offset" {
+					offset = v
+				} else {
+					scale = v
+				}
+			}
+			continue
+		}
+		for _, f := range strings.Fields(line) {
+			if magic == "" {
+				magic = f
+				continue
+			}
+			v, err := strconv.Atoi(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pgm header value %q", f)
+			}
+			fields = append(fields, v)
+		}
+		if magic != "" && magic != "P5" {
+			return nil, fmt.Errorf("unsupported pgm type %s, expected a binary P5 graymap", magic)
+		}
+	}
+	if math.IsNaN(offset) || math.IsNaN(scale) {
+		return nil, fmt.Errorf("the pgm header lacks the Offset and Scale comments of a GeographicLib geoid grid")
+	}
+	width, height, maxValue := fields[0], fields[1], fields[2]
+	if maxValue != 65535 {
+		return nil, fmt.Errorf("unsupported pgm max value %d, expected 65535", maxValue)
+	}
+	if width < 2 || height < 2 || int64(width)*int64(height) > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid pgm size %d x %d", width, height)
+	}
+	raw := make([]uint16, width*height)
+	if err := binary.Read(br, binary.BigEndian, raw); err != nil {
+		return nil, fmt.Errorf("unable to read the pgm values: %w", err)
+	}
+	// the pgm rows go from north to south, reverse them
+	values := make([]float32, width*height)
+	for row := 0; row < height; row++ {
+		src := raw[(height-1-row)*width : (height-row)*width]
+		for col, v := range src {
+			values[row*width+col] = float32(offset + scale*float64(v))
+		}
+	}
+	return NewGrid(-90, 0, 180/float64(height-1), 360/float64(width), height, width, values)
+}
@@ -0,0 +1,80 @@
+package geom
+
+import "fmt"
+
+// AttributeType is the data type of the components of an additional point attribute
+type AttributeType uint8
+
+const (
+	AttributeUint8 AttributeType = iota
+	AttributeInt8
+	AttributeUint16
+	AttributeInt16
+	AttributeUint32
+	AttributeInt32
+	AttributeUint64
+	AttributeInt64
+	AttributeFloat32
+	AttributeFloat64
+)
+
+// Size returns the size in bytes of a component of the given type
+func (t AttributeType) Size() int {
+	switch t {
+	case AttributeUint8, AttributeInt8:
+		return 1
+	case AttributeUint16, AttributeInt16:
+		return 2
+	case AttributeUint32, AttributeInt32, AttributeFloat32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func (t AttributeType) String() string {
+	switch t {
+	case AttributeUint8:
+		return "uint8"
+	case AttributeInt8:
+		return "int8"
+	case AttributeUint16:
+		return "uint16"
+	case AttributeInt16:
+		return "int16"
+	case AttributeUint32:
+		return "uint32"
+	case AttributeInt32:
+		return "int32"
+	case AttributeUint64:
+		return "uint64"
+	case AttributeInt64:
+		return "int64"
+	case AttributeFloat32:
+		return "float32"
+	case AttributeFloat64:
+		return "float64"
+	}
+	return fmt.Sprintf("AttributeType(%d)", uint8(t))
+}
+
+// Attribute describes an additional attribute carried by each point, besides coordinates, colors, intensity
+// and classification. The values of all the attributes of a point are stored apart from the point, see
+// PointList.Attributes, in the same order the attributes are declared, each attribute taking as many values as its
+// components.
+type Attribute struct {
+	Name        string
+	Description string
+	Type        AttributeType
+	// Components is the number of values of the attribute, 1 for scalars, 2 or 3 for arrays
+	Components int
+}
+
+// AttributesLength returns the number of values needed to store the given attributes of a point
+func AttributesLength(attrs []Attribute) int {
+	n := 0
+	for _, a := range attrs {
+		n += a.Components
+	}
+	return n
+}
@@ -16,6 +16,8 @@ type Point64 struct {
 	B              uint8
 	Intensity      uint8
 	Classification uint8
+	// CRS of the coordinates, only set if it differs from the CRS of the reader returning the point
+	CRS string
 }
 
 // Builds a new model.Point from the given coordinates, colors, intensity and classification values
@@ -36,6 +38,9 @@ func NewPoint(X, Y, Z float32, R, G, B, Intensity, Classification uint8) model.P
 type PointList interface {
 	Len() int
 	Next() (model.Point, error)
+	// Attributes returns the values of the additional attributes of the point last returned by Next, nil if the
+	// points have no attributes. The values must not be modified and are only valid until the next call to Next.
+	Attributes() []float64
 	Reset()
 }
 
@@ -71,6 +76,11 @@ func (l *LinkedPointStream) Next() (model.Point, error) {
 	return pt, nil
 }
 
+// Attributes returns nil as linked points have no additional attributes
+func (l *LinkedPointStream) Attributes() []float64 {
+	return nil
+}
+
 func (l *LinkedPointStream) Len() int {
 	return l.len
 }
@@ -54,3 +54,66 @@ func normalToWGS84FromPoint(x, y, z float64) model.Vector {
 		Z: 2 * z / math.Pow(b, 2),
 	}.Unit()
 }
+
+// WGS84 ellipsoid parameters
+const (
+	wgs84A  = 6378137.0
+	wgs84F  = 1 / 298.257223563
+	wgs84E2 = wgs84F * (2 - wgs84F)
+)
+
+// ECEFToGeodetic converts EPSG 4978 coordinates to WGS84 geodetic coordinates: latitude and longitude in degrees
+// and height above the ellipsoid in meters
+func ECEFToGeodetic(x, y, z float64) (lat, lon, h float64) {
+	lon = math.Atan2(y, x)
+	p := math.Hypot(x, y)
+	if p == 0 {
+		// on the polar axis
+		lat = math.Copysign(math.Pi/2, z)
+		return lat * 180 / math.Pi, 0, math.Abs(z) - wgs84A*math.Sqrt(1-wgs84E2)
+	}
+	// iterate starting from the latitude for h = 0, converges to sub millimeter precision in a few iterations
+	phi := math.Atan2(z, p*(1-wgs84E2))
+	for i := 0; i < 10; i++ {
+		sin := math.Sin(phi)
+		n := wgs84A / math.Sqrt(1-wgs84E2*sin*sin)
+		h = p/math.Cos(phi) - n
+		next := math.Atan2(z, p*(1-wgs84E2*n/(n+h)))
+		if math.Abs(next-phi) < 1e-14 {
+			phi = next
+			break
+		}
+		phi = next
+	}
+	sin := math.Sin(phi)
+	n := wgs84A / math.Sqrt(1-wgs84E2*sin*sin)
+	if math.Abs(phi) < math.Pi/4 {
+		h = p/math.Cos(phi) - n
+	} else {
+		h = z/sin - n*(1-wgs84E2)
+	}
+	return phi * 180 / math.Pi, lon * 180 / math.Pi, h
+}
+
+// GeodeticToECEF converts WGS84 geodetic coordinates, latitude and longitude in degrees and height above the
+// ellipsoid in meters, to EPSG 4978 coordinates
+func GeodeticToECEF(lat, lon, h float64) model.Vector {
+	phi, lambda := lat*math.Pi/180, lon*math.Pi/180
+	sin := math.Sin(phi)
+	n := wgs84A / math.Sqrt(1-wgs84E2*sin*sin)
+	return model.Vector{
+		X: (n + h) * math.Cos(phi) * math.Cos(lambda),
+		Y: (n + h) * math.Cos(phi) * math.Sin(lambda),
+		Z: (n*(1-wgs84E2) + h) * sin,
+	}
+}
+
+// GeodeticNormal returns the unit vector normal to the WGS84 ellipsoid at the given latitude and longitude, in degrees
+func GeodeticNormal(lat, lon float64) model.Vector {
+	phi, lambda := lat*math.Pi/180, lon*math.Pi/180
+	return model.Vector{
+		X: math.Cos(phi) * math.Cos(lambda),
+		Y: math.Cos(phi) * math.Sin(lambda),
+		Z: math.Sin(phi),
+	}
+}
@@ -73,3 +73,33 @@ func TestLocalToGlobalTransformFromPoint(t *testing.T) {
 	// Z axis should be oriented correctly
 	compareWithTolerance(model.Vector{X: 0, Y: -100 - 1, Z: 0}, trans.Forward(model.Vector{X: 0, Y: 0, Z: 1}), t)
 }
+
+func TestGeodeticConversions(t *testing.T) {
+	tcs := []struct {
+		lat, lon, h float64
+	}{
+		{0, 0, 0},
+		{45, 10, 100},
+		{-33.5, -70.25, 2500.5},
+		{89.9, 179.9, -30},
+		{-89.999, -120, 10},
+		{90, 0, 5},
+	}
+	for _, tc := range tcs {
+		v := GeodeticToECEF(tc.lat, tc.lon, tc.h)
+		lat, lon, h := ECEFToGeodetic(v.X, v.Y, v.Z)
+		if math.Abs(lat-tc.lat) > 1e-9 || math.Abs(h-tc.h) > 1e-4 {
+			t.Errorf("expected lat %f h %f got lat %f h %f", tc.lat, tc.h, lat, h)
+		}
+		if math.Abs(tc.lat) != 90 && math.Abs(lon-tc.lon) > 1e-9 {
+			t.Errorf("expected lon %f got %f", tc.lon, lon)
+		}
+		// the normal is the direction along which the height grows
+		n := GeodeticNormal(tc.lat, tc.lon)
+		up := GeodeticToECEF(tc.lat, tc.lon, tc.h+1)
+		compareWithTolerance(model.Vector{X: v.X + n.X, Y: v.Y + n.Y, Z: v.Z + n.Z}, up, t)
+	}
+	// known coordinates
+	compareWithTolerance(model.Vector{X: 6378137, Y: 0, Z: 0}, GeodeticToECEF(0, 0, 0), t)
+	compareWithTolerance(model.Vector{X: 0, Y: 0, Z: 6356752.314245179}, GeodeticToECEF(90, 0, 0), t)
+}
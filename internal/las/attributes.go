@@ -0,0 +1,194 @@
+package las
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las/golas"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+var extraBytesAttributeTypes = map[golas.ExtraBytesType]geom.AttributeType{
+	golas.ExtraBytesUint8:   geom.AttributeUint8,
+	golas.ExtraBytesInt8:    geom.AttributeInt8,
+	golas.ExtraBytesUint16:  geom.AttributeUint16,
+	golas.ExtraBytesInt16:   geom.AttributeInt16,
+	golas.ExtraBytesUint32:  geom.AttributeUint32,
+	golas.ExtraBytesInt32:   geom.AttributeInt32,
+	golas.ExtraBytesUint64:  geom.AttributeUint64,
+	golas.ExtraBytesInt64:   geom.AttributeInt64,
+	golas.ExtraBytesFloat32: geom.AttributeFloat32,
+	golas.ExtraBytesFloat64: geom.AttributeFloat64,
+}
+
+// Names of the standard LAS point attributes that can be optionally decoded, besides coordinates,
+// colors, intensity and classification
+const (
+	LasAttributeGpsTime         = "gps-time"
+	LasAttributeReturnNumber    = "return-number"
+	LasAttributeNumberOfReturns = "number-of-returns"
+	LasAttributeScanAngle       = "scan-angle"
+	LasAttributePointSourceID   = "point-source-id"
+	LasAttributeNIR             = "nir"
+	LasAttributeUserData        = "user-data"
+	LasAttributeIntensity16     = "intensity-16"
+)
+
+// lasAttribute describes how to decode a standard LAS point attribute
+type lasAttribute struct {
+	attribute geom.Attribute
+	// formats lists the point formats storing the attribute, nil if stored by all formats
+	formats []uint8
+	value   func(pt golas.Point) float64
+}
+
+var lasAttributes = map[string]lasAttribute{
+	LasAttributeGpsTime: {
+		attribute: geom.Attribute{Name: "GPS_TIME", Description: "GPS time", Type: geom.AttributeFloat64, Components: 1},
+		formats:   []uint8{1, 3, 4, 5, 6, 7, 8, 9, 10},
+		value:     func(pt golas.Point) float64 { return pt.GPSTime },
+	},
+	LasAttributeReturnNumber: {
+		attribute: geom.Attribute{Name: "RETURN_NUMBER", Description: "Return number", Type: geom.AttributeUint8, Components: 1},
+		value:     func(pt golas.Point) float64 { return float64(pt.ReturnNumber()) },
+	},
+	LasAttributeNumberOfReturns: {
+		attribute: geom.Attribute{Name: "NUMBER_OF_RETURNS", Description: "Number of returns of the pulse", Type: geom.AttributeUint8, Components: 1},
+		value:     func(pt golas.Point) float64 { return float64(pt.NumberOfReturns()) },
+	},
+	LasAttributeScanAngle: {
+		attribute: geom.Attribute{Name: "SCAN_ANGLE", Description: "Scan angle in degrees", Type: geom.AttributeFloat32, Components: 1},
+		value: func(pt golas.Point) float64 {
+			if pt.PointDataRecordFormat < 6 {
+				return float64(pt.ScanAngleRank)
+			}
+			// extended scan angle, stored in 0.006 degrees increments
+			return float64(pt.ScanAngle) * 0.006
+		},
+	},
+	LasAttributePointSourceID: {
+		attribute: geom.Attribute{Name: "POINT_SOURCE_ID", Description: "Point source ID", Type: geom.AttributeUint16, Components: 1},
+		value:     func(pt golas.Point) float64 { return float64(pt.PointSourceID) },
+	},
+	LasAttributeNIR: {
+		attribute: geom.Attribute{Name: "NIR", Description: "Near infrared", Type: geom.AttributeUint16, Components: 1},
+		formats:   []uint8{8, 10},
+		value:     func(pt golas.Point) float64 { return float64(pt.NIR) },
+	},
+	LasAttributeUserData: {
+		attribute: geom.Attribute{Name: "USER_DATA", Description: "User data", Type: geom.AttributeUint8, Components: 1},
+		value:     func(pt golas.Point) float64 { return float64(pt.UserData) },
+	},
+	LasAttributeIntensity16: {
+		attribute: geom.Attribute{Name: "INTENSITY_16", Description: "Full 16 bit intensity", Type: geom.AttributeUint16, Components: 1},
+		value:     func(pt golas.Point) float64 { return float64(pt.Intensity) },
+	},
+}
+
+// IsLasAttribute returns true if the given name identifies a standard LAS attribute that can be decoded
+func IsLasAttribute(name string) bool {
+	_, ok := lasAttributes[name]
+	return ok
+}
+
+// pointDecoder converts golas points into the point representation used by gocesiumtiler,
+// decoding the selected standard LAS attributes and extra bytes attributes
+type pointDecoder struct {
+	eightBitColor bool
+	lasAttributes []lasAttribute
+	extraBytes    []golas.ExtraBytesDescriptor
+	attributes    []geom.Attribute
+}
+
+// newPointDecoder returns a pointDecoder for the points of the given LAS file. lasAttrs lists the standard LAS attributes
+// to decode, see the LasAttribute constants, and extraDims lists the names, case insensitive, of the extra bytes attributes
+// to decode. An error is returned if any of them is not available.
+func newPointDecoder(g *golas.Las, eightBitColor bool, lasAttrs []string, extraDims []string) (pointDecoder, error) {
+	d := pointDecoder{eightBitColor: eightBitColor}
+	format := g.Header.PointDataRecordFormat
+	for _, name := range lasAttrs {
+		a, ok := lasAttributes[name]
+		if !ok {
+			return d, fmt.Errorf("unknown LAS attribute %s", name)
+		}
+		if a.formats != nil && !slices.Contains(a.formats, format) {
+			return d, fmt.Errorf("LAS attribute %s not available in point format %d", name, format)
+		}
+		d.lasAttributes = append(d.lasAttributes, a)
+		d.attributes = append(d.attributes, a.attribute)
+	}
+	for _, name := range extraDims {
+		found := false
+		for _, eb := range g.ExtraBytes() {
+			if !strings.EqualFold(eb.Name, name) {
+				continue
+			}
+			t, ok := extraBytesAttributeTypes[eb.Type]
+			if !ok {
+				return d, fmt.Errorf("extra dimension %s has an undocumented data type", eb.Name)
+			}
+			if eb.Scaled {
+				t = geom.AttributeFloat64
+			}
+			d.extraBytes = append(d.extraBytes, eb)
+			d.attributes = append(d.attributes, geom.Attribute{
+				Name:        eb.Name,
+				Description: eb.Description,
+				Type:        t,
+				Components:  eb.Components,
+			})
+			found = true
+			break
+		}
+		if !found {
+			available := []string{}
+			for _, eb := range g.ExtraBytes() {
+				available = append(available, eb.Name)
+			}
+			return d, fmt.Errorf("extra dimension %s not found, available extra dimensions: [%s]", name, strings.Join(available, ", "))
+		}
+	}
+	return d, nil
+}
+
+// decode converts the golas point, storing the values of the attributes in attrs unless it is empty
+func (d pointDecoder) decode(pt golas.Point, attrs []float64) (geom.Point64, error) {
+	p := toPoint64(pt, d.eightBitColor)
+	if len(d.attributes) == 0 || len(attrs) == 0 {
+		return p, nil
+	}
+	offset := 0
+	for _, a := range d.lasAttributes {
+		attrs[offset] = a.value(pt)
+		offset++
+	}
+	for _, eb := range d.extraBytes {
+		if err := eb.ReadValues(pt, attrs[offset:]); err != nil {
+			return p, err
+		}
+		offset += eb.Components
+	}
+	return p, nil
+}
+
+// toPoint64 converts a golas point into the point representation used by gocesiumtiler
+func toPoint64(pt golas.Point, eightBitColor bool) geom.Point64 {
+	var corr uint16 = 256
+	if eightBitColor {
+		corr = 1
+	}
+	return geom.Point64{
+		Vector: model.Vector{
+			X: pt.X,
+			Y: pt.Y,
+			Z: pt.Z,
+		},
+		R:              uint8(pt.Red / corr),
+		G:              uint8(pt.Green / corr),
+		B:              uint8(pt.Blue / corr),
+		Intensity:      uint8(pt.Intensity),
+		Classification: pt.Classification,
+	}
+}
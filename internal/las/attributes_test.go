@@ -0,0 +1,150 @@
+package las
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las/golas"
+)
+
+const extraBytesTestFile = "./golas/testdata/extrabytes.las"
+
+func TestExtraDimensions(t *testing.T) {
+	r, err := NewGoLasReader(extraBytesTestFile, "EPSG:32633", false, nil, []string{"time", "Colors"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	expected := []geom.Attribute{
+		{Name: "Time", Description: "Time", Type: geom.AttributeUint64, Components: 1},
+		{Name: "Colors", Description: "Colors", Type: geom.AttributeUint16, Components: 3},
+	}
+	if actual := r.Attributes(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected attributes %v got %v", expected, actual)
+	}
+	values := make([]float64, 4)
+	for i := 0; i < 10; i++ {
+		pt, err := r.GetNextWithAttributes(values)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// the file stores copies of the time and colors as extra bytes
+		if values[0] < 0 || math.IsNaN(values[0]) {
+			t.Errorf("unexpected time %v", values[0])
+		}
+		if uint8(uint16(values[1])/256) != pt.R || uint8(uint16(values[2])/256) != pt.G || uint8(uint16(values[3])/256) != pt.B {
+			t.Errorf("expected colors %d %d %d got %v", pt.R, pt.G, pt.B, values[1:])
+		}
+	}
+}
+
+func TestExtraDimensionsNone(t *testing.T) {
+	r, err := NewGoLasReader(extraBytesTestFile, "EPSG:32633", false, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	if actual := r.Attributes(); actual != nil {
+		t.Errorf("expected no attributes got %v", actual)
+	}
+	values := []float64{1}
+	if _, err := r.GetNextWithAttributes(values); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if values[0] != 1 {
+		t.Errorf("expected no attribute values got %v", values)
+	}
+}
+
+func TestExtraDimensionsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		dims  []string
+	}{
+		{name: "missing", files: []string{extraBytesTestFile}, dims: []string{"Amplitude"}},
+		{name: "undocumented", files: []string{extraBytesTestFile}, dims: []string{"Reserved"}},
+		{name: "no extra bytes", files: []string{"./testdata/las-12-pf1.las"}, dims: []string{"Time"}},
+		{name: "ply", files: []string{"./missing.ply"}, dims: []string{"Time"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewCombinedFileLasReader(tc.files, SameCRS("EPSG:32633"), false, nil, tc.dims); err == nil {
+				t.Errorf("expected error got none")
+			}
+		})
+	}
+}
+
+func TestCombinedReaderInconsistentAttributes(t *testing.T) {
+	attrs := map[string][]geom.Attribute{
+		"a": {{Name: "Time", Type: geom.AttributeUint64, Components: 1}},
+		"b": {{Name: "Time", Type: geom.AttributeFloat64, Components: 1}},
+	}
+	_, err := newCombinedReader([]string{"a", "b"}, SameCRS("EPSG:32633"), func(f string, crs string) (LasReader, error) {
+		return &MockLasReader{CRS: crs, Attrs: attrs[f]}, nil
+	})
+	if err == nil {
+		t.Errorf("expected error got none")
+	}
+}
+
+func TestLasAttributes(t *testing.T) {
+	attrs := []string{
+		LasAttributeGpsTime, LasAttributeReturnNumber, LasAttributeNumberOfReturns, LasAttributeScanAngle,
+		LasAttributePointSourceID, LasAttributeUserData, LasAttributeIntensity16,
+	}
+	r, err := NewGoLasReader("./golas/testdata/las-14-pf7-sf.las", "EPSG:32633", false, attrs, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	if actual := len(r.Attributes()); actual != len(attrs) {
+		t.Fatalf("expected %d attributes got %d", len(attrs), actual)
+	}
+	if a := r.Attributes()[0]; a.Name != "GPS_TIME" || a.Type != geom.AttributeFloat64 {
+		t.Errorf("unexpected gps time attribute %v", a)
+	}
+
+	f, err := os.Open("./golas/testdata/las-14-pf7-sf.las")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := golas.NewLas(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]float64, len(attrs))
+	for i := 0; i < 10; i++ {
+		if _, err := r.GetNextWithAttributes(values); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		exp, err := g.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []float64{
+			exp.GPSTime, float64(exp.ReturnNumber()), float64(exp.NumberOfReturns()), float64(exp.ScanAngle) * 0.006,
+			float64(exp.PointSourceID), float64(exp.UserData), float64(exp.Intensity),
+		}
+		if !reflect.DeepEqual(values, expected) {
+			t.Errorf("expected attribute values %v got %v", expected, values)
+		}
+	}
+}
+
+func TestLasAttributesErrors(t *testing.T) {
+	if _, err := NewGoLasReader("./testdata/las-12-pf1.las", "EPSG:32633", false, []string{LasAttributeNIR}, nil); err == nil {
+		t.Errorf("expected error for NIR in point format 1, got none")
+	}
+	if _, err := NewGoLasReader("./testdata/las-12-pf1.las", "EPSG:32633", false, []string{"unknown"}, nil); err == nil {
+		t.Errorf("expected error for unknown attribute, got none")
+	}
+	if !IsLasAttribute(LasAttributeNIR) || IsLasAttribute("unknown") {
+		t.Errorf("unexpected IsLasAttribute result")
+	}
+}
@@ -0,0 +1,139 @@
+package las
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las/golas"
+)
+
+// CopcReader reads the points of a COPC file one octree node at a time. The nodes to read can be
+// restricted to the ones intersecting a bounding box and/or to the ones up to a given octree level.
+type CopcReader struct {
+	file     *os.File
+	f        *golas.Las
+	decoder  pointDecoder
+	crs      string
+	bbox     *geom.BoundingBox
+	maxLevel int
+	nodes    []golas.CopcEntry
+	numPts   int
+	current  int
+	buffer   []golas.Point
+	sync.Mutex
+}
+
+// WithCopcBoundingBox restricts the read to the octree nodes intersecting the given bounding box,
+// expressed in the CRS of the COPC file. Nodes are never split, hence points slightly outside the
+// bounding box can be returned.
+func WithCopcBoundingBox(bbox geom.BoundingBox) func(*CopcReader) {
+	return func(r *CopcReader) {
+		r.bbox = &bbox
+	}
+}
+
+// WithCopcMaxLevel restricts the read to the octree nodes up to the given level, with 0 being the root node.
+// Negative values mean no restriction.
+func WithCopcMaxLevel(level int) func(*CopcReader) {
+	return func(r *CopcReader) {
+		r.maxLevel = level
+	}
+}
+
+// NewCopcReader returns a CopcReader instance. If crs is empty the system will attempt to autodetect
+// the CRS from the LAS metadata and return an error in case of issues. lasAttrs lists the standard LAS
+// attributes to read, see the LasAttribute constants, and extraDims the names of the extra bytes attributes to read.
+func NewCopcReader(fileName string, crs string, eightBitColor bool, lasAttrs []string, extraDims []string, opts ...func(*CopcReader)) (*CopcReader, error) {
+	f, g, crs, err := openLas(fileName, crs)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newCopcReader(f, g, crs, eightBitColor, lasAttrs, extraDims, opts...)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func newCopcReader(file *os.File, g *golas.Las, crs string, eightBitColor bool, lasAttrs []string, extraDims []string, opts ...func(*CopcReader)) (*CopcReader, error) {
+	d, err := newPointDecoder(g, eightBitColor, lasAttrs, extraDims)
+	if err != nil {
+		return nil, err
+	}
+	r := &CopcReader{
+		file:     file,
+		f:        g,
+		decoder:  d,
+		crs:      crs,
+		maxLevel: -1,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	entries, err := g.CopcHierarchy()
+	if err != nil {
+		return nil, err
+	}
+	info := g.CopcInfo()
+	for _, e := range entries {
+		if e.PointCount <= 0 {
+			continue
+		}
+		if r.maxLevel >= 0 && int(e.Key.Level) > r.maxLevel {
+			continue
+		}
+		if r.bbox != nil {
+			min, max := e.Key.Bounds(info)
+			if min[0] > r.bbox.Xmax || max[0] < r.bbox.Xmin ||
+				min[1] > r.bbox.Ymax || max[1] < r.bbox.Ymin ||
+				min[2] > r.bbox.Zmax || max[2] < r.bbox.Zmin {
+				continue
+			}
+		}
+		r.nodes = append(r.nodes, e)
+		r.numPts += int(e.PointCount)
+	}
+	return r, nil
+}
+
+func (r *CopcReader) NumberOfPoints() int {
+	return r.numPts
+}
+
+func (r *CopcReader) GetCRS() string {
+	return r.crs
+}
+
+func (r *CopcReader) Attributes() []geom.Attribute {
+	return r.decoder.attributes
+}
+
+func (r *CopcReader) Close() {
+	r.file.Close()
+}
+
+func (r *CopcReader) GetNext() (geom.Point64, error) {
+	return r.GetNextWithAttributes(nil)
+}
+
+func (r *CopcReader) GetNextWithAttributes(attrs []float64) (geom.Point64, error) {
+	r.Lock()
+	defer r.Unlock()
+	for len(r.buffer) == 0 {
+		if r.current >= len(r.nodes) {
+			return geom.Point64{}, io.EOF
+		}
+		pts, err := r.f.ReadCopcNode(r.nodes[r.current])
+		if err != nil {
+			return geom.Point64{}, err
+		}
+		r.current++
+		r.buffer = pts
+	}
+	pt := r.buffer[0]
+	r.buffer = r.buffer[1:]
+	return r.decoder.decode(pt, attrs)
+}
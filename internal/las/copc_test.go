@@ -0,0 +1,120 @@
+package las
+
+import (
+	"io"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+)
+
+const copcTestFile = "./golas/testdata/copc.laz"
+
+func TestCopcReader(t *testing.T) {
+	allZ := func(xmin, xmax, ymin, ymax float64) geom.BoundingBox {
+		return geom.NewBoundingBox(xmin, xmax, ymin, ymax, math.Inf(-1), math.Inf(1))
+	}
+	tcs := []struct {
+		name     string
+		opts     []func(*CopcReader)
+		expected int
+	}{
+		{name: "all", expected: 1065},
+		{name: "level 0", opts: []func(*CopcReader){WithCopcMaxLevel(0)}, expected: 134},
+		{name: "level 1", opts: []func(*CopcReader){WithCopcMaxLevel(1)}, expected: 533},
+		{name: "bbox all", opts: []func(*CopcReader){WithCopcBoundingBox(allZ(0, 1e7, 0, 1e7))}, expected: 1065},
+		{name: "bbox outside", opts: []func(*CopcReader){WithCopcBoundingBox(allZ(0, 1, 0, 1))}, expected: 0},
+		{name: "bbox corner", opts: []func(*CopcReader){WithCopcBoundingBox(allZ(634990, 635000, 848900, 848910))}, expected: 247},
+		{name: "bbox corner level 0", opts: []func(*CopcReader){
+			WithCopcBoundingBox(allZ(634990, 635000, 848900, 848910)),
+			WithCopcMaxLevel(0),
+		}, expected: 134},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewCopcReader(copcTestFile, "EPSG:32633", false, nil, nil, tc.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer r.Close()
+			if actual := r.NumberOfPoints(); actual != tc.expected {
+				t.Errorf("expected %d points got %d", tc.expected, actual)
+			}
+			if actual := r.GetCRS(); actual != "EPSG:32633" {
+				t.Errorf("expected crs %s got %s", "EPSG:32633", actual)
+			}
+			for i := 0; i < r.NumberOfPoints(); i++ {
+				if _, err := r.GetNext(); err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+			}
+			if _, err := r.GetNext(); err != io.EOF {
+				t.Errorf("expected EOF got %v", err)
+			}
+		})
+	}
+}
+
+func TestCopcReaderNotCopc(t *testing.T) {
+	if _, err := NewCopcReader("./testdata/las-12-pf1.las", "EPSG:32633", false, nil, nil); err == nil {
+		t.Errorf("expected error, got none")
+	}
+}
+
+func TestCopcReaderConcurrency(t *testing.T) {
+	r, err := NewCopcReader(copcTestFile, "EPSG:32633", false, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	var read sync.Map
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				pt, err := r.GetNext()
+				if err == io.EOF {
+					return
+				}
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+					return
+				}
+				read.Store(pt.Vector, true)
+			}
+		}()
+	}
+	wg.Wait()
+	n := 0
+	read.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	// the test cloud has a few duplicated points
+	if n < 1000 {
+		t.Errorf("expected at least 1000 distinct points got %d", n)
+	}
+}
+
+func TestCombinedReaderCopc(t *testing.T) {
+	files := []string{"./testdata/las-12-pf1.las", copcTestFile}
+	r, err := NewCombinedFileLasReader(files, SameCRS("EPSG:32633"), false, nil, nil, WithCopcMaxLevel(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	if actual := r.NumberOfPoints(); actual != 10+134 {
+		t.Errorf("expected %d points got %d", 10+134, actual)
+	}
+	for i := 0; i < r.NumberOfPoints(); i++ {
+		if _, err := r.GetNext(); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if _, err := r.GetNext(); err == nil {
+		t.Errorf("expected error, got none")
+	}
+}
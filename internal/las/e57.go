@@ -0,0 +1,135 @@
+package las
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las/e57"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// E57Reader reads all the 3D scans of an E57 file as a single stream of points, implementing the LasReader interface.
+// The pose of each scan is applied so that all points are expressed in the file coordinate system. Colors are
+// rescaled to 8 bits according to the limits declared by each scan, while intensities are stored as raw values,
+// see rawIntensity. Points flagged as invalid are skipped.
+type E57Reader struct {
+	file    *os.File
+	scans   []*e57.Scan
+	current int
+	reader  *e57.ScanReader
+	crs     string
+	numPts  int
+	sync.Mutex
+}
+
+// NewE57Reader returns an E57Reader for the given file. If crs is the empty string the coordinate metadata stored
+// in the file is used, falling back to a sidecar file, see SidecarCRS, and returning an error if both are missing.
+func NewE57Reader(fileName string, crs string) (*E57Reader, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newE57Reader(f, crs, SidecarCRS(fileName))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to read E57 file %s: %w", fileName, err)
+	}
+	return r, nil
+}
+
+func newE57Reader(f *os.File, crs string, sidecarCRS string) (*E57Reader, error) {
+	e, err := e57.NewE57(f)
+	if err != nil {
+		return nil, err
+	}
+	if crs == "" {
+		crs = e.CoordinateMetadata
+	}
+	if crs == "" {
+		crs = sidecarCRS
+	}
+	if crs == "" {
+		return nil, fmt.Errorf("no CRS provided and no coordinate metadata nor sidecar .prj or .wkt file found")
+	}
+	r := &E57Reader{
+		file: f,
+		crs:  crs,
+	}
+	for _, s := range e.Scans {
+		n, err := s.CountValid()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			continue
+		}
+		r.numPts += int(n)
+		r.scans = append(r.scans, s)
+	}
+	return r, nil
+}
+
+func (r *E57Reader) NumberOfPoints() int {
+	return r.numPts
+}
+
+func (r *E57Reader) GetCRS() string {
+	return r.crs
+}
+
+// Attributes returns nil as E57 files do not carry additional attributes
+func (r *E57Reader) Attributes() []geom.Attribute {
+	return nil
+}
+
+func (r *E57Reader) Close() {
+	r.file.Close()
+}
+
+func (r *E57Reader) GetNext() (geom.Point64, error) {
+	r.Lock()
+	defer r.Unlock()
+	for r.current < len(r.scans) {
+		scan := r.scans[r.current]
+		if r.reader == nil {
+			sr, err := scan.NewReader()
+			if err != nil {
+				return geom.Point64{}, err
+			}
+			r.reader = sr
+		}
+		pt, err := r.reader.Next()
+		if err == io.EOF {
+			r.current++
+			r.reader = nil
+			continue
+		}
+		if err != nil {
+			return geom.Point64{}, err
+		}
+		if pt.Invalid {
+			continue
+		}
+		return geom.Point64{
+			Vector: model.Vector{
+				X: pt.X,
+				Y: pt.Y,
+				Z: pt.Z,
+			},
+			R:         to8Bit(pt.Red),
+			G:         to8Bit(pt.Green),
+			B:         to8Bit(pt.Blue),
+			Intensity: rawIntensity(pt.Intensity),
+		}, nil
+	}
+	return geom.Point64{}, io.EOF
+}
+
+// to8Bit maps a value in the [0, 1] range to the [0, 255] range
+func to8Bit(v float64) uint8 {
+	return uint8(clamp(math.Round(v*255), 255))
+}
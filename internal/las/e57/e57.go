@@ -0,0 +1,198 @@
+package e57
+
+import (
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	signature        = "ASTM-E57"
+	fileHeaderLength = 48
+	checksumLength   = 4
+)
+
+// FileHeader models the E57 file header
+type FileHeader struct {
+	Signature          string
+	VersionMajor       uint32
+	VersionMinor       uint32
+	FilePhysicalLength uint64
+	XMLPhysicalOffset  uint64
+	XMLLogicalLength   uint64
+	PageSize           uint64
+}
+
+// E57 allows to read the 3D scans stored in an E57 file. Images and other metadata are ignored.
+type E57 struct {
+	Header FileHeader
+	// CoordinateMetadata stores the definition of the coordinate reference system of the file, if any
+	CoordinateMetadata string
+	Scans              []*Scan
+	r                  io.ReaderAt
+}
+
+// NewE57 parses the header and the XML section of the E57 file read from the given source
+func NewE57(r io.ReaderAt) (*E57, error) {
+	e := &E57{r: r}
+	if err := e.readHeader(); err != nil {
+		return nil, err
+	}
+	data := make([]byte, e.Header.XMLLogicalLength)
+	if err := e.readLogical(e.Header.XMLPhysicalOffset, data); err != nil {
+		return nil, fmt.Errorf("unable to read the e57 xml section: %w", err)
+	}
+	root := &node{}
+	if err := xml.Unmarshal(data, root); err != nil {
+		return nil, fmt.Errorf("unable to parse the e57 xml section: %w", err)
+	}
+	e.CoordinateMetadata = strings.TrimSpace(root.child("coordinateMetadata").Text)
+	if data3D := root.child("data3D"); data3D != nil {
+		for i := range data3D.Nodes {
+			s, err := newScan(e, &data3D.Nodes[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid e57 scan %d: %w", i, err)
+			}
+			e.Scans = append(e.Scans, s)
+		}
+	}
+	return e, nil
+}
+
+func (e *E57) readHeader() error {
+	var raw [fileHeaderLength]byte
+	if _, err := e.r.ReadAt(raw[:], 0); err != nil {
+		return fmt.Errorf("unable to read the e57 header: %w", err)
+	}
+	h := FileHeader{
+		Signature:          string(raw[0:8]),
+		VersionMajor:       binary.LittleEndian.Uint32(raw[8:]),
+		VersionMinor:       binary.LittleEndian.Uint32(raw[12:]),
+		FilePhysicalLength: binary.LittleEndian.Uint64(raw[16:]),
+		XMLPhysicalOffset:  binary.LittleEndian.Uint64(raw[24:]),
+		XMLLogicalLength:   binary.LittleEndian.Uint64(raw[32:]),
+		PageSize:           binary.LittleEndian.Uint64(raw[40:]),
+	}
+	if h.Signature != signature {
+		return errors.New("unexpected file signature")
+	}
+	if h.VersionMajor != 1 {
+		return fmt.Errorf("unsupported e57 version %d.%d", h.VersionMajor, h.VersionMinor)
+	}
+	if h.PageSize <= checksumLength {
+		return fmt.Errorf("invalid e57 page size %d", h.PageSize)
+	}
+	e.Header = h
+	return nil
+}
+
+// readLogical fills the given slice with the logical data starting at the given physical offset.
+// E57 files are split in pages, each one terminated by a checksum which is skipped.
+func (e *E57) readLogical(physicalOffset uint64, data []byte) error {
+	pageSize := e.Header.PageSize
+	for len(data) > 0 {
+		inPage := physicalOffset % pageSize
+		if inPage >= pageSize-checksumLength {
+			// skip the checksum
+			physicalOffset += pageSize - inPage
+			continue
+		}
+		n := min(uint64(len(data)), pageSize-checksumLength-inPage)
+		if _, err := e.r.ReadAt(data[:n], int64(physicalOffset)); err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		data = data[n:]
+		physicalOffset += n
+	}
+	return nil
+}
+
+// physicalOffset returns the physical offset of the data found the given number of logical bytes after the
+// given physical offset
+func (e *E57) physicalOffset(physicalOffset uint64, logicalBytes uint64) uint64 {
+	logicalPageSize := e.Header.PageSize - checksumLength
+	page := physicalOffset / e.Header.PageSize
+	logical := page*logicalPageSize + min(physicalOffset%e.Header.PageSize, logicalPageSize) + logicalBytes
+	return logical/logicalPageSize*e.Header.PageSize + logical%logicalPageSize
+}
+
+// node is a generic element of the E57 XML tree
+type node struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []node     `xml:",any"`
+	Text    string     `xml:",chardata"`
+}
+
+// child returns the first child node with the given name, or nil if not found
+func (n *node) child(name string) *node {
+	if n == nil {
+		return nil
+	}
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == name {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// attr returns the value of the attribute with the given name, or the empty string if not found
+func (n *node) attr(name string) string {
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// float returns the numeric value of the node, or the default value if the node is missing or empty
+func (n *node) float(def float64) (float64, error) {
+	if n == nil || strings.TrimSpace(n.Text) == "" {
+		return def, nil
+	}
+	return strconv.ParseFloat(strings.TrimSpace(n.Text), 64)
+}
+
+// floatAttr returns the numeric value of the given attribute, or the default value if missing
+func (n *node) floatAttr(name string, def float64) (float64, error) {
+	v := n.attr(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// intAttr returns the integer value of the given attribute, or the default value if missing
+func (n *node) intAttr(name string, def int64) (int64, error) {
+	v := n.attr(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// limits returns the min and max values stored in the given limits structure, e.g. colorLimits
+func (n *node) limits(minName, maxName string) (float64, float64, bool) {
+	if n == nil {
+		return 0, 0, false
+	}
+	min, err1 := n.child(minName).float(math.NaN())
+	max, err2 := n.child(maxName).float(math.NaN())
+	if err1 != nil || err2 != nil || math.IsNaN(min) || math.IsNaN(max) {
+		return 0, 0, false
+	}
+	return min, max, true
+}
@@ -0,0 +1,321 @@
+package e57
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+// testField is a prototype field of a test scan with its raw bit packed values
+type testField struct {
+	xml  string
+	bits uint
+	raw  []uint64
+}
+
+// testScan describes a scan to be written in a test E57 file
+type testScan struct {
+	xml    string
+	fields []testField
+	// chunk is the number of bytes of each bytestream written in each data packet
+	chunk int
+}
+
+func floatField(name string, values ...float64) testField {
+	f := testField{xml: fmt.Sprintf(`<%s type="Float"/>`, name), bits: 64}
+	for _, v := range values {
+		f.raw = append(f.raw, math.Float64bits(v))
+	}
+	return f
+}
+
+func singleField(name string, values ...float32) testField {
+	f := testField{xml: fmt.Sprintf(`<%s type="Float" precision="single"/>`, name), bits: 32}
+	for _, v := range values {
+		f.raw = append(f.raw, uint64(math.Float32bits(v)))
+	}
+	return f
+}
+
+func integerField(name string, min, max int64, bits uint, values ...int64) testField {
+	f := testField{xml: fmt.Sprintf(`<%s type="Integer" minimum="%d" maximum="%d"/>`, name, min, max), bits: bits}
+	for _, v := range values {
+		f.raw = append(f.raw, uint64(v-min))
+	}
+	return f
+}
+
+// pack bit packs the values starting from the least significant bit
+func pack(values []uint64, bits uint) []byte {
+	out := make([]byte, (uint(len(values))*bits+7)/8)
+	pos := uint(0)
+	for _, v := range values {
+		for i := uint(0); i < bits; i++ {
+			if v&(1<<i) != 0 {
+				out[pos/8] |= 1 << (pos % 8)
+			}
+			pos++
+		}
+	}
+	return out
+}
+
+// buildE57 returns the content of an E57 file storing the given scans
+func buildE57(scans []testScan, coordinateMetadata string) []byte {
+	const logicalPageSize = 1020
+	physical := func(logical int) int {
+		return logical/logicalPageSize*1024 + logical%logicalPageSize
+	}
+	data := make([]byte, fileHeaderLength)
+	var scansXML []string
+	for _, s := range scans {
+		start := len(data)
+		data = append(data, make([]byte, compressedVectorHeaderLength)...)
+		// an empty packet to be skipped
+		data = append(data, emptyPacketType, 0, 3, 0)
+		streams := make([][]byte, len(s.fields))
+		for i, f := range s.fields {
+			streams[i] = pack(f.raw, f.bits)
+		}
+		for {
+			done := true
+			packet := []byte{dataPacketType, 0, 0, 0}
+			packet = binary.LittleEndian.AppendUint16(packet, uint16(len(streams)))
+			var body []byte
+			for i := range streams {
+				n := min(s.chunk, len(streams[i]))
+				packet = binary.LittleEndian.AppendUint16(packet, uint16(n))
+				body = append(body, streams[i][:n]...)
+				streams[i] = streams[i][n:]
+				if len(streams[i]) > 0 {
+					done = false
+				}
+			}
+			packet = append(packet, body...)
+			for len(packet)%4 != 0 {
+				packet = append(packet, 0)
+			}
+			binary.LittleEndian.PutUint16(packet[2:], uint16(len(packet)-1))
+			data = append(data, packet...)
+			if done {
+				break
+			}
+		}
+		header := data[start:]
+		header[0] = compressedVectorSectionID
+		binary.LittleEndian.PutUint64(header[8:], uint64(len(data)-start))
+		binary.LittleEndian.PutUint64(header[16:], uint64(physical(start+compressedVectorHeaderLength)))
+		var prototype string
+		for _, f := range s.fields {
+			prototype += f.xml
+		}
+		scansXML = append(scansXML, fmt.Sprintf(
+			`<vectorChild type="Structure">%s<points type="CompressedVector" fileOffset="%d" recordCount="%d"><prototype type="Structure">%s</prototype><codecs type="Vector"/></points></vectorChild>`,
+			s.xml, physical(start), len(s.fields[0].raw), prototype,
+		))
+	}
+	xmlOffset := len(data)
+	xml := fmt.Sprintf(
+		`<?xml version="1.0" encoding="UTF-8"?><e57Root type="Structure" xmlns="http://www.astm.org/COMMIT/E57/2010-e57-v1.0"><formatName type="String">ASTM E57 3D Imaging Data File</formatName><coordinateMetadata type="String">%s</coordinateMetadata><data3D type="Vector" allowHeterogeneousChildren="1">%s</data3D><images2D type="Vector" allowHeterogeneousChildren="1"/></e57Root>`,
+		coordinateMetadata, strings.Join(scansXML, ""),
+	)
+	data = append(data, xml...)
+	pages := (len(data) + logicalPageSize - 1) / logicalPageSize
+	copy(data[0:], signature)
+	binary.LittleEndian.PutUint32(data[8:], 1)
+	binary.LittleEndian.PutUint64(data[16:], uint64(pages*1024))
+	binary.LittleEndian.PutUint64(data[24:], uint64(physical(xmlOffset)))
+	binary.LittleEndian.PutUint64(data[32:], uint64(len(xml)))
+	binary.LittleEndian.PutUint64(data[40:], 1024)
+	data = append(data, make([]byte, pages*logicalPageSize-len(data))...)
+	var out []byte
+	table := crc32.MakeTable(crc32.Castagnoli)
+	for p := 0; p < pages; p++ {
+		page := data[p*logicalPageSize : (p+1)*logicalPageSize]
+		out = append(out, page...)
+		out = binary.BigEndian.AppendUint32(out, crc32.Checksum(page, table))
+	}
+	return out
+}
+
+// testScans returns a cartesian scan with a pose, intensity, colors and an invalid point
+// and a spherical scan with no pose
+func testScans() []testScan {
+	const n = 300
+	xs, ys, zs := make([]float64, n), make([]float64, n), make([]float64, n)
+	intensity, red, invalid := make([]int64, n), make([]int64, n), make([]int64, n)
+	for i := 0; i < n; i++ {
+		xs[i], ys[i], zs[i] = float64(i), float64(2*i), float64(3*i)
+		intensity[i] = int64(i * 10)
+		red[i] = int64(i % 256)
+	}
+	invalid[3] = 2
+	return []testScan{
+		{
+			xml: `<name type="String">cartesian</name><guid type="String">{A}</guid>` +
+				`<pose type="Structure"><rotation type="Structure"><w type="Float">0.7071067811865476</w><x type="Float">0</x><y type="Float">0</y><z type="Float">0.7071067811865476</z></rotation>` +
+				`<translation type="Structure"><x type="Float">100</x><y type="Float">200</y><z type="Float">10</z></translation></pose>` +
+				`<colorLimits type="Structure"><colorRedMinimum type="Integer">0</colorRedMinimum><colorRedMaximum type="Integer">255</colorRedMaximum>` +
+				`<colorGreenMinimum type="Integer">0</colorGreenMinimum><colorGreenMaximum type="Integer">255</colorGreenMaximum>` +
+				`<colorBlueMinimum type="Integer">0</colorBlueMinimum><colorBlueMaximum type="Integer">255</colorBlueMaximum></colorLimits>`,
+			fields: []testField{
+				floatField("cartesianX", xs...),
+				floatField("cartesianY", ys...),
+				floatField("cartesianZ", zs...),
+				integerField("intensity", 0, 4095, 12, intensity...),
+				integerField("colorRed", 0, 255, 8, red...),
+				integerField("colorGreen", 0, 255, 8, make([]int64, n)...),
+				integerField("colorBlue", 0, 255, 8, red...),
+				integerField("cartesianInvalidState", 0, 2, 2, invalid...),
+			},
+			chunk: 37,
+		},
+		{
+			xml: `<name type="String">spherical</name><guid type="String">{B}</guid>` +
+				`<intensityLimits type="Structure"><intensityMinimum type="Float">0</intensityMinimum><intensityMaximum type="Float">1</intensityMaximum></intensityLimits>`,
+			fields: []testField{
+				{xml: `<sphericalRange type="ScaledInteger" minimum="0" maximum="100000" scale="0.001"/>`, bits: 17, raw: []uint64{1000, 2000, 99999}},
+				singleField("sphericalAzimuth", 0, math.Pi/2, 0),
+				singleField("sphericalElevation", 0, 0, math.Pi/2),
+				singleField("intensity", 0, 0.5, 1),
+			},
+			chunk: 5,
+		},
+	}
+}
+
+func openTestFile(t *testing.T) *E57 {
+	e, err := NewE57(bytes.NewReader(buildE57(testScans(), "EPSG:32633")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return e
+}
+
+func TestNewE57(t *testing.T) {
+	e := openTestFile(t)
+	if e.CoordinateMetadata != "EPSG:32633" {
+		t.Errorf("expected coordinate metadata EPSG:32633 got %s", e.CoordinateMetadata)
+	}
+	if len(e.Scans) != 2 {
+		t.Fatalf("expected 2 scans got %d", len(e.Scans))
+	}
+	s := e.Scans[0]
+	if s.Name != "cartesian" || s.GUID != "{A}" || s.RecordCount != 300 {
+		t.Errorf("unexpected scan %s %s %d", s.Name, s.GUID, s.RecordCount)
+	}
+	if !s.HasIntensity() || !s.HasColor() {
+		t.Errorf("expected intensity and color")
+	}
+	if s.Pose.Translation != [3]float64{100, 200, 10} {
+		t.Errorf("unexpected translation %v", s.Pose.Translation)
+	}
+	s = e.Scans[1]
+	if s.Name != "spherical" || s.RecordCount != 3 || s.Pose != IdentityPose {
+		t.Errorf("unexpected scan %s %d %v", s.Name, s.RecordCount, s.Pose)
+	}
+	if !s.HasIntensity() || s.HasColor() {
+		t.Errorf("expected intensity and no color")
+	}
+}
+
+func TestNewE57InvalidSignature(t *testing.T) {
+	data := buildE57(testScans(), "")
+	copy(data, "NOT-E57!")
+	if _, err := NewE57(bytes.NewReader(data)); err == nil {
+		t.Errorf("expected error got nil")
+	}
+}
+
+func TestScanReaderCartesian(t *testing.T) {
+	e := openTestFile(t)
+	r, err := e.Scans[0].NewReader()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 300; i++ {
+		p, err := r.Next()
+		if err != nil {
+			t.Fatalf("unexpected error at point %d: %v", i, err)
+		}
+		// 90 degrees rotation around z, then translation
+		expected := Point{
+			X:         100 - float64(2*i),
+			Y:         200 + float64(i),
+			Z:         10 + float64(3*i),
+			Intensity: float64(i * 10),
+			Red:       float64(i%256) / 255,
+			Blue:      float64(i%256) / 255,
+			Invalid:   i == 3,
+		}
+		if !pointsEqual(p, expected) {
+			t.Errorf("point %d: expected %v got %v", i, expected, p)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected EOF got %v", err)
+	}
+}
+
+func TestScanReaderSpherical(t *testing.T) {
+	e := openTestFile(t)
+	r, err := e.Scans[1].NewReader()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Point{
+		{X: 1, Y: 0, Z: 0, Intensity: 0},
+		{X: 0, Y: 2, Z: 0, Intensity: 0.5},
+		{X: 0, Y: 0, Z: 99.999, Intensity: 1},
+	}
+	for i, e := range expected {
+		p, err := r.Next()
+		if err != nil {
+			t.Fatalf("unexpected error at point %d: %v", i, err)
+		}
+		if !pointsEqual(p, e) {
+			t.Errorf("point %d: expected %v got %v", i, e, p)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected EOF got %v", err)
+	}
+}
+
+func TestCountValid(t *testing.T) {
+	e := openTestFile(t)
+	for i, expected := range []int64{299, 3} {
+		n, err := e.Scans[i].CountValid()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != expected {
+			t.Errorf("scan %d: expected %d valid points got %d", i, expected, n)
+		}
+	}
+}
+
+func TestPoseTransform(t *testing.T) {
+	p := Pose{
+		// 180 degrees around x, not normalized
+		Rotation:    [4]float64{0, 2, 0, 0},
+		Translation: [3]float64{1, 1, 1},
+	}
+	x, y, z := p.Transform(1, 2, 3)
+	if math.Abs(x-2) > 1e-9 || math.Abs(y+1) > 1e-9 || math.Abs(z+2) > 1e-9 {
+		t.Errorf("unexpected transformed coordinates %f %f %f", x, y, z)
+	}
+}
+
+func pointsEqual(a, b Point) bool {
+	eq := func(x, y float64) bool {
+		return math.Abs(x-y) < 1e-4
+	}
+	return eq(a.X, b.X) && eq(a.Y, b.Y) && eq(a.Z, b.Z) && eq(a.Intensity, b.Intensity) &&
+		eq(a.Red, b.Red) && eq(a.Green, b.Green) && eq(a.Blue, b.Blue) && a.Invalid == b.Invalid
+}
@@ -0,0 +1,444 @@
+package e57
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+const (
+	compressedVectorSectionID     = 1
+	compressedVectorHeaderLength  = 32
+	indexPacketType               = 0
+	dataPacketType                = 1
+	emptyPacketType               = 2
+	dataPacketHeaderLength        = 6
+	genericPacketHeaderLength     = 4
+	maxPacketLength               = 64 * 1024
+	invalidStateValid             = 0
+	singleFloatPrecision          = "single"
+	scaledIntegerType             = "ScaledInteger"
+	integerType                   = "Integer"
+	floatType                     = "Float"
+	cartesianInvalidStateField    = "cartesianInvalidState"
+	sphericalInvalidStateField    = "sphericalInvalidState"
+	cartesianXField               = "cartesianX"
+	cartesianYField               = "cartesianY"
+	cartesianZField               = "cartesianZ"
+	sphericalRangeField           = "sphericalRange"
+	sphericalAzimuthField         = "sphericalAzimuth"
+	sphericalElevationField       = "sphericalElevation"
+	intensityField                = "intensity"
+	colorRedField                 = "colorRed"
+	colorGreenField               = "colorGreen"
+	colorBlueField                = "colorBlue"
+	compressedVectorType          = "CompressedVector"
+	compressedVectorOffsetAttr    = "fileOffset"
+	compressedVectorRecordsAttr   = "recordCount"
+	compressedVectorPrototypeNode = "prototype"
+)
+
+// Pose is the rigid transformation bringing the points of a scan from the scan local coordinate system
+// to the file coordinate system
+type Pose struct {
+	// Rotation is the unit quaternion expressed as w, x, y, z
+	Rotation [4]float64
+	// Translation is the x, y, z translation
+	Translation [3]float64
+}
+
+// IdentityPose is the pose that leaves the coordinates unchanged
+var IdentityPose = Pose{Rotation: [4]float64{1, 0, 0, 0}}
+
+// Transform applies the pose to the given coordinates
+func (p Pose) Transform(x, y, z float64) (float64, float64, float64) {
+	w, qx, qy, qz := p.Rotation[0], p.Rotation[1], p.Rotation[2], p.Rotation[3]
+	if n := math.Sqrt(w*w + qx*qx + qy*qy + qz*qz); n > 0 {
+		w, qx, qy, qz = w/n, qx/n, qy/n, qz/n
+	}
+	rx := (1-2*(qy*qy+qz*qz))*x + 2*(qx*qy-qz*w)*y + 2*(qx*qz+qy*w)*z
+	ry := 2*(qx*qy+qz*w)*x + (1-2*(qx*qx+qz*qz))*y + 2*(qy*qz-qx*w)*z
+	rz := 2*(qx*qz-qy*w)*x + 2*(qy*qz+qx*w)*y + (1-2*(qx*qx+qy*qy))*z
+	return rx + p.Translation[0], ry + p.Translation[1], rz + p.Translation[2]
+}
+
+// Point is a point of an E57 scan, with coordinates expressed in the file coordinate system.
+// Intensity is the raw value stored in the scan, while colors are normalized to the [0, 1] range using the limits
+// declared by the scan.
+type Point struct {
+	X         float64
+	Y         float64
+	Z         float64
+	Intensity float64
+	Red       float64
+	Green     float64
+	Blue      float64
+	// Invalid is true if the scanner flagged the coordinates of the point as not meaningful
+	Invalid bool
+}
+
+// field describes how a value of the scan records prototype is encoded in the binary section
+type field struct {
+	name string
+	// bits is the number of bits used to store each value
+	bits uint
+	// float is true for Float fields, whose values are stored as IEEE 754 numbers
+	float bool
+	// min is the minimum raw value of Integer and ScaledInteger fields
+	min    int64
+	scale  float64
+	offset float64
+	// lower and upper are the minimum and maximum values the field can take
+	lower float64
+	upper float64
+}
+
+func newField(n *node) (field, error) {
+	f := field{name: n.XMLName.Local, scale: 1}
+	var err error
+	switch n.attr("type") {
+	case floatType:
+		f.float = true
+		f.bits = 64
+		if n.attr("precision") == singleFloatPrecision {
+			f.bits = 32
+		}
+		if f.lower, err = n.floatAttr("minimum", -math.MaxFloat64); err != nil {
+			return f, err
+		}
+		if f.upper, err = n.floatAttr("maximum", math.MaxFloat64); err != nil {
+			return f, err
+		}
+		return f, nil
+	case scaledIntegerType:
+		if f.scale, err = n.floatAttr("scale", 1); err != nil {
+			return f, err
+		}
+		if f.offset, err = n.floatAttr("offset", 0); err != nil {
+			return f, err
+		}
+	case integerType:
+	default:
+		return f, fmt.Errorf("unsupported type %q for field %s", n.attr("type"), f.name)
+	}
+	min, err := n.intAttr("minimum", math.MinInt64)
+	if err != nil {
+		return f, err
+	}
+	max, err := n.intAttr("maximum", math.MaxInt64)
+	if err != nil {
+		return f, err
+	}
+	if max < min {
+		return f, fmt.Errorf("invalid range [%d, %d] for field %s", min, max, f.name)
+	}
+	f.min = min
+	f.bits = uint(bits.Len64(uint64(max) - uint64(min)))
+	f.lower = float64(min)*f.scale + f.offset
+	f.upper = float64(max)*f.scale + f.offset
+	return f, nil
+}
+
+// decode converts the raw bits read from the binary section into the field value
+func (f field) decode(raw uint64) float64 {
+	switch {
+	case f.float && f.bits == 32:
+		return float64(math.Float32frombits(uint32(raw)))
+	case f.float:
+		return math.Float64frombits(raw)
+	default:
+		return float64(f.min+int64(raw))*f.scale + f.offset
+	}
+}
+
+// Scan is a 3D scan (data3D entry) of an E57 file
+type Scan struct {
+	Name        string
+	GUID        string
+	Pose        Pose
+	RecordCount int64
+	file        *E57
+	offset      uint64
+	fields      []field
+	// indexes of the fields storing each attribute, -1 if absent
+	x, y, z, invalid, intensity, red, green, blue int
+	spherical                                     bool
+	// limits used to normalize the colors
+	colorLimits [3][2]float64
+}
+
+func newScan(e *E57, n *node) (*Scan, error) {
+	s := &Scan{
+		Name: n.child("name").Text,
+		GUID: n.child("guid").Text,
+		Pose: IdentityPose,
+		file: e,
+	}
+	if pose := n.child("pose"); pose != nil {
+		var err error
+		if rot := pose.child("rotation"); rot != nil {
+			for i, c := range []string{"w", "x", "y", "z"} {
+				if s.Pose.Rotation[i], err = rot.child(c).float(0); err != nil {
+					return nil, fmt.Errorf("invalid pose rotation: %w", err)
+				}
+			}
+		}
+		if t := pose.child("translation"); t != nil {
+			for i, c := range []string{"x", "y", "z"} {
+				if s.Pose.Translation[i], err = t.child(c).float(0); err != nil {
+					return nil, fmt.Errorf("invalid pose translation: %w", err)
+				}
+			}
+		}
+	}
+	points := n.child("points")
+	if points == nil || points.attr("type") != compressedVectorType {
+		return nil, errors.New("points compressed vector not found")
+	}
+	var err error
+	if s.RecordCount, err = points.intAttr(compressedVectorRecordsAttr, 0); err != nil {
+		return nil, fmt.Errorf("invalid record count: %w", err)
+	}
+	offset, err := points.intAttr(compressedVectorOffsetAttr, -1)
+	if err != nil || offset < 0 {
+		return nil, errors.New("invalid points file offset")
+	}
+	s.offset = uint64(offset)
+	prototype := points.child(compressedVectorPrototypeNode)
+	if prototype == nil {
+		return nil, errors.New("points prototype not found")
+	}
+	for i := range prototype.Nodes {
+		f, err := newField(&prototype.Nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		s.fields = append(s.fields, f)
+	}
+	index := func(name string) int {
+		for i, f := range s.fields {
+			if f.name == name {
+				return i
+			}
+		}
+		return -1
+	}
+	s.x, s.y, s.z = index(cartesianXField), index(cartesianYField), index(cartesianZField)
+	s.invalid = index(cartesianInvalidStateField)
+	if s.x == -1 || s.y == -1 || s.z == -1 {
+		s.spherical = true
+		s.x, s.y, s.z = index(sphericalRangeField), index(sphericalAzimuthField), index(sphericalElevationField)
+		s.invalid = index(sphericalInvalidStateField)
+		if s.x == -1 || s.y == -1 || s.z == -1 {
+			return nil, errors.New("neither cartesian nor spherical coordinates found")
+		}
+	}
+	s.intensity = index(intensityField)
+	s.red, s.green, s.blue = index(colorRedField), index(colorGreenField), index(colorBlueField)
+	for i, c := range []struct {
+		idx  int
+		name string
+	}{{s.red, "Red"}, {s.green, "Green"}, {s.blue, "Blue"}} {
+		if c.idx == -1 {
+			continue
+		}
+		f := s.fields[c.idx]
+		s.colorLimits[i] = [2]float64{f.lower, f.upper}
+		if min, max, ok := n.child("colorLimits").limits("color"+c.name+"Minimum", "color"+c.name+"Maximum"); ok {
+			s.colorLimits[i] = [2]float64{min, max}
+		}
+	}
+	return s, nil
+}
+
+// HasIntensity returns true if the scan records store the intensity
+func (s *Scan) HasIntensity() bool {
+	return s.intensity != -1
+}
+
+// HasColor returns true if the scan records store the color
+func (s *Scan) HasColor() bool {
+	return s.red != -1 && s.green != -1 && s.blue != -1
+}
+
+// CountValid returns the number of points of the scan not flagged as invalid. If the scan does not store
+// the invalid state of the points this is the record count, otherwise all the records are decoded.
+func (s *Scan) CountValid() (int64, error) {
+	if s.invalid == -1 {
+		return s.RecordCount, nil
+	}
+	r, err := s.NewReader()
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	for {
+		p, err := r.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !p.Invalid {
+			count++
+		}
+	}
+}
+
+// NewReader returns a reader of the points of the scan
+func (s *Scan) NewReader() (*ScanReader, error) {
+	var raw [compressedVectorHeaderLength]byte
+	if err := s.file.readLogical(s.offset, raw[:]); err != nil {
+		return nil, fmt.Errorf("unable to read the compressed vector header of scan %s: %w", s.Name, err)
+	}
+	if raw[0] != compressedVectorSectionID {
+		return nil, fmt.Errorf("unexpected section id %d for the compressed vector of scan %s", raw[0], s.Name)
+	}
+	return &ScanReader{
+		scan:    s,
+		pos:     binary.LittleEndian.Uint64(raw[16:]),
+		streams: make([]bytestream, len(s.fields)),
+		values:  make([]float64, len(s.fields)),
+	}, nil
+}
+
+// bytestream buffers the bit packed values of a field read from the data packets
+type bytestream struct {
+	buf    []byte
+	bitPos uint
+}
+
+func (b *bytestream) available() uint {
+	return uint(len(b.buf))*8 - b.bitPos
+}
+
+// read returns the next value of n bits. Values are packed starting from the least significant bit.
+func (b *bytestream) read(n uint) uint64 {
+	var v uint64
+	for done := uint(0); done < n; {
+		byteIdx, bitIdx := b.bitPos/8, b.bitPos%8
+		take := min(8-bitIdx, n-done)
+		chunk := (uint64(b.buf[byteIdx]) >> bitIdx) & (1<<take - 1)
+		v |= chunk << done
+		done += take
+		b.bitPos += take
+	}
+	// drop the consumed bytes
+	if consumed := b.bitPos / 8; consumed > 0 {
+		b.buf = b.buf[consumed:]
+		b.bitPos -= consumed * 8
+	}
+	return v
+}
+
+// ScanReader sequentially decodes the records of a scan. It is not safe for concurrent use.
+type ScanReader struct {
+	scan    *Scan
+	pos     uint64
+	read    int64
+	streams []bytestream
+	values  []float64
+	packet  []byte
+}
+
+// Next returns the next point of the scan, or io.EOF if all points have been read
+func (r *ScanReader) Next() (Point, error) {
+	if r.read >= r.scan.RecordCount {
+		return Point{}, io.EOF
+	}
+	for i, f := range r.scan.fields {
+		for r.streams[i].available() < f.bits {
+			if err := r.nextPacket(); err != nil {
+				return Point{}, fmt.Errorf("unable to read record %d of scan %s: %w", r.read, r.scan.Name, err)
+			}
+		}
+		r.values[i] = f.decode(r.streams[i].read(f.bits))
+	}
+	r.read++
+	s := r.scan
+	x, y, z := r.values[s.x], r.values[s.y], r.values[s.z]
+	if s.spherical {
+		rng, az, el := x, y, z
+		x = rng * math.Cos(el) * math.Cos(az)
+		y = rng * math.Cos(el) * math.Sin(az)
+		z = rng * math.Sin(el)
+	}
+	p := Point{}
+	p.X, p.Y, p.Z = s.Pose.Transform(x, y, z)
+	if s.invalid != -1 {
+		p.Invalid = r.values[s.invalid] != invalidStateValid
+	}
+	if s.intensity != -1 {
+		p.Intensity = r.values[s.intensity]
+	}
+	if s.red != -1 {
+		p.Red = normalize(r.values[s.red], s.colorLimits[0])
+	}
+	if s.green != -1 {
+		p.Green = normalize(r.values[s.green], s.colorLimits[1])
+	}
+	if s.blue != -1 {
+		p.Blue = normalize(r.values[s.blue], s.colorLimits[2])
+	}
+	return p, nil
+}
+
+// nextPacket reads the next data packet, appending its content to the bytestreams.
+// Index and empty packets are skipped.
+func (r *ScanReader) nextPacket() error {
+	for {
+		var header [genericPacketHeaderLength]byte
+		if err := r.scan.file.readLogical(r.pos, header[:]); err != nil {
+			return err
+		}
+		length := uint64(binary.LittleEndian.Uint16(header[2:])) + 1
+		switch header[0] {
+		case indexPacketType, emptyPacketType:
+			r.pos = r.scan.file.physicalOffset(r.pos, length)
+			continue
+		case dataPacketType:
+		default:
+			return fmt.Errorf("unknown packet type %d", header[0])
+		}
+		if length > maxPacketLength || length < dataPacketHeaderLength {
+			return fmt.Errorf("invalid data packet length %d", length)
+		}
+		if cap(r.packet) < int(length) {
+			r.packet = make([]byte, length)
+		}
+		packet := r.packet[:length]
+		if err := r.scan.file.readLogical(r.pos, packet); err != nil {
+			return err
+		}
+		r.pos = r.scan.file.physicalOffset(r.pos, length)
+		count := int(binary.LittleEndian.Uint16(packet[4:]))
+		if count != len(r.streams) {
+			return fmt.Errorf("data packet has %d bytestreams, expected %d", count, len(r.streams))
+		}
+		start := dataPacketHeaderLength + 2*count
+		if start > len(packet) {
+			return errors.New("truncated data packet")
+		}
+		for i := range r.streams {
+			n := int(binary.LittleEndian.Uint16(packet[dataPacketHeaderLength+2*i:]))
+			if start+n > len(packet) {
+				return errors.New("truncated data packet")
+			}
+			r.streams[i].buf = append(r.streams[i].buf, packet[start:start+n]...)
+			start += n
+		}
+		return nil
+	}
+}
+
+// normalize maps v to the [0, 1] range according to the given limits
+func normalize(v float64, limits [2]float64) float64 {
+	if limits[1] <= limits[0] {
+		return 0
+	}
+	return math.Min(math.Max((v-limits[0])/(limits[1]-limits[0]), 0), 1)
+}
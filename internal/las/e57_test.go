@@ -0,0 +1,66 @@
+package las
+
+import (
+	"io"
+	"math"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+func TestE57Reader(t *testing.T) {
+	r, err := NewE57Reader("./e57/testdata/scans.e57", "")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+	if actual := r.GetCRS(); actual != "EPSG:32633" {
+		t.Errorf("expected crs EPSG:32633 got %s", actual)
+	}
+	// the cartesian scan has 300 points, one of them invalid, the spherical one has 3 points
+	if actual := r.NumberOfPoints(); actual != 302 {
+		t.Fatalf("expected 302 points got %d", actual)
+	}
+	expected := map[int]geom.Point64{
+		0: {Vector: model.Vector{X: 100, Y: 200, Z: 10}},
+		1: {Vector: model.Vector{X: 98, Y: 201, Z: 13}, R: 1, B: 1, Intensity: 10},
+		3: {Vector: model.Vector{X: 92, Y: 204, Z: 22}, R: 4, B: 4, Intensity: 40},
+		// raw intensities above 255 are clamped
+		30:  {Vector: model.Vector{X: 38, Y: 231, Z: 103}, R: 31, B: 31, Intensity: 255},
+		301: {Vector: model.Vector{X: 0, Y: 0, Z: 99.999}, Intensity: 1},
+	}
+	for i := 0; i < 302; i++ {
+		pt, err := r.GetNext()
+		if err != nil {
+			t.Fatalf("unexpected error at point %d: %v", i, err)
+		}
+		e, ok := expected[i]
+		if !ok {
+			continue
+		}
+		if math.Abs(pt.X-e.X) > 1e-4 || math.Abs(pt.Y-e.Y) > 1e-4 || math.Abs(pt.Z-e.Z) > 1e-4 {
+			t.Errorf("point %d: expected coordinates %v got %v", i, e.Vector, pt.Vector)
+		}
+		if pt.R != e.R || pt.G != e.G || pt.B != e.B || pt.Intensity != e.Intensity || pt.Classification != 0 {
+			t.Errorf("point %d: expected %v got %v", i, e, pt)
+		}
+	}
+	if _, err := r.GetNext(); err != io.EOF {
+		t.Errorf("expected EOF got %v", err)
+	}
+}
+
+func TestE57ReaderCRS(t *testing.T) {
+	r, err := newFileLasReader("./e57/testdata/scans.e57", "EPSG:4978", false, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+	if _, ok := r.(*E57Reader); !ok {
+		t.Errorf("expected E57Reader got %T", r)
+	}
+	if actual := r.GetCRS(); actual != "EPSG:4978" {
+		t.Errorf("expected crs EPSG:4978 got %s", actual)
+	}
+}
@@ -0,0 +1,170 @@
+package golas
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	copcUserID            = "copc"
+	copcInfoRecordID      = 1
+	copcHierarchyRecordID = 1000
+	copcInfoLength        = 160
+	copcEntryLength       = 32
+	evlrHeaderLength      = 60
+)
+
+// CopcInfo contains the metadata of a Cloud Optimized Point Cloud (COPC) file as stored in the COPC info VLR
+type CopcInfo struct {
+	// CenterX, CenterY and CenterZ are the coordinates of the center of the root octree node
+	CenterX float64
+	CenterY float64
+	CenterZ float64
+	// Halfsize is half the size of the side of the root octree node cube
+	Halfsize float64
+	// Spacing is the space between points at the root node
+	Spacing float64
+	// RootHierarchyOffset is the file offset of the first hierarchy page
+	RootHierarchyOffset uint64
+	// RootHierarchySize is the size of the first hierarchy page in bytes
+	RootHierarchySize uint64
+	GPSTimeMinimum    float64
+	GPSTimeMaximum    float64
+}
+
+// VoxelKey identifies an octree node by its level and its position within the level
+type VoxelKey struct {
+	Level int32
+	X     int32
+	Y     int32
+	Z     int32
+}
+
+// Bounds returns the min and max coordinates of the cube of the octree node identified by the key
+func (k VoxelKey) Bounds(info *CopcInfo) (min [3]float64, max [3]float64) {
+	size := 2 * info.Halfsize / math.Pow(2, float64(k.Level))
+	center := [3]float64{info.CenterX, info.CenterY, info.CenterZ}
+	for i, v := range [3]int32{k.X, k.Y, k.Z} {
+		min[i] = center[i] - info.Halfsize + float64(v)*size
+		max[i] = min[i] + size
+	}
+	return min, max
+}
+
+// CopcEntry describes an octree node of a COPC file and the location of its point data chunk
+type CopcEntry struct {
+	Key        VoxelKey
+	Offset     uint64
+	ByteSize   int32
+	PointCount int32
+}
+
+// CopcInfo returns the COPC metadata of the file, or nil if the file is not a COPC file
+func (g *Las) CopcInfo() *CopcInfo {
+	return g.copc
+}
+
+// extractCopcInfo parses the COPC info VLR, if present
+func (g *Las) extractCopcInfo() error {
+	for _, v := range g.VLRs {
+		if v.UserID != copcUserID || v.RecordID != copcInfoRecordID {
+			continue
+		}
+		if len(v.Data) < copcInfoLength {
+			return fmt.Errorf("invalid copc info VLR length %d", len(v.Data))
+		}
+		info := &CopcInfo{}
+		if err := binary.Read(bytes.NewReader(v.Data[:72]), binary.LittleEndian, info); err != nil {
+			return err
+		}
+		g.copc = info
+		return nil
+	}
+	return nil
+}
+
+// CopcHierarchy returns the entries of all the octree nodes of the COPC file, walking all the hierarchy pages.
+// Entries with no points are returned as well, while the entries pointing to child hierarchy pages are not.
+func (g *Las) CopcHierarchy() ([]CopcEntry, error) {
+	if g.copc == nil {
+		return nil, errors.New("the file is not a copc file")
+	}
+	// hierarchy page offsets are absolute file offsets, locate the hierarchy EVLR data within the file
+	var hierarchy []byte
+	var hierarchyOffset uint64
+	offset := g.Header.StartOfFirstEVLR
+	for _, v := range g.EVLRs {
+		if v.UserID == copcUserID && v.RecordID == copcHierarchyRecordID {
+			hierarchy = v.Data
+			hierarchyOffset = offset + evlrHeaderLength
+			break
+		}
+		offset += evlrHeaderLength + v.RecordLengthAfterHeader
+	}
+	if hierarchy == nil {
+		return nil, errors.New("copc hierarchy EVLR not found")
+	}
+	page := func(offset, size uint64) ([]byte, error) {
+		if offset < hierarchyOffset || offset+size > hierarchyOffset+uint64(len(hierarchy)) {
+			return nil, fmt.Errorf("copc hierarchy page at offset %d is outside the hierarchy EVLR", offset)
+		}
+		start := offset - hierarchyOffset
+		return hierarchy[start : start+size], nil
+	}
+	var entries []CopcEntry
+	pages := [][2]uint64{{g.copc.RootHierarchyOffset, g.copc.RootHierarchySize}}
+	for len(pages) > 0 {
+		data, err := page(pages[0][0], pages[0][1])
+		if err != nil {
+			return nil, err
+		}
+		pages = pages[1:]
+		if len(data)%copcEntryLength != 0 {
+			return nil, fmt.Errorf("invalid copc hierarchy page size %d", len(data))
+		}
+		pageEntries := make([]CopcEntry, len(data)/copcEntryLength)
+		if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, pageEntries); err != nil {
+			return nil, err
+		}
+		for _, e := range pageEntries {
+			if e.PointCount == -1 {
+				pages = append(pages, [2]uint64{e.Offset, uint64(e.ByteSize)})
+				continue
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// ReadCopcNode reads all the points of the given COPC octree node. Reading nodes moves the underlying reader,
+// therefore it should not be mixed with the sequential reads performed via Next.
+func (g *Las) ReadCopcNode(e CopcEntry) ([]Point, error) {
+	if g.copc == nil || g.laz == nil {
+		return nil, errors.New("the file is not a copc file")
+	}
+	if e.PointCount <= 0 {
+		return nil, nil
+	}
+	g.Lock()
+	defer g.Unlock()
+	if err := g.laz.SeekChunk(int64(e.Offset), uint64(e.PointCount)); err != nil {
+		return nil, err
+	}
+	pts := make([]Point, e.PointCount)
+	data := make([]byte, g.Header.PointDataRecordLength)
+	for i := range pts {
+		if err := g.readRecord(data); err != nil {
+			return nil, fmt.Errorf("unable to read copc node %v: %w", e.Key, err)
+		}
+		p, err := g.parsePoint(data)
+		if err != nil {
+			return nil, err
+		}
+		pts[i] = p
+	}
+	return pts, nil
+}
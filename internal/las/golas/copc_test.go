@@ -0,0 +1,118 @@
+package golas
+
+import (
+	"os"
+	"sort"
+	"testing"
+)
+
+func TestCopc(t *testing.T) {
+	f, err := os.Open("./testdata/copc.laz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	las, err := NewLas(f)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	info := las.CopcInfo()
+	if info == nil {
+		t.Fatalf("expected copc info, got nil")
+	}
+	if info.Halfsize < 2318.86 || info.Halfsize > 2318.87 {
+		t.Errorf("unexpected halfsize %f", info.Halfsize)
+	}
+	entries, err := las.CopcHierarchy()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// 34 non empty nodes, one of which stored in a child page, and an empty node
+	if len(entries) != 35 {
+		t.Fatalf("expected 35 entries got %d", len(entries))
+	}
+	var actual [][3]float64
+	for _, e := range entries {
+		pts, err := las.ReadCopcNode(e)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if len(pts) != int(e.PointCount) {
+			t.Errorf("expected %d points got %d", e.PointCount, len(pts))
+		}
+		min, max := e.Key.Bounds(info)
+		for _, p := range pts {
+			if p.X < min[0] || p.X > max[0] || p.Y < min[1] || p.Y > max[1] || p.Z < min[2] || p.Z > max[2] {
+				t.Errorf("point %v outside of node %v bounds", p, e.Key)
+			}
+			actual = append(actual, [3]float64{p.X, p.Y, p.Z})
+		}
+	}
+
+	// nodes must contain all the points of the source cloud
+	fLas, err := os.Open("./testdata/1.2-with-color.las")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fLas.Close()
+	src, err := NewLas(fLas)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if src.CopcInfo() != nil {
+		t.Errorf("expected no copc info")
+	}
+	if _, err := src.CopcHierarchy(); err == nil {
+		t.Errorf("expected error, got none")
+	}
+	var expected [][3]float64
+	for src.HasNext() {
+		p, err := src.Next()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		expected = append(expected, [3]float64{p.X, p.Y, p.Z})
+	}
+	sortPts := func(pts [][3]float64) {
+		sort.Slice(pts, func(i, j int) bool {
+			for k := 0; k < 3; k++ {
+				if pts[i][k] != pts[j][k] {
+					return pts[i][k] < pts[j][k]
+				}
+			}
+			return false
+		})
+	}
+	sortPts(expected)
+	sortPts(actual)
+	if len(expected) != len(actual) {
+		t.Fatalf("expected %d points got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Fatalf("point %d: expected %v got %v", i, expected[i], actual[i])
+		}
+	}
+}
+
+func TestCopcSequentialRead(t *testing.T) {
+	f, err := os.Open("./testdata/copc.laz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	las, err := NewLas(f)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	n := 0
+	for las.HasNext() {
+		if _, err := las.Next(); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		n++
+	}
+	if n != 1065 {
+		t.Errorf("expected 1065 points got %d", n)
+	}
+}
@@ -0,0 +1,217 @@
+package golas
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	extraBytesUserID           = "LASF_Spec"
+	extraBytesRecordID         = 4
+	extraBytesDescriptorLength = 192
+)
+
+// ExtraBytesType is the data type of the components of an extra bytes attribute
+type ExtraBytesType uint8
+
+const (
+	ExtraBytesUndocumented ExtraBytesType = iota
+	ExtraBytesUint8
+	ExtraBytesInt8
+	ExtraBytesUint16
+	ExtraBytesInt16
+	ExtraBytesUint32
+	ExtraBytesInt32
+	ExtraBytesUint64
+	ExtraBytesInt64
+	ExtraBytesFloat32
+	ExtraBytesFloat64
+)
+
+// Size returns the size in bytes of a component of the given type
+func (t ExtraBytesType) Size() int {
+	switch t {
+	case ExtraBytesUint8, ExtraBytesInt8:
+		return 1
+	case ExtraBytesUint16, ExtraBytesInt16:
+		return 2
+	case ExtraBytesUint32, ExtraBytesInt32, ExtraBytesFloat32:
+		return 4
+	case ExtraBytesUint64, ExtraBytesInt64, ExtraBytesFloat64:
+		return 8
+	}
+	return 0
+}
+
+// ExtraBytesDescriptor describes an attribute stored in the extra bytes of the point records,
+// as declared in the Extra Bytes VLR
+type ExtraBytesDescriptor struct {
+	Name        string
+	Description string
+	Type        ExtraBytesType
+	// Components is the number of values of the attribute, 1 for scalars, 2 or 3 for the deprecated array types
+	Components int
+	// Offset is the position of the attribute within the extra bytes of a point record
+	Offset int
+	// Size is the total size in bytes of the attribute
+	Size int
+	// Scaled is true if the raw values have to be scaled or offset
+	Scaled bool
+	Scale  [3]float64
+	Shift  [3]float64
+	// HasNoData is true if NoData holds the raw value marking a missing value
+	HasNoData bool
+	NoData    [3]float64
+}
+
+// ExtraBytes returns the descriptors of the attributes stored in the extra bytes of the point records,
+// as declared in the Extra Bytes VLR or EVLR. Returns nil if the file does not declare any.
+func (g *Las) ExtraBytes() []ExtraBytesDescriptor {
+	return g.extraBytes
+}
+
+// extractExtraBytes parses the Extra Bytes VLR or EVLR, if present
+func (g *Las) extractExtraBytes() error {
+	var data []byte
+	for _, v := range g.VLRs {
+		if v.UserID == extraBytesUserID && v.RecordID == extraBytesRecordID {
+			data = v.Data
+		}
+	}
+	for _, v := range g.EVLRs {
+		if v.UserID == extraBytesUserID && v.RecordID == extraBytesRecordID {
+			data = v.Data
+		}
+	}
+	if data == nil {
+		return nil
+	}
+	if len(data)%extraBytesDescriptorLength != 0 {
+		return fmt.Errorf("invalid extra bytes VLR length %d", len(data))
+	}
+	offset := 0
+	var descriptors []ExtraBytesDescriptor
+	for i := 0; i < len(data); i += extraBytesDescriptorLength {
+		d, err := parseExtraBytesDescriptor(data[i:i+extraBytesDescriptorLength], offset)
+		if err != nil {
+			return err
+		}
+		offset += d.Size
+		descriptors = append(descriptors, d)
+	}
+	g.extraBytes = descriptors
+	return nil
+}
+
+func parseExtraBytesDescriptor(data []byte, offset int) (ExtraBytesDescriptor, error) {
+	dataType := data[2]
+	options := data[3]
+	d := ExtraBytesDescriptor{
+		Name:        strings.TrimRight(string(data[4:36]), "\u0000"),
+		Description: strings.TrimRight(string(data[160:192]), "\u0000"),
+		Offset:      offset,
+		Scale:       [3]float64{1, 1, 1},
+	}
+	switch {
+	case dataType == 0:
+		// undocumented extra bytes, the options store the number of bytes
+		d.Type = ExtraBytesUndocumented
+		d.Components = 1
+		d.Size = int(options)
+		return d, nil
+	case dataType <= 10:
+		d.Type = ExtraBytesType(dataType)
+		d.Components = 1
+	case dataType <= 20:
+		d.Type = ExtraBytesType(dataType - 10)
+		d.Components = 2
+	case dataType <= 30:
+		d.Type = ExtraBytesType(dataType - 20)
+		d.Components = 3
+	default:
+		return d, fmt.Errorf("invalid extra bytes data type %d for attribute %s", dataType, d.Name)
+	}
+	d.Size = d.Type.Size() * d.Components
+	d.Scaled = options&0b11000 != 0
+	for c := 0; c < d.Components; c++ {
+		if options&0b1 != 0 {
+			d.HasNoData = true
+			d.NoData[c] = decodeAnyType(d.Type, data[40+8*c:])
+		}
+		if options&0b1000 != 0 {
+			d.Scale[c] = math.Float64frombits(binary.LittleEndian.Uint64(data[112+8*c:]))
+		}
+		if options&0b10000 != 0 {
+			d.Shift[c] = math.Float64frombits(binary.LittleEndian.Uint64(data[136+8*c:]))
+		}
+	}
+	return d, nil
+}
+
+// decodeAnyType decodes a value of the VLR anytype fields, which store integers as 64 bit integers
+// and floating point numbers as doubles
+func decodeAnyType(t ExtraBytesType, data []byte) float64 {
+	raw := binary.LittleEndian.Uint64(data)
+	switch t {
+	case ExtraBytesUint8, ExtraBytesUint16, ExtraBytesUint32, ExtraBytesUint64:
+		return float64(raw)
+	case ExtraBytesFloat32, ExtraBytesFloat64:
+		return math.Float64frombits(raw)
+	}
+	return float64(int64(raw))
+}
+
+// Values decodes the values of the attribute from the extra bytes of the given point, applying scale and offset
+func (d ExtraBytesDescriptor) Values(p Point) ([]float64, error) {
+	vals := make([]float64, d.Components)
+	return vals, d.ReadValues(p, vals)
+}
+
+// ReadValues decodes the values of the attribute from the extra bytes of the given point into the given
+// slice, which must have room for all the components, applying scale and offset
+func (d ExtraBytesDescriptor) ReadValues(p Point, vals []float64) error {
+	if d.Type == ExtraBytesUndocumented {
+		return fmt.Errorf("attribute %s has an undocumented type", d.Name)
+	}
+	if d.Offset+d.Size > len(p.CustomData) {
+		return errors.New("point extra bytes shorter than declared in the extra bytes VLR")
+	}
+	data := p.CustomData[d.Offset:]
+	size := d.Type.Size()
+	for c := 0; c < d.Components; c++ {
+		v := decodeExtraBytesValue(d.Type, data[c*size:])
+		if d.Scaled {
+			v = v*d.Scale[c] + d.Shift[c]
+		}
+		vals[c] = v
+	}
+	return nil
+}
+
+func decodeExtraBytesValue(t ExtraBytesType, data []byte) float64 {
+	switch t {
+	case ExtraBytesUint8:
+		return float64(data[0])
+	case ExtraBytesInt8:
+		return float64(int8(data[0]))
+	case ExtraBytesUint16:
+		return float64(binary.LittleEndian.Uint16(data))
+	case ExtraBytesInt16:
+		return float64(int16(binary.LittleEndian.Uint16(data)))
+	case ExtraBytesUint32:
+		return float64(binary.LittleEndian.Uint32(data))
+	case ExtraBytesInt32:
+		return float64(int32(binary.LittleEndian.Uint32(data)))
+	case ExtraBytesUint64:
+		return float64(binary.LittleEndian.Uint64(data))
+	case ExtraBytesInt64:
+		return float64(int64(binary.LittleEndian.Uint64(data)))
+	case ExtraBytesFloat32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	default:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data))
+	}
+}
@@ -0,0 +1,122 @@
+package golas
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestExtraBytes(t *testing.T) {
+	f, err := os.Open("./testdata/extrabytes.las")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := NewLas(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eb := g.ExtraBytes()
+	expected := []ExtraBytesDescriptor{
+		{Name: "Colors", Description: "Colors", Type: ExtraBytesUint16, Components: 3, Offset: 0, Size: 6},
+		{Name: "Reserved", Description: "Reserved", Type: ExtraBytesUndocumented, Components: 1, Offset: 6, Size: 7},
+		{Name: "Flags", Description: "Flags", Type: ExtraBytesInt8, Components: 2, Offset: 13, Size: 2},
+		{Name: "Intensity", Description: "Brightness", Type: ExtraBytesUint32, Components: 1, Offset: 15, Size: 4},
+		{Name: "Time", Description: "Time", Type: ExtraBytesUint64, Components: 1, Offset: 19, Size: 8},
+	}
+	if len(eb) != len(expected) {
+		t.Fatalf("expected %d extra bytes descriptors got %d", len(expected), len(eb))
+	}
+	for i, e := range expected {
+		e.Scale = [3]float64{1, 1, 1}
+		if eb[i] != e {
+			t.Errorf("expected descriptor %+v got %+v", e, eb[i])
+		}
+	}
+	for i := 0; i < 10; i++ {
+		p, err := g.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the file stores copies of the standard attributes as extra bytes
+		colors, err := eb[0].Values(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if colors[0] != float64(p.Red) || colors[1] != float64(p.Green) || colors[2] != float64(p.Blue) {
+			t.Errorf("expected colors %d %d %d got %v", p.Red, p.Green, p.Blue, colors)
+		}
+		intensity, err := eb[3].Values(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if intensity[0] != float64(p.Intensity) {
+			t.Errorf("expected intensity %d got %v", p.Intensity, intensity)
+		}
+		time, err := eb[4].Values(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time[0] != math.Floor(p.GPSTime) {
+			t.Errorf("expected time %f got %v", math.Floor(p.GPSTime), time)
+		}
+		if _, err := eb[1].Values(p); err == nil {
+			t.Errorf("expected error reading undocumented extra bytes")
+		}
+	}
+}
+
+func TestExtraBytesMissing(t *testing.T) {
+	f, err := os.Open("./testdata/1.2-with-color.las")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := NewLas(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eb := g.ExtraBytes(); eb != nil {
+		t.Errorf("expected no extra bytes got %v", eb)
+	}
+}
+
+func TestParseExtraBytesDescriptorScaled(t *testing.T) {
+	data := make([]byte, extraBytesDescriptorLength)
+	// two element int16 array with no data, scale and offset
+	data[2] = 14
+	data[3] = 0b11001
+	copy(data[4:], "deviation")
+	noData := int64(-32768)
+	binary.LittleEndian.PutUint64(data[40:], uint64(noData))
+	binary.LittleEndian.PutUint64(data[112:], math.Float64bits(0.01))
+	binary.LittleEndian.PutUint64(data[120:], math.Float64bits(0.1))
+	binary.LittleEndian.PutUint64(data[136:], math.Float64bits(0.5))
+	binary.LittleEndian.PutUint64(data[144:], math.Float64bits(-1))
+	copy(data[160:], "Deviation from design")
+	d, err := parseExtraBytesDescriptor(data, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "deviation" || d.Description != "Deviation from design" || d.Type != ExtraBytesInt16 ||
+		d.Components != 2 || d.Offset != 3 || d.Size != 4 || !d.Scaled || !d.HasNoData || d.NoData[0] != -32768 {
+		t.Errorf("unexpected descriptor %+v", d)
+	}
+	p := Point{CustomData: []byte{0, 0, 0, 0x9c, 0xff, 0x0a, 0x00}}
+	vals, err := d.Values(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// -100 * 0.01 + 0.5 and 10 * 0.1 - 1
+	if math.Abs(vals[0]+0.5) > 1e-9 || math.Abs(vals[1]) > 1e-9 {
+		t.Errorf("unexpected values %v", vals)
+	}
+	if _, err := d.Values(Point{CustomData: []byte{0, 0}}); err == nil {
+		t.Errorf("expected error for short extra bytes")
+	}
+	data[2] = 31
+	if _, err := parseExtraBytesDescriptor(data, 0); err == nil {
+		t.Errorf("expected error for invalid data type")
+	}
+}
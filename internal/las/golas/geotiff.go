@@ -76,7 +76,7 @@ var geotiffKeys = map[int]string{
 	3088: "ProjCenterLongGeoKey",
 	3089: "ProjCenterLatGeoKey",
 	3090: "ProjCenterEastingGeoKey",
-	3091: "ProjFalseOriginNorthingGeoKey",
+	3091: "ProjCenterNorthingGeoKey",
 	3092: "ProjScaleAtNatOriginGeoKey",
 	3093: "ProjScaleAtCenterGeoKey",
 	3094: "ProjAzimuthAngleGeoKey",
@@ -0,0 +1,404 @@
+package golas
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// geotiffUserDefined is the GeoTIFF key value signaling that a CRS component is not identified by an EPSG code
+// but is described by the other keys
+const geotiffUserDefined = 32767
+
+// geotiffCoordTrans maps the GeoTIFF ProjCoordTransGeoKey codes to the PROJ projection names
+var geotiffCoordTrans = map[int]string{
+	1:  "tmerc",
+	3:  "omerc",
+	7:  "merc",
+	8:  "lcc",
+	9:  "lcc",
+	10: "laea",
+	11: "aea",
+	12: "aeqd",
+	13: "eqdc",
+	14: "stere",
+	15: "stere",
+	16: "sterea",
+	17: "eqc",
+	18: "cass",
+	19: "gnom",
+	20: "mill",
+	21: "ortho",
+	22: "poly",
+	23: "robin",
+	24: "sinu",
+	25: "vandg",
+	26: "nzmg",
+	27: "tmerc",
+}
+
+// geotiffGeographicCS maps the EPSG codes of the most common geographic CRSs to the PROJ datum parameters
+var geotiffGeographicCS = map[int]string{
+	4326: "+datum=WGS84",
+	4269: "+datum=NAD83",
+	4267: "+datum=NAD27",
+	4277: "+datum=OSGB36",
+	4314: "+datum=potsdam",
+	4322: "+ellps=WGS72 +towgs84=0,0,4.5,0,0,0.554,0.2263",
+	4230: "+ellps=intl +towgs84=-87,-98,-121,0,0,0,0",
+	4258: "+ellps=GRS80 +towgs84=0,0,0,0,0,0,0",
+	4283: "+ellps=GRS80 +towgs84=0,0,0,0,0,0,0",
+	4617: "+ellps=GRS80 +towgs84=0,0,0,0,0,0,0",
+	4759: "+ellps=GRS80 +towgs84=0,0,0,0,0,0,0",
+	6318: "+ellps=GRS80 +towgs84=0,0,0,0,0,0,0",
+	7844: "+ellps=GRS80 +towgs84=0,0,0,0,0,0,0",
+}
+
+// geotiffDatums maps the EPSG codes of the most common geodetic datums to the PROJ datum parameters
+var geotiffDatums = map[int]string{
+	6326: "+datum=WGS84",
+	6269: "+datum=NAD83",
+	6267: "+datum=NAD27",
+	6277: "+datum=OSGB36",
+	6314: "+datum=potsdam",
+	6322: "+ellps=WGS72 +towgs84=0,0,4.5,0,0,0.554,0.2263",
+	6230: "+ellps=intl +towgs84=-87,-98,-121,0,0,0,0",
+	6258: "+ellps=GRS80 +towgs84=0,0,0,0,0,0,0",
+	6283: "+ellps=GRS80 +towgs84=0,0,0,0,0,0,0",
+}
+
+// geotiffEllipsoids maps the EPSG codes of the most common ellipsoids to the PROJ ellipsoid names
+var geotiffEllipsoids = map[int]string{
+	7001: "airy",
+	7002: "mod_airy",
+	7004: "bessel",
+	7008: "clrk66",
+	7019: "GRS80",
+	7022: "intl",
+	7024: "krass",
+	7030: "WGS84",
+	7043: "WGS72",
+}
+
+// geotiffPrimeMeridians maps the EPSG codes of the prime meridians to the PROJ prime meridian names
+var geotiffPrimeMeridians = map[int]string{
+	8901: "greenwich",
+	8902: "lisbon",
+	8903: "paris",
+	8904: "bogota",
+	8905: "madrid",
+	8906: "rome",
+	8907: "bern",
+	8908: "jakarta",
+	8909: "ferro",
+	8910: "brussels",
+	8911: "stockholm",
+	8912: "athens",
+	8913: "oslo",
+}
+
+// geotiffLinearUnit is a linear unit, identified by its PROJ name if PROJ defines one, else by its size in meters
+type geotiffLinearUnit struct {
+	name    string
+	toMeter float64
+}
+
+// geotiffLinearUnits maps the EPSG codes of the linear units to their definitions
+var geotiffLinearUnits = map[int]geotiffLinearUnit{
+	9001: {name: "m", toMeter: 1},
+	9002: {name: "ft", toMeter: 0.3048},
+	9003: {name: "us-ft", toMeter: 1200.0 / 3937.0},
+	9005: {toMeter: 0.3047972654},
+	9014: {toMeter: 1.8288},
+	9030: {toMeter: 1852},
+	9033: {toMeter: 66 * 1200.0 / 3937.0},
+	9035: {toMeter: 5280 * 1200.0 / 3937.0},
+	9036: {name: "km", toMeter: 1000},
+	9037: {toMeter: 0.9143917962},
+	9038: {toMeter: 20.1166195164},
+	9039: {toMeter: 0.201166195164},
+	9042: {toMeter: 20.11676512},
+	9093: {toMeter: 1609.344},
+	9096: {toMeter: 0.9144},
+	9097: {toMeter: 20.1168},
+	9098: {toMeter: 0.201168},
+}
+
+// geotiffAngularUnits maps the EPSG codes of the angular units to their size in degrees
+var geotiffAngularUnits = map[int]float64{
+	9101: 180 / math.Pi,
+	9102: 1,
+	9104: 1.0 / 3600,
+	9105: 0.9,
+	9122: 1,
+}
+
+// ProjString returns a PROJ string describing the CRS defined by user-defined GeoTIFF keys, i.e. by a CRS
+// whose components are not identified by EPSG codes but by the projection method, its parameters, the datum,
+// the ellipsoid and the units. Components identified by EPSG codes are translated for the most common ones only.
+// The vertical datum cannot be represented and is ignored, but the vertical units, if declared, are honored.
+// An error is returned if the keys do not describe a user-defined CRS or use codes that cannot be translated.
+func (g *GeoTIFFMetadata) ProjString() (string, error) {
+	var params []string
+	projectedCS, hasProjectedCS := g.short(3072)
+	geographicCS, hasGeographicCS := g.short(2048)
+	modelType, _ := g.short(1024)
+	switch {
+	case (hasProjectedCS && projectedCS == geotiffUserDefined) || (!hasProjectedCS && modelType == 1):
+		projection, err := g.projection()
+		if err != nil {
+			return "", err
+		}
+		params = append(params, projection...)
+	case hasGeographicCS && geographicCS == geotiffUserDefined:
+		params = append(params, "+proj=longlat")
+	default:
+		return "", fmt.Errorf("the GeoTIFF keys do not define a user-defined CRS")
+	}
+	datum, err := g.datum()
+	if err != nil {
+		return "", err
+	}
+	params = append(params, datum...)
+	if params[0] != "+proj=longlat" {
+		units, err := g.linearUnit(3076, 3077)
+		if err != nil {
+			return "", err
+		}
+		params = append(params, units.param("units", "to_meter"))
+	}
+	if _, ok := g.short(4099); ok {
+		units, err := g.linearUnit(4099, 0)
+		if err != nil {
+			return "", err
+		}
+		params = append(params, units.param("vunits", "vto_meter"))
+	}
+	params = append(params, "+no_defs", "+type=crs")
+	return strings.Join(params, " "), nil
+}
+
+// short returns the value of the given key as a short, and whether it was found
+func (g *GeoTIFFMetadata) short(key int) (int, bool) {
+	val := g.Keys[key]
+	if val == nil || val.Type != GTTagTypeShort {
+		return 0, false
+	}
+	return int(val.AsShort()), true
+}
+
+// double returns the value of the first of the given keys that is found, and whether any was found
+func (g *GeoTIFFMetadata) double(keys ...int) (float64, bool) {
+	for _, key := range keys {
+		val := g.Keys[key]
+		if val == nil {
+			continue
+		}
+		switch val.Type {
+		case GTTagTypeDouble:
+			return val.AsDouble(), true
+		case GTTagTypeShort:
+			return float64(val.AsShort()), true
+		}
+	}
+	return 0, false
+}
+
+// projection returns the PROJ parameters of the projection method and of its parameters
+func (g *GeoTIFFMetadata) projection() ([]string, error) {
+	angularUnit := 1.0
+	if code, ok := g.short(2054); ok {
+		size, found := geotiffAngularUnits[code]
+		if !found {
+			return nil, fmt.Errorf("unsupported GeoTIFF angular unit %d", code)
+		}
+		angularUnit = size
+	}
+	linearUnit, err := g.linearUnit(3076, 3077)
+	if err != nil {
+		return nil, err
+	}
+	// angles are converted in degrees and false eastings and northings in meters, as PROJ expects them
+	angle := func(keys ...int) (float64, bool) {
+		v, ok := g.double(keys...)
+		return v * angularUnit, ok
+	}
+	length := func(keys ...int) (float64, bool) {
+		v, ok := g.double(keys...)
+		return v * linearUnit.toMeter, ok
+	}
+
+	coordTrans, ok := g.short(3075)
+	if !ok {
+		// the projection might still be identified by an EPSG code, which is supported for the UTM zones only
+		code, found := g.short(3074)
+		switch {
+		case found && code >= 16001 && code <= 16060:
+			return []string{"+proj=utm", fmt.Sprintf("+zone=%d", code-16000)}, nil
+		case found && code >= 16101 && code <= 16160:
+			return []string{"+proj=utm", fmt.Sprintf("+zone=%d", code-16100), "+south"}, nil
+		case found:
+			return nil, fmt.Errorf("unsupported GeoTIFF projection %d", code)
+		}
+		return nil, fmt.Errorf("the GeoTIFF keys do not define the projection method")
+	}
+	name, found := geotiffCoordTrans[coordTrans]
+	if !found {
+		return nil, fmt.Errorf("unsupported GeoTIFF coordinate transformation %d", coordTrans)
+	}
+	params := []string{"+proj=" + name}
+	// add returns a function appending the given parameter if its value was found
+	add := func(param string) func(float64, bool) {
+		return func(value float64, ok bool) {
+			if ok {
+				params = append(params, fmt.Sprintf("+%s=%s", param, strconv.FormatFloat(value, 'f', -1, 64)))
+			}
+		}
+	}
+	switch coordTrans {
+	case 8:
+		// Lambert Conic Conformal 2SP uses the false origin but writers often use the natural origin keys
+		add("lat_1")(angle(3078))
+		add("lat_2")(angle(3079))
+		add("lat_0")(angle(3085, 3081))
+		add("lon_0")(angle(3084, 3080))
+		add("x_0")(length(3086, 3082))
+		add("y_0")(length(3087, 3083))
+		return params, nil
+	case 9:
+		lat0, ok := angle(3081)
+		add("lat_1")(lat0, ok)
+		add("lat_0")(lat0, ok)
+	case 11, 13:
+		add("lat_1")(angle(3078))
+		add("lat_2")(angle(3079))
+		add("lat_0")(angle(3081, 3085))
+		add("lon_0")(angle(3080, 3084))
+		add("x_0")(length(3082, 3086))
+		add("y_0")(length(3083, 3087))
+		return params, nil
+	case 3:
+		add("lat_0")(angle(3089))
+		add("lonc")(angle(3088))
+		add("alpha")(angle(3094))
+		add("k_0")(g.double(3093, 3092))
+		if _, ok := g.double(3090); ok {
+			add("x_0")(length(3090))
+			add("y_0")(length(3091))
+		} else {
+			// the false easting and northing are defined at the natural origin
+			add("x_0")(length(3082))
+			add("y_0")(length(3083))
+			params = append(params, "+no_uoff")
+		}
+		return params, nil
+	case 10, 12, 14, 19, 21:
+		add("lat_0")(angle(3089, 3081))
+		add("lon_0")(angle(3088, 3080))
+	case 15:
+		lat, ok := angle(3081)
+		if ok {
+			lat0 := math.Copysign(90, lat)
+			add("lat_0")(lat0, true)
+			if lat != lat0 {
+				add("lat_ts")(lat, true)
+			}
+		}
+		add("lon_0")(angle(3095, 3080))
+	case 7:
+		add("lat_ts")(angle(3078))
+		add("lon_0")(angle(3080, 3088))
+	case 17:
+		add("lat_ts")(angle(3078, 3089, 3081))
+		add("lon_0")(angle(3088, 3080))
+	case 27:
+		params = append(params, "+axis=wsu")
+		add("lat_0")(angle(3081))
+		add("lon_0")(angle(3080))
+	default:
+		add("lat_0")(angle(3081, 3089))
+		add("lon_0")(angle(3080, 3088))
+	}
+	add("k_0")(g.double(3092, 3093))
+	add("x_0")(length(3082, 3090))
+	add("y_0")(length(3083, 3091))
+	return params, nil
+}
+
+// datum returns the PROJ parameters of the datum, or of the ellipsoid and the prime meridian if the
+// datum is user-defined
+func (g *GeoTIFFMetadata) datum() ([]string, error) {
+	if code, ok := g.short(2048); ok && code != geotiffUserDefined {
+		datum, found := geotiffGeographicCS[code]
+		if !found {
+			return nil, fmt.Errorf("unsupported GeoTIFF geographic CRS %d", code)
+		}
+		return strings.Fields(datum), nil
+	}
+	if code, ok := g.short(2050); ok && code != geotiffUserDefined {
+		datum, found := geotiffDatums[code]
+		if !found {
+			return nil, fmt.Errorf("unsupported GeoTIFF geodetic datum %d", code)
+		}
+		return append(strings.Fields(datum), g.primeMeridian()...), nil
+	}
+	var params []string
+	if code, ok := g.short(2056); ok && code != geotiffUserDefined {
+		ellps, found := geotiffEllipsoids[code]
+		if !found {
+			return nil, fmt.Errorf("unsupported GeoTIFF ellipsoid %d", code)
+		}
+		params = append(params, "+ellps="+ellps)
+	} else {
+		a, ok := g.double(2057)
+		if !ok {
+			return nil, fmt.Errorf("the GeoTIFF keys do not define the ellipsoid")
+		}
+		params = append(params, "+a="+strconv.FormatFloat(a, 'f', -1, 64))
+		if rf, ok := g.double(2059); ok {
+			params = append(params, "+rf="+strconv.FormatFloat(rf, 'f', -1, 64))
+		} else if b, ok := g.double(2058); ok {
+			params = append(params, "+b="+strconv.FormatFloat(b, 'f', -1, 64))
+		} else {
+			return nil, fmt.Errorf("the GeoTIFF keys do not define the ellipsoid flattening")
+		}
+	}
+	return append(params, g.primeMeridian()...), nil
+}
+
+// primeMeridian returns the PROJ parameter of the prime meridian, if different from Greenwich
+func (g *GeoTIFFMetadata) primeMeridian() []string {
+	code, ok := g.short(2051)
+	if name, found := geotiffPrimeMeridians[code]; ok && found && code != 8901 {
+		return []string{"+pm=" + name}
+	}
+	if lon, ok := g.double(2061); ok && lon != 0 {
+		return []string{"+pm=" + strconv.FormatFloat(lon, 'f', -1, 64)}
+	}
+	return nil
+}
+
+// linearUnit returns the linear unit declared by the given unit key or, if user-defined, by the given size key.
+// Meters are returned if the unit key is not found.
+func (g *GeoTIFFMetadata) linearUnit(unitKey int, sizeKey int) (geotiffLinearUnit, error) {
+	code, ok := g.short(unitKey)
+	if !ok {
+		return geotiffLinearUnits[9001], nil
+	}
+	if unit, found := geotiffLinearUnits[code]; found {
+		return unit, nil
+	}
+	if size, found := g.double(sizeKey); sizeKey != 0 && found && size > 0 {
+		return geotiffLinearUnit{toMeter: size}, nil
+	}
+	return geotiffLinearUnit{}, fmt.Errorf("unsupported GeoTIFF linear unit %d", code)
+}
+
+// param returns the PROJ parameter declaring the unit, by name if PROJ defines one, else by size
+func (u geotiffLinearUnit) param(name string, sizeName string) string {
+	if u.name != "" {
+		return fmt.Sprintf("+%s=%s", name, u.name)
+	}
+	return fmt.Sprintf("+%s=%s", sizeName, strconv.FormatFloat(u.toMeter, 'f', -1, 64))
+}
@@ -0,0 +1,191 @@
+package golas
+
+import (
+	"testing"
+)
+
+// newGeoTIFFMetadata builds the GeoTIFF metadata with the given keys, uint16 values being short keys and float64
+// values double keys
+func newGeoTIFFMetadata(keys map[int]any) *GeoTIFFMetadata {
+	g := &GeoTIFFMetadata{Keys: map[int]*GeoTIFFKey{}}
+	for k, v := range keys {
+		key := &GeoTIFFKey{KeyId: k, RawValue: v}
+		switch v.(type) {
+		case uint16:
+			key.Type = GTTagTypeShort
+		case float64:
+			key.Type = GTTagTypeDouble
+		default:
+			key.Type = GTTagTypeString
+		}
+		g.Keys[k] = key
+	}
+	return g
+}
+
+func TestGeoTIFFProjString(t *testing.T) {
+	cases := []struct {
+		name     string
+		keys     map[int]any
+		expected string
+	}{
+		{
+			name: "transverse mercator in us survey feet",
+			keys: map[int]any{
+				1024: uint16(1),
+				2048: uint16(4269),
+				3072: uint16(32767),
+				3074: uint16(32767),
+				3075: uint16(1),
+				3076: uint16(9003),
+				3080: -87.0,
+				3081: 36.666666666666664,
+				3082: 984250.0,
+				3083: 0.0,
+				3092: 0.999966667,
+				4099: uint16(9003),
+			},
+			expected: "+proj=tmerc +lat_0=36.666666666666664 +lon_0=-87 +k_0=0.999966667 +x_0=300000 +y_0=0 +datum=NAD83 +units=us-ft +vunits=us-ft +no_defs +type=crs",
+		},
+		{
+			name: "lambert conformal conic on a user-defined ellipsoid",
+			keys: map[int]any{
+				1024: uint16(1),
+				2048: uint16(32767),
+				2050: uint16(32767),
+				2056: uint16(32767),
+				2057: 6378137.0,
+				2059: 298.257222101,
+				3072: uint16(32767),
+				3075: uint16(8),
+				3078: 49.0,
+				3079: 44.0,
+				3081: 46.5,
+				3080: 3.0,
+				3082: 700000.0,
+				3083: 6600000.0,
+			},
+			expected: "+proj=lcc +lat_1=49 +lat_2=44 +lat_0=46.5 +lon_0=3 +x_0=700000 +y_0=6600000 +a=6378137 +rf=298.257222101 +units=m +no_defs +type=crs",
+		},
+		{
+			name: "utm projection code with user-defined datum",
+			keys: map[int]any{
+				3072: uint16(32767),
+				3074: uint16(16133),
+				2050: uint16(6258),
+				2051: uint16(8901),
+			},
+			expected: "+proj=utm +zone=33 +south +ellps=GRS80 +towgs84=0,0,0,0,0,0,0 +units=m +no_defs +type=crs",
+		},
+		{
+			name: "polar stereographic in radians and international feet",
+			keys: map[int]any{
+				1024: uint16(1),
+				2048: uint16(4326),
+				2054: uint16(9101),
+				3072: uint16(32767),
+				3075: uint16(15),
+				3076: uint16(9002),
+				3081: -1.2217304763960306,
+				3095: 0.0,
+				3082: 0.0,
+				3083: 0.0,
+			},
+			expected: "+proj=stere +lat_0=-90 +lat_ts=-70 +lon_0=0 +x_0=0 +y_0=0 +datum=WGS84 +units=ft +no_defs +type=crs",
+		},
+		{
+			name: "user-defined vertical unit without size",
+			keys: map[int]any{
+				1024: uint16(2),
+				2048: uint16(32767),
+				2050: uint16(32767),
+				2056: uint16(7022),
+				2051: uint16(8903),
+				4099: uint16(32767),
+			},
+			expected: "",
+		},
+		{
+			name: "user-defined geographic",
+			keys: map[int]any{
+				1024: uint16(2),
+				2048: uint16(32767),
+				2050: uint16(32767),
+				2056: uint16(7022),
+				2051: uint16(8903),
+				4099: uint16(9001),
+			},
+			expected: "+proj=longlat +ellps=intl +pm=paris +vunits=m +no_defs +type=crs",
+		},
+		{
+			name: "projected with a user-defined linear unit",
+			keys: map[int]any{
+				3072: uint16(32767),
+				2048: uint16(4326),
+				3075: uint16(7),
+				3076: uint16(32767),
+				3077: 2.0,
+				3078: 10.0,
+				3080: 5.0,
+				3082: 10.0,
+				3083: 20.0,
+			},
+			expected: "+proj=merc +lat_ts=10 +lon_0=5 +x_0=20 +y_0=40 +datum=WGS84 +to_meter=2 +no_defs +type=crs",
+		},
+		{
+			name:     "epsg code",
+			keys:     map[int]any{1024: uint16(1), 3072: uint16(32633)},
+			expected: "",
+		},
+		{
+			name:     "unsupported projection code",
+			keys:     map[int]any{3072: uint16(32767), 3074: uint16(10101), 2048: uint16(4326)},
+			expected: "",
+		},
+		{
+			name:     "unsupported coordinate transformation",
+			keys:     map[int]any{3072: uint16(32767), 3075: uint16(2), 2048: uint16(4326)},
+			expected: "",
+		},
+		{
+			name:     "missing ellipsoid",
+			keys:     map[int]any{2048: uint16(32767), 2050: uint16(32767)},
+			expected: "",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := newGeoTIFFMetadata(tc.keys).ProjString()
+			if tc.expected == "" {
+				if err == nil {
+					t.Errorf("expected error, got %s", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %s got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCRSUserDefinedGeoTIFF(t *testing.T) {
+	l := &Las{geotiff: newGeoTIFFMetadata(map[int]any{
+		1024: uint16(1),
+		2048: uint16(4269),
+		3072: uint16(32767),
+		3074: uint16(16015),
+	})}
+	expected := "+proj=utm +zone=15 +datum=NAD83 +units=m +no_defs +type=crs"
+	if actual := l.CRS(); actual != expected {
+		t.Errorf("expected CRS %s got %s", expected, actual)
+	}
+	// the geographic CRS is returned if the user-defined projection cannot be described
+	l.geotiff.Keys[3074].RawValue = uint16(10101)
+	if actual := l.CRS(); actual != "EPSG:4269" {
+		t.Errorf("expected CRS %s got %s", "EPSG:4269", actual)
+	}
+}
@@ -39,36 +39,18 @@ func readString(r io.Reader, n int) (string, error) {
 	return strings.TrimRight(string(data), "\u0000"), err
 }
 
-func readInt8(r io.Reader) (int8, error) {
-	var data int8
-	err := binary.Read(r, binary.LittleEndian, &data)
-	return data, err
-}
-
 func readUint8(r io.Reader) (uint8, error) {
 	var data uint8
 	err := binary.Read(r, binary.LittleEndian, &data)
 	return data, err
 }
 
-func readShort(r io.Reader) (int16, error) {
-	var data int16
-	err := binary.Read(r, binary.LittleEndian, &data)
-	return data, err
-}
-
 func readUnsignedShort(r io.Reader) (uint16, error) {
 	var data uint16
 	err := binary.Read(r, binary.LittleEndian, &data)
 	return data, err
 }
 
-func readLong(r io.Reader) (int32, error) {
-	var data int32
-	err := binary.Read(r, binary.LittleEndian, &data)
-	return data, err
-}
-
 func readUnsignedLong(r io.Reader) (uint32, error) {
 	var data uint32
 	err := binary.Read(r, binary.LittleEndian, &data)
@@ -81,12 +63,6 @@ func readUnsignedLong64(r io.Reader) (uint64, error) {
 	return data, err
 }
 
-func readFloat32(r io.Reader) (float32, error) {
-	var data float32
-	err := binary.Read(r, binary.LittleEndian, &data)
-	return data, err
-}
-
 func readFloat64(r io.Reader) (float64, error) {
 	var data float64
 	err := binary.Read(r, binary.LittleEndian, &data)
@@ -0,0 +1,271 @@
+package laz
+
+import "io"
+
+const (
+	acMinLength   uint32 = 0x01000000
+	acMaxLength   uint32 = 0xFFFFFFFF
+	bmLengthShift        = 13
+	bmMaxCount    uint32 = 1 << bmLengthShift
+	dmLengthShift        = 15
+	dmMaxCount    uint32 = 1 << dmLengthShift
+)
+
+// decoder is an adaptive arithmetic decoder compatible with the one used by LASzip.
+// Read errors are sticky and are reported by the err field.
+type decoder struct {
+	r      io.ByteReader
+	value  uint32
+	length uint32
+	err    error
+}
+
+// init prepares the decoder to read from the given byte source
+func (d *decoder) init(r io.ByteReader) {
+	d.r = r
+	d.err = nil
+	d.length = acMaxLength
+	d.value = 0
+	for i := 0; i < 4; i++ {
+		d.value = (d.value << 8) | uint32(d.getByte())
+	}
+}
+
+func (d *decoder) getByte() byte {
+	b, err := d.r.ReadByte()
+	if err != nil && d.err == nil {
+		d.err = err
+	}
+	return b
+}
+
+func (d *decoder) renorm() {
+	for {
+		d.value = (d.value << 8) | uint32(d.getByte())
+		d.length <<= 8
+		if d.length >= acMinLength {
+			return
+		}
+	}
+}
+
+func (d *decoder) decodeBit(m *bitModel) uint32 {
+	x := m.bit0Prob * (d.length >> bmLengthShift)
+	var sym uint32
+	if d.value >= x {
+		sym = 1
+		d.value -= x
+		d.length -= x
+	} else {
+		d.length = x
+		m.bit0Count++
+	}
+	if d.length < acMinLength {
+		d.renorm()
+	}
+	m.bitsUntilUpdate--
+	if m.bitsUntilUpdate == 0 {
+		m.update()
+	}
+	return sym
+}
+
+func (d *decoder) decodeSymbol(m *symbolModel) uint32 {
+	var n, sym, x uint32
+	y := d.length
+	d.length >>= dmLengthShift
+	if m.decoderTable != nil {
+		dv := d.value / d.length
+		t := dv >> m.tableShift
+		sym = m.decoderTable[t]
+		n = m.decoderTable[t+1] + 1
+		for n > sym+1 {
+			k := (sym + n) >> 1
+			if m.distribution[k] > dv {
+				n = k
+			} else {
+				sym = k
+			}
+		}
+		x = m.distribution[sym] * d.length
+		if sym != m.lastSymbol {
+			y = m.distribution[sym+1] * d.length
+		}
+	} else {
+		n = m.symbols
+		k := n >> 1
+		for {
+			z := d.length * m.distribution[k]
+			if z > d.value {
+				n = k
+				y = z
+			} else {
+				sym = k
+				x = z
+			}
+			k = (sym + n) >> 1
+			if k == sym {
+				break
+			}
+		}
+	}
+	d.value -= x
+	d.length = y - x
+	if d.length < acMinLength {
+		d.renorm()
+	}
+	m.symbolCount[sym]++
+	m.symbolsUntilUpdate--
+	if m.symbolsUntilUpdate == 0 {
+		m.update()
+	}
+	return sym
+}
+
+func (d *decoder) readBits(bits uint32) uint32 {
+	if bits > 19 {
+		low := d.readShort()
+		bits -= 16
+		high := d.readBits(bits) << 16
+		return high | uint32(low)
+	}
+	d.length >>= bits
+	sym := d.value / d.length
+	d.value -= d.length * sym
+	if d.length < acMinLength {
+		d.renorm()
+	}
+	return sym
+}
+
+func (d *decoder) readShort() uint16 {
+	d.length >>= 16
+	sym := d.value / d.length
+	d.value -= d.length * sym
+	if d.length < acMinLength {
+		d.renorm()
+	}
+	return uint16(sym)
+}
+
+func (d *decoder) readInt() uint32 {
+	low := uint32(d.readShort())
+	high := uint32(d.readShort())
+	return high<<16 | low
+}
+
+// symbolModel is an adaptive model for a multi-symbol alphabet
+type symbolModel struct {
+	symbols            uint32
+	lastSymbol         uint32
+	distribution       []uint32
+	symbolCount        []uint32
+	decoderTable       []uint32
+	totalCount         uint32
+	updateCycle        uint32
+	symbolsUntilUpdate uint32
+	tableSize          uint32
+	tableShift         uint32
+}
+
+// newSymbolModel returns an initialized symbolModel for the given number of symbols
+func newSymbolModel(symbols uint32) *symbolModel {
+	m := &symbolModel{
+		symbols:      symbols,
+		lastSymbol:   symbols - 1,
+		distribution: make([]uint32, symbols),
+		symbolCount:  make([]uint32, symbols),
+	}
+	if symbols > 16 {
+		tableBits := uint32(3)
+		for symbols > (1 << (tableBits + 2)) {
+			tableBits++
+		}
+		m.tableSize = 1 << tableBits
+		m.tableShift = dmLengthShift - tableBits
+		m.decoderTable = make([]uint32, m.tableSize+2)
+	}
+	for k := range m.symbolCount {
+		m.symbolCount[k] = 1
+	}
+	m.updateCycle = symbols
+	m.update()
+	m.updateCycle = (symbols + 6) >> 1
+	m.symbolsUntilUpdate = m.updateCycle
+	return m
+}
+
+func (m *symbolModel) update() {
+	m.totalCount += m.updateCycle
+	if m.totalCount > dmMaxCount {
+		m.totalCount = 0
+		for n := range m.symbolCount {
+			m.symbolCount[n] = (m.symbolCount[n] + 1) >> 1
+			m.totalCount += m.symbolCount[n]
+		}
+	}
+	var sum, s uint32
+	scale := 0x80000000 / m.totalCount
+	for k := uint32(0); k < m.symbols; k++ {
+		m.distribution[k] = (scale * sum) >> (31 - dmLengthShift)
+		sum += m.symbolCount[k]
+		if m.decoderTable != nil {
+			w := m.distribution[k] >> m.tableShift
+			for s < w {
+				s++
+				m.decoderTable[s] = k - 1
+			}
+		}
+	}
+	if m.decoderTable != nil {
+		m.decoderTable[0] = 0
+		for s <= m.tableSize {
+			s++
+			m.decoderTable[s] = m.symbols - 1
+		}
+	}
+	m.updateCycle = (5 * m.updateCycle) >> 2
+	maxCycle := (m.symbols + 6) << 3
+	if m.updateCycle > maxCycle {
+		m.updateCycle = maxCycle
+	}
+	m.symbolsUntilUpdate = m.updateCycle
+}
+
+// bitModel is an adaptive model for a binary alphabet
+type bitModel struct {
+	bit0Count       uint32
+	bitCount        uint32
+	bit0Prob        uint32
+	bitsUntilUpdate uint32
+	updateCycle     uint32
+}
+
+// newBitModel returns an initialized bitModel
+func newBitModel() *bitModel {
+	return &bitModel{
+		bit0Count:       1,
+		bitCount:        2,
+		bit0Prob:        1 << (bmLengthShift - 1),
+		updateCycle:     4,
+		bitsUntilUpdate: 4,
+	}
+}
+
+func (m *bitModel) update() {
+	m.bitCount += m.updateCycle
+	if m.bitCount > bmMaxCount {
+		m.bitCount = (m.bitCount + 1) >> 1
+		m.bit0Count = (m.bit0Count + 1) >> 1
+		if m.bit0Count == m.bitCount {
+			m.bitCount++
+		}
+	}
+	scale := 0x80000000 / m.bitCount
+	m.bit0Prob = (m.bit0Count * scale) >> (31 - bmLengthShift)
+	m.updateCycle = (5 * m.updateCycle) >> 2
+	if m.updateCycle > 64 {
+		m.updateCycle = 64
+	}
+	m.bitsUntilUpdate = m.updateCycle
+}
@@ -0,0 +1,319 @@
+package laz
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Decompressor decodes LAZ compressed point data into raw LAS point records.
+type Decompressor struct {
+	src          io.ReadSeeker
+	r            *bufio.Reader
+	vlr          *VLR
+	recordLength int
+	numPoints    uint64
+	read         uint64
+	// chunk table data, chunkPoints is only populated for variable size chunks
+	chunkStarts []int64
+	chunkPoints []uint32
+	chunkIndex  int
+	remaining   uint64
+	first       bool
+	// decoding state
+	dec       decoder
+	pointwise []pointwiseItem
+	layered   []layeredItem
+	context   uint32
+}
+
+// NewDecompressor returns a Decompressor reading the compressed point data that starts at the given
+// offset of the given source. The VLR specifies the compression scheme and the items composing each
+// record. The source is accessed exclusively by the Decompressor, which performs its own buffering.
+func NewDecompressor(src io.ReadSeeker, vlr *VLR, pointDataOffset int64, numPoints uint64) (*Decompressor, error) {
+	d := &Decompressor{
+		src:          src,
+		vlr:          vlr,
+		recordLength: vlr.RecordLength(),
+		numPoints:    numPoints,
+	}
+	if err := d.initItems(); err != nil {
+		return nil, err
+	}
+	if vlr.Compressor == CompressorPointwise {
+		d.chunkStarts = []int64{pointDataOffset}
+		d.chunkPoints = []uint32{uint32(numPoints)}
+		if err := d.seek(pointDataOffset); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	if err := d.readChunkTable(pointDataOffset); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// RecordLength returns the length of the decoded point records
+func (d *Decompressor) RecordLength() int {
+	return d.recordLength
+}
+
+func (d *Decompressor) initItems() error {
+	switch d.vlr.Compressor {
+	case CompressorPointwise, CompressorPointwiseChunked:
+		for _, it := range d.vlr.Items {
+			if it.Version != 2 {
+				return fmt.Errorf("unsupported laz item %d version %d", it.Type, it.Version)
+			}
+			switch {
+			case it.Type == ItemPoint10 && it.Size == 20:
+				d.pointwise = append(d.pointwise, &point10{dec: &d.dec})
+			case it.Type == ItemGPSTime11 && it.Size == 8:
+				d.pointwise = append(d.pointwise, &gpsTime11{dec: &d.dec})
+			case it.Type == ItemRGB12 && it.Size == 6:
+				d.pointwise = append(d.pointwise, &rgb12{dec: &d.dec})
+			case it.Type == ItemByte:
+				d.pointwise = append(d.pointwise, &byte10{dec: &d.dec})
+			default:
+				return fmt.Errorf("unsupported laz item %d of size %d", it.Type, it.Size)
+			}
+		}
+	case CompressorLayeredChunked:
+		for _, it := range d.vlr.Items {
+			if it.Version != 3 {
+				return fmt.Errorf("unsupported laz item %d version %d", it.Type, it.Version)
+			}
+			switch {
+			case it.Type == ItemPoint14 && it.Size == 30:
+				d.layered = append(d.layered, &point14{})
+			case it.Type == ItemRGB14 && it.Size == 6:
+				d.layered = append(d.layered, &rgb14{})
+			case it.Type == ItemRGBNIR14 && it.Size == 8:
+				d.layered = append(d.layered, &rgb14{nir: true})
+			case it.Type == ItemByte14:
+				d.layered = append(d.layered, newByte14(int(it.Size)))
+			default:
+				return fmt.Errorf("unsupported laz item %d of size %d", it.Type, it.Size)
+			}
+		}
+		if len(d.layered) == 0 {
+			return errors.New("no laz items found")
+		}
+		if _, ok := d.layered[0].(*point14); !ok {
+			return errors.New("layered laz compression requires the first item to be a point14")
+		}
+	default:
+		return fmt.Errorf("unsupported laz compressor %d", d.vlr.Compressor)
+	}
+	return nil
+}
+
+func (d *Decompressor) seek(offset int64) error {
+	if _, err := d.src.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if d.r == nil {
+		d.r = bufio.NewReaderSize(d.src, 64*1024)
+	} else {
+		d.r.Reset(d.src)
+	}
+	return nil
+}
+
+// readChunkTable reads the table storing the position and, for variable size chunks, the number of points
+// of each chunk
+func (d *Decompressor) readChunkTable(pointDataOffset int64) error {
+	if err := d.seek(pointDataOffset); err != nil {
+		return err
+	}
+	var tableOffset int64
+	if err := binary.Read(d.r, binary.LittleEndian, &tableOffset); err != nil {
+		return fmt.Errorf("unable to read laz chunk table offset: %w", err)
+	}
+	chunksStart := pointDataOffset + 8
+	if tableOffset+8 == chunksStart {
+		// the writer was interrupted before writing the chunk table, points can only be read sequentially
+		if d.vlr.ChunkSize == VariableChunkSize {
+			return errors.New("laz chunk table missing for variable size chunks")
+		}
+		return d.seek(chunksStart)
+	}
+	if tableOffset == -1 {
+		// the offset of the chunk table has been stored at the end of the file
+		if _, err := d.src.Seek(-8, io.SeekEnd); err != nil {
+			return err
+		}
+		if err := binary.Read(d.src, binary.LittleEndian, &tableOffset); err != nil {
+			return fmt.Errorf("unable to read laz chunk table offset: %w", err)
+		}
+	}
+	if err := d.seek(tableOffset); err != nil {
+		return err
+	}
+	var header [2]uint32
+	if err := binary.Read(d.r, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("unable to read laz chunk table: %w", err)
+	}
+	if header[0] != 0 {
+		return fmt.Errorf("unsupported laz chunk table version %d", header[0])
+	}
+	numChunks := header[1]
+	d.chunkStarts = make([]int64, numChunks)
+	if d.vlr.ChunkSize == VariableChunkSize {
+		d.chunkPoints = make([]uint32, numChunks)
+	}
+	if numChunks > 0 {
+		dec := &decoder{}
+		dec.init(d.r)
+		ic := newIntegerCoder(32, 2)
+		var prevPoints, prevBytes int32
+		offset := chunksStart
+		for i := range d.chunkStarts {
+			if d.chunkPoints != nil {
+				prevPoints = ic.decompress(dec, prevPoints, 0)
+				d.chunkPoints[i] = uint32(prevPoints)
+			}
+			prevBytes = ic.decompress(dec, prevBytes, 1)
+			d.chunkStarts[i] = offset
+			offset += int64(uint32(prevBytes))
+		}
+		if dec.err != nil {
+			return fmt.Errorf("unable to decode laz chunk table: %w", dec.err)
+		}
+	}
+	return d.seek(chunksStart)
+}
+
+// nextChunk moves the reader to the start of the next chunk
+func (d *Decompressor) nextChunk() error {
+	if d.chunkIndex < len(d.chunkStarts) {
+		if err := d.seek(d.chunkStarts[d.chunkIndex]); err != nil {
+			return err
+		}
+	}
+	if d.chunkPoints != nil {
+		if d.chunkIndex >= len(d.chunkPoints) {
+			return errors.New("laz chunk table does not cover all the points")
+		}
+		d.remaining = uint64(d.chunkPoints[d.chunkIndex])
+	} else {
+		d.remaining = uint64(d.vlr.ChunkSize)
+	}
+	d.chunkIndex++
+	d.first = true
+	return nil
+}
+
+// SeekChunk moves the decompressor to the chunk starting at the given offset, to be decoded as a standalone
+// chunk of numPoints points. Subsequent reads return io.EOF once the chunk has been fully read.
+// It allows random access to the chunks of the file, as required for example by COPC.
+func (d *Decompressor) SeekChunk(offset int64, numPoints uint64) error {
+	if err := d.seek(offset); err != nil {
+		return err
+	}
+	d.numPoints = numPoints
+	d.read = 0
+	d.remaining = numPoints
+	d.first = true
+	d.chunkIndex = len(d.chunkStarts)
+	return nil
+}
+
+// Read decodes the next point record into the given slice, that must be at least RecordLength bytes long.
+// It returns io.EOF once all points have been read.
+func (d *Decompressor) Read(record []byte) error {
+	if d.read >= d.numPoints {
+		return io.EOF
+	}
+	if len(record) < d.recordLength {
+		return fmt.Errorf("record buffer too small, expected %d bytes", d.recordLength)
+	}
+	record = record[:d.recordLength]
+	for d.remaining == 0 {
+		if err := d.nextChunk(); err != nil {
+			return err
+		}
+	}
+	var err error
+	if d.first {
+		err = d.readFirst(record)
+	} else {
+		err = d.readNext(record)
+	}
+	if err != nil {
+		return err
+	}
+	d.remaining--
+	d.read++
+	return nil
+}
+
+// readFirst reads the first point of a chunk, stored uncompressed, and initializes the models
+func (d *Decompressor) readFirst(record []byte) error {
+	if _, err := io.ReadFull(d.r, record); err != nil {
+		return fmt.Errorf("unable to read laz chunk: %w", err)
+	}
+	d.first = false
+	if d.pointwise != nil {
+		d.forEachItem(record, func(i int, item []byte) {
+			d.pointwise[i].init(item)
+		})
+		d.dec.init(d.r)
+		return nil
+	}
+	var count uint32
+	if err := binary.Read(d.r, binary.LittleEndian, &count); err != nil {
+		return fmt.Errorf("unable to read laz chunk: %w", err)
+	}
+	d.remaining = uint64(count)
+	for _, it := range d.layered {
+		if err := it.readLayerSizes(d.r); err != nil {
+			return fmt.Errorf("unable to read laz chunk layer sizes: %w", err)
+		}
+	}
+	var err error
+	d.forEachItem(record, func(i int, item []byte) {
+		if err == nil {
+			err = d.layered[i].init(d.r, item, &d.context)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("unable to read laz chunk layers: %w", err)
+	}
+	return nil
+}
+
+// readNext decodes a compressed point record
+func (d *Decompressor) readNext(record []byte) error {
+	if d.pointwise != nil {
+		d.forEachItem(record, func(i int, item []byte) {
+			d.pointwise[i].read(item)
+		})
+		if d.dec.err != nil {
+			return fmt.Errorf("unable to decode laz point: %w", d.dec.err)
+		}
+		return nil
+	}
+	var err error
+	d.forEachItem(record, func(i int, item []byte) {
+		d.layered[i].read(item, &d.context)
+		if err == nil {
+			err = d.layered[i].error()
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("unable to decode laz point: %w", err)
+	}
+	return nil
+}
+
+func (d *Decompressor) forEachItem(record []byte, f func(i int, item []byte)) {
+	pos := 0
+	for i, it := range d.vlr.Items {
+		f(i, record[pos:pos+int(it.Size)])
+		pos += int(it.Size)
+	}
+}
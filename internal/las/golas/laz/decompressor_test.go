@@ -0,0 +1,265 @@
+package laz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// generateRecords generates realistic raw point records for the given point format
+func generateRecords(format uint8, extraBytes int, n int, seed int64) [][]byte {
+	rnd := rand.New(rand.NewSource(seed))
+	v := newTestVLR(format, extraBytes, CompressorPointwiseChunked, 0)
+	out := make([][]byte, n)
+	x, y, z := int32(100000), int32(-50000), int32(2000)
+	gps := 1000.0
+	ret, nret := uint8(1), uint8(1)
+	channel := uint8(0)
+	rgb := [4]uint16{1000, 2000, 3000, 4000}
+	for i := range out {
+		rec := make([]byte, v.RecordLength())
+		x += int32(rnd.Intn(200) - 90)
+		y += int32(rnd.Intn(200) - 110)
+		z += int32(rnd.Intn(50) - 25)
+		if rnd.Intn(50) == 0 {
+			// occasional large jumps
+			x += int32(rnd.Intn(1 << 24))
+			z -= int32(rnd.Intn(1 << 20))
+		}
+		if ret >= nret {
+			nret = uint8(1 + rnd.Intn(5))
+			ret = 1
+			switch rnd.Intn(20) {
+			case 0:
+				// huge jump starting a new gps time sequence
+				gps += 1e6
+			case 1:
+				// go back to an older sequence
+				gps -= 1e6
+			default:
+				gps += 0.00001 * float64(1+rnd.Intn(3))
+			}
+		} else {
+			ret++
+		}
+		if rnd.Intn(10) == 0 {
+			channel = uint8(rnd.Intn(4))
+		}
+		if rnd.Intn(3) == 0 {
+			rgb[rnd.Intn(4)] += uint16(rnd.Intn(600))
+		}
+		intensity := uint16(rnd.Intn(1 << 16))
+		class := uint8(2 + rnd.Intn(3))
+		userData := uint8(0)
+		if rnd.Intn(7) == 0 {
+			userData = uint8(rnd.Intn(256))
+		}
+		psid := uint16(17 + i/300)
+		pos := 0
+		if format < 6 {
+			putInt32(rec[0:], x)
+			putInt32(rec[4:], y)
+			putInt32(rec[8:], z)
+			binary.LittleEndian.PutUint16(rec[12:], intensity)
+			rec[14] = ret | nret<<3 | uint8(rnd.Intn(2))<<6 | uint8(i%1000/999)<<7
+			rec[15] = class
+			rec[16] = uint8(int8(rnd.Intn(60) - 30))
+			rec[17] = userData
+			binary.LittleEndian.PutUint16(rec[18:], psid)
+			pos = 20
+			if format == 1 || format == 3 {
+				binary.LittleEndian.PutUint64(rec[pos:], math.Float64bits(gps))
+				pos += 8
+			}
+			if format == 2 || format == 3 {
+				writeRGB(rec[pos:], [3]uint16{rgb[0], rgb[1], rgb[2]})
+				pos += 6
+			}
+		} else {
+			p := point14Fields{
+				x: x, y: y, z: z,
+				intensity:           intensity,
+				returnNumber:        ret,
+				numberOfReturns:     nret,
+				classificationFlags: uint8(rnd.Intn(16) / 15),
+				scannerChannel:      channel,
+				scanDirectionFlag:   uint8(rnd.Intn(2)),
+				classification:      class,
+				userData:            userData,
+				scanAngle:           int16(rnd.Intn(30000) - 15000),
+				pointSourceID:       psid,
+				gpsTime:             math.Float64bits(gps),
+			}
+			p.pack(rec)
+			pos = 30
+			if format >= 7 {
+				writeRGB(rec[pos:], [3]uint16{rgb[0], rgb[1], rgb[2]})
+				pos += 6
+			}
+			if format == 8 {
+				binary.LittleEndian.PutUint16(rec[pos:], rgb[3])
+				pos += 2
+			}
+		}
+		for j := 0; j < extraBytes; j++ {
+			if j == 0 {
+				// a constant byte
+				rec[pos+j] = 42
+			} else {
+				rec[pos+j] = uint8(rnd.Intn(256))
+			}
+		}
+		out[i] = rec
+	}
+	return out
+}
+
+func TestDecompressorRoundTrip(t *testing.T) {
+	type testCase struct {
+		format     uint8
+		extraBytes int
+		compressor uint16
+		chunkSize  uint32
+	}
+	var tcs []testCase
+	for _, f := range []uint8{0, 1, 2, 3} {
+		tcs = append(tcs,
+			testCase{f, 0, CompressorPointwiseChunked, 50000},
+			testCase{f, 3, CompressorPointwiseChunked, 333},
+			testCase{f, 0, CompressorPointwiseChunked, VariableChunkSize},
+			testCase{f, 2, CompressorPointwise, 0},
+		)
+	}
+	for _, f := range []uint8{6, 7, 8} {
+		tcs = append(tcs,
+			testCase{f, 0, CompressorLayeredChunked, 50000},
+			testCase{f, 3, CompressorLayeredChunked, 333},
+			testCase{f, 1, CompressorLayeredChunked, VariableChunkSize},
+		)
+	}
+	for _, tc := range tcs {
+		t.Run(fmt.Sprintf("format %d extra %d compressor %d chunk %d", tc.format, tc.extraBytes, tc.compressor, tc.chunkSize), func(t *testing.T) {
+			records := generateRecords(tc.format, tc.extraBytes, 2000, int64(tc.format))
+			vlr := newTestVLR(tc.format, tc.extraBytes, tc.compressor, tc.chunkSize)
+			// simulate some data preceding the point data
+			offset := int64(100)
+			data := append(make([]byte, offset), compressPoints(vlr, records, offset)...)
+			d, err := NewDecompressor(bytes.NewReader(data), vlr, offset, uint64(len(records)))
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if d.RecordLength() != len(records[0]) {
+				t.Errorf("expected record length %d, got %d", len(records[0]), d.RecordLength())
+			}
+			rec := make([]byte, d.RecordLength())
+			for i, expected := range records {
+				if err := d.Read(rec); err != nil {
+					t.Fatalf("unexpected error reading point %d: %v", i, err)
+				}
+				if !bytes.Equal(rec, expected) {
+					t.Fatalf("point %d mismatch, expected %v got %v", i, expected, rec)
+				}
+			}
+			if err := d.Read(rec); err != io.EOF {
+				t.Errorf("expected EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDecompressorTruncated(t *testing.T) {
+	records := generateRecords(3, 0, 500, 1)
+	vlr := newTestVLR(3, 0, CompressorPointwise, 0)
+	data := compressPoints(vlr, records, 0)
+	d, err := NewDecompressor(bytes.NewReader(data[:len(data)/2]), vlr, 0, uint64(len(records)))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	rec := make([]byte, d.RecordLength())
+	for i := range records {
+		if err = d.Read(rec); err != nil {
+			break
+		}
+		if !bytes.Equal(rec, records[i]) {
+			t.Fatalf("point %d mismatch", i)
+		}
+	}
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF error, got %v", err)
+	}
+}
+
+func TestNewDecompressorUnsupported(t *testing.T) {
+	tcs := []struct {
+		name string
+		vlr  *VLR
+	}{
+		{name: "compressor", vlr: &VLR{Compressor: 7, Items: []Item{{ItemPoint10, 20, 2}}}},
+		{name: "item version", vlr: &VLR{Compressor: CompressorPointwiseChunked, Items: []Item{{ItemPoint10, 20, 1}}}},
+		{name: "wave packets", vlr: &VLR{Compressor: CompressorPointwiseChunked, Items: []Item{{ItemPoint10, 20, 2}, {ItemWavePacket13, 29, 2}}}},
+		{name: "layered without point14", vlr: &VLR{Compressor: CompressorLayeredChunked, Items: []Item{{ItemRGB14, 6, 3}}}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewDecompressor(bytes.NewReader(make([]byte, 100)), tc.vlr, 0, 1); err == nil {
+				t.Errorf("expected error, got none")
+			}
+		})
+	}
+}
+
+func TestParseVLR(t *testing.T) {
+	expected := newTestVLR(8, 4, CompressorLayeredChunked, 50000)
+	actual, err := ParseVLR(vlrPayload(expected))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v got %v", expected, actual)
+	}
+	if actual.RecordLength() != 42 {
+		t.Errorf("expected record length 42, got %d", actual.RecordLength())
+	}
+	if _, err := ParseVLR(vlrPayload(expected)[:40]); err == nil {
+		t.Errorf("expected error parsing truncated VLR, got none")
+	}
+}
+
+func TestDecompressorSeekChunk(t *testing.T) {
+	records := generateRecords(7, 0, 500, 3)
+	vlr := newTestVLR(7, 0, CompressorLayeredChunked, VariableChunkSize)
+	data := compressPoints(vlr, records, 0)
+	d, err := NewDecompressor(bytes.NewReader(data), vlr, 0, uint64(len(records)))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// read the second chunk, then go back to the first one
+	rec := make([]byte, d.RecordLength())
+	for _, chunk := range []int{1, 0} {
+		start := 0
+		for i := 0; i < chunk; i++ {
+			start += int(d.chunkPoints[i])
+		}
+		n := uint64(d.chunkPoints[chunk])
+		if err := d.SeekChunk(d.chunkStarts[chunk], n); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		for i := 0; i < int(n); i++ {
+			if err := d.Read(rec); err != nil {
+				t.Fatalf("unexpected error reading point %d of chunk %d: %v", i, chunk, err)
+			}
+			if !bytes.Equal(rec, records[start+i]) {
+				t.Fatalf("point %d of chunk %d mismatch", i, chunk)
+			}
+		}
+		if err := d.Read(rec); err != io.EOF {
+			t.Errorf("expected EOF, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,968 @@
+package laz
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// This file contains a LASzip compatible encoder used to produce the compressed data the tests decode.
+
+type encoder struct {
+	out    []byte
+	base   uint32
+	length uint32
+}
+
+func newEncoder() *encoder {
+	return &encoder{length: acMaxLength}
+}
+
+func (e *encoder) propagateCarry() {
+	p := len(e.out) - 1
+	for e.out[p] == 0xFF {
+		e.out[p] = 0
+		p--
+	}
+	e.out[p]++
+}
+
+func (e *encoder) renorm() {
+	for {
+		e.out = append(e.out, byte(e.base>>24))
+		e.base <<= 8
+		e.length <<= 8
+		if e.length >= acMinLength {
+			return
+		}
+	}
+}
+
+func (e *encoder) encodeBit(m *bitModel, sym uint32) {
+	x := m.bit0Prob * (e.length >> bmLengthShift)
+	if sym == 0 {
+		e.length = x
+		m.bit0Count++
+	} else {
+		initBase := e.base
+		e.base += x
+		e.length -= x
+		if initBase > e.base {
+			e.propagateCarry()
+		}
+	}
+	if e.length < acMinLength {
+		e.renorm()
+	}
+	m.bitsUntilUpdate--
+	if m.bitsUntilUpdate == 0 {
+		m.update()
+	}
+}
+
+func (e *encoder) encodeSymbol(m *symbolModel, sym uint32) {
+	initBase := e.base
+	if sym == m.lastSymbol {
+		x := m.distribution[sym] * (e.length >> dmLengthShift)
+		e.base += x
+		e.length -= x
+	} else {
+		e.length >>= dmLengthShift
+		x := m.distribution[sym] * e.length
+		e.base += x
+		e.length = m.distribution[sym+1]*e.length - x
+	}
+	if initBase > e.base {
+		e.propagateCarry()
+	}
+	if e.length < acMinLength {
+		e.renorm()
+	}
+	m.symbolCount[sym]++
+	m.symbolsUntilUpdate--
+	if m.symbolsUntilUpdate == 0 {
+		m.update()
+	}
+}
+
+func (e *encoder) writeShift(bits, sym uint32) {
+	initBase := e.base
+	e.length >>= bits
+	e.base += sym * e.length
+	if initBase > e.base {
+		e.propagateCarry()
+	}
+	if e.length < acMinLength {
+		e.renorm()
+	}
+}
+
+func (e *encoder) writeBits(bits, sym uint32) {
+	if bits > 19 {
+		e.writeShift(16, sym&0xFFFF)
+		sym >>= 16
+		bits -= 16
+	}
+	e.writeShift(bits, sym)
+}
+
+func (e *encoder) writeInt(sym uint32) {
+	e.writeShift(16, sym&0xFFFF)
+	e.writeShift(16, sym>>16)
+}
+
+func (e *encoder) done() []byte {
+	initBase := e.base
+	another := true
+	if e.length > 2*acMinLength {
+		e.base += acMinLength
+		e.length = acMinLength >> 1
+	} else {
+		e.base += acMinLength >> 1
+		e.length = acMinLength >> 9
+		another = false
+	}
+	if initBase > e.base {
+		e.propagateCarry()
+	}
+	e.renorm()
+	e.out = append(e.out, 0, 0)
+	if another {
+		e.out = append(e.out, 0)
+	}
+	return e.out
+}
+
+func (c *integerCoder) compress(e *encoder, pred, real int32, context uint32) {
+	corr := real - pred
+	if corr < c.corrMin {
+		corr += int32(c.corrRange)
+	} else if corr > c.corrMax {
+		corr -= int32(c.corrRange)
+	}
+	c.writeCorrector(e, corr, c.mBits[context])
+}
+
+func (c *integerCoder) writeCorrector(e *encoder, corr int32, m *symbolModel) {
+	var c1 uint32
+	if corr <= 0 {
+		c1 = uint32(-corr)
+	} else {
+		c1 = uint32(corr - 1)
+	}
+	c.k = 0
+	for c1 != 0 {
+		c1 >>= 1
+		c.k++
+	}
+	e.encodeSymbol(m, c.k)
+	if c.k == 0 {
+		e.encodeBit(c.mBit0, uint32(corr))
+		return
+	}
+	if c.k >= 32 {
+		return
+	}
+	if corr < 0 {
+		corr += (1 << c.k) - 1
+	} else {
+		corr--
+	}
+	if c.k <= c.bitsHigh {
+		e.encodeSymbol(c.mCorr[c.k], uint32(corr))
+		return
+	}
+	k1 := c.k - c.bitsHigh
+	low := uint32(corr) & ((1 << k1) - 1)
+	e.encodeSymbol(c.mCorr[c.k], uint32(corr)>>k1)
+	e.writeBits(k1, low)
+}
+
+func foldDiff(a, b int32) uint32 {
+	return uint32(u8Fold(a - b))
+}
+
+// pointwise item encoders
+
+type itemEncoder interface {
+	init(item []byte)
+	write(item []byte)
+}
+
+type point10Encoder struct {
+	point10
+	enc *encoder
+}
+
+func (p *point10Encoder) write(item []byte) {
+	e := p.enc
+	last := p.last[:]
+	r := item[14] & 0x7
+	n := (item[14] >> 3) & 0x7
+	m := numberReturnMap[n][r]
+	l := numberReturnLevel[n][r]
+	intensity := binary.LittleEndian.Uint16(item[12:])
+	changed := uint32(0)
+	if last[14] != item[14] {
+		changed |= 32
+	}
+	if p.lastIntensity[m] != intensity {
+		changed |= 16
+	}
+	if last[15] != item[15] {
+		changed |= 8
+	}
+	if last[16] != item[16] {
+		changed |= 4
+	}
+	if last[17] != item[17] {
+		changed |= 2
+	}
+	if !bytes.Equal(last[18:20], item[18:20]) {
+		changed |= 1
+	}
+	e.encodeSymbol(p.changedValues, changed)
+	if changed&32 != 0 {
+		p.bitByte[last[14]] = lazyModel(p.bitByte[last[14]], 256)
+		e.encodeSymbol(p.bitByte[last[14]], uint32(item[14]))
+	}
+	if changed&16 != 0 {
+		ctx := uint32(m)
+		if ctx > 3 {
+			ctx = 3
+		}
+		p.icIntensity.compress(e, int32(p.lastIntensity[m]), int32(intensity), ctx)
+		p.lastIntensity[m] = intensity
+	}
+	if changed&8 != 0 {
+		p.classification[last[15]] = lazyModel(p.classification[last[15]], 256)
+		e.encodeSymbol(p.classification[last[15]], uint32(item[15]))
+	}
+	if changed&4 != 0 {
+		e.encodeSymbol(p.scanAngleRank[(item[14]>>6)&1], foldDiff(int32(item[16]), int32(last[16])))
+	}
+	if changed&2 != 0 {
+		p.userData[last[17]] = lazyModel(p.userData[last[17]], 256)
+		e.encodeSymbol(p.userData[last[17]], uint32(item[17]))
+	}
+	if changed&1 != 0 {
+		p.icPointSource.compress(e, int32(binary.LittleEndian.Uint16(last[18:])), int32(binary.LittleEndian.Uint16(item[18:])), 0)
+	}
+	oneReturn := uint32(0)
+	if n == 1 {
+		oneReturn = 1
+	}
+	diff := getInt32(item[0:]) - getInt32(last[0:])
+	p.icDx.compress(e, p.lastXDiff[m].get(), diff, oneReturn)
+	p.lastXDiff[m].add(diff)
+	kBits := p.icDx.getK()
+	diff = getInt32(item[4:]) - getInt32(last[4:])
+	p.icDy.compress(e, p.lastYDiff[m].get(), diff, oneReturn+minContext(kBits, 20))
+	p.lastYDiff[m].add(diff)
+	kBits = (p.icDx.getK() + p.icDy.getK()) / 2
+	p.icZ.compress(e, p.lastHeight[l], getInt32(item[8:]), oneReturn+minContext(kBits, 18))
+	p.lastHeight[l] = getInt32(item[8:])
+	copy(last, item)
+}
+
+type gpsTimeEncoder struct {
+	gpsTimeState
+	enc *encoder
+}
+
+func (g *gpsTimeEncoder) init(item []byte) {
+	g.gpsTimeState = newGPSTimeState(binary.LittleEndian.Uint64(item), gpsTimeMultiTotal, 6)
+}
+
+func quantize(f float32) int32 {
+	if f >= 0 {
+		return int32(f + 0.5)
+	}
+	return int32(f - 0.5)
+}
+
+func fitsInt32(v int64) bool {
+	return v == int64(int32(v))
+}
+
+// writeMulti encodes a GPS time difference as a multiple of the last difference, returning false if the
+// difference does not fit into 32 bits
+func (g *gpsTimeState) writeMulti(e *encoder, diff64 int64) bool {
+	if !fitsInt32(diff64) {
+		return false
+	}
+	diff := int32(diff64)
+	multi := quantize(float32(diff) / float32(g.lastGPSTimeDiff[g.last]))
+	last := g.lastGPSTimeDiff[g.last]
+	switch {
+	case multi == 1:
+		e.encodeSymbol(g.mMulti, 1)
+		g.ic.compress(e, last, diff, 1)
+		g.multiExtremeCounter[g.last] = 0
+	case multi > 0 && multi < gpsTimeMulti:
+		e.encodeSymbol(g.mMulti, uint32(multi))
+		ctx := uint32(2)
+		if multi >= 10 {
+			ctx = 3
+		}
+		g.ic.compress(e, multi*last, diff, ctx)
+	case multi > 0:
+		e.encodeSymbol(g.mMulti, gpsTimeMulti)
+		g.ic.compress(e, gpsTimeMulti*last, diff, 4)
+		g.countExtreme(diff)
+	case multi < 0 && multi > gpsTimeMultiMinus:
+		e.encodeSymbol(g.mMulti, uint32(gpsTimeMulti-multi))
+		g.ic.compress(e, multi*last, diff, 5)
+	case multi < 0:
+		e.encodeSymbol(g.mMulti, gpsTimeMulti-gpsTimeMultiMinus)
+		g.ic.compress(e, gpsTimeMultiMinus*last, diff, 6)
+		g.countExtreme(diff)
+	default:
+		e.encodeSymbol(g.mMulti, 0)
+		g.ic.compress(e, 0, diff, 7)
+		g.countExtreme(diff)
+	}
+	return true
+}
+
+func (g *gpsTimeState) writeFull(e *encoder, t uint64) {
+	g.ic.compress(e, int32(g.lastGPSTime[g.last]>>32), int32(t>>32), 8)
+	e.writeInt(uint32(t))
+	g.next = (g.next + 1) & 3
+	g.last = g.next
+	g.lastGPSTimeDiff[g.last] = 0
+	g.multiExtremeCounter[g.last] = 0
+}
+
+// otherSequence returns the offset of another sequence the GPS time can be predicted from, or 0
+func (g *gpsTimeState) otherSequence(t uint64) uint32 {
+	for i := uint32(1); i < 4; i++ {
+		if fitsInt32(int64(t) - int64(g.lastGPSTime[(g.last+i)&3])) {
+			return i
+		}
+	}
+	return 0
+}
+
+func (g *gpsTimeEncoder) write(item []byte) {
+	e := g.enc
+	t := binary.LittleEndian.Uint64(item)
+	for {
+		diff64 := int64(t) - int64(g.lastGPSTime[g.last])
+		if g.lastGPSTimeDiff[g.last] == 0 {
+			if diff64 == 0 {
+				e.encodeSymbol(g.m0Diff, 0)
+				return
+			}
+			if fitsInt32(diff64) {
+				e.encodeSymbol(g.m0Diff, 1)
+				g.ic.compress(e, 0, int32(diff64), 0)
+				g.lastGPSTimeDiff[g.last] = int32(diff64)
+				g.multiExtremeCounter[g.last] = 0
+			} else if i := g.otherSequence(t); i > 0 {
+				e.encodeSymbol(g.m0Diff, i+2)
+				g.last = (g.last + i) & 3
+				continue
+			} else {
+				e.encodeSymbol(g.m0Diff, 2)
+				g.writeFull(e, t)
+			}
+		} else {
+			if diff64 == 0 {
+				e.encodeSymbol(g.mMulti, gpsTimeMultiUnchanged)
+				return
+			}
+			if !g.writeMulti(e, diff64) {
+				if i := g.otherSequence(t); i > 0 {
+					e.encodeSymbol(g.mMulti, gpsTimeMultiFull+i)
+					g.last = (g.last + i) & 3
+					continue
+				}
+				e.encodeSymbol(g.mMulti, gpsTimeMultiFull)
+				g.writeFull(e, t)
+			}
+		}
+		g.lastGPSTime[g.last] = t
+		return
+	}
+}
+
+func (g *gpsTimeState) write14(e *encoder, t uint64) {
+	for {
+		diff64 := int64(t) - int64(g.lastGPSTime[g.last])
+		if g.lastGPSTimeDiff[g.last] == 0 {
+			if fitsInt32(diff64) {
+				e.encodeSymbol(g.m0Diff, 0)
+				g.ic.compress(e, 0, int32(diff64), 0)
+				g.lastGPSTimeDiff[g.last] = int32(diff64)
+				g.multiExtremeCounter[g.last] = 0
+			} else if i := g.otherSequence(t); i > 0 {
+				e.encodeSymbol(g.m0Diff, i+1)
+				g.last = (g.last + i) & 3
+				continue
+			} else {
+				e.encodeSymbol(g.m0Diff, 1)
+				g.writeFull(e, t)
+			}
+		} else if !g.writeMulti(e, diff64) {
+			if i := g.otherSequence(t); i > 0 {
+				e.encodeSymbol(g.mMulti, gpsTimeMultiFull14+i)
+				g.last = (g.last + i) & 3
+				continue
+			}
+			e.encodeSymbol(g.mMulti, gpsTimeMultiFull14)
+			g.writeFull(e, t)
+		}
+		g.lastGPSTime[g.last] = t
+		return
+	}
+}
+
+type rgbEncoder struct {
+	rgbModels
+	enc  *encoder
+	last [3]uint16
+}
+
+func (c *rgbEncoder) init(item []byte) {
+	c.rgbModels = newRGBModels()
+	c.last = readRGB(item)
+}
+
+func (c *rgbEncoder) write(item []byte) {
+	cur := readRGB(item)
+	c.rgbModels.write(c.enc, c.last, cur)
+	c.last = cur
+}
+
+func (m *rgbModels) write(e *encoder, last, cur [3]uint16) {
+	sym := uint32(0)
+	for i := 0; i < 3; i++ {
+		if last[i]&0xFF != cur[i]&0xFF {
+			sym |= 1 << (2 * i)
+		}
+		if last[i]&0xFF00 != cur[i]&0xFF00 {
+			sym |= 1 << (2*i + 1)
+		}
+	}
+	if cur[0]&0xFF != cur[1]&0xFF || cur[0]&0xFF != cur[2]&0xFF || cur[0]&0xFF00 != cur[1]&0xFF00 || cur[0]&0xFF00 != cur[2]&0xFF00 {
+		sym |= 1 << 6
+	}
+	e.encodeSymbol(m.byteUsed, sym)
+	var diffL, diffH int32
+	if sym&(1<<0) != 0 {
+		diffL = int32(cur[0]&0xFF) - int32(last[0]&0xFF)
+		e.encodeSymbol(m.diff[0], uint32(u8Fold(diffL)))
+	}
+	if sym&(1<<1) != 0 {
+		diffH = int32(cur[0]>>8) - int32(last[0]>>8)
+		e.encodeSymbol(m.diff[1], uint32(u8Fold(diffH)))
+	}
+	if sym&(1<<6) == 0 {
+		return
+	}
+	if sym&(1<<2) != 0 {
+		e.encodeSymbol(m.diff[2], foldDiff(int32(cur[1]&0xFF), u8Clamp(diffL+int32(last[1]&0xFF))))
+	}
+	if sym&(1<<4) != 0 {
+		diffL = (diffL + int32(cur[1]&0xFF) - int32(last[1]&0xFF)) / 2
+		e.encodeSymbol(m.diff[4], foldDiff(int32(cur[2]&0xFF), u8Clamp(diffL+int32(last[2]&0xFF))))
+	}
+	if sym&(1<<3) != 0 {
+		e.encodeSymbol(m.diff[3], foldDiff(int32(cur[1]>>8), u8Clamp(diffH+int32(last[1]>>8))))
+	}
+	if sym&(1<<5) != 0 {
+		diffH = (diffH + int32(cur[1]>>8) - int32(last[1]>>8)) / 2
+		e.encodeSymbol(m.diff[5], foldDiff(int32(cur[2]>>8), u8Clamp(diffH+int32(last[2]>>8))))
+	}
+}
+
+type byteEncoder struct {
+	byte10
+	enc *encoder
+}
+
+func (b *byteEncoder) write(item []byte) {
+	for i := range b.last {
+		b.enc.encodeSymbol(b.models[i], foldDiff(int32(item[i]), int32(b.last[i])))
+	}
+	copy(b.last, item)
+}
+
+// layered item encoders
+
+type layeredEncoder interface {
+	init(item []byte, context *uint32)
+	write(item []byte, context *uint32)
+	// layers returns the data of each layer, empty if the layer has never changed
+	layers() [][]byte
+}
+
+type layerEncoder struct {
+	enc     *encoder
+	changed bool
+}
+
+func newLayerEncoders(n int) []layerEncoder {
+	l := make([]layerEncoder, n)
+	for i := range l {
+		l[i].enc = newEncoder()
+	}
+	return l
+}
+
+func layersData(layers []layerEncoder, mandatory int) [][]byte {
+	out := make([][]byte, len(layers))
+	for i := range layers {
+		if layers[i].changed || i == mandatory {
+			out[i] = layers[i].enc.done()
+		}
+	}
+	return out
+}
+
+type point14Encoder struct {
+	l        []layerEncoder
+	contexts [4]*point14Context
+	current  uint32
+}
+
+func (p *point14Encoder) init(item []byte, context *uint32) {
+	p.l = newLayerEncoders(point14Layers)
+	pt := unpackPoint14(item)
+	p.contexts = [4]*point14Context{}
+	p.current = uint32(pt.scannerChannel)
+	*context = p.current
+	p.contexts[p.current] = newPoint14Context(pt)
+}
+
+func (p *point14Encoder) layers() [][]byte {
+	return layersData(p.l, layerChannelReturnsXY)
+}
+
+func (p *point14Encoder) write(item []byte, context *uint32) {
+	pt := unpackPoint14(item)
+	c := p.contexts[p.current]
+	e := p.l[layerChannelReturnsXY].enc
+	lpr := 0
+	if c.last.returnNumber == 1 {
+		lpr++
+	}
+	if c.last.returnNumber >= c.last.numberOfReturns {
+		lpr += 2
+	}
+	if c.last.gpsTimeChange {
+		lpr += 4
+	}
+	channel := uint32(pt.scannerChannel)
+	last := &c.last
+	if channel != p.current && p.contexts[channel] != nil {
+		last = &p.contexts[channel].last
+	}
+	pointSourceChange := pt.pointSourceID != last.pointSourceID
+	gpsTimeChange := pt.gpsTime != last.gpsTime
+	scanAngleChange := pt.scanAngle != last.scanAngle
+	lastN, lastR := last.numberOfReturns, last.returnNumber
+	n, r := pt.numberOfReturns, pt.returnNumber
+	changed := uint32(0)
+	if channel != p.current {
+		changed |= 1 << 6
+	}
+	if pointSourceChange {
+		changed |= 1 << 5
+	}
+	if gpsTimeChange {
+		changed |= 1 << 4
+	}
+	if scanAngleChange {
+		changed |= 1 << 3
+	}
+	if n != lastN {
+		changed |= 1 << 2
+	}
+	if r != lastR {
+		if r == (lastR+1)%16 {
+			changed |= 1
+		} else if r == (lastR+15)%16 {
+			changed |= 2
+		} else {
+			changed |= 3
+		}
+	}
+	e.encodeSymbol(c.changedValues[lpr], changed)
+	if changed&(1<<6) != 0 {
+		diff := int32(channel) - int32(p.current)
+		if diff > 0 {
+			e.encodeSymbol(c.scannerChannel, uint32(diff-1))
+		} else {
+			e.encodeSymbol(c.scannerChannel, uint32(diff-1+4))
+		}
+		if p.contexts[channel] == nil {
+			p.contexts[channel] = newPoint14Context(c.last)
+		}
+		p.current = channel
+		*context = channel
+		c = p.contexts[channel]
+		last = &c.last
+	}
+	gpsIdx := uint32(0)
+	if gpsTimeChange {
+		gpsIdx = 1
+	}
+	if changed&(1<<2) != 0 {
+		c.numberOfReturns[lastN] = lazyModel(c.numberOfReturns[lastN], 16)
+		e.encodeSymbol(c.numberOfReturns[lastN], uint32(n))
+	}
+	if changed&3 == 3 {
+		if gpsTimeChange {
+			c.returnNumber[lastR] = lazyModel(c.returnNumber[lastR], 16)
+			e.encodeSymbol(c.returnNumber[lastR], uint32(r))
+		} else {
+			diff := int32(r) - int32(lastR)
+			if diff > 1 {
+				e.encodeSymbol(c.returnNumberGPSSame, uint32(diff-2))
+			} else {
+				e.encodeSymbol(c.returnNumberGPSSame, uint32(diff+16-2))
+			}
+		}
+	}
+	m := uint32(numberReturnMap6Ctx[n][r])
+	l := numberReturnLevel8Ctx(n, r)
+	cpr := uint32(0)
+	if r == 1 {
+		cpr += 2
+	}
+	if r >= n {
+		cpr++
+	}
+	oneReturn := uint32(0)
+	if n == 1 {
+		oneReturn = 1
+	}
+	idx := (m << 1) | gpsIdx
+	diff := pt.x - last.x
+	c.icDx.compress(e, c.lastXDiff[idx].get(), diff, oneReturn)
+	c.lastXDiff[idx].add(diff)
+	kBits := c.icDx.getK()
+	diff = pt.y - last.y
+	c.icDy.compress(e, c.lastYDiff[idx].get(), diff, oneReturn+minContext(kBits, 20))
+	c.lastYDiff[idx].add(diff)
+
+	if pt.z != last.z {
+		p.l[layerZ].changed = true
+	}
+	kBits = (c.icDx.getK() + c.icDy.getK()) / 2
+	c.icZ.compress(p.l[layerZ].enc, c.lastZ[l], pt.z, oneReturn+minContext(kBits, 18))
+	c.lastZ[l] = pt.z
+
+	if pt.classification != last.classification {
+		p.l[layerClassification].changed = true
+	}
+	ccc := uint32(last.classification&0x1F) << 1
+	if cpr == 3 {
+		ccc++
+	}
+	c.classification[ccc] = lazyModel(c.classification[ccc], 256)
+	p.l[layerClassification].enc.encodeSymbol(c.classification[ccc], uint32(pt.classification))
+
+	lastFlags := last.flags()
+	if pt.flags() != lastFlags {
+		p.l[layerFlags].changed = true
+	}
+	c.flags[lastFlags] = lazyModel(c.flags[lastFlags], 64)
+	p.l[layerFlags].enc.encodeSymbol(c.flags[lastFlags], uint32(pt.flags()))
+
+	if pt.intensity != last.intensity {
+		p.l[layerIntensity].changed = true
+	}
+	iidx := (cpr << 1) | gpsIdx
+	c.icIntensity.compress(p.l[layerIntensity].enc, int32(c.lastIntensity[iidx]), int32(pt.intensity), cpr)
+	c.lastIntensity[iidx] = pt.intensity
+
+	if scanAngleChange {
+		p.l[layerScanAngle].changed = true
+		c.icScanAngle.compress(p.l[layerScanAngle].enc, int32(last.scanAngle), int32(pt.scanAngle), gpsIdx)
+	}
+
+	if pt.userData != last.userData {
+		p.l[layerUserData].changed = true
+	}
+	uctx := last.userData / 4
+	c.userData[uctx] = lazyModel(c.userData[uctx], 256)
+	p.l[layerUserData].enc.encodeSymbol(c.userData[uctx], uint32(pt.userData))
+
+	if pointSourceChange {
+		p.l[layerPointSource].changed = true
+		c.icPointSource.compress(p.l[layerPointSource].enc, int32(last.pointSourceID), int32(pt.pointSourceID), 0)
+	}
+
+	if gpsTimeChange {
+		p.l[layerGPSTime].changed = true
+		c.gps.write14(p.l[layerGPSTime].enc, pt.gpsTime)
+	}
+
+	*last = pt
+	last.gpsTimeChange = gpsTimeChange
+}
+
+type rgb14Encoder struct {
+	nir      bool
+	l        []layerEncoder
+	contexts [4]*rgb14Context
+	current  uint32
+	dec      rgb14
+}
+
+func (c *rgb14Encoder) init(item []byte, context *uint32) {
+	c.dec.nir = c.nir
+	c.l = newLayerEncoders(c.dec.numLayers())
+	c.contexts = [4]*rgb14Context{}
+	c.current = *context
+	c.contexts[c.current] = newRGB14Context(c.dec.unpack(item))
+}
+
+func (c *rgb14Encoder) layers() [][]byte {
+	return layersData(c.l, -1)
+}
+
+func (c *rgb14Encoder) write(item []byte, context *uint32) {
+	ctx := c.contexts[c.current]
+	if c.current != *context {
+		c.current = *context
+		if c.contexts[c.current] == nil {
+			c.contexts[c.current] = newRGB14Context(ctx.last)
+		}
+		ctx = c.contexts[c.current]
+	}
+	cur := c.dec.unpack(item)
+	lastRGB := [3]uint16{ctx.last[0], ctx.last[1], ctx.last[2]}
+	curRGB := [3]uint16{cur[0], cur[1], cur[2]}
+	if lastRGB != curRGB {
+		c.l[0].changed = true
+	}
+	ctx.rgbModels.write(c.l[0].enc, lastRGB, curRGB)
+	if c.nir {
+		e := c.l[1].enc
+		last := ctx.last[3]
+		if last != cur[3] {
+			c.l[1].changed = true
+		}
+		sym := uint32(0)
+		if last&0xFF != cur[3]&0xFF {
+			sym |= 1
+		}
+		if last&0xFF00 != cur[3]&0xFF00 {
+			sym |= 2
+		}
+		e.encodeSymbol(ctx.nirUsed, sym)
+		if sym&1 != 0 {
+			e.encodeSymbol(ctx.nirDiff[0], foldDiff(int32(cur[3]&0xFF), int32(last&0xFF)))
+		}
+		if sym&2 != 0 {
+			e.encodeSymbol(ctx.nirDiff[1], foldDiff(int32(cur[3]>>8), int32(last>>8)))
+		}
+	}
+	ctx.last = cur
+}
+
+type byte14Encoder struct {
+	size     int
+	l        []layerEncoder
+	contexts [4]*byte14Context
+	current  uint32
+}
+
+func (b *byte14Encoder) init(item []byte, context *uint32) {
+	b.l = newLayerEncoders(b.size)
+	b.contexts = [4]*byte14Context{}
+	b.current = *context
+	b.contexts[b.current] = newByte14Context(item)
+}
+
+func (b *byte14Encoder) layers() [][]byte {
+	return layersData(b.l, -1)
+}
+
+func (b *byte14Encoder) write(item []byte, context *uint32) {
+	ctx := b.contexts[b.current]
+	if b.current != *context {
+		b.current = *context
+		if b.contexts[b.current] == nil {
+			b.contexts[b.current] = newByte14Context(ctx.last)
+		}
+		ctx = b.contexts[b.current]
+	}
+	for i := range b.l {
+		if item[i] != ctx.last[i] {
+			b.l[i].changed = true
+		}
+		b.l[i].enc.encodeSymbol(ctx.models[i], foldDiff(int32(item[i]), int32(ctx.last[i])))
+	}
+	copy(ctx.last, item)
+}
+
+// compressPoints compresses the given raw records and returns the compressed point data, chunk table included.
+// The chunk table offset is computed assuming that the point data starts at the given offset.
+func compressPoints(vlr *VLR, records [][]byte, pointDataOffset int64) []byte {
+	out := &bytes.Buffer{}
+	if vlr.Compressor != CompressorPointwise {
+		out.Write(make([]byte, 8))
+	}
+	var chunkBytes []int32
+	var chunkPoints []int32
+	for start := 0; start < len(records); {
+		// variable size chunks alternate between 100 and 37 points
+		chunkSize := int(vlr.ChunkSize)
+		if vlr.Compressor == CompressorPointwise {
+			chunkSize = len(records)
+		} else if vlr.ChunkSize == VariableChunkSize {
+			chunkSize = 100 - 63*(len(chunkBytes)%2)
+		}
+		end := start + chunkSize
+		if end > len(records) {
+			end = len(records)
+		}
+		before := out.Len()
+		compressChunk(vlr, records[start:end], out)
+		chunkBytes = append(chunkBytes, int32(out.Len()-before))
+		chunkPoints = append(chunkPoints, int32(end-start))
+		start = end
+	}
+	if vlr.Compressor == CompressorPointwise {
+		return out.Bytes()
+	}
+	data := out.Bytes()
+	binary.LittleEndian.PutUint64(data, uint64(pointDataOffset+int64(len(data))))
+	binary.Write(out, binary.LittleEndian, [2]uint32{0, uint32(len(chunkBytes))})
+	e := newEncoder()
+	ic := newIntegerCoder(32, 2)
+	for i := range chunkBytes {
+		if vlr.ChunkSize == VariableChunkSize {
+			pred := int32(0)
+			if i > 0 {
+				pred = chunkPoints[i-1]
+			}
+			ic.compress(e, pred, chunkPoints[i], 0)
+		}
+		pred := int32(0)
+		if i > 0 {
+			pred = chunkBytes[i-1]
+		}
+		ic.compress(e, pred, chunkBytes[i], 1)
+	}
+	out.Write(e.done())
+	return out.Bytes()
+}
+
+func splitItems(vlr *VLR, record []byte) [][]byte {
+	items := make([][]byte, len(vlr.Items))
+	pos := 0
+	for i, it := range vlr.Items {
+		items[i] = record[pos : pos+int(it.Size)]
+		pos += int(it.Size)
+	}
+	return items
+}
+
+func compressChunk(vlr *VLR, records [][]byte, out *bytes.Buffer) {
+	out.Write(records[0])
+	if vlr.Compressor != CompressorLayeredChunked {
+		e := newEncoder()
+		encs := make([]itemEncoder, len(vlr.Items))
+		for i, it := range vlr.Items {
+			switch it.Type {
+			case ItemPoint10:
+				encs[i] = &point10Encoder{enc: e}
+			case ItemGPSTime11:
+				encs[i] = &gpsTimeEncoder{enc: e}
+			case ItemRGB12:
+				encs[i] = &rgbEncoder{enc: e}
+			default:
+				encs[i] = &byteEncoder{enc: e}
+			}
+		}
+		for i, item := range splitItems(vlr, records[0]) {
+			encs[i].init(item)
+		}
+		for _, rec := range records[1:] {
+			for i, item := range splitItems(vlr, rec) {
+				encs[i].write(item)
+			}
+		}
+		out.Write(e.done())
+		return
+	}
+	encs := make([]layeredEncoder, len(vlr.Items))
+	for i, it := range vlr.Items {
+		switch it.Type {
+		case ItemPoint14:
+			encs[i] = &point14Encoder{}
+		case ItemRGB14:
+			encs[i] = &rgb14Encoder{}
+		case ItemRGBNIR14:
+			encs[i] = &rgb14Encoder{nir: true}
+		default:
+			encs[i] = &byte14Encoder{size: int(it.Size)}
+		}
+	}
+	var context uint32
+	for i, item := range splitItems(vlr, records[0]) {
+		encs[i].init(item, &context)
+	}
+	for _, rec := range records[1:] {
+		for i, item := range splitItems(vlr, rec) {
+			encs[i].write(item, &context)
+		}
+	}
+	binary.Write(out, binary.LittleEndian, uint32(len(records)))
+	var layers [][]byte
+	for _, enc := range encs {
+		layers = append(layers, enc.layers()...)
+	}
+	for _, l := range layers {
+		binary.Write(out, binary.LittleEndian, uint32(len(l)))
+	}
+	for _, l := range layers {
+		out.Write(l)
+	}
+}
+
+// newTestVLR returns the LASzip VLR describing the given point format and number of extra bytes
+func newTestVLR(format uint8, extraBytes int, compressor uint16, chunkSize uint32) *VLR {
+	v := &VLR{Compressor: compressor, VersionMajor: 3, VersionMinor: 4, ChunkSize: chunkSize}
+	if format < 6 {
+		v.Items = append(v.Items, Item{ItemPoint10, 20, 2})
+		if format == 1 || format == 3 {
+			v.Items = append(v.Items, Item{ItemGPSTime11, 8, 2})
+		}
+		if format == 2 || format == 3 {
+			v.Items = append(v.Items, Item{ItemRGB12, 6, 2})
+		}
+		if extraBytes > 0 {
+			v.Items = append(v.Items, Item{ItemByte, uint16(extraBytes), 2})
+		}
+		return v
+	}
+	v.Items = append(v.Items, Item{ItemPoint14, 30, 3})
+	if format == 7 {
+		v.Items = append(v.Items, Item{ItemRGB14, 6, 3})
+	}
+	if format == 8 {
+		v.Items = append(v.Items, Item{ItemRGBNIR14, 8, 3})
+	}
+	if extraBytes > 0 {
+		v.Items = append(v.Items, Item{ItemByte14, uint16(extraBytes), 3})
+	}
+	return v
+}
+
+// vlrPayload serializes the LASzip VLR
+func vlrPayload(v *VLR) []byte {
+	out := &bytes.Buffer{}
+	for _, f := range []any{
+		v.Compressor, v.Coder, v.VersionMajor, v.VersionMinor, v.VersionRevision, v.Options,
+		v.ChunkSize, v.NumberOfSpecialEVLRs, v.OffsetToSpecialEVLRs, uint16(len(v.Items)), v.Items,
+	} {
+		binary.Write(out, binary.LittleEndian, f)
+	}
+	return out.Bytes()
+}
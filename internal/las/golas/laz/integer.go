@@ -0,0 +1,176 @@
+package laz
+
+import "math"
+
+// integerCoder models the correctors between predicted and actual integer values, as done
+// by the LASzip IntegerCompressor. The same models are used for compression and decompression.
+type integerCoder struct {
+	bits      uint32
+	bitsHigh  uint32
+	corrRange uint32
+	corrMin   int32
+	corrMax   int32
+	k         uint32
+	mBits     []*symbolModel
+	mBit0     *bitModel
+	mCorr     []*symbolModel
+}
+
+// newIntegerCoder returns an initialized integerCoder for values of the given number of bits
+// and the given number of contexts
+func newIntegerCoder(bits, contexts uint32) *integerCoder {
+	c := &integerCoder{
+		bitsHigh: 8,
+	}
+	if bits > 0 && bits < 32 {
+		c.bits = bits
+		c.corrRange = 1 << bits
+		c.corrMin = -int32(c.corrRange / 2)
+		c.corrMax = c.corrMin + int32(c.corrRange-1)
+	} else {
+		c.bits = 32
+		c.corrRange = 0
+		c.corrMin = math.MinInt32
+		c.corrMax = math.MaxInt32
+	}
+	c.mBits = make([]*symbolModel, contexts)
+	for i := range c.mBits {
+		c.mBits[i] = newSymbolModel(c.bits + 1)
+	}
+	c.mBit0 = newBitModel()
+	c.mCorr = make([]*symbolModel, c.bits+1)
+	for i := uint32(1); i <= c.bits; i++ {
+		if i <= c.bitsHigh {
+			c.mCorr[i] = newSymbolModel(1 << i)
+		} else {
+			c.mCorr[i] = newSymbolModel(1 << c.bitsHigh)
+		}
+	}
+	return c
+}
+
+// getK returns the number of bits of the last processed corrector
+func (c *integerCoder) getK() uint32 {
+	return c.k
+}
+
+// decompress decodes a value given its prediction and the context to use
+func (c *integerCoder) decompress(d *decoder, pred int32, context uint32) int32 {
+	real := pred + c.readCorrector(d, c.mBits[context])
+	if real < 0 {
+		real += int32(c.corrRange)
+	} else if uint32(real) >= c.corrRange {
+		real -= int32(c.corrRange)
+	}
+	return real
+}
+
+func (c *integerCoder) readCorrector(d *decoder, m *symbolModel) int32 {
+	c.k = d.decodeSymbol(m)
+	if c.k == 0 {
+		return int32(d.decodeBit(c.mBit0))
+	}
+	if c.k >= 32 {
+		return c.corrMin
+	}
+	var corr int32
+	if c.k <= c.bitsHigh {
+		corr = int32(d.decodeSymbol(c.mCorr[c.k]))
+	} else {
+		k1 := c.k - c.bitsHigh
+		corr = int32(d.decodeSymbol(c.mCorr[c.k]))
+		corr = (corr << k1) | int32(d.readBits(k1))
+	}
+	if corr >= 1<<(c.k-1) {
+		return corr + 1
+	}
+	return corr - ((1 << c.k) - 1)
+}
+
+// streamingMedian5 keeps track of the median of the last five values added to it
+type streamingMedian5 struct {
+	values [5]int32
+	high   bool
+}
+
+func newStreamingMedian5() streamingMedian5 {
+	return streamingMedian5{high: true}
+}
+
+func (s *streamingMedian5) add(v int32) {
+	if s.high {
+		if v < s.values[2] {
+			s.values[4] = s.values[3]
+			s.values[3] = s.values[2]
+			if v < s.values[0] {
+				s.values[2] = s.values[1]
+				s.values[1] = s.values[0]
+				s.values[0] = v
+			} else if v < s.values[1] {
+				s.values[2] = s.values[1]
+				s.values[1] = v
+			} else {
+				s.values[2] = v
+			}
+		} else {
+			if v < s.values[3] {
+				s.values[4] = s.values[3]
+				s.values[3] = v
+			} else {
+				s.values[4] = v
+			}
+			s.high = false
+		}
+	} else {
+		if s.values[2] < v {
+			s.values[0] = s.values[1]
+			s.values[1] = s.values[2]
+			if s.values[4] < v {
+				s.values[2] = s.values[3]
+				s.values[3] = s.values[4]
+				s.values[4] = v
+			} else if s.values[3] < v {
+				s.values[2] = s.values[3]
+				s.values[3] = v
+			} else {
+				s.values[2] = v
+			}
+		} else {
+			if s.values[1] < v {
+				s.values[0] = s.values[1]
+				s.values[1] = v
+			} else {
+				s.values[0] = v
+			}
+			s.high = true
+		}
+	}
+}
+
+func (s *streamingMedian5) get() int32 {
+	return s.values[2]
+}
+
+func u8Fold(n int32) uint8 {
+	if n < 0 {
+		return uint8(n + 256)
+	}
+	if n > 255 {
+		return uint8(n - 256)
+	}
+	return uint8(n)
+}
+
+func u8Clamp(n int32) int32 {
+	if n <= 0 {
+		return 0
+	}
+	if n >= 255 {
+		return 255
+	}
+	return n
+}
+
+func zeroBit0(n uint32) uint32 {
+	return n &^ 1
+}
@@ -0,0 +1,402 @@
+package laz
+
+import "encoding/binary"
+
+// pointwiseItem decodes one item of a point record compressed with the pointwise (v2) scheme.
+// All the items of a record share the same arithmetic decoder.
+type pointwiseItem interface {
+	// init resets the item models using the raw item that starts the chunk
+	init(item []byte)
+	// read decodes the next item into the given slice
+	read(item []byte)
+}
+
+var numberReturnMap = [8][8]uint8{
+	{15, 14, 13, 12, 11, 10, 9, 8},
+	{14, 0, 1, 3, 6, 10, 10, 9},
+	{13, 1, 2, 4, 7, 11, 11, 10},
+	{12, 3, 4, 5, 8, 12, 12, 11},
+	{11, 6, 7, 8, 9, 13, 13, 12},
+	{10, 10, 11, 12, 13, 14, 14, 13},
+	{9, 10, 11, 12, 13, 14, 15, 14},
+	{8, 9, 10, 11, 12, 13, 14, 15},
+}
+
+var numberReturnLevel = [8][8]uint8{
+	{0, 1, 2, 3, 4, 5, 6, 7},
+	{1, 0, 1, 2, 3, 4, 5, 6},
+	{2, 1, 0, 1, 2, 3, 4, 5},
+	{3, 2, 1, 0, 1, 2, 3, 4},
+	{4, 3, 2, 1, 0, 1, 2, 3},
+	{5, 4, 3, 2, 1, 0, 1, 2},
+	{6, 5, 4, 3, 2, 1, 0, 1},
+	{7, 6, 5, 4, 3, 2, 1, 0},
+}
+
+// point10 decodes the 20 bytes core of point formats 0 to 5
+type point10 struct {
+	dec            *decoder
+	last           [20]byte
+	lastIntensity  [16]uint16
+	lastXDiff      [16]streamingMedian5
+	lastYDiff      [16]streamingMedian5
+	lastHeight     [8]int32
+	changedValues  *symbolModel
+	scanAngleRank  [2]*symbolModel
+	bitByte        [256]*symbolModel
+	classification [256]*symbolModel
+	userData       [256]*symbolModel
+	icIntensity    *integerCoder
+	icPointSource  *integerCoder
+	icDx           *integerCoder
+	icDy           *integerCoder
+	icZ            *integerCoder
+}
+
+func (p *point10) init(item []byte) {
+	*p = point10{dec: p.dec}
+	for i := range p.lastXDiff {
+		p.lastXDiff[i] = newStreamingMedian5()
+		p.lastYDiff[i] = newStreamingMedian5()
+	}
+	p.changedValues = newSymbolModel(64)
+	p.scanAngleRank[0] = newSymbolModel(256)
+	p.scanAngleRank[1] = newSymbolModel(256)
+	p.icIntensity = newIntegerCoder(16, 4)
+	p.icPointSource = newIntegerCoder(16, 1)
+	p.icDx = newIntegerCoder(32, 2)
+	p.icDy = newIntegerCoder(32, 22)
+	p.icZ = newIntegerCoder(32, 20)
+	copy(p.last[:], item)
+	// the intensity of the first point is not used as prediction
+	p.last[12] = 0
+	p.last[13] = 0
+}
+
+func (p *point10) read(item []byte) {
+	d := p.dec
+	last := p.last[:]
+	changed := d.decodeSymbol(p.changedValues)
+	if changed&32 != 0 {
+		p.bitByte[last[14]] = lazyModel(p.bitByte[last[14]], 256)
+		last[14] = uint8(d.decodeSymbol(p.bitByte[last[14]]))
+	}
+	r := last[14] & 0x7
+	n := (last[14] >> 3) & 0x7
+	m := numberReturnMap[n][r]
+	l := numberReturnLevel[n][r]
+	if changed&16 != 0 {
+		ctx := uint32(m)
+		if ctx > 3 {
+			ctx = 3
+		}
+		intensity := uint16(p.icIntensity.decompress(d, int32(p.lastIntensity[m]), ctx))
+		p.lastIntensity[m] = intensity
+		binary.LittleEndian.PutUint16(last[12:], intensity)
+	} else if changed != 0 {
+		binary.LittleEndian.PutUint16(last[12:], p.lastIntensity[m])
+	}
+	if changed&8 != 0 {
+		p.classification[last[15]] = lazyModel(p.classification[last[15]], 256)
+		last[15] = uint8(d.decodeSymbol(p.classification[last[15]]))
+	}
+	if changed&4 != 0 {
+		val := d.decodeSymbol(p.scanAngleRank[(last[14]>>6)&1])
+		last[16] = u8Fold(int32(val) + int32(last[16]))
+	}
+	if changed&2 != 0 {
+		p.userData[last[17]] = lazyModel(p.userData[last[17]], 256)
+		last[17] = uint8(d.decodeSymbol(p.userData[last[17]]))
+	}
+	if changed&1 != 0 {
+		psid := binary.LittleEndian.Uint16(last[18:])
+		psid = uint16(p.icPointSource.decompress(d, int32(psid), 0))
+		binary.LittleEndian.PutUint16(last[18:], psid)
+	}
+
+	oneReturn := uint32(0)
+	if n == 1 {
+		oneReturn = 1
+	}
+	// x
+	median := p.lastXDiff[m].get()
+	diff := p.icDx.decompress(d, median, oneReturn)
+	putInt32(last[0:], getInt32(last[0:])+diff)
+	p.lastXDiff[m].add(diff)
+	// y
+	median = p.lastYDiff[m].get()
+	kBits := p.icDx.getK()
+	diff = p.icDy.decompress(d, median, oneReturn+minContext(kBits, 20))
+	putInt32(last[4:], getInt32(last[4:])+diff)
+	p.lastYDiff[m].add(diff)
+	// z
+	kBits = (p.icDx.getK() + p.icDy.getK()) / 2
+	z := p.icZ.decompress(d, p.lastHeight[l], oneReturn+minContext(kBits, 18))
+	putInt32(last[8:], z)
+	p.lastHeight[l] = z
+
+	copy(item, last)
+}
+
+const (
+	gpsTimeMulti          = 500
+	gpsTimeMultiMinus     = -10
+	gpsTimeMultiUnchanged = gpsTimeMulti - gpsTimeMultiMinus + 1
+	gpsTimeMultiFull      = gpsTimeMulti - gpsTimeMultiMinus + 2
+	gpsTimeMultiTotal     = gpsTimeMulti - gpsTimeMultiMinus + 6
+	gpsTimeMultiFull14    = gpsTimeMulti - gpsTimeMultiMinus + 1
+	gpsTimeMultiTotal14   = gpsTimeMulti - gpsTimeMultiMinus + 5
+)
+
+// gpsTimeState tracks the up to four GPS time sequences used to predict GPS times
+type gpsTimeState struct {
+	last                uint32
+	next                uint32
+	lastGPSTime         [4]uint64
+	lastGPSTimeDiff     [4]int32
+	multiExtremeCounter [4]int32
+	mMulti              *symbolModel
+	m0Diff              *symbolModel
+	ic                  *integerCoder
+}
+
+func newGPSTimeState(gpsTime uint64, multiSymbols, zeroDiffSymbols uint32) gpsTimeState {
+	return gpsTimeState{
+		lastGPSTime: [4]uint64{gpsTime, 0, 0, 0},
+		mMulti:      newSymbolModel(multiSymbols),
+		m0Diff:      newSymbolModel(zeroDiffSymbols),
+		ic:          newIntegerCoder(32, 9),
+	}
+}
+
+// readMultiDiff decodes a GPS time difference predicted as multiple of the last difference.
+// It is shared by the v2 and v3 schemes as they only differ for the special symbols.
+func (g *gpsTimeState) readMultiDiff(d *decoder, multi int32) int32 {
+	var diff int32
+	switch {
+	case multi == 0:
+		diff = g.ic.decompress(d, 0, 7)
+		g.countExtreme(diff)
+	case multi < gpsTimeMulti:
+		ctx := uint32(2)
+		if multi >= 10 {
+			ctx = 3
+		}
+		diff = g.ic.decompress(d, multi*g.lastGPSTimeDiff[g.last], ctx)
+	case multi == gpsTimeMulti:
+		diff = g.ic.decompress(d, gpsTimeMulti*g.lastGPSTimeDiff[g.last], 4)
+		g.countExtreme(diff)
+	default:
+		multi = gpsTimeMulti - multi
+		if multi > gpsTimeMultiMinus {
+			diff = g.ic.decompress(d, multi*g.lastGPSTimeDiff[g.last], 5)
+		} else {
+			diff = g.ic.decompress(d, gpsTimeMultiMinus*g.lastGPSTimeDiff[g.last], 6)
+			g.countExtreme(diff)
+		}
+	}
+	return diff
+}
+
+func (g *gpsTimeState) countExtreme(diff int32) {
+	g.multiExtremeCounter[g.last]++
+	if g.multiExtremeCounter[g.last] > 3 {
+		g.lastGPSTimeDiff[g.last] = diff
+		g.multiExtremeCounter[g.last] = 0
+	}
+}
+
+// readFull decodes a GPS time that starts a new sequence
+func (g *gpsTimeState) readFull(d *decoder) {
+	g.next = (g.next + 1) & 3
+	high := uint64(g.ic.decompress(d, int32(g.lastGPSTime[g.last]>>32), 8))
+	g.lastGPSTime[g.next] = high<<32 | uint64(d.readInt())
+	g.last = g.next
+	g.lastGPSTimeDiff[g.last] = 0
+	g.multiExtremeCounter[g.last] = 0
+}
+
+// gpsTime11 decodes the GPS time of point formats 1, 3, 4 and 5
+type gpsTime11 struct {
+	dec *decoder
+	gpsTimeState
+}
+
+func (g *gpsTime11) init(item []byte) {
+	g.gpsTimeState = newGPSTimeState(binary.LittleEndian.Uint64(item), gpsTimeMultiTotal, 6)
+}
+
+func (g *gpsTime11) read(item []byte) {
+	d := g.dec
+	for {
+		if g.lastGPSTimeDiff[g.last] == 0 {
+			multi := d.decodeSymbol(g.m0Diff)
+			if multi == 1 {
+				g.lastGPSTimeDiff[g.last] = g.ic.decompress(d, 0, 0)
+				g.lastGPSTime[g.last] += uint64(int64(g.lastGPSTimeDiff[g.last]))
+				g.multiExtremeCounter[g.last] = 0
+			} else if multi == 2 {
+				g.readFull(d)
+			} else if multi > 2 {
+				g.last = (g.last + multi - 2) & 3
+				continue
+			}
+		} else {
+			multi := int32(d.decodeSymbol(g.mMulti))
+			if multi == 1 {
+				g.lastGPSTime[g.last] += uint64(int64(g.ic.decompress(d, g.lastGPSTimeDiff[g.last], 1)))
+				g.multiExtremeCounter[g.last] = 0
+			} else if multi < gpsTimeMultiUnchanged {
+				g.lastGPSTime[g.last] += uint64(int64(g.readMultiDiff(d, multi)))
+			} else if multi == gpsTimeMultiFull {
+				g.readFull(d)
+			} else if multi > gpsTimeMultiFull {
+				g.last = (g.last + uint32(multi-gpsTimeMultiFull)) & 3
+				continue
+			}
+		}
+		break
+	}
+	binary.LittleEndian.PutUint64(item, g.lastGPSTime[g.last])
+}
+
+// rgb12 decodes the RGB colors of point formats 2, 3 and 5
+type rgb12 struct {
+	dec *decoder
+	rgbModels
+	last [3]uint16
+}
+
+// rgbModels contains the models used to decode RGB colors
+type rgbModels struct {
+	byteUsed *symbolModel
+	diff     [6]*symbolModel
+}
+
+func newRGBModels() rgbModels {
+	m := rgbModels{byteUsed: newSymbolModel(128)}
+	for i := range m.diff {
+		m.diff[i] = newSymbolModel(256)
+	}
+	return m
+}
+
+func (c *rgb12) init(item []byte) {
+	c.rgbModels = newRGBModels()
+	c.last = readRGB(item)
+}
+
+func (c *rgb12) read(item []byte) {
+	c.last = c.rgbModels.read(c.dec, c.last)
+	writeRGB(item, c.last)
+}
+
+// read decodes a RGB triplet given the previous one
+func (m *rgbModels) read(d *decoder, last [3]uint16) [3]uint16 {
+	var cur [3]uint16
+	sym := d.decodeSymbol(m.byteUsed)
+	if sym&(1<<0) != 0 {
+		corr := int32(d.decodeSymbol(m.diff[0]))
+		cur[0] = uint16(u8Fold(corr + int32(last[0]&0xFF)))
+	} else {
+		cur[0] = last[0] & 0xFF
+	}
+	if sym&(1<<1) != 0 {
+		corr := int32(d.decodeSymbol(m.diff[1]))
+		cur[0] |= uint16(u8Fold(corr+int32(last[0]>>8))) << 8
+	} else {
+		cur[0] |= last[0] & 0xFF00
+	}
+	if sym&(1<<6) == 0 {
+		cur[1] = cur[0]
+		cur[2] = cur[0]
+		return cur
+	}
+	diff := int32(cur[0]&0xFF) - int32(last[0]&0xFF)
+	if sym&(1<<2) != 0 {
+		corr := int32(d.decodeSymbol(m.diff[2]))
+		cur[1] = uint16(u8Fold(corr + u8Clamp(diff+int32(last[1]&0xFF))))
+	} else {
+		cur[1] = last[1] & 0xFF
+	}
+	if sym&(1<<4) != 0 {
+		corr := int32(d.decodeSymbol(m.diff[4]))
+		diff = (diff + (int32(cur[1]&0xFF) - int32(last[1]&0xFF))) / 2
+		cur[2] = uint16(u8Fold(corr + u8Clamp(diff+int32(last[2]&0xFF))))
+	} else {
+		cur[2] = last[2] & 0xFF
+	}
+	diff = int32(cur[0]>>8) - int32(last[0]>>8)
+	if sym&(1<<3) != 0 {
+		corr := int32(d.decodeSymbol(m.diff[3]))
+		cur[1] |= uint16(u8Fold(corr+u8Clamp(diff+int32(last[1]>>8)))) << 8
+	} else {
+		cur[1] |= last[1] & 0xFF00
+	}
+	if sym&(1<<5) != 0 {
+		corr := int32(d.decodeSymbol(m.diff[5]))
+		diff = (diff + (int32(cur[1]>>8) - int32(last[1]>>8))) / 2
+		cur[2] |= uint16(u8Fold(corr+u8Clamp(diff+int32(last[2]>>8)))) << 8
+	} else {
+		cur[2] |= last[2] & 0xFF00
+	}
+	return cur
+}
+
+// byte10 decodes the extra bytes of a point record, one model per byte
+type byte10 struct {
+	dec    *decoder
+	last   []byte
+	models []*symbolModel
+}
+
+func (b *byte10) init(item []byte) {
+	b.last = append(b.last[:0], item...)
+	b.models = make([]*symbolModel, len(item))
+	for i := range b.models {
+		b.models[i] = newSymbolModel(256)
+	}
+}
+
+func (b *byte10) read(item []byte) {
+	for i := range b.last {
+		b.last[i] = u8Fold(int32(b.last[i]) + int32(b.dec.decodeSymbol(b.models[i])))
+	}
+	copy(item, b.last)
+}
+
+func lazyModel(m *symbolModel, symbols uint32) *symbolModel {
+	if m == nil {
+		return newSymbolModel(symbols)
+	}
+	return m
+}
+
+func minContext(kBits, max uint32) uint32 {
+	if kBits < max {
+		return zeroBit0(kBits)
+	}
+	return max
+}
+
+func readRGB(item []byte) [3]uint16 {
+	return [3]uint16{
+		binary.LittleEndian.Uint16(item[0:]),
+		binary.LittleEndian.Uint16(item[2:]),
+		binary.LittleEndian.Uint16(item[4:]),
+	}
+}
+
+func writeRGB(item []byte, c [3]uint16) {
+	binary.LittleEndian.PutUint16(item[0:], c[0])
+	binary.LittleEndian.PutUint16(item[2:], c[1])
+	binary.LittleEndian.PutUint16(item[4:], c[2])
+}
+
+func getInt32(b []byte) int32 {
+	return int32(binary.LittleEndian.Uint32(b))
+}
+
+func putInt32(b []byte, v int32) {
+	binary.LittleEndian.PutUint32(b, uint32(v))
+}
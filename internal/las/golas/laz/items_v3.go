@@ -0,0 +1,596 @@
+package laz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// layeredItem decodes one item of a point record compressed with the layered (v3) scheme.
+// Each item attribute is stored in a separate layer with its own arithmetic decoder.
+type layeredItem interface {
+	// readLayerSizes reads from the chunk header the byte size of each layer of the item
+	readLayerSizes(r io.Reader) error
+	// init loads the layers data and resets the item models using the raw item that starts the chunk.
+	// The context can be updated by the item to signal the current scanner channel.
+	init(r io.Reader, item []byte, context *uint32) error
+	// read decodes the next item into the given slice
+	read(item []byte, context *uint32)
+	// error returns the first decoding error occurred in the chunk, if any
+	error() error
+}
+
+// layer is a single independently compressed stream of a chunk
+type layer struct {
+	size    uint32
+	data    []byte
+	changed bool
+	dec     decoder
+}
+
+func readLayerSizes(r io.Reader, layers []layer) error {
+	for i := range layers {
+		if err := binary.Read(r, binary.LittleEndian, &layers[i].size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads the layer data. Layers with no data are marked as unchanged, meaning that the
+// attribute they store is constant across the chunk. Mandatory layers are initialized regardless.
+func (l *layer) load(r io.Reader, mandatory bool) error {
+	if cap(l.data) < int(l.size) {
+		l.data = make([]byte, l.size)
+	}
+	l.data = l.data[:l.size]
+	if _, err := io.ReadFull(r, l.data); err != nil {
+		return err
+	}
+	l.changed = l.size > 0
+	if l.changed || mandatory {
+		l.dec.init(bytes.NewReader(l.data))
+	}
+	return nil
+}
+
+func layersError(layers []layer) error {
+	for i := range layers {
+		if layers[i].changed && layers[i].dec.err != nil {
+			return layers[i].dec.err
+		}
+	}
+	return nil
+}
+
+var numberReturnMap6Ctx = [16][16]uint8{
+	{0, 1, 2, 3, 4, 5, 3, 4, 4, 5, 5, 5, 5, 5, 5, 5},
+	{1, 0, 1, 3, 4, 5, 3, 4, 4, 5, 5, 5, 5, 5, 5, 5},
+	{2, 1, 2, 4, 4, 5, 4, 4, 4, 5, 5, 5, 5, 5, 5, 5},
+	{3, 3, 4, 5, 4, 5, 4, 4, 4, 5, 5, 5, 5, 5, 5, 5},
+	{4, 4, 4, 4, 5, 5, 4, 4, 4, 5, 5, 5, 5, 5, 5, 5},
+	{5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+	{3, 3, 4, 4, 4, 5, 4, 4, 4, 5, 5, 5, 5, 5, 5, 5},
+	{4, 4, 4, 4, 4, 5, 4, 4, 4, 5, 5, 5, 5, 5, 5, 5},
+	{4, 4, 4, 4, 4, 5, 4, 4, 4, 5, 5, 5, 5, 5, 5, 5},
+	{5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+	{5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+	{5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+	{5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+	{5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+	{5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+	{5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+}
+
+func numberReturnLevel8Ctx(n, r uint8) uint8 {
+	l := int(n) - int(r)
+	if l < 0 {
+		l = -l
+	}
+	if l > 7 {
+		l = 7
+	}
+	return uint8(l)
+}
+
+// layers of the point14 item
+const (
+	layerChannelReturnsXY = iota
+	layerZ
+	layerClassification
+	layerFlags
+	layerIntensity
+	layerScanAngle
+	layerUserData
+	layerPointSource
+	layerGPSTime
+	point14Layers
+)
+
+// point14Fields contains the unpacked attributes of the 30 bytes core of point formats 6 to 10
+type point14Fields struct {
+	x, y, z             int32
+	intensity           uint16
+	returnNumber        uint8
+	numberOfReturns     uint8
+	classificationFlags uint8
+	scannerChannel      uint8
+	scanDirectionFlag   uint8
+	edgeOfFlightLine    uint8
+	classification      uint8
+	userData            uint8
+	scanAngle           int16
+	pointSourceID       uint16
+	gpsTime             uint64
+	gpsTimeChange       bool
+}
+
+func unpackPoint14(b []byte) point14Fields {
+	return point14Fields{
+		x:                   getInt32(b[0:]),
+		y:                   getInt32(b[4:]),
+		z:                   getInt32(b[8:]),
+		intensity:           binary.LittleEndian.Uint16(b[12:]),
+		returnNumber:        b[14] & 0x0F,
+		numberOfReturns:     b[14] >> 4,
+		classificationFlags: b[15] & 0x0F,
+		scannerChannel:      (b[15] >> 4) & 0x03,
+		scanDirectionFlag:   (b[15] >> 6) & 0x01,
+		edgeOfFlightLine:    b[15] >> 7,
+		classification:      b[16],
+		userData:            b[17],
+		scanAngle:           int16(binary.LittleEndian.Uint16(b[18:])),
+		pointSourceID:       binary.LittleEndian.Uint16(b[20:]),
+		gpsTime:             binary.LittleEndian.Uint64(b[22:]),
+	}
+}
+
+func (p *point14Fields) pack(b []byte) {
+	putInt32(b[0:], p.x)
+	putInt32(b[4:], p.y)
+	putInt32(b[8:], p.z)
+	binary.LittleEndian.PutUint16(b[12:], p.intensity)
+	b[14] = p.returnNumber | p.numberOfReturns<<4
+	b[15] = p.classificationFlags | p.scannerChannel<<4 | p.scanDirectionFlag<<6 | p.edgeOfFlightLine<<7
+	b[16] = p.classification
+	b[17] = p.userData
+	binary.LittleEndian.PutUint16(b[18:], uint16(p.scanAngle))
+	binary.LittleEndian.PutUint16(b[20:], p.pointSourceID)
+	binary.LittleEndian.PutUint64(b[22:], p.gpsTime)
+}
+
+func (p *point14Fields) flags() uint8 {
+	return p.edgeOfFlightLine<<5 | p.scanDirectionFlag<<4 | p.classificationFlags
+}
+
+// point14Context holds the models used for the points of a scanner channel
+type point14Context struct {
+	unused              bool
+	last                point14Fields
+	lastIntensity       [8]uint16
+	lastXDiff           [12]streamingMedian5
+	lastYDiff           [12]streamingMedian5
+	lastZ               [8]int32
+	changedValues       [8]*symbolModel
+	scannerChannel      *symbolModel
+	numberOfReturns     [16]*symbolModel
+	returnNumber        [16]*symbolModel
+	returnNumberGPSSame *symbolModel
+	icDx                *integerCoder
+	icDy                *integerCoder
+	icZ                 *integerCoder
+	classification      [64]*symbolModel
+	flags               [64]*symbolModel
+	userData            [64]*symbolModel
+	icIntensity         *integerCoder
+	icScanAngle         *integerCoder
+	icPointSource       *integerCoder
+	gps                 gpsTimeState
+}
+
+func newPoint14Context(last point14Fields) *point14Context {
+	c := &point14Context{
+		last:                last,
+		scannerChannel:      newSymbolModel(3),
+		returnNumberGPSSame: newSymbolModel(13),
+		icDx:                newIntegerCoder(32, 2),
+		icDy:                newIntegerCoder(32, 22),
+		icZ:                 newIntegerCoder(32, 20),
+		icIntensity:         newIntegerCoder(16, 4),
+		icScanAngle:         newIntegerCoder(16, 2),
+		icPointSource:       newIntegerCoder(16, 1),
+		gps:                 newGPSTimeState(last.gpsTime, gpsTimeMultiTotal14, 5),
+	}
+	c.last.gpsTimeChange = false
+	for i := range c.changedValues {
+		c.changedValues[i] = newSymbolModel(128)
+	}
+	for i := range c.lastXDiff {
+		c.lastXDiff[i] = newStreamingMedian5()
+		c.lastYDiff[i] = newStreamingMedian5()
+	}
+	for i := range c.lastIntensity {
+		c.lastIntensity[i] = last.intensity
+		c.lastZ[i] = last.z
+	}
+	return c
+}
+
+// point14 decodes the 30 bytes core of point formats 6 to 10
+type point14 struct {
+	layers   [point14Layers]layer
+	contexts [4]*point14Context
+	current  uint32
+}
+
+func (p *point14) readLayerSizes(r io.Reader) error {
+	return readLayerSizes(r, p.layers[:])
+}
+
+func (p *point14) init(r io.Reader, item []byte, context *uint32) error {
+	for i := range p.layers {
+		if err := p.layers[i].load(r, i == layerChannelReturnsXY); err != nil {
+			return err
+		}
+	}
+	p.layers[layerChannelReturnsXY].changed = true
+	pt := unpackPoint14(item)
+	p.contexts = [4]*point14Context{}
+	p.current = uint32(pt.scannerChannel)
+	*context = p.current
+	p.contexts[p.current] = newPoint14Context(pt)
+	return nil
+}
+
+func (p *point14) error() error {
+	return layersError(p.layers[:])
+}
+
+func (p *point14) read(item []byte, context *uint32) {
+	c := p.contexts[p.current]
+	dec := &p.layers[layerChannelReturnsXY].dec
+
+	lpr := 0
+	if c.last.returnNumber == 1 {
+		lpr++
+	}
+	if c.last.returnNumber >= c.last.numberOfReturns {
+		lpr += 2
+	}
+	if c.last.gpsTimeChange {
+		lpr += 4
+	}
+	changed := dec.decodeSymbol(c.changedValues[lpr])
+
+	// scanner channel switch
+	if changed&(1<<6) != 0 {
+		diff := dec.decodeSymbol(c.scannerChannel)
+		channel := (p.current + diff + 1) % 4
+		if p.contexts[channel] == nil {
+			p.contexts[channel] = newPoint14Context(c.last)
+		}
+		p.current = channel
+		*context = channel
+		c = p.contexts[channel]
+		c.last.scannerChannel = uint8(channel)
+	}
+	last := &c.last
+	pointSourceChange := changed&(1<<5) != 0
+	gpsTimeChange := changed&(1<<4) != 0
+	scanAngleChange := changed&(1<<3) != 0
+	gpsIdx := uint32(0)
+	if gpsTimeChange {
+		gpsIdx = 1
+	}
+
+	// number of returns and return number
+	lastN := last.numberOfReturns
+	lastR := last.returnNumber
+	n := lastN
+	if changed&(1<<2) != 0 {
+		c.numberOfReturns[lastN] = lazyModel(c.numberOfReturns[lastN], 16)
+		n = uint8(dec.decodeSymbol(c.numberOfReturns[lastN]))
+		last.numberOfReturns = n
+	}
+	var r uint8
+	switch changed & 3 {
+	case 0:
+		r = lastR
+	case 1:
+		r = (lastR + 1) % 16
+	case 2:
+		r = (lastR + 15) % 16
+	default:
+		if gpsTimeChange {
+			c.returnNumber[lastR] = lazyModel(c.returnNumber[lastR], 16)
+			r = uint8(dec.decodeSymbol(c.returnNumber[lastR]))
+		} else {
+			sym := dec.decodeSymbol(c.returnNumberGPSSame)
+			r = uint8((uint32(lastR) + sym + 2) % 16)
+		}
+	}
+	last.returnNumber = r
+
+	m := uint32(numberReturnMap6Ctx[n][r])
+	l := numberReturnLevel8Ctx(n, r)
+	cpr := uint32(0)
+	if r == 1 {
+		cpr += 2
+	}
+	if r >= n {
+		cpr++
+	}
+	oneReturn := uint32(0)
+	if n == 1 {
+		oneReturn = 1
+	}
+
+	// x and y
+	idx := (m << 1) | gpsIdx
+	median := c.lastXDiff[idx].get()
+	diff := c.icDx.decompress(dec, median, oneReturn)
+	last.x += diff
+	c.lastXDiff[idx].add(diff)
+	median = c.lastYDiff[idx].get()
+	kBits := c.icDx.getK()
+	diff = c.icDy.decompress(dec, median, oneReturn+minContext(kBits, 20))
+	last.y += diff
+	c.lastYDiff[idx].add(diff)
+
+	// z
+	if lz := &p.layers[layerZ]; lz.changed {
+		kBits = (c.icDx.getK() + c.icDy.getK()) / 2
+		last.z = c.icZ.decompress(&lz.dec, c.lastZ[l], oneReturn+minContext(kBits, 18))
+		c.lastZ[l] = last.z
+	}
+
+	// classification
+	if lc := &p.layers[layerClassification]; lc.changed {
+		ccc := uint32(last.classification&0x1F) << 1
+		if cpr == 3 {
+			ccc++
+		}
+		c.classification[ccc] = lazyModel(c.classification[ccc], 256)
+		last.classification = uint8(lc.dec.decodeSymbol(c.classification[ccc]))
+	}
+
+	// flags
+	if lf := &p.layers[layerFlags]; lf.changed {
+		lastFlags := last.flags()
+		c.flags[lastFlags] = lazyModel(c.flags[lastFlags], 64)
+		flags := uint8(lf.dec.decodeSymbol(c.flags[lastFlags]))
+		last.edgeOfFlightLine = (flags >> 5) & 1
+		last.scanDirectionFlag = (flags >> 4) & 1
+		last.classificationFlags = flags & 0x0F
+	}
+
+	// intensity
+	if li := &p.layers[layerIntensity]; li.changed {
+		idx := (cpr << 1) | gpsIdx
+		last.intensity = uint16(c.icIntensity.decompress(&li.dec, int32(c.lastIntensity[idx]), cpr))
+		c.lastIntensity[idx] = last.intensity
+	}
+
+	// scan angle
+	if ls := &p.layers[layerScanAngle]; ls.changed && scanAngleChange {
+		last.scanAngle = int16(c.icScanAngle.decompress(&ls.dec, int32(last.scanAngle), gpsIdx))
+	}
+
+	// user data
+	if lu := &p.layers[layerUserData]; lu.changed {
+		ctx := last.userData / 4
+		c.userData[ctx] = lazyModel(c.userData[ctx], 256)
+		last.userData = uint8(lu.dec.decodeSymbol(c.userData[ctx]))
+	}
+
+	// point source id
+	if lp := &p.layers[layerPointSource]; lp.changed && pointSourceChange {
+		last.pointSourceID = uint16(c.icPointSource.decompress(&lp.dec, int32(last.pointSourceID), 0))
+	}
+
+	// gps time
+	if lg := &p.layers[layerGPSTime]; lg.changed && gpsTimeChange {
+		c.gps.read14(&lg.dec)
+		last.gpsTime = c.gps.lastGPSTime[c.gps.last]
+	}
+
+	last.pack(item)
+	last.gpsTimeChange = gpsTimeChange
+}
+
+// read14 decodes the next GPS time using the v3 scheme
+func (g *gpsTimeState) read14(d *decoder) {
+	for {
+		if g.lastGPSTimeDiff[g.last] == 0 {
+			multi := d.decodeSymbol(g.m0Diff)
+			if multi == 0 {
+				g.lastGPSTimeDiff[g.last] = g.ic.decompress(d, 0, 0)
+				g.lastGPSTime[g.last] += uint64(int64(g.lastGPSTimeDiff[g.last]))
+				g.multiExtremeCounter[g.last] = 0
+			} else if multi == 1 {
+				g.readFull(d)
+			} else {
+				g.last = (g.last + multi - 1) & 3
+				continue
+			}
+		} else {
+			multi := int32(d.decodeSymbol(g.mMulti))
+			if multi == 1 {
+				g.lastGPSTime[g.last] += uint64(int64(g.ic.decompress(d, g.lastGPSTimeDiff[g.last], 1)))
+				g.multiExtremeCounter[g.last] = 0
+			} else if multi < gpsTimeMultiFull14 {
+				g.lastGPSTime[g.last] += uint64(int64(g.readMultiDiff(d, multi)))
+			} else if multi == gpsTimeMultiFull14 {
+				g.readFull(d)
+			} else {
+				g.last = (g.last + uint32(multi-gpsTimeMultiFull14)) & 3
+				continue
+			}
+		}
+		return
+	}
+}
+
+// rgb14Context holds the models used for the colors of a scanner channel
+type rgb14Context struct {
+	rgbModels
+	nirUsed *symbolModel
+	nirDiff [2]*symbolModel
+	last    [4]uint16
+}
+
+func newRGB14Context(last [4]uint16) *rgb14Context {
+	return &rgb14Context{
+		rgbModels: newRGBModels(),
+		nirUsed:   newSymbolModel(4),
+		nirDiff:   [2]*symbolModel{newSymbolModel(256), newSymbolModel(256)},
+		last:      last,
+	}
+}
+
+// rgb14 decodes the RGB colors, and optionally the NIR channel, of point formats 7, 8 and 10
+type rgb14 struct {
+	nir      bool
+	layers   [2]layer
+	contexts [4]*rgb14Context
+	current  uint32
+}
+
+func (c *rgb14) numLayers() int {
+	if c.nir {
+		return 2
+	}
+	return 1
+}
+
+func (c *rgb14) readLayerSizes(r io.Reader) error {
+	return readLayerSizes(r, c.layers[:c.numLayers()])
+}
+
+func (c *rgb14) init(r io.Reader, item []byte, context *uint32) error {
+	for i := 0; i < c.numLayers(); i++ {
+		if err := c.layers[i].load(r, false); err != nil {
+			return err
+		}
+	}
+	c.contexts = [4]*rgb14Context{}
+	c.current = *context
+	c.contexts[c.current] = newRGB14Context(c.unpack(item))
+	return nil
+}
+
+func (c *rgb14) unpack(item []byte) [4]uint16 {
+	rgb := readRGB(item)
+	out := [4]uint16{rgb[0], rgb[1], rgb[2], 0}
+	if c.nir {
+		out[3] = binary.LittleEndian.Uint16(item[6:])
+	}
+	return out
+}
+
+func (c *rgb14) error() error {
+	return layersError(c.layers[:c.numLayers()])
+}
+
+func (c *rgb14) read(item []byte, context *uint32) {
+	ctx := c.contexts[c.current]
+	if c.current != *context {
+		c.current = *context
+		if c.contexts[c.current] == nil {
+			c.contexts[c.current] = newRGB14Context(ctx.last)
+		}
+		ctx = c.contexts[c.current]
+	}
+	if l := &c.layers[0]; l.changed {
+		rgb := ctx.rgbModels.read(&l.dec, [3]uint16{ctx.last[0], ctx.last[1], ctx.last[2]})
+		ctx.last[0], ctx.last[1], ctx.last[2] = rgb[0], rgb[1], rgb[2]
+	}
+	if l := &c.layers[1]; c.nir && l.changed {
+		d := &l.dec
+		last := ctx.last[3]
+		sym := d.decodeSymbol(ctx.nirUsed)
+		var cur uint16
+		if sym&(1<<0) != 0 {
+			corr := int32(d.decodeSymbol(ctx.nirDiff[0]))
+			cur = uint16(u8Fold(corr + int32(last&0xFF)))
+		} else {
+			cur = last & 0xFF
+		}
+		if sym&(1<<1) != 0 {
+			corr := int32(d.decodeSymbol(ctx.nirDiff[1]))
+			cur |= uint16(u8Fold(corr+int32(last>>8))) << 8
+		} else {
+			cur |= last & 0xFF00
+		}
+		ctx.last[3] = cur
+	}
+	writeRGB(item, [3]uint16{ctx.last[0], ctx.last[1], ctx.last[2]})
+	if c.nir {
+		binary.LittleEndian.PutUint16(item[6:], ctx.last[3])
+	}
+}
+
+// byte14Context holds the models used for the extra bytes of a scanner channel
+type byte14Context struct {
+	models []*symbolModel
+	last   []byte
+}
+
+func newByte14Context(last []byte) *byte14Context {
+	c := &byte14Context{
+		models: make([]*symbolModel, len(last)),
+		last:   append([]byte{}, last...),
+	}
+	for i := range c.models {
+		c.models[i] = newSymbolModel(256)
+	}
+	return c
+}
+
+// byte14 decodes the extra bytes of point formats 6 to 10, one layer per byte
+type byte14 struct {
+	layers   []layer
+	contexts [4]*byte14Context
+	current  uint32
+}
+
+func newByte14(size int) *byte14 {
+	return &byte14{layers: make([]layer, size)}
+}
+
+func (b *byte14) readLayerSizes(r io.Reader) error {
+	return readLayerSizes(r, b.layers)
+}
+
+func (b *byte14) init(r io.Reader, item []byte, context *uint32) error {
+	for i := range b.layers {
+		if err := b.layers[i].load(r, false); err != nil {
+			return err
+		}
+	}
+	b.contexts = [4]*byte14Context{}
+	b.current = *context
+	b.contexts[b.current] = newByte14Context(item)
+	return nil
+}
+
+func (b *byte14) error() error {
+	return layersError(b.layers)
+}
+
+func (b *byte14) read(item []byte, context *uint32) {
+	ctx := b.contexts[b.current]
+	if b.current != *context {
+		b.current = *context
+		if b.contexts[b.current] == nil {
+			b.contexts[b.current] = newByte14Context(ctx.last)
+		}
+		ctx = b.contexts[b.current]
+	}
+	for i := range b.layers {
+		if l := &b.layers[i]; l.changed {
+			ctx.last[i] = u8Fold(int32(ctx.last[i]) + int32(l.dec.decodeSymbol(ctx.models[i])))
+		}
+	}
+	copy(item, ctx.last)
+}
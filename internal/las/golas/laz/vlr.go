@@ -0,0 +1,101 @@
+package laz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// UserID and RecordID identify the VLR storing the LASzip compression metadata
+const (
+	UserID   = "laszip encoded"
+	RecordID = 22204
+)
+
+// Compressor types
+const (
+	CompressorNone             uint16 = 0
+	CompressorPointwise        uint16 = 1
+	CompressorPointwiseChunked uint16 = 2
+	CompressorLayeredChunked   uint16 = 3
+)
+
+// VariableChunkSize is the chunk size value signaling that chunks have variable sizes
+// that are stored in the chunk table
+const VariableChunkSize uint32 = math.MaxUint32
+
+// ItemType identifies the type of a compressed item
+type ItemType uint16
+
+const (
+	ItemByte         ItemType = 0
+	ItemShort        ItemType = 1
+	ItemInt          ItemType = 2
+	ItemLong         ItemType = 3
+	ItemFloat        ItemType = 4
+	ItemDouble       ItemType = 5
+	ItemPoint10      ItemType = 6
+	ItemGPSTime11    ItemType = 7
+	ItemRGB12        ItemType = 8
+	ItemWavePacket13 ItemType = 9
+	ItemPoint14      ItemType = 10
+	ItemRGB14        ItemType = 11
+	ItemRGBNIR14     ItemType = 12
+	ItemWavePacket14 ItemType = 13
+	ItemByte14       ItemType = 14
+)
+
+// Item describes one of the items a compressed point record is made of
+type Item struct {
+	Type    ItemType
+	Size    uint16
+	Version uint16
+}
+
+// VLR contains the LASzip compression metadata
+type VLR struct {
+	Compressor           uint16
+	Coder                uint16
+	VersionMajor         uint8
+	VersionMinor         uint8
+	VersionRevision      uint16
+	Options              uint32
+	ChunkSize            uint32
+	NumberOfSpecialEVLRs int64
+	OffsetToSpecialEVLRs int64
+	Items                []Item
+}
+
+// ParseVLR parses the payload of the LASzip VLR
+func ParseVLR(data []byte) (*VLR, error) {
+	v := &VLR{}
+	r := bytes.NewReader(data)
+	var numItems uint16
+	fields := []any{
+		&v.Compressor, &v.Coder, &v.VersionMajor, &v.VersionMinor, &v.VersionRevision,
+		&v.Options, &v.ChunkSize, &v.NumberOfSpecialEVLRs, &v.OffsetToSpecialEVLRs, &numItems,
+	}
+	for _, f := range fields {
+		if err := binary.Read(r, binary.LittleEndian, f); err != nil {
+			return nil, fmt.Errorf("unable to parse laszip vlr: %w", err)
+		}
+	}
+	v.Items = make([]Item, numItems)
+	if err := binary.Read(r, binary.LittleEndian, v.Items); err != nil {
+		return nil, fmt.Errorf("unable to parse laszip vlr items: %w", err)
+	}
+	if v.Coder != 0 {
+		return nil, fmt.Errorf("unsupported laszip coder %d", v.Coder)
+	}
+	return v, nil
+}
+
+// RecordLength returns the length in bytes of the uncompressed point records
+func (v *VLR) RecordLength() int {
+	l := 0
+	for _, it := range v.Items {
+		l += int(it.Size)
+	}
+	return l
+}
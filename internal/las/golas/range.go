@@ -0,0 +1,213 @@
+package golas
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrCompressed is returned when attempting to read a range of points of a LAZ compressed file,
+// whose point data can only be read sequentially
+var ErrCompressed = errors.New("compressed point data can only be read sequentially")
+
+// recordDecoder decodes the raw point data records of a LAS. The layout of the records is computed once
+// from the header so that decoding a record requires no lookups nor allocations.
+type recordDecoder struct {
+	format                     uint8
+	xScale, yScale, zScale     float64
+	xOffset, yOffset, zOffset  float64
+	extraFlagByte, gpsTime     bool
+	scanAngleRank, scanAngle   bool
+	rgbColors, nir, wavePacket bool
+	// minLength is the length of the standard fields of the record, any following byte is custom data
+	minLength int
+}
+
+func newRecordDecoder(h LasHeader) recordDecoder {
+	f := h.PointDataRecordFormat
+	d := recordDecoder{
+		format:        f,
+		xScale:        h.XScaleFactor,
+		yScale:        h.YScaleFactor,
+		zScale:        h.ZScaleFactor,
+		xOffset:       h.XOffset,
+		yOffset:       h.YOffset,
+		zOffset:       h.ZOffset,
+		extraFlagByte: formatHasExtraFlagByte(f),
+		gpsTime:       formatHasGpsTime(f),
+		scanAngleRank: formatHasScanAngleRank(f),
+		scanAngle:     formatHasScanAngle(f),
+		rgbColors:     formatHasRgbColors(f),
+		nir:           formatHasNir(f),
+		wavePacket:    formatHasWavePackets(f),
+	}
+	// coordinates, intensity, flags, classification, user data and point source id
+	d.minLength = 12 + 2 + 1 + 1 + 1 + 2
+	if d.extraFlagByte {
+		d.minLength++
+	}
+	if d.scanAngleRank {
+		d.minLength++
+	}
+	if d.scanAngle {
+		d.minLength += 2
+	}
+	if d.gpsTime {
+		d.minLength += 8
+	}
+	if d.rgbColors {
+		d.minLength += 6
+	}
+	if d.nir {
+		d.minLength += 2
+	}
+	if d.wavePacket {
+		d.minLength += 29
+	}
+	return d
+}
+
+// decode interprets the given raw point data record. The CustomData of the point shares the memory of the record.
+func (d recordDecoder) decode(data []byte) (Point, error) {
+	p := Point{
+		PointDataRecordFormat: d.format,
+	}
+	if len(data) < d.minLength {
+		return p, io.ErrUnexpectedEOF
+	}
+	le := binary.LittleEndian
+
+	// Read the coordinates and compute the real ones
+	p.X = (float64(int32(le.Uint32(data[0:]))) * d.xScale) + d.xOffset
+	p.Y = (float64(int32(le.Uint32(data[4:]))) * d.yScale) + d.yOffset
+	p.Z = (float64(int32(le.Uint32(data[8:]))) * d.zScale) + d.zOffset
+	p.Intensity = le.Uint16(data[12:])
+	p.flags1 = data[14]
+	o := 15
+
+	// Read Flags byte 2 for point formats >= 6
+	if d.extraFlagByte {
+		p.flags2 = data[o]
+		o++
+	}
+
+	// Read and parse the classification, for point formats < 6 only keep the first 5 bits
+	p.classificationRaw = data[o]
+	p.Classification = data[o]
+	if d.format < 6 {
+		p.Classification &= 0b00011111
+	}
+	o++
+
+	if d.scanAngleRank {
+		p.ScanAngleRank = int8(data[o])
+		o++
+	}
+	p.UserData = data[o]
+	o++
+	if d.scanAngle {
+		p.ScanAngle = int16(le.Uint16(data[o:]))
+		o += 2
+	}
+	p.PointSourceID = le.Uint16(data[o:])
+	o += 2
+	if d.gpsTime {
+		p.GPSTime = math.Float64frombits(le.Uint64(data[o:]))
+		o += 8
+	}
+
+	// Colors are kept as 16 bit of color depth
+	if d.rgbColors {
+		p.Red = le.Uint16(data[o:])
+		p.Green = le.Uint16(data[o+2:])
+		p.Blue = le.Uint16(data[o+4:])
+		o += 6
+	}
+	if d.nir {
+		p.NIR = le.Uint16(data[o:])
+		o += 2
+	}
+	if d.wavePacket {
+		p.WavePacketDescriptorIndex = data[o]
+		p.ByteOffsetToWaveformData = le.Uint64(data[o+1:])
+		p.WaveformPacketSizeBytes = le.Uint32(data[o+9:])
+		p.ReturnPointWaveformLocation = math.Float32frombits(le.Uint32(data[o+13:]))
+		p.ParametricDx = math.Float32frombits(le.Uint32(data[o+17:]))
+		p.ParametricDy = math.Float32frombits(le.Uint32(data[o+21:]))
+		p.ParametricDz = math.Float32frombits(le.Uint32(data[o+25:]))
+		o += 29
+	}
+
+	// Point custom data, all the residual bytes
+	p.CustomData = data[o:len(data):len(data)]
+	return p, nil
+}
+
+// RangeReader decodes the points of a LAS with index in a given range, reading them from its own io.ReaderAt.
+// RangeReaders share no state with each other nor with the Las they were created from, hence multiple
+// RangeReaders can read different ranges of the same file in parallel, without locking. Only uncompressed
+// point data can be read by range.
+type RangeReader struct {
+	r            io.ReaderAt
+	decoder      recordDecoder
+	recordLength int
+	// offset is the position in the file of the next record to read
+	offset int64
+	// next and end are the index of the next point to read and the end of the range
+	next, end uint64
+}
+
+// NewRangeReader returns a RangeReader of the points with index in [start, end), read from r, which must read
+// the same data of the io.ReadSeeker the Las was created from. An *os.File of the LAS file can be shared by
+// multiple RangeReaders. Returns ErrCompressed if the point data is LAZ compressed.
+func (g *Las) NewRangeReader(r io.ReaderAt, start, end uint64) (*RangeReader, error) {
+	if g.compressed {
+		return nil, ErrCompressed
+	}
+	if start > end || end > g.NumberOfPoints() {
+		return nil, fmt.Errorf("invalid point range [%d, %d), the las has %d points", start, end, g.NumberOfPoints())
+	}
+	recordLength := int(g.Header.PointDataRecordLength)
+	return &RangeReader{
+		r:            r,
+		decoder:      g.decoder,
+		recordLength: recordLength,
+		offset:       int64(g.Header.OffsetToPointData) + int64(start)*int64(recordLength),
+		next:         start,
+		end:          end,
+	}, nil
+}
+
+// Remaining returns the number of points of the range not read yet
+func (rr *RangeReader) Remaining() uint64 {
+	return rr.end - rr.next
+}
+
+// Read decodes the next points of the range into pts, up to its length, and returns the number of points read.
+// Once all the points of the range have been read it returns 0 and io.EOF. The points are read with a single
+// read operation and the CustomData of the points read by each call share the same newly allocated buffer.
+func (rr *RangeReader) Read(pts []Point) (int, error) {
+	if rr.next >= rr.end {
+		return 0, io.EOF
+	}
+	n := int(min(uint64(len(pts)), rr.end-rr.next))
+	buf := make([]byte, n*rr.recordLength)
+	if read, err := rr.r.ReadAt(buf, rr.offset); read < len(buf) {
+		if err == nil || errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	for i := 0; i < n; i++ {
+		p, err := rr.decoder.decode(buf[i*rr.recordLength : (i+1)*rr.recordLength])
+		if err != nil {
+			return 0, err
+		}
+		pts[i] = p
+	}
+	rr.offset += int64(len(buf))
+	rr.next += uint64(n)
+	return n, nil
+}
@@ -0,0 +1,170 @@
+package golas
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRangeReader(t *testing.T) {
+	files := []string{
+		"1.1_0.las", "1.2_0.las", "1.2_1.las", "1.2_2.las", "1.2_3.las", "las-13-pf4.las", "las-13-pf5.las",
+		"las-14-pf2.las", "las-14-pf4.las", "las-14-pf5-sf.las", "las-14-pf7-sf.las", "extrabytes.las",
+	}
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			f, err := os.Open(path.Join("testdata", file))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer f.Close()
+			g, err := NewLas(f)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := []Point{}
+			for g.HasNext() {
+				p, err := g.Next()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				expected = append(expected, p)
+			}
+
+			// read three ranges in parallel, in batches of 3 points
+			n := g.NumberOfPoints()
+			bounds := []uint64{0, n / 3, 2 * n / 3, n}
+			actual := make([]Point, n)
+			errs := make([]error, 3)
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					rr, err := g.NewRangeReader(f, bounds[i], bounds[i+1])
+					if err != nil {
+						errs[i] = err
+						return
+					}
+					batch := make([]Point, 3)
+					pos := bounds[i]
+					for {
+						read, err := rr.Read(batch)
+						if errors.Is(err, io.EOF) {
+							break
+						}
+						if err != nil {
+							errs[i] = err
+							return
+						}
+						copy(actual[pos:], batch[:read])
+						pos += uint64(read)
+					}
+					if pos != bounds[i+1] || rr.Remaining() != 0 {
+						errs[i] = errors.New("unexpected number of points read")
+					}
+				}(i)
+			}
+			wg.Wait()
+			for _, err := range errs {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			for i := range expected {
+				if !reflect.DeepEqual(expected[i], actual[i]) {
+					t.Errorf("point %d: expected %v got %v", i, expected[i], actual[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRangeReaderCompressed(t *testing.T) {
+	f, err := os.Open("testdata/1.2-with-color.laz")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+	g, err := NewLas(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := g.NewRangeReader(f, 0, 1); !errors.Is(err, ErrCompressed) {
+		t.Errorf("expected error %v got %v", ErrCompressed, err)
+	}
+}
+
+func TestRangeReaderInvalid(t *testing.T) {
+	data, err := os.ReadFile("testdata/1.2_0.las")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g, err := NewLas(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n := g.NumberOfPoints()
+	if _, err := g.NewRangeReader(bytes.NewReader(data), 2, 1); err == nil {
+		t.Errorf("expected error got none")
+	}
+	if _, err := g.NewRangeReader(bytes.NewReader(data), 0, n+1); err == nil {
+		t.Errorf("expected error got none")
+	}
+
+	// truncated point data
+	end := int(g.Header.OffsetToPointData) + int(n)*int(g.Header.PointDataRecordLength)
+	rr, err := g.NewRangeReader(bytes.NewReader(data[:end-1]), 0, n)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := rr.Read(make([]Point, n)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected error %v got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	// empty range
+	rr, err = g.NewRangeReader(bytes.NewReader(data), 1, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := rr.Read(make([]Point, 1)); !errors.Is(err, io.EOF) {
+		t.Errorf("expected error %v got %v", io.EOF, err)
+	}
+}
+
+func BenchmarkLasNext(b *testing.B) {
+	data, err := os.ReadFile("testdata/las-14-pf7-sf.las")
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		g, _ := NewLas(bytes.NewReader(data))
+		for g.HasNext() {
+			g.Next()
+		}
+	}
+}
+
+func BenchmarkLasRangeReader(b *testing.B) {
+	data, err := os.ReadFile("testdata/las-14-pf7-sf.las")
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	batch := make([]Point, 1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		g, _ := NewLas(bytes.NewReader(data))
+		rr, _ := g.NewRangeReader(bytes.NewReader(data), 0, g.NumberOfPoints())
+		for {
+			if _, err := rr.Read(batch); err != nil {
+				break
+			}
+		}
+	}
+}
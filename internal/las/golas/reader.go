@@ -8,6 +8,8 @@ import (
 	"io"
 	"strings"
 	"sync"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las/golas/laz"
 )
 
 type GPSTimeType uint8
@@ -18,14 +20,21 @@ const (
 )
 
 // Las allows to read LAS file format data. The supported LAS versions range from 1.1 to 1.4.
+// LAZ compressed point data is transparently decompressed. The octree nodes of COPC files
+// can also be read individually.
 type Las struct {
-	Header  LasHeader
-	VLRs    []VLR
-	EVLRs   []EVLR
-	wkt     *WKT
-	geotiff *GeoTIFFMetadata
-	r       io.ReadSeeker
-	current uint64
+	Header     LasHeader
+	VLRs       []VLR
+	EVLRs      []EVLR
+	wkt        *WKT
+	geotiff    *GeoTIFFMetadata
+	copc       *CopcInfo
+	extraBytes []ExtraBytesDescriptor
+	r          io.ReadSeeker
+	current    uint64
+	compressed bool
+	laz        *laz.Decompressor
+	decoder    recordDecoder
 	sync.Mutex
 }
 
@@ -116,6 +125,7 @@ func NewLas(r io.ReadSeeker) (*Las, error) {
 	if err != nil {
 		return nil, err
 	}
+	g.decoder = newRecordDecoder(g.Header)
 	err = g.readVLRs()
 	if err != nil {
 		return nil, err
@@ -129,7 +139,21 @@ func NewLas(r io.ReadSeeker) (*Las, error) {
 	if err != nil {
 		return g, err
 	}
+	err = g.extractCopcInfo()
+	if err != nil {
+		return nil, err
+	}
+	err = g.extractExtraBytes()
+	if err != nil {
+		return nil, err
+	}
 	// prepare the reader to read point data
+	if g.compressed {
+		if err = g.initDecompressor(); err != nil {
+			return nil, err
+		}
+		return g, nil
+	}
 	_, err = g.r.Seek(int64(g.Header.OffsetToPointData), io.SeekStart)
 	if err != nil {
 		return nil, err
@@ -137,6 +161,30 @@ func NewLas(r io.ReadSeeker) (*Las, error) {
 	return g, nil
 }
 
+// IsCompressed returns true if the point data is LAZ compressed
+func (g *Las) IsCompressed() bool {
+	return g.compressed
+}
+
+// initDecompressor sets up the LAZ decompressor according to the LASzip VLR
+func (g *Las) initDecompressor() error {
+	for _, v := range g.VLRs {
+		if v.UserID != laz.UserID || v.RecordID != laz.RecordID {
+			continue
+		}
+		lazVLR, err := laz.ParseVLR(v.Data)
+		if err != nil {
+			return err
+		}
+		if lazVLR.RecordLength() != int(g.Header.PointDataRecordLength) {
+			return fmt.Errorf("laz record length %d does not match header record length %d", lazVLR.RecordLength(), g.Header.PointDataRecordLength)
+		}
+		g.laz, err = laz.NewDecompressor(g.r, lazVLR, int64(g.Header.OffsetToPointData), g.NumberOfPoints())
+		return err
+	}
+	return errors.New("compressed point data found but the laszip VLR is missing")
+}
+
 // WKT returns the WKT object storing the LAS coordinate reference system metadata, if any was
 // found in the LAS VLRs and EVLRs, else it returns nil.
 func (g *Las) WKT() *WKT {
@@ -150,12 +198,12 @@ func (g *Las) GeoTIFFMetadata() *GeoTIFFMetadata {
 }
 
 // CRS returns either the EPSG code of the CRS in use extracted from the Geotiff metadata or returns a WKT string representing the Coordinate System embedded in the LAS.
-// Emtpy string means no CRS metadata has been found, or it could not be extracted as string and requires
-// advanced interpretation of the Geotiff metadata.
+// Emtpy string means no CRS metadata has been found, or it could not be interpreted.
 // If WKT Coordinate System information is available, it takes precedence over the Geotiff metadata.
 // The EPSG code returned in case of GeoTIFF will have the form EPSG:XYZ (in case of geographic or projected CRS) or
 // the form EPSG:XYZ+LMN in case the GeoTIFF also declares the presence of a Vertical CRS.
-// Geotiff codes outside the EPSG ranges are skipped.
+// Geotiff codes outside the EPSG ranges are skipped. User-defined GeoTIFF CRSs are returned as PROJ strings,
+// see GeoTIFFMetadata.ProjString.
 func (g *Las) CRS() string {
 	if wkt := g.WKT(); wkt != nil {
 		return wkt.CoordinateSystem
@@ -178,6 +226,10 @@ func (g *Las) CRS() string {
 				return fmt.Sprintf("EPSG:%d%s", val.AsShort(), verticalCS)
 			}
 		}
+		// user-defined CRSs are described by a PROJ string built from the other keys
+		if proj, err := geotiff.ProjString(); err == nil {
+			return proj
+		}
 		// GeographicTypeGeoKey is key 2048
 		// valid EPSG values should be between 4000 and 4999
 		if val := geotiff.Keys[2048]; val != nil {
@@ -223,171 +275,41 @@ func (g *Las) Next() (Point, error) {
 		return p, io.EOF
 	}
 	data := make([]byte, g.Header.PointDataRecordLength)
-	if _, err := io.ReadFull(g.r, data); err != nil {
-		return p, io.ErrUnexpectedEOF
+	if err := g.readRecord(data); err != nil {
+		g.Unlock()
+		return p, err
 	}
 	g.current++
 	g.Unlock()
+	return g.parsePoint(data)
+}
 
-	// Interpret raw point data
-	r := bytes.NewReader(data)
-
-	// Read X and compute the real coordinates
-	x, err := readLong(r)
-	if err != nil {
-		return p, err
-	}
-	p.X = (float64(x) * g.Header.XScaleFactor) + g.Header.XOffset
-
-	// Read Y and compute the real coordinates
-	y, err := readLong(r)
-	if err != nil {
-		return p, err
-	}
-	p.Y = (float64(y) * g.Header.YScaleFactor) + g.Header.YOffset
-
-	// Read Z and compute the real coordinates
-	z, err := readLong(r)
-	if err != nil {
-		return p, err
-	}
-	p.Z = (float64(z) * g.Header.ZScaleFactor) + g.Header.ZOffset
-
-	// Read Intensity
-	p.Intensity, err = readUnsignedShort(r)
-	if err != nil {
-		return p, err
-	}
-
-	// Read Flags byte 1
-	p.flags1, err = r.ReadByte()
-	if err != nil {
-		return p, err
-	}
-
-	// Read Flags byte 2 for point formats >= 6
-	if formatHasExtraFlagByte(p.PointDataRecordFormat) {
-		p.flags2, err = r.ReadByte()
-		if err != nil {
-			return p, err
-		}
-	}
-
-	// Read Classification Byte
-	classRaw, err := r.ReadByte()
-	if err != nil {
-		return p, err
-	}
-	p.classificationRaw = classRaw
-
-	// Parse Classification
-	classification := classRaw
-	if p.PointDataRecordFormat < 6 {
-		// for point formats < 6 only keep the first 5 bits
-		classification &= 0b00011111
-	}
-	p.Classification = classification
-
-	// Read Scan Angle Rank for point formats supporting it
-	if formatHasScanAngleRank(p.PointDataRecordFormat) {
-		p.ScanAngleRank, err = readInt8(r)
-		if err != nil {
-			return p, err
-		}
-	}
-
-	// Read User Data
-	p.UserData, err = readUint8(r)
-	if err != nil {
-		return p, err
-	}
-
-	// Read Scan Angle for point formats supporting it
-	if formatHasScanAngle(p.PointDataRecordFormat) {
-		p.ScanAngle, err = readShort(r)
-		if err != nil {
-			return p, err
-		}
-	}
-
-	// Read Point Source ID
-	p.PointSourceID, err = readUnsignedShort(r)
+// parsePoint interprets the given raw point data record. The custom data is copied so that the record
+// can be reused.
+func (g *Las) parsePoint(data []byte) (Point, error) {
+	p, err := g.decoder.decode(data)
 	if err != nil {
 		return p, err
 	}
+	p.CustomData = bytes.Clone(p.CustomData)
+	return p, nil
+}
 
-	// Read GPS Time for point formats supporting it
-	if formatHasGpsTime(p.PointDataRecordFormat) {
-		p.GPSTime, err = readFloat64(r)
-		if err != nil {
-			return p, err
-		}
-	}
-
-	// Read Colors for point formats supporting them. Colors are kept as 16 bit of color depth.
-	if formatHasRgbColors(p.PointDataRecordFormat) {
-		p.Red, err = readUnsignedShort(r)
-		if err != nil {
-			return p, err
-		}
-		p.Green, err = readUnsignedShort(r)
-		if err != nil {
-			return p, err
-		}
-		p.Blue, err = readUnsignedShort(r)
-		if err != nil {
-			return p, err
-		}
-	}
-
-	// Read NIR data for point formats supporting it.
-	if formatHasNir(p.PointDataRecordFormat) {
-		p.NIR, err = readUnsignedShort(r)
-		if err != nil {
-			return p, err
-		}
-	}
-
-	// Read WavePackets metadata for point formats supporting it.
-	if formatHasWavePackets(p.PointDataRecordFormat) {
-		p.WavePacketDescriptorIndex, err = readUint8(r)
-		if err != nil {
-			return p, err
-		}
-		p.ByteOffsetToWaveformData, err = readUnsignedLong64(r)
-		if err != nil {
-			return p, err
-		}
-		p.WaveformPacketSizeBytes, err = readUnsignedLong(r)
-		if err != nil {
-			return p, err
-		}
-		p.ReturnPointWaveformLocation, err = readFloat32(r)
-		if err != nil {
-			return p, err
-		}
-		p.ParametricDx, err = readFloat32(r)
-		if err != nil {
-			return p, err
-		}
-		p.ParametricDy, err = readFloat32(r)
-		if err != nil {
-			return p, err
-		}
-		p.ParametricDz, err = readFloat32(r)
-		if err != nil {
-			return p, err
+// readRecord reads the next raw point data record, decompressing it if required
+func (g *Las) readRecord(data []byte) error {
+	if g.laz != nil {
+		if err := g.laz.Read(data); err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+			return err
 		}
+		return nil
 	}
-
-	// Read point custom data, all the residual bytes
-	p.CustomData, err = io.ReadAll(r)
-	if err != nil {
-		return p, err
+	if _, err := io.ReadFull(g.r, data); err != nil {
+		return io.ErrUnexpectedEOF
 	}
-
-	// Return the point
-	return p, nil
+	return nil
 }
 
 // readHeader reads the LAS header metadata from the las reader and sets it in the struct
@@ -401,6 +323,11 @@ func (g *Las) readHeader() error {
 	if err := g.readVersionSpecificHeaderSection(header); err != nil {
 		return err
 	}
+	// LAZ files flag compressed point data by setting the two highest bits of the point format
+	if header.PointDataRecordFormat&0xC0 != 0 {
+		g.compressed = true
+		header.PointDataRecordFormat &= 0x3F
+	}
 	g.Header = *header
 	return nil
 }
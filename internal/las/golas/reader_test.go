@@ -1032,3 +1032,71 @@ func TestLasUTM17(t *testing.T) {
 		t.Errorf("expected CRS %s got %s", "EPSG:32617", actual)
 	}
 }
+
+func TestLazMatchesLas(t *testing.T) {
+	for _, name := range []string{"1.2-with-color", "las-14-pf7-sf"} {
+		t.Run(name, func(t *testing.T) {
+			fLas, err := os.Open(path.Join("./testdata", name+".las"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fLas.Close()
+			fLaz, err := os.Open(path.Join("./testdata", name+".laz"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fLaz.Close()
+			las, err := NewLas(fLas)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			laz, err := NewLas(fLaz)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if las.IsCompressed() {
+				t.Errorf("expected las not to be compressed")
+			}
+			if !laz.IsCompressed() {
+				t.Errorf("expected laz to be compressed")
+			}
+			if laz.Header.PointDataRecordFormat != las.Header.PointDataRecordFormat {
+				t.Errorf("expected point format %d got %d", las.Header.PointDataRecordFormat, laz.Header.PointDataRecordFormat)
+			}
+			if laz.NumberOfPoints() != las.NumberOfPoints() {
+				t.Fatalf("expected %d points got %d", las.NumberOfPoints(), laz.NumberOfPoints())
+			}
+			for i := uint64(0); i < las.NumberOfPoints(); i++ {
+				expected, err := las.Next()
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				actual, err := laz.Next()
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if !reflect.DeepEqual(expected, actual) {
+					t.Fatalf("point %d: expected %v got %v", i, expected, actual)
+				}
+			}
+			if laz.HasNext() {
+				t.Errorf("expected no more points")
+			}
+			if _, err := laz.Next(); err != io.EOF {
+				t.Errorf("expected EOF got %v", err)
+			}
+		})
+	}
+}
+
+func TestLazMissingVLR(t *testing.T) {
+	data, err := os.ReadFile("./testdata/las-12-pf3.las")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flag the point data as compressed without adding the laszip VLR
+	data[104] |= 0x80
+	if _, err := NewLas(bytes.NewReader(data)); err == nil {
+		t.Errorf("expected error, got none")
+	}
+}
@@ -10,6 +10,8 @@ type MockLasReader struct {
 	Cur         int
 	Pts         []geom.Point64
 	CRS         string
+	Attrs       []geom.Attribute
+	Values      [][]float64
 	CloseCalled bool
 }
 
@@ -20,6 +22,9 @@ func (m *MockLasReader) NumberOfPoints() int {
 func (m *MockLasReader) GetCRS() string {
 	return m.CRS
 }
+func (m *MockLasReader) Attributes() []geom.Attribute {
+	return m.Attrs
+}
 func (m *MockLasReader) GetNext() (geom.Point64, error) {
 	if m.Cur < len(m.Pts) {
 		m.Cur++
@@ -27,6 +32,12 @@ func (m *MockLasReader) GetNext() (geom.Point64, error) {
 	}
 	return geom.Point64{}, fmt.Errorf("point not available")
 }
+func (m *MockLasReader) GetNextWithAttributes(attrs []float64) (geom.Point64, error) {
+	if m.Cur < len(m.Values) {
+		copy(attrs, m.Values[m.Cur])
+	}
+	return m.GetNext()
+}
 func (m *MockLasReader) Close() {
 	m.CloseCalled = true
 }
@@ -0,0 +1,378 @@
+package las
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// plyType is a PLY scalar property type
+type plyType struct {
+	size   int
+	signed bool
+	float  bool
+}
+
+var plyTypes = map[string]plyType{
+	"char":    {size: 1, signed: true},
+	"int8":    {size: 1, signed: true},
+	"uchar":   {size: 1},
+	"uint8":   {size: 1},
+	"short":   {size: 2, signed: true},
+	"int16":   {size: 2, signed: true},
+	"ushort":  {size: 2},
+	"uint16":  {size: 2},
+	"int":     {size: 4, signed: true},
+	"int32":   {size: 4, signed: true},
+	"uint":    {size: 4},
+	"uint32":  {size: 4},
+	"float":   {size: 4, float: true},
+	"float32": {size: 4, float: true},
+	"double":  {size: 8, float: true},
+	"float64": {size: 8, float: true},
+}
+
+// plyProperty is a property of a PLY element. List properties store a variable number of values.
+type plyProperty struct {
+	name      string
+	typ       plyType
+	list      bool
+	countType plyType
+}
+
+// plyElement is an element of a PLY file, e.g. vertex or face
+type plyElement struct {
+	name  string
+	count int
+	props []plyProperty
+}
+
+// plyValueReader reads single values from the body of a PLY file
+type plyValueReader interface {
+	read(t plyType) (float64, error)
+}
+
+// plyBinaryReader reads values from binary PLY bodies
+type plyBinaryReader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	buf   [8]byte
+}
+
+func (b *plyBinaryReader) read(t plyType) (float64, error) {
+	data := b.buf[:t.size]
+	if _, err := io.ReadFull(b.r, data); err != nil {
+		return 0, err
+	}
+	switch {
+	case t.float && t.size == 4:
+		return float64(math.Float32frombits(b.order.Uint32(data))), nil
+	case t.float:
+		return math.Float64frombits(b.order.Uint64(data)), nil
+	case t.size == 1 && t.signed:
+		return float64(int8(data[0])), nil
+	case t.size == 1:
+		return float64(data[0]), nil
+	case t.size == 2 && t.signed:
+		return float64(int16(b.order.Uint16(data))), nil
+	case t.size == 2:
+		return float64(b.order.Uint16(data)), nil
+	case t.signed:
+		return float64(int32(b.order.Uint32(data))), nil
+	default:
+		return float64(b.order.Uint32(data)), nil
+	}
+}
+
+// plyASCIIReader reads values from ASCII PLY bodies
+type plyASCIIReader struct {
+	s *bufio.Scanner
+}
+
+func (a *plyASCIIReader) read(t plyType) (float64, error) {
+	if !a.s.Scan() {
+		if err := a.s.Err(); err != nil {
+			return 0, err
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+	return strconv.ParseFloat(a.s.Text(), 64)
+}
+
+// PlyReader reads the vertices of PLY files, either ASCII or binary, implementing the LasReader interface.
+// The x, y and z vertex properties are mandatory. The red, green and blue properties are read as 8 bit colors
+// if stored as uchar or as 16 bit colors if stored as ushort. The intensity and classification properties,
+// optionally prefixed by scalar_ as written by some tools, are read as well, intensities being stored as raw values,
+// see rawIntensity. Other properties are ignored.
+type PlyReader struct {
+	file   *os.File
+	values plyValueReader
+	vertex plyElement
+	// index of the property storing each attribute, -1 if absent
+	x, y, z, r, g, b, intensity, classification int
+	crs                                         string
+	numPts                                      int
+	current                                     int
+	record                                      []float64
+	sync.Mutex
+}
+
+// NewPlyReader returns a PlyReader for the given file. PLY files carry no CRS metadata,
+// therefore the crs is mandatory unless a sidecar file stores it, see SidecarCRS.
+func NewPlyReader(fileName string, crs string) (*PlyReader, error) {
+	if crs == "" {
+		crs = SidecarCRS(fileName)
+	}
+	if crs == "" {
+		return nil, fmt.Errorf("a CRS must be provided to read the PLY file %s", fileName)
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newPlyReader(f, crs)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to read PLY file %s: %w", fileName, err)
+	}
+	return r, nil
+}
+
+func newPlyReader(f *os.File, crs string) (*PlyReader, error) {
+	br := bufio.NewReaderSize(f, 64*1024)
+	format, elements, err := readPlyHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	r := &PlyReader{
+		file: f,
+		crs:  crs,
+	}
+	switch format {
+	case "ascii":
+		s := bufio.NewScanner(br)
+		s.Split(bufio.ScanWords)
+		r.values = &plyASCIIReader{s: s}
+	case "binary_little_endian":
+		r.values = &plyBinaryReader{r: br, order: binary.LittleEndian}
+	case "binary_big_endian":
+		r.values = &plyBinaryReader{r: br, order: binary.BigEndian}
+	default:
+		return nil, fmt.Errorf("unsupported PLY format %s", format)
+	}
+	// skip the elements preceding the vertices
+	found := false
+	for _, e := range elements {
+		if e.name == "vertex" {
+			r.vertex = e
+			found = true
+			break
+		}
+		for i := 0; i < e.count; i++ {
+			if err := r.readRecord(e, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !found {
+		return nil, errors.New("vertex element not found")
+	}
+	index := func(names ...string) int {
+		for i, p := range r.vertex.props {
+			for _, n := range names {
+				if strings.EqualFold(p.name, n) && !p.list {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+	r.x, r.y, r.z = index("x"), index("y"), index("z")
+	if r.x == -1 || r.y == -1 || r.z == -1 {
+		return nil, errors.New("the vertex element must have the x, y and z properties")
+	}
+	r.r, r.g, r.b = index("red", "r"), index("green", "g"), index("blue", "b")
+	r.intensity = index("intensity", "scalar_intensity")
+	r.classification = index("classification", "scalar_classification")
+	r.numPts = r.vertex.count
+	r.record = make([]float64, len(r.vertex.props))
+	return r, nil
+}
+
+// readPlyHeader parses the PLY header returning the body format and the list of elements
+func readPlyHeader(r *bufio.Reader) (string, []plyElement, error) {
+	var format string
+	var elements []plyElement
+	for line := 0; ; line++ {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid PLY header: %w", err)
+		}
+		fields := strings.Fields(l)
+		if line == 0 {
+			if len(fields) != 1 || fields[0] != "ply" {
+				return "", nil, errors.New("invalid PLY signature")
+			}
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) != 3 {
+				return "", nil, fmt.Errorf("invalid PLY format line %q", strings.TrimSpace(l))
+			}
+			format = fields[1]
+		case "element":
+			if len(fields) != 3 {
+				return "", nil, fmt.Errorf("invalid PLY element line %q", strings.TrimSpace(l))
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return "", nil, fmt.Errorf("invalid PLY element count %q", fields[2])
+			}
+			elements = append(elements, plyElement{name: fields[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return "", nil, errors.New("PLY property declared before any element")
+			}
+			p, err := parsePlyProperty(fields)
+			if err != nil {
+				return "", nil, err
+			}
+			e := &elements[len(elements)-1]
+			e.props = append(e.props, p)
+		case "end_header":
+			if format == "" {
+				return "", nil, errors.New("PLY format not declared")
+			}
+			return format, elements, nil
+		}
+	}
+}
+
+func parsePlyProperty(fields []string) (plyProperty, error) {
+	if len(fields) == 5 && fields[1] == "list" {
+		countType, ok1 := plyTypes[fields[2]]
+		typ, ok2 := plyTypes[fields[3]]
+		if !ok1 || !ok2 || countType.float {
+			return plyProperty{}, fmt.Errorf("invalid PLY list property types %s %s", fields[2], fields[3])
+		}
+		return plyProperty{name: fields[4], typ: typ, list: true, countType: countType}, nil
+	}
+	if len(fields) != 3 {
+		return plyProperty{}, fmt.Errorf("invalid PLY property line %q", strings.Join(fields, " "))
+	}
+	typ, ok := plyTypes[fields[1]]
+	if !ok {
+		return plyProperty{}, fmt.Errorf("unknown PLY property type %s", fields[1])
+	}
+	return plyProperty{name: fields[2], typ: typ}, nil
+}
+
+// readRecord reads a record of the given element, storing the value of scalar properties in the given slice, if not nil
+func (r *PlyReader) readRecord(e plyElement, values []float64) error {
+	for i, p := range e.props {
+		if p.list {
+			n, err := r.values.read(p.countType)
+			if err != nil {
+				return err
+			}
+			for j := 0; j < int(n); j++ {
+				if _, err := r.values.read(p.typ); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		v, err := r.values.read(p.typ)
+		if err != nil {
+			return err
+		}
+		if values != nil {
+			values[i] = v
+		}
+	}
+	return nil
+}
+
+func (r *PlyReader) NumberOfPoints() int {
+	return r.numPts
+}
+
+func (r *PlyReader) GetCRS() string {
+	return r.crs
+}
+
+// Attributes returns nil as PLY files do not carry additional attributes
+func (r *PlyReader) Attributes() []geom.Attribute {
+	return nil
+}
+
+func (r *PlyReader) Close() {
+	r.file.Close()
+}
+
+func (r *PlyReader) GetNext() (geom.Point64, error) {
+	r.Lock()
+	defer r.Unlock()
+	if r.current >= r.numPts {
+		return geom.Point64{}, io.EOF
+	}
+	if err := r.readRecord(r.vertex, r.record); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return geom.Point64{}, fmt.Errorf("unable to read PLY vertex %d: %w", r.current, err)
+	}
+	r.current++
+	return geom.Point64{
+		Vector: model.Vector{
+			X: r.record[r.x],
+			Y: r.record[r.y],
+			Z: r.record[r.z],
+		},
+		R:              r.color(r.r),
+		G:              r.color(r.g),
+		B:              r.color(r.b),
+		Intensity:      rawIntensity(r.property(r.intensity)),
+		Classification: uint8(r.scalar(r.classification)),
+	}, nil
+}
+
+// color returns the value of the color property at the given index scaled to 8 bits
+func (r *PlyReader) color(idx int) uint8 {
+	if idx < 0 {
+		return 0
+	}
+	v := r.record[idx]
+	if t := r.vertex.props[idx].typ; t.size == 2 && !t.signed && !t.float {
+		// 16 bit colors
+		v /= 256
+	}
+	return uint8(clamp(math.Floor(v), 255))
+}
+
+// property returns the value of the property at the given index, 0 if the property is missing
+func (r *PlyReader) property(idx int) float64 {
+	if idx < 0 {
+		return 0
+	}
+	return r.record[idx]
+}
+
+// scalar returns the value of the property at the given index clamped to the 0-255 range
+func (r *PlyReader) scalar(idx int) float64 {
+	return clamp(r.property(idx), 255)
+}
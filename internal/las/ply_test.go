@@ -0,0 +1,192 @@
+package las
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+func TestPlyReaderASCII(t *testing.T) {
+	content := `ply
+format ascii 1.0
+comment generated by a test
+element vertex 2
+property float x
+property float y
+property double z
+property uchar red
+property uchar green
+property uchar blue
+property float scalar_Intensity
+property list uchar int extra
+end_header
+1.5 2.5 3.5 255 128 0 12.4 2 7 8
+-1 -2 -3 1 2 3 300 0
+`
+	r, err := NewPlyReader(writeTextFile(t, "cloud.ply", content), "EPSG:32633")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+	expected := []geom.Point64{
+		{Vector: model.Vector{X: 1.5, Y: 2.5, Z: 3.5}, R: 255, G: 128, B: 0, Intensity: 12},
+		{Vector: model.Vector{X: -1, Y: -2, Z: -3}, R: 1, G: 2, B: 3, Intensity: 255},
+	}
+	checkPlyPoints(t, r, expected)
+}
+
+func TestPlyReaderBinary(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			format := "binary_little_endian"
+			if order == binary.BigEndian {
+				format = "binary_big_endian"
+			}
+			b := &bytes.Buffer{}
+			b.WriteString("ply\nformat " + format + " 1.0\n" +
+				"element camera 1\nproperty list uchar float pose\n" +
+				"element vertex 2\nproperty double x\nproperty double y\nproperty float z\n" +
+				"property ushort red\nproperty ushort green\nproperty ushort blue\nproperty uchar classification\nproperty short other\n" +
+				"property ushort intensity\n" +
+				"element face 1\nproperty list uchar int vertex_indices\n" +
+				"end_header\n")
+			// camera element to skip
+			binary.Write(b, order, uint8(2))
+			binary.Write(b, order, []float32{1, 2})
+			for i := 0; i < 2; i++ {
+				binary.Write(b, order, []float64{float64(i) + 0.5, float64(i) + 1.5})
+				binary.Write(b, order, float32(i)+2.5)
+				binary.Write(b, order, []uint16{65535, 32768, uint16(i * 256)})
+				binary.Write(b, order, uint8(i+2))
+				binary.Write(b, order, int16(-5))
+				binary.Write(b, order, uint16(12+i*300))
+			}
+			binary.Write(b, order, uint8(0))
+			r, err := NewPlyReader(writeTextFile(t, "cloud.ply", b.String()), "EPSG:32633")
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			defer r.Close()
+			expected := []geom.Point64{
+				{Vector: model.Vector{X: 0.5, Y: 1.5, Z: 2.5}, R: 255, G: 128, B: 0, Intensity: 12, Classification: 2},
+				{Vector: model.Vector{X: 1.5, Y: 2.5, Z: 3.5}, R: 255, G: 128, B: 1, Intensity: 255, Classification: 3},
+			}
+			checkPlyPoints(t, r, expected)
+		})
+	}
+}
+
+func TestPlyReaderIntensity(t *testing.T) {
+	tcs := []struct {
+		typ      string
+		value    string
+		expected uint8
+	}{
+		{typ: "uchar", value: "200", expected: 200},
+		{typ: "char", value: "-3", expected: 0},
+		{typ: "ushort", value: "65535", expected: 255},
+		{typ: "ushort", value: "100", expected: 100},
+		{typ: "float", value: "0.2", expected: 0},
+		{typ: "float", value: "12.6", expected: 13},
+		{typ: "double", value: "-0.5", expected: 0},
+		{typ: "double", value: "250", expected: 250},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.typ+" "+tc.value, func(t *testing.T) {
+			content := "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nproperty float z\n" +
+				"property " + tc.typ + " intensity\nend_header\n1 2 3 " + tc.value + "\n"
+			r, err := NewPlyReader(writeTextFile(t, "cloud.ply", content), "EPSG:32633")
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			defer r.Close()
+			pt, err := r.GetNext()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if pt.Intensity != tc.expected {
+				t.Errorf("expected intensity %d got %d", tc.expected, pt.Intensity)
+			}
+		})
+	}
+}
+
+func checkPlyPoints(t *testing.T, r *PlyReader, expected []geom.Point64) {
+	if actual := r.NumberOfPoints(); actual != len(expected) {
+		t.Errorf("expected %d points got %d", len(expected), actual)
+	}
+	if actual := r.GetCRS(); actual != "EPSG:32633" {
+		t.Errorf("expected crs %s got %s", "EPSG:32633", actual)
+	}
+	for i, e := range expected {
+		actual, err := r.GetNext()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !reflect.DeepEqual(actual, e) {
+			t.Errorf("point %d: expected %v got %v", i, e, actual)
+		}
+	}
+	if _, err := r.GetNext(); err != io.EOF {
+		t.Errorf("expected EOF got %v", err)
+	}
+}
+
+func TestPlyReaderErrors(t *testing.T) {
+	tcs := []struct {
+		name    string
+		content string
+	}{
+		{name: "signature", content: "plx\nformat ascii 1.0\nend_header\n"},
+		{name: "no format", content: "ply\nelement vertex 1\nproperty float x\nend_header\n"},
+		{name: "bad format", content: "ply\nformat binary 1.0\nelement vertex 1\nproperty float x\nend_header\n"},
+		{name: "no vertex", content: "ply\nformat ascii 1.0\nelement face 0\nproperty list uchar int vertex_indices\nend_header\n"},
+		{name: "no z", content: "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nend_header\n1 2\n"},
+		{name: "bad type", content: "ply\nformat ascii 1.0\nelement vertex 1\nproperty float128 x\nend_header\n"},
+		{name: "truncated header", content: "ply\nformat ascii 1.0\nelement vertex 1\n"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewPlyReader(writeTextFile(t, "cloud.ply", tc.content), "EPSG:32633"); err == nil {
+				t.Errorf("expected error, got none")
+			}
+		})
+	}
+	valid := writeTextFile(t, "cloud.ply", "ply\nformat ascii 1.0\nelement vertex 2\nproperty float x\nproperty float y\nproperty float z\nend_header\n1 2 3\n")
+	if _, err := NewPlyReader(valid, ""); err == nil {
+		t.Errorf("expected error for missing crs, got none")
+	}
+	r, err := NewPlyReader(valid, "EPSG:32633")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+	if _, err := r.GetNext(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := r.GetNext(); err == nil || err == io.EOF {
+		t.Errorf("expected truncated file error, got %v", err)
+	}
+}
+
+func TestCombinedReaderPly(t *testing.T) {
+	ply := writeTextFile(t, "cloud.ply", "ply\nformat ascii 1.0\nelement vertex 2\nproperty float x\nproperty float y\nproperty float z\nend_header\n1 2 3\n4 5 6\n")
+	r, err := NewCombinedFileLasReader([]string{"./testdata/las-12-pf1.las", ply}, SameCRS("EPSG:32633"), false, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+	if actual := r.NumberOfPoints(); actual != 12 {
+		t.Errorf("expected %d points got %d", 12, actual)
+	}
+	for i := 0; i < r.NumberOfPoints(); i++ {
+		if _, err := r.GetNext(); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+}
@@ -1,14 +1,17 @@
 package las
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync/atomic"
 
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las/golas"
-	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
 )
 
 // LasReader wraps
@@ -19,40 +22,183 @@ type LasReader interface {
 	GetNext() (geom.Point64, error)
 	// GetCRS returns a string defining the CRS. This is typically a string of the form EPSG:XYZ where XYZ is the EPSG code of the CRS.
 	GetCRS() string
+	// Attributes returns the additional attributes of the points, whose values are returned by the readers
+	// implementing AttributeReader
+	Attributes() []geom.Attribute
 	// Close closes the reader
 	Close()
 }
 
-// CombinedFileLasReader enables reading a a list of LAS files as if they were a single one
-// the files MUST have the same properties (SRID, etc)
+// PointReader reads a sequence of points
+type PointReader interface {
+	// GetNext returns the next point, or io.EOF once all points have been read
+	GetNext() (geom.Point64, error)
+	// Close closes the reader
+	Close()
+}
+
+// AttributeReader is implemented by the PointReaders able to decode the additional attributes of the points,
+// see LasReader.Attributes
+type AttributeReader interface {
+	// GetNextWithAttributes works as GetNext, also storing the values of the additional attributes of the point in
+	// attrs, that must have room for the geom.AttributesLength of the attributes
+	GetNextWithAttributes(attrs []float64) (geom.Point64, error)
+}
+
+// NextPoint returns the next point of the reader, storing the values of its additional attributes in attrs if not
+// empty. Readers not implementing AttributeReader leave attrs untouched.
+func NextPoint(r PointReader, attrs []float64) (geom.Point64, error) {
+	if ar, ok := r.(AttributeReader); ok && len(attrs) > 0 {
+		return ar.GetNextWithAttributes(attrs)
+	}
+	return r.GetNext()
+}
+
+// ErrRangesNotSupported is returned by the RangeReaders whose points can only be read sequentially
+var ErrRangesNotSupported = errors.New("the points can only be read sequentially")
+
+// RangeReader is implemented by the LasReaders able to read any range of their points independently of the
+// others, hence allowing multiple goroutines to read different ranges in parallel without contention.
+type RangeReader interface {
+	// ReadRange returns a reader of the points with index in [start, end), in the same order and with the same values
+	// returned by GetNext. Reading ranges does not affect GetNext. Returns ErrRangesNotSupported if the points can only
+	// be read sequentially, e.g. if they are compressed.
+	ReadRange(start, end int) (PointReader, error)
+}
+
+// FileCRS returns the CRS of the given input file, or the empty string if it should be autodetected from the file
+// metadata
+type FileCRS func(file string) string
+
+// SameCRS returns a FileCRS assigning the given CRS to all files. If crs is empty the CRS of all files is autodetected.
+func SameCRS(crs string) FileCRS {
+	return func(string) string {
+		return crs
+	}
+}
+
+// MappedCRS returns a FileCRS assigning to each file the CRS mapped to its path in the given map, and the default CRS
+// to the files not in the map. Paths are compared after being made absolute.
+func MappedCRS(crss map[string]string, defaultCRS string) FileCRS {
+	abs := make(map[string]string, len(crss))
+	for f, crs := range crss {
+		abs[absPath(f)] = crs
+	}
+	return func(file string) string {
+		if crs, ok := abs[absPath(file)]; ok {
+			return crs
+		}
+		return defaultCRS
+	}
+}
+
+// absPath returns the absolute and clean version of the given path, or the clean path if it cannot be made absolute
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// CombinedFileLasReader enables reading a a list of LAS files as if they were a single one.
+// The files MUST have the same attributes, while they can have different CRSs: in that case GetCRS returns
+// the empty string and the CRS of each point returned is set in its CRS field.
 type CombinedFileLasReader struct {
 	currentReader atomic.Int32
 	readers       []LasReader
 	numPts        int
 	crs           string
+	// crss stores the CRS of each reader
+	crss       []string
+	attributes []geom.Attribute
 }
 
-// NewCombinedFileReader creates a new file reader for the files passed as input. If crs is the empty string, the
-// reader will autodetect the CRS from the input files, however an error is returned if the CRS is not consistent across
-// all of them or if it's not found in the files.
-func NewCombinedFileLasReader(files []string, crs string, eightBitColor bool) (*CombinedFileLasReader, error) {
+// NewCombinedFileReader creates a new file reader for the files passed as input, each in the CRS returned by crs.
+// If the CRS returned for a file is the empty string, the reader will autodetect it from the file metadata and an
+// error is returned if it's not found. Files with different CRSs can be combined.
+// COPC files are read node by node, filtered according to the given COPC options, when any are provided.
+// lasAttrs lists the standard LAS attributes to read, see the LasAttribute constants, and extraDims the names of the
+// LAS extra bytes attributes to read. They must be available in all the files.
+func NewCombinedFileLasReader(files []string, crs FileCRS, eightBitColor bool, lasAttrs []string, extraDims []string, copcOpts ...func(*CopcReader)) (*CombinedFileLasReader, error) {
+	return newCombinedReader(files, crs, func(f string, crs string) (LasReader, error) {
+		return newFileLasReader(f, crs, eightBitColor, lasAttrs, extraDims, copcOpts...)
+	})
+}
+
+// NewCombinedFileTextReader creates a new reader for the text files passed as input, all sharing the
+// given format. The crs of each file is mandatory as text files carry no CRS metadata.
+func NewCombinedFileTextReader(files []string, crs FileCRS, eightBitColor bool, format TextFormat) (*CombinedFileLasReader, error) {
+	return newCombinedReader(files, crs, func(f string, crs string) (LasReader, error) {
+		return NewTextReader(f, crs, eightBitColor, format)
+	})
+}
+
+func newCombinedReader(files []string, crs FileCRS, open func(f string, crs string) (LasReader, error)) (*CombinedFileLasReader, error) {
 	r := &CombinedFileLasReader{}
-	crsProvided := crs != ""
 	for _, f := range files {
-		fr, err := NewGoLasReader(f, crs, eightBitColor)
+		fileCRS := crs(f)
+		fr, err := open(f, fileCRS)
 		if err != nil {
+			r.Close()
 			return nil, err
 		}
 		r.numPts += fr.NumberOfPoints()
 		r.readers = append(r.readers, fr)
-		if !crsProvided {
-			if crs != "" && crs != fr.GetCRS() {
-				return nil, fmt.Errorf("no CRS was provided and inconsistent CRS were detected:\n%s\n\n and\n\n%s", crs, fr.GetCRS())
-			}
-			crs = fr.GetCRS()
+		if len(r.readers) == 1 {
+			r.attributes = fr.Attributes()
+		} else if !slices.Equal(r.attributes, fr.Attributes()) {
+			r.Close()
+			return nil, fmt.Errorf("inconsistent attributes detected in file %s", f)
+		}
+		if fileCRS == "" {
+			fileCRS = fr.GetCRS()
+		}
+		r.crss = append(r.crss, fileCRS)
+	}
+	if len(r.crss) > 0 {
+		r.crs = r.crss[0]
+	}
+	for _, c := range r.crss {
+		if c != r.crs {
+			// the points carry their own CRS
+			r.crs = ""
+			break
 		}
 	}
-	r.crs = crs
+	return r, nil
+}
+
+// newFileLasReader returns a reader for the given file. COPC files are read via a CopcReader when COPC options are given,
+// files with the .ply extension are read via a PlyReader and files with the .e57 extension via an E57Reader.
+// LAS attributes and extra dimensions are only supported for LAS and LAZ files.
+func newFileLasReader(fileName string, crs string, eightBitColor bool, lasAttrs []string, extraDims []string, copcOpts ...func(*CopcReader)) (LasReader, error) {
+	switch ext := strings.ToLower(filepath.Ext(fileName)); ext {
+	case ".ply", ".e57":
+		if len(lasAttrs) > 0 || len(extraDims) > 0 {
+			return nil, fmt.Errorf("LAS attributes and extra dimensions are not supported for %s files", ext)
+		}
+		if ext == ".ply" {
+			return NewPlyReader(fileName, crs)
+		}
+		return NewE57Reader(fileName, crs)
+	}
+	if len(copcOpts) == 0 {
+		return NewGoLasReader(fileName, crs, eightBitColor, lasAttrs, extraDims)
+	}
+	f, g, crs, err := openLas(fileName, crs)
+	if err != nil {
+		return nil, err
+	}
+	var r LasReader
+	if g.CopcInfo() == nil {
+		r, err = newGoLasReader(f, g, crs, eightBitColor, lasAttrs, extraDims)
+	} else {
+		r, err = newCopcReader(f, g, crs, eightBitColor, lasAttrs, extraDims, copcOpts...)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
 	return r, nil
 }
 
@@ -60,26 +206,73 @@ func (m *CombinedFileLasReader) NumberOfPoints() int {
 	return m.numPts
 }
 
+// GetCRS returns the CRS of the files, or the empty string if they have different CRSs
 func (m *CombinedFileLasReader) GetCRS() string {
 	return m.crs
 }
 
+// FileCRSs returns the CRS of each file, in the order the files have been given
+func (m *CombinedFileLasReader) FileCRSs() []string {
+	return m.crss
+}
+
+func (m *CombinedFileLasReader) Attributes() []geom.Attribute {
+	return m.attributes
+}
+
 func (m *CombinedFileLasReader) GetNext() (geom.Point64, error) {
+	return m.GetNextWithAttributes(nil)
+}
+
+func (m *CombinedFileLasReader) GetNextWithAttributes(attrs []float64) (geom.Point64, error) {
 	for {
 		currReader := int(m.currentReader.Load())
 		if currReader >= len(m.readers) {
 			return geom.Point64{}, io.EOF
 		}
-		pt, err := m.readers[currReader].GetNext()
+		pt, err := NextPoint(m.readers[currReader], attrs)
 		if err != nil {
 			// try to move on to the next reader
 			m.currentReader.CompareAndSwap(int32(currReader), int32(currReader)+1)
 			continue
 		}
+		if m.crs == "" {
+			pt.CRS = m.crss[currReader]
+		}
 		return pt, nil
 	}
 }
 
+// ReadRange returns a reader of the points with index in [start, end), the points of all files being indexed in
+// order. Ranges are only supported if all the files support them.
+func (m *CombinedFileLasReader) ReadRange(start, end int) (PointReader, error) {
+	chained := &chainedPointReader{}
+	offset := 0
+	for i, r := range m.readers {
+		n := r.NumberOfPoints()
+		from, to := max(start, offset)-offset, min(end, offset+n)-offset
+		offset += n
+		if from >= to {
+			continue
+		}
+		rr, ok := r.(RangeReader)
+		if !ok {
+			chained.Close()
+			return nil, ErrRangesNotSupported
+		}
+		pr, err := rr.ReadRange(from, to)
+		if err != nil {
+			chained.Close()
+			return nil, err
+		}
+		if m.crs == "" {
+			pr = &crsPointReader{PointReader: pr, crs: m.crss[i]}
+		}
+		chained.readers = append(chained.readers, pr)
+	}
+	return chained, nil
+}
+
 func (m *CombinedFileLasReader) Close() {
 	for _, r := range m.readers {
 		r.Close()
@@ -88,37 +281,64 @@ func (m *CombinedFileLasReader) Close() {
 
 // GoLasReader wraps a golas.Las object implementing the specific interface LasReader required by gocesiumtiler
 type GoLasReader struct {
-	file          *os.File
-	f             *golas.Las
-	eightBitColor bool
-	crs           string
+	file    *os.File
+	f       *golas.Las
+	decoder pointDecoder
+	crs     string
 }
 
 // NewGoLasReader returns a GoLasReader instance. If crs is empty the system will attempt to autodetect
-// the CRS from the LAS metadata and return an error in case of issues.
-func NewGoLasReader(fileName string, crs string, eightBitColor bool) (*GoLasReader, error) {
-	f, err := os.Open(fileName)
+// the CRS from the LAS metadata or from a sidecar file, see SidecarCRS, and return an error in case of issues. lasAttrs lists the standard LAS
+// attributes to read, see the LasAttribute constants, and extraDims the names of the extra bytes attributes to read.
+func NewGoLasReader(fileName string, crs string, eightBitColor bool, lasAttrs []string, extraDims []string) (*GoLasReader, error) {
+	f, g, crs, err := openLas(fileName, crs)
 	if err != nil {
 		return nil, err
 	}
-	g, err := golas.NewLas(f)
+	r, err := newGoLasReader(f, g, crs, eightBitColor, lasAttrs, extraDims)
 	if err != nil {
 		f.Close()
+		return nil, fmt.Errorf("unable to read LAS file %s: %w", fileName, err)
+	}
+	return r, nil
+}
+
+func newGoLasReader(f *os.File, g *golas.Las, crs string, eightBitColor bool, lasAttrs []string, extraDims []string) (*GoLasReader, error) {
+	d, err := newPointDecoder(g, eightBitColor, lasAttrs, extraDims)
+	if err != nil {
 		return nil, err
 	}
+	return &GoLasReader{
+		file:    f,
+		f:       g,
+		decoder: d,
+		crs:     crs,
+	}, nil
+}
+
+// openLas opens the given LAS file, autodetecting the CRS from the LAS metadata, or from a sidecar file if the
+// metadata has none, if crs is empty
+func openLas(fileName string, crs string) (*os.File, *golas.Las, string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	g, err := golas.NewLas(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, "", err
+	}
 	if crs == "" {
 		crs = g.CRS()
-		if crs == "" {
-			f.Close()
-			return nil, fmt.Errorf("no CRS provided and was not possible to determine CRS from LAS file %s", fileName)
-		}
 	}
-	return &GoLasReader{
-		file:          f,
-		f:             g,
-		eightBitColor: eightBitColor,
-		crs:           crs,
-	}, nil
+	if crs == "" {
+		crs = SidecarCRS(fileName)
+	}
+	if crs == "" {
+		f.Close()
+		return nil, nil, "", fmt.Errorf("no CRS provided and was not possible to determine CRS from LAS file %s nor from a sidecar .prj or .wkt file", fileName)
+	}
+	return f, g, crs, nil
 }
 
 func (f *GoLasReader) NumberOfPoints() int {
@@ -126,7 +346,11 @@ func (f *GoLasReader) NumberOfPoints() int {
 }
 
 func (f *GoLasReader) GetCRS() string {
-	return f.f.CRS()
+	return f.crs
+}
+
+func (f *GoLasReader) Attributes() []geom.Attribute {
+	return f.decoder.attributes
 }
 
 func (f *GoLasReader) Close() {
@@ -134,24 +358,106 @@ func (f *GoLasReader) Close() {
 }
 
 func (f *GoLasReader) GetNext() (geom.Point64, error) {
+	return f.GetNextWithAttributes(nil)
+}
+
+func (f *GoLasReader) GetNextWithAttributes(attrs []float64) (geom.Point64, error) {
 	pt, err := f.f.Next()
 	if err != nil {
 		return geom.Point64{}, err
 	}
-	var corr uint16 = 256
-	if f.eightBitColor {
-		corr = 1
-	}
-	return geom.Point64{
-		Vector: model.Vector{
-			X: pt.X,
-			Y: pt.Y,
-			Z: pt.Z,
-		},
-		R:              uint8(pt.Red / corr),
-		G:              uint8(pt.Green / corr),
-		B:              uint8(pt.Blue / corr),
-		Intensity:      uint8(pt.Intensity),
-		Classification: pt.Classification,
+	return f.decoder.decode(pt, attrs)
+}
+
+// ReadRange returns a reader of the points with index in [start, end), reading them from the file in batches
+// independently of the other readers. LAZ files are not supported.
+func (f *GoLasReader) ReadRange(start, end int) (PointReader, error) {
+	rr, err := f.f.NewRangeReader(f.file, uint64(start), uint64(end))
+	if errors.Is(err, golas.ErrCompressed) {
+		return nil, ErrRangesNotSupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &goLasRangeReader{
+		r:       rr,
+		decoder: f.decoder,
+		batch:   make([]golas.Point, min(rangeBatchSize, end-start)),
 	}, nil
 }
+
+// rangeBatchSize is the number of points decoded at once by a goLasRangeReader
+const rangeBatchSize = 4096
+
+// goLasRangeReader reads a range of points of a LAS file, decoding them in batches
+type goLasRangeReader struct {
+	r       *golas.RangeReader
+	decoder pointDecoder
+	batch   []golas.Point
+	// cur and n are the index of the next point to return and the number of points in the batch
+	cur, n int
+}
+
+func (r *goLasRangeReader) GetNext() (geom.Point64, error) {
+	return r.GetNextWithAttributes(nil)
+}
+
+func (r *goLasRangeReader) GetNextWithAttributes(attrs []float64) (geom.Point64, error) {
+	if r.cur >= r.n {
+		n, err := r.r.Read(r.batch)
+		if err != nil {
+			return geom.Point64{}, err
+		}
+		r.cur, r.n = 0, n
+	}
+	pt := r.batch[r.cur]
+	r.cur++
+	return r.decoder.decode(pt, attrs)
+}
+
+// Close does nothing as the file is owned by the GoLasReader
+func (r *goLasRangeReader) Close() {}
+
+// chainedPointReader reads the points of multiple readers, one after the other
+type chainedPointReader struct {
+	readers []PointReader
+	cur     int
+}
+
+func (c *chainedPointReader) GetNext() (geom.Point64, error) {
+	return c.GetNextWithAttributes(nil)
+}
+
+func (c *chainedPointReader) GetNextWithAttributes(attrs []float64) (geom.Point64, error) {
+	for c.cur < len(c.readers) {
+		pt, err := NextPoint(c.readers[c.cur], attrs)
+		if errors.Is(err, io.EOF) {
+			c.cur++
+			continue
+		}
+		return pt, err
+	}
+	return geom.Point64{}, io.EOF
+}
+
+func (c *chainedPointReader) Close() {
+	for _, r := range c.readers {
+		r.Close()
+	}
+}
+
+// crsPointReader sets the given CRS to the points read
+type crsPointReader struct {
+	PointReader
+	crs string
+}
+
+func (c *crsPointReader) GetNext() (geom.Point64, error) {
+	return c.GetNextWithAttributes(nil)
+}
+
+func (c *crsPointReader) GetNextWithAttributes(attrs []float64) (geom.Point64, error) {
+	pt, err := NextPoint(c.PointReader, attrs)
+	pt.CRS = c.crs
+	return pt, err
+}
@@ -1,10 +1,15 @@
 package las
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"reflect"
 	"sync"
 	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
 )
 
 func TestCombinedReader(t *testing.T) {
@@ -19,7 +24,7 @@ func TestCombinedReader(t *testing.T) {
 		files = append(files, fmt.Sprintf("./testdata/%s", filename))
 	}
 
-	r, err := NewCombinedFileLasReader(files, "EPSG:32633", false)
+	r, err := NewCombinedFileLasReader(files, SameCRS("EPSG:32633"), false, nil, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -56,7 +61,7 @@ func TestCombinedReaderConcurrency(t *testing.T) {
 		files = append(files, fmt.Sprintf("./testdata/%s", filename))
 	}
 
-	r, err := NewCombinedFileLasReader(files, "EPSG:32633", false)
+	r, err := NewCombinedFileLasReader(files, SameCRS("EPSG:32633"), false, nil, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -94,3 +99,140 @@ func TestCombinedReaderConcurrency(t *testing.T) {
 		t.Errorf("errors detected in the error channel but none expected")
 	}
 }
+
+func TestCombinedReaderReadRange(t *testing.T) {
+	files := []string{"./testdata/las-12-pf1.las", "./testdata/las-12-pf2.las"}
+	r, err := NewCombinedFileLasReader(files, SameCRS("EPSG:32633"), false, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	expected := []geom.Point64{}
+	for i := 0; i < r.NumberOfPoints(); i++ {
+		pt, err := r.GetNext()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		expected = append(expected, pt)
+	}
+
+	// ranges within a file and spanning both, read in parallel
+	ranges := [][2]int{{0, 3}, {3, 14}, {14, 20}, {5, 5}}
+	actual := make([][]geom.Point64, len(ranges))
+	errs := make([]error, len(ranges))
+	wg := &sync.WaitGroup{}
+	for i, rg := range ranges {
+		wg.Add(1)
+		go func(i int, rg [2]int) {
+			defer wg.Done()
+			pr, err := r.ReadRange(rg[0], rg[1])
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer pr.Close()
+			for {
+				pt, err := pr.GetNext()
+				if errors.Is(err, io.EOF) {
+					return
+				}
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				actual[i] = append(actual[i], pt)
+			}
+		}(i, rg)
+	}
+	wg.Wait()
+	for i, rg := range ranges {
+		if errs[i] != nil {
+			t.Fatalf("unexpected error: %v", errs[i])
+		}
+		if len(actual[i]) != rg[1]-rg[0] {
+			t.Fatalf("range %v: expected %d points got %d", rg, rg[1]-rg[0], len(actual[i]))
+		}
+		for j, pt := range actual[i] {
+			if !reflect.DeepEqual(pt, expected[rg[0]+j]) {
+				t.Errorf("range %v: expected point %v got %v", rg, expected[rg[0]+j], pt)
+			}
+		}
+	}
+}
+
+func TestCombinedReaderReadRangeCompressed(t *testing.T) {
+	files := []string{"./testdata/las-12-pf1.las", "./golas/testdata/1.2-with-color.laz"}
+	r, err := NewCombinedFileLasReader(files, SameCRS("EPSG:32633"), false, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	// the range of the uncompressed file only is supported
+	pr, err := r.ReadRange(0, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pr.Close()
+	if _, err := r.ReadRange(5, 15); !errors.Is(err, ErrRangesNotSupported) {
+		t.Errorf("expected error %v got %v", ErrRangesNotSupported, err)
+	}
+}
+
+func TestCombinedReaderFileCRS(t *testing.T) {
+	files := []string{"./testdata/las-12-pf1.las", "./testdata/las-12-pf2.las"}
+	crs := MappedCRS(map[string]string{"testdata/las-12-pf2.las": "EPSG:32632"}, "EPSG:32633")
+	if actual := crs("./testdata/las-12-pf2.las"); actual != "EPSG:32632" {
+		t.Errorf("expected crs %s got %s", "EPSG:32632", actual)
+	}
+	if actual := crs("./testdata/las-12-pf1.las"); actual != "EPSG:32633" {
+		t.Errorf("expected crs %s got %s", "EPSG:32633", actual)
+	}
+
+	r, err := NewCombinedFileLasReader(files, crs, false, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	if actual := r.GetCRS(); actual != "" {
+		t.Errorf("expected no common crs got %s", actual)
+	}
+	if actual := r.FileCRSs(); !reflect.DeepEqual(actual, []string{"EPSG:32633", "EPSG:32632"}) {
+		t.Errorf("expected file crss %v got %v", []string{"EPSG:32633", "EPSG:32632"}, actual)
+	}
+	// each point carries the CRS of its file, both reading sequentially and by range
+	expected := make([]string, 0, r.NumberOfPoints())
+	for i := 0; i < r.NumberOfPoints(); i++ {
+		pt, err := r.GetNext()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected = append(expected, pt.CRS)
+	}
+	if expected[0] != "EPSG:32633" || expected[len(expected)-1] != "EPSG:32632" {
+		t.Errorf("unexpected point crss %v", expected)
+	}
+	pr, err := r.ReadRange(5, 15)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer pr.Close()
+	for i := 5; i < 15; i++ {
+		pt, err := pr.GetNext()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pt.CRS != expected[i] {
+			t.Errorf("point %d: expected crs %s got %s", i, expected[i], pt.CRS)
+		}
+	}
+
+	// with the same CRS the points do not carry it
+	r, err = NewCombinedFileLasReader(files, SameCRS("EPSG:32633"), false, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	if pt, err := r.GetNext(); err != nil || pt.CRS != "" {
+		t.Errorf("expected no point crs got %q %v", pt.CRS, err)
+	}
+}
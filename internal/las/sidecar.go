@@ -0,0 +1,31 @@
+package las
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sidecarExtensions lists the extensions of the files that can store the CRS of a point cloud file
+var sidecarExtensions = []string{".prj", ".wkt", ".PRJ", ".WKT"}
+
+// SidecarCRS returns the CRS stored in a sidecar file next to the given point cloud file, or the empty string if
+// none is found. The sidecar file has the same name of the point cloud file, with or without its extension,
+// followed by a .prj or .wkt extension, e.g. cloud.prj or cloud.las.prj for cloud.las, and contains any CRS
+// definition understood by PROJ, typically a WKT string as written by GIS software.
+func SidecarCRS(fileName string) string {
+	base := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	for _, name := range []string{base, fileName} {
+		for _, ext := range sidecarExtensions {
+			content, err := os.ReadFile(name + ext)
+			if err != nil {
+				continue
+			}
+			// the UTF-8 byte order mark written by some editors is not part of the definition
+			if crs := strings.TrimSpace(strings.TrimPrefix(string(content), "\ufeff")); crs != "" {
+				return crs
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package las
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func copyFile(t *testing.T, src string, dst string) {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSidecarCRS(t *testing.T) {
+	wkt := `PROJCS["WGS 84 / UTM zone 33N",GEOGCS["WGS 84"]]`
+	tcs := []struct {
+		name     string
+		sidecar  string
+		content  string
+		expected string
+	}{
+		{name: "prj without extension", sidecar: "cloud.prj", content: wkt + "\n", expected: wkt},
+		{name: "wkt with extension", sidecar: "cloud.xyz.wkt", content: "\ufeff EPSG:32633 ", expected: "EPSG:32633"},
+		{name: "uppercase", sidecar: "cloud.PRJ", content: wkt, expected: wkt},
+		{name: "empty", sidecar: "cloud.prj", content: " \n", expected: ""},
+		{name: "unrelated", sidecar: "other.prj", content: wkt, expected: ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tc.sidecar), []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if actual := SidecarCRS(filepath.Join(dir, "cloud.xyz")); actual != tc.expected {
+				t.Errorf("expected crs %q got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestReadersSidecarCRS(t *testing.T) {
+	dir := t.TempDir()
+	las := filepath.Join(dir, "cloud.las")
+	copyFile(t, "./testdata/las-12-pf1.las", las)
+	if _, err := NewGoLasReader(las, "", false, nil, nil); err == nil {
+		t.Fatalf("expected error without crs and sidecar file")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cloud.prj"), []byte("EPSG:32633"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewCombinedFileLasReader([]string{las}, SameCRS(""), false, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+	if actual := r.GetCRS(); actual != "EPSG:32633" {
+		t.Errorf("expected crs %s got %s", "EPSG:32633", actual)
+	}
+
+	// the crs provided explicitly takes precedence over the sidecar file
+	lr, err := NewGoLasReader(las, "EPSG:32632", false, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer lr.Close()
+	if actual := lr.GetCRS(); actual != "EPSG:32632" {
+		t.Errorf("expected crs %s got %s", "EPSG:32632", actual)
+	}
+
+	format, err := NewTextFormat("x y z", " ", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt := writeTextFile(t, "cloud.txt", "1 2 3\n")
+	if err := os.WriteFile(filepath.Join(filepath.Dir(txt), "cloud.txt.wkt"), []byte("EPSG:4979"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := NewTextReader(txt, "", false, format)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer tr.Close()
+	if actual := tr.GetCRS(); actual != "EPSG:4979" {
+		t.Errorf("expected crs %s got %s", "EPSG:4979", actual)
+	}
+}
@@ -0,0 +1,281 @@
+package las
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// TextFormat describes the layout of a point cloud stored as delimited text, such as XYZ, CSV or TXT files.
+// Column indexes are zero based, -1 means the column is not present.
+type TextFormat struct {
+	X              int
+	Y              int
+	Z              int
+	R              int
+	G              int
+	B              int
+	Intensity      int
+	Classification int
+	// Delimiter separates the columns. If empty, columns are separated by any sequence of whitespaces.
+	Delimiter string
+	// SkipRows is the number of header rows to skip at the beginning of the file
+	SkipRows int
+	// ColorBits is the bit depth of the colors, 8 or 16. If 0 the colors are read as 16 bit colors unless the
+	// reader is set to read 8 bit colors.
+	ColorBits int
+}
+
+// NewTextFormat returns the TextFormat described by the given column spec, delimiter, rows to skip and color bits.
+// The column spec lists the content of each column, separated by commas or spaces, using the following
+// identifiers: x, y, z, r, g, b, i (intensity), c (classification). Columns to ignore are marked with _.
+// The x, y and z columns are mandatory. As an example, "x,y,z,_,r,g,b" describes a file where the 4th column
+// is ignored.
+func NewTextFormat(columns string, delimiter string, skipRows int, colorBits int) (TextFormat, error) {
+	f := TextFormat{
+		X: -1, Y: -1, Z: -1, R: -1, G: -1, B: -1, Intensity: -1, Classification: -1,
+		Delimiter: delimiter,
+		SkipRows:  skipRows,
+		ColorBits: colorBits,
+	}
+	if skipRows < 0 {
+		return f, fmt.Errorf("invalid number of rows to skip %d", skipRows)
+	}
+	if colorBits != 0 && colorBits != 8 && colorBits != 16 {
+		return f, fmt.Errorf("invalid color bits %d, expected 8 or 16", colorBits)
+	}
+	if delimiter == "tab" || delimiter == `\t` {
+		f.Delimiter = "\t"
+	}
+	fields := map[string]*int{
+		"x": &f.X, "y": &f.Y, "z": &f.Z,
+		"r": &f.R, "g": &f.G, "b": &f.B,
+		"i": &f.Intensity, "c": &f.Classification,
+	}
+	specs := strings.FieldsFunc(strings.ToLower(columns), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	for i, s := range specs {
+		if s == "_" {
+			continue
+		}
+		field, ok := fields[s]
+		if !ok {
+			return f, fmt.Errorf("unknown column identifier %q", s)
+		}
+		if *field != -1 {
+			return f, fmt.Errorf("column %q specified more than once", s)
+		}
+		*field = i
+	}
+	if f.X == -1 || f.Y == -1 || f.Z == -1 {
+		return f, errors.New("the x, y and z columns are mandatory")
+	}
+	return f, nil
+}
+
+// numColumns returns the minimum number of columns each row should have
+func (f TextFormat) numColumns() int {
+	n := 0
+	for _, c := range []int{f.X, f.Y, f.Z, f.R, f.G, f.B, f.Intensity, f.Classification} {
+		n = max(n, c+1)
+	}
+	return n
+}
+
+// split splits a row into its columns
+func (f TextFormat) split(row string) []string {
+	if f.Delimiter == "" {
+		return strings.Fields(row)
+	}
+	cols := strings.Split(row, f.Delimiter)
+	for i := range cols {
+		cols[i] = strings.TrimSpace(cols[i])
+	}
+	return cols
+}
+
+// TextReader reads point clouds stored as delimited text, implementing the LasReader interface.
+// Empty rows and rows starting with # are ignored.
+type TextReader struct {
+	file          *os.File
+	scanner       *bufio.Scanner
+	format        TextFormat
+	eightBitColor bool
+	crs           string
+	numPts        int
+	row           int
+	sync.Mutex
+}
+
+// NewTextReader returns a TextReader for the given file. Text files carry no CRS metadata,
+// therefore the crs is mandatory unless a sidecar file stores it, see SidecarCRS. The ColorBits of the format,
+// if set, take precedence over eightBitColor.
+func NewTextReader(fileName string, crs string, eightBitColor bool, format TextFormat) (*TextReader, error) {
+	if crs == "" {
+		crs = SidecarCRS(fileName)
+	}
+	if crs == "" {
+		return nil, fmt.Errorf("a CRS must be provided to read the text file %s", fileName)
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if format.ColorBits != 0 {
+		eightBitColor = format.ColorBits == 8
+	}
+	r := &TextReader{
+		file:          f,
+		format:        format,
+		eightBitColor: eightBitColor,
+		crs:           crs,
+	}
+	// count the points upfront as the number of points must be known before reading them
+	if err := r.rewind(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	for {
+		_, err := r.nextRow()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("unable to read text file %s: %w", fileName, err)
+		}
+		r.numPts++
+	}
+	if err := r.rewind(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// rewind moves the reader to the first data row
+func (r *TextReader) rewind() error {
+	if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r.scanner = bufio.NewScanner(r.file)
+	r.row = 0
+	for i := 0; i < r.format.SkipRows; i++ {
+		if !r.scanner.Scan() {
+			break
+		}
+		r.row++
+	}
+	return r.scanner.Err()
+}
+
+// nextRow returns the next row storing point data
+func (r *TextReader) nextRow() (string, error) {
+	for r.scanner.Scan() {
+		r.row++
+		row := strings.TrimSpace(r.scanner.Text())
+		if row == "" || strings.HasPrefix(row, "#") {
+			continue
+		}
+		return row, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+func (r *TextReader) NumberOfPoints() int {
+	return r.numPts
+}
+
+func (r *TextReader) GetCRS() string {
+	return r.crs
+}
+
+// Attributes returns nil as text files do not carry additional attributes
+func (r *TextReader) Attributes() []geom.Attribute {
+	return nil
+}
+
+func (r *TextReader) Close() {
+	r.file.Close()
+}
+
+func (r *TextReader) GetNext() (geom.Point64, error) {
+	r.Lock()
+	row, err := r.nextRow()
+	rowNum := r.row
+	r.Unlock()
+	if err != nil {
+		return geom.Point64{}, err
+	}
+	pt, err := r.parse(row)
+	if err != nil {
+		return geom.Point64{}, fmt.Errorf("row %d: %w", rowNum, err)
+	}
+	return pt, nil
+}
+
+// parse converts a row into a point according to the text format
+func (r *TextReader) parse(row string) (geom.Point64, error) {
+	cols := r.format.split(row)
+	if len(cols) < r.format.numColumns() {
+		return geom.Point64{}, fmt.Errorf("expected at least %d columns, found %d", r.format.numColumns(), len(cols))
+	}
+	var err error
+	value := func(col int) float64 {
+		if col < 0 || err != nil {
+			return 0
+		}
+		var v float64
+		v, err = strconv.ParseFloat(cols[col], 64)
+		return v
+	}
+	maxColor := 65535.0
+	var corr uint16 = 256
+	if r.eightBitColor {
+		maxColor = 255
+		corr = 1
+	}
+	color := func(col int) uint8 {
+		return uint8(uint16(clamp(value(col), maxColor)) / corr)
+	}
+	pt := geom.Point64{
+		Vector: model.Vector{
+			X: value(r.format.X),
+			Y: value(r.format.Y),
+			Z: value(r.format.Z),
+		},
+		R:              color(r.format.R),
+		G:              color(r.format.G),
+		B:              color(r.format.B),
+		Intensity:      rawIntensity(value(r.format.Intensity)),
+		Classification: uint8(clamp(value(r.format.Classification), 255)),
+	}
+	if err != nil {
+		return geom.Point64{}, err
+	}
+	return pt, nil
+}
+
+// rawIntensity returns the 8 bit intensity of a point whose intensity is read as a number from text, PLY or E57 files.
+// As for the LAS files the raw value is stored, without any scaling, rounded and clamped to the [0, 255] range.
+func rawIntensity(v float64) uint8 {
+	return uint8(clamp(v, 255))
+}
+
+// clamp rounds v to the nearest integer in the range [0, upper]
+func clamp(v float64, upper float64) float64 {
+	return math.Min(math.Max(math.Round(v), 0), upper)
+}
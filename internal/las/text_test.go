@@ -0,0 +1,217 @@
+package las
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+func writeTextFile(t *testing.T, name string, content string) string {
+	f := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestNewTextFormat(t *testing.T) {
+	tcs := []struct {
+		columns   string
+		delimiter string
+		skipRows  int
+		colorBits int
+		expected  TextFormat
+		err       bool
+	}{
+		{
+			columns:  "x y z",
+			expected: TextFormat{X: 0, Y: 1, Z: 2, R: -1, G: -1, B: -1, Intensity: -1, Classification: -1},
+		},
+		{
+			columns:   "X,Y,Z,_,R,G,B,I,C",
+			delimiter: ",",
+			skipRows:  1,
+			expected:  TextFormat{X: 0, Y: 1, Z: 2, R: 4, G: 5, B: 6, Intensity: 7, Classification: 8, Delimiter: ",", SkipRows: 1},
+		},
+		{
+			columns:   "c,x,y,z",
+			delimiter: "tab",
+			expected:  TextFormat{X: 1, Y: 2, Z: 3, R: -1, G: -1, B: -1, Intensity: -1, Classification: 0, Delimiter: "\t"},
+		},
+		{
+			columns:   "x,y,z,r,g,b",
+			colorBits: 8,
+			expected:  TextFormat{X: 0, Y: 1, Z: 2, R: 3, G: 4, B: 5, Intensity: -1, Classification: -1, ColorBits: 8},
+		},
+		{columns: "x,y", err: true},
+		{columns: "x,y,z,x", err: true},
+		{columns: "x,y,z,w", err: true},
+		{columns: "x,y,z", skipRows: -1, err: true},
+		{columns: "x,y,z", colorBits: 12, err: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.columns, func(t *testing.T) {
+			actual, err := NewTextFormat(tc.columns, tc.delimiter, tc.skipRows, tc.colorBits)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTextReader(t *testing.T) {
+	content := `X;Y;Z;R;G;B;Intensity;Class
+# a comment
+1.5; 2.5; 3.5; 255; 128; 0; 12; 2
+
+10;20;30;1;2;3;300;6
+`
+	file := writeTextFile(t, "cloud.csv", content)
+	format, err := NewTextFormat("x,y,z,r,g,b,i,c", ";", 1, 0)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	r, err := NewTextReader(file, "EPSG:32633", true, format)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+	if actual := r.NumberOfPoints(); actual != 2 {
+		t.Errorf("expected %d points got %d", 2, actual)
+	}
+	if actual := r.GetCRS(); actual != "EPSG:32633" {
+		t.Errorf("expected crs %s got %s", "EPSG:32633", actual)
+	}
+	expected := []geom.Point64{
+		{Vector: model.Vector{X: 1.5, Y: 2.5, Z: 3.5}, R: 255, G: 128, B: 0, Intensity: 12, Classification: 2},
+		{Vector: model.Vector{X: 10, Y: 20, Z: 30}, R: 1, G: 2, B: 3, Intensity: 255, Classification: 6},
+	}
+	for i, e := range expected {
+		actual, err := r.GetNext()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !reflect.DeepEqual(actual, e) {
+			t.Errorf("point %d: expected %v got %v", i, e, actual)
+		}
+	}
+	if _, err := r.GetNext(); err != io.EOF {
+		t.Errorf("expected EOF got %v", err)
+	}
+}
+
+func TestTextReaderSixteenBitColors(t *testing.T) {
+	file := writeTextFile(t, "cloud.xyz", "1 2 3 65535 32768 256\n")
+	format, _ := NewTextFormat("x y z r g b", "", 0, 0)
+	r, err := NewTextReader(file, "EPSG:32633", false, format)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+	pt, err := r.GetNext()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if pt.R != 255 || pt.G != 128 || pt.B != 1 {
+		t.Errorf("unexpected colors %d %d %d", pt.R, pt.G, pt.B)
+	}
+}
+
+func TestTextReaderColorBits(t *testing.T) {
+	file := writeTextFile(t, "cloud.xyz", "1 2 3 255 128 1\n")
+	tcs := []struct {
+		colorBits     int
+		eightBitColor bool
+		expected      [3]uint8
+	}{
+		{colorBits: 8, expected: [3]uint8{255, 128, 1}},
+		{colorBits: 8, eightBitColor: true, expected: [3]uint8{255, 128, 1}},
+		{colorBits: 16, eightBitColor: true, expected: [3]uint8{0, 0, 0}},
+		{colorBits: 0, eightBitColor: true, expected: [3]uint8{255, 128, 1}},
+		{colorBits: 0, expected: [3]uint8{0, 0, 0}},
+	}
+	for _, tc := range tcs {
+		t.Run(fmt.Sprintf("%d bits %v", tc.colorBits, tc.eightBitColor), func(t *testing.T) {
+			format, err := NewTextFormat("x y z r g b", "", 0, tc.colorBits)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			r, err := NewTextReader(file, "EPSG:32633", tc.eightBitColor, format)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			defer r.Close()
+			pt, err := r.GetNext()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if actual := [3]uint8{pt.R, pt.G, pt.B}; actual != tc.expected {
+				t.Errorf("expected colors %v got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTextReaderErrors(t *testing.T) {
+	format, _ := NewTextFormat("x y z", "", 0, 0)
+	file := writeTextFile(t, "cloud.xyz", "1 2 3\n1 2\n1 2 a\n")
+	if _, err := NewTextReader(file, "", false, format); err == nil {
+		t.Errorf("expected error for missing crs, got none")
+	}
+	r, err := NewTextReader(file, "EPSG:32633", false, format)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+	if _, err := r.GetNext(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := r.GetNext(); err == nil {
+			t.Errorf("expected error, got none")
+		}
+	}
+}
+
+func TestCombinedTextReader(t *testing.T) {
+	format, _ := NewTextFormat("x y z", "", 0, 0)
+	files := []string{
+		writeTextFile(t, "a.xyz", "1 2 3\n4 5 6\n"),
+		writeTextFile(t, "b.xyz", "7 8 9\n"),
+	}
+	r, err := NewCombinedFileTextReader(files, SameCRS("EPSG:32633"), false, format)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer r.Close()
+	if actual := r.NumberOfPoints(); actual != 3 {
+		t.Errorf("expected %d points got %d", 3, actual)
+	}
+	for i := 0; i < 3; i++ {
+		pt, err := r.GetNext()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if expected := float64(1 + 3*i); pt.X != expected {
+			t.Errorf("expected x %f got %f", expected, pt.X)
+		}
+	}
+	if _, err := NewCombinedFileTextReader(files, SameCRS(""), false, format); err == nil {
+		t.Errorf("expected error, got none")
+	}
+}
@@ -0,0 +1,153 @@
+// Package meshopt implements an encoder of vertex buffers into the meshoptimizer vertex codec format, version 0,
+// as decoded by the EXT_meshopt_compression glTF extension in the ATTRIBUTES mode.
+//
+// Vertices are split in blocks. In each block every byte of the vertex is delta encoded against the same byte
+// of the previous vertex, and the zigzag encoded deltas are bit packed in groups of 16. Storing spatially
+// coherent vertices next to each other improves the compression.
+package meshopt
+
+import (
+	"fmt"
+)
+
+const (
+	vertexHeader = 0xa0
+	// byteGroupSize is the number of deltas packed together
+	byteGroupSize = 16
+	// blockSizeBytes is the maximum size of the vertex data of a block
+	blockSizeBytes = 8192
+	// blockMaxSize is the maximum number of vertices of a block
+	blockMaxSize = 256
+	// tailMaxSize is the minimum size of the tail storing the first vertex
+	tailMaxSize = 32
+)
+
+// groupBits holds the bits per value of each group encoding mode
+var groupBits = [4]int{0, 2, 4, 8}
+
+// EncodeVertexBuffer encodes count vertices of the given size, stored one after the other in vertices.
+// The vertex size must be a multiple of 4 not greater than 256 bytes, as required by EXT_meshopt_compression.
+func EncodeVertexBuffer(vertices []byte, count int, size int) ([]byte, error) {
+	if size <= 0 || size > 256 || size%4 != 0 {
+		return nil, fmt.Errorf("vertex size should be a multiple of 4 between 4 and 256, got %d", size)
+	}
+	if len(vertices) != count*size {
+		return nil, fmt.Errorf("expected %d bytes of vertex data, got %d", count*size, len(vertices))
+	}
+	out := []byte{vertexHeader}
+	first := make([]byte, size)
+	copy(first, vertices)
+	last := make([]byte, size)
+	copy(last, first)
+
+	blockSize := blockVertices(size)
+	for offset := 0; offset < count; offset += blockSize {
+		n := min(blockSize, count-offset)
+		out = encodeBlock(out, vertices[offset*size:(offset+n)*size], n, size, last)
+	}
+
+	// the first vertex is stored at the end of the stream, padded to the tail size
+	if size < tailMaxSize {
+		out = append(out, make([]byte, tailMaxSize-size)...)
+	}
+	return append(out, first...), nil
+}
+
+// blockVertices returns the number of vertices of each block
+func blockVertices(size int) int {
+	n := blockSizeBytes / size
+	n &^= byteGroupSize - 1
+	return min(n, blockMaxSize)
+}
+
+// encodeBlock appends to out the encoded vertices of a block. last holds the previous vertex and
+// is updated with the last vertex of the block.
+func encodeBlock(out []byte, vertices []byte, count int, size int, last []byte) []byte {
+	aligned := (count + byteGroupSize - 1) &^ (byteGroupSize - 1)
+	deltas := make([]byte, aligned)
+	for k := 0; k < size; k++ {
+		p := last[k]
+		for i := 0; i < count; i++ {
+			v := vertices[i*size+k]
+			deltas[i] = zigzag(v - p)
+			p = v
+		}
+		clear(deltas[count:])
+		out = encodeBytes(out, deltas)
+	}
+	copy(last, vertices[(count-1)*size:])
+	return out
+}
+
+// zigzag maps the signed delta to an unsigned value, so that small deltas of either sign are small values
+func zigzag(v byte) byte {
+	return byte(int8(v)>>7) ^ v<<1
+}
+
+// encodeBytes appends the deltas, whose length must be a multiple of the group size. Each group is prefixed
+// by a 2 bit header selecting its encoding: all zeros, 2 bits, 4 bits or 8 bits per value.
+func encodeBytes(out []byte, deltas []byte) []byte {
+	groups := len(deltas) / byteGroupSize
+	headerStart := len(out)
+	out = append(out, make([]byte, (groups+3)/4)...)
+	for g := 0; g < groups; g++ {
+		group := deltas[g*byteGroupSize : (g+1)*byteGroupSize]
+		mode, best := 3, byteGroupSize
+		for m := 0; m < 3; m++ {
+			if size := groupSize(group, groupBits[m]); size < best {
+				mode, best = m, size
+			}
+		}
+		out[headerStart+g/4] |= byte(mode) << ((g % 4) * 2)
+		out = encodeGroup(out, group, groupBits[mode])
+	}
+	return out
+}
+
+// groupSize returns the encoded size of the group using the given bits per value, or a size greater than
+// the raw one if the group cannot be encoded with them
+func groupSize(group []byte, bits int) int {
+	if bits == 0 {
+		for _, v := range group {
+			if v != 0 {
+				return byteGroupSize + 1
+			}
+		}
+		return 0
+	}
+	size := byteGroupSize * bits / 8
+	sentinel := byte(1<<bits - 1)
+	for _, v := range group {
+		if v >= sentinel {
+			size++
+		}
+	}
+	return size
+}
+
+// encodeGroup appends the group packing each value with the given bits, most significant bits first. Values
+// not representable are replaced by a sentinel with all the bits set and appended after the packed values.
+func encodeGroup(out []byte, group []byte, bits int) []byte {
+	switch bits {
+	case 0:
+		return out
+	case 8:
+		return append(out, group...)
+	}
+	sentinel := byte(1<<bits - 1)
+	perByte := 8 / bits
+	var exceptions []byte
+	for i := 0; i < byteGroupSize; i += perByte {
+		var b byte
+		for j := 0; j < perByte; j++ {
+			v := group[i+j]
+			if v >= sentinel {
+				exceptions = append(exceptions, v)
+				v = sentinel
+			}
+			b = b<<bits | v
+		}
+		out = append(out, b)
+	}
+	return append(out, exceptions...)
+}
@@ -0,0 +1,222 @@
+package meshopt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// decodeVertexBuffer decodes the vertex buffer as done by the EXT_meshopt_compression reference decoder
+func decodeVertexBuffer(data []byte, count int, size int) ([]byte, error) {
+	if len(data) < 1 || data[0] != vertexHeader {
+		return nil, fmt.Errorf("invalid header")
+	}
+	tail := max(size, tailMaxSize)
+	if len(data) < 1+tail {
+		return nil, fmt.Errorf("stream too short")
+	}
+	last := append([]byte{}, data[len(data)-size:]...)
+	body := data[1 : len(data)-tail]
+	out := make([]byte, count*size)
+	blockSize := blockVertices(size)
+	for offset := 0; offset < count; offset += blockSize {
+		n := min(blockSize, count-offset)
+		aligned := (n + byteGroupSize - 1) &^ (byteGroupSize - 1)
+		for k := 0; k < size; k++ {
+			var deltas []byte
+			var err error
+			deltas, body, err = decodeBytes(body, aligned)
+			if err != nil {
+				return nil, err
+			}
+			p := last[k]
+			for i := 0; i < n; i++ {
+				d := deltas[i]
+				p += (d >> 1) ^ -(d & 1)
+				out[(offset+i)*size+k] = p
+			}
+		}
+		copy(last, out[(offset+n-1)*size:(offset+n)*size])
+	}
+	if len(body) != 0 {
+		return nil, fmt.Errorf("%d unexpected trailing bytes", len(body))
+	}
+	return out, nil
+}
+
+func decodeBytes(data []byte, n int) ([]byte, []byte, error) {
+	groups := n / byteGroupSize
+	headerSize := (groups + 3) / 4
+	if len(data) < headerSize {
+		return nil, nil, fmt.Errorf("stream too short")
+	}
+	header, data := data[:headerSize], data[headerSize:]
+	out := make([]byte, 0, n)
+	for g := 0; g < groups; g++ {
+		bits := groupBits[(header[g/4]>>((g%4)*2))&3]
+		switch bits {
+		case 0:
+			out = append(out, make([]byte, byteGroupSize)...)
+			continue
+		case 8:
+			if len(data) < byteGroupSize {
+				return nil, nil, fmt.Errorf("stream too short")
+			}
+			out, data = append(out, data[:byteGroupSize]...), data[byteGroupSize:]
+			continue
+		}
+		packed := byteGroupSize * bits / 8
+		if len(data) < packed {
+			return nil, nil, fmt.Errorf("stream too short")
+		}
+		values, rest := data[:packed], data[packed:]
+		sentinel := byte(1<<bits - 1)
+		for _, b := range values {
+			for j := 0; j < 8/bits; j++ {
+				v := b >> (8 - bits)
+				b <<= bits
+				if v == sentinel {
+					if len(rest) == 0 {
+						return nil, nil, fmt.Errorf("stream too short")
+					}
+					v, rest = rest[0], rest[1:]
+				}
+				out = append(out, v)
+			}
+		}
+		data = rest
+	}
+	return out, data, nil
+}
+
+func TestEncodeVertexBuffer(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name  string
+		count int
+		size  int
+		gen   func(i, k int) byte
+	}{
+		{name: "empty", count: 0, size: 4},
+		{name: "single", count: 1, size: 12, gen: func(i, k int) byte { return byte(k) }},
+		{name: "constant", count: 300, size: 4, gen: func(i, k int) byte { return 7 }},
+		{name: "smooth", count: 1000, size: 8, gen: func(i, k int) byte { return byte(i/(k+1) + k) }},
+		{name: "small deltas", count: 517, size: 12, gen: func(i, k int) byte { return byte(i + rnd.Intn(9)) }},
+		{name: "random", count: 600, size: 16, gen: func(i, k int) byte { return byte(rnd.Intn(256)) }},
+		{name: "large vertices", count: 100, size: 256, gen: func(i, k int) byte { return byte(i * k) }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			vertices := make([]byte, tc.count*tc.size)
+			for i := 0; i < tc.count; i++ {
+				for k := 0; k < tc.size; k++ {
+					vertices[i*tc.size+k] = tc.gen(i, k)
+				}
+			}
+			data, err := EncodeVertexBuffer(vertices, tc.count, tc.size)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			decoded, err := decodeVertexBuffer(data, tc.count, tc.size)
+			if err != nil {
+				t.Fatalf("unable to decode the vertices: %v", err)
+			}
+			if !bytes.Equal(decoded, vertices) {
+				t.Errorf("decoded vertices do not match the encoded ones")
+			}
+		})
+	}
+}
+
+// goldenVertices returns 20 vertices of 12 bytes, each made of three little endian uint16 positions, two normal
+// bytes and two little endian uint16 texture coordinates, whose encoding is stored in testdata/vertices.bin.
+// The golden file can be checked with the reference decoder, meshopt_decodeVertexBuffer(out, 20, 12, data, size),
+// which must return the same vertices.
+func goldenVertices() []byte {
+	vertices := make([]byte, 0, 20*12)
+	for i := 0; i < 20; i++ {
+		vertices = binary.LittleEndian.AppendUint16(vertices, uint16(i*300))
+		vertices = binary.LittleEndian.AppendUint16(vertices, uint16((i%4)*300))
+		vertices = binary.LittleEndian.AppendUint16(vertices, uint16(i*i))
+		vertices = append(vertices, byte(127+i), byte(127-i))
+		vertices = binary.LittleEndian.AppendUint16(vertices, uint16(i*500))
+		vertices = binary.LittleEndian.AppendUint16(vertices, uint16(65535-i*500))
+	}
+	return vertices
+}
+
+func TestEncodeVertexBufferGolden(t *testing.T) {
+	expected, err := os.ReadFile(filepath.Join("testdata", "vertices.bin"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	vertices := goldenVertices()
+	actual, err := EncodeVertexBuffer(vertices, 20, 12)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("encoded data differs from testdata/vertices.bin")
+	}
+	decoded, err := decodeVertexBuffer(expected, 20, 12)
+	if err != nil {
+		t.Fatalf("unable to decode the vertices: %v", err)
+	}
+	if !bytes.Equal(decoded, vertices) {
+		t.Errorf("decoded vertices differ from the encoded ones")
+	}
+}
+
+func TestEncodeVertexBufferCompression(t *testing.T) {
+	vertices := make([]byte, 1024*8)
+	for i := 0; i < 1024; i++ {
+		vertices[i*8] = byte(i)
+		vertices[i*8+4] = byte(i / 4)
+	}
+	data, err := EncodeVertexBuffer(vertices, 1024, 8)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(data) > len(vertices)/8 {
+		t.Errorf("expected the data to be compressed to at most %d bytes, got %d", len(vertices)/8, len(data))
+	}
+}
+
+func TestEncodeVertexBufferErrors(t *testing.T) {
+	if _, err := EncodeVertexBuffer(make([]byte, 6), 1, 6); err == nil {
+		t.Errorf("expected error for a size not multiple of 4")
+	}
+	if _, err := EncodeVertexBuffer(make([]byte, 260), 1, 260); err == nil {
+		t.Errorf("expected error for a size greater than 256")
+	}
+	if _, err := EncodeVertexBuffer(make([]byte, 7), 2, 4); err == nil {
+		t.Errorf("expected error for mismatching data length")
+	}
+}
+
+func TestEncodeGroup(t *testing.T) {
+	group := []byte{0, 1, 2, 3, 0, 1, 5, 0, 0, 0, 0, 0, 0, 0, 0, 2}
+	// the values 3 and 5 are replaced by the sentinel and appended after the packed values
+	expected := []byte{0b00011011, 0b00011100, 0, 0b00000010, 3, 5}
+	if actual := encodeGroup(nil, group, 2); !bytes.Equal(actual, expected) {
+		t.Errorf("expected %08b got %08b", expected, actual)
+	}
+	if actual := groupSize(group, 2); actual != len(expected) {
+		t.Errorf("expected size %d got %d", len(expected), actual)
+	}
+	if actual := groupSize(group, 0); actual <= byteGroupSize {
+		t.Errorf("expected a non zero group not to be encodable with 0 bits, got size %d", actual)
+	}
+}
+
+func TestZigzag(t *testing.T) {
+	for v, expected := range map[int8]byte{0: 0, -1: 1, 1: 2, -2: 3, 2: 4, 127: 254, -128: 255} {
+		if actual := zigzag(byte(v)); actual != expected {
+			t.Errorf("expected %d for %d got %d", expected, v, actual)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCesiumURL is the CesiumJS build folder loaded by the viewer when no other location is configured
+const DefaultCesiumURL = "https://cesium.com/downloads/cesiumjs/releases/1.121/Build/Cesium/"
+
+const (
+	tilesPrefix  = "/tiles/"
+	cesiumPrefix = "/cesium/"
+)
+
+//go:embed viewer.html
+var viewerPage string
+
+var viewerTemplate = template.Must(template.New("viewer").Parse(viewerPage))
+
+var contentTypes = map[string]string{
+	".json":    "application/json",
+	".glb":     "model/gltf-binary",
+	".gltf":    "model/gltf+json",
+	".pnts":    "application/octet-stream",
+	".b3dm":    "application/octet-stream",
+	".i3dm":    "application/octet-stream",
+	".cmpt":    "application/octet-stream",
+	".subtree": "application/octet-stream",
+	".bin":     "application/octet-stream",
+	".html":    "text/html; charset=utf-8",
+	".js":      "text/javascript; charset=utf-8",
+	".css":     "text/css; charset=utf-8",
+	".png":     "image/png",
+	".jpg":     "image/jpeg",
+	".svg":     "image/svg+xml",
+	".wasm":    "application/wasm",
+}
+
+// Server serves a tileset folder or .3tz archive over HTTP, together with a minimal CesiumJS viewer page
+// available at the root path. Tileset files are available under /tiles/.
+type Server struct {
+	tiles     fs.FS
+	closer    io.Closer
+	cesiumURL string
+	cesium    fs.FS
+}
+
+// WithCesiumURL sets the location of the CesiumJS build folder, the one containing Cesium.js. It can either be
+// an http(s) URL or a local folder, which is then served under /cesium/ so that the viewer can run offline.
+func WithCesiumURL(location string) func(*Server) {
+	return func(s *Server) {
+		s.cesiumURL = location
+	}
+}
+
+// NewServer returns a server for the tileset stored in the given folder or .3tz archive
+func NewServer(root string, options ...func(*Server)) (*Server, error) {
+	s := &Server{cesiumURL: DefaultCesiumURL}
+	for _, optFn := range options {
+		optFn(s)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", root, err)
+	}
+	if info.IsDir() {
+		s.tiles = os.DirFS(root)
+	} else {
+		z, err := zip.OpenReader(root)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open the archive %s: %w", root, err)
+		}
+		s.tiles = z
+		s.closer = z
+	}
+	if !isRemote(s.cesiumURL) {
+		info, err := os.Stat(s.cesiumURL)
+		if err != nil || !info.IsDir() {
+			s.Close()
+			return nil, fmt.Errorf("invalid CesiumJS location %s, expected an http(s) URL or a local folder", s.cesiumURL)
+		}
+		s.cesium = os.DirFS(s.cesiumURL)
+	}
+	return s, nil
+}
+
+// Close releases the archive opened by the server, if any
+func (s *Server) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// Tilesets returns the paths of the root tilesets to display in the viewer: tileset.json if present, otherwise
+// the tileset.json files found in the first level subfolders, as written when processing a folder without join
+func (s *Server) Tilesets() []string {
+	if _, err := fs.Stat(s.tiles, "tileset.json"); err == nil {
+		return []string{"tileset.json"}
+	}
+	matches, _ := fs.Glob(s.tiles, "*/tileset.json")
+	return matches
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Encoding, Content-Range")
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodGet, http.MethodHead:
+	default:
+		w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p := r.URL.Path
+	switch {
+	case p == "/" || p == "/index.html":
+		s.serveViewer(w, r)
+	case strings.HasPrefix(p, tilesPrefix):
+		serveFile(w, r, s.tiles, strings.TrimPrefix(p, tilesPrefix))
+	case strings.HasPrefix(p, cesiumPrefix) && s.cesium != nil:
+		serveFile(w, r, s.cesium, strings.TrimPrefix(p, cesiumPrefix))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveViewer(w http.ResponseWriter, r *http.Request) {
+	tilesets := s.Tilesets()
+	if t := r.URL.Query().Get("tileset"); t != "" {
+		tilesets = []string{strings.TrimPrefix(t, "/")}
+	}
+	urls := make([]string, len(tilesets))
+	for i, t := range tilesets {
+		urls[i] = tilesPrefix + t
+	}
+	cesiumURL := cesiumPrefix
+	if s.cesium == nil {
+		cesiumURL = s.cesiumURL
+	}
+	if !strings.HasSuffix(cesiumURL, "/") {
+		cesiumURL += "/"
+	}
+	page := &bytes.Buffer{}
+	err := viewerTemplate.Execute(page, struct {
+		CesiumURL string
+		Tilesets  []string
+	}{cesiumURL, urls})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypes[".html"])
+	w.Header().Set("Cache-Control", "no-cache")
+	serveBytes(w, r, "index.html", time.Time{}, page.Bytes(), false)
+}
+
+// serveFile serves the file at the given path of fsys handling the gzip encoding. Files stored gzip compressed in
+// place are sent as they are to the clients accepting gzip and decompressed for the others, .gz sidecar files are
+// preferred when present and the remaining files are compressed on the fly.
+func serveFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) {
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+	info, err := fs.Stat(fsys, name)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ct, ok := contentTypes[path.Ext(name)]; ok {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.Header().Set("Vary", "Accept-Encoding")
+	gz := acceptsGzip(r)
+	if path.Ext(name) != ".gz" && isGzip(data) {
+		if gz {
+			serveBytes(w, r, name, info.ModTime(), data, true)
+			return
+		}
+		if data, err = gunzip(data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		serveBytes(w, r, name, info.ModTime(), data, false)
+		return
+	}
+	if !gz {
+		serveBytes(w, r, name, info.ModTime(), data, false)
+		return
+	}
+	if sidecar, err := fs.ReadFile(fsys, name+".gz"); err == nil && isGzip(sidecar) {
+		serveBytes(w, r, name, info.ModTime(), sidecar, true)
+		return
+	}
+	compressed := &bytes.Buffer{}
+	zw, _ := gzip.NewWriterLevel(compressed, gzip.BestSpeed)
+	zw.Write(data)
+	zw.Close()
+	serveBytes(w, r, name, info.ModTime(), compressed.Bytes(), true)
+}
+
+func serveBytes(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, data []byte, gzipped bool) {
+	if gzipped {
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	http.ServeContent(w, r, name, modTime, bytes.NewReader(data))
+}
+
+// acceptsGzip returns true if the Accept-Encoding header of the request allows gzip responses
+func acceptsGzip(r *http.Request) bool {
+	for _, e := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(e, ";")
+		coding = strings.TrimSpace(coding)
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isGzip(data []byte) bool {
+	return len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+func isRemote(location string) bool {
+	l := strings.ToLower(location)
+	return strings.HasPrefix(l, "http://") || strings.HasPrefix(l, "https://") || strings.HasPrefix(l, "//")
+}
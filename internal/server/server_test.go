@@ -0,0 +1,227 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	zw := gzip.NewWriter(b)
+	io.WriteString(zw, data)
+	if err := zw.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return b.Bytes()
+}
+
+func writeFiles(t *testing.T, root string, files map[string][]byte) {
+	t.Helper()
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+}
+
+func get(t *testing.T, s http.Handler, method string, url string, acceptEncoding string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(method, url, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func body(t *testing.T, res *http.Response) string {
+	t.Helper()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if res.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if b, err = io.ReadAll(zr); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	return string(b)
+}
+
+func TestServerFolder(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string][]byte{
+		"tileset.json":      []byte(`{"asset":{"version":"1.0"}}`),
+		"content.pnts":      []byte("pnts"),
+		"1/tileset.json":    gzipBytes(t, `{"asset":{"version":"1.1"}}`),
+		"1/content.glb":     []byte("glTF"),
+		"1/content.glb.gz":  gzipBytes(t, "glTF"),
+		"1/0/0/0/0.subtree": []byte("subt"),
+	})
+	s, err := NewServer(root)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer s.Close()
+
+	cases := []struct {
+		url            string
+		acceptEncoding string
+		contentType    string
+		encoding       string
+		body           string
+	}{
+		{"/tiles/tileset.json", "", "application/json", "", `{"asset":{"version":"1.0"}}`},
+		{"/tiles/tileset.json", "gzip, deflate", "application/json", "gzip", `{"asset":{"version":"1.0"}}`},
+		{"/tiles/content.pnts", "br;q=1.0, gzip;q=0", "application/octet-stream", "", "pnts"},
+		{"/tiles/1/tileset.json", "", "application/json", "", `{"asset":{"version":"1.1"}}`},
+		{"/tiles/1/tileset.json", "gzip", "application/json", "gzip", `{"asset":{"version":"1.1"}}`},
+		{"/tiles/1/content.glb", "gzip", "model/gltf-binary", "gzip", "glTF"},
+		{"/tiles/1/content.glb", "", "model/gltf-binary", "", "glTF"},
+		{"/tiles/1/0/0/0/0.subtree", "", "application/octet-stream", "", "subt"},
+	}
+	for _, c := range cases {
+		res := get(t, s, http.MethodGet, c.url, c.acceptEncoding)
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected status 200 got %d", c.url, res.StatusCode)
+			continue
+		}
+		if actual := res.Header.Get("Content-Type"); actual != c.contentType {
+			t.Errorf("%s: expected content type %s got %s", c.url, c.contentType, actual)
+		}
+		if actual := res.Header.Get("Content-Encoding"); actual != c.encoding {
+			t.Errorf("%s: expected content encoding %q got %q", c.url, c.encoding, actual)
+		}
+		if actual := res.Header.Get("Access-Control-Allow-Origin"); actual != "*" {
+			t.Errorf("%s: expected CORS header got %q", c.url, actual)
+		}
+		if actual := body(t, res); actual != c.body {
+			t.Errorf("%s: expected body %q got %q", c.url, c.body, actual)
+		}
+	}
+
+	for _, url := range []string{"/tiles/missing.json", "/tiles/1", "/tiles/../outside/evil.txt", "/cesium/Cesium.js", "/other"} {
+		if res := get(t, s, http.MethodGet, url, ""); res.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected status 404 got %d", url, res.StatusCode)
+		}
+	}
+	if res := get(t, s, http.MethodOptions, "/tiles/tileset.json", ""); res.StatusCode != http.StatusNoContent || res.Header.Get("Access-Control-Allow-Methods") == "" {
+		t.Errorf("unexpected preflight response %d %v", res.StatusCode, res.Header)
+	}
+	if res := get(t, s, http.MethodPost, "/tiles/tileset.json", ""); res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 got %d", res.StatusCode)
+	}
+}
+
+func TestServerArchive(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "tileset.3tz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{"tileset.json": "{}", "0/content.glb": "glTF"} {
+		w, _ := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		io.WriteString(w, content)
+	}
+	zw.Close()
+	f.Close()
+
+	s, err := NewServer(archive)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer s.Close()
+	res := get(t, s, http.MethodGet, "/tiles/0/content.glb", "gzip")
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "model/gltf-binary" || body(t, res) != "glTF" {
+		t.Errorf("unexpected response %d %v", res.StatusCode, res.Header)
+	}
+	if actual := s.Tilesets(); !reflect.DeepEqual(actual, []string{"tileset.json"}) {
+		t.Errorf("unexpected tilesets %v", actual)
+	}
+
+	if _, err := NewServer(filepath.Join(t.TempDir(), "missing.3tz")); err == nil {
+		t.Errorf("expected error for missing archive")
+	}
+}
+
+func TestServerViewer(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string][]byte{
+		"a/tileset.json": []byte("{}"),
+		"b/tileset.json": []byte("{}"),
+	})
+	s, err := NewServer(root)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	res := get(t, s, http.MethodGet, "/", "")
+	page := body(t, res)
+	if res.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("unexpected content type %s", res.Header.Get("Content-Type"))
+	}
+	for _, expected := range []string{DefaultCesiumURL + "Cesium.js", `["/tiles/a/tileset.json","/tiles/b/tileset.json"]`, "${CLASSIFICATION}", "${INTENSITY}"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("expected the viewer page to contain %s", expected)
+		}
+	}
+	page = body(t, get(t, s, http.MethodGet, "/?tileset=a/tileset.json", ""))
+	if !strings.Contains(page, `["/tiles/a/tileset.json"]`) {
+		t.Errorf("expected the viewer page to load the requested tileset")
+	}
+
+	// local CesiumJS build
+	cesium := t.TempDir()
+	writeFiles(t, cesium, map[string][]byte{"Cesium.js": []byte("var Cesium = {};")})
+	s, err = NewServer(root, WithCesiumURL(cesium))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if page := body(t, get(t, s, http.MethodGet, "/", "")); !strings.Contains(page, `src="/cesium/Cesium.js"`) {
+		t.Errorf("expected the viewer page to load the local CesiumJS build")
+	}
+	res = get(t, s, http.MethodGet, "/cesium/Cesium.js", "")
+	if res.Header.Get("Content-Type") != "text/javascript; charset=utf-8" || body(t, res) != "var Cesium = {};" {
+		t.Errorf("unexpected CesiumJS response %v", res.Header)
+	}
+	if _, err := NewServer(root, WithCesiumURL(filepath.Join(cesium, "missing"))); err == nil {
+		t.Errorf("expected error for missing CesiumJS folder")
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	cases := map[string]bool{
+		"":                    false,
+		"gzip":                true,
+		"deflate, gzip;q=1.0": true,
+		"gzip;q=0":            false,
+		"*":                   true,
+		"br":                  false,
+	}
+	for header, expected := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", header)
+		if actual := acceptsGzip(req); actual != expected {
+			t.Errorf("%q: expected %v got %v", header, expected, actual)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package grid
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"sync"
@@ -30,10 +31,14 @@ func (l *loader) load(n *Node, r las.LasReader, ctx context.Context) (err error)
 	}
 	subCtx, cancelFunc := context.WithCancel(ctx)
 
-	// Store all the points in a continuous memory space
-	// While not required, storing points in a contiguous array makes
+	// Store all the points in a continuous memory space, as columnar arrays
+	// While not required, storing points in contiguous arrays makes
 	// the system more CPU cache friendly and thus measurably faster
-	backingArray := make([]geom.LinkedPoint, numPts)
+	store, err := newPointStore(numPts, geom.AttributesLength(r.Attributes()))
+	if err != nil {
+		cancelFunc()
+		return err
+	}
 
 	// read first point
 
@@ -43,35 +48,35 @@ func (l *loader) load(n *Node, r las.LasReader, ctx context.Context) (err error)
 	}
 
 	// compute the baseline point and local CRS
-	localToGlobal, base, read, err := l.baseline(r, c)
+	baseAttrs := make([]float64, store.attributes)
+	localToGlobal, base, read, err := l.baseline(r, c, baseAttrs)
 	if err != nil {
 		return err
 	}
-	backingArray[0] = base
+	store.set(0, base, baseAttrs)
 
 	// init concurrent vars
 	var wg sync.WaitGroup
 	var errchan chan error = make(chan error)
 
-	// launch consumers
-	basePtPerWorker := (numPts - read) / l.workers
-	residual := (numPts - read) - basePtPerWorker*l.workers
-	start := read
+	// launch consumers, each reading its own range of points if supported by the reader
+	ranges := workerRanges(read, numPts, l.workers)
+	readers, closeReaders, err := pointReaders(r, ranges)
+	if err != nil {
+		cancelFunc()
+		return err
+	}
+	defer closeReaders()
 	consumers := []*consumer{}
-	for i := 0; i < l.workers; i++ {
-		workerPtsNum := basePtPerWorker
-		if i < residual {
-			workerPtsNum += 1
-		}
+	for i, rg := range ranges {
 		conv, err := l.createCoorConverter()
 		if err != nil {
 			cancelFunc()
 			return err
 		}
-		consumers = append(consumers, newConsumer(i, start, workerPtsNum, conv, l.mutator, r.GetCRS(), &backingArray))
+		consumers = append(consumers, newConsumer(i, rg[0], rg[1]-rg[0], conv, l.mutator, r.GetCRS(), store))
 		wg.Add(1)
-		go consumers[i].consume(r, localToGlobal, errchan, &wg, subCtx)
-		start = start + workerPtsNum
+		go consumers[i].consume(readers[i], localToGlobal, errchan, &wg, subCtx)
 	}
 
 	// retrieve errors
@@ -96,26 +101,29 @@ func (l *loader) load(n *Node, r las.LasReader, ctx context.Context) (err error)
 		return errs[0]
 	}
 
-	var pts *geom.LinkedPoint
+	var pts uint32 = noPoint
 	var bboxbuilder *boundingBoxBuilder
 
 	// merge consumer points and bounding boxes
 	for _, c := range consumers {
-		c.endPt.Next = pts
-		pts = c.startPt
+		if c.startPt != noPoint {
+			store.next[c.endPt] = pts
+			pts = c.startPt
+		}
 		if bboxbuilder == nil {
 			bboxbuilder = c.bboxBuilder
 		} else {
 			bboxbuilder.mergeWith(c.bboxBuilder)
 		}
 	}
-	bboxbuilder.processPoint(base.Pt.X, base.Pt.Y, base.Pt.Z)
+	bboxbuilder.processPoint(base.X, base.Y, base.Z)
 	bbox := bboxbuilder.build()
-	base.Next = pts
+	store.next[0] = pts
 
 	// set data into the gridnode
 	n.bounds = bbox
-	n.pts = &base
+	n.store = store
+	n.pts = 0
 	n.localToGlobal = &localToGlobal
 	return nil
 }
@@ -141,17 +149,18 @@ func toLocal(p geom.Point64, localToGlobal model.Transform) model.Point {
 // - the point, in local coordinates
 // - the number of points read from the point cloud
 // - An error in case the operation failed
-func (l *loader) baseline(r las.LasReader, c coor.Converter) (model.Transform, geom.LinkedPoint, int, error) {
+// The attribute values of the point are stored in attrs.
+func (l *loader) baseline(r las.LasReader, c coor.Converter, attrs []float64) (model.Transform, model.Point, int, error) {
 	read := 0
 	for {
-		first, err := r.GetNext()
+		first, err := las.NextPoint(r, attrs)
 		if err != nil {
-			return model.Transform{}, geom.LinkedPoint{}, 0, err
+			return model.Transform{}, model.Point{}, 0, err
 		}
 		read++
-		pt, err := transformPoint(first, c, r.GetCRS())
+		pt, err := transformPoint(mutateSource(first, l.mutator), c, r.GetCRS())
 		if err != nil {
-			return model.Transform{}, geom.LinkedPoint{}, 0, err
+			return model.Transform{}, model.Point{}, 0, err
 		}
 
 		localToGlobal := geom.LocalToGlobalTransformFromPoint(pt.X, pt.Y, pt.Z)
@@ -163,39 +172,42 @@ func (l *loader) baseline(r las.LasReader, c coor.Converter) (model.Transform, g
 				continue
 			}
 		}
-		return localToGlobal, geom.LinkedPoint{Pt: baselinePtLocalCoords}, read, nil
+		return localToGlobal, baselinePtLocalCoords, read, nil
 	}
 }
 
 // consumer consumes points from the las reader storing them internally and updating its internal bounds
 type consumer struct {
-	id           int
-	start        int
-	count        int
-	conv         coor.Converter
-	mutator      mutator.Mutator
-	crs          string
-	backingArray *[]geom.LinkedPoint
+	id      int
+	start   int
+	count   int
+	conv    coor.Converter
+	mutator mutator.Mutator
+	crs     string
+	store   *pointStore
 	// output vars
 	bboxBuilder *boundingBoxBuilder
-	startPt     *geom.LinkedPoint
-	endPt       *geom.LinkedPoint
+	// startPt and endPt are the indexes of the first and last point of the list of points read, noPoint if none
+	startPt uint32
+	endPt   uint32
 }
 
-func newConsumer(id int, start int, count int, conv coor.Converter, mut mutator.Mutator, crs string, backingArray *[]geom.LinkedPoint) *consumer {
+func newConsumer(id int, start int, count int, conv coor.Converter, mut mutator.Mutator, crs string, store *pointStore) *consumer {
 	return &consumer{
-		id:           id,
-		start:        start,
-		count:        count,
-		conv:         conv,
-		mutator:      mut,
-		crs:          crs,
-		backingArray: backingArray,
-		bboxBuilder:  newBoundingBoxBuilder(),
+		id:          id,
+		start:       start,
+		count:       count,
+		conv:        conv,
+		mutator:     mut,
+		crs:         crs,
+		store:       store,
+		bboxBuilder: newBoundingBoxBuilder(),
+		startPt:     noPoint,
+		endPt:       noPoint,
 	}
 }
 
-func (c *consumer) consume(r las.LasReader, localToGlobal model.Transform, errchan chan error, wg *sync.WaitGroup, ctx context.Context) {
+func (c *consumer) consume(r las.PointReader, localToGlobal model.Transform, errchan chan error, wg *sync.WaitGroup, ctx context.Context) {
 	defer func() {
 		if r := recover(); r != nil {
 			errchan <- fmt.Errorf("panic while reading from las: %v", r)
@@ -203,23 +215,92 @@ func (c *consumer) consume(r las.LasReader, localToGlobal model.Transform, errch
 	}()
 	defer c.conv.Cleanup()
 	defer wg.Done()
-	var currentPt *geom.LinkedPoint
-	i := 0
-	for i < c.count {
+	attrs := make([]float64, c.store.attributes)
+	err := readPoints(r, c.count, c.conv, c.mutator, c.crs, localToGlobal, c.bboxBuilder, ctx, attrs, func(i int, localPt model.Point) error {
+		// store point in the store at the right offset and append it to the list
+		newPt := uint32(c.start + i)
+		c.store.set(newPt, localPt, attrs)
+		if c.startPt == noPoint {
+			c.startPt = newPt
+		} else {
+			c.store.next[c.endPt] = newPt
+		}
+		c.endPt = newPt
+		return nil
+	})
+	if err != nil {
+		errchan <- err
+	}
+}
+
+// workerRanges splits the points with index in [start, end) in the given number of contiguous ranges, one per worker
+func workerRanges(start, end, workers int) [][2]int {
+	basePtPerWorker := (end - start) / workers
+	residual := (end - start) - basePtPerWorker*workers
+	ranges := make([][2]int, workers)
+	for i := range ranges {
+		count := basePtPerWorker
+		if i < residual {
+			count++
+		}
+		ranges[i] = [2]int{start, start + count}
+		start += count
+	}
+	return ranges
+}
+
+// pointReaders returns the readers of the given ranges of points. If the las reader supports it each range is read
+// by an independent reader, so that the ranges can be read in parallel without contention, else all the ranges are
+// read sequentially from the las reader itself. The returned function closes the range readers.
+func pointReaders(r las.LasReader, ranges [][2]int) ([]las.PointReader, func(), error) {
+	readers := make([]las.PointReader, len(ranges))
+	var opened []las.PointReader
+	closeReaders := func() {
+		for _, pr := range opened {
+			pr.Close()
+		}
+	}
+	if rr, ok := r.(las.RangeReader); ok {
+		for i, rg := range ranges {
+			pr, err := rr.ReadRange(rg[0], rg[1])
+			if err != nil {
+				closeReaders()
+				if errors.Is(err, las.ErrRangesNotSupported) {
+					opened = nil
+					break
+				}
+				return nil, nil, err
+			}
+			readers[i] = pr
+			opened = append(opened, pr)
+		}
+		if len(opened) == len(ranges) {
+			return readers, closeReaders, nil
+		}
+	}
+	for i := range readers {
+		readers[i] = r
+	}
+	return readers, closeReaders, nil
+}
+
+// readPoints reads count points from the las reader, converting them to the local CRS and mutating them, and passes
+// the retained ones to the store function together with their index among the points read. The attribute values of
+// each point are read in attrs before calling store. The bounds of the retained points are tracked in the given
+// boundingBoxBuilder.
+func readPoints(r las.PointReader, count int, conv coor.Converter, mut mutator.Mutator, crs string, localToGlobal model.Transform, bbox *boundingBoxBuilder, ctx context.Context, attrs []float64, store func(i int, pt model.Point) error) error {
+	for i := 0; i < count; i++ {
 		if err := ctx.Err(); err != nil {
-			errchan <- err
-			return
+			return err
 		}
-		pt, err := r.GetNext()
+		pt, err := las.NextPoint(r, attrs)
 		if err != nil {
-			errchan <- err
-			return
+			return err
 		}
 
-		pt, err = transformPoint(pt, c.conv, c.crs)
+		pt, err = transformPoint(mutateSource(pt, mut), conv, crs)
 		if err != nil {
-			errchan <- err
-			return
+			return err
 		}
 
 		// transform local and update bounds
@@ -227,35 +308,38 @@ func (c *consumer) consume(r las.LasReader, localToGlobal model.Transform, errch
 		keep := true
 
 		// mutate the point
-		if c.mutator != nil {
-			localPt, keep = c.mutator.Mutate(localPt, localToGlobal)
+		if mut != nil {
+			localPt, keep = mut.Mutate(localPt, localToGlobal)
 			if !keep {
 				// point should be discarded, move on
-				i++
 				continue
 			}
 		}
-		c.bboxBuilder.processPoint(localPt.X, localPt.Y, localPt.Z)
-
-		// store point in backing array at the right offset
-		(*c.backingArray)[c.start+i] = geom.LinkedPoint{Pt: localPt}
-		newPt := &((*c.backingArray)[c.start+i])
-		if currentPt == nil {
-			currentPt = newPt
-			c.startPt = currentPt
-		} else {
-			currentPt.Next = newPt
-			currentPt = newPt
-			c.endPt = currentPt
+		bbox.processPoint(localPt.X, localPt.Y, localPt.Z)
+		if err := store(i, localPt); err != nil {
+			return err
 		}
-		i++
 	}
+	return nil
 }
 
-// transformPoint converts a point from the original CRS to EPSG:4978
+// mutateSource applies the mutator to the coordinates of the point in the original CRS, if it implements
+// mutator.SourceMutator
+func mutateSource(pt geom.Point64, mut mutator.Mutator) geom.Point64 {
+	if sm, ok := mut.(mutator.SourceMutator); ok {
+		pt.Vector = sm.MutateSource(pt.Vector)
+	}
+	return pt
+}
+
+// transformPoint converts a point from the original CRS to EPSG:4978. The CRS of the point, if set, takes
+// precedence over the given source CRS.
 func transformPoint(pt geom.Point64, conv coor.Converter, sourceCRS string) (geom.Point64, error) {
 	var err error
 
+	if pt.CRS != "" {
+		sourceCRS = pt.CRS
+	}
 	out, err := conv.ToWGS84Cartesian(sourceCRS, model.Vector{X: pt.X, Y: pt.Y, Z: pt.Z})
 	if err != nil {
 		return pt, err
@@ -0,0 +1,226 @@
+package grid
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/conv/coor"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/mutator"
+)
+
+// rangeLasReader is a mock las reader able to read ranges of points, unless err is set
+type rangeLasReader struct {
+	*las.MockLasReader
+	err    error
+	ranges []*las.MockLasReader
+}
+
+func (r *rangeLasReader) ReadRange(start, end int) (las.PointReader, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	pr := &las.MockLasReader{Pts: r.Pts[start:end], Values: r.Values[start:end]}
+	r.ranges = append(r.ranges, pr)
+	return pr, nil
+}
+
+func TestWorkerRanges(t *testing.T) {
+	actual := workerRanges(2, 13, 3)
+	expected := [][2]int{{2, 6}, {6, 10}, {10, 13}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected ranges %v got %v", expected, actual)
+	}
+	actual = workerRanges(0, 1, 2)
+	expected = [][2]int{{0, 1}, {1, 1}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected ranges %v got %v", expected, actual)
+	}
+}
+
+func TestPointReaders(t *testing.T) {
+	ranges := workerRanges(0, 10, 2)
+
+	// readers not supporting ranges are shared
+	sequential := randomCloud(10)
+	readers, closeReaders, err := pointReaders(sequential, ranges)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, r := range readers {
+		if r != sequential {
+			t.Errorf("expected the las reader to be shared")
+		}
+	}
+	closeReaders()
+	if sequential.CloseCalled {
+		t.Errorf("expected the las reader not to be closed")
+	}
+
+	// as well as readers not supporting ranges for their content
+	unsupported := &rangeLasReader{MockLasReader: randomCloud(10), err: las.ErrRangesNotSupported}
+	readers, _, err = pointReaders(unsupported, ranges)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, r := range readers {
+		if r != unsupported {
+			t.Errorf("expected the las reader to be shared")
+		}
+	}
+
+	// else each range gets its own reader
+	ranged := &rangeLasReader{MockLasReader: randomCloud(10)}
+	readers, closeReaders, err = pointReaders(ranged, ranges)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, r := range readers {
+		if r != ranged.ranges[i] {
+			t.Errorf("expected reader %d to read its own range", i)
+		}
+		if actual := ranged.ranges[i].Pts; !reflect.DeepEqual(actual, ranged.Pts[ranges[i][0]:ranges[i][1]]) {
+			t.Errorf("expected reader %d to read the range %v", i, ranges[i])
+		}
+	}
+	closeReaders()
+	for i, r := range ranged.ranges {
+		if !r.CloseCalled {
+			t.Errorf("expected reader %d to be closed", i)
+		}
+	}
+}
+
+func TestGridTreeLoadRanges(t *testing.T) {
+	opts := []func(*Node){WithGridSize(20), WithMaxDepth(5), WithMinPointsPerChildren(10), WithLoadWorkersNumber(4)}
+
+	expected := NewTree(opts...)
+	if err := expected.Load(&syncLasReader{LasReader: randomCloud(5000)}, identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := expected.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ranged := &rangeLasReader{MockLasReader: randomCloud(5000)}
+	actual := NewTree(opts...)
+	if err := actual.Load(ranged, identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := actual.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ranged.ranges) != 4 {
+		t.Errorf("expected %d range readers got %d", 4, len(ranged.ranges))
+	}
+	compareNodes(t, "r", expected, actual)
+}
+
+// offsetConverter shifts the X coordinate by the offset of the source CRS and fails for unknown CRSs
+type offsetConverter map[string]float64
+
+func (c offsetConverter) Transform(sourceCRS string, targetCRS string, coord model.Vector) (model.Vector, error) {
+	offset, ok := c[sourceCRS]
+	if !ok {
+		return model.Vector{}, fmt.Errorf("unknown crs %s", sourceCRS)
+	}
+	coord.X += offset
+	return coord, nil
+}
+
+func (c offsetConverter) ToWGS84Cartesian(sourceCRS string, coord model.Vector) (model.Vector, error) {
+	return c.Transform(sourceCRS, "EPSG:4978", coord)
+}
+
+func (c offsetConverter) Cleanup() {}
+
+func TestGridTreeLoadPointCRS(t *testing.T) {
+	// the second half of the points has its own CRS, offset by 1000 meters
+	cloud := randomCloud(1000)
+	cloud.CRS = "A"
+	for i := 500; i < len(cloud.Pts); i++ {
+		cloud.Pts[i].CRS = "B"
+	}
+	factory := func() (coor.Converter, error) {
+		return offsetConverter{"A": 0, "B": 1000}, nil
+	}
+	tr := NewTree(WithGridSize(20), WithMaxDepth(5), WithMinPointsPerChildren(10), WithLoadWorkersNumber(2))
+	if err := tr.Load(&rangeLasReader{MockLasReader: cloud}, factory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// all nodes store the points in the local CRS of the root
+	localToGlobal := tr.ToParentCRS()
+	shifted := 0
+	var visit func(n tree.Node)
+	visit = func(n tree.Node) {
+		for _, pt := range orderedPoints(t, n) {
+			if localToGlobal.Forward(model.Vector{X: float64(pt.X), Y: float64(pt.Y), Z: float64(pt.Z)}).X > 4472500 {
+				shifted++
+			}
+		}
+		for _, c := range n.Children() {
+			if c != nil {
+				visit(c)
+			}
+		}
+	}
+	visit(tr)
+	if shifted != 500 {
+		t.Errorf("expected %d points converted from their own crs got %d", 500, shifted)
+	}
+
+	// points without a CRS use the one of the reader
+	cloud.Cur = 0
+	cloud.CRS = "C"
+	if err := NewTree().Load(cloud, factory, nil, context.TODO()); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
+
+func TestGridTreeLoadSourceMutator(t *testing.T) {
+	// the source coordinates are shifted by 1000 meters before being converted
+	cloud := randomCloud(1000)
+	cloud.CRS = "A"
+	factory := func() (coor.Converter, error) {
+		return offsetConverter{"A": 0}, nil
+	}
+	shift := mutator.NewAffine(model.NewTransform([4][4]float64{
+		{1, 0, 0, 1000},
+		{0, 1, 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	}))
+	tr := NewTree(WithGridSize(20), WithMaxDepth(5), WithMinPointsPerChildren(10), WithLoadWorkersNumber(2))
+	if err := tr.Load(&rangeLasReader{MockLasReader: cloud}, factory, mutator.NewPipeline(shift), context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	localToGlobal := tr.ToParentCRS()
+	shifted := 0
+	var visit func(n tree.Node)
+	visit = func(n tree.Node) {
+		for _, pt := range orderedPoints(t, n) {
+			if localToGlobal.Forward(model.Vector{X: float64(pt.X), Y: float64(pt.Y), Z: float64(pt.Z)}).X > 4472500 {
+				shifted++
+			}
+		}
+		for _, c := range n.Children() {
+			if c != nil {
+				visit(c)
+			}
+		}
+	}
+	visit(tr)
+	if shifted != 1000 {
+		t.Errorf("expected %d points shifted in the source CRS got %d", 1000, shifted)
+	}
+}
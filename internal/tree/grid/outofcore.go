@@ -0,0 +1,721 @@
+package grid
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/conv/coor"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/mutator"
+)
+
+// pointMemorySize is the approximate number of bytes taken by a point loaded in a grid Node, excluding its
+// attributes, accounting for the point store, the sampling map and the encoding buffers
+const pointMemorySize = 64
+
+// maxSamplingPartitions is the maximum number of partitions the points of an out of core node can be split into
+// to bound the memory taken by its sampling grid, limiting the number of spill files open at the same time
+const maxSamplingPartitions = 256
+
+// OutOfCoreTree implements the Tree interface for point clouds that do not fit in memory, producing the same
+// tree of the grid Node. The points are spilled to temporary files while loaded, then each node is built with a
+// streaming pass over its points, storing the sampled points and the points falling in each child octant in
+// separate files. As soon as the points of a node fit in a quarter of the memory limit, the subtree rooted at it
+// is loaded and built in memory as a grid Node, when the writer first needs it. Loaded subtrees are released,
+// least recently used first, to keep them within half of the memory limit and are rebuilt from their files if
+// needed again. The sampling grid of a node built out of core is kept within a quarter of the memory limit by
+// splitting its points by grid cell in up to maxSamplingPartitions files, sampled one at a time, and the build fails
+// if that is not enough. The memory limit is approximate: it bounds the points held in memory, not the buffers of
+// the spill files nor the tiles being encoded by the writer.
+//
+// The temporary files are removed by Close.
+type OutOfCoreTree struct {
+	// opts holds the grid options, used to build all the nodes
+	opts        *Node
+	memoryLimit int64
+	tempDir     string
+	// dir is the folder storing the spill files of this tree
+	dir    string
+	codec  spillCodec
+	root   tree.Node
+	cache  *subtreeCache
+	nextID atomic.Int64
+	// builder builds the nodes of all the in memory subtrees concurrently, nil if the tree is built serially
+	builder *parallelBuilder
+}
+
+// NewOutOfCoreTree returns a new out of core tree holding at most approximately memoryLimit bytes of points in
+// memory and spilling the others to temporary files in tempDir, or in the default temporary folder if empty.
+// The options are the same of the in memory grid tree.
+// With the parallel build the in memory subtrees are built in background once loaded, sharing the same workers.
+func NewOutOfCoreTree(memoryLimit int64, tempDir string, opts ...func(*Node)) *OutOfCoreTree {
+	t := &OutOfCoreTree{
+		opts:        NewTree(opts...),
+		memoryLimit: memoryLimit,
+		tempDir:     tempDir,
+		cache:       newSubtreeCache(memoryLimit / 2),
+	}
+	if t.opts.parallelBuild {
+		t.builder = newParallelBuilder(t.opts.loadWorkersNumber)
+	}
+	return t
+}
+
+// Load spills the points of the reader to temporary files, converting them into local coordinates, and closes it
+func (t *OutOfCoreTree) Load(r las.LasReader, convFactory coor.ConverterFactory, mut mutator.Mutator, ctx context.Context) (err error) {
+	defer r.Close()
+	numPts := r.NumberOfPoints()
+	if numPts == 0 {
+		return fmt.Errorf("las with no points")
+	}
+	if t.dir, err = os.MkdirTemp(t.tempDir, "gocesiumtiler-"); err != nil {
+		return fmt.Errorf("unable to create the temporary folder: %w", err)
+	}
+	t.codec = spillCodec{attributes: geom.AttributesLength(r.Attributes())}
+
+	l := &loader{
+		createCoorConverter: convFactory,
+		mutator:             mut,
+		workers:             t.opts.loadWorkersNumber,
+	}
+	c, err := convFactory()
+	if err != nil {
+		return err
+	}
+	defer c.Cleanup()
+	baseAttrs := make([]float64, t.codec.attributes)
+	localToGlobal, base, read, err := l.baseline(r, c, baseAttrs)
+	if err != nil {
+		return err
+	}
+
+	// each worker spills the points it reads to its own file, the first one also stores the baseline point
+	writers := make([]*spillWriter, l.workers)
+	for i := range writers {
+		if writers[i], err = newSpillWriter(t.spillPath(), t.codec); err != nil {
+			for _, w := range writers[:i] {
+				w.close()
+			}
+			return err
+		}
+	}
+	ranges := workerRanges(read, numPts, l.workers)
+	readers, closeReaders, err := pointReaders(r, ranges)
+	if err != nil {
+		for _, w := range writers {
+			w.close()
+		}
+		return err
+	}
+	defer closeReaders()
+	errs := make([]error, l.workers)
+	errs[0] = writers[0].write(base, baseAttrs)
+	bboxBuilders := make([]*boundingBoxBuilder, l.workers)
+	var wg sync.WaitGroup
+	for i, rg := range ranges {
+		count := rg[1] - rg[0]
+		bboxBuilders[i] = newBoundingBoxBuilder()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("panic while reading from las: %v", r)
+				}
+			}()
+			if errs[i] != nil {
+				return
+			}
+			conv, err := convFactory()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer conv.Cleanup()
+			attrs := make([]float64, t.codec.attributes)
+			errs[i] = readPoints(readers[i], count, conv, mut, r.GetCRS(), localToGlobal, bboxBuilders[i], ctx, attrs, func(_ int, pt model.Point) error {
+				return writers[i].write(pt, attrs)
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	files := make([]spillFile, l.workers)
+	for i, w := range writers {
+		f, err := w.close()
+		if errs[i] == nil {
+			errs[i] = err
+		}
+		files[i] = f
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	bboxBuilder := bboxBuilders[0]
+	for _, b := range bboxBuilders[1:] {
+		bboxBuilder.mergeWith(b)
+	}
+	bboxBuilder.processPoint(base.X, base.Y, base.Z)
+	t.root = t.newNode(files, bboxBuilder.build(), 0, t.opts.gridSize, &localToGlobal)
+	return nil
+}
+
+// Build builds all the nodes that do not fit in memory, the in memory subtrees are built when first accessed
+func (t *OutOfCoreTree) Build() error {
+	switch n := t.root.(type) {
+	case *outOfCoreNode:
+		return n.build()
+	case *subtreeNode:
+		_, _, err := n.metadata()
+		return err
+	}
+	return nil
+}
+
+func (t *OutOfCoreTree) RootNode() tree.Node {
+	return t.root
+}
+
+// Close stops the background build of the loaded subtrees and removes the temporary files of the tree
+func (t *OutOfCoreTree) Close() error {
+	t.cache.clear()
+	if t.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(t.dir)
+}
+
+// pointSize returns the approximate number of bytes taken by a point loaded in memory
+func (t *OutOfCoreTree) pointSize() int64 {
+	return int64(pointMemorySize + 8*t.codec.attributes)
+}
+
+// spillPath returns the path of a new spill file
+func (t *OutOfCoreTree) spillPath() string {
+	return filepath.Join(t.dir, strconv.FormatInt(t.nextID.Add(1), 10)+".pts")
+}
+
+// newNode returns the node containing the points of the given files, built in memory if they fit in the limit
+func (t *OutOfCoreTree) newNode(files []spillFile, bounds geom.BoundingBox, depth int, gridSize float64, localToGlobal *model.Transform) tree.Node {
+	total := 0
+	for _, f := range files {
+		total += f.count
+	}
+	if int64(total)*t.pointSize() <= t.memoryLimit/4 {
+		return &subtreeNode{
+			tree:          t,
+			files:         files,
+			total:         total,
+			bounds:        bounds,
+			depth:         depth,
+			gridSize:      gridSize,
+			localToGlobal: localToGlobal,
+		}
+	}
+	return &outOfCoreNode{
+		tree:          t,
+		input:         files,
+		total:         total,
+		bounds:        bounds,
+		depth:         depth,
+		gridSize:      gridSize,
+		localToGlobal: localToGlobal,
+	}
+}
+
+// outOfCoreNode is a node whose points do not fit in memory. Its own points are stored in a spill file and
+// loaded only when requested.
+type outOfCoreNode struct {
+	tree *OutOfCoreTree
+	// input are the files storing all the points of the node and its children, removed once built
+	input         []spillFile
+	total         int
+	bounds        geom.BoundingBox
+	depth         int
+	gridSize      float64
+	localToGlobal *model.Transform
+	// points is the file storing the points retained by the node
+	points   []spillFile
+	children [8]tree.Node
+}
+
+// build samples the points of the node with the same grid algorithm of the grid Node, streaming them from the
+// input files and spilling the ones not retained to a file per child octant, then builds the children
+func (n *outOfCoreNode) build() error {
+	opts := n.tree.opts
+	if n.depth >= opts.maxDepth {
+		// reached maxDepth, swallow in all points
+		n.points, n.input = n.input, nil
+		return nil
+	}
+
+	sampling := newSamplingGrid(n.bounds, n.gridSize)
+	parts, err := n.samplingPartitions(sampling)
+	if err != nil {
+		return err
+	}
+	partitions := [][]spillFile{n.input}
+	if parts > 1 {
+		if partitions, err = n.partition(sampling, parts); err != nil {
+			return err
+		}
+		removeSpill(n.input)
+	}
+	n.input = nil
+
+	// the retained points are stored first, followed by the points of the children with too few points
+	w, err := newSpillWriter(n.tree.spillPath(), n.tree.codec)
+	if err != nil {
+		return err
+	}
+	var writers [8]*spillWriter
+	push := func(pt model.Point, attrs []float64) error {
+		idx := childIndex(n.bounds, pt.X, pt.Y, pt.Z)
+		if writers[idx] == nil {
+			w, err := newSpillWriter(n.tree.spillPath(), n.tree.codec)
+			if err != nil {
+				return err
+			}
+			writers[idx] = w
+		}
+		return writers[idx].write(pt, attrs)
+	}
+	for _, files := range partitions {
+		if err = sample(files, n.tree.codec, sampling, w.write, push); err != nil {
+			break
+		}
+		removeSpill(files)
+	}
+	var childFiles [8]spillFile
+	for i, cw := range writers {
+		if cw != nil {
+			f, cerr := cw.close()
+			if err == nil {
+				err = cerr
+			}
+			childFiles[i] = f
+		}
+	}
+
+	// roll up the children with too few points
+	for i, f := range childFiles {
+		if err != nil {
+			break
+		}
+		if f.count > 0 && f.count < opts.minPointsPerChildren {
+			err = readSpill([]spillFile{f}, n.tree.codec, w.write)
+			removeSpill([]spillFile{f})
+			childFiles[i] = spillFile{}
+		}
+	}
+	points, cerr := w.close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	n.points = []spillFile{points}
+
+	for i, f := range childFiles {
+		if f.count == 0 {
+			continue
+		}
+		child := n.tree.newNode([]spillFile{f}, geom.NewBoundingBoxFromParent(n.bounds, i), n.depth+1, n.gridSize/2, nil)
+		if c, ok := child.(*outOfCoreNode); ok {
+			if err := c.build(); err != nil {
+				return err
+			}
+		}
+		n.children[i] = child
+	}
+	return nil
+}
+
+// samplingPartitions returns the number of partitions the points of the node are split into, by sampling grid
+// cell, so that the winners of the cells of each partition fit in a quarter of the memory limit. The number of
+// winners is bounded by both the number of points and of cells. Returns an error if the memory limit is too low
+// to sample the node with at most maxSamplingPartitions partitions.
+func (n *outOfCoreNode) samplingPartitions(sampling samplingGrid) (int, error) {
+	size := math.Min(float64(n.total), sampling.nX*sampling.nY*sampling.nZ) * float64(n.tree.pointSize())
+	budget := float64(n.tree.memoryLimit / 4)
+	if size <= budget {
+		return 1, nil
+	}
+	parts := math.Ceil(size / math.Max(budget, 1))
+	if parts > maxSamplingPartitions {
+		required := math.Ceil(4 * size / maxSamplingPartitions / (1 << 20))
+		return 0, fmt.Errorf("the memory limit is too low to sample the %d points of the tree node at depth %d, at least %.0f MB are required", n.total, n.depth, required)
+	}
+	return int(parts), nil
+}
+
+// partition splits the input points of the node in the given number of files, assigning all the points of the same
+// sampling grid cell to the same file and preserving their order
+func (n *outOfCoreNode) partition(sampling samplingGrid, parts int) ([][]spillFile, error) {
+	writers := make([]*spillWriter, parts)
+	err := readSpill(n.input, n.tree.codec, func(pt model.Point, attrs []float64) error {
+		cellIndex, _ := sampling.cell(pt.X, pt.Y, pt.Z)
+		// spatial hash of the cell, to spread the cells evenly across the partitions
+		h := uint32(cellIndex[0])*73856093 ^ uint32(cellIndex[1])*19349663 ^ uint32(cellIndex[2])*83492791
+		idx := h % uint32(parts)
+		if writers[idx] == nil {
+			w, err := newSpillWriter(n.tree.spillPath(), n.tree.codec)
+			if err != nil {
+				return err
+			}
+			writers[idx] = w
+		}
+		return writers[idx].write(pt, attrs)
+	})
+	partitions := [][]spillFile{}
+	for _, w := range writers {
+		if w != nil {
+			f, cerr := w.close()
+			if err == nil {
+				err = cerr
+			}
+			partitions = append(partitions, []spillFile{f})
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return partitions, nil
+}
+
+// sample streams the points of the files, calling retain for the point closest to the center of each sampling grid
+// cell, in order of discovery of the cells, and discard for all the other points
+func sample(files []spillFile, codec spillCodec, sampling samplingGrid, retain, discard func(model.Point, []float64) error) error {
+	type cell struct {
+		pt   model.Point
+		dist float64
+	}
+	// as in the grid Node the winners are stored in order of discovery, so that the order of the points of the node
+	// only depends on the order of the input points. Their attribute values are stored in a columnar array.
+	winners := []cell{}
+	winnerAttrs := []float64{}
+	n := codec.attributes
+	grid := map[[3]int32]int{}
+	err := readSpill(files, codec, func(pt model.Point, attrs []float64) error {
+		cellIndex, dist := sampling.cell(pt.X, pt.Y, pt.Z)
+		w, ok := grid[cellIndex]
+		if !ok {
+			grid[cellIndex] = len(winners)
+			winners = append(winners, cell{pt: pt, dist: dist})
+			winnerAttrs = append(winnerAttrs, attrs...)
+			return nil
+		}
+		if dist < winners[w].dist {
+			loser := winners[w].pt
+			winners[w] = cell{pt: pt, dist: dist}
+			if err := discard(loser, winnerAttrs[w*n:(w+1)*n]); err != nil {
+				return err
+			}
+			copy(winnerAttrs[w*n:(w+1)*n], attrs)
+			return nil
+		}
+		return discard(pt, attrs)
+	})
+	if err != nil {
+		return err
+	}
+	for w, c := range winners {
+		if err := retain(c.pt, winnerAttrs[w*n:(w+1)*n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *outOfCoreNode) BoundingBox() geom.BoundingBox {
+	return n.bounds
+}
+
+func (n *outOfCoreNode) Children() [8]tree.Node {
+	return n.children
+}
+
+// Points loads the points of the node from its spill file. As Node does not allow returning errors, if the file
+// cannot be read the returned list fails with the load error when reading its points.
+func (n *outOfCoreNode) Points() geom.PointList {
+	store, head, count, err := loadSpill(n.points, n.tree.codec)
+	if err != nil {
+		return &errorPointList{len: n.NumberOfPoints(), err: fmt.Errorf("unable to load the points of the node: %w", err)}
+	}
+	return newPointStream(store, head, count)
+}
+
+func (n *outOfCoreNode) TotalNumberOfPoints() int {
+	return n.total
+}
+
+func (n *outOfCoreNode) NumberOfPoints() int {
+	count := 0
+	for _, f := range n.points {
+		count += f.count
+	}
+	return count
+}
+
+func (n *outOfCoreNode) IsRoot() bool {
+	return n.depth == 0
+}
+
+func (n *outOfCoreNode) IsLeaf() bool {
+	for _, c := range n.children {
+		if c != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *outOfCoreNode) GeometricError() float64 {
+	return math.Sqrt(n.gridSize * n.gridSize * 3)
+}
+
+func (n *outOfCoreNode) ToParentCRS() *model.Transform {
+	return n.localToGlobal
+}
+
+// subtreeNode is the root of a subtree whose points fit in memory. The subtree is loaded from the spill files
+// and built as a grid Node when first accessed, and can be released and rebuilt as needed.
+type subtreeNode struct {
+	tree          *OutOfCoreTree
+	files         []spillFile
+	total         int
+	bounds        geom.BoundingBox
+	depth         int
+	gridSize      float64
+	localToGlobal *model.Transform
+	// root is the in memory subtree, nil if not loaded
+	root atomic.Pointer[Node]
+	// mu guards the loading of the subtree and the properties cached after the first build
+	mu sync.Mutex
+	// stopPrefetch stops the background build of the loaded subtree, nil if not started
+	stopPrefetch atomic.Pointer[func()]
+
+	built     bool
+	numPoints int
+	leaf      bool
+}
+
+// get returns the in memory subtree, loading it if needed
+func (s *subtreeNode) get() (*Node, error) {
+	if r := s.root.Load(); r != nil {
+		s.tree.cache.touch(s)
+		return r, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r := s.root.Load(); r != nil {
+		return r, nil
+	}
+	return s.load()
+}
+
+// metadata returns the number of points and the leaf status of the subtree root, building it once if needed
+func (s *subtreeNode) metadata() (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.built {
+		if _, err := s.load(); err != nil {
+			return 0, false, err
+		}
+	}
+	return s.numPoints, s.leaf, nil
+}
+
+// load reads the points from the spill files and builds the subtree. Must be called holding the mutex.
+func (s *subtreeNode) load() (*Node, error) {
+	store, head, _, err := loadSpill(s.files, s.tree.codec)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the points of the subtree: %w", err)
+	}
+	opts := s.tree.opts
+	r := &Node{
+		store:                store,
+		pts:                  head,
+		childrenPts:          emptyChildrenPts(),
+		bounds:               s.bounds,
+		depth:                s.depth,
+		maxDepth:             opts.maxDepth,
+		gridSize:             s.gridSize,
+		minPointsPerChildren: opts.minPointsPerChildren,
+		builder:              s.tree.builder,
+		localToGlobal:        s.localToGlobal,
+	}
+	if err := r.Build(); err != nil {
+		return nil, err
+	}
+	s.numPoints, s.leaf, s.built = r.NumberOfPoints(), r.IsLeaf(), true
+	if r.builder != nil {
+		stop := r.builder.startPrefetch(r)
+		s.stopPrefetch.Store(&stop)
+	}
+	s.root.Store(r)
+	s.tree.cache.add(s, int64(s.total)*s.tree.pointSize())
+	return r, nil
+}
+
+// release drops the in memory subtree, stopping its background build
+func (s *subtreeNode) release() {
+	s.root.Store(nil)
+	if stop := s.stopPrefetch.Swap(nil); stop != nil {
+		(*stop)()
+	}
+}
+
+func (s *subtreeNode) BoundingBox() geom.BoundingBox {
+	return s.bounds
+}
+
+// Children returns the children of the subtree root. As Node does not allow returning errors, no children are
+// returned if the subtree cannot be loaded, the error being reported by Points.
+func (s *subtreeNode) Children() [8]tree.Node {
+	r, err := s.get()
+	if err != nil {
+		return [8]tree.Node{}
+	}
+	return r.Children()
+}
+
+// Points returns the points of the subtree root. As Node does not allow returning errors, if the subtree cannot
+// be loaded the returned list fails with the load error when reading its points.
+func (s *subtreeNode) Points() geom.PointList {
+	r, err := s.get()
+	if err != nil {
+		return &errorPointList{len: s.total, err: err}
+	}
+	return r.Points()
+}
+
+func (s *subtreeNode) TotalNumberOfPoints() int {
+	return s.total
+}
+
+// NumberOfPoints returns the number of points of the subtree root. If the subtree cannot be loaded the total
+// number of points is returned, so that the node is written and the error reported by Points.
+func (s *subtreeNode) NumberOfPoints() int {
+	n, _, err := s.metadata()
+	if err != nil {
+		return s.total
+	}
+	return n
+}
+
+func (s *subtreeNode) IsRoot() bool {
+	return s.depth == 0
+}
+
+// IsLeaf returns true if the subtree root has no children, as when the subtree cannot be loaded
+func (s *subtreeNode) IsLeaf() bool {
+	_, leaf, err := s.metadata()
+	return leaf || err != nil
+}
+
+func (s *subtreeNode) GeometricError() float64 {
+	return math.Sqrt(s.gridSize * s.gridSize * 3)
+}
+
+func (s *subtreeNode) ToParentCRS() *model.Transform {
+	return s.localToGlobal
+}
+
+// errorPointList is a list of points that cannot be read, returning the given error
+type errorPointList struct {
+	len int
+	err error
+}
+
+func (l *errorPointList) Len() int {
+	return l.len
+}
+
+func (l *errorPointList) Next() (model.Point, error) {
+	return model.Point{}, l.err
+}
+
+func (l *errorPointList) Attributes() []float64 {
+	return nil
+}
+
+func (l *errorPointList) Reset() {}
+
+// subtreeCache keeps track of the loaded subtrees, releasing the least recently used ones when their
+// total size exceeds the limit
+type subtreeCache struct {
+	mu      sync.Mutex
+	limit   int64
+	size    int64
+	entries *list.List
+	index   map[*subtreeNode]*list.Element
+}
+
+type subtreeCacheEntry struct {
+	node *subtreeNode
+	size int64
+}
+
+func newSubtreeCache(limit int64) *subtreeCache {
+	return &subtreeCache{
+		limit:   limit,
+		entries: list.New(),
+		index:   map[*subtreeNode]*list.Element{},
+	}
+}
+
+// add records a newly loaded subtree of the given size, releasing the least recently used ones if needed
+func (c *subtreeCache) add(s *subtreeNode, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.index[s]; ok {
+		c.size -= e.Value.(*subtreeCacheEntry).size
+		c.entries.Remove(e)
+	}
+	c.index[s] = c.entries.PushFront(&subtreeCacheEntry{node: s, size: size})
+	c.size += size
+	for c.size > c.limit && c.entries.Len() > 1 {
+		e := c.entries.Back()
+		entry := e.Value.(*subtreeCacheEntry)
+		c.entries.Remove(e)
+		delete(c.index, entry.node)
+		c.size -= entry.size
+		entry.node.release()
+	}
+}
+
+// clear releases all the loaded subtrees
+func (c *subtreeCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.entries.Front(); e != nil; e = e.Next() {
+		e.Value.(*subtreeCacheEntry).node.release()
+	}
+	c.entries.Init()
+	c.index = map[*subtreeNode]*list.Element{}
+	c.size = 0
+}
+
+// touch marks the subtree as the most recently used one
+func (c *subtreeCache) touch(s *subtreeNode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.index[s]; ok {
+		c.entries.MoveToFront(e)
+	}
+}
@@ -0,0 +1,409 @@
+package grid
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/conv/coor"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// identityConverter is a converter that assumes the input coordinates to be already in EPSG 4978
+type identityConverter struct{}
+
+func (c identityConverter) Transform(sourceCRS string, targetCRS string, coord model.Vector) (model.Vector, error) {
+	return coord, nil
+}
+
+func (c identityConverter) ToWGS84Cartesian(sourceCRS string, coord model.Vector) (model.Vector, error) {
+	return coord, nil
+}
+
+func (c identityConverter) Cleanup() {}
+
+func identityConverterFactory() (coor.Converter, error) {
+	return identityConverter{}, nil
+}
+
+// syncLasReader makes a las reader safe for concurrent use
+type syncLasReader struct {
+	las.LasReader
+	sync.Mutex
+}
+
+func (r *syncLasReader) GetNext() (geom.Point64, error) {
+	r.Lock()
+	defer r.Unlock()
+	return r.LasReader.GetNext()
+}
+
+func (r *syncLasReader) GetNextWithAttributes(attrs []float64) (geom.Point64, error) {
+	r.Lock()
+	defer r.Unlock()
+	return las.NextPoint(r.LasReader, attrs)
+}
+
+// randomCloud returns a mock reader of n random points in a 100m cube centered on the earth surface
+func randomCloud(n int) *las.MockLasReader {
+	rnd := rand.New(rand.NewSource(42))
+	pts := make([]geom.Point64, n)
+	values := make([][]float64, n)
+	for i := range pts {
+		pts[i] = geom.Point64{
+			Vector: model.Vector{
+				X: 4472000 + rnd.Float64()*100,
+				Y: 1000000 + rnd.Float64()*100,
+				Z: 4417000 + rnd.Float64()*100,
+			},
+			R:              uint8(rnd.Intn(256)),
+			G:              uint8(rnd.Intn(256)),
+			B:              uint8(rnd.Intn(256)),
+			Intensity:      uint8(rnd.Intn(256)),
+			Classification: uint8(rnd.Intn(32)),
+		}
+		values[i] = []float64{rnd.Float64()}
+	}
+	return &las.MockLasReader{
+		CRS:    "EPSG:4978",
+		Pts:    pts,
+		Attrs:  []geom.Attribute{{Name: "attr", Type: geom.AttributeFloat64, Components: 1}},
+		Values: values,
+	}
+}
+
+func sortedPoints(t *testing.T, n tree.Node) []model.Point {
+	list := n.Points()
+	pts := make([]model.Point, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		pt, err := list.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pts = append(pts, pt)
+	}
+	sort.Slice(pts, func(i, j int) bool {
+		if pts[i].X != pts[j].X {
+			return pts[i].X < pts[j].X
+		}
+		if pts[i].Y != pts[j].Y {
+			return pts[i].Y < pts[j].Y
+		}
+		return pts[i].Z < pts[j].Z
+	})
+	return pts
+}
+
+// compareNodes verifies the two nodes and all their descendants are equivalent
+func compareNodes(t *testing.T, path string, expected, actual tree.Node) {
+	if (expected == nil) != (actual == nil) {
+		t.Fatalf("node %s: expected %v got %v", path, expected, actual)
+	}
+	if expected == nil {
+		return
+	}
+	if e, a := expected.BoundingBox(), actual.BoundingBox(); !reflect.DeepEqual(e, a) {
+		t.Errorf("node %s: expected bounds %v got %v", path, e, a)
+	}
+	if e, a := expected.TotalNumberOfPoints(), actual.TotalNumberOfPoints(); e != a {
+		t.Errorf("node %s: expected %d total points got %d", path, e, a)
+	}
+	if e, a := expected.NumberOfPoints(), actual.NumberOfPoints(); e != a {
+		t.Errorf("node %s: expected %d points got %d", path, e, a)
+	}
+	if e, a := expected.IsLeaf(), actual.IsLeaf(); e != a {
+		t.Errorf("node %s: expected leaf %v got %v", path, e, a)
+	}
+	if e, a := expected.IsRoot(), actual.IsRoot(); e != a {
+		t.Errorf("node %s: expected root %v got %v", path, e, a)
+	}
+	if e, a := expected.GeometricError(), actual.GeometricError(); e != a {
+		t.Errorf("node %s: expected geometric error %f got %f", path, e, a)
+	}
+	if e, a := expected.ToParentCRS(), actual.ToParentCRS(); !reflect.DeepEqual(e, a) {
+		t.Errorf("node %s: expected transform %v got %v", path, e, a)
+	}
+	if e, a := sortedPoints(t, expected), sortedPoints(t, actual); !reflect.DeepEqual(e, a) {
+		t.Errorf("node %s: points differ", path)
+	}
+	ec, ac := expected.Children(), actual.Children()
+	for i := range ec {
+		compareNodes(t, path+string(rune('0'+i)), ec[i], ac[i])
+	}
+}
+
+func TestOutOfCoreTreeMatchesGridTree(t *testing.T) {
+	opts := []func(*Node){WithGridSize(40), WithMaxDepth(5), WithMinPointsPerChildren(10), WithLoadWorkersNumber(3)}
+
+	expected := NewTree(opts...)
+	if err := expected.Load(&syncLasReader{LasReader: randomCloud(5000)}, identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := expected.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, limit := range []int64{256, 100000, 1 << 30} {
+		actual := NewOutOfCoreTree(limit, t.TempDir(), opts...)
+		if err := actual.Load(&syncLasReader{LasReader: randomCloud(5000)}, identityConverterFactory, nil, context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := actual.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		compareNodes(t, "r", expected.RootNode(), actual.RootNode())
+		if err := actual.Close(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestOutOfCoreTreeParallelBuild(t *testing.T) {
+	opts := []func(*Node){WithGridSize(40), WithMaxDepth(5), WithMinPointsPerChildren(10), WithLoadWorkersNumber(3)}
+
+	expected := NewTree(opts...)
+	if err := expected.Load(&rangeLasReader{MockLasReader: randomCloud(5000)}, identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := expected.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, limit := range []int64{100000, 1 << 30} {
+		actual := NewOutOfCoreTree(limit, t.TempDir(), append(opts, WithParallelBuild(true))...)
+		if err := actual.Load(&rangeLasReader{MockLasReader: randomCloud(5000)}, identityConverterFactory, nil, context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := actual.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actual.builder == nil {
+			t.Fatalf("expected the subtrees to be built in parallel")
+		}
+		compareNodes(t, "r", expected.RootNode(), actual.RootNode())
+		if err := actual.Close(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestOutOfCoreTreeNodeKinds(t *testing.T) {
+	tr := NewOutOfCoreTree(256, t.TempDir(), WithGridSize(40), WithMaxDepth(3), WithMinPointsPerChildren(1))
+	if err := tr.Load(randomCloud(1000), identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// with no memory available for a single point all nodes are out of core, down to the max depth
+	var visit func(n tree.Node, depth int)
+	maxDepth := 0
+	visit = func(n tree.Node, depth int) {
+		if _, ok := n.(*outOfCoreNode); !ok {
+			t.Errorf("expected an out of core node, got %T", n)
+		}
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		for _, c := range n.Children() {
+			if c != nil {
+				visit(c, depth+1)
+			}
+		}
+	}
+	visit(tr.RootNode(), 0)
+	if maxDepth != 3 {
+		t.Errorf("expected max depth %d got %d", 3, maxDepth)
+	}
+
+	tr = NewOutOfCoreTree(1<<30, t.TempDir(), WithGridSize(20), WithMaxDepth(3), WithMinPointsPerChildren(1))
+	if err := tr.Load(randomCloud(1000), identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := tr.RootNode().(*subtreeNode); !ok {
+		t.Errorf("expected an in memory subtree, got %T", tr.RootNode())
+	}
+}
+
+func TestOutOfCoreTreeDeterministic(t *testing.T) {
+	build := func() tree.Node {
+		// the sampling grid of the root is split in multiple partitions
+		tr := NewOutOfCoreTree(256, t.TempDir(), WithGridSize(40), WithMaxDepth(3), WithMinPointsPerChildren(10))
+		t.Cleanup(func() { tr.Close() })
+		if err := tr.Load(randomCloud(2000), identityConverterFactory, nil, context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := tr.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return tr.RootNode()
+	}
+	expected := build()
+	for i := 0; i < 3; i++ {
+		compareOrderedNodes(t, "r", expected, build())
+	}
+}
+
+func TestOutOfCoreTreeMemoryLimitTooLow(t *testing.T) {
+	tr := NewOutOfCoreTree(1, t.TempDir(), WithGridSize(1))
+	defer tr.Close()
+	if err := tr.Load(randomCloud(1000), identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Build(); err == nil || !strings.Contains(err.Error(), "memory limit is too low") {
+		t.Errorf("expected memory limit error got %v", err)
+	}
+}
+
+func TestOutOfCoreTreeLoadErrors(t *testing.T) {
+	tr := NewOutOfCoreTree(256, t.TempDir(), WithGridSize(40), WithMaxDepth(3), WithMinPointsPerChildren(1))
+	if err := tr.Load(randomCloud(1000), identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the spill files are removed, hence the points can no longer be loaded
+	if err := tr.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pts := tr.RootNode().Points()
+	if pts.Len() != tr.RootNode().NumberOfPoints() {
+		t.Errorf("expected %d points got %d", tr.RootNode().NumberOfPoints(), pts.Len())
+	}
+	if _, err := pts.Next(); err == nil {
+		t.Errorf("expected error got none")
+	}
+
+	s := &subtreeNode{tree: tr, files: []spillFile{{path: filepath.Join(t.TempDir(), "missing.pts"), count: 10}}, total: 10}
+	if _, _, err := s.metadata(); err == nil {
+		t.Errorf("expected error got none")
+	}
+	if s.NumberOfPoints() != 10 || !s.IsLeaf() || s.Children() != [8]tree.Node{} {
+		t.Errorf("expected a leaf with %d points got %d points, leaf %v", 10, s.NumberOfPoints(), s.IsLeaf())
+	}
+	pts = s.Points()
+	if pts.Len() != 10 {
+		t.Errorf("expected %d points got %d", 10, pts.Len())
+	}
+	if _, err := pts.Next(); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
+
+func TestOutOfCoreTreeClose(t *testing.T) {
+	tr := NewOutOfCoreTree(256, t.TempDir())
+	if err := tr.Load(randomCloud(100), identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(tr.dir); err != nil {
+		t.Fatalf("expected the temporary folder to exist: %v", err)
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(tr.dir); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary folder to be removed, got %v", err)
+	}
+}
+
+func TestOutOfCoreTreeNoPoints(t *testing.T) {
+	tr := NewOutOfCoreTree(0, t.TempDir())
+	if err := tr.Load(&las.MockLasReader{}, identityConverterFactory, nil, context.TODO()); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
+
+func TestSpillRoundTrip(t *testing.T) {
+	codec := spillCodec{attributes: 2}
+	pts := []model.Point{
+		{X: 1.5, Y: -2.25, Z: 3, R: 1, G: 2, B: 3, Intensity: 4, Classification: 5},
+		{X: -100, Y: 0, Z: 1e6, R: 255, G: 0, B: 128, Intensity: 9, Classification: 31},
+	}
+	attrs := [][]float64{{6.5, -7}, {0, 1e-9}}
+	w, err := newSpillWriter(t.TempDir()+"/test.pts", codec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, pt := range pts {
+		if err := w.write(pt, attrs[i]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	f, err := w.close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.count != 2 {
+		t.Errorf("expected %d points got %d", 2, f.count)
+	}
+	store, head, count, err := loadSpill([]spillFile{f, f}, codec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("expected %d points got %d", 4, count)
+	}
+	for i := 0; head != noPoint; i++ {
+		if pt := store.point(head); pt != pts[i%2] {
+			t.Errorf("expected point %v got %v", pts[i%2], pt)
+		}
+		if values := store.attributeValues(head); !reflect.DeepEqual(values, attrs[i%2]) {
+			t.Errorf("expected attribute values %v got %v", attrs[i%2], values)
+		}
+		head = store.next[head]
+	}
+
+	// truncated files are reported
+	if err := os.Truncate(f.path, int64(codec.recordSize())+1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, _, err := loadSpill([]spillFile{f}, codec); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
+
+func TestSubtreeCache(t *testing.T) {
+	c := newSubtreeCache(100)
+	nodes := make([]*subtreeNode, 4)
+	for i := range nodes {
+		nodes[i] = &subtreeNode{}
+		nodes[i].root.Store(&Node{})
+	}
+	c.add(nodes[0], 40)
+	c.add(nodes[1], 40)
+	c.touch(nodes[0])
+	c.add(nodes[2], 40)
+	if nodes[1].root.Load() != nil {
+		t.Errorf("expected the least recently used subtree to be released")
+	}
+	if nodes[0].root.Load() == nil || nodes[2].root.Load() == nil {
+		t.Errorf("expected the recently used subtrees to be kept")
+	}
+	if c.size != 80 {
+		t.Errorf("expected size %d got %d", 80, c.size)
+	}
+	// a single subtree bigger than the limit is kept
+	c.add(nodes[3], 200)
+	if nodes[3].root.Load() == nil {
+		t.Errorf("expected the last subtree to be kept")
+	}
+	if c.entries.Len() != 1 {
+		t.Errorf("expected %d entries got %d", 1, c.entries.Len())
+	}
+}
@@ -0,0 +1,115 @@
+package grid
+
+import (
+	"sync"
+)
+
+// parallelBuilder builds the nodes of a tree concurrently, bounding the number of nodes being built at the same time.
+// Nodes only modify the links of their own points in the store, hence sibling nodes, and more generally nodes
+// not descending from each other, can be built in parallel. Each node is built exactly as in the serial mode,
+// so the resulting tree does not depend on the order the nodes are built.
+type parallelBuilder struct {
+	workers int
+	// sem bounds the number of nodes being built at the same time
+	sem chan struct{}
+}
+
+func newParallelBuilder(workers int) *parallelBuilder {
+	if workers < 1 {
+		workers = 1
+	}
+	return &parallelBuilder{
+		workers: workers,
+		sem:     make(chan struct{}, workers),
+	}
+}
+
+// build builds the given nodes concurrently and waits for all of them to be built
+func (b *parallelBuilder) build(nodes []*Node) {
+	var wg sync.WaitGroup
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n *Node) {
+			defer wg.Done()
+			b.sem <- struct{}{}
+			defer func() { <-b.sem }()
+			n.build()
+		}(n)
+	}
+	wg.Wait()
+}
+
+// startPrefetch prefetches the tree rooted at the given node in background. Returns a function that stops the
+// prefetch and waits for its workers to exit, the nodes not built yet being built when first queried.
+func (b *parallelBuilder) startPrefetch(root *Node) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.prefetch(root, stop)
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stop) })
+		<-done
+	}
+}
+
+// prefetch traverses the tree breadth first with a pool of workers, building the children of each node, so that
+// the deeper levels are built while the shallower ones are being consumed. Nodes whose children are requested
+// while being built by the prefetch wait for them to complete. Returns once the whole tree is built or, after the
+// nodes being built are complete, once the stop channel is closed.
+func (b *parallelBuilder) prefetch(root *Node, stop <-chan struct{}) {
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	queue := []*Node{root}
+	// busy counts the workers building children, that could add further nodes to the queue
+	busy := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				for len(queue) == 0 && busy > 0 {
+					cond.Wait()
+				}
+				if len(queue) == 0 || stopped(stop) {
+					// no more nodes nor workers able to find new ones, or the prefetch has been stopped
+					mu.Unlock()
+					cond.Broadcast()
+					return
+				}
+				n := queue[0]
+				queue = queue[1:]
+				busy++
+				mu.Unlock()
+
+				children := n.Children()
+
+				mu.Lock()
+				for _, c := range children {
+					if c != nil {
+						queue = append(queue, c.(*Node))
+					}
+				}
+				busy--
+				mu.Unlock()
+				cond.Broadcast()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// stopped returns true if the given channel is closed
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
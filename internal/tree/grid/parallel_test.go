@@ -0,0 +1,177 @@
+package grid
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// orderedPoints returns the points of the node in the order they are listed
+func orderedPoints(t *testing.T, n tree.Node) []model.Point {
+	list := n.Points()
+	pts := make([]model.Point, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		pt, err := list.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pts = append(pts, pt)
+	}
+	return pts
+}
+
+// compareOrderedNodes verifies the two nodes and all their descendants list the same points in the same order
+func compareOrderedNodes(t *testing.T, path string, expected, actual tree.Node) {
+	if (expected == nil) != (actual == nil) {
+		t.Fatalf("node %s: expected %v got %v", path, expected, actual)
+	}
+	if expected == nil {
+		return
+	}
+	if e, a := orderedPoints(t, expected), orderedPoints(t, actual); !reflect.DeepEqual(e, a) {
+		t.Errorf("node %s: points differ", path)
+	}
+	ec, ac := expected.Children(), actual.Children()
+	for i := range ec {
+		compareOrderedNodes(t, path+string(rune('0'+i)), ec[i], ac[i])
+	}
+}
+
+func TestParallelBuildMatchesSerialBuild(t *testing.T) {
+	for _, workers := range []int{1, 2, 8} {
+		opts := []func(*Node){WithGridSize(20), WithMaxDepth(6), WithMinPointsPerChildren(10), WithLoadWorkersNumber(workers)}
+		// read the points by range, so that the trees are loaded with the points in the same order
+		expected := NewTree(opts...)
+		if err := expected.Load(&rangeLasReader{MockLasReader: randomCloud(20000)}, identityConverterFactory, nil, context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := expected.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		actual := NewTree(append(opts, WithParallelBuild(true))...)
+		if err := actual.Load(&rangeLasReader{MockLasReader: randomCloud(20000)}, identityConverterFactory, nil, context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := actual.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if actual.builder == nil {
+			t.Fatalf("expected the tree to be built in parallel")
+		}
+		compareNodes(t, "r", expected, actual)
+		compareOrderedNodes(t, "r", expected, actual)
+	}
+}
+
+func TestParallelBuildConcurrentTraversal(t *testing.T) {
+	tr := NewTree(WithGridSize(20), WithMaxDepth(6), WithMinPointsPerChildren(10), WithLoadWorkersNumber(4), WithParallelBuild(true))
+	if err := tr.Load(&rangeLasReader{MockLasReader: randomCloud(20000)}, identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// traverse the tree reading the points while the prefetch is building it
+	var wg sync.WaitGroup
+	counts := make([]int, 4)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var visit func(n tree.Node)
+			visit = func(n tree.Node) {
+				counts[i] += len(orderedPoints(t, n))
+				for _, c := range n.Children() {
+					if c != nil {
+						visit(c)
+					}
+				}
+			}
+			visit(tr)
+		}(i)
+	}
+	wg.Wait()
+	for _, count := range counts {
+		if count != 20000 {
+			t.Errorf("expected %d points got %d", 20000, count)
+		}
+	}
+}
+
+func TestParallelBuildClose(t *testing.T) {
+	opts := []func(*Node){WithGridSize(20), WithMaxDepth(6), WithMinPointsPerChildren(10), WithLoadWorkersNumber(4)}
+	expected := NewTree(opts...)
+	if err := expected.Load(&rangeLasReader{MockLasReader: randomCloud(20000)}, identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := expected.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual := NewTree(append(opts, WithParallelBuild(true))...)
+	if err := actual.Load(&rangeLasReader{MockLasReader: randomCloud(20000)}, identityConverterFactory, nil, context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := actual.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// stop the prefetch before traversing the tree, as done when the export fails
+	done := make(chan error)
+	go func() { done <- actual.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the prefetch did not stop")
+	}
+	if err := actual.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// the nodes not built by the prefetch are built when queried
+	compareOrderedNodes(t, "r", expected, actual)
+}
+
+func TestSerialBuildIsDeterministic(t *testing.T) {
+	build := func() *Node {
+		tr := NewTree(WithGridSize(20), WithMaxDepth(4), WithMinPointsPerChildren(10))
+		if err := tr.Load(randomCloud(5000), identityConverterFactory, nil, context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := tr.Build(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return tr
+	}
+	compareOrderedNodes(t, "r", build(), build())
+}
+
+func BenchmarkGridTreeParallelBuild(b *testing.B) {
+	for _, parallel := range []bool{false, true} {
+		name := "serial"
+		if parallel {
+			name = "parallel"
+		}
+		b.Run(name, func(b *testing.B) {
+			reader := &rangeLasReader{MockLasReader: randomCloud(benchmarkPoints)}
+			for i := 0; i < b.N; i++ {
+				reader.Cur = 0
+				tr := NewTree(WithGridSize(10), WithMaxDepth(6), WithMinPointsPerChildren(1000), WithLoadWorkersNumber(8), WithParallelBuild(parallel))
+				if err := tr.Load(reader, identityConverterFactory, nil, context.TODO()); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+				if err := tr.Build(); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+				buildAll(tr)
+			}
+		})
+	}
+}
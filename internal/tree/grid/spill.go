@@ -0,0 +1,170 @@
+package grid
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// spillBufferSize is the size of the buffers used to read and write the spill files
+const spillBufferSize = 1 << 16
+
+// spillCodec encodes points as fixed length little endian records: the float32 coordinates, the colors,
+// intensity and classification bytes, and the float64 attribute values
+type spillCodec struct {
+	// attributes is the number of attribute values of each point
+	attributes int
+}
+
+func (c spillCodec) recordSize() int {
+	return 17 + 8*c.attributes
+}
+
+func (c spillCodec) encode(buf []byte, pt model.Point, attrs []float64) {
+	le := binary.LittleEndian
+	le.PutUint32(buf[0:], math.Float32bits(pt.X))
+	le.PutUint32(buf[4:], math.Float32bits(pt.Y))
+	le.PutUint32(buf[8:], math.Float32bits(pt.Z))
+	buf[12], buf[13], buf[14], buf[15], buf[16] = pt.R, pt.G, pt.B, pt.Intensity, pt.Classification
+	for i := 0; i < c.attributes; i++ {
+		le.PutUint64(buf[17+8*i:], math.Float64bits(attrs[i]))
+	}
+}
+
+// decode returns the point stored in the record, storing its attribute values in attrs
+func (c spillCodec) decode(buf []byte, attrs []float64) model.Point {
+	le := binary.LittleEndian
+	pt := geom.NewPoint(
+		math.Float32frombits(le.Uint32(buf[0:])),
+		math.Float32frombits(le.Uint32(buf[4:])),
+		math.Float32frombits(le.Uint32(buf[8:])),
+		buf[12], buf[13], buf[14], buf[15], buf[16],
+	)
+	for i := 0; i < c.attributes; i++ {
+		attrs[i] = math.Float64frombits(le.Uint64(buf[17+8*i:]))
+	}
+	return pt
+}
+
+// spillFile is a temporary file storing a number of point records
+type spillFile struct {
+	path  string
+	count int
+}
+
+// spillWriter appends point records to a new spill file
+type spillWriter struct {
+	codec spillCodec
+	f     *os.File
+	w     *bufio.Writer
+	buf   []byte
+	file  spillFile
+}
+
+func newSpillWriter(path string, codec spillCodec) (*spillWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the spill file: %w", err)
+	}
+	return &spillWriter{
+		codec: codec,
+		f:     f,
+		w:     bufio.NewWriterSize(f, spillBufferSize),
+		buf:   make([]byte, codec.recordSize()),
+		file:  spillFile{path: path},
+	}, nil
+}
+
+func (w *spillWriter) write(pt model.Point, attrs []float64) error {
+	w.codec.encode(w.buf, pt, attrs)
+	if _, err := w.w.Write(w.buf); err != nil {
+		return fmt.Errorf("unable to write to the spill file: %w", err)
+	}
+	w.file.count++
+	return nil
+}
+
+// close flushes the buffered records and returns the written file
+func (w *spillWriter) close() (spillFile, error) {
+	err := w.w.Flush()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return w.file, fmt.Errorf("unable to write to the spill file: %w", err)
+	}
+	return w.file, nil
+}
+
+// readSpill calls the given function for every point stored in the files, in order, together with its attribute
+// values. The attribute values are only valid until the function returns.
+func readSpill(files []spillFile, codec spillCodec, fn func(model.Point, []float64) error) error {
+	buf := make([]byte, codec.recordSize())
+	attrs := make([]float64, codec.attributes)
+	for _, file := range files {
+		f, err := os.Open(file.path)
+		if err != nil {
+			return fmt.Errorf("unable to open the spill file: %w", err)
+		}
+		r := bufio.NewReaderSize(f, spillBufferSize)
+		for i := 0; i < file.count; i++ {
+			if _, err = io.ReadFull(r, buf); err != nil {
+				break
+			}
+			if err = fn(codec.decode(buf, attrs), attrs); err != nil {
+				break
+			}
+		}
+		f.Close()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("spill file %s is truncated", file.path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSpill reads all the points stored in the files into a new store, linked in a single list. Returns the store,
+// the index of the first point of the list, noPoint if empty, and the number of points.
+func loadSpill(files []spillFile, codec spillCodec) (*pointStore, uint32, int, error) {
+	count := 0
+	for _, f := range files {
+		count += f.count
+	}
+	store, err := newPointStore(count, codec.attributes)
+	if err != nil {
+		return nil, noPoint, 0, err
+	}
+	i := uint32(0)
+	err = readSpill(files, codec, func(pt model.Point, attrs []float64) error {
+		store.set(i, pt, attrs)
+		if i > 0 {
+			store.next[i-1] = i
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		return nil, noPoint, 0, err
+	}
+	if count == 0 {
+		return store, noPoint, 0, nil
+	}
+	return store, 0, count, nil
+}
+
+// removeSpill deletes the given spill files
+func removeSpill(files []spillFile) {
+	for _, f := range files {
+		os.Remove(f.path)
+	}
+}
@@ -0,0 +1,139 @@
+package grid
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// noPoint is the index marking the end of a list of points in a pointStore
+const noPoint = math.MaxUint32
+
+// maxStorePoints is the maximum number of points a pointStore can hold
+const maxStorePoints = noPoint
+
+// pointStore stores the points of a tree in columnar arrays, free of pointers so that they are not scanned by the
+// garbage collector. Points are identified by their index in the arrays and are chained in singly linked lists
+// via the next array, which allows moving them across lists without copying their data. This takes 21 bytes per
+// point, plus 8 bytes per attribute value, while a slice of geom.LinkedPoint takes 32 bytes per point.
+type pointStore struct {
+	// attributes is the number of attribute values of each point
+	attributes int
+	// xyz stores the X, Y, Z coordinates of each point, in this order
+	xyz []float32
+	// rgb stores the R, G, B color components of each point, in this order
+	rgb            []uint8
+	intensity      []uint8
+	classification []uint8
+	// attrs stores the attribute values of each point, in groups of attributes values
+	attrs []float64
+	// next stores the index of the point following each point in its list, noPoint if last
+	next []uint32
+}
+
+// newPointStore returns a store for count points having the given number of attribute values each.
+// All points are initially unlinked.
+func newPointStore(count int, attributes int) (*pointStore, error) {
+	if int64(count) > maxStorePoints {
+		return nil, fmt.Errorf("too many points to load in memory: %d, the maximum is %d", count, maxStorePoints)
+	}
+	s := &pointStore{
+		attributes:     attributes,
+		xyz:            make([]float32, 3*count),
+		rgb:            make([]uint8, 3*count),
+		intensity:      make([]uint8, count),
+		classification: make([]uint8, count),
+		attrs:          make([]float64, attributes*count),
+		next:           make([]uint32, count),
+	}
+	for i := range s.next {
+		s.next[i] = noPoint
+	}
+	return s, nil
+}
+
+// set stores the point and its attribute values at the given index, without changing its links
+func (s *pointStore) set(i uint32, pt model.Point, attrs []float64) {
+	s.xyz[3*i], s.xyz[3*i+1], s.xyz[3*i+2] = pt.X, pt.Y, pt.Z
+	s.rgb[3*i], s.rgb[3*i+1], s.rgb[3*i+2] = pt.R, pt.G, pt.B
+	s.intensity[i] = pt.Intensity
+	s.classification[i] = pt.Classification
+	copy(s.attributeValues(i), attrs)
+}
+
+// point returns the point stored at the given index
+func (s *pointStore) point(i uint32) model.Point {
+	return model.Point{
+		X:              s.xyz[3*i],
+		Y:              s.xyz[3*i+1],
+		Z:              s.xyz[3*i+2],
+		R:              s.rgb[3*i],
+		G:              s.rgb[3*i+1],
+		B:              s.rgb[3*i+2],
+		Intensity:      s.intensity[i],
+		Classification: s.classification[i],
+	}
+}
+
+// attributeValues returns the attribute values of the point stored at the given index, nil if the points have
+// no attributes. The returned slice shares the memory of the store.
+func (s *pointStore) attributeValues(i uint32) []float64 {
+	if s.attributes == 0 {
+		return nil
+	}
+	return s.attrs[int(i)*s.attributes : (int(i)+1)*s.attributes : (int(i)+1)*s.attributes]
+}
+
+// position returns the coordinates of the point stored at the given index
+func (s *pointStore) position(i uint32) (float32, float32, float32) {
+	return s.xyz[3*i], s.xyz[3*i+1], s.xyz[3*i+2]
+}
+
+// pointStream implements the geom.PointList interface for a list of points in a pointStore
+type pointStream struct {
+	store   *pointStore
+	len     int
+	current uint32
+	start   uint32
+	// last is the index of the point last returned by Next, noPoint if none
+	last uint32
+}
+
+// newPointStream returns a stream over the list of points starting from the given index.
+// the length is not cross-verified, it must be coherent with the actual point count in the list.
+func newPointStream(store *pointStore, start uint32, len int) *pointStream {
+	return &pointStream{
+		store:   store,
+		len:     len,
+		current: start,
+		start:   start,
+		last:    noPoint,
+	}
+}
+
+func (p *pointStream) Next() (model.Point, error) {
+	if p.current == noPoint {
+		return model.Point{}, fmt.Errorf("no more points")
+	}
+	pt := p.store.point(p.current)
+	p.last = p.current
+	p.current = p.store.next[p.current]
+	return pt, nil
+}
+
+func (p *pointStream) Attributes() []float64 {
+	if p.last == noPoint {
+		return nil
+	}
+	return p.store.attributeValues(p.last)
+}
+
+func (p *pointStream) Len() int {
+	return p.len
+}
+
+func (p *pointStream) Reset() {
+	p.current = p.start
+	p.last = noPoint
+}
@@ -0,0 +1,198 @@
+package grid
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+func TestPointStore(t *testing.T) {
+	s, err := newPointStore(3, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pts := []model.Point{
+		geom.NewPoint(1, 2, 3, 4, 5, 6, 7, 8),
+		geom.NewPoint(-1, -2, -3, 255, 254, 253, 252, 251),
+		geom.NewPoint(0.5, 0.25, 0.125, 0, 0, 0, 0, 0),
+	}
+	for i, pt := range pts {
+		if s.next[i] != noPoint {
+			t.Errorf("expected point %d to be unlinked", i)
+		}
+		s.set(uint32(i), pt, nil)
+	}
+	for i, pt := range pts {
+		if actual := s.point(uint32(i)); actual != pt {
+			t.Errorf("expected point %v got %v", pt, actual)
+		}
+		if x, y, z := s.position(uint32(i)); x != pt.X || y != pt.Y || z != pt.Z {
+			t.Errorf("expected position %v %v %v got %v %v %v", pt.X, pt.Y, pt.Z, x, y, z)
+		}
+	}
+}
+
+func TestPointStoreAttributes(t *testing.T) {
+	s, err := newPointStore(2, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pt := geom.NewPoint(1, 2, 3, 4, 5, 6, 7, 8)
+	s.set(1, pt, []float64{1.5, -2})
+	if actual := s.point(1); actual != pt {
+		t.Errorf("expected point %v got %v", pt, actual)
+	}
+	if actual := s.attributeValues(1); !reflect.DeepEqual(actual, []float64{1.5, -2}) {
+		t.Errorf("expected attributes %v got %v", []float64{1.5, -2}, actual)
+	}
+	if actual := s.attributeValues(0); !reflect.DeepEqual(actual, []float64{0, 0}) {
+		t.Errorf("expected attributes %v got %v", []float64{0, 0}, actual)
+	}
+	// appending to the values must not overwrite the ones of the next point
+	_ = append(s.attributeValues(0), 10)
+	if actual := s.attributeValues(1); actual[0] != 1.5 {
+		t.Errorf("expected attributes %v got %v", []float64{1.5, -2}, actual)
+	}
+}
+
+func TestPointStoreTooManyPoints(t *testing.T) {
+	n := int64(maxStorePoints) + 1
+	if int64(int(n)) != n {
+		t.Skip("int is too small to hold the number of points")
+	}
+	if _, err := newPointStore(int(n), 0); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
+
+func TestPointStream(t *testing.T) {
+	s, _ := newPointStore(4, 0)
+	for i := 0; i < 4; i++ {
+		s.set(uint32(i), geom.NewPoint(float32(i), 0, 0, 0, 0, 0, 0, 0), nil)
+	}
+	// list 3 -> 1 -> 2, point 0 is not linked
+	s.next[3], s.next[1] = 1, 2
+	stream := newPointStream(s, 3, 3)
+	if stream.Len() != 3 {
+		t.Errorf("expected len %d got %d", 3, stream.Len())
+	}
+	for j := 0; j < 2; j++ {
+		for _, expected := range []float32{3, 1, 2} {
+			pt, err := stream.Next()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if pt.X != expected {
+				t.Errorf("expected point %v got %v", expected, pt.X)
+			}
+		}
+		if _, err := stream.Next(); err == nil {
+			t.Errorf("expected error got none")
+		}
+		stream.Reset()
+	}
+
+	empty := newPointStream(s, noPoint, 0)
+	if _, err := empty.Next(); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
+
+const benchmarkPoints = 1000000
+
+func benchmarkPoint(rnd *rand.Rand) model.Point {
+	return geom.NewPoint(rnd.Float32()*100, rnd.Float32()*100, rnd.Float32()*100, 1, 2, 3, 4, 5)
+}
+
+// reportStorage reports the memory allocated per point and the duration of a garbage collection while the points
+// are still reachable
+func reportStorage(b *testing.B, before runtime.MemStats, keepAlive any) {
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.TotalAlloc-before.TotalAlloc)/float64(b.N)/benchmarkPoints, "B/point")
+	start := time.Now()
+	runtime.GC()
+	b.ReportMetric(float64(time.Since(start).Microseconds()), "gc-µs")
+	runtime.KeepAlive(keepAlive)
+}
+
+// BenchmarkPointStorage compares storing, linking and reading back the points using a slice of geom.LinkedPoint and
+// using the columnar pointStore of the grid tree
+func BenchmarkPointStorage(b *testing.B) {
+	b.Run("linked", func(b *testing.B) {
+		rnd := rand.New(rand.NewSource(1))
+		var before runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		var pts []geom.LinkedPoint
+		for i := 0; i < b.N; i++ {
+			pts = make([]geom.LinkedPoint, benchmarkPoints)
+			for j := range pts {
+				pts[j].Pt = benchmarkPoint(rnd)
+				if j > 0 {
+					pts[j-1].Next = &pts[j]
+				}
+			}
+			stream := geom.NewLinkedPointStream(&pts[0], len(pts))
+			for j := 0; j < stream.Len(); j++ {
+				stream.Next()
+			}
+		}
+		b.StopTimer()
+		reportStorage(b, before, pts)
+	})
+	b.Run("columnar", func(b *testing.B) {
+		rnd := rand.New(rand.NewSource(1))
+		var before runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		var s *pointStore
+		for i := 0; i < b.N; i++ {
+			s, _ = newPointStore(benchmarkPoints, 0)
+			for j := uint32(0); j < benchmarkPoints; j++ {
+				s.set(j, benchmarkPoint(rnd), nil)
+				if j > 0 {
+					s.next[j-1] = j
+				}
+			}
+			stream := newPointStream(s, 0, benchmarkPoints)
+			for j := 0; j < stream.Len(); j++ {
+				stream.Next()
+			}
+		}
+		b.StopTimer()
+		reportStorage(b, before, s)
+	})
+}
+
+// BenchmarkGridTreeBuild measures loading and fully building a grid tree
+func BenchmarkGridTreeBuild(b *testing.B) {
+	reader := randomCloud(benchmarkPoints)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		reader.Cur = 0
+		tr := NewTree(WithGridSize(10), WithMaxDepth(6), WithMinPointsPerChildren(1000))
+		if err := tr.Load(reader, identityConverterFactory, nil, context.TODO()); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+		if err := tr.Build(); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+		buildAll(tr)
+	}
+}
+
+// buildAll builds all the descendants of the node
+func buildAll(n *Node) {
+	for _, c := range n.Children() {
+		if c != nil {
+			buildAll(c.(*Node))
+		}
+	}
+}
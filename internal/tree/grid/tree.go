@@ -25,14 +25,18 @@ import (
 //     unless the maximum depth of the tree is reached, in which case all points are retained.
 //   - store all other points no retained to be used to build the children
 //
-// The tree is "lazy". It never builds the children until they are queried.
+// The tree is "lazy". It never builds the children until they are queried, unless the parallel build is enabled, in
+// which case the nodes are built concurrently in background ahead of being queried.
 type Node struct {
-	// pts is a linked list of points in local coordinates belonging to this Node
-	pts *geom.LinkedPoint
+	// store holds the points of the whole tree, in local coordinates
+	store *pointStore
 
-	// childrenPts that temporarily stores the points that should
-	// fall into the 8 children octants before these are built
-	childrenPts [8]*geom.LinkedPoint
+	// pts is the index in the store of the first point of the linked list of points belonging to this Node
+	pts uint32
+
+	// childrenPts that temporarily stores the index of the first point of the lists of points
+	// that should fall into the 8 children octants before these are built
+	childrenPts [8]uint32
 
 	// children contains pointers to the child nodes of the tree
 	children [8]tree.Node
@@ -68,6 +72,15 @@ type Node struct {
 	// points in the node
 	loadWorkersNumber int
 
+	// parallelBuild is true if the nodes of the tree should be built concurrently
+	parallelBuild bool
+
+	// builder builds the nodes of the tree concurrently, nil if the tree is built serially
+	builder *parallelBuilder
+
+	// stopPrefetch stops the background build of the tree, nil if not started
+	stopPrefetch func()
+
 	// minPointsPerChildren is the minimum numbr of points a children can contain,
 	// if less its points will be rolled up to the parent
 	minPointsPerChildren int
@@ -83,6 +96,8 @@ type Node struct {
 // NewTree returns a new tree with default settings
 func NewTree(opts ...func(*Node)) *Node {
 	t := &Node{
+		pts:                  noPoint,
+		childrenPts:          emptyChildrenPts(),
 		built:                false,
 		maxDepth:             10,
 		depth:                0,
@@ -120,6 +135,15 @@ func WithLoadWorkersNumber(num int) func(t *Node) {
 	}
 }
 
+// WithParallelBuild builds the tree nodes concurrently, using as many goroutines as the load workers. Once the root
+// is built the children are built in background, level by level, while the tree is being traversed. The resulting
+// tree is identical to the one built serially.
+func WithParallelBuild(parallel bool) func(t *Node) {
+	return func(t *Node) {
+		t.parallelBuild = parallel
+	}
+}
+
 // WithMinPointsPerChildren sets the minimum number of points a children node should contain,
 // if that is not possible the children points will be rolled up to its parent
 func WithMinPointsPerChildren(num int) func(t *Node) {
@@ -141,113 +165,106 @@ func (t *Node) RootNode() tree.Node {
 }
 
 func (t *Node) Build() error {
+	if err := t.build(); err != nil {
+		return err
+	}
+	if t.parallelBuild && t.depth == 0 {
+		t.builder = newParallelBuilder(t.loadWorkersNumber)
+		t.stopPrefetch = t.builder.startPrefetch(t)
+	}
+	return nil
+}
+
+// Close stops the background build of the nodes started by the parallel build, if any, and waits for it to exit.
+// Must be called once the tree is no longer needed, e.g. if the export fails or is cancelled before traversing the
+// whole tree. The nodes not built yet are still built when first queried.
+func (t *Node) Close() error {
+	if t.stopPrefetch != nil {
+		t.stopPrefetch()
+	}
+	return nil
+}
+
+// build samples the points of the node, parking the ones not retained in the lists of the children octants
+func (t *Node) build() error {
+	s := t.store
 	if t.depth >= t.maxDepth {
 		// reached maxDepth, swallow in all points
 		current := t.pts
 		// traverse just to update the internal counters
-		for current != nil {
+		for current != noPoint {
 			t.totalNumPoints++
 			t.numPoints++
-			current = current.Next
+			current = s.next[current]
 		}
 		// max depth, no further subdivision possible, mark as built and return
 		t.built = true
 		return nil
 	}
 
-	// nX, nY, nZ represent the number of grid cells in each direction, should always be >= 1
-	nX := math.Ceil((t.bounds.Xmax - t.bounds.Xmin) / t.gridSize)
-	nY := math.Ceil((t.bounds.Ymax - t.bounds.Ymin) / t.gridSize)
-	nZ := math.Ceil((t.bounds.Zmax - t.bounds.Zmin) / t.gridSize)
-
-	// these are the actual gridSizes after the rounding
-	gridSizeX := (t.bounds.Xmax - t.bounds.Xmin) / nX
-	gridSizeY := (t.bounds.Ymax - t.bounds.Ymin) / nY
-	gridSizeZ := (t.bounds.Zmax - t.bounds.Zmin) / nZ
+	sampling := newSamplingGrid(t.bounds, t.gridSize)
 
 	// we need to keep track of the closest point to each grid cell center
 	// define an inner type so that it's not leaked outside the scope of the build method
 	type cell struct {
-		pt   *geom.LinkedPoint
+		pt   uint32
 		dist float64
 	}
+	// the winners are stored in a slice, in order of discovery, so that the order of the points of the node
+	// only depends on the order of the input points
+	winners := []cell{}
 
 	childrenCount := [8]int{}
 
 	// start from the first point
 	cur := t.pts
 
-	// the winners (i.e. closest points to each cell center are stored in a map)
-	// the key to the map is a [3]float array of the grid cell center.
-	grid := map[[3]int32]cell{}
+	// the index of the winners (i.e. closest points to each cell center) are stored in a map
+	// the key to the map is a [3]int32 array of the grid cell indexes.
+	grid := map[[3]int32]int{}
 
-	for cur != nil {
+	for cur != noPoint {
 		// keep track of the number of points seen overall
 		t.totalNumPoints++
 		// store the next point for the next iteration in the loop,
-		// then detach the current point from the linked list by wiping the 'next' pointer
-		next := cur.Next
-		cur.Next = nil
-
-		// compute 3D integer coordinates of the cell the point falls into
-		iX := int32(math.Min(math.Max(1, math.Ceil((float64(cur.Pt.X)-t.bounds.Xmin)/gridSizeX)), float64(nX)))
-		iY := int32(math.Min(math.Max(1, math.Ceil((float64(cur.Pt.Y)-t.bounds.Ymin)/gridSizeY)), float64(nY)))
-		iZ := int32(math.Min(math.Max(1, math.Ceil((float64(cur.Pt.Z)-t.bounds.Zmin)/gridSizeZ)), float64(nZ)))
-		// this is the unique id of the cell the point belongs to
-		cellIndex := [3]int32{iX, iY, iZ}
-
-		// compute the cell center coordinates
-		cX := t.bounds.Xmin + float64(iX-1)*gridSizeX + gridSizeX/2
-		cY := t.bounds.Ymin + float64(iY-1)*gridSizeY + gridSizeY/2
-		cZ := t.bounds.Zmin + float64(iZ-1)*gridSizeZ + gridSizeZ/2
+		// then detach the current point from the linked list by wiping the 'next' index
+		next := s.next[cur]
+		s.next[cur] = noPoint
 
-		// get the (squared, to save some CPU) distance of the point to the cell center
-		curDist := (cX-float64(cur.Pt.X))*(cX-float64(cur.Pt.X)) + (cY-float64(cur.Pt.Y))*(cY-float64(cur.Pt.Y)) + (cZ-float64(cur.Pt.Z))*(cZ-float64(cur.Pt.Z))
+		// find the cell the point belongs to and its distance to the cell center
+		x, y, z := s.position(cur)
+		cellIndex, curDist := sampling.cell(x, y, z)
 
 		// find if we already have a "winner" (closest point) for the identified grid cell
-		oldWinner, ok := grid[cellIndex]
+		w, ok := grid[cellIndex]
 		if !ok {
 			// no winner? then the current point is the new cell winner
-			grid[cellIndex] = cell{pt: cur, dist: curDist}
+			grid[cellIndex] = len(winners)
+			winners = append(winners, cell{pt: cur, dist: curDist})
 		} else {
 			// we have a winner, check if it loses against the current point
-			if curDist < oldWinner.dist {
+			loser := cur
+			if curDist < winners[w].dist {
 				// current point wins, old winner needs to go
-				grid[cellIndex] = cell{pt: cur, dist: curDist}
-				// oldWinner needs to be moved to the linked list of the child octant it belongs to
-				idx := t.getChildrenIndex(oldWinner.pt.Pt)
-				childrenCount[idx]++
-
-				if t.childrenPts[idx] == nil {
-					t.childrenPts[idx] = oldWinner.pt
-				} else {
-					oldWinner.pt.Next = t.childrenPts[idx]
-					t.childrenPts[idx] = oldWinner.pt
-				}
-			} else {
-				// oldWinner wins against current point, so just push current point to the
-				// relevant octant list
-				idx := t.getChildrenIndex(cur.Pt)
-				childrenCount[idx]++
-				if t.childrenPts[idx] == nil {
-					t.childrenPts[idx] = cur
-				} else {
-					cur.Next = t.childrenPts[idx]
-					t.childrenPts[idx] = cur
-				}
+				loser = winners[w].pt
+				winners[w] = cell{pt: cur, dist: curDist}
 			}
+			// the loser needs to be moved to the linked list of the child octant it belongs to
+			idx := t.getChildrenIndex(loser)
+			childrenCount[idx]++
+			s.next[loser] = t.childrenPts[idx]
+			t.childrenPts[idx] = loser
 		}
 		// update cur with the next one
 		cur = next
 	}
 
-	// now we need to extract all points in the map as they are
+	// now we need to extract all the winners as they are
 	// the ones left belonging to this node
-	t.pts = nil
-	for _, pt := range grid {
-		point := pt.pt
-		point.Next = t.pts
-		t.pts = point
+	t.pts = noPoint
+	for _, pt := range winners {
+		s.next[pt.pt] = t.pts
+		t.pts = pt.pt
 		t.numPoints++
 	}
 
@@ -256,14 +273,14 @@ func (t *Node) Build() error {
 	for i, count := range childrenCount {
 		if count < t.minPointsPerChildren {
 			current := t.childrenPts[i]
-			for current != nil {
-				next := current.Next
-				current.Next = t.pts
+			for current != noPoint {
+				next := s.next[current]
+				s.next[current] = t.pts
 				t.pts = current
 				current = next
 				t.numPoints++
 			}
-			t.childrenPts[i] = nil
+			t.childrenPts[i] = noPoint
 		}
 	}
 	t.built = true
@@ -293,31 +310,41 @@ func (t *Node) Children() [8]tree.Node {
 		// not built? return nothing
 		return t.children
 	}
+	nodes := []*Node{}
 	for i, c := range t.childrenPts {
-		if c == nil {
+		if c == noPoint {
 			continue
 		}
 		v := &Node{
+			store:                t.store,
 			pts:                  c,
-			childrenPts:          [8]*geom.LinkedPoint{},
+			childrenPts:          emptyChildrenPts(),
 			bounds:               geom.NewBoundingBoxFromParent(t.bounds, i),
 			depth:                t.depth + 1,
 			maxDepth:             t.maxDepth,
 			gridSize:             t.gridSize / 2,
 			childrenBuilt:        false,
 			minPointsPerChildren: t.minPointsPerChildren,
+			builder:              t.builder,
 			localToGlobal:        nil,
 		}
-		// Children MUST be built before returned
-		v.Build()
+		nodes = append(nodes, v)
 		t.children[i] = tree.Node(v)
 	}
+	// Children MUST be built before returned
+	if t.builder != nil {
+		t.builder.build(nodes)
+	} else {
+		for _, v := range nodes {
+			v.build()
+		}
+	}
 	t.childrenBuilt = true
 	return t.children
 }
 
 func (t *Node) Points() geom.PointList {
-	return geom.NewLinkedPointStream(t.pts, t.numPoints)
+	return newPointStream(t.store, t.pts, t.numPoints)
 }
 
 func (t *Node) TotalNumberOfPoints() int {
@@ -341,25 +368,76 @@ func (t *Node) GeometricError() float64 {
 	return math.Sqrt(t.gridSize * t.gridSize * 3)
 }
 
-func (t *Node) getChildrenIndex(p model.Point) int {
-	if float64(p.X) < t.bounds.Xmid && float64(p.Y) < t.bounds.Ymid && float64(p.Z) < t.bounds.Zmid {
+func (t *Node) getChildrenIndex(i uint32) int {
+	x, y, z := t.store.position(i)
+	return childIndex(t.bounds, x, y, z)
+}
+
+// emptyChildrenPts returns children lists with no points
+func emptyChildrenPts() [8]uint32 {
+	return [8]uint32{noPoint, noPoint, noPoint, noPoint, noPoint, noPoint, noPoint, noPoint}
+}
+
+// childIndex returns the index of the octant of the bounding box the point with the given coordinates falls into
+func childIndex(b geom.BoundingBox, x, y, z float32) int {
+	if float64(x) < b.Xmid && float64(y) < b.Ymid && float64(z) < b.Zmid {
 		return 0
-	} else if float64(p.X) >= t.bounds.Xmid && float64(p.Y) < t.bounds.Ymid && float64(p.Z) < t.bounds.Zmid {
+	} else if float64(x) >= b.Xmid && float64(y) < b.Ymid && float64(z) < b.Zmid {
 		return 1
-	} else if float64(p.X) < t.bounds.Xmid && float64(p.Y) >= t.bounds.Ymid && float64(p.Z) < t.bounds.Zmid {
+	} else if float64(x) < b.Xmid && float64(y) >= b.Ymid && float64(z) < b.Zmid {
 		return 2
-	} else if float64(p.X) >= t.bounds.Xmid && float64(p.Y) >= t.bounds.Ymid && float64(p.Z) < t.bounds.Zmid {
+	} else if float64(x) >= b.Xmid && float64(y) >= b.Ymid && float64(z) < b.Zmid {
 		return 3
-	} else if float64(p.X) < t.bounds.Xmid && float64(p.Y) < t.bounds.Ymid && float64(p.Z) >= t.bounds.Zmid {
+	} else if float64(x) < b.Xmid && float64(y) < b.Ymid && float64(z) >= b.Zmid {
 		return 4
-	} else if float64(p.X) >= t.bounds.Xmid && float64(p.Y) < t.bounds.Ymid && float64(p.Z) >= t.bounds.Zmid {
+	} else if float64(x) >= b.Xmid && float64(y) < b.Ymid && float64(z) >= b.Zmid {
 		return 5
-	} else if float64(p.X) < t.bounds.Xmid && float64(p.Y) >= t.bounds.Ymid && float64(p.Z) >= t.bounds.Zmid {
+	} else if float64(x) < b.Xmid && float64(y) >= b.Ymid && float64(z) >= b.Zmid {
 		return 6
 	}
 	return 7
 }
 
+// samplingGrid partitions a bounding box in cells of approximately the given size, used to sample the points
+type samplingGrid struct {
+	bounds geom.BoundingBox
+	// nX, nY, nZ represent the number of grid cells in each direction, should always be >= 1
+	nX, nY, nZ float64
+	// the actual grid sizes after the rounding
+	sizeX, sizeY, sizeZ float64
+}
+
+func newSamplingGrid(bounds geom.BoundingBox, gridSize float64) samplingGrid {
+	g := samplingGrid{
+		bounds: bounds,
+		nX:     math.Ceil((bounds.Xmax - bounds.Xmin) / gridSize),
+		nY:     math.Ceil((bounds.Ymax - bounds.Ymin) / gridSize),
+		nZ:     math.Ceil((bounds.Zmax - bounds.Zmin) / gridSize),
+	}
+	g.sizeX = (bounds.Xmax - bounds.Xmin) / g.nX
+	g.sizeY = (bounds.Ymax - bounds.Ymin) / g.nY
+	g.sizeZ = (bounds.Zmax - bounds.Zmin) / g.nZ
+	return g
+}
+
+// cell returns the unique id of the grid cell the point with the given coordinates falls into and the squared
+// distance of the point from the cell center
+func (g samplingGrid) cell(x, y, z float32) ([3]int32, float64) {
+	// compute 3D integer coordinates of the cell the point falls into
+	iX := int32(math.Min(math.Max(1, math.Ceil((float64(x)-g.bounds.Xmin)/g.sizeX)), g.nX))
+	iY := int32(math.Min(math.Max(1, math.Ceil((float64(y)-g.bounds.Ymin)/g.sizeY)), g.nY))
+	iZ := int32(math.Min(math.Max(1, math.Ceil((float64(z)-g.bounds.Zmin)/g.sizeZ)), g.nZ))
+
+	// compute the cell center coordinates
+	cX := g.bounds.Xmin + float64(iX-1)*g.sizeX + g.sizeX/2
+	cY := g.bounds.Ymin + float64(iY-1)*g.sizeY + g.sizeY/2
+	cZ := g.bounds.Zmin + float64(iZ-1)*g.sizeZ + g.sizeZ/2
+
+	// get the (squared, to save some CPU) distance of the point to the cell center
+	dist := (cX-float64(x))*(cX-float64(x)) + (cY-float64(y))*(cY-float64(y)) + (cZ-float64(z))*(cZ-float64(z))
+	return [3]int32{iX, iY, iZ}, dist
+}
+
 func (t *Node) loadPoints(reader las.LasReader, convFactory coor.ConverterFactory, mut mutator.Mutator, ctx context.Context) error {
 	l := loader{
 		createCoorConverter: convFactory,
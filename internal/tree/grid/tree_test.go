@@ -114,10 +114,10 @@ func TestGridTreeLoad(t *testing.T) {
 	// verify the points are stored
 	cur := tree.pts
 	for i := 0; i < len(reader.Pts); i++ {
-		if cur.Pt != expected[i] {
-			t.Errorf("expected pt %v got %v", expected[i], cur.Pt)
+		if pt := tree.store.point(cur); pt != expected[i] {
+			t.Errorf("expected pt %v got %v", expected[i], pt)
 		}
-		cur = cur.Next
+		cur = tree.store.next[cur]
 	}
 
 	// build
@@ -193,10 +193,10 @@ func TestGridTreeBuild(t *testing.T) {
 	// verify the points are stored
 	cur := tree.pts
 	for i := 0; i < len(reader.Pts); i++ {
-		if cur.Pt != expected[i] {
-			t.Errorf("expected pt %v got %v", expected[i], cur.Pt)
+		if pt := tree.store.point(cur); pt != expected[i] {
+			t.Errorf("expected pt %v got %v", expected[i], pt)
 		}
-		cur = cur.Next
+		cur = tree.store.next[cur]
 	}
 
 	// build
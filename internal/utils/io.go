@@ -1,8 +1,13 @@
 package utils
 
 import (
+	"encoding/csv"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -24,7 +29,18 @@ func TouchFile(path string) error {
 	return f.Close()
 }
 
+// FindLasFilesInFolder returns the LAS, LAZ, PLY and E57 files found in the given directory
 func FindLasFilesInFolder(directory string) ([]string, error) {
+	return findFilesInFolder(directory, "las", "laz", "ply", "e57")
+}
+
+// FindTextFilesInFolder returns the XYZ, CSV and TXT point cloud files found in the given directory
+func FindTextFilesInFolder(directory string) ([]string, error) {
+	return findFilesInFolder(directory, "xyz", "csv", "txt")
+}
+
+// findFilesInFolder returns the files in the given directory having one of the given extensions, case insensitive
+func findFilesInFolder(directory string, extensions ...string) ([]string, error) {
 	if _, err := os.Stat(directory); err != nil {
 		return nil, err
 	}
@@ -40,8 +56,8 @@ func FindLasFilesInFolder(directory string) ([]string, error) {
 		lastIndex := -1
 		name := e.Name()
 		if lastIndex = strings.LastIndex(name, "."); lastIndex != -1 {
-			ext := e.Name()[lastIndex+1:]
-			if strings.ToLower(ext) != "las" {
+			ext := strings.ToLower(e.Name()[lastIndex+1:])
+			if !slices.Contains(extensions, ext) {
 				continue
 			}
 		}
@@ -50,3 +66,45 @@ func FindLasFilesInFolder(directory string) ([]string, error) {
 	}
 	return files, nil
 }
+
+// ReadCRSManifest reads a CSV file listing on each row the path of an input file and its CRS, and returns the map
+// from the paths to the CRSs. Relative paths are resolved against the folder of the manifest and bare numbers are
+// interpreted as EPSG codes. CRSs containing commas, like WKT strings, must be double quoted. An optional header
+// row starting with "file" and lines starting with # are skipped.
+func ReadCRSManifest(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	dir := filepath.Dir(path)
+	crss := map[string]string{}
+	for row := 0; ; row++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRS manifest %s: %w", path, err)
+		}
+		file, crs := strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		if row == 0 && strings.EqualFold(file, "file") {
+			continue
+		}
+		if file == "" || crs == "" {
+			return nil, fmt.Errorf("invalid CRS manifest %s: empty file or CRS in row %d", path, row+1)
+		}
+		if code, err := strconv.Atoi(crs); err == nil {
+			crs = fmt.Sprintf("EPSG:%d", code)
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		crss[file] = crs
+	}
+	return crss, nil
+}
@@ -20,6 +20,10 @@ func TestFindLasFilesInFolder(t *testing.T) {
 	TouchFile(filepath.Join(tmp, "test0.xyz"))
 	TouchFile(filepath.Join(tmp, "test1.LAS"))
 	TouchFile(filepath.Join(tmp, "test2.LAS"))
+	TouchFile(filepath.Join(tmp, "test3.laz"))
+	TouchFile(filepath.Join(tmp, "test4.LAZ"))
+	TouchFile(filepath.Join(tmp, "test5.ply"))
+	TouchFile(filepath.Join(tmp, "test6.E57"))
 
 	files, err := FindLasFilesInFolder(tmp)
 	if err != nil {
@@ -29,8 +33,74 @@ func TestFindLasFilesInFolder(t *testing.T) {
 		filepath.Join(tmp, "test0.las"),
 		filepath.Join(tmp, "test1.LAS"),
 		filepath.Join(tmp, "test2.LAS"),
+		filepath.Join(tmp, "test3.laz"),
+		filepath.Join(tmp, "test4.LAZ"),
+		filepath.Join(tmp, "test5.ply"),
+		filepath.Join(tmp, "test6.E57"),
 	}
 	if !reflect.DeepEqual(expected, files) {
 		t.Errorf("expected %v got %v", expected, files)
 	}
 }
+
+func TestFindTextFilesInFolder(t *testing.T) {
+	tmp, err := os.MkdirTemp(os.TempDir(), "tst")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(tmp)
+	})
+
+	TouchFile(filepath.Join(tmp, "test0.las"))
+	TouchFile(filepath.Join(tmp, "test1.xyz"))
+	TouchFile(filepath.Join(tmp, "test2.CSV"))
+	TouchFile(filepath.Join(tmp, "test3.txt"))
+
+	files, err := FindTextFilesInFolder(tmp)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := []string{
+		filepath.Join(tmp, "test1.xyz"),
+		filepath.Join(tmp, "test2.CSV"),
+		filepath.Join(tmp, "test3.txt"),
+	}
+	if !reflect.DeepEqual(expected, files) {
+		t.Errorf("expected %v got %v", expected, files)
+	}
+}
+
+func TestReadCRSManifest(t *testing.T) {
+	tmp := t.TempDir()
+	manifest := filepath.Join(tmp, "crs.csv")
+	abs := filepath.Join(tmp, "other", "c.las")
+	content := "file,crs\n# zone 32\na.las, 32632\nsub/b.laz,EPSG:32633\n\"" + abs + "\",\"PROJCS[\"\"WGS 84 / UTM zone 34N\"\",AUTHORITY[\"\"EPSG\"\",\"\"32634\"\"]]\"\n"
+	if err := os.WriteFile(manifest, []byte(content), 0o644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	crss, err := ReadCRSManifest(manifest)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := map[string]string{
+		filepath.Join(tmp, "a.las"):        "EPSG:32632",
+		filepath.Join(tmp, "sub", "b.laz"): "EPSG:32633",
+		abs:                                `PROJCS["WGS 84 / UTM zone 34N",AUTHORITY["EPSG","32634"]]`,
+	}
+	if !reflect.DeepEqual(expected, crss) {
+		t.Errorf("expected %v got %v", expected, crss)
+	}
+
+	for _, invalid := range []string{"a.las\n", "a.las,EPSG:32632,x\n", "a.las,\n"} {
+		if err := os.WriteFile(manifest, []byte(invalid), 0o644); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if _, err := ReadCRSManifest(manifest); err == nil {
+			t.Errorf("expected error for %q got none", invalid)
+		}
+	}
+	if _, err := ReadCRSManifest(filepath.Join(tmp, "missing.csv")); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
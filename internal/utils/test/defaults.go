@@ -2,12 +2,20 @@ package test
 
 import (
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/conv/coor"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/conv/coor/native"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/conv/coor/proj"
 )
 
-// GetTestCoordinateConverter returns the function to use to convert coordinates in tests
+// GetTestCoordinateConverter returns the function to use to convert coordinates in tests. As in the tiler, the
+// CRSs supported by the built-in converter do not require PROJ, so that the tests also run in builds without it.
 func GetTestCoordinateConverterFactory() coor.ConverterFactory {
 	return func() (coor.Converter, error) {
-		return proj.NewProjCoordinateConverter()
+		return coor.NewFallbackConverter(native.NewNativeCoordinateConverter(), native.Supports, func() (coor.Converter, error) {
+			c, err := proj.NewProjCoordinateConverter()
+			if err != nil {
+				return nil, err
+			}
+			return c, nil
+		}), nil
 	}
 }
@@ -0,0 +1,179 @@
+package writer
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
+)
+
+const (
+	// ArchiveExtension is the extension of the 3D Tiles archive files
+	ArchiveExtension = ".3tz"
+	// archiveIndexName is the name of the index entry, which must be the last one of the archive
+	archiveIndexName = "@3dtilesIndex1@"
+)
+
+// archiveIndexEntry locates an archive entry by the MD5 hash of its path
+type archiveIndexEntry struct {
+	hash   [md5.Size]byte
+	offset uint64
+}
+
+// ArchiveSink writes the files of a tileset as the entries of a single 3D Tiles archive (.3tz), that is a zip
+// file whose last entry is an index of the entries sorted by the MD5 hash of their paths. Entries are stored
+// uncompressed, as they are written, hence consumers only hold in memory the files they are writing.
+type ArchiveSink struct {
+	// root is the tileset folder, the entries are named after the file paths relative to it
+	root    string
+	file    io.WriteCloser
+	counter *countingWriter
+	zw      *zip.Writer
+	index   []archiveIndexEntry
+	closed  bool
+	mu      sync.Mutex
+}
+
+// NewArchiveSink creates the archive file in the storage, storing the files created under the root folder as
+// entries named after their path relative to it
+func NewArchiveSink(s storage.Storage, root string, archivePath string) (Sink, error) {
+	f, err := s.Create(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	counter := &countingWriter{w: f}
+	if root = path.Clean(root); root == "." {
+		root = ""
+	}
+	return &ArchiveSink{
+		root:    root,
+		file:    f,
+		counter: counter,
+		zw:      zip.NewWriter(counter),
+	}, nil
+}
+
+// Create returns a writer buffering the file content, that is added to the archive when the writer is closed
+func (s *ArchiveSink) Create(p string) (io.WriteCloser, error) {
+	name := strings.TrimPrefix(path.Clean(p), s.root+"/")
+	if s.root == "" {
+		name = path.Clean(p)
+	} else if name == path.Clean(p) {
+		return nil, fmt.Errorf("file %s is outside of the archive root %s", p, s.root)
+	}
+	return &archiveEntryWriter{sink: s, name: name}, nil
+}
+
+// addEntry writes the entry to the archive and records its position in the index
+func (s *ArchiveSink) addEntry(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("archive already closed")
+	}
+	offset, err := s.writeEntry(name, data)
+	if err != nil {
+		return err
+	}
+	s.index = append(s.index, archiveIndexEntry{hash: md5.Sum([]byte(name)), offset: offset})
+	return nil
+}
+
+// writeEntry writes the uncompressed entry, returning the offset of its local file header
+func (s *ArchiveSink) writeEntry(name string, data []byte) (uint64, error) {
+	// raw entries do not need a data descriptor, hence once flushed the archive size is the next header offset
+	if err := s.zw.Flush(); err != nil {
+		return 0, err
+	}
+	offset := s.counter.n
+	w, err := s.zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(data),
+		CompressedSize64:   uint64(len(data)),
+		UncompressedSize64: uint64(len(data)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	_, err = w.Write(data)
+	return offset, err
+}
+
+// Close appends the index entry and finalizes the archive
+func (s *ArchiveSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if _, err := s.writeEntry(archiveIndexName, s.generateIndex()); err != nil {
+		s.file.Close()
+		return err
+	}
+	if err := s.zw.Close(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// generateIndex returns the index entries sorted by hash, compared as two little endian uint64 starting from
+// the first 8 bytes. Each entry is made of the hash followed by the offset of the entry.
+func (s *ArchiveSink) generateIndex() []byte {
+	slices.SortFunc(s.index, func(a, b archiveIndexEntry) int {
+		return compareArchiveHashes(a.hash, b.hash)
+	})
+	out := make([]byte, 0, len(s.index)*(md5.Size+8))
+	for _, e := range s.index {
+		out = append(out, e.hash[:]...)
+		out = binary.LittleEndian.AppendUint64(out, e.offset)
+	}
+	return out
+}
+
+func compareArchiveHashes(a, b [md5.Size]byte) int {
+	for _, i := range []int{0, 8} {
+		x, y := binary.LittleEndian.Uint64(a[i:]), binary.LittleEndian.Uint64(b[i:])
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+// archiveEntryWriter buffers the content of an archive entry until closed
+type archiveEntryWriter struct {
+	bytes.Buffer
+	sink *ArchiveSink
+	name string
+}
+
+func (w *archiveEntryWriter) Close() error {
+	return w.sink.addEntry(w.name, w.Bytes())
+}
+
+// countingWriter counts the bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += uint64(n)
+	return n, err
+}
@@ -0,0 +1,166 @@
+package writer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
+	"github.com/mfbonfigli/gocesiumtiler/v2/version"
+)
+
+// readArchive returns the content of the archive entries by name, checking that the index is the last
+// entry, that it is sorted and that it points to the local header of each entry
+func readArchive(t *testing.T, raw []byte) map[string][]byte {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		t.Fatalf("unable to open the archive: %v", err)
+	}
+	entries := map[string][]byte{}
+	for _, f := range r.File {
+		if f.Method != zip.Store {
+			t.Errorf("expected entry %s to be stored uncompressed", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("unable to open entry %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("unable to read entry %s: %v", f.Name, err)
+		}
+		entries[f.Name] = data
+	}
+	if last := r.File[len(r.File)-1].Name; last != archiveIndexName {
+		t.Fatalf("expected the index to be the last entry, got %s", last)
+	}
+	index := entries[archiveIndexName]
+	delete(entries, archiveIndexName)
+	if len(index) != 24*len(entries) {
+		t.Fatalf("expected %d index entries got %d bytes", len(entries), len(index))
+	}
+	for i := 0; i < len(index); i += 24 {
+		var hash [md5.Size]byte
+		copy(hash[:], index[i:])
+		if i > 0 {
+			var prev [md5.Size]byte
+			copy(prev[:], index[i-24:])
+			if compareArchiveHashes(prev, hash) >= 0 {
+				t.Errorf("index entries are not sorted")
+			}
+		}
+		offset := binary.LittleEndian.Uint64(index[i+16:])
+		if binary.LittleEndian.Uint32(raw[offset:]) != 0x04034b50 {
+			t.Fatalf("index offset %d does not point to a local file header", offset)
+		}
+		nameLen := int(binary.LittleEndian.Uint16(raw[offset+26:]))
+		name := raw[offset+30 : offset+30+uint64(nameLen)]
+		if md5.Sum(name) != hash {
+			t.Errorf("index hash does not match the entry %s", name)
+		}
+	}
+	return entries
+}
+
+func TestArchiveSink(t *testing.T) {
+	root := "out/tileset"
+	archive := "out/tileset/tileset.3tz"
+	store := storage.NewMemoryStorage()
+	s, err := NewArchiveSink(store, root, archive)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := path.Join(root, fmt.Sprint(i%8), fmt.Sprint(i), "content.pnts")
+			if err := writeFile(s, name, []byte(fmt.Sprintf("content %d", i))); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := writeFile(s, path.Join(root, "tileset.json"), []byte("{}")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := s.Create("out/other/tileset.json"); err == nil {
+		t.Errorf("expected error for a file outside of the archive root")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := writeFile(s, path.Join(root, "late.json"), []byte("{}")); err == nil {
+		t.Errorf("expected error writing to a closed archive")
+	}
+
+	raw, ok := store.Get(archive)
+	if !ok {
+		t.Fatalf("expected the archive to be stored")
+	}
+	entries := readArchive(t, raw)
+	if len(entries) != 51 {
+		t.Errorf("expected 51 entries got %d", len(entries))
+	}
+	if string(entries["tileset.json"]) != "{}" {
+		t.Errorf("unexpected tileset.json content %s", entries["tileset.json"])
+	}
+	if actual := string(entries["3/11/content.pnts"]); actual != "content 11" {
+		t.Errorf("unexpected content %s", actual)
+	}
+}
+
+func TestCompareArchiveHashes(t *testing.T) {
+	a := [md5.Size]byte{0: 1, 8: 2}
+	b := [md5.Size]byte{0: 2, 8: 1}
+	c := [md5.Size]byte{7: 1}
+	if compareArchiveHashes(a, b) != -1 || compareArchiveHashes(b, a) != 1 || compareArchiveHashes(a, a) != 0 {
+		t.Errorf("expected the first 8 bytes to be compared first")
+	}
+	if compareArchiveHashes(b, c) != -1 {
+		t.Errorf("expected the 8th byte to be the most significant of the first 8")
+	}
+}
+
+func TestWriterArchive(t *testing.T) {
+	tmp := t.TempDir()
+	w, err := NewWriter(tmp, WithArchive(true), WithNumWorkers(3), WithTilesetVersion(version.TilesetVersion_1_1))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.Write(implicitTestTree(), "tileset", context.TODO()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	files, err := os.ReadDir(filepath.Join(tmp, "tileset"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(files) != 1 || files[0].Name() != "tileset.3tz" {
+		t.Fatalf("expected only the archive in the tileset folder, got %v", files)
+	}
+	raw, err := os.ReadFile(filepath.Join(tmp, "tileset", "tileset.3tz"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	entries := readArchive(t, raw)
+	for _, name := range []string{"tileset.json", "content.glb", "1/tileset.json", "1/content.glb", "1/3/content.glb", "6/content.glb"} {
+		if len(entries[name]) == 0 {
+			t.Errorf("expected a non empty entry %s", name)
+		}
+	}
+	if len(entries) != 6 {
+		t.Errorf("expected 6 entries got %d", len(entries))
+	}
+}
@@ -0,0 +1,64 @@
+package writer
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+)
+
+// attributePropertyName returns the name used to store the given attribute in the tiles. The name is sanitized
+// to only contain letters, digits and underscores and is prefixed with EXTRA_ if it clashes with the names
+// of the standard attributes.
+func attributePropertyName(a geom.Attribute) string {
+	name := []rune(a.Name)
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			name[i] = '_'
+		}
+	}
+	s := string(name)
+	if s == "" || s[0] >= '0' && s[0] <= '9' || strings.EqualFold(s, "INTENSITY") || strings.EqualFold(s, "CLASSIFICATION") {
+		s = "EXTRA_" + s
+	}
+	return s
+}
+
+// attributeType returns the element type, SCALAR, VEC2 or VEC3, of an attribute with the given number of components
+func attributeType(components int) string {
+	switch components {
+	case 2:
+		return "VEC2"
+	case 3:
+		return "VEC3"
+	}
+	return "SCALAR"
+}
+
+// putAttributeValue writes the value v into the buffer b using the little endian binary representation
+// of the given type
+func putAttributeValue(b []byte, t geom.AttributeType, v float64) {
+	switch t {
+	case geom.AttributeUint8:
+		b[0] = uint8(v)
+	case geom.AttributeInt8:
+		b[0] = uint8(int8(v))
+	case geom.AttributeUint16:
+		binary.LittleEndian.PutUint16(b, uint16(v))
+	case geom.AttributeInt16:
+		binary.LittleEndian.PutUint16(b, uint16(int16(v)))
+	case geom.AttributeUint32:
+		binary.LittleEndian.PutUint32(b, uint32(v))
+	case geom.AttributeInt32:
+		binary.LittleEndian.PutUint32(b, uint32(int32(v)))
+	case geom.AttributeUint64:
+		binary.LittleEndian.PutUint64(b, uint64(v))
+	case geom.AttributeInt64:
+		binary.LittleEndian.PutUint64(b, uint64(int64(v)))
+	case geom.AttributeFloat32:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)))
+	default:
+		binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+	}
+}
@@ -0,0 +1,214 @@
+package writer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+var testAttributes = []geom.Attribute{
+	{Name: "Flags", Description: "Flags", Type: geom.AttributeInt8, Components: 1},
+	{Name: "Time", Description: "GPS \"time\"", Type: geom.AttributeUint64, Components: 1},
+	{Name: "Colors", Description: "Colors", Type: geom.AttributeUint16, Components: 3},
+	{Name: "intensity", Description: "Brightness", Type: geom.AttributeFloat32, Components: 1},
+}
+
+// writeContentFile encodes the node in the content file of the encoder in the given folder
+func writeContentFile(e GeometryEncoder, node tree.Node, folder string) error {
+	f, err := os.Create(filepath.Join(folder, e.Filename()))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return e.Write(node, f)
+}
+
+func attributesTestNode() *tree.MockNode {
+	pts := &pointSlice{
+		pts: []model.Point{
+			{X: 0, Y: 0, Z: 0, Intensity: 1, Classification: 2},
+			{X: 1, Y: 1, Z: 1, Intensity: 3, Classification: 4},
+			{X: 2, Y: 2, Z: 2, Intensity: 5, Classification: 6},
+		},
+		attrs: []float64{
+			-1, 1000, 1, 2, 3, 0.5,
+			-2, 2000, 4, 5, 6, 1.5,
+			-3, 3000, 7, 8, 9, 2.5,
+		},
+		attributes: 6,
+	}
+	return &tree.MockNode{
+		TotalNumPts: 3,
+		Pts:         pts,
+	}
+}
+
+func TestAttributePropertyName(t *testing.T) {
+	tests := map[string]string{
+		"Amplitude":      "Amplitude",
+		"pulse width":    "pulse_width",
+		"1st":            "EXTRA_1st",
+		"Intensity":      "EXTRA_Intensity",
+		"CLASSIFICATION": "EXTRA_CLASSIFICATION",
+		"":               "EXTRA_",
+	}
+	for name, expected := range tests {
+		if actual := attributePropertyName(geom.Attribute{Name: name}); actual != expected {
+			t.Errorf("expected property name %s for %s got %s", expected, name, actual)
+		}
+	}
+}
+
+func TestPntsEncoderAttributes(t *testing.T) {
+	tmp := t.TempDir()
+	e := NewPntsEncoder(WithPntsAttributes(testAttributes))
+	if err := writeContentFile(e, attributesTestNode(), tmp); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(tmp, "content.pnts"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	ftLen := int(binary.LittleEndian.Uint32(b[12:]))
+	ftBinLen := int(binary.LittleEndian.Uint32(b[16:]))
+	btLen := int(binary.LittleEndian.Uint32(b[20:]))
+	btBinLen := int(binary.LittleEndian.Uint32(b[24:]))
+	btStart := 28 + ftLen + ftBinLen
+	btBinStart := btStart + btLen
+	if btBinStart%8 != 0 || btBinLen%8 != 0 {
+		t.Errorf("expected batch table binary aligned to 8 bytes, got offset %d and length %d", btBinStart, btBinLen)
+	}
+	if len(b) != btBinStart+btBinLen {
+		t.Fatalf("expected file length %d got %d", btBinStart+btBinLen, len(b))
+	}
+	type property struct {
+		ByteOffset    int    `json:"byteOffset"`
+		ComponentType string `json:"componentType"`
+		Type          string `json:"type"`
+	}
+	bt := map[string]property{}
+	if err := json.Unmarshal(b[btStart:btBinStart], &bt); err != nil {
+		t.Fatalf("unable to decode batch table: %v", err)
+	}
+	expected := map[string]property{
+		"INTENSITY":       {ByteOffset: 0, ComponentType: "UNSIGNED_BYTE", Type: "SCALAR"},
+		"CLASSIFICATION":  {ByteOffset: 3, ComponentType: "UNSIGNED_BYTE", Type: "SCALAR"},
+		"Flags":           {ByteOffset: 6, ComponentType: "BYTE", Type: "SCALAR"},
+		"Time":            {ByteOffset: 16, ComponentType: "DOUBLE", Type: "SCALAR"},
+		"Colors":          {ByteOffset: 40, ComponentType: "UNSIGNED_SHORT", Type: "VEC3"},
+		"EXTRA_intensity": {ByteOffset: 60, ComponentType: "FLOAT", Type: "SCALAR"},
+	}
+	for k, v := range expected {
+		if bt[k] != v {
+			t.Errorf("expected batch table property %s to be %v got %v", k, v, bt[k])
+		}
+	}
+	bin := b[btBinStart:]
+	for i := 0; i < 3; i++ {
+		if actual := int8(bin[6+i]); actual != int8(-1-i) {
+			t.Errorf("expected flags %d got %d", -1-i, actual)
+		}
+		if actual := math.Float64frombits(binary.LittleEndian.Uint64(bin[16+8*i:])); actual != float64(1000*(i+1)) {
+			t.Errorf("expected time %d got %v", 1000*(i+1), actual)
+		}
+		for c := 0; c < 3; c++ {
+			if actual := binary.LittleEndian.Uint16(bin[40+6*i+2*c:]); actual != uint16(3*i+c+1) {
+				t.Errorf("expected color component %d got %d", 3*i+c+1, actual)
+			}
+		}
+		if actual := math.Float32frombits(binary.LittleEndian.Uint32(bin[60+4*i:])); actual != float32(i)+0.5 {
+			t.Errorf("expected intensity %v got %v", float32(i)+0.5, actual)
+		}
+	}
+}
+
+func TestGltfEncoderAttributes(t *testing.T) {
+	tmp := t.TempDir()
+	e := NewGltfEncoder(WithGltfAttributes(testAttributes))
+	if err := writeContentFile(e, attributesTestNode(), tmp); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	doc, err := gltf.Open(filepath.Join(tmp, "content.glb"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	ext := struct {
+		Schema struct {
+			Classes map[string]struct {
+				Properties map[string]struct {
+					Description   string `json:"description"`
+					Type          string `json:"type"`
+					ComponentType string `json:"componentType"`
+				} `json:"properties"`
+			} `json:"classes"`
+		} `json:"schema"`
+		PropertyAttributes []struct {
+			Properties map[string]struct {
+				Attribute string   `json:"attribute"`
+				Offset    *float64 `json:"offset"`
+			} `json:"properties"`
+		} `json:"propertyAttributes"`
+	}{}
+	raw, err := json.Marshal(doc.Extensions["EXT_structural_metadata"])
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := json.Unmarshal(raw, &ext); err != nil {
+		t.Fatalf("unable to decode EXT_structural_metadata: %v", err)
+	}
+	props := ext.Schema.Classes["point"].Properties
+	if len(props) != 6 {
+		t.Errorf("expected 6 properties got %d", len(props))
+	}
+	if p := props["Time"]; p.ComponentType != "FLOAT32" || p.Type != "SCALAR" || p.Description != "GPS \"time\"" {
+		t.Errorf("unexpected Time property %v", p)
+	}
+	if p := props["Colors"]; p.ComponentType != "UINT16" || p.Type != "VEC3" {
+		t.Errorf("unexpected Colors property %v", p)
+	}
+	if p := props["Flags"]; p.ComponentType != "INT16" || p.Type != "SCALAR" {
+		t.Errorf("unexpected Flags property %v", p)
+	}
+	if a := ext.PropertyAttributes[0].Properties["EXTRA_intensity"].Attribute; a != "_EXTRA_INTENSITY" {
+		t.Errorf("expected attribute _EXTRA_INTENSITY got %s", a)
+	}
+
+	attrs := doc.Meshes[0].Primitives[0].Attributes
+	colors, err := modeler.ReadAccessor(doc, doc.Accessors[attrs["_COLORS"]], nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if c := colors.([][3]uint16); c[2] != [3]uint16{7, 8, 9} {
+		t.Errorf("expected colors %v got %v", [3]uint16{7, 8, 9}, c[2])
+	}
+	times, err := modeler.ReadAccessor(doc, doc.Accessors[attrs["_TIME"]], nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// 64 bit values are stored relative to their minimum
+	if tm := times.([]float32); tm[1] != 1000 {
+		t.Errorf("expected time %v got %v", 1000, tm[1])
+	}
+	if o := ext.PropertyAttributes[0].Properties["Time"].Offset; o == nil || *o != 1000 {
+		t.Errorf("expected time offset %v got %v", 1000, o)
+	}
+	if o := ext.PropertyAttributes[0].Properties["EXTRA_intensity"].Offset; o != nil {
+		t.Errorf("expected no offset for 32 bit floats got %v", *o)
+	}
+	flags, err := modeler.ReadAccessor(doc, doc.Accessors[attrs["_FLAGS"]], nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if f := flags.([]int16); f[0] != -1 {
+		t.Errorf("expected flags %v got %v", -1, f[0])
+	}
+}
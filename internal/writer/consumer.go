@@ -4,20 +4,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"io"
 	"path"
 	"strconv"
 	"sync"
 
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
-	"github.com/mfbonfigli/gocesiumtiler/v2/internal/utils"
 	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
 	"github.com/mfbonfigli/gocesiumtiler/v2/version"
 )
 
 // GeometryEncoder encodes a tree.Node into a binary file, like a .pnts or .glb/.gltf files.
 type GeometryEncoder interface {
-	Write(n tree.Node, folderPath string) error
+	Write(n tree.Node, w io.Writer) error
 	TilesetVersion() version.TilesetVersion
 	Filename() string
 }
@@ -28,15 +28,23 @@ type Consumer interface {
 
 type StandardConsumer struct {
 	encoder GeometryEncoder
+	sink    Sink
+	// subtreeLevels is the number of levels of each subtree when using implicit tiling
+	subtreeLevels int
+	gzip          GzipMode
 }
 
 func NewStandardConsumer(optFn ...func(*StandardConsumer)) Consumer {
 	c := &StandardConsumer{
 		encoder: NewPntsEncoder(),
+		sink:    NewStorageSink(storage.NewFileStorage("")),
 	}
 	for _, fn := range optFn {
 		fn(c)
 	}
+	if c.gzip != GzipNone {
+		c.sink = newGzipSink(c.sink, c.gzip)
+	}
 	return c
 }
 
@@ -47,6 +55,27 @@ func WithGeometryEncoder(e GeometryEncoder) func(*StandardConsumer) {
 	}
 }
 
+// WithSink sets the sink where the consumer stores the files it writes
+func WithSink(s Sink) func(*StandardConsumer) {
+	return func(c *StandardConsumer) {
+		c.sink = s
+	}
+}
+
+// WithGzip compresses the files written by the consumer, either replacing them or alongside them
+func WithGzip(mode GzipMode) func(*StandardConsumer) {
+	return func(c *StandardConsumer) {
+		c.gzip = mode
+	}
+}
+
+// WithSubtreeLevels sets the number of levels of the subtrees written for WorkUnits using implicit tiling
+func WithSubtreeLevels(levels int) func(*StandardConsumer) {
+	return func(c *StandardConsumer) {
+		c.subtreeLevels = levels
+	}
+}
+
 // Continually consumes WorkUnits submitted to a work channel producing corresponding gometry .pnts/.glb files and tileset.json files
 // continues working until work channel is closed or if an error is raised. In this last case submits the error to an error
 // channel before quitting
@@ -80,18 +109,13 @@ func (c *StandardConsumer) Consume(workchan chan *WorkUnit, errchan chan error,
 
 // Takes a workunit and writes the corresponding content.glb/.pnts and tileset.json files
 func (c *StandardConsumer) doWork(workUnit *WorkUnit) error {
-	parentFolder := workUnit.BasePath
-	node := workUnit.Node
-
-	// Create base folder if it does not exist
-	err := utils.CreateDirectoryIfDoesNotExist(parentFolder)
+	// encodes and writes the geometries to the sink as a .pnts/.glb file
+	err := c.writeContent(workUnit)
 	if err != nil {
 		return err
 	}
-	// encodes and writes the geometries to the disk as a .pnts/.glb file
-	err = c.encoder.Write(node, parentFolder)
-	if err != nil {
-		return err
+	if workUnit.Implicit != nil {
+		return c.writeImplicitFiles(workUnit)
 	}
 	// as an edge case we could have a leaf root node. This needs a tileset.json even if it's leaf.
 	if !workUnit.Node.IsLeaf() || workUnit.Node.IsRoot() {
@@ -104,17 +128,49 @@ func (c *StandardConsumer) doWork(workUnit *WorkUnit) error {
 	return nil
 }
 
+// Writes the .pnts/.glb file with the geometries of the WorkUnit node
+func (c *StandardConsumer) writeContent(workUnit *WorkUnit) error {
+	f, err := c.sink.Create(path.Join(workUnit.BasePath, c.encoder.Filename()))
+	if err != nil {
+		return err
+	}
+	if err := c.encoder.Write(workUnit.Node, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Writes the subtree file if the WorkUnit tile is the root of a subtree and the tileset.json file if it is the root tile
+func (c *StandardConsumer) writeImplicitFiles(workUnit *WorkUnit) error {
+	tile := workUnit.Implicit
+	if c.subtreeLevels < 1 {
+		return errors.New("implicit tiling requires the number of subtree levels to be set")
+	}
+	if tile.Level%c.subtreeLevels == 0 {
+		subtree, err := c.generateSubtree(workUnit.Node, c.subtreeLevels)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(c.sink, tile.SubtreePath(), subtree); err != nil {
+			return err
+		}
+	}
+	if tile.Level == 0 {
+		jsonData, err := c.generateImplicitTilesetJson(workUnit.Node)
+		if err != nil {
+			return err
+		}
+		return writeFile(c.sink, path.Join(tile.TilesetPath, "tileset.json"), jsonData)
+	}
+	return nil
+}
+
 // Writes the tileset.json file for the given WorkUnit
 func (c *StandardConsumer) writeTilesetJsonFile(workUnit WorkUnit) error {
 	parentFolder := workUnit.BasePath
 	node := workUnit.Node
 
-	// Create base folder if it does not exist
-	err := utils.CreateDirectoryIfDoesNotExist(parentFolder)
-	if err != nil {
-		return err
-	}
-
 	// tileset.json file
 	file := path.Join(parentFolder, "tileset.json")
 	jsonData, err := c.generateTilesetJson(node)
@@ -123,7 +179,7 @@ func (c *StandardConsumer) writeTilesetJsonFile(workUnit WorkUnit) error {
 	}
 
 	// Writes the tileset.json binary content to the given file
-	err = os.WriteFile(file, jsonData, 0666)
+	err = writeFile(c.sink, file, jsonData)
 	if err != nil {
 		return err
 	}
@@ -161,22 +217,25 @@ func (c *StandardConsumer) generateTilesetRoot(node tree.Node) (Root, error) {
 		return Root{}, err
 	}
 
-	var cMajorTransformPtr *[16]float64
-	if trans := node.ToParentCRS(); trans != nil && *trans != model.IdentityTransform {
-		cMajor := trans.ForwardColumnMajor()
-		cMajorTransformPtr = &cMajor
-	}
-
 	return Root{
 		Content:        Content{c.encoder.Filename()},
 		BoundingVolume: BoundingVolume{Box: reg.AsCesiumBox()},
 		GeometricError: node.GeometricError(),
 		Refine:         "ADD",
 		Children:       children,
-		Transform:      cMajorTransformPtr,
+		Transform:      rootTransform(node),
 	}, nil
 }
 
+// rootTransform returns the column major transform from the node CRS to the parent one, or nil if it is the identity
+func rootTransform(node tree.Node) *[16]float64 {
+	if trans := node.ToParentCRS(); trans != nil && *trans != model.IdentityTransform {
+		cMajor := trans.ForwardColumnMajor()
+		return &cMajor
+	}
+	return nil
+}
+
 func (c *StandardConsumer) generateTileset(node tree.Node, root Root) Tileset {
 	tileset := Tileset{}
 	tileset.Asset = Asset{Version: c.encoder.TilesetVersion()}
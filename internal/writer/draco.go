@@ -0,0 +1,132 @@
+package writer
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// DracoOptions configures the Draco compression of the tile geometries
+type DracoOptions struct {
+	// PositionBits is the number of bits used to quantize the point coordinates
+	PositionBits int
+	// ColorBits is the number of bits retained for each color component, up to 8
+	ColorBits int
+	// AttributeBits is the number of bits used to quantize the floating point attributes
+	AttributeBits int
+}
+
+// pointSlice implements the PointList interface on top of a slice of points and of the columnar array of
+// their attribute values
+type pointSlice struct {
+	pts        []model.Point
+	attrs      []float64
+	attributes int
+	current    int
+}
+
+func (p *pointSlice) Next() (model.Point, error) {
+	if p.current >= len(p.pts) {
+		return model.Point{}, fmt.Errorf("no more points")
+	}
+	p.current++
+	return p.pts[p.current-1], nil
+}
+
+func (p *pointSlice) Len() int {
+	return len(p.pts)
+}
+
+func (p *pointSlice) Reset() {
+	p.current = 0
+}
+
+func (p *pointSlice) Attributes() []float64 {
+	if p.attributes == 0 || p.current == 0 {
+		return nil
+	}
+	return p.attrs[(p.current-1)*p.attributes : p.current*p.attributes]
+}
+
+// mortonSortedPoints returns the points of the list sorted in Morton order, so that points close in space
+// are also close in the list. This greatly improves the Draco compression as each point is predicted from
+// the previous one.
+func mortonSortedPoints(pts geom.PointList) (*pointSlice, error) {
+	type mortonPoint struct {
+		code  uint64
+		pt    model.Point
+		attrs []float64
+	}
+	points := make([]mortonPoint, pts.Len())
+	attributes := 0
+	var attrs []float64
+	minX, minY, minZ := float32(math.MaxFloat32), float32(math.MaxFloat32), float32(math.MaxFloat32)
+	maxX, maxY, maxZ := -minX, -minY, -minZ
+	for i := range points {
+		pt, err := pts.Next()
+		if err != nil {
+			return nil, err
+		}
+		points[i].pt = pt
+		if values := pts.Attributes(); len(values) > 0 {
+			// the values are only valid until the next point is read, hence they are copied
+			if attrs == nil {
+				attributes = len(values)
+				attrs = make([]float64, 0, len(points)*attributes)
+			}
+			attrs = append(attrs, values...)
+			points[i].attrs = attrs[i*attributes : (i+1)*attributes]
+		}
+		minX, minY, minZ = min(minX, pt.X), min(minY, pt.Y), min(minZ, pt.Z)
+		maxX, maxY, maxZ = max(maxX, pt.X), max(maxY, pt.Y), max(maxZ, pt.Z)
+	}
+	pts.Reset()
+	// each coordinate is scaled to 21 bits, so that the interleaved code fits in 64 bits
+	scale := float64(1<<21-1) / math.Max(float64(max(maxX-minX, maxY-minY, maxZ-minZ)), 1e-9)
+	for i := range points {
+		pt := points[i].pt
+		points[i].code = spreadBits(uint64(float64(pt.X-minX)*scale)) |
+			spreadBits(uint64(float64(pt.Y-minY)*scale))<<1 |
+			spreadBits(uint64(float64(pt.Z-minZ)*scale))<<2
+	}
+	slices.SortStableFunc(points, func(a, b mortonPoint) int {
+		switch {
+		case a.code < b.code:
+			return -1
+		case a.code > b.code:
+			return 1
+		}
+		return 0
+	})
+	sorted := make([]model.Point, len(points))
+	sortedAttrs := make([]float64, 0, len(attrs))
+	for i, p := range points {
+		sorted[i] = p.pt
+		sortedAttrs = append(sortedAttrs, p.attrs...)
+	}
+	return &pointSlice{pts: sorted, attrs: sortedAttrs, attributes: attributes}, nil
+}
+
+// spreadBits spreads the lowest 21 bits of v so that they are separated by two zero bits
+func spreadBits(v uint64) uint64 {
+	v &= 0x1fffff
+	v = (v | v<<32) & 0x1f00000000ffff
+	v = (v | v<<16) & 0x1f0000ff0000ff
+	v = (v | v<<8) & 0x100f00f00f00f00f
+	v = (v | v<<4) & 0x10c30c30c30c30c3
+	v = (v | v<<2) & 0x1249249249249249
+	return v
+}
+
+// quantizeColor rounds the color component to the nearest value representable with the given number of bits
+func quantizeColor(c uint8, bits int) int32 {
+	if bits >= 8 {
+		return int32(c)
+	}
+	levels := float64(int(1)<<bits - 1)
+	q := math.Round(float64(c) * levels / 255)
+	return int32(math.Round(q * 255 / levels))
+}
@@ -0,0 +1,216 @@
+package writer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+	"github.com/mfbonfigli/gocesiumtiler/v2/version"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+var testDracoOptions = DracoOptions{PositionBits: 14, ColorBits: 8, AttributeBits: 16}
+
+func TestMortonSortedPoints(t *testing.T) {
+	pts := []model.Point{
+		{X: 1, Y: 1, Z: 1, Intensity: 0},
+		{X: 0, Y: 0, Z: 0, Intensity: 1},
+		{X: 0, Y: 1, Z: 0, Intensity: 2},
+		{X: 1, Y: 0, Z: 0, Intensity: 3},
+	}
+	pt4 := &geom.LinkedPoint{Pt: pts[3]}
+	pt3 := &geom.LinkedPoint{Pt: pts[2], Next: pt4}
+	pt2 := &geom.LinkedPoint{Pt: pts[1], Next: pt3}
+	pt1 := &geom.LinkedPoint{Pt: pts[0], Next: pt2}
+	sorted, err := mortonSortedPoints(geom.NewLinkedPointStream(pt1, 4))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if sorted.Len() != 4 {
+		t.Fatalf("expected 4 points got %d", sorted.Len())
+	}
+	for _, expected := range []uint8{1, 3, 2, 0} {
+		pt, err := sorted.Next()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if pt.Intensity != expected {
+			t.Errorf("expected point %d got %d", expected, pt.Intensity)
+		}
+	}
+	if _, err := sorted.Next(); err == nil {
+		t.Errorf("expected error got none")
+	}
+	sorted.Reset()
+	if pt, _ := sorted.Next(); pt.Intensity != 1 {
+		t.Errorf("expected point 1 after reset got %d", pt.Intensity)
+	}
+}
+
+func TestQuantizeColor(t *testing.T) {
+	tests := []struct {
+		c        uint8
+		bits     int
+		expected int32
+	}{
+		{c: 200, bits: 8, expected: 200},
+		{c: 200, bits: 1, expected: 255},
+		{c: 100, bits: 1, expected: 0},
+		{c: 130, bits: 2, expected: 170},
+		{c: 255, bits: 5, expected: 255},
+	}
+	for _, tc := range tests {
+		if actual := quantizeColor(tc.c, tc.bits); actual != tc.expected {
+			t.Errorf("expected %d for %d with %d bits, got %d", tc.expected, tc.c, tc.bits, actual)
+		}
+	}
+}
+
+func TestPntsEncoderDraco(t *testing.T) {
+	tmp := t.TempDir()
+	e := NewPntsEncoder(WithPntsAttributes(testAttributes), WithPntsDraco(testDracoOptions))
+	if err := writeContentFile(e, attributesTestNode(), tmp); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(tmp, "content.pnts"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	ftLen := int(binary.LittleEndian.Uint32(b[12:]))
+	ftBinLen := int(binary.LittleEndian.Uint32(b[16:]))
+	btLen := int(binary.LittleEndian.Uint32(b[20:]))
+	btBinLen := int(binary.LittleEndian.Uint32(b[24:]))
+	ftBinStart := 28 + ftLen
+	btStart := ftBinStart + ftBinLen
+	btBinStart := btStart + btLen
+	if ftBinStart%8 != 0 || btBinStart%8 != 0 {
+		t.Errorf("expected binary bodies aligned to 8 bytes, got offsets %d and %d", ftBinStart, btBinStart)
+	}
+	if len(b) != btBinStart+btBinLen {
+		t.Fatalf("expected file length %d got %d", btBinStart+btBinLen, len(b))
+	}
+
+	type dracoExtension struct {
+		Properties map[string]int `json:"properties"`
+		ByteOffset int            `json:"byteOffset"`
+		ByteLength int            `json:"byteLength"`
+	}
+	ft := struct {
+		PointsLength int `json:"POINTS_LENGTH"`
+		Extensions   struct {
+			Draco dracoExtension `json:"3DTILES_draco_point_compression"`
+		} `json:"extensions"`
+	}{}
+	if err := json.Unmarshal(b[28:ftBinStart], &ft); err != nil {
+		t.Fatalf("unable to decode feature table: %v", err)
+	}
+	ext := ft.Extensions.Draco
+	if ft.PointsLength != 3 || ext.Properties["POSITION"] != 0 || ext.Properties["RGB"] != 1 || ext.ByteOffset != 0 {
+		t.Errorf("unexpected feature table %+v", ft)
+	}
+	if ext.ByteLength > ftBinLen || string(b[ftBinStart:ftBinStart+5]) != "DRACO" {
+		t.Errorf("expected draco data of length %d in the feature table binary body", ext.ByteLength)
+	}
+
+	bt := struct {
+		Flags struct {
+			ByteOffset int `json:"byteOffset"`
+		}
+		Extensions struct {
+			Draco dracoExtension `json:"3DTILES_draco_point_compression"`
+		} `json:"extensions"`
+	}{}
+	if err := json.Unmarshal(b[btStart:btBinStart], &bt); err != nil {
+		t.Fatalf("unable to decode batch table: %v", err)
+	}
+	if p := bt.Extensions.Draco.Properties; p["INTENSITY"] != 2 || p["CLASSIFICATION"] != 3 {
+		t.Errorf("unexpected batch table draco properties %v", p)
+	}
+	// the additional attributes are not compressed and start at the beginning of the binary body
+	if bt.Flags.ByteOffset != 0 {
+		t.Errorf("expected flags at offset 0 got %d", bt.Flags.ByteOffset)
+	}
+	if f := int8(b[btBinStart]); f != -1 {
+		t.Errorf("expected flags %d got %d", -1, f)
+	}
+}
+
+func TestGltfEncoderDraco(t *testing.T) {
+	tmp := t.TempDir()
+	e := NewGltfEncoder(WithGltfAttributes(testAttributes), WithGltfDraco(testDracoOptions))
+	if err := writeContentFile(e, attributesTestNode(), tmp); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	doc, err := gltf.Open(filepath.Join(tmp, "content.glb"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(doc.ExtensionsRequired) != 1 || doc.ExtensionsRequired[0] != "KHR_draco_mesh_compression" {
+		t.Errorf("expected KHR_draco_mesh_compression to be required, got %v", doc.ExtensionsRequired)
+	}
+	primitive := doc.Meshes[0].Primitives[0]
+	ext := struct {
+		BufferView uint32         `json:"bufferView"`
+		Attributes map[string]int `json:"attributes"`
+	}{}
+	raw, err := json.Marshal(primitive.Extensions["KHR_draco_mesh_compression"])
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := json.Unmarshal(raw, &ext); err != nil {
+		t.Fatalf("unable to decode KHR_draco_mesh_compression: %v", err)
+	}
+	expected := map[string]int{
+		"POSITION": 0, "COLOR_0": 1, "_INTENSITY": 2, "_CLASSIFICATION": 3,
+		"_FLAGS": 4, "_TIME": 5, "_COLORS": 6, "_EXTRA_INTENSITY": 7,
+	}
+	if len(ext.Attributes) != len(expected) || len(primitive.Attributes) != len(expected) {
+		t.Fatalf("expected attributes %v got %v", expected, ext.Attributes)
+	}
+	for name, id := range expected {
+		if ext.Attributes[name] != id {
+			t.Errorf("expected draco attribute %s to have id %d got %d", name, id, ext.Attributes[name])
+		}
+		acr := doc.Accessors[primitive.Attributes[name]]
+		if acr.BufferView != nil || acr.Count != 3 {
+			t.Errorf("unexpected accessor %+v for attribute %s", acr, name)
+		}
+	}
+	if acr := doc.Accessors[primitive.Attributes["_FLAGS"]]; acr.ComponentType != gltf.ComponentShort || acr.Type != gltf.AccessorScalar {
+		t.Errorf("unexpected flags accessor %+v", acr)
+	}
+	if acr := doc.Accessors[primitive.Attributes["_COLORS"]]; acr.ComponentType != gltf.ComponentUshort || acr.Type != gltf.AccessorVec3 {
+		t.Errorf("unexpected colors accessor %+v", acr)
+	}
+	if acr := doc.Accessors[primitive.Attributes["POSITION"]]; acr.Min[0] != 0 || acr.Max[2] != 2 {
+		t.Errorf("unexpected position bounds %v %v", acr.Min, acr.Max)
+	}
+	data, err := modeler.ReadBufferView(doc, doc.BufferViews[ext.BufferView])
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// the mesh encoder type is required by the extension
+	if string(data[:5]) != "DRACO" || data[7] != 1 {
+		t.Errorf("expected draco mesh data got %v", data[:8])
+	}
+}
+
+func TestWriterDraco(t *testing.T) {
+	w, err := NewWriter("base", WithDraco(testDracoOptions))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	c := w.consumerFunc(version.TilesetVersion_1_0, nil)
+	if e := c.(*StandardConsumer).encoder.(*PntsEncoder); e.draco == nil || *e.draco != testDracoOptions {
+		t.Errorf("expected draco options %v in the pnts encoder", testDracoOptions)
+	}
+	c = w.consumerFunc(version.TilesetVersion_1_1, nil)
+	if e := c.(*StandardConsumer).encoder.(*GltfEncoder); e.draco == nil || *e.draco != testDracoOptions {
+		t.Errorf("expected draco options %v in the gltf encoder", testDracoOptions)
+	}
+}
@@ -2,18 +2,25 @@ package writer
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"math"
-	"path"
+	"strings"
 
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/draco"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/meshopt"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
 	"github.com/mfbonfigli/gocesiumtiler/v2/version"
 	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/binary"
 	"github.com/qmuntal/gltf/modeler"
 )
 
 // Intensity and Classifications are stored using the EXT_structural_metadata
 // GLTF extension. The following is the static schema that defines such properties and
-// links them the the _INTENSITY and _CLASSIFICATION point
+// links them the the _INTENSITY and _CLASSIFICATION point. Additional point attributes
+// are appended to the properties and property attributes
 var extJson = `
 {
 	"schema": {
@@ -37,7 +44,7 @@ var extJson = `
 			  "type": "SCALAR",
 			  "componentType": "UINT16",
 			  "required": true
-			}
+			}%s
 		  }
 		}
 	  }
@@ -51,7 +58,7 @@ var extJson = `
 		  },
 		  "CLASSIFICATION": {
 			"attribute": "_CLASSIFICATION"
-		  }
+		  }%s
 		}
 	  }
 	]
@@ -59,8 +66,26 @@ var extJson = `
   `
 
 // GltfEncoder writes a node data as Gltf/Glb binary file (3D Tiles 1.1 specs)
-// Encodes intensity and classification using the EXT_structural_metadata GLTF extension
-type GltfEncoder struct{}
+// Encodes intensity, classification and the additional point attributes using the EXT_structural_metadata GLTF extension.
+// If Draco compression is enabled all the point attributes are compressed using the KHR_draco_mesh_compression extension.
+// Otherwise positions can be quantized using the KHR_mesh_quantization extension and the vertex buffers compressed
+// using the EXT_meshopt_compression extension.
+type GltfEncoder struct {
+	attributes []geom.Attribute
+	draco      *DracoOptions
+	quantize   bool
+	meshopt    bool
+}
+
+// gltfVertexAttribute is a vertex attribute of the point cloud primitive
+type gltfVertexAttribute struct {
+	name       string
+	data       any
+	normalized bool
+}
+
+// yUpMatrix rotates the Z up coordinates to the Y up glTF space, column major
+var yUpMatrix = [16]float64{1, 0, 0, 0, 0, 0, -1, 0, 0, 1, 0, 0, 0, 0, 0, 1}
 
 func (e *GltfEncoder) TilesetVersion() version.TilesetVersion {
 	return version.TilesetVersion_1_1
@@ -70,12 +95,53 @@ func (e *GltfEncoder) Filename() string {
 	return "content.glb"
 }
 
-func NewGltfEncoder() *GltfEncoder {
-	return &GltfEncoder{}
+func NewGltfEncoder(opts ...func(*GltfEncoder)) *GltfEncoder {
+	e := &GltfEncoder{}
+	for _, optFn := range opts {
+		optFn(e)
+	}
+	return e
+}
+
+// WithGltfAttributes sets the additional point attributes to store as EXT_structural_metadata property attributes
+func WithGltfAttributes(attributes []geom.Attribute) func(*GltfEncoder) {
+	return func(e *GltfEncoder) {
+		e.attributes = attributes
+	}
 }
 
-func (e *GltfEncoder) Write(node tree.Node, folderPath string) error {
+// WithGltfDraco enables the Draco compression of the points using the given options
+func WithGltfDraco(opts DracoOptions) func(*GltfEncoder) {
+	return func(e *GltfEncoder) {
+		e.draco = &opts
+	}
+}
+
+// WithGltfQuantizedPositions stores the positions as 16 bit integers relative to the bounding box of the node,
+// using the KHR_mesh_quantization extension. Ignored if Draco compression is enabled.
+func WithGltfQuantizedPositions(quantize bool) func(*GltfEncoder) {
+	return func(e *GltfEncoder) {
+		e.quantize = quantize
+	}
+}
+
+// WithGltfMeshopt compresses the vertex buffers using the EXT_meshopt_compression extension.
+// Ignored if Draco compression is enabled.
+func WithGltfMeshopt(meshopt bool) func(*GltfEncoder) {
+	return func(e *GltfEncoder) {
+		e.meshopt = meshopt
+	}
+}
+
+func (e *GltfEncoder) Write(node tree.Node, out io.Writer) error {
 	pts := node.Points()
+	if e.draco != nil || e.meshopt {
+		sorted, err := mortonSortedPoints(pts)
+		if err != nil {
+			return err
+		}
+		pts = sorted
+	}
 
 	doc := gltf.NewDocument()
 	doc.Asset = gltf.Asset{
@@ -89,6 +155,10 @@ func (e *GltfEncoder) Write(node tree.Node, folderPath string) error {
 	// Note: for some reason uint8 results in an invalid GLTF being generated
 	intensities := make([]uint16, pts.Len())
 	classifications := make([]uint16, pts.Len())
+	attributes := make([][]float64, len(e.attributes))
+	for j, a := range e.attributes {
+		attributes[j] = make([]float64, 0, pts.Len()*a.Components)
+	}
 	for i := 0; i < pts.Len(); i++ {
 		pt, err := pts.Next()
 		if err != nil {
@@ -106,18 +176,72 @@ func (e *GltfEncoder) Write(node tree.Node, folderPath string) error {
 		colors[i][2] = uint8(math.Pow((float64(pt.B)/255), 2.2) * 255)
 		intensities[i] = uint16(pt.Intensity)
 		classifications[i] = uint16(pt.Classification)
+		values := pts.Attributes()
+		index := 0
+		for j, a := range e.attributes {
+			attributes[j] = append(attributes[j], values[index:index+a.Components]...)
+			index += a.Components
+		}
 	}
 
-	attrs, err := modeler.WriteAttributesInterleaved(doc, modeler.Attributes{
-		Position: coords,
-		Color:    colors,
-		CustomAttributes: []modeler.CustomAttribute{
-			{Name: "_INTENSITY", Data: intensities},
-			{Name: "_CLASSIFICATION", Data: classifications},
-		},
-	})
-	if err != nil {
-		return err
+	// values stored with a lower precision than the source one are stored relative to their minimum in the tile
+	offsets := make([][]float64, len(e.attributes))
+	for j, a := range e.attributes {
+		if gltfComponentType(a.Type) == "FLOAT32" && a.Type != geom.AttributeFloat32 {
+			offsets[j] = subtractMinimum(attributes[j], a.Components)
+		}
+	}
+
+	primitiveExtensions := gltf.Extensions{
+		"EXT_structural_metadata": json.RawMessage(`{"propertyAttributes": [0]}`),
+	}
+	extensionsUsed := []string{"EXT_structural_metadata"}
+	var attrs gltf.Attribute
+	var err error
+	matrix := yUpMatrix
+	if e.draco != nil {
+		var dracoExt json.RawMessage
+		attrs, dracoExt, err = e.writeDracoAttributes(doc, coords, colors, intensities, classifications, attributes)
+		if err != nil {
+			return err
+		}
+		primitiveExtensions["KHR_draco_mesh_compression"] = dracoExt
+		extensionsUsed = append(extensionsUsed, "KHR_draco_mesh_compression")
+		doc.ExtensionsRequired = []string{"KHR_draco_mesh_compression"}
+	} else {
+		position := gltfVertexAttribute{name: gltf.POSITION, data: coords}
+		if e.quantize {
+			var offset, scale [3]float64
+			position.data, offset, scale = quantizePositions(coords, node.BoundingBox())
+			matrix = dequantizationMatrix(offset, scale)
+			extensionsUsed = append(extensionsUsed, "KHR_mesh_quantization")
+			doc.ExtensionsRequired = append(doc.ExtensionsRequired, "KHR_mesh_quantization")
+		}
+		vertexAttributes := []gltfVertexAttribute{
+			position,
+			{name: gltf.COLOR_0, data: colors, normalized: true},
+			{name: "_INTENSITY", data: intensities},
+			{name: "_CLASSIFICATION", data: classifications},
+		}
+		for j, a := range e.attributes {
+			vertexAttributes = append(vertexAttributes, gltfVertexAttribute{
+				name: "_" + strings.ToUpper(attributePropertyName(a)),
+				data: gltfAttributeData(a, attributes[j]),
+			})
+		}
+
+		if e.meshopt {
+			attrs, err = writeMeshoptAttributes(doc, vertexAttributes)
+			extensionsUsed = append(extensionsUsed, "EXT_meshopt_compression")
+			doc.ExtensionsRequired = append(doc.ExtensionsRequired, "EXT_meshopt_compression")
+		} else {
+			attrs, err = writeInterleavedAttributes(doc, vertexAttributes)
+		}
+		if err != nil {
+			return err
+		}
+		acr := doc.Accessors[attrs[gltf.POSITION]]
+		acr.Min, acr.Max = positionBounds(position.data)
 	}
 
 	// When both featureId.attribute and featureId.texture are undefined, then the feature ID value
@@ -128,9 +252,7 @@ func (e *GltfEncoder) Write(node tree.Node, folderPath string) error {
 		Primitives: []*gltf.Primitive{{
 			Mode:       gltf.PrimitivePoints,
 			Attributes: attrs,
-			Extensions: gltf.Extensions{
-				"EXT_structural_metadata": json.RawMessage(`{"propertyAttributes": [0]}`),
-			},
+			Extensions: primitiveExtensions,
 		}},
 	}}
 	// gltf is Y up, however Cesium is Z up. This means that a rotation transform needs to be applied.
@@ -138,17 +260,370 @@ func (e *GltfEncoder) Write(node tree.Node, folderPath string) error {
 		{
 			Name:   "PointCloud",
 			Mesh:   gltf.Index(0),
-			Matrix: [16]float64{1, 0, 0, 0, 0, 0, -1, 0, 0, 1, 0, 0, 0, 0, 0, 1},
+			Matrix: matrix,
 		},
 	}
 	doc.Scenes[0].Nodes = append(doc.Scenes[0].Nodes, 0)
 	doc.Extensions = gltf.Extensions{
-		"EXT_structural_metadata": json.RawMessage(extJson),
+		"EXT_structural_metadata": json.RawMessage(e.generateExtJson(offsets)),
 	}
-	doc.ExtensionsUsed = []string{
-		"EXT_structural_metadata",
+	doc.ExtensionsUsed = extensionsUsed
+
+	return gltf.NewEncoder(out).Encode(doc)
+}
+
+// writeDracoAttributes compresses the point attributes with Draco into a new buffer view and adds the accessors
+// describing the decoded attributes. Returns the accessors by attribute name and the KHR_draco_mesh_compression
+// primitive extension linking them to the Draco attributes.
+func (e *GltfEncoder) writeDracoAttributes(doc *gltf.Document, coords [][3]float32, colors [][3]uint8, intensities, classifications []uint16, attributes [][]float64) (gltf.Attribute, json.RawMessage, error) {
+	enc := draco.NewEncoder(len(coords), draco.WithGeometryType(draco.TriangularMesh))
+	attrs := gltf.Attribute{}
+	dracoAttrs := map[string]int{}
+	addAccessor := func(name string, id int, c gltf.ComponentType, components int, normalized bool) *gltf.Accessor {
+		doc.Accessors = append(doc.Accessors, &gltf.Accessor{
+			ComponentType: c,
+			Normalized:    normalized,
+			Count:         uint32(len(coords)),
+			Type:          gltfAccessorType(components),
+		})
+		attrs[name] = uint32(len(doc.Accessors) - 1)
+		dracoAttrs[name] = id
+		return doc.Accessors[len(doc.Accessors)-1]
 	}
 
-	pntsFilePath := path.Join(folderPath, e.Filename())
-	return gltf.SaveBinary(doc, pntsFilePath)
+	positions := make([]float32, 0, 3*len(coords))
+	for _, c := range coords {
+		positions = append(positions, c[:]...)
+	}
+	id, err := enc.AddFloatAttribute(draco.Position, 3, positions, e.draco.PositionBits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to encode positions: %w", err)
+	}
+	acr := addAccessor(gltf.POSITION, id, gltf.ComponentFloat, 3, false)
+	acr.Min, acr.Max = minMax(positions, 3)
+
+	values := make([]int32, 0, 3*len(colors))
+	for _, c := range colors {
+		for _, v := range c {
+			values = append(values, quantizeColor(v, e.draco.ColorBits))
+		}
+	}
+	if id, err = enc.AddIntAttribute(draco.Color, draco.Uint8, 3, true, values); err != nil {
+		return nil, nil, fmt.Errorf("unable to encode colors: %w", err)
+	}
+	addAccessor(gltf.COLOR_0, id, gltf.ComponentUbyte, 3, true)
+
+	for _, attr := range []struct {
+		name string
+		data []uint16
+	}{{"_INTENSITY", intensities}, {"_CLASSIFICATION", classifications}} {
+		values := make([]int32, len(attr.data))
+		for i, v := range attr.data {
+			values[i] = int32(v)
+		}
+		if id, err = enc.AddIntAttribute(draco.Generic, draco.Uint16, 1, false, values); err != nil {
+			return nil, nil, fmt.Errorf("unable to encode %s: %w", attr.name, err)
+		}
+		addAccessor(attr.name, id, gltf.ComponentUshort, 1, false)
+	}
+
+	for j, a := range e.attributes {
+		name := "_" + strings.ToUpper(attributePropertyName(a))
+		switch gltfComponentType(a.Type) {
+		case "INT16":
+			values := make([]int32, len(attributes[j]))
+			for i, v := range attributes[j] {
+				values[i] = int32(int16(v))
+			}
+			id, err = enc.AddIntAttribute(draco.Generic, draco.Int16, a.Components, false, values)
+			addAccessor(name, id, gltf.ComponentShort, a.Components, false)
+		case "UINT16":
+			values := make([]int32, len(attributes[j]))
+			for i, v := range attributes[j] {
+				values[i] = int32(uint16(v))
+			}
+			id, err = enc.AddIntAttribute(draco.Generic, draco.Uint16, a.Components, false, values)
+			addAccessor(name, id, gltf.ComponentUshort, a.Components, false)
+		default:
+			values := make([]float32, len(attributes[j]))
+			for i, v := range attributes[j] {
+				values[i] = float32(v)
+			}
+			id, err = enc.AddFloatAttribute(draco.Generic, a.Components, values, e.draco.AttributeBits)
+			addAccessor(name, id, gltf.ComponentFloat, a.Components, false)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to encode attribute %s: %w", a.Name, err)
+		}
+	}
+
+	data, err := enc.Encode()
+	if err != nil {
+		return nil, nil, err
+	}
+	ext, err := json.Marshal(map[string]any{
+		"bufferView": modeler.WriteBufferView(doc, gltf.TargetNone, data),
+		"attributes": dracoAttrs,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return attrs, ext, nil
+}
+
+// writeInterleavedAttributes writes the vertex attributes interleaved in a single buffer view
+func writeInterleavedAttributes(doc *gltf.Document, vertexAttributes []gltfVertexAttribute) (gltf.Attribute, error) {
+	data := make([]any, len(vertexAttributes))
+	for i, a := range vertexAttributes {
+		data[i] = a.data
+	}
+	indices, err := modeler.WriteAccessorsInterleaved(doc, data...)
+	if err != nil {
+		return nil, err
+	}
+	attrs := gltf.Attribute{}
+	for i, index := range indices {
+		attrs[vertexAttributes[i].name] = index
+		doc.Accessors[index].Normalized = vertexAttributes[i].normalized
+	}
+	return attrs, nil
+}
+
+// writeMeshoptAttributes writes each vertex attribute in its own buffer view compressed with the
+// EXT_meshopt_compression extension. The compressed data is stored in the first buffer while the buffer
+// views refer to a fallback buffer with no data, hence the extension is required to decode the attributes.
+func writeMeshoptAttributes(doc *gltf.Document, vertexAttributes []gltfVertexAttribute) (gltf.Attribute, error) {
+	if len(doc.Buffers) == 0 {
+		doc.Buffers = append(doc.Buffers, new(gltf.Buffer))
+	}
+	buffer := doc.Buffers[0]
+	fallback := &gltf.Buffer{
+		Extensions: gltf.Extensions{"EXT_meshopt_compression": json.RawMessage(`{"fallback":true}`)},
+	}
+	doc.Buffers = append(doc.Buffers, fallback)
+	attrs := gltf.Attribute{}
+	for _, a := range vertexAttributes {
+		c, t, count := binary.Type(a.data)
+		// the extension requires the vertex size to be a multiple of 4
+		stride := (gltf.SizeOfElement(c, t) + 3) &^ 3
+		raw := make([]byte, count*stride)
+		if err := binary.Write(raw, stride, a.data); err != nil {
+			return nil, err
+		}
+		compressed, err := meshopt.EncodeVertexBuffer(raw, int(count), int(stride))
+		if err != nil {
+			return nil, fmt.Errorf("unable to compress attribute %s: %w", a.name, err)
+		}
+		buffer.Data = append(buffer.Data, make([]byte, (4-len(buffer.Data)%4)%4)...)
+		ext, err := json.Marshal(map[string]any{
+			"buffer":     0,
+			"byteOffset": len(buffer.Data),
+			"byteLength": len(compressed),
+			"byteStride": stride,
+			"mode":       "ATTRIBUTES",
+			"count":      count,
+		})
+		if err != nil {
+			return nil, err
+		}
+		buffer.Data = append(buffer.Data, compressed...)
+		buffer.ByteLength = uint32(len(buffer.Data))
+
+		doc.BufferViews = append(doc.BufferViews, &gltf.BufferView{
+			Buffer:     1,
+			ByteOffset: fallback.ByteLength,
+			ByteLength: uint32(len(raw)),
+			ByteStride: stride,
+			Target:     gltf.TargetArrayBuffer,
+			Extensions: gltf.Extensions{"EXT_meshopt_compression": json.RawMessage(ext)},
+		})
+		fallback.ByteLength += uint32(len(raw))
+		doc.Accessors = append(doc.Accessors, &gltf.Accessor{
+			BufferView:    gltf.Index(uint32(len(doc.BufferViews) - 1)),
+			ComponentType: c,
+			Normalized:    a.normalized,
+			Count:         count,
+			Type:          t,
+		})
+		attrs[a.name] = uint32(len(doc.Accessors) - 1)
+	}
+	return attrs, nil
+}
+
+// quantizePositions maps the coordinates to 16 bit integers spanning the bounding box, returning them together
+// with the offset and the scale that restore the original coordinates as offset + scale * value
+func quantizePositions(coords [][3]float32, bounds geom.BoundingBox) ([][3]int16, [3]float64, [3]float64) {
+	offset := [3]float64{bounds.Xmid, bounds.Ymid, bounds.Zmid}
+	extent := [3]float64{bounds.Xmax - bounds.Xmin, bounds.Ymax - bounds.Ymin, bounds.Zmax - bounds.Zmin}
+	var scale [3]float64
+	for c := range scale {
+		scale[c] = extent[c] / 2 / math.MaxInt16
+		if scale[c] <= 0 {
+			scale[c] = 1
+		}
+	}
+	quantized := make([][3]int16, len(coords))
+	for i, p := range coords {
+		for c := range p {
+			q := math.Round((float64(p[c]) - offset[c]) / scale[c])
+			quantized[i][c] = int16(math.Min(math.Max(q, -math.MaxInt16), math.MaxInt16))
+		}
+	}
+	return quantized, offset, scale
+}
+
+// dequantizationMatrix returns the node matrix restoring the quantized positions and rotating them to the Y up space
+func dequantizationMatrix(offset, scale [3]float64) [16]float64 {
+	m := [16]float64{
+		scale[0], 0, 0, 0,
+		0, scale[1], 0, 0,
+		0, 0, scale[2], 0,
+		offset[0], offset[1], offset[2], 1,
+	}
+	return multiplyMatrix(yUpMatrix, m)
+}
+
+// multiplyMatrix returns the product of the column major 4x4 matrices
+func multiplyMatrix(a, b [16]float64) [16]float64 {
+	var m [16]float64
+	for col := 0; col < 4; col++ {
+		for row := 0; row < 4; row++ {
+			for k := 0; k < 4; k++ {
+				m[col*4+row] += a[k*4+row] * b[col*4+k]
+			}
+		}
+	}
+	return m
+}
+
+// positionBounds returns the minimum and maximum of the positions, either float or quantized
+func positionBounds(positions any) ([]float64, []float64) {
+	var values []float32
+	switch p := positions.(type) {
+	case [][3]float32:
+		values = make([]float32, 0, 3*len(p))
+		for _, v := range p {
+			values = append(values, v[:]...)
+		}
+	case [][3]int16:
+		values = make([]float32, 0, 3*len(p))
+		for _, v := range p {
+			values = append(values, float32(v[0]), float32(v[1]), float32(v[2]))
+		}
+	}
+	return minMax(values, 3)
+}
+
+// gltfAccessorType returns the accessor type of an attribute with the given number of components
+func gltfAccessorType(components int) gltf.AccessorType {
+	switch components {
+	case 2:
+		return gltf.AccessorVec2
+	case 3:
+		return gltf.AccessorVec3
+	}
+	return gltf.AccessorScalar
+}
+
+// minMax returns the minimum and maximum of each component of the interleaved values
+func minMax(values []float32, components int) ([]float64, []float64) {
+	mins, maxs := make([]float64, components), make([]float64, components)
+	for c := range mins {
+		mins[c], maxs[c] = math.MaxFloat64, -math.MaxFloat64
+	}
+	for i, v := range values {
+		mins[i%components] = math.Min(mins[i%components], float64(v))
+		maxs[i%components] = math.Max(maxs[i%components], float64(v))
+	}
+	return mins, maxs
+}
+
+// generateExtJson returns the EXT_structural_metadata schema and property attributes, including the
+// additional point attributes. offsets holds, for each additional attribute, the offsets to add to the
+// stored values, or nil if the values are stored as they are
+func (e *GltfEncoder) generateExtJson(offsets [][]float64) string {
+	properties, propertyAttributes := "", ""
+	for j, a := range e.attributes {
+		name := attributePropertyName(a)
+		description, _ := json.Marshal(a.Description)
+		properties += fmt.Sprintf(`,"%s":{"description":%s,"type":"%s","componentType":"%s","required":true}`,
+			name, description, attributeType(a.Components), gltfComponentType(a.Type))
+		offset := ""
+		if offsets[j] != nil {
+			var v any = offsets[j]
+			if a.Components == 1 {
+				v = offsets[j][0]
+			}
+			b, _ := json.Marshal(v)
+			offset = fmt.Sprintf(`,"offset":%s`, b)
+		}
+		propertyAttributes += fmt.Sprintf(`,"%s":{"attribute":"_%s"%s}`, name, strings.ToUpper(name), offset)
+	}
+	return fmt.Sprintf(extJson, properties, propertyAttributes)
+}
+
+// gltfComponentType returns the metadata component type used to store values of the given type.
+// 8 bit values are stored as 16 bit values, as done for intensity and classification, while
+// 32 and 64 bit values are stored as 32 bit floats as integer vertex attributes of such sizes are not allowed.
+func gltfComponentType(t geom.AttributeType) string {
+	switch t {
+	case geom.AttributeInt8, geom.AttributeInt16:
+		return "INT16"
+	case geom.AttributeUint8, geom.AttributeUint16:
+		return "UINT16"
+	}
+	return "FLOAT32"
+}
+
+// gltfAttributeData converts the values of an attribute into a slice of the type matching its component type
+func gltfAttributeData(a geom.Attribute, values []float64) any {
+	switch gltfComponentType(a.Type) {
+	case "INT16":
+		return toComponents[int16](values, a.Components)
+	case "UINT16":
+		return toComponents[uint16](values, a.Components)
+	}
+	return toComponents[float32](values, a.Components)
+}
+
+// subtractMinimum subtracts from the interleaved values of each component their minimum, returning the minimums
+func subtractMinimum(values []float64, components int) []float64 {
+	mins := make([]float64, components)
+	for c := range mins {
+		mins[c] = math.Inf(1)
+	}
+	for i, v := range values {
+		mins[i%components] = math.Min(mins[i%components], v)
+	}
+	for c := range mins {
+		if math.IsInf(mins[c], 1) {
+			mins[c] = 0
+		}
+	}
+	for i := range values {
+		values[i] -= mins[i%components]
+	}
+	return mins
+}
+
+func toComponents[T int16 | uint16 | float32](values []float64, components int) any {
+	switch components {
+	case 2:
+		out := make([][2]T, len(values)/2)
+		for i := range out {
+			out[i] = [2]T{T(values[2*i]), T(values[2*i+1])}
+		}
+		return out
+	case 3:
+		out := make([][3]T, len(values)/3)
+		for i := range out {
+			out[i] = [3]T{T(values[3*i]), T(values[3*i+1]), T(values[3*i+2])}
+		}
+		return out
+	}
+	out := make([]T, len(values))
+	for i, v := range values {
+		out[i] = T(v)
+	}
+	return out
 }
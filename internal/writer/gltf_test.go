@@ -0,0 +1,161 @@
+package writer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/version"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+func TestDequantizationMatrix(t *testing.T) {
+	actual := dequantizationMatrix([3]float64{10, 20, 30}, [3]float64{2, 3, 4})
+	expected := [16]float64{2, 0, 0, 0, 0, 0, -3, 0, 0, 4, 0, 0, 10, 30, -20, 1}
+	if actual != expected {
+		t.Errorf("expected matrix %v got %v", expected, actual)
+	}
+}
+
+func TestQuantizePositions(t *testing.T) {
+	coords := [][3]float32{{0, 0, 5}, {2, 1, 5}, {4, 2, 5}, {5, -1, 5}}
+	quantized, offset, scale := quantizePositions(coords, geom.NewBoundingBox(0, 4, 0, 2, 5, 5))
+	if offset != [3]float64{2, 1, 5} {
+		t.Errorf("unexpected offset %v", offset)
+	}
+	if expected := [3]float64{2.0 / math.MaxInt16, 1.0 / math.MaxInt16, 1}; scale != expected {
+		t.Errorf("expected scale %v got %v", expected, scale)
+	}
+	// points outside of the bounds are clamped
+	expected := [][3]int16{{-32767, -32767, 0}, {0, 0, 0}, {32767, 32767, 0}, {32767, -32767, 0}}
+	for i := range expected {
+		if quantized[i] != expected[i] {
+			t.Errorf("expected point %d to be %v got %v", i, expected[i], quantized[i])
+		}
+	}
+}
+
+func TestGltfEncoderQuantized(t *testing.T) {
+	tmp := t.TempDir()
+	node := attributesTestNode()
+	node.Bounds = geom.NewBoundingBox(0, 2, 0, 2, 0, 4)
+	e := NewGltfEncoder(WithGltfAttributes(testAttributes), WithGltfQuantizedPositions(true))
+	if err := writeContentFile(e, node, tmp); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	doc, err := gltf.Open(filepath.Join(tmp, "content.glb"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(doc.ExtensionsRequired) != 1 || doc.ExtensionsRequired[0] != "KHR_mesh_quantization" {
+		t.Errorf("expected KHR_mesh_quantization to be required, got %v", doc.ExtensionsRequired)
+	}
+	acr := doc.Accessors[doc.Meshes[0].Primitives[0].Attributes[gltf.POSITION]]
+	if acr.ComponentType != gltf.ComponentShort || acr.Normalized {
+		t.Errorf("expected unnormalized short positions, got %+v", acr)
+	}
+	if acr.Min[0] != -32767 || acr.Max[0] != 32767 || acr.Min[2] != -32767 || acr.Max[2] != 0 {
+		t.Errorf("unexpected position bounds %v %v", acr.Min, acr.Max)
+	}
+	data, err := modeler.ReadAccessor(doc, acr, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// the node matrix restores the original coordinates in the Y up space
+	m := doc.Nodes[0].Matrix
+	for i, q := range data.([][3]int16) {
+		x := m[0]*float64(q[0]) + m[4]*float64(q[1]) + m[8]*float64(q[2]) + m[12]
+		y := m[1]*float64(q[0]) + m[5]*float64(q[1]) + m[9]*float64(q[2]) + m[13]
+		z := m[2]*float64(q[0]) + m[6]*float64(q[1]) + m[10]*float64(q[2]) + m[14]
+		// the original point is (i, i, i), rotated to (i, i, -i)
+		if math.Abs(x-float64(i)) > 1e-4 || math.Abs(y-float64(i)) > 1e-4 || math.Abs(z+float64(i)) > 1e-4 {
+			t.Errorf("expected point %d to be restored to %v got %v", i, [3]int{i, i, -i}, [3]float64{x, y, z})
+		}
+	}
+}
+
+func TestGltfEncoderMeshopt(t *testing.T) {
+	tmp := t.TempDir()
+	e := NewGltfEncoder(WithGltfAttributes(testAttributes), WithGltfMeshopt(true))
+	if err := writeContentFile(e, attributesTestNode(), tmp); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(tmp, "content.glb"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// the fallback buffer has no uri, hence the glb is parsed by hand
+	jsonLen := binary.LittleEndian.Uint32(b[12:])
+	doc := &gltf.Document{}
+	if err := json.Unmarshal(b[20:20+jsonLen], doc); err != nil {
+		t.Fatalf("unable to decode the gltf json: %v", err)
+	}
+	bin := b[20+jsonLen+8:]
+	if len(doc.ExtensionsRequired) != 1 || doc.ExtensionsRequired[0] != "EXT_meshopt_compression" {
+		t.Errorf("expected EXT_meshopt_compression to be required, got %v", doc.ExtensionsRequired)
+	}
+	if len(doc.Buffers) != 2 || doc.Buffers[1].URI != "" || doc.Buffers[1].Extensions["EXT_meshopt_compression"] == nil {
+		t.Fatalf("expected a fallback buffer, got %+v", doc.Buffers)
+	}
+	primitive := doc.Meshes[0].Primitives[0]
+	if len(primitive.Attributes) != 8 || len(doc.BufferViews) != 8 {
+		t.Fatalf("expected 8 attributes in their own buffer view, got %d attributes and %d buffer views", len(primitive.Attributes), len(doc.BufferViews))
+	}
+	expectedStrides := map[string]uint32{
+		gltf.POSITION: 12, gltf.COLOR_0: 4, "_INTENSITY": 4, "_CLASSIFICATION": 4,
+		"_FLAGS": 4, "_TIME": 4, "_COLORS": 8, "_EXTRA_INTENSITY": 4,
+	}
+	fallbackLen := uint32(0)
+	for name, stride := range expectedStrides {
+		acr := doc.Accessors[primitive.Attributes[name]]
+		bv := doc.BufferViews[*acr.BufferView]
+		if bv.Buffer != 1 || bv.ByteStride != stride || bv.ByteLength != 3*stride || acr.Count != 3 {
+			t.Errorf("unexpected buffer view %+v for attribute %s", bv, name)
+		}
+		fallbackLen += bv.ByteLength
+		ext := struct {
+			Buffer     uint32 `json:"buffer"`
+			ByteOffset uint32 `json:"byteOffset"`
+			ByteLength uint32 `json:"byteLength"`
+			ByteStride uint32 `json:"byteStride"`
+			Mode       string `json:"mode"`
+			Count      uint32 `json:"count"`
+		}{}
+		raw, _ := json.Marshal(bv.Extensions["EXT_meshopt_compression"])
+		if err := json.Unmarshal(raw, &ext); err != nil {
+			t.Fatalf("unable to decode EXT_meshopt_compression: %v", err)
+		}
+		if ext.Buffer != 0 || ext.ByteStride != stride || ext.Mode != "ATTRIBUTES" || ext.Count != 3 || ext.ByteOffset%4 != 0 {
+			t.Errorf("unexpected extension %+v for attribute %s", ext, name)
+		}
+		// the encoded data starts with the vertex codec header and ends with the first vertex
+		if data := bin[ext.ByteOffset : ext.ByteOffset+ext.ByteLength]; data[0] != 0xa0 || len(data) < 32 {
+			t.Errorf("unexpected encoded data for attribute %s", name)
+		}
+	}
+	if doc.Buffers[1].ByteLength != fallbackLen {
+		t.Errorf("expected fallback buffer length %d got %d", fallbackLen, doc.Buffers[1].ByteLength)
+	}
+	if acr := doc.Accessors[primitive.Attributes[gltf.COLOR_0]]; !acr.Normalized {
+		t.Errorf("expected normalized colors")
+	}
+	if acr := doc.Accessors[primitive.Attributes[gltf.POSITION]]; acr.Min[0] != 0 || acr.Max[2] != 2 {
+		t.Errorf("unexpected position bounds %v %v", acr.Min, acr.Max)
+	}
+}
+
+func TestWriterMeshopt(t *testing.T) {
+	w, err := NewWriter("base", WithQuantizedPositions(true), WithMeshopt(true))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	c := w.consumerFunc(version.TilesetVersion_1_1, nil)
+	if e := c.(*StandardConsumer).encoder.(*GltfEncoder); !e.quantize || !e.meshopt {
+		t.Errorf("expected quantized positions and meshopt compression in the gltf encoder")
+	}
+}
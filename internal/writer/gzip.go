@@ -0,0 +1,97 @@
+package writer
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+// GzipMode defines if and how the files of the tilesets are gzip compressed
+type GzipMode int
+
+const (
+	// GzipNone writes the files uncompressed
+	GzipNone GzipMode = iota
+	// GzipReplace writes the compressed files in place of the original ones, with the same name, to be served
+	// with the Content-Encoding: gzip header
+	GzipReplace
+	// GzipSidecar writes the compressed files alongside the original ones, adding the .gz extension
+	GzipSidecar
+)
+
+const (
+	gzipEncoding  = "gzip"
+	gzipExtension = ".gz"
+)
+
+// encodedSink is implemented by the sinks able to record the content encoding of the files they store
+type encodedSink interface {
+	CreateWithEncoding(path string, encoding string) (io.WriteCloser, error)
+}
+
+// gzipSink compresses the files written to the wrapped sink
+type gzipSink struct {
+	Sink
+	mode GzipMode
+}
+
+func newGzipSink(s Sink, mode GzipMode) Sink {
+	return &gzipSink{Sink: s, mode: mode}
+}
+
+func (s *gzipSink) Create(path string) (io.WriteCloser, error) {
+	if s.mode == GzipSidecar {
+		original, err := s.Sink.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		compressed, err := s.Sink.Create(path + gzipExtension)
+		if err != nil {
+			original.Close()
+			return nil, err
+		}
+		return newGzipWriter(compressed, original), nil
+	}
+	var f io.WriteCloser
+	var err error
+	if es, ok := s.Sink.(encodedSink); ok {
+		f, err = es.CreateWithEncoding(path, gzipEncoding)
+	} else {
+		f, err = s.Sink.Create(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newGzipWriter(f, nil), nil
+}
+
+// gzipWriter compresses the data written to a file, optionally writing it uncompressed to another one
+type gzipWriter struct {
+	zw         *gzip.Writer
+	compressed io.WriteCloser
+	original   io.WriteCloser
+	w          io.Writer
+}
+
+func newGzipWriter(compressed io.WriteCloser, original io.WriteCloser) *gzipWriter {
+	// BestCompression never fails for a valid level
+	zw, _ := gzip.NewWriterLevel(compressed, gzip.BestCompression)
+	g := &gzipWriter{zw: zw, compressed: compressed, original: original, w: zw}
+	if original != nil {
+		g.w = io.MultiWriter(original, zw)
+	}
+	return g
+}
+
+func (g *gzipWriter) Write(p []byte) (int, error) {
+	return g.w.Write(p)
+}
+
+// Close flushes the compressed stream and closes the files
+func (g *gzipWriter) Close() error {
+	errs := []error{g.zw.Close(), g.compressed.Close()}
+	if g.original != nil {
+		errs = append(errs, g.original.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,98 @@
+package writer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
+)
+
+func gunzip(t *testing.T, data []byte) []byte {
+	t.Helper()
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unable to read the gzip stream: %v", err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unable to read the gzip stream: %v", err)
+	}
+	return out
+}
+
+func TestWriterGzipReplace(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	w, err := NewWriter("out", WithStorage(s), WithGzipCompression(GzipReplace), WithNumWorkers(2))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.Write(implicitTestTree(), "", context.TODO()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{
+		"out/1/3/content.pnts",
+		"out/1/content.pnts",
+		"out/1/tileset.json",
+		"out/6/content.pnts",
+		"out/content.pnts",
+		"out/tileset.json",
+	}
+	if actual := s.Files(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected files %v got %v", expected, actual)
+	}
+	for _, f := range expected {
+		if actual := s.Encoding(f); actual != "gzip" {
+			t.Errorf("expected gzip encoding for %s got %q", f, actual)
+		}
+	}
+	data, _ := s.Get("out/tileset.json")
+	tileset := Tileset{}
+	if err := json.Unmarshal(gunzip(t, data), &tileset); err != nil {
+		t.Errorf("unable to decode the tileset.json: %v", err)
+	}
+	data, _ = s.Get("out/content.pnts")
+	if magic := string(gunzip(t, data)[:4]); magic != "pnts" {
+		t.Errorf("unexpected content magic %s", magic)
+	}
+}
+
+func TestWriterGzipSidecar(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	w, err := NewWriter("out", WithStorage(s), WithGzipCompression(GzipSidecar))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.Write(implicitTestTree(), "", context.TODO()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(s.Files()) != 12 {
+		t.Errorf("expected 12 files got %v", s.Files())
+	}
+	for _, f := range []string{"out/tileset.json", "out/1/3/content.pnts"} {
+		original, ok := s.Get(f)
+		if !ok {
+			t.Fatalf("expected file %s to exist", f)
+		}
+		compressed, ok := s.Get(f + ".gz")
+		if !ok {
+			t.Fatalf("expected file %s.gz to exist", f)
+		}
+		if !bytes.Equal(gunzip(t, compressed), original) {
+			t.Errorf("expected %s.gz to be the compressed %s", f, f)
+		}
+		if s.Encoding(f) != "" || s.Encoding(f+".gz") != "" {
+			t.Errorf("expected no content encoding for sidecar files")
+		}
+	}
+}
+
+func TestWriterGzipArchive(t *testing.T) {
+	if _, err := NewWriter("out", WithArchive(true), WithGzipCompression(GzipReplace)); err == nil {
+		t.Errorf("expected error for gzip compressed archives")
+	}
+}
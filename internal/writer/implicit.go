@@ -0,0 +1,167 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"path"
+	"strconv"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
+)
+
+const (
+	implicitContentFolder = "content"
+	implicitSubtreeFolder = "subtrees"
+)
+
+// ImplicitTile holds the coordinates of a tile in the octree implicit tiling scheme
+type ImplicitTile struct {
+	Level int
+	X     int
+	Y     int
+	Z     int
+	// TilesetPath is the path of the folder where the tileset.json, the contents and the subtrees are written
+	TilesetPath string
+}
+
+// ContentPath returns the path of the folder where to write the content of the tile
+func (t ImplicitTile) ContentPath() string {
+	return path.Join(t.TilesetPath, implicitContentFolder, strconv.Itoa(t.Level), strconv.Itoa(t.X), strconv.Itoa(t.Y), strconv.Itoa(t.Z))
+}
+
+// SubtreePath returns the path of the subtree file of the subtree rooted at the tile
+func (t ImplicitTile) SubtreePath() string {
+	return path.Join(t.TilesetPath, implicitSubtreeFolder, strconv.Itoa(t.Level), strconv.Itoa(t.X), strconv.Itoa(t.Y), strconv.Itoa(t.Z)+".subtree")
+}
+
+// Child returns the coordinates of the child tile with the given octant index, which matches
+// the Morton order used by the implicit tiling scheme: x varies first, then y and finally z.
+func (t ImplicitTile) Child(i int) ImplicitTile {
+	return ImplicitTile{
+		Level:       t.Level + 1,
+		X:           2*t.X + i&1,
+		Y:           2*t.Y + (i>>1)&1,
+		Z:           2*t.Z + (i>>2)&1,
+		TilesetPath: t.TilesetPath,
+	}
+}
+
+// bitstream is an availability bitstream, with bits stored starting from the least significant one of each byte
+type bitstream []byte
+
+func newBitstream(bits int) bitstream {
+	return make(bitstream, (bits+7)/8)
+}
+
+func (b bitstream) set(i int) {
+	b[i/8] |= 1 << (i % 8)
+}
+
+// constant returns the value of the first n bits and true if they are all equal
+func (b bitstream) constant(n int) (int, bool) {
+	first := int(b[0] & 1)
+	for i := 1; i < n; i++ {
+		if int(b[i/8]>>(i%8)&1) != first {
+			return 0, false
+		}
+	}
+	return first, true
+}
+
+// tilesInLevels returns the number of tiles in the first levels of an octree
+func tilesInLevels(levels int) int {
+	return (1<<(3*levels) - 1) / 7
+}
+
+// generateSubtree returns the binary subtree file content for the subtree of the given levels rooted at the node
+func (c *StandardConsumer) generateSubtree(node tree.Node, levels int) ([]byte, error) {
+	tileBits, childBits := tilesInLevels(levels), 1<<(3*levels)
+	tiles, contents, children := newBitstream(tileBits), newBitstream(tileBits), newBitstream(childBits)
+	var visit func(n tree.Node, level int, index int)
+	visit = func(n tree.Node, level int, index int) {
+		if level == levels {
+			children.set(index)
+			return
+		}
+		tiles.set(tilesInLevels(level) + index)
+		if n.NumberOfPoints() > 0 {
+			contents.set(tilesInLevels(level) + index)
+		}
+		for i, child := range n.Children() {
+			if c.nodeContainsPoints(child) {
+				visit(child, level+1, 8*index+i)
+			}
+		}
+	}
+	visit(node, 0, 0)
+
+	subtree := Subtree{}
+	var body []byte
+	availability := func(b bitstream, n int) Availability {
+		if v, ok := b.constant(n); ok {
+			return Availability{Constant: &v}
+		}
+		subtree.BufferViews = append(subtree.BufferViews, SubtreeBufferView{Buffer: 0, ByteOffset: len(body), ByteLength: len(b)})
+		// buffer views must start at an 8 byte boundary
+		body = append(body, b...)
+		body = append(body, make([]byte, (8-len(body)%8)%8)...)
+		index := len(subtree.BufferViews) - 1
+		return Availability{Bitstream: &index}
+	}
+	subtree.TileAvailability = availability(tiles, tileBits)
+	subtree.ContentAvailability = []Availability{availability(contents, tileBits)}
+	subtree.ChildSubtreeAvailability = availability(children, childBits)
+	if len(body) > 0 {
+		subtree.Buffers = []SubtreeBuffer{{ByteLength: len(body)}}
+	}
+
+	jsonData, err := json.Marshal(subtree)
+	if err != nil {
+		return nil, err
+	}
+	// the binary body must start at an 8 byte boundary, the header is 24 bytes long
+	jsonData = append(jsonData, bytes.Repeat([]byte(" "), (8-len(jsonData)%8)%8)...)
+
+	out := bytes.NewBuffer(make([]byte, 0, 24+len(jsonData)+len(body)))
+	out.WriteString("subt")
+	binary.Write(out, binary.LittleEndian, uint32(1))
+	binary.Write(out, binary.LittleEndian, uint64(len(jsonData)))
+	binary.Write(out, binary.LittleEndian, uint64(len(body)))
+	out.Write(jsonData)
+	out.Write(body)
+	return out.Bytes(), nil
+}
+
+// availableLevels returns the number of levels of the tree rooted at the node
+func (c *StandardConsumer) availableLevels(node tree.Node) int {
+	levels := 0
+	for _, child := range node.Children() {
+		if c.nodeContainsPoints(child) {
+			levels = max(levels, c.availableLevels(child))
+		}
+	}
+	return levels + 1
+}
+
+// Generates the tileset.json content of the implicit tileset rooted at the given node
+func (c *StandardConsumer) generateImplicitTilesetJson(node tree.Node) ([]byte, error) {
+	tileset := ImplicitTileset{
+		Asset:          Asset{Version: c.encoder.TilesetVersion()},
+		GeometricError: node.GeometricError(),
+		Root: ImplicitRoot{
+			Content:        Content{path.Join(implicitContentFolder, "{level}", "{x}", "{y}", "{z}", c.encoder.Filename())},
+			BoundingVolume: BoundingVolume{Box: node.BoundingBox().AsCesiumBox()},
+			GeometricError: node.GeometricError(),
+			Refine:         "ADD",
+			Transform:      rootTransform(node),
+			ImplicitTiling: ImplicitTiling{
+				SubdivisionScheme: "OCTREE",
+				SubtreeLevels:     c.subtreeLevels,
+				AvailableLevels:   c.availableLevels(node),
+				Subtrees:          Subtrees{path.Join(implicitSubtreeFolder, "{level}", "{x}", "{y}", "{z}.subtree")},
+			},
+		},
+	}
+	return json.Marshal(tileset)
+}
@@ -0,0 +1,224 @@
+package writer
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
+	"github.com/mfbonfigli/gocesiumtiler/v2/version"
+)
+
+// implicitTestTree returns a tree whose root has children in the octants 1 and 6, the first
+// having in turn a child in the octant 3
+func implicitTestTree() *tree.MockNode {
+	node := func(total int, children [8]tree.Node) *tree.MockNode {
+		pt := &geom.LinkedPoint{Pt: geom.NewPoint(1, 2, 3, 4, 5, 6, 7, 8)}
+		return &tree.MockNode{
+			TotalNumPts: total,
+			Pts:         geom.NewLinkedPointStream(pt, 1),
+			ChildNodes:  children,
+			GeomError:   10,
+			Bounds:      geom.NewBoundingBox(0, 8, 0, 8, 0, 8),
+			Leaf:        children == [8]tree.Node{},
+		}
+	}
+	grandChild := node(1, [8]tree.Node{})
+	child1 := node(2, [8]tree.Node{3: grandChild})
+	child6 := node(1, [8]tree.Node{})
+	root := node(4, [8]tree.Node{1: child1, 6: child6})
+	root.Root = true
+	return root
+}
+
+// readSubtree decodes the json and the binary body of a subtree file
+func readSubtree(t *testing.T, data []byte) (Subtree, []byte) {
+	t.Helper()
+	if string(data[:4]) != "subt" || binary.LittleEndian.Uint32(data[4:]) != 1 {
+		t.Fatalf("unexpected subtree header %v", data[:8])
+	}
+	jsonLen := int(binary.LittleEndian.Uint64(data[8:]))
+	binLen := int(binary.LittleEndian.Uint64(data[16:]))
+	if (24+jsonLen)%8 != 0 || len(data) != 24+jsonLen+binLen {
+		t.Fatalf("unexpected subtree lengths %d %d for a file of %d bytes", jsonLen, binLen, len(data))
+	}
+	subtree := Subtree{}
+	if err := json.Unmarshal(data[24:24+jsonLen], &subtree); err != nil {
+		t.Fatalf("unable to decode the subtree json: %v", err)
+	}
+	return subtree, data[24+jsonLen:]
+}
+
+// bitstreamOf returns the bytes of the bitstream referenced by the availability
+func bitstreamOf(t *testing.T, s Subtree, body []byte, a Availability) []byte {
+	t.Helper()
+	if a.Bitstream == nil {
+		t.Fatalf("expected a bitstream availability got constant %v", *a.Constant)
+	}
+	bv := s.BufferViews[*a.Bitstream]
+	if bv.ByteOffset%8 != 0 {
+		t.Errorf("expected buffer view aligned to 8 bytes, got offset %d", bv.ByteOffset)
+	}
+	return body[bv.ByteOffset : bv.ByteOffset+bv.ByteLength]
+}
+
+func TestImplicitTileChild(t *testing.T) {
+	tile := ImplicitTile{Level: 1, X: 1, Y: 0, Z: 1, TilesetPath: "base"}
+	expected := map[int]ImplicitTile{
+		0: {Level: 2, X: 2, Y: 0, Z: 2, TilesetPath: "base"},
+		3: {Level: 2, X: 3, Y: 1, Z: 2, TilesetPath: "base"},
+		6: {Level: 2, X: 2, Y: 1, Z: 3, TilesetPath: "base"},
+	}
+	for i, e := range expected {
+		if actual := tile.Child(i); actual != e {
+			t.Errorf("expected child %d to be %v got %v", i, e, actual)
+		}
+	}
+	if actual := tile.ContentPath(); actual != "base/content/1/1/0/1" {
+		t.Errorf("unexpected content path %s", actual)
+	}
+	if actual := tile.SubtreePath(); actual != "base/subtrees/1/1/0/1.subtree" {
+		t.Errorf("unexpected subtree path %s", actual)
+	}
+}
+
+func TestGenerateSubtree(t *testing.T) {
+	c := &StandardConsumer{encoder: NewGltfEncoder()}
+	data, err := c.generateSubtree(implicitTestTree(), 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	subtree, body := readSubtree(t, data)
+	if len(subtree.Buffers) != 1 || subtree.Buffers[0].ByteLength != len(body) {
+		t.Errorf("unexpected buffers %v for a body of %d bytes", subtree.Buffers, len(body))
+	}
+	// root at bit 0, children at bits 1+1 and 1+6
+	if actual := bitstreamOf(t, subtree, body, subtree.TileAvailability); !reflect.DeepEqual(actual, []byte{0x85, 0}) {
+		t.Errorf("unexpected tile availability %08b", actual)
+	}
+	if actual := bitstreamOf(t, subtree, body, subtree.ContentAvailability[0]); !reflect.DeepEqual(actual, []byte{0x85, 0}) {
+		t.Errorf("unexpected content availability %08b", actual)
+	}
+	// the grand child is the root of the child subtree 8*1+3
+	expected := make([]byte, 8)
+	expected[1] = 0x08
+	if actual := bitstreamOf(t, subtree, body, subtree.ChildSubtreeAvailability); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected child subtree availability %08b", actual)
+	}
+
+	// a subtree of 3 levels includes all the tiles
+	data, err = c.generateSubtree(implicitTestTree(), 3)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	subtree, _ = readSubtree(t, data)
+	if a := subtree.ChildSubtreeAvailability; a.Constant == nil || *a.Constant != 0 {
+		t.Errorf("expected constant child subtree availability 0, got %+v", a)
+	}
+	if len(subtree.BufferViews) != 2 {
+		t.Errorf("expected 2 buffer views got %d", len(subtree.BufferViews))
+	}
+
+	// a single tile subtree has constant availabilities
+	data, err = c.generateSubtree(implicitTestTree(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	subtree, body = readSubtree(t, data)
+	if a := subtree.TileAvailability; a.Constant == nil || *a.Constant != 1 {
+		t.Errorf("expected constant tile availability 1, got %+v", a)
+	}
+	if len(subtree.Buffers) != 1 || len(body) != 8 {
+		t.Errorf("expected only the child subtree bitstream, got %v", subtree.Buffers)
+	}
+}
+
+func TestConsumeImplicit(t *testing.T) {
+	tmp := t.TempDir()
+	root := implicitTestTree()
+	p := NewImplicitProducer(tmp, "tileset")
+	c := NewStandardConsumer(WithGeometryEncoder(NewGltfEncoder()), WithSubtreeLevels(2))
+	wc := make(chan *WorkUnit, 10)
+	ec := make(chan error, 10)
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	p.Produce(wc, ec, wg, root, context.TODO())
+	c.Consume(wc, ec, wg)
+	wg.Wait()
+	close(ec)
+	for err := range ec {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	base := filepath.Join(tmp, "tileset")
+	for _, f := range []string{
+		"content/0/0/0/0/content.glb",
+		"content/1/1/0/0/content.glb",
+		"content/1/0/1/1/content.glb",
+		"content/2/3/1/0/content.glb",
+		"subtrees/0/0/0/0.subtree",
+		"subtrees/2/3/1/0.subtree",
+	} {
+		if _, err := os.Stat(filepath.Join(base, f)); err != nil {
+			t.Errorf("expected file %s to exist: %v", f, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(base, "subtrees/1/1/0/0.subtree")); err == nil {
+		t.Errorf("expected no subtree file for level 1")
+	}
+
+	b, err := os.ReadFile(filepath.Join(base, "tileset.json"))
+	if err != nil {
+		t.Fatalf("unable to read tileset.json: %v", err)
+	}
+	tileset := ImplicitTileset{}
+	if err := json.Unmarshal(b, &tileset); err != nil {
+		t.Fatalf("unable to decode tileset.json: %v", err)
+	}
+	expected := ImplicitTiling{
+		SubdivisionScheme: "OCTREE",
+		SubtreeLevels:     2,
+		AvailableLevels:   3,
+		Subtrees:          Subtrees{Url: "subtrees/{level}/{x}/{y}/{z}.subtree"},
+	}
+	if tileset.Root.ImplicitTiling != expected {
+		t.Errorf("expected implicit tiling %v got %v", expected, tileset.Root.ImplicitTiling)
+	}
+	if tileset.Root.Content.Url != "content/{level}/{x}/{y}/{z}/content.glb" || tileset.Asset.Version != version.TilesetVersion_1_1 {
+		t.Errorf("unexpected tileset %s", b)
+	}
+}
+
+func TestConsumeImplicitWithoutSubtreeLevels(t *testing.T) {
+	c := NewStandardConsumer(WithGeometryEncoder(NewGltfEncoder())).(*StandardConsumer)
+	err := c.doWork(&WorkUnit{
+		Node:     implicitTestTree(),
+		BasePath: t.TempDir(),
+		Implicit: &ImplicitTile{},
+	})
+	if err == nil {
+		t.Errorf("expected error got none")
+	}
+}
+
+func TestWriterImplicit(t *testing.T) {
+	if _, err := NewWriter("base", WithImplicitTiling(3)); err == nil {
+		t.Errorf("expected error for implicit tiling with version 1.0")
+	}
+	w, err := NewWriter("base", WithImplicitTiling(3), WithTilesetVersion(version.TilesetVersion_1_1))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if p := w.producerFunc("base", "folder").(*StandardProducer); !p.implicit {
+		t.Errorf("expected an implicit producer")
+	}
+	if c := w.consumerFunc(version.TilesetVersion_1_1, nil).(*StandardConsumer); c.subtreeLevels != 3 {
+		t.Errorf("expected 3 subtree levels got %d", c.subtreeLevels)
+	}
+}
@@ -2,12 +2,14 @@ package writer
 
 import (
 	"bufio"
+	"encoding/binary"
 	"fmt"
 	"io"
-	"os"
-	"path"
+	"math"
+	"strconv"
 	"strings"
 
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/draco"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/utils"
@@ -15,7 +17,27 @@ import (
 )
 
 // PntsEncoder writes a node data as Pnts file (3D Tiles 1.0 specs)
-type PntsEncoder struct{}
+// Additional point attributes, if any, are stored in the batch table. If Draco compression is enabled positions,
+// colors, intensities and classifications are compressed using the 3DTILES_draco_point_compression extension.
+// Otherwise positions can optionally be stored as POSITION_QUANTIZED and colors as RGB565 to reduce the tile size.
+type PntsEncoder struct {
+	attributes        []geom.Attribute
+	draco             *DracoOptions
+	quantizePositions bool
+	rgb565            bool
+}
+
+// pntsAttribute describes how an additional attribute is stored in the batch table binary body
+type pntsAttribute struct {
+	name          string
+	componentType string
+	dataType      geom.AttributeType
+	components    int
+	// byteOffset is the offset of the attribute values in the batch table binary body
+	byteOffset int
+	// index is the position of the first value of the attribute in the point attribute values
+	index int
+}
 
 func (e *PntsEncoder) TilesetVersion() version.TilesetVersion {
 	return version.TilesetVersion_1_0
@@ -25,30 +47,64 @@ func (e *PntsEncoder) Filename() string {
 	return "content.pnts"
 }
 
-func NewPntsEncoder() *PntsEncoder {
-	return &PntsEncoder{}
+func NewPntsEncoder(opts ...func(*PntsEncoder)) *PntsEncoder {
+	e := &PntsEncoder{}
+	for _, optFn := range opts {
+		optFn(e)
+	}
+	return e
+}
+
+// WithPntsAttributes sets the additional point attributes to store in the batch table
+func WithPntsAttributes(attributes []geom.Attribute) func(*PntsEncoder) {
+	return func(e *PntsEncoder) {
+		e.attributes = attributes
+	}
+}
+
+// WithPntsDraco enables the Draco compression of the points using the given options
+func WithPntsDraco(opts DracoOptions) func(*PntsEncoder) {
+	return func(e *PntsEncoder) {
+		e.draco = &opts
+	}
+}
+
+// WithPntsQuantizedPositions stores the point coordinates as POSITION_QUANTIZED, 16 bit integers relative
+// to the bounding box of the node. Ignored if Draco compression is enabled.
+func WithPntsQuantizedPositions(quantize bool) func(*PntsEncoder) {
+	return func(e *PntsEncoder) {
+		e.quantizePositions = quantize
+	}
+}
+
+// WithPntsRGB565 stores the point colors as RGB565, 16 bits per point. Ignored if Draco compression is enabled.
+func WithPntsRGB565(rgb565 bool) func(*PntsEncoder) {
+	return func(e *PntsEncoder) {
+		e.rgb565 = rgb565
+	}
 }
 
-func (e *PntsEncoder) Write(node tree.Node, folderPath string) error {
+func (e *PntsEncoder) Write(node tree.Node, out io.Writer) error {
 	pts := node.Points()
+	if e.draco != nil {
+		return e.writeDraco(pts, out)
+	}
+	bounds := node.BoundingBox()
 
 	// Feature table
-	featureTableBytes, featureTableLen := e.generateFeatureTable(pts.Len())
+	featureTableBytes, featureTableLen := e.generateFeatureTable(pts.Len(), bounds)
+	featureTableDataLen := pts.Len() * (e.positionSize() + e.colorSize())
+	featureTableBinaryLen := featureTableDataLen + (8-featureTableDataLen%8)%8
 
 	// Batch table
-	batchTableBytes, batchTableLen := e.generateBatchTable(pts.Len())
-
-	// Write binary content to file
-	pntsFilePath := path.Join(folderPath, e.Filename())
-	f, err := os.Create(pntsFilePath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+	layout, batchTableBinaryLen := e.batchTableLayout(pts.Len(), 2*pts.Len())
+	batchTableOffset := 28 + featureTableLen + featureTableBinaryLen
+	batchTableBytes, batchTableLen := e.generateBatchTable(pts.Len(), batchTableOffset, layout)
 
-	wr := bufio.NewWriter(f)
+	// Write binary content
+	wr := bufio.NewWriter(out)
 
-	err = e.writePntsHeader(pts.Len(), featureTableLen, batchTableLen, wr)
+	err := e.writePntsHeader(featureTableLen, featureTableBinaryLen, batchTableLen, batchTableBinaryLen, wr)
 	if err != nil {
 		return err
 	}
@@ -57,7 +113,7 @@ func (e *PntsEncoder) Write(node tree.Node, folderPath string) error {
 		return err
 	}
 
-	err = e.writePointCoords(pts, wr)
+	err = e.writePointCoords(pts, bounds, wr)
 	if err != nil {
 		return err
 	}
@@ -67,6 +123,12 @@ func (e *PntsEncoder) Write(node tree.Node, folderPath string) error {
 		return err
 	}
 
+	// the binary body must end at an 8 byte boundary
+	_, err = wr.Write(make([]byte, featureTableBinaryLen-featureTableDataLen))
+	if err != nil {
+		return err
+	}
+
 	err = e.writeTable(batchTableBytes, wr)
 	if err != nil {
 		return err
@@ -82,6 +144,11 @@ func (e *PntsEncoder) Write(node tree.Node, folderPath string) error {
 		return err
 	}
 
+	err = e.writePointAttributes(pts, layout, 2*pts.Len(), batchTableBinaryLen, wr)
+	if err != nil {
+		return err
+	}
+
 	err = wr.Flush()
 	if err != nil {
 		return err
@@ -90,19 +157,99 @@ func (e *PntsEncoder) Write(node tree.Node, folderPath string) error {
 	return nil
 }
 
-func (e *PntsEncoder) generateFeatureTable(numPoints int) ([]byte, int) {
-	featureTableStr := e.generateFeatureTableJsonContent(numPoints, 0)
+// writeDraco writes the pnts file compressing positions, colors, intensities and classifications with Draco.
+// The additional attributes, if any, are stored uncompressed in the batch table binary body.
+func (e *PntsEncoder) writeDraco(pts geom.PointList, out io.Writer) error {
+	sorted, err := mortonSortedPoints(pts)
+	if err != nil {
+		return err
+	}
+	data, err := e.encodeDraco(sorted)
+	if err != nil {
+		return err
+	}
+
+	// Feature table, the binary body only contains the draco data
+	featureTableBytes := []byte(e.generateDracoFeatureTableJsonContent(sorted.Len(), len(data), 0))
+	featureTableBinaryLen := len(data) + (8-len(data)%8)%8
+
+	// Batch table
+	layout, batchTableBinaryLen := e.batchTableLayout(sorted.Len(), 0)
+	batchTableOffset := 28 + len(featureTableBytes) + featureTableBinaryLen
+	batchTableBytes, batchTableLen := e.generateBatchTable(sorted.Len(), batchTableOffset, layout)
+
+	wr := bufio.NewWriter(out)
+	if err := e.writePntsHeader(len(featureTableBytes), featureTableBinaryLen, batchTableLen, batchTableBinaryLen, wr); err != nil {
+		return err
+	}
+	if err := e.writeTable(featureTableBytes, wr); err != nil {
+		return err
+	}
+	if err := e.writeTable(append(data, make([]byte, featureTableBinaryLen-len(data))...), wr); err != nil {
+		return err
+	}
+	if err := e.writeTable(batchTableBytes, wr); err != nil {
+		return err
+	}
+	if err := e.writePointAttributes(sorted, layout, 0, batchTableBinaryLen, wr); err != nil {
+		return err
+	}
+	return wr.Flush()
+}
+
+// encodeDraco returns the Draco encoded positions, colors, intensities and classifications of the points,
+// stored as attributes with unique ids from 0 to 3
+func (e *PntsEncoder) encodeDraco(pts geom.PointList) ([]byte, error) {
+	n := pts.Len()
+	positions := make([]float32, 0, 3*n)
+	colors := make([]int32, 0, 3*n)
+	intensities := make([]int32, 0, n)
+	classifications := make([]int32, 0, n)
+	for i := 0; i < n; i++ {
+		pt, err := pts.Next()
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, pt.X, pt.Y, pt.Z)
+		colors = append(colors,
+			quantizeColor(pt.R, e.draco.ColorBits),
+			quantizeColor(pt.G, e.draco.ColorBits),
+			quantizeColor(pt.B, e.draco.ColorBits),
+		)
+		intensities = append(intensities, int32(pt.Intensity))
+		classifications = append(classifications, int32(pt.Classification))
+	}
+	pts.Reset()
+
+	enc := draco.NewEncoder(n)
+	if _, err := enc.AddFloatAttribute(draco.Position, 3, positions, e.draco.PositionBits); err != nil {
+		return nil, fmt.Errorf("unable to encode positions: %w", err)
+	}
+	if _, err := enc.AddIntAttribute(draco.Color, draco.Uint8, 3, true, colors); err != nil {
+		return nil, fmt.Errorf("unable to encode colors: %w", err)
+	}
+	if _, err := enc.AddIntAttribute(draco.Generic, draco.Uint8, 1, false, intensities); err != nil {
+		return nil, fmt.Errorf("unable to encode intensities: %w", err)
+	}
+	if _, err := enc.AddIntAttribute(draco.Generic, draco.Uint8, 1, false, classifications); err != nil {
+		return nil, fmt.Errorf("unable to encode classifications: %w", err)
+	}
+	return enc.Encode()
+}
+
+func (e *PntsEncoder) generateFeatureTable(numPoints int, bounds geom.BoundingBox) ([]byte, int) {
+	featureTableStr := e.generateFeatureTableJsonContent(numPoints, bounds, 0)
 	featureTableLen := len(featureTableStr)
 	return []byte(featureTableStr), featureTableLen
 }
 
-func (e *PntsEncoder) generateBatchTable(numPoints int) ([]byte, int) {
-	batchTableStr := e.generateBatchTableJsonContent(numPoints, 0)
+func (e *PntsEncoder) generateBatchTable(numPoints int, offset int, layout []pntsAttribute) ([]byte, int) {
+	batchTableStr := e.generateBatchTableJsonContent(numPoints, offset, layout, 0)
 	batchTableLen := len(batchTableStr)
 	return []byte(batchTableStr), batchTableLen
 }
 
-func (e *PntsEncoder) writePntsHeader(numPoints int, featureTableLen int, batchTableLen int, wr io.Writer) error {
+func (e *PntsEncoder) writePntsHeader(featureTableLen int, featureTableBinaryLen int, batchTableLen int, batchTableBinaryLen int, wr io.Writer) error {
 	_, err := wr.Write([]byte("pnts")) // magic
 	if err != nil {
 		return err
@@ -111,8 +258,7 @@ func (e *PntsEncoder) writePntsHeader(numPoints int, featureTableLen int, batchT
 	if err != nil {
 		return err
 	}
-	positionBytesLen := 4 * 3 * numPoints                                                  // 4 bytes per coordinate component (x,y,z) -> 12 bytes per point
-	err = utils.WriteIntAs4ByteNumber(28+featureTableLen+positionBytesLen+numPoints*3, wr) // numpoints*3 is colorbytes (1 byte per color component)
+	err = utils.WriteIntAs4ByteNumber(28+featureTableLen+featureTableBinaryLen+batchTableLen+batchTableBinaryLen, wr) // total byte length
 	if err != nil {
 		return err
 	}
@@ -120,7 +266,7 @@ func (e *PntsEncoder) writePntsHeader(numPoints int, featureTableLen int, batchT
 	if err != nil {
 		return err
 	}
-	err = utils.WriteIntAs4ByteNumber(positionBytesLen+numPoints*3, wr) // feature table binary length (position len + colors len)
+	err = utils.WriteIntAs4ByteNumber(featureTableBinaryLen, wr) // feature table binary length (position len + colors len or draco data)
 	if err != nil {
 		return err
 	}
@@ -128,7 +274,7 @@ func (e *PntsEncoder) writePntsHeader(numPoints int, featureTableLen int, batchT
 	if err != nil {
 		return err
 	}
-	err = utils.WriteIntAs4ByteNumber(2*numPoints, wr) // intensity + classification
+	err = utils.WriteIntAs4ByteNumber(batchTableBinaryLen, wr) // intensity + classification + additional attributes
 	if err != nil {
 		return err
 	}
@@ -143,14 +289,41 @@ func (e *PntsEncoder) writeTable(tableBytes []byte, wr io.Writer) error {
 	return nil
 }
 
-func (e *PntsEncoder) writePointCoords(pts geom.PointList, wr io.Writer) error {
+// positionSize returns the number of bytes used to store the coordinates of a point
+func (e *PntsEncoder) positionSize() int {
+	if e.quantizePositions {
+		return 6
+	}
+	return 12
+}
+
+// colorSize returns the number of bytes used to store the color of a point
+func (e *PntsEncoder) colorSize() int {
+	if e.rgb565 {
+		return 2
+	}
+	return 3
+}
+
+func (e *PntsEncoder) writePointCoords(pts geom.PointList, bounds geom.BoundingBox, wr io.Writer) error {
 	n := pts.Len()
+	offset, scale := quantizedVolume(bounds)
+	buf := make([]byte, 6)
 	// write coords
 	for i := 0; i < n; i++ {
 		pt, err := pts.Next()
 		if err != nil {
 			return err
 		}
+		if e.quantizePositions {
+			binary.LittleEndian.PutUint16(buf[0:], quantizePosition(pt.X, offset[0], scale[0]))
+			binary.LittleEndian.PutUint16(buf[2:], quantizePosition(pt.Y, offset[1], scale[1]))
+			binary.LittleEndian.PutUint16(buf[4:], quantizePosition(pt.Z, offset[2], scale[2]))
+			if _, err = wr.Write(buf); err != nil {
+				return err
+			}
+			continue
+		}
 		err = utils.WriteFloat32LittleEndian(pt.X, wr)
 		if err != nil {
 			return err
@@ -176,7 +349,11 @@ func (e *PntsEncoder) writePointColors(pts geom.PointList, wr io.Writer) error {
 		if err != nil {
 			return err
 		}
-		_, err = wr.Write([]byte{pt.R, pt.G, pt.B})
+		if e.rgb565 {
+			_, err = wr.Write(binary.LittleEndian.AppendUint16(nil, toRGB565(pt.R, pt.G, pt.B)))
+		} else {
+			_, err = wr.Write([]byte{pt.R, pt.G, pt.B})
+		}
 		if err != nil {
 			return err
 		}
@@ -219,29 +396,182 @@ func (e *PntsEncoder) writePointClassifications(pts geom.PointList, wr io.Writer
 	return nil
 }
 
-// Generates the json representation of the feature table
-func (e *PntsEncoder) generateFeatureTableJsonContent(pointNo int, spaceNo int) string {
-	s := fmt.Sprintf(`{"POINTS_LENGTH":%d,"POSITION":{"byteOffset":0},"RGB":{"byteOffset":%d}}%s`,
+// batchTableLayout computes how the additional attributes are stored in the batch table binary body, starting
+// at the given offset, and returns it together with the length of the binary body
+func (e *PntsEncoder) batchTableLayout(numPoints int, start int) ([]pntsAttribute, int) {
+	length := start
+	if len(e.attributes) == 0 {
+		// the binary body must end at an 8 byte boundary
+		return nil, length + (8-length%8)%8
+	}
+	layout := make([]pntsAttribute, len(e.attributes))
+	index := 0
+	for i, a := range e.attributes {
+		componentType, dataType := pntsComponentType(a.Type)
+		// align the values to their component size
+		length += (dataType.Size() - length%dataType.Size()) % dataType.Size()
+		layout[i] = pntsAttribute{
+			name:          attributePropertyName(a),
+			componentType: componentType,
+			dataType:      dataType,
+			components:    a.Components,
+			byteOffset:    length,
+			index:         index,
+		}
+		length += numPoints * a.Components * dataType.Size()
+		index += a.Components
+	}
+	// the binary body must end at an 8 byte boundary
+	length += (8 - length%8) % 8
+	return layout, length
+}
+
+// pntsComponentType returns the batch table component type used to store values of the given type, together
+// with the data type matching it. 64 bit integers are not supported by the batch table and are stored as doubles.
+func pntsComponentType(t geom.AttributeType) (string, geom.AttributeType) {
+	switch t {
+	case geom.AttributeInt8:
+		return "BYTE", t
+	case geom.AttributeUint8:
+		return "UNSIGNED_BYTE", t
+	case geom.AttributeInt16:
+		return "SHORT", t
+	case geom.AttributeUint16:
+		return "UNSIGNED_SHORT", t
+	case geom.AttributeInt32:
+		return "INT", t
+	case geom.AttributeUint32:
+		return "UNSIGNED_INT", t
+	case geom.AttributeFloat32:
+		return "FLOAT", t
+	}
+	return "DOUBLE", geom.AttributeFloat64
+}
+
+func (e *PntsEncoder) writePointAttributes(pts geom.PointList, layout []pntsAttribute, start int, batchTableBinaryLen int, wr io.Writer) error {
+	n := pts.Len()
+	written := start
+	for _, a := range layout {
+		// padding
+		if _, err := wr.Write(make([]byte, a.byteOffset-written)); err != nil {
+			return err
+		}
+		size := a.dataType.Size()
+		buf := make([]byte, a.components*size)
+		for i := 0; i < n; i++ {
+			if _, err := pts.Next(); err != nil {
+				return err
+			}
+			values := pts.Attributes()
+			for c := 0; c < a.components; c++ {
+				putAttributeValue(buf[c*size:], a.dataType, values[a.index+c])
+			}
+			if _, err := wr.Write(buf); err != nil {
+				return err
+			}
+		}
+		pts.Reset()
+		written = a.byteOffset + n*len(buf)
+	}
+	_, err := wr.Write(make([]byte, batchTableBinaryLen-written))
+	return err
+}
+
+// quantizedVolume returns the QUANTIZED_VOLUME_OFFSET and QUANTIZED_VOLUME_SCALE of the given bounding box
+func quantizedVolume(bounds geom.BoundingBox) ([3]float64, [3]float64) {
+	return [3]float64{bounds.Xmin, bounds.Ymin, bounds.Zmin},
+		[3]float64{bounds.Xmax - bounds.Xmin, bounds.Ymax - bounds.Ymin, bounds.Zmax - bounds.Zmin}
+}
+
+// quantizePosition maps the coordinate, expected in the range [offset, offset+scale], to a 16 bit integer.
+// Coordinates outside of the range are clamped to it.
+func quantizePosition(v float32, offset float64, scale float64) uint16 {
+	if scale <= 0 {
+		return 0
+	}
+	q := math.Round((float64(v) - offset) / scale * math.MaxUint16)
+	return uint16(math.Min(math.Max(q, 0), math.MaxUint16))
+}
+
+// toRGB565 packs the color in 16 bits, 5 for red, 6 for green and 5 for blue
+func toRGB565(r, g, b uint8) uint16 {
+	scale := func(c uint8, bits int) uint16 {
+		return uint16(math.Round(float64(c) * float64(int(1)<<bits-1) / 255))
+	}
+	return scale(r, 5)<<11 | scale(g, 6)<<5 | scale(b, 5)
+}
+
+// Generates the json representation of the feature table, padded so that the binary body starts at an 8 byte boundary
+func (e *PntsEncoder) generateFeatureTableJsonContent(pointNo int, bounds geom.BoundingBox, spaceNo int) string {
+	position := `"POSITION":{"byteOffset":0}`
+	if e.quantizePositions {
+		offset, scale := quantizedVolume(bounds)
+		position = fmt.Sprintf(`"POSITION_QUANTIZED":{"byteOffset":0},"QUANTIZED_VOLUME_OFFSET":[%s],"QUANTIZED_VOLUME_SCALE":[%s]`,
+			formatVector(offset),
+			formatVector(scale),
+		)
+	}
+	color := "RGB"
+	if e.rgb565 {
+		color = "RGB565"
+	}
+	s := fmt.Sprintf(`{"POINTS_LENGTH":%d,%s,"%s":{"byteOffset":%d}}%s`,
 		pointNo,
-		pointNo*12,
+		position,
+		color,
+		pointNo*e.positionSize(),
 		strings.Repeat(" ", spaceNo),
 	)
-	headerByteLength := len([]byte(s))
-	paddingSize := headerByteLength % 4
+	paddingSize := (28 + len([]byte(s))) % 8
 	if paddingSize != 0 {
-		return e.generateFeatureTableJsonContent(pointNo, 4-paddingSize)
+		return e.generateFeatureTableJsonContent(pointNo, bounds, 8-paddingSize)
 	}
 	return s
 }
 
-// Generates the json representation of the batch table
-func (e *PntsEncoder) generateBatchTableJsonContent(pointNumber, spaceNumber int) string {
+// formatVector returns the comma separated json representation of the vector components
+func formatVector(v [3]float64) string {
+	return strings.Join([]string{
+		strconv.FormatFloat(v[0], 'f', -1, 64),
+		strconv.FormatFloat(v[1], 'f', -1, 64),
+		strconv.FormatFloat(v[2], 'f', -1, 64),
+	}, ",")
+}
+
+// Generates the json representation of the feature table storing the positions and colors in the Draco data,
+// padded so that the binary body starts at an 8 byte boundary
+func (e *PntsEncoder) generateDracoFeatureTableJsonContent(pointNo int, dracoLen int, spaceNo int) string {
+	s := fmt.Sprintf(`{"POINTS_LENGTH":%d,"POSITION":{"byteOffset":0},"RGB":{"byteOffset":0},`+
+		`"extensions":{"3DTILES_draco_point_compression":{"properties":{"POSITION":0,"RGB":1},"byteOffset":0,"byteLength":%d}}}%s`,
+		pointNo,
+		dracoLen,
+		strings.Repeat(" ", spaceNo),
+	)
+	paddingSize := (28 + len([]byte(s))) % 8
+	if paddingSize != 0 {
+		return e.generateDracoFeatureTableJsonContent(pointNo, dracoLen, 8-paddingSize)
+	}
+	return s
+}
+
+// Generates the json representation of the batch table, padded so that the binary body, given the offset of the
+// json in the file, starts at an 8 byte boundary
+func (e *PntsEncoder) generateBatchTableJsonContent(pointNumber, offset int, layout []pntsAttribute, spaceNumber int) string {
+	attrs := ""
+	for _, a := range layout {
+		attrs += fmt.Sprintf(`,"%s":{"byteOffset":%d,"componentType":"%s","type":"%s"}`, a.name, a.byteOffset, a.componentType, attributeType(a.components))
+	}
+	classificationOffset := pointNumber
+	if e.draco != nil {
+		// intensity and classification are stored in the draco data of the feature table
+		classificationOffset = 0
+		attrs += `,"extensions":{"3DTILES_draco_point_compression":{"properties":{"INTENSITY":2,"CLASSIFICATION":3}}}`
+	}
 	s := fmt.Sprintf(`{"INTENSITY":{"byteOffset":0,"componentType":"UNSIGNED_BYTE","type":"SCALAR"},
-	"CLASSIFICATION":{"byteOffset":%d,"componentType":"UNSIGNED_BYTE","type":"SCALAR"}}%s`, 1*pointNumber, strings.Repeat(" ", spaceNumber))
-	headerByteLength := len([]byte(s))
-	paddingSize := headerByteLength % 4
+	"CLASSIFICATION":{"byteOffset":%d,"componentType":"UNSIGNED_BYTE","type":"SCALAR"}%s}%s`, classificationOffset, attrs, strings.Repeat(" ", spaceNumber))
+	paddingSize := (offset + len([]byte(s))) % 8
 	if paddingSize != 0 {
-		return e.generateBatchTableJsonContent(pointNumber, 4-paddingSize)
+		return e.generateBatchTableJsonContent(pointNumber, offset, layout, 8-paddingSize)
 	}
 	return s
 }
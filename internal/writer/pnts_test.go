@@ -0,0 +1,171 @@
+package writer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+	"github.com/mfbonfigli/gocesiumtiler/v2/version"
+)
+
+func TestQuantizePosition(t *testing.T) {
+	tests := []struct {
+		v        float32
+		offset   float64
+		scale    float64
+		expected uint16
+	}{
+		{v: 0, offset: 0, scale: 4, expected: 0},
+		{v: 1, offset: 0, scale: 4, expected: 16384},
+		{v: 4, offset: 0, scale: 4, expected: 65535},
+		{v: -1, offset: 0, scale: 4, expected: 0},
+		{v: 5, offset: 0, scale: 4, expected: 65535},
+		{v: 3, offset: 3, scale: 0, expected: 0},
+	}
+	for _, tc := range tests {
+		if actual := quantizePosition(tc.v, tc.offset, tc.scale); actual != tc.expected {
+			t.Errorf("expected %d for %v in [%v, %v], got %d", tc.expected, tc.v, tc.offset, tc.offset+tc.scale, actual)
+		}
+	}
+}
+
+func TestToRGB565(t *testing.T) {
+	tests := []struct {
+		r, g, b  uint8
+		expected uint16
+	}{
+		{expected: 0},
+		{r: 255, g: 255, b: 255, expected: 0xffff},
+		{r: 255, expected: 0xf800},
+		{g: 255, expected: 0x07e0},
+		{b: 255, expected: 0x001f},
+		{r: 128, g: 128, b: 128, expected: 16<<11 | 32<<5 | 16},
+	}
+	for _, tc := range tests {
+		if actual := toRGB565(tc.r, tc.g, tc.b); actual != tc.expected {
+			t.Errorf("expected %#04x for %d,%d,%d got %#04x", tc.expected, tc.r, tc.g, tc.b, actual)
+		}
+	}
+}
+
+func TestPntsEncoderQuantized(t *testing.T) {
+	pts := []model.Point{
+		{X: 0, Y: 0, Z: 0, R: 255, G: 0, B: 0, Intensity: 1, Classification: 2},
+		{X: 1, Y: 1, Z: 1, R: 0, G: 255, B: 0, Intensity: 3, Classification: 4},
+		{X: 2, Y: 2, Z: 2, R: 0, G: 0, B: 255, Intensity: 5, Classification: 6},
+	}
+	pt3 := &geom.LinkedPoint{Pt: pts[2]}
+	pt2 := &geom.LinkedPoint{Pt: pts[1], Next: pt3}
+	pt1 := &geom.LinkedPoint{Pt: pts[0], Next: pt2}
+	node := &tree.MockNode{
+		TotalNumPts: 3,
+		Pts:         geom.NewLinkedPointStream(pt1, 3),
+		Bounds:      geom.NewBoundingBox(0, 4, 0, 2, -2, 2),
+	}
+
+	tmp := t.TempDir()
+	e := NewPntsEncoder(WithPntsQuantizedPositions(true), WithPntsRGB565(true))
+	if err := writeContentFile(e, node, tmp); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(tmp, "content.pnts"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	ftLen := int(binary.LittleEndian.Uint32(b[12:]))
+	ftBinLen := int(binary.LittleEndian.Uint32(b[16:]))
+	btLen := int(binary.LittleEndian.Uint32(b[20:]))
+	btBinLen := int(binary.LittleEndian.Uint32(b[24:]))
+	if ftBinLen != 3*8 {
+		t.Errorf("expected feature table binary length %d got %d", 3*8, ftBinLen)
+	}
+	if expected := 28 + ftLen + ftBinLen + btLen + btBinLen; len(b) != expected {
+		t.Fatalf("expected file length %d got %d", expected, len(b))
+	}
+
+	ft := struct {
+		PointsLength int                       `json:"POINTS_LENGTH"`
+		Position     *struct{ ByteOffset int } `json:"POSITION"`
+		Quantized    struct {
+			ByteOffset int `json:"byteOffset"`
+		} `json:"POSITION_QUANTIZED"`
+		Offset []float64 `json:"QUANTIZED_VOLUME_OFFSET"`
+		Scale  []float64 `json:"QUANTIZED_VOLUME_SCALE"`
+		RGB565 struct {
+			ByteOffset int `json:"byteOffset"`
+		} `json:"RGB565"`
+	}{}
+	if err := json.Unmarshal(b[28:28+ftLen], &ft); err != nil {
+		t.Fatalf("unable to decode feature table: %v", err)
+	}
+	if ft.PointsLength != 3 || ft.Position != nil || ft.Quantized.ByteOffset != 0 || ft.RGB565.ByteOffset != 18 {
+		t.Errorf("unexpected feature table %s", b[28:28+ftLen])
+	}
+	if !reflect.DeepEqual(ft.Offset, []float64{0, 0, -2}) || !reflect.DeepEqual(ft.Scale, []float64{4, 2, 4}) {
+		t.Errorf("unexpected quantized volume offset %v scale %v", ft.Offset, ft.Scale)
+	}
+
+	body := b[28+ftLen:]
+	expectedPositions := []uint16{
+		0, 0, 32768,
+		16384, 32768, 49151,
+		32768, 65535, 65535,
+	}
+	for i, expected := range expectedPositions {
+		if actual := binary.LittleEndian.Uint16(body[2*i:]); actual != expected {
+			t.Errorf("expected quantized coordinate %d to be %d got %d", i, expected, actual)
+		}
+	}
+	for i, expected := range []uint16{0xf800, 0x07e0, 0x001f} {
+		if actual := binary.LittleEndian.Uint16(body[18+2*i:]); actual != expected {
+			t.Errorf("expected color %d to be %#04x got %#04x", i, expected, actual)
+		}
+	}
+	if intensity := body[ftBinLen+btLen]; intensity != 1 {
+		t.Errorf("expected first intensity %d got %d", 1, intensity)
+	}
+}
+
+func TestPntsByteLength(t *testing.T) {
+	pt2 := &geom.LinkedPoint{Pt: model.Point{X: 1, Y: 1, Z: 1, Intensity: 3, Classification: 4}}
+	pt1 := &geom.LinkedPoint{Pt: model.Point{X: 0, Y: 0, Z: 0, Intensity: 1, Classification: 2}, Next: pt2}
+	node := &tree.MockNode{
+		TotalNumPts: 2,
+		Pts:         geom.NewLinkedPointStream(pt1, 2),
+		Bounds:      geom.NewBoundingBox(0, 1, 0, 1, 0, 1),
+	}
+	tmp := t.TempDir()
+	if err := writeContentFile(NewPntsEncoder(), node, tmp); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, file := range []string{filepath.Join(tmp, "content.pnts"), "./testdata/content.pnts"} {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		// the byte length in the header includes the batch table, storing intensity and classification
+		if btLen := binary.LittleEndian.Uint32(b[20:]); btLen == 0 {
+			t.Errorf("%s: expected a batch table", file)
+		}
+		if byteLength := int(binary.LittleEndian.Uint32(b[8:])); byteLength != len(b) {
+			t.Errorf("%s: expected byteLength %d got %d", file, len(b), byteLength)
+		}
+	}
+}
+
+func TestWriterQuantized(t *testing.T) {
+	w, err := NewWriter("base", WithQuantizedPositions(true), WithRGB565(true))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	c := w.consumerFunc(version.TilesetVersion_1_0, nil)
+	if e := c.(*StandardConsumer).encoder.(*PntsEncoder); !e.quantizePositions || !e.rgb565 {
+		t.Errorf("expected quantized positions and RGB565 colors in the pnts encoder")
+	}
+}
@@ -16,6 +16,8 @@ type Producer interface {
 
 type StandardProducer struct {
 	basePath string
+	// implicit is true if the tiles are addressed using the implicit tiling scheme
+	implicit bool
 }
 
 func NewStandardProducer(basepath string, subfolder string) Producer {
@@ -24,6 +26,15 @@ func NewStandardProducer(basepath string, subfolder string) Producer {
 	}
 }
 
+// NewImplicitProducer returns a producer of WorkUnits for the 3D Tiles 1.1 implicit tiling scheme. The content
+// of each tile is written in the content/{level}/{x}/{y}/{z} subfolder of the tileset folder.
+func NewImplicitProducer(basepath string, subfolder string) Producer {
+	return &StandardProducer{
+		basePath: path.Join(basepath, subfolder),
+		implicit: true,
+	}
+}
+
 // Parses a tree node and submits WorkUnits the the provided workchannel. Should be called only on the tree root node.
 // Closes the channel when all work is submitted.
 func (p *StandardProducer) Produce(work chan *WorkUnit, errchan chan error, wg *sync.WaitGroup, node tree.Node, ctx context.Context) {
@@ -33,7 +44,11 @@ func (p *StandardProducer) Produce(work chan *WorkUnit, errchan chan error, wg *
 		}
 	}()
 	defer close(work)
-	p.produce(errchan, p.basePath, node, work, wg, ctx)
+	if p.implicit {
+		p.produceImplicit(errchan, ImplicitTile{TilesetPath: p.basePath}, node, work, ctx)
+	} else {
+		p.produce(errchan, p.basePath, node, work, wg, ctx)
+	}
 	wg.Done()
 }
 
@@ -60,3 +75,26 @@ func (p *StandardProducer) produce(errchan chan error, basePath string, node tre
 		}
 	}
 }
+
+// Parses a tree node and submits WorkUnits addressed by their implicit tile coordinates to the provided workchannel.
+func (p *StandardProducer) produceImplicit(errchan chan error, tile ImplicitTile, node tree.Node, work chan *WorkUnit, ctx context.Context) {
+	if err := ctx.Err(); err != nil {
+		errchan <- fmt.Errorf("context closed: %v", err)
+		return
+	}
+	if node.NumberOfPoints() > 0 {
+		work <- &WorkUnit{
+			Node:     node,
+			BasePath: tile.ContentPath(),
+			Implicit: &tile,
+		}
+	} else {
+		errchan <- fmt.Errorf("unexpected error: found tile without points: %v", node)
+	}
+
+	for i, child := range node.Children() {
+		if child != nil {
+			p.produceImplicit(errchan, tile.Child(i), child, work, ctx)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package writer
+
+import (
+	"io"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
+)
+
+// Sink stores the files of a tileset. Implementations must be safe for concurrent use by multiple consumers.
+type Sink interface {
+	// Create returns a writer for the file at the given path. The file is complete once the writer is closed.
+	Create(path string) (io.WriteCloser, error)
+	// Close finalizes the output, no files can be created afterwards
+	Close() error
+}
+
+// StorageSink writes each file of the tileset to the storage
+type StorageSink struct {
+	storage storage.Storage
+}
+
+func NewStorageSink(s storage.Storage) Sink {
+	return &StorageSink{storage: s}
+}
+
+func (s *StorageSink) Create(path string) (io.WriteCloser, error) {
+	return s.storage.Create(path)
+}
+
+// CreateWithEncoding creates the file recording its content encoding, if supported by the storage
+func (s *StorageSink) CreateWithEncoding(path string, encoding string) (io.WriteCloser, error) {
+	if es, ok := s.storage.(storage.EncodedStorage); ok {
+		return es.CreateWithEncoding(path, encoding)
+	}
+	return s.storage.Create(path)
+}
+
+func (s *StorageSink) Close() error {
+	return nil
+}
+
+// writeFile stores the given content in the file at the given path of the sink
+func writeFile(s Sink, path string, data []byte) error {
+	f, err := s.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
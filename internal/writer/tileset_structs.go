@@ -35,3 +35,53 @@ type Tileset struct {
 	GeometricError float64 `json:"geometricError"`
 	Root           Root    `json:"root"`
 }
+
+type Subtrees struct {
+	Url string `json:"uri"`
+}
+
+type ImplicitTiling struct {
+	SubdivisionScheme string   `json:"subdivisionScheme"`
+	SubtreeLevels     int      `json:"subtreeLevels"`
+	AvailableLevels   int      `json:"availableLevels"`
+	Subtrees          Subtrees `json:"subtrees"`
+}
+
+type ImplicitRoot struct {
+	Content        Content        `json:"content"`
+	BoundingVolume BoundingVolume `json:"boundingVolume"`
+	GeometricError float64        `json:"geometricError"`
+	Refine         string         `json:"refine"`
+	Transform      *[16]float64   `json:"transform,omitempty"`
+	ImplicitTiling ImplicitTiling `json:"implicitTiling"`
+}
+
+type ImplicitTileset struct {
+	Asset          Asset        `json:"asset"`
+	GeometricError float64      `json:"geometricError"`
+	Root           ImplicitRoot `json:"root"`
+}
+
+type SubtreeBuffer struct {
+	ByteLength int `json:"byteLength"`
+}
+
+type SubtreeBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+}
+
+// Availability is either a bitstream, referencing a buffer view, or a constant
+type Availability struct {
+	Bitstream *int `json:"bitstream,omitempty"`
+	Constant  *int `json:"constant,omitempty"`
+}
+
+type Subtree struct {
+	Buffers                  []SubtreeBuffer     `json:"buffers,omitempty"`
+	BufferViews              []SubtreeBufferView `json:"bufferViews,omitempty"`
+	TileAvailability         Availability        `json:"tileAvailability"`
+	ContentAvailability      []Availability      `json:"contentAvailability"`
+	ChildSubtreeAvailability Availability        `json:"childSubtreeAvailability"`
+}
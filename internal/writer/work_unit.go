@@ -11,4 +11,6 @@ type WorkUnit struct {
 	Node tree.Node
 	// BasePath is the path of the folder where to write the content.pnts and tileset.json files for this workunit
 	BasePath string
+	// Implicit holds the coordinates of the tile in the implicit tiling scheme, nil if implicit tiling is not used
+	Implicit *ImplicitTile
 }
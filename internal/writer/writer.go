@@ -2,10 +2,14 @@ package writer
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"path"
 	"sync"
 
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
 	"github.com/mfbonfigli/gocesiumtiler/v2/version"
 )
 
@@ -15,31 +19,86 @@ type Writer interface {
 }
 
 type StandardWriter struct {
-	numWorkers   int
-	bufferRatio  int
-	basePath     string
-	version      version.TilesetVersion
+	numWorkers  int
+	bufferRatio int
+	basePath    string
+	version     version.TilesetVersion
+	attributes  []geom.Attribute
+	draco       *DracoOptions
+	quantize    bool
+	rgb565      bool
+	meshopt     bool
+	// subtreeLevels enables implicit tiling if greater than zero
+	subtreeLevels int
+	// archive writes each tileset in a single .3tz file instead of a folder tree
+	archive      bool
+	storage      storage.Storage
+	gzip         GzipMode
 	producerFunc func(basepath, folder string) Producer
-	consumerFunc func(version.TilesetVersion) Consumer
+	consumerFunc func(version.TilesetVersion, Sink) Consumer
+	sinkFunc     func(basepath, folder string) (Sink, error)
 }
 
 func NewWriter(basePath string, options ...func(*StandardWriter)) (*StandardWriter, error) {
 	w := &StandardWriter{
-		basePath:     basePath,
-		numWorkers:   1,
-		bufferRatio:  5,
-		version:      version.TilesetVersion_1_0,
-		producerFunc: NewStandardProducer,
-		consumerFunc: func(v version.TilesetVersion) Consumer {
-			if v == version.TilesetVersion_1_0 {
-				return NewStandardConsumer(WithGeometryEncoder(NewPntsEncoder()))
+		basePath:    basePath,
+		numWorkers:  1,
+		bufferRatio: 5,
+		version:     version.TilesetVersion_1_0,
+	}
+	w.producerFunc = func(basepath, folder string) Producer {
+		if w.subtreeLevels > 0 {
+			return NewImplicitProducer(basepath, folder)
+		}
+		return NewStandardProducer(basepath, folder)
+	}
+	w.consumerFunc = func(v version.TilesetVersion, sink Sink) Consumer {
+		if v == version.TilesetVersion_1_0 {
+			opts := []func(*PntsEncoder){
+				WithPntsAttributes(w.attributes),
+				WithPntsQuantizedPositions(w.quantize),
+				WithPntsRGB565(w.rgb565),
+			}
+			if w.draco != nil {
+				opts = append(opts, WithPntsDraco(*w.draco))
 			}
-			return NewStandardConsumer(WithGeometryEncoder(NewGltfEncoder()))
-		},
+			return NewStandardConsumer(WithGeometryEncoder(NewPntsEncoder(opts...)), WithSink(sink), WithGzip(w.gzip))
+		}
+		opts := []func(*GltfEncoder){
+			WithGltfAttributes(w.attributes),
+			WithGltfQuantizedPositions(w.quantize),
+			WithGltfMeshopt(w.meshopt),
+		}
+		if w.draco != nil {
+			opts = append(opts, WithGltfDraco(*w.draco))
+		}
+		return NewStandardConsumer(WithGeometryEncoder(NewGltfEncoder(opts...)), WithSink(sink), WithGzip(w.gzip), WithSubtreeLevels(w.subtreeLevels))
+	}
+	w.sinkFunc = func(basepath, folder string) (Sink, error) {
+		if w.archive {
+			root := path.Join(basepath, folder)
+			return NewArchiveSink(w.storage, root, path.Join(root, "tileset"+ArchiveExtension))
+		}
+		return NewStorageSink(w.storage), nil
 	}
 	for _, optFn := range options {
 		optFn(w)
 	}
+	if w.storage == nil {
+		// the base path is the location of the storage, and the files are written relative to it
+		s, err := storage.New(basePath)
+		if err != nil {
+			return nil, err
+		}
+		w.storage = s
+		w.basePath = ""
+	}
+	if w.gzip != GzipNone && w.archive {
+		return nil, fmt.Errorf("gzip compression is not supported for archive outputs")
+	}
+	if w.subtreeLevels > 0 && w.version != version.TilesetVersion_1_1 {
+		return nil, fmt.Errorf("implicit tiling requires tileset version %s", version.TilesetVersion_1_1)
+	}
 	return w, nil
 }
 
@@ -65,7 +124,81 @@ func WithTilesetVersion(v version.TilesetVersion) func(*StandardWriter) {
 	}
 }
 
+// WithAttributes sets the additional point attributes to write in the tiles, besides intensity and classification.
+func WithAttributes(attributes []geom.Attribute) func(*StandardWriter) {
+	return func(w *StandardWriter) {
+		w.attributes = attributes
+	}
+}
+
+// WithDraco enables the Draco compression of the tiles using the given options. Pnts tiles use the
+// 3DTILES_draco_point_compression extension while glb tiles use the KHR_draco_mesh_compression extension.
+func WithDraco(opts DracoOptions) func(*StandardWriter) {
+	return func(w *StandardWriter) {
+		w.draco = &opts
+	}
+}
+
+// WithQuantizedPositions stores the point coordinates as 16 bit integers relative to the tile bounds, using
+// POSITION_QUANTIZED in pnts tiles and the KHR_mesh_quantization extension in glb tiles.
+func WithQuantizedPositions(quantize bool) func(*StandardWriter) {
+	return func(w *StandardWriter) {
+		w.quantize = quantize
+	}
+}
+
+// WithRGB565 stores the point colors of pnts tiles in 16 bits using the RGB565 encoding.
+func WithRGB565(rgb565 bool) func(*StandardWriter) {
+	return func(w *StandardWriter) {
+		w.rgb565 = rgb565
+	}
+}
+
+// WithMeshopt compresses the vertex buffers of glb tiles using the EXT_meshopt_compression extension.
+func WithMeshopt(meshopt bool) func(*StandardWriter) {
+	return func(w *StandardWriter) {
+		w.meshopt = meshopt
+	}
+}
+
+// WithImplicitTiling writes the tileset using the 3D Tiles 1.1 octree implicit tiling scheme, with subtrees of the
+// given number of levels, instead of nesting a tileset.json for each non leaf tile. Requires tileset version 1.1.
+func WithImplicitTiling(subtreeLevels int) func(*StandardWriter) {
+	return func(w *StandardWriter) {
+		w.subtreeLevels = subtreeLevels
+	}
+}
+
+// WithArchive writes each tileset as a single 3D Tiles archive file named tileset.3tz, stored in the tileset
+// folder, instead of a tree of folders and files.
+func WithArchive(archive bool) func(*StandardWriter) {
+	return func(w *StandardWriter) {
+		w.archive = archive
+	}
+}
+
+// WithStorage writes the tilesets to the given storage. The writer base path is then interpreted as the slash
+// separated path of the output folder in the storage, instead of the storage location.
+func WithStorage(s storage.Storage) func(*StandardWriter) {
+	return func(w *StandardWriter) {
+		w.storage = s
+	}
+}
+
+// WithGzipCompression gzip compresses the tileset.json, subtree and tile files, either replacing the original files
+// or alongside them. Compression is performed by the consumers.
+func WithGzipCompression(mode GzipMode) func(*StandardWriter) {
+	return func(w *StandardWriter) {
+		w.gzip = mode
+	}
+}
+
 func (w *StandardWriter) Write(t tree.Tree, folderName string, ctx context.Context) error {
+	sink, err := w.sinkFunc(w.basePath, folderName)
+	if err != nil {
+		return err
+	}
+
 	// init channel where consumers can eventually submit errors that prevented them to finish the job
 	errorChannel := make(chan error)
 
@@ -84,7 +217,7 @@ func (w *StandardWriter) Write(t tree.Tree, folderName string, ctx context.Conte
 	for i := 0; i < w.numWorkers; i++ {
 		waitGroup.Add(1)
 		// instantiate a new converter per each goroutine for thread safety
-		consumer := w.consumerFunc(w.version)
+		consumer := w.consumerFunc(w.version, sink)
 		go consumer.Consume(workChannel, errorChannel, &waitGroup)
 	}
 
@@ -109,6 +242,10 @@ func (w *StandardWriter) Write(t tree.Tree, folderName string, ctx context.Conte
 	close(errorChannel)
 	errorWaitGroup.Wait()
 
+	// the sink is finalized even in case of errors to release its resources
+	if err := sink.Close(); err != nil {
+		errs = append(errs, err)
+	}
 	if len(errs) != 0 {
 		return errs[0]
 	}
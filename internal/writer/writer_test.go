@@ -3,10 +3,12 @@ package writer
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
 	"github.com/mfbonfigli/gocesiumtiler/v2/version"
 )
 
@@ -51,7 +53,7 @@ func TestWriter(t *testing.T) {
 	w.producerFunc = func(basepath, folder string) Producer {
 		return p
 	}
-	w.consumerFunc = func(v version.TilesetVersion) Consumer {
+	w.consumerFunc = func(v version.TilesetVersion, s Sink) Consumer {
 		return c
 	}
 	err = w.Write(root, "base", context.TODO())
@@ -117,7 +119,7 @@ func TestWriterWithProducerError(t *testing.T) {
 	w.producerFunc = func(basepath, folder string) Producer {
 		return p
 	}
-	w.consumerFunc = func(v version.TilesetVersion) Consumer {
+	w.consumerFunc = func(v version.TilesetVersion, s Sink) Consumer {
 		return c
 	}
 	err = w.Write(root, "base", context.TODO())
@@ -183,7 +185,7 @@ func TestWriterWithConsumerError(t *testing.T) {
 	w.producerFunc = func(basepath, folder string) Producer {
 		return p
 	}
-	w.consumerFunc = func(v version.TilesetVersion) Consumer {
+	w.consumerFunc = func(v version.TilesetVersion, s Sink) Consumer {
 		return c
 	}
 	err = w.Write(root, "base", context.TODO())
@@ -218,7 +220,7 @@ func TestWriterTilesetVersion(t *testing.T) {
 	if w.version != version.TilesetVersion_1_0 {
 		t.Errorf("unexpected tileset version")
 	}
-	c := w.consumerFunc(version.TilesetVersion_1_0)
+	c := w.consumerFunc(version.TilesetVersion_1_0, nil)
 	if _, success := (c.(*StandardConsumer).encoder).(*PntsEncoder); success != true {
 		t.Errorf("unexpected geometry encoder for tileset version 1.0")
 	}
@@ -233,8 +235,34 @@ func TestWriterTilesetVersion(t *testing.T) {
 	if w.version != version.TilesetVersion_1_1 {
 		t.Errorf("unexpected tileset version")
 	}
-	c = w.consumerFunc(version.TilesetVersion_1_1)
+	c = w.consumerFunc(version.TilesetVersion_1_1, nil)
 	if _, success := (c.(*StandardConsumer).encoder).(*GltfEncoder); success != true {
 		t.Errorf("unexpected geometry encoder for tileset version 1.1")
 	}
 }
+
+func TestWriterStorage(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	w, err := NewWriter("out", WithStorage(s), WithNumWorkers(2))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.Write(implicitTestTree(), "tileset", context.TODO()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{
+		"out/tileset/1/3/content.pnts",
+		"out/tileset/1/content.pnts",
+		"out/tileset/1/tileset.json",
+		"out/tileset/6/content.pnts",
+		"out/tileset/content.pnts",
+		"out/tileset/tileset.json",
+	}
+	if actual := s.Files(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected files %v got %v", expected, actual)
+	}
+
+	if _, err := NewWriter("ftp://host/out"); err == nil {
+		t.Errorf("expected error for an unsupported storage location")
+	}
+}
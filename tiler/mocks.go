@@ -3,6 +3,8 @@ package tiler
 import (
 	"context"
 
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/writer"
 	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/mutator"
 	"github.com/mfbonfigli/gocesiumtiler/v2/version"
 )
@@ -18,12 +20,30 @@ type MockTiler struct {
 	ProcessFilesCalled  bool
 	ProcessFolderCalled bool
 	// opts settings
-	EightBit   bool
-	GridSize   float64
-	PtsPerTile int
-	Depth      int
-	Version    version.TilesetVersion
-	err        error
+	EightBit      bool
+	GridSize      float64
+	PtsPerTile    int
+	Depth         int
+	Version       version.TilesetVersion
+	CopcBounds    *geom.BoundingBox
+	CopcMaxLevel  int
+	TextColumns   string
+	TextColorBits int
+	LasAttrs      []string
+	ExtraDims     []string
+	Draco         *writer.DracoOptions
+	Quantize      bool
+	RGB565        bool
+	Meshopt       bool
+	SubtreeLevels int
+	Archive       bool
+	Gzip          writer.GzipMode
+	MemoryLimit   int64
+	TempDir       string
+	ParallelBuild bool
+	Converter     ConverterEngine
+	FileCRS       map[string]string
+	err           error
 }
 
 func (m *MockTiler) ProcessFiles(inputLasFiles []string, outputFolder string, sourceCRS string, opts *TilerOptions, ctx context.Context) error {
@@ -39,6 +59,24 @@ func (m *MockTiler) ProcessFiles(inputLasFiles []string, outputFolder string, so
 	m.Depth = opts.maxDepth
 	m.Version = opts.version
 	m.Mutators = opts.mutators
+	m.CopcBounds = opts.copcBounds
+	m.CopcMaxLevel = opts.copcMaxLevel
+	m.TextColumns = opts.textColumns
+	m.TextColorBits = opts.textColorBits
+	m.LasAttrs = opts.lasAttributes
+	m.ExtraDims = opts.extraDimensions
+	m.Draco = opts.draco
+	m.Quantize = opts.quantizePositions
+	m.RGB565 = opts.rgb565
+	m.Meshopt = opts.meshopt
+	m.SubtreeLevels = opts.subtreeLevels
+	m.Archive = opts.archive
+	m.Gzip = opts.gzip
+	m.MemoryLimit = opts.memoryLimit
+	m.TempDir = opts.tempDir
+	m.ParallelBuild = opts.parallelBuild
+	m.Converter = opts.converter
+	m.FileCRS = opts.fileCRS
 	return m.err
 }
 
@@ -55,5 +93,23 @@ func (m *MockTiler) ProcessFolder(inputFolder, outputFolder string, sourceCRS st
 	m.Depth = opts.maxDepth
 	m.Version = opts.version
 	m.Mutators = opts.mutators
+	m.CopcBounds = opts.copcBounds
+	m.CopcMaxLevel = opts.copcMaxLevel
+	m.TextColumns = opts.textColumns
+	m.TextColorBits = opts.textColorBits
+	m.LasAttrs = opts.lasAttributes
+	m.ExtraDims = opts.extraDimensions
+	m.Draco = opts.draco
+	m.Quantize = opts.quantizePositions
+	m.RGB565 = opts.rgb565
+	m.Meshopt = opts.meshopt
+	m.SubtreeLevels = opts.subtreeLevels
+	m.Archive = opts.archive
+	m.Gzip = opts.gzip
+	m.MemoryLimit = opts.memoryLimit
+	m.TempDir = opts.tempDir
+	m.ParallelBuild = opts.parallelBuild
+	m.Converter = opts.converter
+	m.FileCRS = opts.fileCRS
 	return m.err
 }
@@ -1,5 +1,10 @@
 package model
 
+import (
+	"errors"
+	"math"
+)
+
 // IdentityTransform is the identity transformation object
 var IdentityTransform Transform = Transform{
 	forward: [4][4]float64{
@@ -37,6 +42,38 @@ func NewTransform(fwd [4][4]float64) Transform {
 	}
 }
 
+// NewAffineTransform returns a new transform object from the given forward affine transformation matrix, whose
+// upper left 3x3 block can also scale and shear the coordinates. An error is returned if the matrix is not
+// invertible or its last row is not 0, 0, 0, 1.
+func NewAffineTransform(fwd [4][4]float64) (Transform, error) {
+	if fwd[3] != [4]float64{0, 0, 0, 1} {
+		return Transform{}, errors.New("the last row of an affine transformation matrix must be 0, 0, 0, 1")
+	}
+	// inverse of the linear part as the transposed cofactor matrix divided by the determinant
+	cofactor := func(r, c int) float64 {
+		r1, r2 := (r+1)%3, (r+2)%3
+		c1, c2 := (c+1)%3, (c+2)%3
+		return fwd[r1][c1]*fwd[r2][c2] - fwd[r1][c2]*fwd[r2][c1]
+	}
+	det := fwd[0][0]*cofactor(0, 0) + fwd[0][1]*cofactor(0, 1) + fwd[0][2]*cofactor(0, 2)
+	if math.Abs(det) < 1e-15 {
+		return Transform{}, errors.New("the affine transformation matrix is not invertible")
+	}
+	inverse := [4][4]float64{3: {0, 0, 0, 1}}
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			inverse[r][c] = cofactor(c, r) / det
+		}
+	}
+	for r := 0; r < 3; r++ {
+		inverse[r][3] = -(inverse[r][0]*fwd[0][3] + inverse[r][1]*fwd[1][3] + inverse[r][2]*fwd[2][3])
+	}
+	return Transform{
+		forward: fwd,
+		inverse: inverse,
+	}, nil
+}
+
 // Forward transforms the given Vector from the source to the destination CRS
 func (q Transform) Forward(v Vector) Vector {
 	return q.transform(v, q.forward)
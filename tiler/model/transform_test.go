@@ -121,3 +121,32 @@ func TestColumnMajorForwardInverse(t *testing.T) {
 		t.Errorf("expected inverse column major %v, got %v", expectedInverse, actual)
 	}
 }
+
+func TestAffineTransformForwardInverse(t *testing.T) {
+	// scale, shear and translation
+	q, err := NewAffineTransform(
+		[4][4]float64{
+			{2, 0.5, 0, 10},
+			{0, 3, 0, 20},
+			{1, 0, 0.5, 30},
+			{0, 0, 0, 1},
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	source := Vector{X: 5, Y: -4, Z: 7}
+	actual := q.Forward(source)
+	expected := Vector{X: 18, Y: 8, Z: 38.5}
+	compareWithTolerance(expected, actual, t)
+	actual = q.Inverse(expected)
+	expected = source
+	compareWithTolerance(expected, actual, t)
+
+	if _, err := NewAffineTransform([4][4]float64{{1, 2, 3, 0}, {2, 4, 6, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}); err == nil {
+		t.Errorf("expected error for a singular matrix")
+	}
+	if _, err := NewAffineTransform([4][4]float64{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 1, 1}}); err == nil {
+		t.Errorf("expected error for a projective matrix")
+	}
+}
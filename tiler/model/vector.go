@@ -32,3 +32,23 @@ func (v Vector) Cross(w Vector) Vector {
 		Z: v.X*w.Y - v.Y*w.X,
 	}
 }
+
+// Add returns the sum of the vector and the vector passed as input
+func (v Vector) Add(w Vector) Vector {
+	return Vector{X: v.X + w.X, Y: v.Y + w.Y, Z: v.Z + w.Z}
+}
+
+// Subtract returns the difference between the vector and the vector passed as input
+func (v Vector) Subtract(w Vector) Vector {
+	return Vector{X: v.X - w.X, Y: v.Y - w.Y, Z: v.Z - w.Z}
+}
+
+// Scale returns the vector multiplied by the given factor
+func (v Vector) Scale(f float64) Vector {
+	return Vector{X: v.X * f, Y: v.Y * f, Z: v.Z * f}
+}
+
+// Dot returns the result of the dot product with the vector passed as input
+func (v Vector) Dot(w Vector) float64 {
+	return v.X*w.X + v.Y*w.Y + v.Z*w.Z
+}
@@ -101,3 +101,14 @@ func TestVectorCross(t *testing.T) {
 	expected = Vector{X: 1, Y: 0, Z: 0}
 	compareWithTolerance(expected, u.Cross(v), t)
 }
+
+func TestVectorArithmetic(t *testing.T) {
+	u := Vector{X: 1, Y: 2, Z: 3}
+	v := Vector{X: 4, Y: -5, Z: 6}
+	compareWithTolerance(Vector{X: 5, Y: -3, Z: 9}, u.Add(v), t)
+	compareWithTolerance(Vector{X: -3, Y: 7, Z: -3}, u.Subtract(v), t)
+	compareWithTolerance(Vector{X: 2, Y: 4, Z: 6}, u.Scale(2), t)
+	if actual := u.Dot(v); actual != 12 {
+		t.Errorf("expected dot product %f, got %f", 12.0, actual)
+	}
+}
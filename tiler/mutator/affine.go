@@ -0,0 +1,30 @@
+package mutator
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// Affine is a mutator that applies an affine transformation, like a rigid roto-translation or a 7-parameter
+// Helmert transformation, to the coordinates of the points in the source CRS, before they are converted to the
+// global CRS. It can be used to georeference clouds in local scanner coordinates or to apply a site calibration,
+// the transformed coordinates being expressed in the source CRS of the cloud.
+type Affine struct {
+	transform model.Transform
+}
+
+// NewAffine returns an Affine mutator applying the forward transformation of the given transform,
+// see model.NewAffineTransform and FitHelmert to build it
+func NewAffine(t model.Transform) *Affine {
+	return &Affine{
+		transform: t,
+	}
+}
+
+// Mutate leaves the points unchanged, as the transformation is applied by MutateSource
+func (a *Affine) Mutate(pt model.Point, localToGlobal model.Transform) (model.Point, bool) {
+	return pt, true
+}
+
+func (a *Affine) MutateSource(coord model.Vector) model.Vector {
+	return a.transform.Forward(coord)
+}
@@ -0,0 +1,42 @@
+package mutator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+func TestAffine(t *testing.T) {
+	a := NewAffine(model.NewTransform([4][4]float64{
+		{0, -1, 0, 10},
+		{1, 0, 0, 20},
+		{0, 0, 1, 30},
+		{0, 0, 0, 1},
+	}))
+	pt := geom.NewPoint(1, 2, 3, 1, 2, 3, 4, 5)
+	actual, keep := a.Mutate(pt, model.Transform{})
+	if !reflect.DeepEqual(actual, pt) {
+		t.Errorf("expected %v, got %v", pt, actual)
+	}
+	if !keep {
+		t.Errorf("expected keep to be true but is false")
+	}
+	expected := model.Vector{X: 8, Y: 21, Z: 33}
+	if actual := a.MutateSource(model.Vector{X: 1, Y: 2, Z: 3}); actual != expected {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	// the pipeline applies the source mutators in order and ignores the others
+	p := NewPipeline(a, NewZOffset(1), NewAffine(model.NewTransform([4][4]float64{
+		{1, 0, 0, 1},
+		{0, 1, 0, 1},
+		{0, 0, 1, 1},
+		{0, 0, 0, 1},
+	})))
+	expected = model.Vector{X: 9, Y: 22, Z: 34}
+	if actual := p.MutateSource(model.Vector{X: 1, Y: 2, Z: 3}); actual != expected {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,57 @@
+package mutator
+
+import (
+	"sync/atomic"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geoid"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// Geoid is a mutator that converts the orthometric heights of the points, referred to a geoid model, into heights
+// above the WGS84 ellipsoid, as required by Cesium. Each point is shifted by the geoid undulation at its position,
+// bilinearly interpolated from the geoid grid, along the normal to the ellipsoid.
+// Points outside the area covered by the grid are left unchanged and counted in Uncovered.
+type Geoid struct {
+	grid      *geoid.Grid
+	uncovered atomic.Int64
+}
+
+// NewGeoid returns a Geoid mutator using the geoid grid stored in the given file, like the EGM96 and EGM2008 models
+// or national geoid models. Supported formats are .gtx, GeographicLib .pgm and GeoTIFF .tif grids.
+func NewGeoid(path string) (*Geoid, error) {
+	g, err := geoid.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return newGeoid(g), nil
+}
+
+func newGeoid(g *geoid.Grid) *Geoid {
+	return &Geoid{
+		grid: g,
+	}
+}
+
+func (g *Geoid) Mutate(pt model.Point, localToGlobal model.Transform) (model.Point, bool) {
+	global := localToGlobal.Forward(pt.Vector())
+	lat, lon, _ := geom.ECEFToGeodetic(global.X, global.Y, global.Z)
+	n, ok := g.grid.Undulation(lat, lon)
+	if !ok {
+		g.uncovered.Add(1)
+		return pt, true
+	}
+	normal := geom.GeodeticNormal(lat, lon)
+	local := localToGlobal.Inverse(model.Vector{
+		X: global.X + n*normal.X,
+		Y: global.Y + n*normal.Y,
+		Z: global.Z + n*normal.Z,
+	})
+	pt.X, pt.Y, pt.Z = float32(local.X), float32(local.Y), float32(local.Z)
+	return pt, true
+}
+
+// Uncovered returns the number of points left unchanged as outside of the area covered by the geoid grid
+func (g *Geoid) Uncovered() int64 {
+	return g.uncovered.Load()
+}
@@ -0,0 +1,59 @@
+package mutator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geoid"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+)
+
+func TestGeoid(t *testing.T) {
+	// undulation growing by 1 meter per degree of longitude, from 10 meters at longitude 10
+	grid, err := geoid.NewGrid(44, 10, 1, 1, 3, 3, []float32{10, 11, 12, 10, 11, 12, 10, 11, 12})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g := newGeoid(grid)
+	origin := geom.GeodeticToECEF(45, 10.5, 100)
+	localToGlobal := geom.LocalToGlobalTransformFromPoint(origin.X, origin.Y, origin.Z)
+
+	for _, p := range [][3]float64{{45, 10.5, 100}, {45.2, 11.5, 250}, {44.5, 10, -10}} {
+		global := geom.GeodeticToECEF(p[0], p[1], p[2])
+		local := localToGlobal.Inverse(global)
+		pt := geom.NewPoint(float32(local.X), float32(local.Y), float32(local.Z), 1, 2, 3, 4, 5)
+		actual, keep := g.Mutate(pt, localToGlobal)
+		if !keep {
+			t.Errorf("expected keep to be true but is false")
+		}
+		if actual.R != 1 || actual.Classification != 5 {
+			t.Errorf("expected the point attributes to be unchanged")
+		}
+		v := localToGlobal.Forward(actual.Vector())
+		lat, lon, h := geom.ECEFToGeodetic(v.X, v.Y, v.Z)
+		// the undulation equals the longitude
+		expected := p[2] + p[1]
+		if math.Abs(lat-p[0]) > 1e-6 || math.Abs(lon-p[1]) > 1e-6 || math.Abs(h-expected) > 0.01 {
+			t.Errorf("expected %f %f %f got %f %f %f", p[0], p[1], expected, lat, lon, h)
+		}
+	}
+	if g.Uncovered() != 0 {
+		t.Errorf("expected no uncovered points got %d", g.Uncovered())
+	}
+
+	// points outside of the grid are unchanged
+	local := localToGlobal.Inverse(geom.GeodeticToECEF(50, 10.5, 100))
+	pt := geom.NewPoint(float32(local.X), float32(local.Y), float32(local.Z), 1, 2, 3, 4, 5)
+	if actual, keep := g.Mutate(pt, localToGlobal); actual.X != pt.X || actual.Y != pt.Y || actual.Z != pt.Z || !keep {
+		t.Errorf("expected point %v got %v", pt, actual)
+	}
+	if g.Uncovered() != 1 {
+		t.Errorf("expected %d uncovered points got %d", 1, g.Uncovered())
+	}
+}
+
+func TestNewGeoidInvalidFile(t *testing.T) {
+	if _, err := NewGeoid("missing.gtx"); err == nil {
+		t.Errorf("expected error got none")
+	}
+}
@@ -0,0 +1,265 @@
+package mutator
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+// ControlPoint is a pair of coordinates of the same point, expressed in the source and in the target CRS
+type ControlPoint struct {
+	Source model.Vector
+	Target model.Vector
+}
+
+// HelmertFit is the 7-parameter Helmert transformation, made of a scale, a rotation and a translation, that best
+// fits a set of control points in the least squares sense, mapping each source coordinate v to Scale*Rotation*v+Translation
+type HelmertFit struct {
+	// Transform is the fitted transformation, to be used with the Affine mutator
+	Transform model.Transform
+	// Scale is the scale factor
+	Scale float64
+	// Rotation is the rotation matrix
+	Rotation [3][3]float64
+	// Translation is the translation vector, in the target CRS units
+	Translation model.Vector
+	// Residuals are the differences between the target coordinates of each control point and the transformed
+	// source coordinates, in the order of the control points
+	Residuals []model.Vector
+	// RMS is the root mean square of the residual distances
+	RMS float64
+}
+
+// RotationAngles returns the rotation angles around the X, Y and Z axes, in degrees, such that the rotation
+// is equivalent to rotating around X, then around Y and then around Z
+func (h *HelmertFit) RotationAngles() (rx, ry, rz float64) {
+	r := h.Rotation
+	rx = math.Atan2(r[2][1], r[2][2])
+	ry = math.Asin(math.Max(-1, math.Min(1, -r[2][0])))
+	rz = math.Atan2(r[1][0], r[0][0])
+	return rx * 180 / math.Pi, ry * 180 / math.Pi, rz * 180 / math.Pi
+}
+
+// FitHelmert estimates the 7-parameter Helmert transformation mapping the source to the target coordinates of the
+// given control points with the least squares closed form solution by B. K. P. Horn, "Closed-form solution of
+// absolute orientation using unit quaternions" (1987). Rotations of any magnitude are supported, therefore the
+// source coordinates can be local scanner coordinates. At least three non collinear control points are required.
+func FitHelmert(points []ControlPoint) (*HelmertFit, error) {
+	if len(points) < 3 {
+		return nil, fmt.Errorf("at least 3 control points are required, got %d", len(points))
+	}
+	// centroids, to which the coordinates are referred to preserve the precision of large coordinate values
+	var srcCenter, tgtCenter model.Vector
+	for _, p := range points {
+		srcCenter = srcCenter.Add(p.Source)
+		tgtCenter = tgtCenter.Add(p.Target)
+	}
+	n := float64(len(points))
+	srcCenter = srcCenter.Scale(1 / n)
+	tgtCenter = tgtCenter.Scale(1 / n)
+	src := make([]model.Vector, len(points))
+	tgt := make([]model.Vector, len(points))
+	for i, p := range points {
+		src[i] = p.Source.Subtract(srcCenter)
+		tgt[i] = p.Target.Subtract(tgtCenter)
+	}
+	if collinear(src) {
+		return nil, errors.New("the control points are collinear")
+	}
+
+	// cross covariance of the source and target coordinates
+	var m [3][3]float64
+	srcNorm := 0.0
+	for i := range src {
+		s := [3]float64{src[i].X, src[i].Y, src[i].Z}
+		t := [3]float64{tgt[i].X, tgt[i].Y, tgt[i].Z}
+		for r := 0; r < 3; r++ {
+			for c := 0; c < 3; c++ {
+				m[r][c] += s[r] * t[c]
+			}
+		}
+		srcNorm += src[i].Dot(src[i])
+	}
+	// the optimal rotation is given by the unit quaternion that is the eigenvector of the largest eigenvalue of N
+	sxx, sxy, sxz := m[0][0], m[0][1], m[0][2]
+	syx, syy, syz := m[1][0], m[1][1], m[1][2]
+	szx, szy, szz := m[2][0], m[2][1], m[2][2]
+	nm := [4][4]float64{
+		{sxx + syy + szz, syz - szy, szx - sxz, sxy - syx},
+		{syz - szy, sxx - syy - szz, sxy + syx, szx + sxz},
+		{szx - sxz, sxy + syx, -sxx + syy - szz, syz + szy},
+		{sxy - syx, szx + sxz, syz + szy, -sxx - syy + szz},
+	}
+	q := largestEigenvector(nm)
+	q0, qx, qy, qz := q[0], q[1], q[2], q[3]
+	rot := [3][3]float64{
+		{q0*q0 + qx*qx - qy*qy - qz*qz, 2 * (qx*qy - q0*qz), 2 * (qx*qz + q0*qy)},
+		{2 * (qy*qx + q0*qz), q0*q0 - qx*qx + qy*qy - qz*qz, 2 * (qy*qz - q0*qx)},
+		{2 * (qz*qx - q0*qy), 2 * (qz*qy + q0*qx), q0*q0 - qx*qx - qy*qy + qz*qz},
+	}
+	rotate := func(v model.Vector) model.Vector {
+		return model.Vector{
+			X: rot[0][0]*v.X + rot[0][1]*v.Y + rot[0][2]*v.Z,
+			Y: rot[1][0]*v.X + rot[1][1]*v.Y + rot[1][2]*v.Z,
+			Z: rot[2][0]*v.X + rot[2][1]*v.Y + rot[2][2]*v.Z,
+		}
+	}
+	// least squares scale given the rotation
+	dot := 0.0
+	for i := range src {
+		dot += tgt[i].Dot(rotate(src[i]))
+	}
+	scale := dot / srcNorm
+	translation := tgtCenter.Subtract(rotate(srcCenter).Scale(scale))
+
+	var fwd [4][4]float64
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			fwd[r][c] = scale * rot[r][c]
+		}
+	}
+	fwd[0][3], fwd[1][3], fwd[2][3] = translation.X, translation.Y, translation.Z
+	fwd[3][3] = 1
+	transform, err := model.NewAffineTransform(fwd)
+	if err != nil {
+		return nil, err
+	}
+
+	fit := &HelmertFit{
+		Transform:   transform,
+		Scale:       scale,
+		Rotation:    rot,
+		Translation: translation,
+		Residuals:   make([]model.Vector, len(points)),
+	}
+	for i := range src {
+		// computed on the centered coordinates to avoid cancellation errors
+		fit.Residuals[i] = tgt[i].Subtract(rotate(src[i]).Scale(scale))
+		fit.RMS += fit.Residuals[i].Dot(fit.Residuals[i])
+	}
+	fit.RMS = math.Sqrt(fit.RMS / n)
+	return fit, nil
+}
+
+// collinear returns true if all the given coordinates, referred to their centroid, lie on a line
+func collinear(coords []model.Vector) bool {
+	// the farthest point from the centroid defines the direction of the line
+	var dir model.Vector
+	for _, c := range coords {
+		if c.Dot(c) > dir.Dot(dir) {
+			dir = c
+		}
+	}
+	if dir.Dot(dir) == 0 {
+		return true
+	}
+	dir = dir.Scale(1 / math.Sqrt(dir.Dot(dir)))
+	for _, c := range coords {
+		if d := c.Subtract(dir.Scale(c.Dot(dir))); d.Dot(d) > 1e-18*c.Dot(c)+1e-24 {
+			return false
+		}
+	}
+	return true
+}
+
+// largestEigenvector returns the unit eigenvector of the largest eigenvalue of the given symmetric matrix,
+// computed with the cyclic Jacobi eigenvalue algorithm
+func largestEigenvector(a [4][4]float64) [4]float64 {
+	v := [4][4]float64{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}
+	for sweep := 0; sweep < 50; sweep++ {
+		off := 0.0
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				off += a[p][q] * a[p][q]
+			}
+		}
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				// rotation zeroing a[p][q]
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := math.Copysign(1, theta) / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < 4; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < 4; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < 4; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+	largest := 0
+	for i := 1; i < 4; i++ {
+		if a[i][i] > a[largest][largest] {
+			largest = i
+		}
+	}
+	return [4]float64{v[0][largest], v[1][largest], v[2][largest], v[3][largest]}
+}
+
+// ReadControlPoints reads a CSV file listing on each row the source and target coordinates of a control point,
+// as source X, source Y, source Z, target X, target Y, target Z. An optional header row and lines starting with #
+// are skipped.
+func ReadControlPoints(path string) ([]ControlPoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = 6
+	r.TrimLeadingSpace = true
+	var points []ControlPoint
+	for row := 0; ; row++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid control points file %s: %w", path, err)
+		}
+		var vals [6]float64
+		for i, field := range record {
+			vals[i], err = strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if row == 0 {
+				// header row
+				continue
+			}
+			return nil, fmt.Errorf("invalid control points file %s: invalid coordinate in row %d", path, row+1)
+		}
+		points = append(points, ControlPoint{
+			Source: model.Vector{X: vals[0], Y: vals[1], Z: vals[2]},
+			Target: model.Vector{X: vals[3], Y: vals[4], Z: vals[5]},
+		})
+	}
+	return points, nil
+}
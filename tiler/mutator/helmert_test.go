@@ -0,0 +1,108 @@
+package mutator
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+func TestFitHelmert(t *testing.T) {
+	// local scanner coordinates rotated by 30 degrees around X and 120 degrees around Z, scaled and moved to UTM
+	// coordinates
+	ca, sa := math.Cos(30*math.Pi/180), math.Sin(30*math.Pi/180)
+	cb, sb := math.Cos(120*math.Pi/180), math.Sin(120*math.Pi/180)
+	scale := 1.0002
+	expected, err := model.NewAffineTransform([4][4]float64{
+		{scale * cb, -scale * sb * ca, scale * sb * sa, 500123.25},
+		{scale * sb, scale * cb * ca, -scale * cb * sa, 4649876.5},
+		{0, scale * sa, scale * ca, 102.75},
+		{0, 0, 0, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := []model.Vector{{X: 0, Y: 0, Z: 0}, {X: 50, Y: 0, Z: 1}, {X: 0, Y: 80, Z: -2}, {X: 40, Y: 60, Z: 10}, {X: -20, Y: 15, Z: 3}}
+	var points []ControlPoint
+	for _, s := range sources {
+		points = append(points, ControlPoint{Source: s, Target: expected.Forward(s)})
+	}
+	fit, err := FitHelmert(points)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if math.Abs(fit.Scale-scale) > 1e-9 {
+		t.Errorf("expected scale %f got %f", scale, fit.Scale)
+	}
+	if rx, ry, rz := fit.RotationAngles(); math.Abs(rx-30) > 1e-7 || math.Abs(ry) > 1e-7 || math.Abs(rz-120) > 1e-7 {
+		t.Errorf("expected rotation angles 30, 0, 120 got %f, %f, %f", rx, ry, rz)
+	}
+	if fit.RMS > 1e-6 {
+		t.Errorf("expected no residuals, got RMS %f", fit.RMS)
+	}
+	for _, s := range []model.Vector{{X: 1000, Y: -300, Z: 20}, {X: 5, Y: 5, Z: 5}} {
+		e, a := expected.Forward(s), fit.Transform.Forward(s)
+		if d := e.Subtract(a).Norm(); d > 1e-6 {
+			t.Errorf("expected %v got %v", e, a)
+		}
+		if d := fit.Transform.Inverse(a).Subtract(s).Norm(); d > 1e-6 {
+			t.Errorf("expected inverse %v got %v", s, fit.Transform.Inverse(a))
+		}
+	}
+
+	// an error on a control point is reported in its residual
+	points[4].Target.Z += 0.1
+	fit, err = FitHelmert(points)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(fit.Residuals) != 5 || fit.Residuals[4].Norm() < fit.Residuals[0].Norm() || fit.RMS < 0.01 || fit.RMS > 0.1 {
+		t.Errorf("unexpected residuals %v, RMS %f", fit.Residuals, fit.RMS)
+	}
+}
+
+func TestFitHelmertErrors(t *testing.T) {
+	point := func(x float64) ControlPoint {
+		return ControlPoint{Source: model.Vector{X: x, Y: 2 * x, Z: 3 * x}, Target: model.Vector{X: x}}
+	}
+	if _, err := FitHelmert([]ControlPoint{point(1), point(2)}); err == nil {
+		t.Errorf("expected error for too few control points")
+	}
+	if _, err := FitHelmert([]ControlPoint{point(1), point(2), point(5)}); err == nil {
+		t.Errorf("expected error for collinear control points")
+	}
+}
+
+func TestReadControlPoints(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "gcp.csv")
+	content := "sx,sy,sz,tx,ty,tz\n# first point\n1, 2, 3, 4, 5, 6\n-1.5,0,0,500000,4649776.25,100\n"
+	if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	points, err := ReadControlPoints(f)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []ControlPoint{
+		{Source: model.Vector{X: 1, Y: 2, Z: 3}, Target: model.Vector{X: 4, Y: 5, Z: 6}},
+		{Source: model.Vector{X: -1.5}, Target: model.Vector{X: 500000, Y: 4649776.25, Z: 100}},
+	}
+	if len(points) != len(expected) || points[0] != expected[0] || points[1] != expected[1] {
+		t.Errorf("expected %v got %v", expected, points)
+	}
+
+	if err := os.WriteFile(f, []byte("1,2,3,4,5,6\n1,2,x,4,5,6\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadControlPoints(f); err == nil {
+		t.Errorf("expected error for invalid coordinate")
+	}
+	if err := os.WriteFile(f, []byte("1,2,3,4,5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadControlPoints(f); err == nil {
+		t.Errorf("expected error for missing coordinate")
+	}
+}
@@ -17,3 +17,10 @@ type Mutator interface {
 	// or false if the point should be discarded from the final point cloud
 	Mutate(pt model.Point, localToGlobal model.Transform) (model.Point, bool)
 }
+
+// SourceMutator is optionally implemented by the mutators that transform the coordinates of the points as read
+// from the input, in the source CRS, before they are converted to the global EPSG 4978 CRS.
+type SourceMutator interface {
+	// MutateSource returns the transformed coordinates, expressed in the source CRS
+	MutateSource(coord model.Vector) model.Vector
+}
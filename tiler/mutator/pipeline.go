@@ -26,3 +26,13 @@ func (p *Pipeline) Mutate(pt model.Point, localToGlobal model.Transform) (model.
 	}
 	return pt, true
 }
+
+// MutateSource applies sequentially the registered mutators implementing SourceMutator
+func (p *Pipeline) MutateSource(coord model.Vector) model.Vector {
+	for _, m := range p.mutators {
+		if sm, ok := m.(SourceMutator); ok {
+			coord = sm.MutateSource(coord)
+		}
+	}
+	return coord
+}
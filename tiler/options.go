@@ -3,7 +3,11 @@ package tiler
 import (
 	"runtime"
 
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/writer"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
 	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/mutator"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
 	"github.com/mfbonfigli/gocesiumtiler/v2/version"
 )
 
@@ -23,21 +27,56 @@ const (
 	EventExportStarted
 	EventExportCompleted
 	EventExportError
+	// EventPointLoadingWarning reports issues found while loading the points that do not stop the processing
+	EventPointLoadingWarning
 )
 
 type TilerOptions struct {
-	gridSize         float64
-	maxDepth         int
-	mutators         []mutator.Mutator
-	eightBitColors   bool
-	numWorkers       int
-	minPointsPerTile int
-	callback         TilerCallback
-	version          version.TilesetVersion
+	gridSize          float64
+	maxDepth          int
+	mutators          []mutator.Mutator
+	eightBitColors    bool
+	numWorkers        int
+	minPointsPerTile  int
+	callback          TilerCallback
+	version           version.TilesetVersion
+	copcBounds        *geom.BoundingBox
+	copcMaxLevel      int
+	textColumns       string
+	textDelimiter     string
+	textSkipRows      int
+	textColorBits     int
+	lasAttributes     []string
+	extraDimensions   []string
+	draco             *writer.DracoOptions
+	quantizePositions bool
+	rgb565            bool
+	meshopt           bool
+	subtreeLevels     int
+	archive           bool
+	storage           storage.Storage
+	gzip              writer.GzipMode
+	memoryLimit       int64
+	tempDir           string
+	parallelBuild     bool
+	converter         ConverterEngine
+	fileCRS           map[string]string
 }
 
 type tilerOptionsFn func(*TilerOptions)
 
+// ConverterEngine selects the implementation used to convert the point coordinates
+type ConverterEngine int
+
+const (
+	// ConverterAuto uses the built-in converter if it supports the CRS of the points, PROJ otherwise
+	ConverterAuto ConverterEngine = iota
+	// ConverterNative only uses the built-in pure Go converter, failing if the CRS is not supported
+	ConverterNative
+	// ConverterProj only uses PROJ
+	ConverterProj
+)
+
 type TilerCallback func(event TilerEvent, inputDesc string, elapsed int64, msg string)
 
 // NewDefaultTilerOptions returns sensible defaults for tiling options
@@ -50,6 +89,7 @@ func NewDefaultTilerOptions() *TilerOptions {
 		eightBitColors:   false,
 		callback:         nil,
 		version:          version.TilesetVersion_1_0,
+		copcMaxLevel:     -1,
 	}
 }
 
@@ -121,3 +161,175 @@ func WithTilesetVersion(v version.TilesetVersion) tilerOptionsFn {
 		opt.version = v
 	}
 }
+
+// WithCopcBounds restricts the points read from COPC input files to the ones stored in the octree nodes
+// intersecting the bounding box with the given min and max corners, expressed in the source CRS.
+// Points are selected node by node hence some points slightly outside the bounds can be included.
+func WithCopcBounds(min, max model.Vector) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		bbox := geom.NewBoundingBox(min.X, max.X, min.Y, max.Y, min.Z, max.Z)
+		opt.copcBounds = &bbox
+	}
+}
+
+// WithCopcMaxLevel restricts the points read from COPC input files to the ones stored in the octree nodes
+// up to the given level, 0 being the root. A negative level means no restriction.
+func WithCopcMaxLevel(level int) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.copcMaxLevel = level
+	}
+}
+
+// WithTextFormat sets the tiler to read the input files as point clouds stored as delimited text (XYZ, CSV, TXT)
+// rather than LAS files. The columns spec lists the content of each column using the identifiers
+// x, y, z, r, g, b, i (intensity), c (classification) or _ (ignored column), for example "x,y,z,r,g,b".
+// An empty delimiter means columns are separated by whitespaces. skipRows sets the number of header rows to skip.
+// Text files carry no CRS information, hence the source CRS must always be provided.
+func WithTextFormat(columns string, delimiter string, skipRows int) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.textColumns = columns
+		opt.textDelimiter = delimiter
+		opt.textSkipRows = skipRows
+	}
+}
+
+// WithTextColorBits sets the bit depth of the colors of the text input files, 8 or 16. If not set the colors are
+// read as 16 bit colors unless 8 bit colors are enabled with WithEightBitColors.
+func WithTextColorBits(bits int) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.textColorBits = bits
+	}
+}
+
+// WithLasAttributes sets the standard LAS point attributes to store in the tiles besides colors, intensity and
+// classification, e.g. GPS time or return number. See the las.LasAttribute constants for the allowed names.
+func WithLasAttributes(names ...string) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.lasAttributes = names
+	}
+}
+
+// WithExtraDimensions sets the names of the LAS extra bytes dimensions to read from the input files and to store
+// in the tiles, in the pnts batch table or as glTF EXT_structural_metadata property attributes.
+// All input files must declare the given dimensions in their Extra Bytes VLR.
+func WithExtraDimensions(names ...string) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.extraDimensions = names
+	}
+}
+
+// WithDraco enables the Draco compression of the tiles. positionBits sets the number of bits used to quantize the
+// point coordinates, colorBits the number of bits retained for each color component (up to 8) and attributeBits the
+// number of bits used to quantize the floating point attributes. Intensity, classification and integer attributes
+// are always compressed losslessly.
+func WithDraco(positionBits, colorBits, attributeBits int) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.draco = &writer.DracoOptions{
+			PositionBits:  positionBits,
+			ColorBits:     colorBits,
+			AttributeBits: attributeBits,
+		}
+	}
+}
+
+// WithQuantizedPositions stores the point coordinates as 16 bit integers relative to the bounds of each tile,
+// halving the space taken by the coordinates. 1.0 tilesets use POSITION_QUANTIZED while 1.1 tilesets use the
+// KHR_mesh_quantization extension. Ignored if Draco is enabled.
+func WithQuantizedPositions(quantize bool) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.quantizePositions = quantize
+	}
+}
+
+// WithRGB565 stores the point colors of 1.0 tilesets as RGB565, using 16 bits per point instead of 24.
+// Ignored if Draco is enabled.
+func WithRGB565(rgb565 bool) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.rgb565 = rgb565
+	}
+}
+
+// WithMeshopt compresses the vertex buffers of 1.1 tilesets using the EXT_meshopt_compression extension, which is
+// faster to decode than Draco. Ignored if Draco is enabled.
+func WithMeshopt(meshopt bool) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.meshopt = meshopt
+	}
+}
+
+// WithImplicitTiling writes 1.1 tilesets using the octree implicit tiling scheme, with availability subtrees spanning
+// the given number of levels, instead of a tileset.json for each non leaf tile. Zero disables implicit tiling.
+func WithImplicitTiling(subtreeLevels int) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.subtreeLevels = subtreeLevels
+	}
+}
+
+// WithArchive writes each tileset as a single tileset.3tz 3D Tiles archive, stored in the tileset output folder,
+// instead of a tree of folders and files.
+func WithArchive(archive bool) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.archive = archive
+	}
+}
+
+// WithStorage writes the tilesets to the given storage, like an in memory one. The output folder passed to the tiler
+// is then the slash separated path of the output folder in the storage. By default the output folder is the
+// location of the storage, either a local path or an s3://bucket/prefix URL.
+func WithStorage(s storage.Storage) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.storage = s
+	}
+}
+
+// WithGzip gzip compresses the tileset.json, subtree and tile files. If sidecar is false the compressed files replace
+// the original ones, to be served with the Content-Encoding: gzip header, else they are written alongside them with
+// the .gz extension. Storages supporting it, like S3, record the gzip content encoding of the replaced files.
+// Brotli compression is not supported.
+func WithGzip(sidecar bool) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.gzip = writer.GzipReplace
+		if sidecar {
+			opt.gzip = writer.GzipSidecar
+		}
+	}
+}
+
+// WithMemoryLimit builds the tree out of core, spilling the points to temporary files, so to hold approximately at
+// most the given number of megabytes of points in memory. Needed for point clouds that do not fit in RAM.
+// If the limit is too low to sample the largest tree nodes the tiling fails, reporting the memory required.
+// tempDir is the folder where to store the temporary files, the system temporary folder if empty.
+// Zero or a negative limit builds the tree fully in memory.
+func WithMemoryLimit(megabytes int, tempDir string) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.memoryLimit = int64(megabytes) << 20
+		opt.tempDir = tempDir
+	}
+}
+
+// WithParallelBuild builds the tree nodes concurrently using the configured number of workers, building the deeper
+// levels of the tree in background while the shallower ones are exported. The output is identical to the one of the
+// serial build. With the memory limit the subtrees loaded in memory are built concurrently.
+func WithParallelBuild(parallel bool) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.parallelBuild = parallel
+	}
+}
+
+// WithConverterEngine selects the implementation used to convert the point coordinates. The built-in converter supports
+// EPSG:4326, EPSG:4979, EPSG:4978, EPSG:3857 and the WGS84 and ETRS89 UTM zones without requiring PROJ and its
+// share folder. By default the built-in converter is used if it supports the CRS of the points, PROJ otherwise.
+func WithConverterEngine(engine ConverterEngine) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.converter = engine
+	}
+}
+
+// WithFileCRS sets the CRS of specific input files, mapping their path to their CRS. The files not in the map use
+// the source CRS passed to the tiler, or the one autodetected from their metadata if that is empty. Paths are compared
+// after being made absolute.
+func WithFileCRS(crss map[string]string) tilerOptionsFn {
+	return func(opt *TilerOptions) {
+		opt.fileCRS = crss
+	}
+}
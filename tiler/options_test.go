@@ -1,9 +1,14 @@
 package tiler
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/writer"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
 	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/mutator"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
 )
 
 func TestOptions(t *testing.T) {
@@ -16,6 +21,24 @@ func TestOptions(t *testing.T) {
 		WithMinPointsPerTile(10),
 		WithWorkerNumber(3),
 		WithMutators([]mutator.Mutator{m}),
+		WithCopcBounds(model.Vector{X: 1, Y: 2, Z: 3}, model.Vector{X: 4, Y: 5, Z: 6}),
+		WithCopcMaxLevel(4),
+		WithTextFormat("x,y,z", ";", 2),
+		WithTextColorBits(8),
+		WithLasAttributes("gps-time", "nir"),
+		WithExtraDimensions("Colors", "Time"),
+		WithDraco(14, 6, 16),
+		WithQuantizedPositions(true),
+		WithRGB565(true),
+		WithMeshopt(true),
+		WithImplicitTiling(5),
+		WithArchive(true),
+		WithStorage(storage.NewMemoryStorage()),
+		WithGzip(true),
+		WithMemoryLimit(512, "/tmp/spill"),
+		WithParallelBuild(true),
+		WithConverterEngine(ConverterNative),
+		WithFileCRS(map[string]string{"a.las": "EPSG:32632"}),
 	)
 
 	if opts.callback == nil {
@@ -39,4 +62,53 @@ func TestOptions(t *testing.T) {
 	if opts.mutators[0] != m && len(opts.mutators) != 1 {
 		t.Error("expected 1 mutator to be registered")
 	}
+	if expected := geom.NewBoundingBox(1, 4, 2, 5, 3, 6); opts.copcBounds == nil || *opts.copcBounds != expected {
+		t.Errorf("expected copcBounds to be %v got %v", expected, opts.copcBounds)
+	}
+	if opts.copcMaxLevel != 4 {
+		t.Errorf("expected copcMaxLevel to be %v got %v", 4, opts.copcMaxLevel)
+	}
+	if opts.textColumns != "x,y,z" || opts.textDelimiter != ";" || opts.textSkipRows != 2 || opts.textColorBits != 8 {
+		t.Errorf("unexpected text format options %v %v %v %v", opts.textColumns, opts.textDelimiter, opts.textSkipRows, opts.textColorBits)
+	}
+	if len(opts.lasAttributes) != 2 || opts.lasAttributes[0] != "gps-time" || opts.lasAttributes[1] != "nir" {
+		t.Errorf("expected lasAttributes to be %v got %v", []string{"gps-time", "nir"}, opts.lasAttributes)
+	}
+	if len(opts.extraDimensions) != 2 || opts.extraDimensions[0] != "Colors" || opts.extraDimensions[1] != "Time" {
+		t.Errorf("expected extraDimensions to be %v got %v", []string{"Colors", "Time"}, opts.extraDimensions)
+	}
+	if expected := (writer.DracoOptions{PositionBits: 14, ColorBits: 6, AttributeBits: 16}); opts.draco == nil || *opts.draco != expected {
+		t.Errorf("expected draco to be %v got %v", expected, opts.draco)
+	}
+	if !opts.quantizePositions || !opts.rgb565 || !opts.meshopt {
+		t.Errorf("expected quantizePositions, rgb565 and meshopt to be true got %v %v %v", opts.quantizePositions, opts.rgb565, opts.meshopt)
+	}
+	if opts.subtreeLevels != 5 {
+		t.Errorf("expected subtreeLevels to be %v got %v", 5, opts.subtreeLevels)
+	}
+	if !opts.archive {
+		t.Errorf("expected archive to be true")
+	}
+	if _, ok := opts.storage.(*storage.MemoryStorage); !ok {
+		t.Errorf("expected memory storage got %v", opts.storage)
+	}
+	if opts.gzip != writer.GzipSidecar {
+		t.Errorf("expected gzip mode %v got %v", writer.GzipSidecar, opts.gzip)
+	}
+	if opts.memoryLimit != 512<<20 || opts.tempDir != "/tmp/spill" {
+		t.Errorf("expected memory limit %v in %v got %v in %v", 512<<20, "/tmp/spill", opts.memoryLimit, opts.tempDir)
+	}
+	if !opts.parallelBuild {
+		t.Errorf("expected parallel build to be true")
+	}
+	if opts.converter != ConverterNative {
+		t.Errorf("expected converter engine %v got %v", ConverterNative, opts.converter)
+	}
+	if expected := map[string]string{"a.las": "EPSG:32632"}; !reflect.DeepEqual(opts.fileCRS, expected) {
+		t.Errorf("expected file crs %v got %v", expected, opts.fileCRS)
+	}
+	WithGzip(false)(opts)
+	if opts.gzip != writer.GzipReplace {
+		t.Errorf("expected gzip mode %v got %v", writer.GzipReplace, opts.gzip)
+	}
 }
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/utils"
+)
+
+// FileStorage stores the files in a folder of the local file system
+type FileStorage struct {
+	root string
+}
+
+// NewFileStorage returns a storage writing the files under the given root folder. If the root is empty
+// the paths are resolved against the working directory, or used as they are if absolute.
+func NewFileStorage(root string) *FileStorage {
+	return &FileStorage{root: root}
+}
+
+// Create creates the file at the given path, creating its parent folders if they do not exist
+func (s *FileStorage) Create(path string) (io.WriteCloser, error) {
+	file := filepath.Join(s.root, filepath.FromSlash(path))
+	if err := utils.CreateDirectoryIfDoesNotExist(filepath.Dir(file)); err != nil {
+		return nil, err
+	}
+	return os.Create(file)
+}
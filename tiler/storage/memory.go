@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"sort"
+	"sync"
+)
+
+// MemoryStorage keeps the files in memory, which is useful to post process the tilesets or to test the output
+type MemoryStorage struct {
+	files     map[string][]byte
+	encodings map[string]string
+	mu        sync.RWMutex
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		files:     map[string][]byte{},
+		encodings: map[string]string{},
+	}
+}
+
+// Create returns a writer buffering the file content, which is stored when the writer is closed
+func (s *MemoryStorage) Create(p string) (io.WriteCloser, error) {
+	return &memoryFile{storage: s, path: path.Clean(p)}, nil
+}
+
+// CreateWithEncoding works like Create, recording the content encoding of the file
+func (s *MemoryStorage) CreateWithEncoding(p string, encoding string) (io.WriteCloser, error) {
+	return &memoryFile{storage: s, path: path.Clean(p), encoding: encoding}, nil
+}
+
+// Encoding returns the content encoding of the file at the given path, empty if not set
+func (s *MemoryStorage) Encoding(p string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.encodings[path.Clean(p)]
+}
+
+// Get returns the content of the file at the given path and true if it exists
+func (s *MemoryStorage) Get(p string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.files[path.Clean(p)]
+	return data, ok
+}
+
+// Files returns the sorted paths of the stored files
+func (s *MemoryStorage) Files() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	files := make([]string, 0, len(s.files))
+	for f := range s.files {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+type memoryFile struct {
+	bytes.Buffer
+	storage  *MemoryStorage
+	path     string
+	encoding string
+}
+
+func (f *memoryFile) Close() error {
+	f.storage.mu.Lock()
+	defer f.storage.mu.Unlock()
+	f.storage.files[f.path] = f.Bytes()
+	if f.encoding != "" {
+		f.storage.encodings[f.path] = f.encoding
+	} else {
+		delete(f.storage.encodings, f.path)
+	}
+	return nil
+}
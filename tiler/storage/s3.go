@@ -0,0 +1,434 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3DefaultRegion = "us-east-1"
+	s3Service       = "s3"
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3TimeFormat    = "20060102T150405Z"
+	// s3PartSize is the size of the parts of the multipart uploads, above the 5 MB minimum required by S3
+	s3PartSize = 16 << 20
+)
+
+// S3Storage uploads the files as objects of a bucket of an S3 compatible object store, authenticating the
+// requests with AWS Signature Version 4. Files smaller than 16 MB are buffered in memory and uploaded with a
+// single PUT request when closed, larger files are streamed with a multipart upload, buffering one part at a time.
+type S3Storage struct {
+	bucket       string
+	prefix       string
+	region       string
+	endpoint     string
+	endpointURL  *url.URL
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+	partSize     int
+	now          func() time.Time
+}
+
+// NewS3Storage returns a storage writing the files under the given prefix of the bucket. The region, the
+// credentials and the endpoint are read from the AWS_REGION, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN and AWS_ENDPOINT_URL_S3 (or AWS_ENDPOINT_URL) environment variables, and can be
+// overridden with the options. Custom endpoints, like a MinIO server, are addressed in path style.
+func NewS3Storage(bucket string, prefix string, optFn ...func(*S3Storage)) (*S3Storage, error) {
+	s := &S3Storage{
+		bucket:       bucket,
+		prefix:       strings.Trim(prefix, "/"),
+		region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       http.DefaultClient,
+		partSize:     s3PartSize,
+		now:          time.Now,
+	}
+	if s.region == "" {
+		s.region = s3DefaultRegion
+	}
+	s.endpoint = firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL")
+	for _, fn := range optFn {
+		fn(s)
+	}
+	if s.bucket == "" {
+		return nil, errors.New("s3 bucket not set")
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, errors.New("s3 credentials not set, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+	if s.endpoint != "" {
+		u, err := url.Parse(strings.TrimSuffix(s.endpoint, "/"))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid s3 endpoint %s", s.endpoint)
+		}
+		s.endpointURL = u
+	}
+	return s, nil
+}
+
+// WithS3Endpoint sets the URL of an S3 compatible endpoint, addressing the buckets in path style
+func WithS3Endpoint(endpoint string) func(*S3Storage) {
+	return func(s *S3Storage) {
+		s.endpoint = endpoint
+	}
+}
+
+// WithS3Region sets the region used to sign the requests
+func WithS3Region(region string) func(*S3Storage) {
+	return func(s *S3Storage) {
+		s.region = region
+	}
+}
+
+// WithS3Credentials sets the credentials used to sign the requests. The session token can be empty.
+func WithS3Credentials(accessKey, secretKey, sessionToken string) func(*S3Storage) {
+	return func(s *S3Storage) {
+		s.accessKey = accessKey
+		s.secretKey = secretKey
+		s.sessionToken = sessionToken
+	}
+}
+
+// WithS3HTTPClient sets the HTTP client used to perform the requests
+func WithS3HTTPClient(client *http.Client) func(*S3Storage) {
+	return func(s *S3Storage) {
+		s.client = client
+	}
+}
+
+// Create returns a writer uploading the file content, which is stored when the writer is closed
+func (s *S3Storage) Create(p string) (io.WriteCloser, error) {
+	return &s3Object{storage: s, key: path.Join(s.prefix, p)}, nil
+}
+
+// CreateWithEncoding returns a writer uploading the file content, which is stored with the given
+// Content-Encoding metadata when the writer is closed
+func (s *S3Storage) CreateWithEncoding(p string, encoding string) (io.WriteCloser, error) {
+	return &s3Object{storage: s, key: path.Join(s.prefix, p), encoding: encoding}, nil
+}
+
+// objectURL returns the URL of the object with the given key
+func (s *S3Storage) objectURL(key string) *url.URL {
+	if s.endpointURL == nil {
+		// AWS endpoints use the virtual hosted style
+		return &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", s.bucket, s.region), Path: "/" + key}
+	}
+	u := *s.endpointURL
+	u.Path = path.Join("/", u.Path, s.bucket, key)
+	return &u
+}
+
+// objectHeaders returns the headers storing the content type and encoding of the object
+func objectHeaders(key string, encoding string) http.Header {
+	h := http.Header{}
+	h.Set("Content-Type", contentType(key))
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+	}
+	return h
+}
+
+// do performs a signed request on the object with the given key, returning the response headers and body
+func (s *S3Storage) do(method string, key string, query url.Values, header http.Header, body []byte) (http.Header, []byte, error) {
+	u := s.objectURL(key)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, nil, fmt.Errorf("%s %s", resp.Status, msg)
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	return resp.Header, respBody, err
+}
+
+// put uploads the object with a single request
+func (s *S3Storage) put(key string, data []byte, encoding string) error {
+	if _, _, err := s.do(http.MethodPut, key, nil, objectHeaders(key, encoding), data); err != nil {
+		return fmt.Errorf("unable to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// createMultipartUpload starts a multipart upload of the object, returning its upload ID
+func (s *S3Storage) createMultipartUpload(key string, encoding string) (string, error) {
+	_, body, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, objectHeaders(key, encoding), nil)
+	if err != nil {
+		return "", err
+	}
+	result := struct {
+		UploadID string `xml:"UploadId"`
+	}{}
+	if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("invalid multipart upload response %s", body)
+	}
+	return result.UploadID, nil
+}
+
+// uploadPart uploads a part of a multipart upload, returning its ETag
+func (s *S3Storage) uploadPart(key string, uploadID string, number int, data []byte) (string, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	h, _, err := s.do(http.MethodPut, key, query, nil, data)
+	if err != nil {
+		return "", err
+	}
+	return h.Get("ETag"), nil
+}
+
+// completeMultipartUpload stores the object made of the uploaded parts, whose ETags are given in order
+func (s *S3Storage) completeMultipartUpload(key string, uploadID string, etags []string) error {
+	type part struct {
+		PartNumber int
+		ETag       string
+	}
+	request := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{}
+	for i, etag := range etags {
+		request.Parts = append(request.Parts, part{PartNumber: i + 1, ETag: etag})
+	}
+	data, err := xml.Marshal(request)
+	if err != nil {
+		return err
+	}
+	_, body, err := s.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, nil, data)
+	if err != nil {
+		return err
+	}
+	// the completion can fail after the response status has been sent, in which case the body reports the error
+	if bytes.Contains(body, []byte("<Error>")) {
+		return fmt.Errorf("%s", body)
+	}
+	return nil
+}
+
+// abortMultipartUpload discards the parts of a failed multipart upload
+func (s *S3Storage) abortMultipartUpload(key string, uploadID string) error {
+	_, _, err := s.do(http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	return err
+}
+
+// sign adds to the request the headers required by the AWS Signature Version 4, signing the host, the content
+// type and encoding, the range and all the x-amz-* headers
+func (s *S3Storage) sign(req *http.Request, payloadHash string, t time.Time) {
+	amzDate := t.UTC().Format(s3TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || name == "content-encoding" || name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{amzDate[:8], s.region, s3Service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{amzDate[:8], s.region, s3Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", s3Algorithm, s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// escapePath encodes the path as required by the canonical request, leaving the slashes unescaped
+func escapePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = escape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// canonicalQuery returns the query parameters sorted by name and encoded as required by the canonical request
+func canonicalQuery(q url.Values) string {
+	names := make([]string, 0, len(q))
+	for name := range q {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := []string{}
+	for _, name := range names {
+		values := q[name]
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, escape(name)+"="+escape(v))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// escape percent encodes all the characters but the unreserved ones
+func escape(s string) string {
+	out := strings.Builder{}
+	for _, b := range []byte(s) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || b == '-' || b == '_' || b == '.' || b == '~' {
+			out.WriteByte(b)
+		} else {
+			fmt.Fprintf(&out, "%%%02X", b)
+		}
+	}
+	return out.String()
+}
+
+// contentType returns the media type of the tileset files, based on their extension
+func contentType(key string) string {
+	switch path.Ext(key) {
+	case ".json":
+		return "application/json"
+	case ".glb":
+		return "model/gltf-binary"
+	}
+	return "application/octet-stream"
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// s3Object buffers the content of an object until it reaches the part size, switching then to a multipart upload
+// to which each part is uploaded as soon as it is complete
+type s3Object struct {
+	buf      bytes.Buffer
+	storage  *S3Storage
+	key      string
+	encoding string
+	uploadID string
+	etags    []string
+	err      error
+}
+
+func (o *s3Object) Write(p []byte) (int, error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+	o.buf.Write(p)
+	for o.buf.Len() >= o.storage.partSize {
+		if err := o.uploadPart(o.buf.Next(o.storage.partSize)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// uploadPart uploads the next part, starting the multipart upload if needed
+func (o *s3Object) uploadPart(data []byte) error {
+	s := o.storage
+	if o.uploadID == "" {
+		o.uploadID, o.err = s.createMultipartUpload(o.key, o.encoding)
+		if o.err != nil {
+			o.err = fmt.Errorf("unable to upload %s: %w", o.key, o.err)
+			return o.err
+		}
+	}
+	etag, err := s.uploadPart(o.key, o.uploadID, len(o.etags)+1, data)
+	if err != nil {
+		o.fail(err)
+		return o.err
+	}
+	o.etags = append(o.etags, etag)
+	return nil
+}
+
+// fail records the error and aborts the multipart upload, so that the uploaded parts are not retained
+func (o *s3Object) fail(err error) {
+	o.err = fmt.Errorf("unable to upload %s: %w", o.key, err)
+	if abortErr := o.storage.abortMultipartUpload(o.key, o.uploadID); abortErr != nil {
+		o.err = fmt.Errorf("%w, unable to abort the multipart upload: %v", o.err, abortErr)
+	}
+}
+
+func (o *s3Object) Close() error {
+	if o.err != nil {
+		return o.err
+	}
+	if o.uploadID == "" {
+		if err := o.storage.put(o.key, o.buf.Bytes(), o.encoding); err != nil {
+			o.err = err
+			return err
+		}
+	} else {
+		// the last part can be smaller than the part size, but not empty unless it is the only one
+		if o.buf.Len() > 0 {
+			if err := o.uploadPart(o.buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		if err := o.storage.completeMultipartUpload(o.key, o.uploadID, o.etags); err != nil {
+			o.fail(err)
+			return o.err
+		}
+	}
+	o.err = errors.New("object already closed")
+	return nil
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage stores the files of the generated tilesets. Implementations must be safe for concurrent use.
+type Storage interface {
+	// Create returns a writer for the file at the given slash separated path, relative to the storage root.
+	// The file is stored once the writer is successfully closed.
+	Create(path string) (io.WriteCloser, error)
+}
+
+// EncodedStorage is implemented by the storages able to record the content encoding of the files, like the
+// S3 object metadata, so that they can be served with the right Content-Encoding header
+type EncodedStorage interface {
+	Storage
+	// CreateWithEncoding works like Create, recording that the file content has the given encoding, e.g. gzip
+	CreateWithEncoding(path string, encoding string) (io.WriteCloser, error)
+}
+
+// New returns the storage for the given output location, that can either be an s3://bucket/prefix URL, to write
+// to an S3 compatible object store configured via the standard AWS environment variables, or a local folder
+// expressed as a path or a file:// URL.
+func New(location string) (Storage, error) {
+	scheme, rest, ok := strings.Cut(location, "://")
+	if !ok {
+		return NewFileStorage(location), nil
+	}
+	switch strings.ToLower(scheme) {
+	case "file":
+		return NewFileStorage(filepath.FromSlash(rest)), nil
+	case "s3":
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid s3 location %s: %w", location, err)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid s3 location %s: missing bucket", location)
+		}
+		return NewS3Storage(u.Host, strings.Trim(u.Path, "/"))
+	}
+	return nil, fmt.Errorf("unsupported storage scheme %s", scheme)
+}
+
+// Join appends the given elements to the output location, which can either be a path or an URL
+func Join(location string, elem ...string) string {
+	if _, _, ok := strings.Cut(location, "://"); ok {
+		return strings.TrimSuffix(location, "/") + "/" + path.Join(elem...)
+	}
+	return filepath.Join(append([]string{location}, elem...)...)
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func write(t *testing.T, s Storage, path string, content string) {
+	t.Helper()
+	f, err := s.Create(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := io.WriteString(f, content); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_S3", "")
+	t.Setenv("AWS_ENDPOINT_URL", "")
+	s, err := New("s3://bucket/some/prefix/")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if s3, ok := s.(*S3Storage); !ok || s3.bucket != "bucket" || s3.prefix != "some/prefix" {
+		t.Errorf("unexpected storage %+v", s)
+	}
+	s, err = New("/tmp/out")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if fs, ok := s.(*FileStorage); !ok || fs.root != "/tmp/out" {
+		t.Errorf("unexpected storage %+v", s)
+	}
+	s, err = New("file:///tmp/out")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if fs, ok := s.(*FileStorage); !ok || fs.root != filepath.FromSlash("/tmp/out") {
+		t.Errorf("unexpected storage %+v", s)
+	}
+	for _, location := range []string{"ftp://host/folder", "s3:///prefix"} {
+		if _, err := New(location); err == nil {
+			t.Errorf("expected error for location %s", location)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	if actual := Join("s3://bucket/prefix/", "a", "b"); actual != "s3://bucket/prefix/a/b" {
+		t.Errorf("unexpected url %s", actual)
+	}
+	if actual := Join("out", "a"); actual != filepath.Join("out", "a") {
+		t.Errorf("unexpected path %s", actual)
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	tmp := t.TempDir()
+	write(t, NewFileStorage(tmp), "a/b/file.json", "{}")
+	b, err := os.ReadFile(filepath.Join(tmp, "a", "b", "file.json"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(b) != "{}" {
+		t.Errorf("unexpected content %s", b)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	s := NewMemoryStorage()
+	write(t, s, "b/file.pnts", "pnts")
+	write(t, s, "a/tileset.json", "{}")
+	f, err := s.Create("c/not_closed.json")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	io.WriteString(f, "{}")
+	if actual := s.Files(); !reflect.DeepEqual(actual, []string{"a/tileset.json", "b/file.pnts"}) {
+		t.Errorf("unexpected files %v", actual)
+	}
+	if data, ok := s.Get("b/file.pnts"); !ok || string(data) != "pnts" {
+		t.Errorf("unexpected content %s", data)
+	}
+	if _, ok := s.Get("c/not_closed.json"); ok {
+		t.Errorf("expected files not to be stored until closed")
+	}
+	f, _ = s.CreateWithEncoding("a/tileset.json", "gzip")
+	f.Close()
+	if actual := s.Encoding("a/tileset.json"); actual != "gzip" {
+		t.Errorf("expected gzip encoding got %q", actual)
+	}
+	if actual := s.Encoding("b/file.pnts"); actual != "" {
+		t.Errorf("expected no encoding got %q", actual)
+	}
+}
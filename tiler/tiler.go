@@ -3,18 +3,23 @@ package tiler
 import (
 	"context"
 	"fmt"
+	"io"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/conv/coor"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/conv/coor/native"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/conv/coor/proj"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree/grid"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/utils"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/writer"
 	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/mutator"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
 )
 
 type Tiler interface {
@@ -25,38 +30,87 @@ type Tiler interface {
 // GoCesiumTiler wraps the logic required to convert
 // LAS point clouds into Cesium 3D tiles
 type GoCesiumTiler struct {
-	convFactory coor.ConverterFactory
+	converterProvider
 	treeProvider
 	writerProvider
 	lasReaderProvider
 }
 
+type converterProvider func(opts *TilerOptions) coor.ConverterFactory
 type treeProvider func(opts *TilerOptions) tree.Tree
-type writerProvider func(folder string, opts *TilerOptions) (writer.Writer, error)
-type lasReaderProvider func(inputLasFiles []string, sourceCRS string, eightbit bool) (las.LasReader, error)
+type writerProvider func(folder string, opts *TilerOptions, attributes []geom.Attribute) (writer.Writer, error)
+type lasReaderProvider func(inputLasFiles []string, sourceCRS string, opts *TilerOptions) (las.LasReader, error)
 
 // NewGoCesiumTiler returns a new tiler to be used to convert LAS files into Cesium 3D Tiles
 func NewGoCesiumTiler() (*GoCesiumTiler, error) {
 	return &GoCesiumTiler{
-		convFactory: func() (coor.Converter, error) {
-			return proj.NewProjCoordinateConverter()
+		converterProvider: func(opts *TilerOptions) coor.ConverterFactory {
+			// in builds without PROJ the factory fails, hence only the CRSs supported natively can be converted
+			projFactory := func() (coor.Converter, error) {
+				c, err := proj.NewProjCoordinateConverter()
+				if err != nil {
+					return nil, err
+				}
+				return c, nil
+			}
+			switch opts.converter {
+			case ConverterProj:
+				return projFactory
+			case ConverterNative:
+				return func() (coor.Converter, error) {
+					return native.NewNativeCoordinateConverter(), nil
+				}
+			}
+			return func() (coor.Converter, error) {
+				return coor.NewFallbackConverter(native.NewNativeCoordinateConverter(), native.Supports, projFactory), nil
+			}
 		},
 		treeProvider: func(opts *TilerOptions) tree.Tree {
-			return grid.NewTree(
+			gridOpts := []func(*grid.Node){
 				grid.WithGridSize(opts.gridSize),
 				grid.WithMaxDepth(opts.maxDepth),
 				grid.WithLoadWorkersNumber(opts.numWorkers),
 				grid.WithMinPointsPerChildren(opts.minPointsPerTile),
-			)
+				grid.WithParallelBuild(opts.parallelBuild),
+			}
+			if opts.memoryLimit > 0 {
+				return grid.NewOutOfCoreTree(opts.memoryLimit, opts.tempDir, gridOpts...)
+			}
+			return grid.NewTree(gridOpts...)
 		},
-		writerProvider: func(folder string, opts *TilerOptions) (writer.Writer, error) {
-			return writer.NewWriter(folder,
+		writerProvider: func(folder string, opts *TilerOptions, attributes []geom.Attribute) (writer.Writer, error) {
+			writerOpts := []func(*writer.StandardWriter){
 				writer.WithNumWorkers(opts.numWorkers),
 				writer.WithTilesetVersion(opts.version),
-			)
+				writer.WithAttributes(attributes),
+				writer.WithQuantizedPositions(opts.quantizePositions),
+				writer.WithRGB565(opts.rgb565),
+				writer.WithMeshopt(opts.meshopt),
+				writer.WithImplicitTiling(opts.subtreeLevels),
+				writer.WithArchive(opts.archive),
+				writer.WithGzipCompression(opts.gzip),
+			}
+			if opts.draco != nil {
+				writerOpts = append(writerOpts, writer.WithDraco(*opts.draco))
+			}
+			if opts.storage != nil {
+				writerOpts = append(writerOpts, writer.WithStorage(opts.storage))
+			}
+			return writer.NewWriter(folder, writerOpts...)
 		},
-		lasReaderProvider: func(inputLasFiles []string, sourceCRS string, eightbit bool) (las.LasReader, error) {
-			return las.NewCombinedFileLasReader(inputLasFiles, sourceCRS, eightbit)
+		lasReaderProvider: func(inputLasFiles []string, sourceCRS string, opts *TilerOptions) (las.LasReader, error) {
+			fileCRS := las.SameCRS(sourceCRS)
+			if len(opts.fileCRS) > 0 {
+				fileCRS = las.MappedCRS(opts.fileCRS, sourceCRS)
+			}
+			if opts.textColumns != "" {
+				format, err := las.NewTextFormat(opts.textColumns, opts.textDelimiter, opts.textSkipRows, opts.textColorBits)
+				if err != nil {
+					return nil, err
+				}
+				return las.NewCombinedFileTextReader(inputLasFiles, fileCRS, opts.eightBitColors, format)
+			}
+			return las.NewCombinedFileLasReader(inputLasFiles, fileCRS, opts.eightBitColors, opts.lasAttributes, opts.extraDimensions, copcReaderOptions(opts)...)
 		},
 	}, nil
 }
@@ -64,14 +118,20 @@ func NewGoCesiumTiler() (*GoCesiumTiler, error) {
 // ProcessFolder converts all LAS files found in the provided input folder converting them into separate tilesets
 // each tileset is stored in a subdirectory in the outputFolder named after the filename.
 // If sourceCRS is left empty, the CRS will attempted to be autodetected from LAS GeoTIFF or WKT VLRs.
+// The CRSs set per file in the options take precedence over sourceCRS.
+// If a text format has been set in the options, XYZ, CSV and TXT files are converted instead.
 func (t *GoCesiumTiler) ProcessFolder(inputFolder, outputFolder string, sourceCRS string, opts *TilerOptions, ctx context.Context) error {
-	files, err := utils.FindLasFilesInFolder(inputFolder)
+	findFiles := utils.FindLasFilesInFolder
+	if opts.textColumns != "" {
+		findFiles = utils.FindTextFilesInFolder
+	}
+	files, err := findFiles(inputFolder)
 	if err != nil {
 		return err
 	}
 	for _, f := range files {
 		subfolderName := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
-		err := t.ProcessFiles([]string{f}, filepath.Join(outputFolder, subfolderName), sourceCRS, opts, ctx)
+		err := t.ProcessFiles([]string{f}, outputSubfolder(outputFolder, subfolderName, opts), sourceCRS, opts, ctx)
 		if err != nil {
 			return err
 		}
@@ -79,11 +139,26 @@ func (t *GoCesiumTiler) ProcessFolder(inputFolder, outputFolder string, sourceCR
 	return nil
 }
 
+// outputSubfolder returns the output folder of a tileset in the output folder, which is a path in the storage
+// if one was set, else the storage location
+func outputSubfolder(outputFolder string, name string, opts *TilerOptions) string {
+	if opts.storage != nil {
+		return path.Join(outputFolder, name)
+	}
+	return storage.Join(outputFolder, name)
+}
+
 // ProcessFiles converts the specified LAS files as a single cesium tileset and stores them in the given output folder.
 // If sourceCRS is left empty, the CRS will attempted to be autodetected from LAS GeoTIFF or WKT VLRs.
+// The CRSs set per file in the options take precedence over sourceCRS. Files with different CRSs can be combined,
+// each point being converted from the CRS of its file.
 func (t *GoCesiumTiler) ProcessFiles(inputLasFiles []string, outputFolder string, sourceCRS string, opts *TilerOptions, ctx context.Context) error {
 	start := time.Now()
 	tr := t.treeProvider(opts)
+	if c, ok := tr.(io.Closer); ok {
+		// out of core trees store temporary files that must be removed, parallel trees stop building in background
+		defer c.Close()
+	}
 
 	inputDesc := fmt.Sprintf("%d files", len(inputLasFiles))
 	if len(inputLasFiles) == 1 {
@@ -92,23 +167,31 @@ func (t *GoCesiumTiler) ProcessFiles(inputLasFiles []string, outputFolder string
 
 	// PARSE LAS HEADER
 	emitEvent(EventReadLasHeaderStarted, opts, start, inputDesc, "start reading las")
-	lasFile, err := t.lasReaderProvider(inputLasFiles, sourceCRS, opts.eightBitColors)
+	lasFile, err := t.lasReaderProvider(inputLasFiles, sourceCRS, opts)
 	if err != nil {
 		emitEvent(EventReadLasHeaderError, opts, start, inputDesc, fmt.Sprintf("las read error: %v", err))
 		return err
 	}
 	emitEvent(EventReadLasHeaderCompleted, opts, start, inputDesc, fmt.Sprintf("las header read completed: found %d points", lasFile.NumberOfPoints()))
-	emitEvent(EventReadCRSDetected, opts, start, inputDesc, fmt.Sprintf("crs: %s", lasFile.GetCRS()))
+	crsMsg := lasFile.GetCRS()
+	if crsMsg == "" {
+		crsMsg = "multiple, each file is converted from its own CRS"
+	}
+	emitEvent(EventReadCRSDetected, opts, start, inputDesc, fmt.Sprintf("crs: %s", crsMsg))
 
 	// LOAD POINTS
 	emitEvent(EventPointLoadingStarted, opts, start, inputDesc, "point loading started")
 	mutatorPipeline := mutator.NewPipeline(opts.mutators...)
-	err = tr.Load(lasFile, t.convFactory, mutatorPipeline, ctx)
+	uncovered := geoidUncovered(opts.mutators)
+	err = tr.Load(lasFile, t.converterProvider(opts), mutatorPipeline, ctx)
 	if err != nil {
 		emitEvent(EventPointLoadingError, opts, start, inputDesc, fmt.Sprintf("load error: %v", err))
 		return err
 	}
 	emitEvent(EventPointLoadingCompleted, opts, start, inputDesc, "point loading completed")
+	if n := geoidUncovered(opts.mutators) - uncovered; n > 0 {
+		emitEvent(EventPointLoadingWarning, opts, start, inputDesc, fmt.Sprintf("warning: %d points outside of the geoid grid were left unchanged", n))
+	}
 
 	// BUILD TREE
 	emitEvent(EventBuildStarted, opts, start, inputDesc, "build started")
@@ -121,7 +204,7 @@ func (t *GoCesiumTiler) ProcessFiles(inputLasFiles []string, outputFolder string
 
 	// EXPORT
 	emitEvent(EventExportStarted, opts, start, inputDesc, "export started")
-	w, err := t.writerProvider(outputFolder, opts)
+	w, err := t.writerProvider(outputFolder, opts, lasFile.Attributes())
 	if err != nil {
 		emitEvent(EventBuildError, opts, start, inputDesc, fmt.Sprintf("export init error: %v", err))
 		return err
@@ -135,6 +218,29 @@ func (t *GoCesiumTiler) ProcessFiles(inputLasFiles []string, outputFolder string
 	return nil
 }
 
+// geoidUncovered returns the number of points left unchanged so far by the geoid mutators as outside of their grid
+func geoidUncovered(mutators []mutator.Mutator) int64 {
+	var n int64
+	for _, m := range mutators {
+		if g, ok := m.(*mutator.Geoid); ok {
+			n += g.Uncovered()
+		}
+	}
+	return n
+}
+
+// copcReaderOptions returns the options to use to read COPC files, if any has been set
+func copcReaderOptions(opts *TilerOptions) []func(*las.CopcReader) {
+	var copcOpts []func(*las.CopcReader)
+	if opts.copcBounds != nil {
+		copcOpts = append(copcOpts, las.WithCopcBoundingBox(*opts.copcBounds))
+	}
+	if opts.copcMaxLevel >= 0 {
+		copcOpts = append(copcOpts, las.WithCopcMaxLevel(opts.copcMaxLevel))
+	}
+	return copcOpts
+}
+
 func emitEvent(e TilerEvent, opts *TilerOptions, start time.Time, inputDesc string, msg string) {
 	if opts.callback != nil {
 		opts.callback(e, inputDesc, time.Since(start).Milliseconds(), msg)
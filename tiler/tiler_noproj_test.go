@@ -0,0 +1,32 @@
+//go:build !cgo || noproj
+
+package tiler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/conv/coor/proj"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+)
+
+func TestTilerConverterProviderWithoutProj(t *testing.T) {
+	tiler, err := NewGoCesiumTiler()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := tiler.converterProvider(NewTilerOptions(WithConverterEngine(ConverterProj)))(); !errors.Is(err, proj.ErrNotAvailable) {
+		t.Errorf("expected error %v got %v", proj.ErrNotAvailable, err)
+	}
+	conv, err := tiler.converterProvider(NewTilerOptions(WithConverterEngine(ConverterAuto)))()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conv.Cleanup()
+	if _, err := conv.ToWGS84Cartesian("EPSG:32633", model.Vector{X: 500000}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := conv.ToWGS84Cartesian("EPSG:3124", model.Vector{}); !errors.Is(err, proj.ErrNotAvailable) {
+		t.Errorf("expected error %v got %v", proj.ErrNotAvailable, err)
+	}
+}
@@ -1,17 +1,24 @@
 package tiler
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/conv/coor"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/las"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree/grid"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/utils"
 	"github.com/mfbonfigli/gocesiumtiler/v2/internal/writer"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/model"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/mutator"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
 )
 
 func TestTilerDefaults(t *testing.T) {
@@ -25,9 +32,15 @@ func TestTilerDefaults(t *testing.T) {
 	default:
 		t.Errorf("unexpected tree type returned")
 	}
+	tr = tiler.treeProvider(NewTilerOptions(WithMemoryLimit(100, "")))
+	switch tr.(type) {
+	case *grid.OutOfCoreTree:
+	default:
+		t.Errorf("unexpected tree type returned with memory limit")
+	}
 	// this returns an error due to a non-esitant path
 	// but we ignore it on purpose for the sake of this test
-	l, _ := tiler.lasReaderProvider([]string{""}, "EPSG:123", true)
+	l, _ := tiler.lasReaderProvider([]string{""}, "EPSG:123", NewDefaultTilerOptions())
 	switch l.(type) {
 	case *las.CombinedFileLasReader:
 	default:
@@ -35,7 +48,7 @@ func TestTilerDefaults(t *testing.T) {
 	}
 	// this returns an error due to a non-esitant path
 	// but we ignore it on purpose for the sake of this test
-	w, err := tiler.writerProvider("", NewDefaultTilerOptions())
+	w, err := tiler.writerProvider("", NewDefaultTilerOptions(), nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -46,6 +59,55 @@ func TestTilerDefaults(t *testing.T) {
 	}
 }
 
+func TestTilerConverterProvider(t *testing.T) {
+	tiler, err := NewGoCesiumTiler()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, engine := range []ConverterEngine{ConverterAuto, ConverterNative} {
+		conv, err := tiler.converterProvider(NewTilerOptions(WithConverterEngine(engine)))()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// supported by the built-in converter, does not require PROJ
+		actual, err := conv.ToWGS84Cartesian("EPSG:4326", model.Vector{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := (model.Vector{X: 6378137}); actual != expected {
+			t.Errorf("expected coordinate %v got %v", expected, actual)
+		}
+		conv.Cleanup()
+	}
+	conv, err := tiler.converterProvider(NewTilerOptions(WithConverterEngine(ConverterNative)))()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := conv.ToWGS84Cartesian("EPSG:3124", model.Vector{}); err == nil {
+		t.Errorf("expected error got none")
+	}
+	if f := tiler.converterProvider(NewTilerOptions(WithConverterEngine(ConverterProj))); f == nil {
+		t.Errorf("expected non-nil coordinate converter factory")
+	}
+}
+
+func TestTilerFileCRS(t *testing.T) {
+	tiler, err := NewGoCesiumTiler()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files := []string{"../internal/las/testdata/las-12-pf1.las", "../internal/las/testdata/las-12-pf2.las"}
+	opts := NewTilerOptions(WithFileCRS(map[string]string{files[1]: "EPSG:32632"}))
+	l, err := tiler.lasReaderProvider(files, "EPSG:32633", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+	if actual := l.(*las.CombinedFileLasReader).FileCRSs(); !reflect.DeepEqual(actual, []string{"EPSG:32633", "EPSG:32632"}) {
+		t.Errorf("expected file crss %v got %v", []string{"EPSG:32633", "EPSG:32632"}, actual)
+	}
+}
+
 func TestTilerProcessFile(t *testing.T) {
 	tiler, err := NewGoCesiumTiler()
 	if err != nil {
@@ -56,13 +118,13 @@ func TestTilerProcessFile(t *testing.T) {
 	l := &las.MockLasReader{}
 	opts := NewDefaultTilerOptions()
 	c := context.TODO()
-	tiler.writerProvider = func(folder string, opts *TilerOptions) (writer.Writer, error) {
+	tiler.writerProvider = func(folder string, opts *TilerOptions, attributes []geom.Attribute) (writer.Writer, error) {
 		return w, nil
 	}
 	tiler.treeProvider = func(opts *TilerOptions) tree.Tree {
 		return tr
 	}
-	tiler.lasReaderProvider = func(inputLasFiles []string, sourceCRS string, eightbit bool) (las.LasReader, error) {
+	tiler.lasReaderProvider = func(inputLasFiles []string, sourceCRS string, opts *TilerOptions) (las.LasReader, error) {
 		return l, nil
 	}
 
@@ -99,6 +161,106 @@ func TestTilerProcessFile(t *testing.T) {
 	}
 }
 
+// closerTree is a mock tree that records when it is closed
+type closerTree struct {
+	*tree.MockNode
+	closed bool
+}
+
+func (c *closerTree) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestTilerProcessFileClosesTree(t *testing.T) {
+	tiler, err := NewGoCesiumTiler()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tr := &closerTree{MockNode: &tree.MockNode{}}
+	tiler.writerProvider = func(folder string, opts *TilerOptions, attributes []geom.Attribute) (writer.Writer, error) {
+		return &writer.MockWriter{}, nil
+	}
+	tiler.treeProvider = func(opts *TilerOptions) tree.Tree {
+		return tr
+	}
+	tiler.lasReaderProvider = func(inputLasFiles []string, sourceCRS string, opts *TilerOptions) (las.LasReader, error) {
+		return &las.MockLasReader{}, nil
+	}
+	if err := tiler.ProcessFiles([]string{"abc.las"}, "out", "EPSG:123", NewDefaultTilerOptions(), context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !tr.closed {
+		t.Errorf("expected the tree to be closed")
+	}
+}
+
+// mutatingTree is a mock tree mutating the given points when loaded
+type mutatingTree struct {
+	*tree.MockNode
+	pts []model.Point
+}
+
+func (m *mutatingTree) Load(l las.LasReader, c coor.ConverterFactory, mut mutator.Mutator, ctx context.Context) error {
+	for _, pt := range m.pts {
+		mut.Mutate(pt, model.IdentityTransform)
+	}
+	return m.MockNode.Load(l, c, mut, ctx)
+}
+
+func TestTilerProcessFileGeoidWarning(t *testing.T) {
+	tiler, err := NewGoCesiumTiler()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// geoid grid covering latitudes 44 to 46 and longitudes 10 to 12
+	b := &bytes.Buffer{}
+	binary.Write(b, binary.BigEndian, []float64{44, 10, 1, 1})
+	binary.Write(b, binary.BigEndian, []int32{3, 3})
+	binary.Write(b, binary.BigEndian, make([]float32, 9))
+	path := filepath.Join(t.TempDir(), "geoid.gtx")
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := mutator.NewGeoid(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	point := func(lat, lon float64) model.Point {
+		v := geom.GeodeticToECEF(lat, lon, 0)
+		return model.Point{X: float32(v.X), Y: float32(v.Y), Z: float32(v.Z)}
+	}
+	tr := &mutatingTree{MockNode: &tree.MockNode{}, pts: []model.Point{point(45, 11), point(50, 11), point(45, 20)}}
+	tiler.writerProvider = func(folder string, opts *TilerOptions, attributes []geom.Attribute) (writer.Writer, error) {
+		return &writer.MockWriter{}, nil
+	}
+	tiler.treeProvider = func(opts *TilerOptions) tree.Tree {
+		return tr
+	}
+	tiler.lasReaderProvider = func(inputLasFiles []string, sourceCRS string, opts *TilerOptions) (las.LasReader, error) {
+		return &las.MockLasReader{}, nil
+	}
+	var warnings []string
+	opts := NewTilerOptions(
+		WithMutators([]mutator.Mutator{g}),
+		WithCallback(func(event TilerEvent, inputDesc string, elapsed int64, msg string) {
+			if event == EventPointLoadingWarning {
+				warnings = append(warnings, msg)
+			}
+		}),
+	)
+	for i := 0; i < 2; i++ {
+		if err := tiler.ProcessFiles([]string{"abc.las"}, "out", "EPSG:123", opts, context.TODO()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// each run only reports its own points
+	expected := "warning: 2 points outside of the geoid grid were left unchanged"
+	if len(warnings) != 2 || warnings[0] != expected || warnings[1] != expected {
+		t.Errorf("expected warnings %q got %q", expected, warnings)
+	}
+}
+
 func TestTilerProcessFolder(t *testing.T) {
 	tiler, err := NewGoCesiumTiler()
 	if err != nil {
@@ -109,14 +271,14 @@ func TestTilerProcessFolder(t *testing.T) {
 	l := &las.MockLasReader{}
 	opts := NewDefaultTilerOptions()
 	c := context.TODO()
-	tiler.writerProvider = func(folder string, opts *TilerOptions) (writer.Writer, error) {
+	tiler.writerProvider = func(folder string, opts *TilerOptions, attributes []geom.Attribute) (writer.Writer, error) {
 		return w, nil
 	}
 	tiler.treeProvider = func(opts *TilerOptions) tree.Tree {
 		return tr
 	}
 	files := []string{}
-	tiler.lasReaderProvider = func(inputLasFiles []string, sourceCRS string, eightbit bool) (las.LasReader, error) {
+	tiler.lasReaderProvider = func(inputLasFiles []string, sourceCRS string, opts *TilerOptions) (las.LasReader, error) {
 		files = append(files, inputLasFiles...)
 		return l, nil
 	}
@@ -170,3 +332,70 @@ func TestTilerProcessFolder(t *testing.T) {
 		t.Errorf("expected files processed %v, got %v", files, expected)
 	}
 }
+
+func TestTilerProcessFolderText(t *testing.T) {
+	tiler, err := NewGoCesiumTiler()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tiler.writerProvider = func(folder string, opts *TilerOptions, attributes []geom.Attribute) (writer.Writer, error) {
+		return &writer.MockWriter{}, nil
+	}
+	tiler.treeProvider = func(opts *TilerOptions) tree.Tree {
+		return &tree.MockNode{}
+	}
+	files := []string{}
+	tiler.lasReaderProvider = func(inputLasFiles []string, sourceCRS string, opts *TilerOptions) (las.LasReader, error) {
+		files = append(files, inputLasFiles...)
+		return &las.MockLasReader{}, nil
+	}
+	tmp := t.TempDir()
+	utils.TouchFile(filepath.Join(tmp, "abc.las"))
+	utils.TouchFile(filepath.Join(tmp, "def.xyz"))
+	utils.TouchFile(filepath.Join(tmp, "ghi.csv"))
+	opts := NewTilerOptions(WithTextFormat("x,y,z", ",", 0))
+	tiler.ProcessFolder(tmp, "out", "EPSG:123", opts, context.TODO())
+	expected := []string{
+		filepath.Join(tmp, "def.xyz"),
+		filepath.Join(tmp, "ghi.csv"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files processed %v, got %v", expected, files)
+	}
+}
+
+func TestTilerTextReaderProvider(t *testing.T) {
+	tiler, err := NewGoCesiumTiler()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f := filepath.Join(t.TempDir(), "test.xyz")
+	if err := os.WriteFile(f, []byte("1,2,3\n4,5,6\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := tiler.lasReaderProvider([]string{f}, "EPSG:123", NewTilerOptions(WithTextFormat("x,y,z", ",", 0)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+	if actual := l.NumberOfPoints(); actual != 2 {
+		t.Errorf("expected %d points got %d", 2, actual)
+	}
+	if _, err := tiler.lasReaderProvider([]string{f}, "EPSG:123", NewTilerOptions(WithTextFormat("x,y", ",", 0))); err == nil {
+		t.Errorf("expected error, got none")
+	}
+}
+
+func TestOutputSubfolder(t *testing.T) {
+	opts := NewDefaultTilerOptions()
+	if actual := outputSubfolder("s3://bucket/prefix", "abc", opts); actual != "s3://bucket/prefix/abc" {
+		t.Errorf("unexpected output folder %s", actual)
+	}
+	if actual := outputSubfolder("out", "abc", opts); actual != filepath.Join("out", "abc") {
+		t.Errorf("unexpected output folder %s", actual)
+	}
+	WithStorage(storage.NewMemoryStorage())(opts)
+	if actual := outputSubfolder("out", "abc", opts); actual != "out/abc" {
+		t.Errorf("unexpected output folder %s", actual)
+	}
+}
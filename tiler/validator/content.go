@@ -0,0 +1,361 @@
+package validator
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+const (
+	pntsHeaderLength = 28
+	glbHeaderLength  = 12
+	glbJsonChunk     = 0x4E4F534A
+	glbBinChunk      = 0x004E4942
+)
+
+// pntsSemantics maps the per point semantics of the .pnts feature table to their component and element sizes
+var pntsSemantics = map[string][2]int{
+	"POSITION":           {4, 12},
+	"POSITION_QUANTIZED": {2, 6},
+	"RGBA":               {1, 4},
+	"RGB":                {1, 3},
+	"RGB565":             {2, 2},
+	"NORMAL":             {4, 12},
+	"NORMAL_OCT16P":      {1, 2},
+}
+
+var pntsComponentSizes = map[string]int{
+	"BYTE":           1,
+	"UNSIGNED_BYTE":  1,
+	"SHORT":          2,
+	"UNSIGNED_SHORT": 2,
+	"INT":            4,
+	"UNSIGNED_INT":   4,
+	"FLOAT":          4,
+	"DOUBLE":         8,
+}
+
+var componentCounts = map[string]int{
+	"SCALAR": 1,
+	"VEC2":   2,
+	"VEC3":   3,
+	"VEC4":   4,
+	"MAT2":   4,
+	"MAT3":   9,
+	"MAT4":   16,
+}
+
+var gltfComponentSizes = map[int]int{
+	5120: 1,
+	5121: 1,
+	5122: 2,
+	5123: 2,
+	5125: 4,
+	5126: 4,
+}
+
+type binaryRef struct {
+	ByteOffset    *int   `json:"byteOffset"`
+	ComponentType string `json:"componentType"`
+	Type          string `json:"type"`
+}
+
+type dracoPointCompression struct {
+	Properties map[string]int `json:"properties"`
+	ByteOffset int            `json:"byteOffset"`
+	ByteLength int            `json:"byteLength"`
+}
+
+// validatePnts checks the header, the table lengths and the binary references of a .pnts tile
+func (v *validator) validatePnts(p string, data []byte) {
+	if len(data) < pntsHeaderLength {
+		v.errorf(p, "header", "file too short, %d bytes", len(data))
+		return
+	}
+	le := binary.LittleEndian
+	if version := le.Uint32(data[4:]); version != 1 {
+		v.errorf(p, "header", "unsupported version %d", version)
+	}
+	if byteLength := int(le.Uint32(data[8:])); byteLength != len(data) {
+		v.errorf(p, "header", "byteLength %d does not match the file size %d", byteLength, len(data))
+		return
+	}
+	ftJson, ftBin := int(le.Uint32(data[12:])), int(le.Uint32(data[16:]))
+	btJson, btBin := int(le.Uint32(data[20:])), int(le.Uint32(data[24:]))
+	if total := pntsHeaderLength + ftJson + ftBin + btJson + btBin; total != len(data) {
+		v.errorf(p, "header", "header and table lengths sum up to %d bytes, expected %d", total, len(data))
+		return
+	}
+	// the json headers and the binary bodies of both tables must end at 8 byte boundaries
+	offset := pntsHeaderLength
+	for _, section := range []struct {
+		location string
+		name     string
+		length   int
+	}{
+		{"featureTable", "json header", ftJson},
+		{"featureTable", "binary body", ftBin},
+		{"batchTable", "json header", btJson},
+		{"batchTable", "binary body", btBin},
+	} {
+		offset += section.length
+		if section.length > 0 && offset%8 != 0 {
+			v.errorf(p, section.location, "%s ends at byte %d, not on an 8 byte boundary", section.name, offset)
+		}
+	}
+
+	ftStart := pntsHeaderLength + ftJson
+	ft := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data[pntsHeaderLength:ftStart], &ft); err != nil {
+		v.errorf(p, "featureTable", "invalid json: %v", err)
+		return
+	}
+	points := 0
+	if err := json.Unmarshal(ft["POINTS_LENGTH"], &points); err != nil || points < 0 {
+		v.errorf(p, "featureTable.POINTS_LENGTH", "missing or invalid required property")
+		return
+	}
+	draco := v.pntsDraco(p, "featureTable", ft, ftBin)
+	if ft["POSITION"] == nil && ft["POSITION_QUANTIZED"] == nil {
+		v.errorf(p, "featureTable", "either POSITION or POSITION_QUANTIZED must be set")
+	}
+	if ft["POSITION_QUANTIZED"] != nil && (ft["QUANTIZED_VOLUME_OFFSET"] == nil || ft["QUANTIZED_VOLUME_SCALE"] == nil) {
+		v.errorf(p, "featureTable", "POSITION_QUANTIZED requires QUANTIZED_VOLUME_OFFSET and QUANTIZED_VOLUME_SCALE")
+	}
+	for _, name := range sortedKeys(pntsSemantics) {
+		sizes := pntsSemantics[name]
+		if _, ok := draco[name]; ft[name] == nil || ok {
+			continue
+		}
+		ref := binaryRef{}
+		if err := json.Unmarshal(ft[name], &ref); err != nil || ref.ByteOffset == nil {
+			v.errorf(p, "featureTable."+name, "invalid binary body reference")
+			continue
+		}
+		v.checkBinaryRef(p, "featureTable."+name, ftStart, ftBin, *ref.ByteOffset, sizes[0], points*sizes[1])
+	}
+
+	btStart := ftStart + ftBin + btJson
+	if btJson == 0 {
+		return
+	}
+	bt := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data[ftStart+ftBin:btStart], &bt); err != nil {
+		v.errorf(p, "batchTable", "invalid json: %v", err)
+		return
+	}
+	features := points
+	if ft["BATCH_LENGTH"] != nil {
+		if err := json.Unmarshal(ft["BATCH_LENGTH"], &features); err != nil || features < 0 {
+			v.errorf(p, "featureTable.BATCH_LENGTH", "invalid property")
+			return
+		}
+	}
+	draco = v.pntsDraco(p, "batchTable", bt, -1)
+	for _, name := range sortedKeys(bt) {
+		raw := bt[name]
+		if _, ok := draco[name]; name == "extensions" || name == "extras" || ok {
+			continue
+		}
+		ref := binaryRef{}
+		if json.Unmarshal(raw, &ref) != nil || ref.ByteOffset == nil {
+			// properties stored as json arrays
+			continue
+		}
+		size, count := pntsComponentSizes[ref.ComponentType], componentCounts[ref.Type]
+		if size == 0 || count == 0 || count > 4 {
+			v.errorf(p, "batchTable."+name, "invalid componentType %q or type %q", ref.ComponentType, ref.Type)
+			continue
+		}
+		v.checkBinaryRef(p, "batchTable."+name, btStart, btBin, *ref.ByteOffset, size, features*size*count)
+	}
+}
+
+// pntsDraco returns the properties stored in the 3DTILES_draco_point_compression data of the table, if any,
+// checking that the data lies in the binary body of the given length when not negative
+func (v *validator) pntsDraco(p string, table string, t map[string]json.RawMessage, binLength int) map[string]int {
+	ext := map[string]json.RawMessage{}
+	if t["extensions"] == nil || json.Unmarshal(t["extensions"], &ext) != nil || ext["3DTILES_draco_point_compression"] == nil {
+		return nil
+	}
+	d := dracoPointCompression{}
+	if err := json.Unmarshal(ext["3DTILES_draco_point_compression"], &d); err != nil {
+		v.errorf(p, table+".extensions.3DTILES_draco_point_compression", "invalid json: %v", err)
+		return nil
+	}
+	if binLength >= 0 && (d.ByteOffset < 0 || d.ByteLength <= 0 || d.ByteOffset+d.ByteLength > binLength) {
+		v.errorf(p, table+".extensions.3DTILES_draco_point_compression", "draco data of %d bytes at offset %d exceeds the binary body of %d bytes", d.ByteLength, d.ByteOffset, binLength)
+	}
+	return d.Properties
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkBinaryRef checks that length bytes at the given offset of a binary body fit in it and are aligned
+// to the component size within the file
+func (v *validator) checkBinaryRef(p string, location string, bodyStart int, bodyLength int, offset int, componentSize int, length int) {
+	if offset < 0 || offset+length > bodyLength {
+		v.errorf(p, location, "%d bytes at offset %d exceed the binary body of %d bytes", length, offset, bodyLength)
+	} else if (bodyStart+offset)%componentSize != 0 {
+		v.errorf(p, location, "data at offset %d of the file is not aligned to %d bytes", bodyStart+offset, componentSize)
+	}
+}
+
+type gltfJson struct {
+	Buffers []struct {
+		Uri        string                     `json:"uri"`
+		ByteLength int                        `json:"byteLength"`
+		Extensions map[string]json.RawMessage `json:"extensions"`
+	} `json:"buffers"`
+	BufferViews []struct {
+		Buffer     int                        `json:"buffer"`
+		ByteOffset int                        `json:"byteOffset"`
+		ByteLength int                        `json:"byteLength"`
+		ByteStride int                        `json:"byteStride"`
+		Extensions map[string]json.RawMessage `json:"extensions"`
+	} `json:"bufferViews"`
+	Accessors []struct {
+		BufferView    *int   `json:"bufferView"`
+		ByteOffset    int    `json:"byteOffset"`
+		ComponentType int    `json:"componentType"`
+		Count         int    `json:"count"`
+		Type          string `json:"type"`
+	} `json:"accessors"`
+}
+
+// validateGlb checks the header, the chunks and the buffers, buffer views and accessors bounds of a .glb tile
+func (v *validator) validateGlb(p string, data []byte) {
+	if len(data) < glbHeaderLength {
+		v.errorf(p, "header", "file too short, %d bytes", len(data))
+		return
+	}
+	le := binary.LittleEndian
+	if version := le.Uint32(data[4:]); version != 2 {
+		v.errorf(p, "header", "unsupported version %d", version)
+		return
+	}
+	if length := int(le.Uint32(data[8:])); length != len(data) {
+		v.errorf(p, "header", "length %d does not match the file size %d", length, len(data))
+		return
+	}
+	var jsonChunk, binChunk []byte
+	offset := glbHeaderLength
+	for i := 0; offset < len(data); i++ {
+		location := fmt.Sprintf("chunks[%d]", i)
+		if offset+8 > len(data) {
+			v.errorf(p, location, "truncated chunk header")
+			return
+		}
+		length, chunkType := int(le.Uint32(data[offset:])), le.Uint32(data[offset+4:])
+		if offset+8+length > len(data) {
+			v.errorf(p, location, "chunk of %d bytes exceeds the file size", length)
+			return
+		}
+		if length%4 != 0 {
+			v.errorf(p, location, "chunk length %d is not a multiple of 4", length)
+		}
+		chunk := data[offset+8 : offset+8+length]
+		switch {
+		case i == 0 && chunkType == glbJsonChunk:
+			jsonChunk = chunk
+		case i == 0:
+			v.errorf(p, location, "the first chunk must be a JSON chunk")
+			return
+		case i == 1 && chunkType == glbBinChunk:
+			binChunk = chunk
+		case chunkType == glbJsonChunk || chunkType == glbBinChunk:
+			v.errorf(p, location, "unexpected chunk of type 0x%08X", chunkType)
+		}
+		offset += 8 + length
+	}
+	if jsonChunk == nil {
+		v.errorf(p, "chunks", "missing JSON chunk")
+		return
+	}
+	doc := gltfJson{}
+	if err := json.Unmarshal(jsonChunk, &doc); err != nil {
+		v.errorf(p, "json", "invalid json: %v", err)
+		return
+	}
+	for i, b := range doc.Buffers {
+		location := fmt.Sprintf("buffers[%d]", i)
+		switch {
+		case b.Uri != "":
+			if !strings.HasPrefix(b.Uri, "data:") {
+				if ref, ok := v.resolve(p, b.Uri); ok {
+					if _, err := fs.Stat(v.fsys, ref); err != nil {
+						v.errorf(p, location, "buffer %s cannot be read: %v", ref, err)
+					}
+				}
+			}
+		case i == 0 && binChunk != nil:
+			if b.ByteLength > len(binChunk) || len(binChunk) > b.ByteLength+3 {
+				v.errorf(p, location, "byteLength %d does not match the BIN chunk length %d", b.ByteLength, len(binChunk))
+			}
+		case b.Extensions["EXT_meshopt_compression"] != nil:
+			// fallback buffers of compressed buffer views have no data
+		default:
+			v.errorf(p, location, "buffer has no uri and is not stored in the BIN chunk")
+		}
+	}
+	checkView := func(location string, buffer, offset, length int) {
+		if buffer < 0 || buffer >= len(doc.Buffers) {
+			v.errorf(p, location, "invalid buffer %d", buffer)
+		} else if offset < 0 || length < 0 || offset+length > doc.Buffers[buffer].ByteLength {
+			v.errorf(p, location, "%d bytes at offset %d exceed the buffer of %d bytes", length, offset, doc.Buffers[buffer].ByteLength)
+		}
+	}
+	for i, bv := range doc.BufferViews {
+		location := fmt.Sprintf("bufferViews[%d]", i)
+		checkView(location, bv.Buffer, bv.ByteOffset, bv.ByteLength)
+		if raw := bv.Extensions["EXT_meshopt_compression"]; raw != nil {
+			ext := struct {
+				Buffer     int `json:"buffer"`
+				ByteOffset int `json:"byteOffset"`
+				ByteLength int `json:"byteLength"`
+			}{}
+			if err := json.Unmarshal(raw, &ext); err != nil {
+				v.errorf(p, location+".extensions.EXT_meshopt_compression", "invalid json: %v", err)
+			} else {
+				checkView(location+".extensions.EXT_meshopt_compression", ext.Buffer, ext.ByteOffset, ext.ByteLength)
+			}
+		}
+	}
+	for i, a := range doc.Accessors {
+		location := fmt.Sprintf("accessors[%d]", i)
+		size, count := gltfComponentSizes[a.ComponentType], componentCounts[a.Type]
+		if size == 0 || count == 0 {
+			v.errorf(p, location, "invalid componentType %d or type %q", a.ComponentType, a.Type)
+			continue
+		}
+		if a.BufferView == nil || a.Count == 0 {
+			// accessors without buffer views, like the Draco compressed ones, have no data to check
+			continue
+		}
+		if *a.BufferView < 0 || *a.BufferView >= len(doc.BufferViews) {
+			v.errorf(p, location, "invalid bufferView %d", *a.BufferView)
+			continue
+		}
+		bv := doc.BufferViews[*a.BufferView]
+		stride := bv.ByteStride
+		if stride == 0 {
+			stride = size * count
+		}
+		if length := a.ByteOffset + stride*(a.Count-1) + size*count; length > bv.ByteLength {
+			v.errorf(p, location, "%d elements need %d bytes, exceeding the buffer view of %d bytes", a.Count, length, bv.ByteLength)
+		}
+		if (bv.ByteOffset+a.ByteOffset)%size != 0 {
+			v.errorf(p, location, "data is not aligned to %d bytes", size)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package validator
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const subtreeHeaderLength = 24
+
+type implicitTilingJson struct {
+	SubdivisionScheme string `json:"subdivisionScheme"`
+	SubtreeLevels     int    `json:"subtreeLevels"`
+	AvailableLevels   int    `json:"availableLevels"`
+	Subtrees          *struct {
+		Uri string `json:"uri"`
+	} `json:"subtrees"`
+}
+
+type availabilityJson struct {
+	Bitstream *int `json:"bitstream"`
+	Constant  *int `json:"constant"`
+}
+
+type subtreeJson struct {
+	Buffers []struct {
+		Uri        string `json:"uri"`
+		ByteLength int    `json:"byteLength"`
+	} `json:"buffers"`
+	BufferViews []struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+	} `json:"bufferViews"`
+	TileAvailability         *availabilityJson  `json:"tileAvailability"`
+	ContentAvailability      []availabilityJson `json:"contentAvailability"`
+	ChildSubtreeAvailability *availabilityJson  `json:"childSubtreeAvailability"`
+}
+
+// implicitTile holds the coordinates of a tile in the implicit tiling scheme
+type implicitTile struct {
+	level, x, y, z int
+}
+
+// expand replaces the template variables of the uri with the tile coordinates
+func (t implicitTile) expand(uri string) string {
+	return strings.NewReplacer(
+		"{level}", strconv.Itoa(t.level),
+		"{x}", strconv.Itoa(t.x),
+		"{y}", strconv.Itoa(t.y),
+		"{z}", strconv.Itoa(t.z),
+	).Replace(uri)
+}
+
+// descendant returns the tile at the given Morton index of the level below this tile
+func (t implicitTile) descendant(level int, index int, octree bool) implicitTile {
+	d := implicitTile{level: t.level + level, x: t.x << level, y: t.y << level, z: t.z << level}
+	dims := 2
+	if octree {
+		dims = 3
+	}
+	for bit := 0; bit < level; bit++ {
+		d.x |= (index >> (dims * bit) & 1) << bit
+		d.y |= (index >> (dims*bit + 1) & 1) << bit
+		if octree {
+			d.z |= (index >> (dims*bit + 2) & 1) << bit
+		}
+	}
+	return d
+}
+
+type implicitTiling struct {
+	file            string
+	location        string
+	octree          bool
+	subtreeLevels   int
+	availableLevels int
+	subtrees        string
+	contents        []string
+}
+
+// tilesInLevels returns the number of tiles in the first levels of the tree
+func (it *implicitTiling) tilesInLevels(levels int) int {
+	if it.octree {
+		return (1<<(3*levels) - 1) / 7
+	}
+	return (1<<(2*levels) - 1) / 3
+}
+
+func (it *implicitTiling) tilesInLevel(level int) int {
+	if it.octree {
+		return 1 << (3 * level)
+	}
+	return 1 << (2 * level)
+}
+
+// validateImplicitTiling checks the implicit tiling properties of the tile and walks the subtrees
+func (v *validator) validateImplicitTiling(t *tileJson, file string, location string) {
+	implicit := t.ImplicitTiling
+	location += ".implicitTiling"
+	it := &implicitTiling{
+		file:            file,
+		location:        location,
+		octree:          implicit.SubdivisionScheme == "OCTREE",
+		subtreeLevels:   implicit.SubtreeLevels,
+		availableLevels: implicit.AvailableLevels,
+	}
+	valid := true
+	if implicit.SubdivisionScheme != "OCTREE" && implicit.SubdivisionScheme != "QUADTREE" {
+		v.errorf(file, location+".subdivisionScheme", "invalid subdivision scheme %q, expected OCTREE or QUADTREE", implicit.SubdivisionScheme)
+		valid = false
+	}
+	if implicit.SubtreeLevels < 1 {
+		v.errorf(file, location+".subtreeLevels", "must be at least 1")
+		valid = false
+	}
+	if implicit.AvailableLevels < 1 {
+		v.errorf(file, location+".availableLevels", "must be at least 1")
+		valid = false
+	}
+	if implicit.Subtrees == nil || implicit.Subtrees.Uri == "" {
+		v.errorf(file, location+".subtrees.uri", "missing required property")
+		valid = false
+	} else {
+		it.subtrees = implicit.Subtrees.Uri
+	}
+	if t.Content != nil {
+		it.contents = append(it.contents, t.Content.Uri)
+	}
+	for _, c := range t.Contents {
+		it.contents = append(it.contents, c.Uri)
+	}
+	if !valid {
+		return
+	}
+	v.validateSubtree(it, implicitTile{})
+}
+
+// validateSubtree checks the subtree rooted at the given tile, its contents and its child subtrees
+func (v *validator) validateSubtree(it *implicitTiling, root implicitTile) {
+	p, ok := v.resolve(it.file, root.expand(it.subtrees))
+	if !ok {
+		v.warnf(it.file, it.location+".subtrees.uri", "external subtree %s not checked", root.expand(it.subtrees))
+		return
+	}
+	data, err := v.readFile(p)
+	if err != nil {
+		v.errorf(it.file, it.location+".subtrees.uri", "subtree %s cannot be read: %v", p, err)
+		return
+	}
+	subtree, buffers, ok := v.parseSubtree(p, data)
+	if !ok {
+		return
+	}
+
+	levels := min(it.subtreeLevels, it.availableLevels-root.level)
+	tileBits := it.tilesInLevels(it.subtreeLevels)
+	tiles, ok := v.availability(p, "tileAvailability", subtree.TileAvailability, subtree, buffers, tileBits)
+	if !ok {
+		return
+	}
+	contents := make([]func(int) bool, len(subtree.ContentAvailability))
+	for i, a := range subtree.ContentAvailability {
+		if contents[i], ok = v.availability(p, fmt.Sprintf("contentAvailability[%d]", i), &a, subtree, buffers, tileBits); !ok {
+			return
+		}
+	}
+	if len(contents) != len(it.contents) {
+		v.errorf(p, "contentAvailability", "expected %d content availabilities, got %d", len(it.contents), len(contents))
+		return
+	}
+	if !tiles(0) {
+		v.errorf(p, "tileAvailability", "the root tile of the subtree is not available")
+	}
+	for level := 0; level < levels; level++ {
+		for i := 0; i < it.tilesInLevel(level); i++ {
+			bit := it.tilesInLevels(level) + i
+			available := tiles(bit)
+			if available && level > 0 && !tiles(it.tilesInLevels(level-1)+i/it.tilesInLevel(1)) {
+				v.errorf(p, "tileAvailability", "tile %d is available but its parent is not", bit)
+			}
+			if available && (level > 0 || root.level > 0) {
+				v.report.Tiles++
+			}
+			tile := root.descendant(level, i, it.octree)
+			for c, content := range contents {
+				if !content(bit) {
+					continue
+				}
+				location := fmt.Sprintf("contentAvailability[%d]", c)
+				if !available {
+					v.errorf(p, location, "content %d is available but its tile is not", bit)
+					continue
+				}
+				if cp, ok := v.resolve(it.file, tile.expand(it.contents[c])); ok {
+					v.validateContentFile(cp, p, location)
+				}
+			}
+		}
+	}
+
+	if root.level+it.subtreeLevels >= it.availableLevels {
+		return
+	}
+	children, ok := v.availability(p, "childSubtreeAvailability", subtree.ChildSubtreeAvailability, subtree, buffers, it.tilesInLevel(it.subtreeLevels))
+	if !ok {
+		return
+	}
+	for i := 0; i < it.tilesInLevel(it.subtreeLevels); i++ {
+		if !children(i) {
+			continue
+		}
+		if !tiles(it.tilesInLevels(it.subtreeLevels-1) + i/it.tilesInLevel(1)) {
+			v.errorf(p, "childSubtreeAvailability", "child subtree %d is available but its parent tile is not", i)
+		}
+		v.validateSubtree(it, root.descendant(it.subtreeLevels, i, it.octree))
+	}
+}
+
+// parseSubtree decodes the header, the json and the buffers of a subtree file
+func (v *validator) parseSubtree(p string, data []byte) (subtreeJson, [][]byte, bool) {
+	subtree := subtreeJson{}
+	if len(data) < subtreeHeaderLength || string(data[:4]) != "subt" {
+		v.errorf(p, "header", "invalid subtree header")
+		return subtree, nil, false
+	}
+	le := binary.LittleEndian
+	if version := le.Uint32(data[4:]); version != 1 {
+		v.errorf(p, "header", "unsupported version %d", version)
+		return subtree, nil, false
+	}
+	jsonLen, binLen := le.Uint64(data[8:]), le.Uint64(data[16:])
+	if jsonLen > uint64(len(data)) || binLen > uint64(len(data)) || subtreeHeaderLength+jsonLen+binLen != uint64(len(data)) {
+		v.errorf(p, "header", "json and binary lengths %d and %d do not match the file size %d", jsonLen, binLen, len(data))
+		return subtree, nil, false
+	}
+	if err := json.Unmarshal(data[subtreeHeaderLength:subtreeHeaderLength+jsonLen], &subtree); err != nil {
+		v.errorf(p, "json", "invalid json: %v", err)
+		return subtree, nil, false
+	}
+	body := data[subtreeHeaderLength+jsonLen:]
+	buffers := make([][]byte, len(subtree.Buffers))
+	for i, b := range subtree.Buffers {
+		location := fmt.Sprintf("buffers[%d]", i)
+		if b.Uri == "" {
+			if b.ByteLength > len(body) {
+				v.errorf(p, location, "byteLength %d exceeds the binary body of %d bytes", b.ByteLength, len(body))
+				return subtree, nil, false
+			}
+			buffers[i] = body[:b.ByteLength]
+			continue
+		}
+		bp, ok := v.resolve(p, b.Uri)
+		if !ok {
+			v.errorf(p, location, "external buffer %s not supported", b.Uri)
+			return subtree, nil, false
+		}
+		data, err := v.readFile(bp)
+		if err != nil || len(data) < b.ByteLength {
+			v.errorf(p, location, "buffer %s cannot be read or is too short", bp)
+			return subtree, nil, false
+		}
+		buffers[i] = data[:b.ByteLength]
+	}
+	return subtree, buffers, true
+}
+
+// availability returns a function reporting whether the bit at the given index of the availability is set
+func (v *validator) availability(p string, location string, a *availabilityJson, s subtreeJson, buffers [][]byte, bits int) (func(int) bool, bool) {
+	switch {
+	case a == nil:
+		v.errorf(p, location, "missing required property")
+	case a.Constant != nil:
+		if *a.Constant != 0 && *a.Constant != 1 {
+			v.errorf(p, location+".constant", "invalid constant %d, expected 0 or 1", *a.Constant)
+			return nil, false
+		}
+		return func(int) bool { return *a.Constant == 1 }, true
+	case a.Bitstream != nil:
+		i := *a.Bitstream
+		if i < 0 || i >= len(s.BufferViews) {
+			v.errorf(p, location+".bitstream", "invalid buffer view %d", i)
+			return nil, false
+		}
+		bv := s.BufferViews[i]
+		if bv.Buffer < 0 || bv.Buffer >= len(buffers) || bv.ByteOffset < 0 || bv.ByteOffset+bv.ByteLength > len(buffers[bv.Buffer]) {
+			v.errorf(p, fmt.Sprintf("bufferViews[%d]", i), "buffer view exceeds its buffer")
+			return nil, false
+		}
+		if bv.ByteLength < (bits+7)/8 {
+			v.errorf(p, location+".bitstream", "bitstream of %d bytes is too short for %d bits", bv.ByteLength, bits)
+			return nil, false
+		}
+		bitstream := buffers[bv.Buffer][bv.ByteOffset : bv.ByteOffset+bv.ByteLength]
+		return func(i int) bool { return bitstream[i/8]>>(i%8)&1 == 1 }, true
+	default:
+		v.errorf(p, location, "either bitstream or constant must be set")
+	}
+	return nil, false
+}
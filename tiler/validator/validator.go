@@ -0,0 +1,361 @@
+package validator
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Severity classifies the issues by their impact on the tileset clients
+type Severity string
+
+const (
+	// SeverityError marks the issues that make the tileset invalid or prevent clients from loading it
+	SeverityError Severity = "error"
+	// SeverityWarning marks the issues that clients usually tolerate
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem found in a file of the tileset
+type Issue struct {
+	Severity Severity `json:"severity"`
+	// File is the slash separated path of the file, relative to the tileset root folder
+	File string `json:"file"`
+	// Location is the element of the file the issue refers to, like root.children[1].content
+	Location string `json:"location,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Location != "" {
+		return fmt.Sprintf("%s: %s: %s: %s", i.Severity, i.File, i.Location, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.File, i.Message)
+}
+
+// Report summarizes the result of a validation
+type Report struct {
+	Tileset  string  `json:"tileset"`
+	Tilesets int     `json:"tilesets"`
+	Tiles    int     `json:"tiles"`
+	Contents int     `json:"contents"`
+	Issues   []Issue `json:"issues"`
+}
+
+// Errors returns the number of issues with error severity
+func (r *Report) Errors() int {
+	return r.count(SeverityError)
+}
+
+// Warnings returns the number of issues with warning severity
+func (r *Report) Warnings() int {
+	return r.count(SeverityWarning)
+}
+
+// Valid returns true if no errors have been found
+func (r *Report) Valid() bool {
+	return r.Errors() == 0
+}
+
+func (r *Report) count(s Severity) int {
+	n := 0
+	for _, i := range r.Issues {
+		if i.Severity == s {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteText writes the report in human readable form, one issue per line followed by a summary
+func (r *Report) WriteText(w io.Writer) error {
+	for _, i := range r.Issues {
+		if _, err := fmt.Fprintln(w, i.String()); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s: checked %d tilesets, %d tiles and %d contents, found %d errors and %d warnings\n",
+		r.Tileset, r.Tilesets, r.Tiles, r.Contents, r.Errors(), r.Warnings())
+	return err
+}
+
+// WriteJSON writes the report as an indented JSON document
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// ValidatePath validates the tileset at the given location, which can either be a folder containing a
+// tileset.json, a tileset .json file or a .3tz archive. An error is returned only if the tileset cannot be opened.
+func ValidatePath(location string) (*Report, error) {
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", location, err)
+	}
+	if info.IsDir() {
+		return Validate(os.DirFS(location), "tileset.json"), nil
+	}
+	if strings.EqualFold(filepath.Ext(location), ".3tz") {
+		z, err := zip.OpenReader(location)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open the archive %s: %w", location, err)
+		}
+		defer z.Close()
+		return Validate(z, "tileset.json"), nil
+	}
+	return Validate(os.DirFS(filepath.Dir(location)), filepath.Base(location)), nil
+}
+
+// Validate walks the tileset rooted at the given tileset.json path of fsys, including the external tilesets
+// and the implicit tiling subtrees, and returns all the issues found. It checks that:
+//   - the tileset.json files match the 3D Tiles schema
+//   - every content uri points to an existing file
+//   - the .pnts headers, byte lengths, 8 byte table alignment and tables, and the .glb chunks and buffers are consistent
+//   - the bounding volume of each tile is contained in the one of its parent
+//   - geometric errors never increase moving from a tile to its children
+//
+// Files stored gzip compressed in place are transparently decompressed.
+func Validate(fsys fs.FS, tilesetPath string) *Report {
+	v := &validator{
+		fsys:    fsys,
+		report:  &Report{Tileset: tilesetPath, Issues: []Issue{}},
+		visited: map[string]bool{},
+	}
+	v.validateTileset(path.Clean(tilesetPath), identity, nil)
+	return v.report
+}
+
+type validator struct {
+	fsys    fs.FS
+	report  *Report
+	visited map[string]bool
+}
+
+func (v *validator) errorf(file string, location string, format string, args ...any) {
+	v.report.Issues = append(v.report.Issues, Issue{SeverityError, file, location, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(file string, location string, format string, args ...any) {
+	v.report.Issues = append(v.report.Issues, Issue{SeverityWarning, file, location, fmt.Sprintf(format, args...)})
+}
+
+// readFile reads the file at the given path of the tileset, decompressing it if gzip compressed
+func (v *validator) readFile(p string) ([]byte, error) {
+	data, err := fs.ReadFile(v.fsys, p)
+	if err != nil || len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+// parentTile holds the properties of a tile that constrain its children
+type parentTile struct {
+	volume         *volume
+	geometricError float64
+}
+
+type tilesetJson struct {
+	Asset *struct {
+		Version string `json:"version"`
+	} `json:"asset"`
+	GeometricError *float64  `json:"geometricError"`
+	Root           *tileJson `json:"root"`
+}
+
+type tileJson struct {
+	BoundingVolume *boundingVolumeJson `json:"boundingVolume"`
+	GeometricError *float64            `json:"geometricError"`
+	Refine         string              `json:"refine"`
+	Transform      []float64           `json:"transform"`
+	Content        *contentJson        `json:"content"`
+	Contents       []contentJson       `json:"contents"`
+	Children       []tileJson          `json:"children"`
+	ImplicitTiling *implicitTilingJson `json:"implicitTiling"`
+}
+
+type contentJson struct {
+	Uri            string              `json:"uri"`
+	Url            string              `json:"url"`
+	BoundingVolume *boundingVolumeJson `json:"boundingVolume"`
+}
+
+type boundingVolumeJson struct {
+	Box    []float64 `json:"box"`
+	Region []float64 `json:"region"`
+	Sphere []float64 `json:"sphere"`
+}
+
+// validateTileset validates the tileset.json at the given path, referenced by a tile with the given transform
+func (v *validator) validateTileset(p string, transform mat4, parent *parentTile) {
+	if v.visited[p] {
+		v.errorf(p, "", "tileset referenced more than once, or recursively")
+		return
+	}
+	v.visited[p] = true
+	v.report.Tilesets++
+	data, err := v.readFile(p)
+	if err != nil {
+		v.errorf(p, "", "unable to read the tileset: %v", err)
+		return
+	}
+	t := tilesetJson{}
+	if err := json.Unmarshal(data, &t); err != nil {
+		v.errorf(p, "", "invalid tileset json: %v", err)
+		return
+	}
+	if t.Asset == nil {
+		v.errorf(p, "asset", "missing required property")
+	} else if t.Asset.Version == "" {
+		v.errorf(p, "asset.version", "missing required property")
+	} else if t.Asset.Version != "1.0" && t.Asset.Version != "1.1" {
+		v.warnf(p, "asset.version", "unknown 3D Tiles version %s", t.Asset.Version)
+	}
+	if t.GeometricError == nil {
+		v.errorf(p, "geometricError", "missing required property")
+	} else if *t.GeometricError < 0 {
+		v.errorf(p, "geometricError", "negative geometric error %g", *t.GeometricError)
+	}
+	if t.Root == nil {
+		v.errorf(p, "root", "missing required property")
+		return
+	}
+	if t.Root.Refine == "" {
+		v.errorf(p, "root.refine", "missing required property")
+	}
+	if t.GeometricError != nil && t.Root.GeometricError != nil && *t.Root.GeometricError > *t.GeometricError {
+		v.warnf(p, "root.geometricError", "root geometric error %g is greater than the tileset one %g", *t.Root.GeometricError, *t.GeometricError)
+	}
+	v.validateTile(t.Root, p, "root", transform, parent)
+}
+
+func (v *validator) validateTile(t *tileJson, file string, location string, transform mat4, parent *parentTile) {
+	v.report.Tiles++
+	if t.Transform != nil {
+		if len(t.Transform) != 16 {
+			v.errorf(file, location+".transform", "expected 16 elements, got %d", len(t.Transform))
+		} else {
+			transform = transform.mul(mat4(t.Transform))
+		}
+	}
+	current := &parentTile{}
+	if t.BoundingVolume == nil {
+		v.errorf(file, location+".boundingVolume", "missing required property")
+	} else if vol, err := newVolume(t.BoundingVolume, transform); err != nil {
+		v.errorf(file, location+".boundingVolume", "%v", err)
+	} else {
+		current.volume = vol
+		if parent != nil && parent.volume != nil && !parent.volume.contains(vol) {
+			v.errorf(file, location+".boundingVolume", "bounding volume is not contained in the parent one")
+		}
+	}
+	if t.GeometricError == nil {
+		v.errorf(file, location+".geometricError", "missing required property")
+	} else {
+		current.geometricError = *t.GeometricError
+		if *t.GeometricError < 0 {
+			v.errorf(file, location+".geometricError", "negative geometric error %g", *t.GeometricError)
+		}
+		if parent != nil && *t.GeometricError > parent.geometricError {
+			v.errorf(file, location+".geometricError", "geometric error %g is greater than the parent one %g", *t.GeometricError, parent.geometricError)
+		}
+	}
+	if t.Refine != "" && t.Refine != "ADD" && t.Refine != "REPLACE" {
+		v.errorf(file, location+".refine", "invalid refine %q, expected ADD or REPLACE", t.Refine)
+	}
+	if t.Content != nil && t.Contents != nil {
+		v.errorf(file, location, "content and contents cannot be both set")
+	}
+	if t.ImplicitTiling != nil {
+		if t.Children != nil {
+			v.errorf(file, location+".children", "tiles with implicit tiling cannot have explicit children")
+		}
+		v.validateImplicitTiling(t, file, location)
+		return
+	}
+	if t.Content != nil {
+		v.validateContentRef(t.Content, file, location+".content", transform, current)
+	}
+	for i := range t.Contents {
+		v.validateContentRef(&t.Contents[i], file, fmt.Sprintf("%s.contents[%d]", location, i), transform, current)
+	}
+	for i := range t.Children {
+		v.validateTile(&t.Children[i], file, fmt.Sprintf("%s.children[%d]", location, i), transform, current)
+	}
+}
+
+// validateContentRef validates the content of a tile, either a tile content file or an external tileset
+func (v *validator) validateContentRef(c *contentJson, file string, location string, transform mat4, tile *parentTile) {
+	uri := c.Uri
+	if uri == "" && c.Url != "" {
+		v.warnf(file, location+".url", "url is deprecated, use uri instead")
+		uri = c.Url
+	}
+	if uri == "" {
+		v.errorf(file, location+".uri", "missing required property")
+		return
+	}
+	if c.BoundingVolume != nil {
+		vol, err := newVolume(c.BoundingVolume, transform)
+		if err != nil {
+			v.errorf(file, location+".boundingVolume", "%v", err)
+		} else if tile.volume != nil && !tile.volume.contains(vol) {
+			v.errorf(file, location+".boundingVolume", "content bounding volume is not contained in the tile one")
+		}
+	}
+	p, ok := v.resolve(file, uri)
+	if !ok {
+		v.warnf(file, location+".uri", "external uri %s not checked", uri)
+		return
+	}
+	if strings.EqualFold(path.Ext(p), ".json") {
+		if _, err := fs.Stat(v.fsys, p); err != nil {
+			v.errorf(file, location+".uri", "external tileset %s cannot be read: %v", p, err)
+			return
+		}
+		v.validateTileset(p, transform, tile)
+		return
+	}
+	v.validateContentFile(p, file, location+".uri")
+}
+
+// resolve returns the path of the file referenced by the given uri, relative to the file containing it.
+// Absolute URLs cannot be resolved and false is returned.
+func (v *validator) resolve(file string, uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.HasPrefix(u.Path, "/") {
+		return "", false
+	}
+	return path.Join(path.Dir(file), u.Path), true
+}
+
+// validateContentFile checks the content file at the given path, referenced in the given file location
+func (v *validator) validateContentFile(p string, file string, location string) {
+	data, err := v.readFile(p)
+	if err != nil {
+		v.errorf(file, location, "content %s cannot be read: %v", p, err)
+		return
+	}
+	v.report.Contents++
+	switch {
+	case bytes.HasPrefix(data, []byte("pnts")):
+		v.validatePnts(p, data)
+	case bytes.HasPrefix(data, []byte("glTF")):
+		v.validateGlb(p, data)
+	case strings.EqualFold(path.Ext(p), ".pnts"), strings.EqualFold(path.Ext(p), ".glb"):
+		v.errorf(p, "", "invalid magic %q", data[:min(4, len(data))])
+	}
+}
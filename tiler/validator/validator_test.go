@@ -0,0 +1,335 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/geom"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/tree"
+	"github.com/mfbonfigli/gocesiumtiler/v2/internal/writer"
+	"github.com/mfbonfigli/gocesiumtiler/v2/tiler/storage"
+	"github.com/mfbonfigli/gocesiumtiler/v2/version"
+)
+
+func testTree() *tree.MockNode {
+	node := func(total int, geomError float64, children [8]tree.Node) *tree.MockNode {
+		pt1 := &geom.LinkedPoint{Pt: geom.NewPoint(1, 2, 3, 4, 5, 6, 7, 8)}
+		pt2 := &geom.LinkedPoint{Pt: geom.NewPoint(2, 3, 4, 5, 6, 7, 8, 9)}
+		pt1.Next = pt2
+		return &tree.MockNode{
+			TotalNumPts: total,
+			Pts:         geom.NewLinkedPointStream(pt1, 2),
+			ChildNodes:  children,
+			GeomError:   geomError,
+			Bounds:      geom.NewBoundingBox(0, 8, 0, 8, 0, 8),
+			Leaf:        children == [8]tree.Node{},
+		}
+	}
+	grandChild := node(2, 1, [8]tree.Node{})
+	child1 := node(4, 5, [8]tree.Node{3: grandChild})
+	child6 := node(2, 5, [8]tree.Node{})
+	root := node(8, 10, [8]tree.Node{1: child1, 6: child6})
+	root.Root = true
+	return root
+}
+
+// writeTileset writes the test tree with the given writer options and returns the produced files
+func writeTileset(t *testing.T, opts ...func(*writer.StandardWriter)) fstest.MapFS {
+	t.Helper()
+	s := storage.NewMemoryStorage()
+	w, err := writer.NewWriter("out", append(opts, writer.WithStorage(s), writer.WithNumWorkers(2))...)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := w.Write(testTree(), "tileset", context.TODO()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	fsys := fstest.MapFS{}
+	for _, f := range s.Files() {
+		data, _ := s.Get(f)
+		fsys[strings.TrimPrefix(f, "out/tileset/")] = &fstest.MapFile{Data: data}
+	}
+	return fsys
+}
+
+func TestValidateWriterOutput(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     []func(*writer.StandardWriter)
+		tilesets int
+		tiles    int
+	}{
+		// the child 1 is written as an external tileset, whose root tile is counted as well
+		{"pnts", nil, 2, 5},
+		{"pnts quantized rgb565", []func(*writer.StandardWriter){writer.WithQuantizedPositions(true), writer.WithRGB565(true)}, 2, 5},
+		{"pnts draco", []func(*writer.StandardWriter){writer.WithDraco(writer.DracoOptions{PositionBits: 14, ColorBits: 8, AttributeBits: 16})}, 2, 5},
+		{"glb", []func(*writer.StandardWriter){writer.WithTilesetVersion(version.TilesetVersion_1_1)}, 2, 5},
+		{"glb meshopt", []func(*writer.StandardWriter){writer.WithTilesetVersion(version.TilesetVersion_1_1), writer.WithMeshopt(true)}, 2, 5},
+		{"implicit", []func(*writer.StandardWriter){writer.WithTilesetVersion(version.TilesetVersion_1_1), writer.WithImplicitTiling(2)}, 1, 4},
+		{"gzip", []func(*writer.StandardWriter){writer.WithGzipCompression(writer.GzipReplace)}, 2, 5},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := Validate(writeTileset(t, c.opts...), "tileset.json")
+			if !r.Valid() || len(r.Issues) != 0 {
+				t.Errorf("expected no issues, got %v", r.Issues)
+			}
+			if r.Tilesets != c.tilesets || r.Tiles != c.tiles || r.Contents != 4 {
+				t.Errorf("expected %d tilesets, %d tiles and 4 contents, got %d, %d and %d", c.tilesets, c.tiles, r.Tilesets, r.Tiles, r.Contents)
+			}
+		})
+	}
+}
+
+func TestValidateTilesetSchema(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tileset.json": {Data: []byte(`{
+			"asset": {},
+			"root": {
+				"boundingVolume": {"box": [0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1], "sphere": [0, 0, 0, 1]},
+				"refine": "MERGE",
+				"content": {"uri": "missing.pnts"},
+				"children": [{"boundingVolume": {"box": [0, 0, 0]}}]
+			}
+		}`)},
+	}
+	r := Validate(fsys, "tileset.json")
+	expected := []Issue{
+		{SeverityError, "tileset.json", "asset.version", "missing required property"},
+		{SeverityError, "tileset.json", "geometricError", "missing required property"},
+		{SeverityError, "tileset.json", "root.boundingVolume", "exactly one of box, region or sphere must be set"},
+		{SeverityError, "tileset.json", "root.geometricError", "missing required property"},
+		{SeverityError, "tileset.json", "root.refine", "invalid refine \"MERGE\", expected ADD or REPLACE"},
+		{SeverityError, "tileset.json", "root.content.uri", "content missing.pnts cannot be read: open missing.pnts: file does not exist"},
+		{SeverityError, "tileset.json", "root.children[0].boundingVolume", "box must have 12 elements, got 3"},
+		{SeverityError, "tileset.json", "root.children[0].geometricError", "missing required property"},
+	}
+	if len(r.Issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %v", len(expected), r.Issues)
+	}
+	for i := range expected {
+		if r.Issues[i] != expected[i] {
+			t.Errorf("expected issue %v, got %v", expected[i], r.Issues[i])
+		}
+	}
+	if r.Valid() || r.Errors() != len(expected) || r.Warnings() != 0 {
+		t.Errorf("unexpected report counters %d errors %d warnings", r.Errors(), r.Warnings())
+	}
+}
+
+func TestValidateHierarchy(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tileset.json": {Data: []byte(`{
+			"asset": {"version": "1.0"},
+			"geometricError": 10,
+			"root": {
+				"boundingVolume": {"box": [0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1]},
+				"geometricError": 10,
+				"refine": "ADD",
+				"transform": [1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 100, 0, 0, 1],
+				"children": [
+					{"boundingVolume": {"box": [0.5, 0, 0, 0.5, 0, 0, 0, 1, 0, 0, 0, 1]}, "geometricError": 5},
+					{"boundingVolume": {"box": [1, 0, 0, 0.5, 0, 0, 0, 1, 0, 0, 0, 1]}, "geometricError": 5},
+					{"boundingVolume": {"sphere": [0, 0, 0, 1.5]}, "geometricError": 20},
+					{"boundingVolume": {"box": [0, 0, 0, 0.1, 0, 0, 0, 0.1, 0, 0, 0, 0.1]}, "geometricError": 1, "content": {"uri": "external.json"}}
+				]
+			}
+		}`)},
+		"external.json": {Data: []byte(`{
+			"asset": {"version": "1.0"},
+			"geometricError": 1,
+			"root": {
+				"boundingVolume": {"box": [0, 0, 0, 0.2, 0, 0, 0, 0.1, 0, 0, 0, 0.1]},
+				"geometricError": 1,
+				"refine": "ADD"
+			}
+		}`)},
+	}
+	r := Validate(fsys, "tileset.json")
+	expected := []Issue{
+		{SeverityError, "tileset.json", "root.children[1].boundingVolume", "bounding volume is not contained in the parent one"},
+		{SeverityError, "tileset.json", "root.children[2].boundingVolume", "bounding volume is not contained in the parent one"},
+		{SeverityError, "tileset.json", "root.children[2].geometricError", "geometric error 20 is greater than the parent one 10"},
+		{SeverityError, "external.json", "root.boundingVolume", "bounding volume is not contained in the parent one"},
+	}
+	if len(r.Issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %v", len(expected), r.Issues)
+	}
+	for i := range expected {
+		if r.Issues[i] != expected[i] {
+			t.Errorf("expected issue %v, got %v", expected[i], r.Issues[i])
+		}
+	}
+	if r.Tilesets != 2 || r.Tiles != 6 {
+		t.Errorf("expected 2 tilesets and 6 tiles, got %d and %d", r.Tilesets, r.Tiles)
+	}
+}
+
+func TestValidateBrokenContents(t *testing.T) {
+	fsys := writeTileset(t)
+	pnts := fsys["content.pnts"].Data
+	fsys["content.pnts"].Data = pnts[:len(pnts)-4]
+	fsys["1/content.pnts"].Data = bytes.Replace(fsys["1/content.pnts"].Data, []byte(`"POINTS_LENGTH":2`), []byte(`"POINTS_LENGTH":9`), 1)
+	delete(fsys, "6/content.pnts")
+
+	r := Validate(fsys, "tileset.json")
+	expected := []Issue{
+		{SeverityError, "content.pnts", "header", "byteLength " + strconv.Itoa(len(pnts)) + " does not match the file size " + strconv.Itoa(len(pnts)-4)},
+		{SeverityError, "1/content.pnts", "featureTable.POSITION", "108 bytes at offset 0 exceed the binary body of 32 bytes"},
+		{SeverityError, "1/content.pnts", "featureTable.RGB", "27 bytes at offset 24 exceed the binary body of 32 bytes"},
+		{SeverityError, "1/content.pnts", "batchTable.CLASSIFICATION", "9 bytes at offset 2 exceed the binary body of 8 bytes"},
+		{SeverityError, "1/content.pnts", "batchTable.INTENSITY", "9 bytes at offset 0 exceed the binary body of 8 bytes"},
+		{SeverityError, "tileset.json", "root.children[1].content.uri", "content 6/content.pnts cannot be read: open 6/content.pnts: file does not exist"},
+	}
+	if len(r.Issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %v", len(expected), r.Issues)
+	}
+	for i := range expected {
+		if r.Issues[i] != expected[i] {
+			t.Errorf("expected issue %v, got %v", expected[i], r.Issues[i])
+		}
+	}
+}
+
+func TestValidatePntsAlignment(t *testing.T) {
+	fsys := writeTileset(t)
+	pnts := fsys["content.pnts"].Data
+	// drop the padding of the batch table binary body leaving the header consistent
+	btBin := int(binary.LittleEndian.Uint32(pnts[24:]))
+	broken := append([]byte{}, pnts[:len(pnts)-4]...)
+	binary.LittleEndian.PutUint32(broken[8:], uint32(len(broken)))
+	binary.LittleEndian.PutUint32(broken[24:], uint32(btBin-4))
+	fsys["content.pnts"].Data = broken
+
+	r := Validate(fsys, "tileset.json")
+	expected := []Issue{
+		{SeverityError, "content.pnts", "batchTable", "binary body ends at byte " + strconv.Itoa(len(broken)) + ", not on an 8 byte boundary"},
+	}
+	if len(r.Issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %v", len(expected), r.Issues)
+	}
+	for i := range expected {
+		if r.Issues[i] != expected[i] {
+			t.Errorf("expected issue %v, got %v", expected[i], r.Issues[i])
+		}
+	}
+}
+
+func TestValidateBrokenGlb(t *testing.T) {
+	fsys := writeTileset(t, writer.WithTilesetVersion(version.TilesetVersion_1_1))
+	glb := fsys["content.glb"].Data
+	// shrink the BIN chunk leaving the header consistent
+	jsonLen := int(binary.LittleEndian.Uint32(glb[12:]))
+	binStart := 12 + 8 + jsonLen
+	broken := append([]byte{}, glb[:binStart+8+4]...)
+	binary.LittleEndian.PutUint32(broken[8:], uint32(len(broken)))
+	binary.LittleEndian.PutUint32(broken[binStart:], 4)
+	fsys["content.glb"].Data = broken
+
+	r := Validate(fsys, "tileset.json")
+	if r.Valid() {
+		t.Fatalf("expected errors")
+	}
+	if i := r.Issues[0]; i.File != "content.glb" || i.Location != "buffers[0]" {
+		t.Errorf("expected a buffers[0] issue, got %v", i)
+	}
+}
+
+func TestValidateImplicitAvailability(t *testing.T) {
+	fsys := writeTileset(t, writer.WithTilesetVersion(version.TilesetVersion_1_1), writer.WithImplicitTiling(2))
+	delete(fsys, "content/1/0/1/1/content.glb")
+	delete(fsys, "subtrees/2/3/1/0.subtree")
+	r := Validate(fsys, "tileset.json")
+	expected := []Issue{
+		{SeverityError, "subtrees/0/0/0/0.subtree", "contentAvailability[0]", "content content/1/0/1/1/content.glb cannot be read: open content/1/0/1/1/content.glb: file does not exist"},
+		{SeverityError, "tileset.json", "root.implicitTiling.subtrees.uri", "subtree subtrees/2/3/1/0.subtree cannot be read: open subtrees/2/3/1/0.subtree: file does not exist"},
+	}
+	if len(r.Issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %v", len(expected), r.Issues)
+	}
+	for i := range expected {
+		if r.Issues[i] != expected[i] {
+			t.Errorf("expected issue %v, got %v", expected[i], r.Issues[i])
+		}
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	tmp := t.TempDir()
+	for name, f := range writeTileset(t) {
+		p := filepath.Join(tmp, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		os.WriteFile(p, f.Data, 0o644)
+	}
+	for _, p := range []string{tmp, filepath.Join(tmp, "tileset.json")} {
+		r, err := ValidatePath(p)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !r.Valid() || r.Tiles != 5 {
+			t.Errorf("expected a valid tileset with 5 tiles, got %v", r)
+		}
+	}
+
+	s := storage.NewMemoryStorage()
+	w, _ := writer.NewWriter("out", writer.WithStorage(s), writer.WithArchive(true))
+	if err := w.Write(testTree(), "tileset", context.TODO()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	archive, _ := s.Get("out/tileset/tileset.3tz")
+	os.WriteFile(filepath.Join(tmp, "tileset.3tz"), archive, 0o644)
+	r, err := ValidatePath(filepath.Join(tmp, "tileset.3tz"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !r.Valid() || r.Tiles != 5 {
+		t.Errorf("expected a valid archive with 5 tiles, got %v", r)
+	}
+
+	if _, err := ValidatePath(filepath.Join(tmp, "missing")); err == nil {
+		t.Errorf("expected error for a missing tileset")
+	}
+}
+
+func TestReportOutput(t *testing.T) {
+	r := &Report{
+		Tileset:  "tileset.json",
+		Tilesets: 1,
+		Tiles:    2,
+		Contents: 1,
+		Issues: []Issue{
+			{SeverityError, "content.pnts", "header", "bad header"},
+			{SeverityWarning, "tileset.json", "", "a warning"},
+		},
+	}
+	text := &bytes.Buffer{}
+	if err := r.WriteText(text); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := "error: content.pnts: header: bad header\n" +
+		"warning: tileset.json: a warning\n" +
+		"tileset.json: checked 1 tilesets, 2 tiles and 1 contents, found 1 errors and 1 warnings\n"
+	if text.String() != expected {
+		t.Errorf("expected text %q got %q", expected, text.String())
+	}
+
+	js := &bytes.Buffer{}
+	if err := r.WriteJSON(js); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	actual := &Report{}
+	if err := json.Unmarshal(js.Bytes(), actual); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if actual.Tiles != 2 || len(actual.Issues) != 2 || actual.Issues[0] != r.Issues[0] {
+		t.Errorf("unexpected json report %s", js.String())
+	}
+}
@@ -0,0 +1,223 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// containment tolerances, in meters and radians, absorbing the rounding errors of the bounding volumes
+const (
+	distanceTolerance = 1e-3
+	angleTolerance    = 1e-9
+)
+
+type vec3 [3]float64
+
+func (a vec3) add(b vec3) vec3 {
+	return vec3{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
+
+func (a vec3) sub(b vec3) vec3 {
+	return vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func (a vec3) scale(s float64) vec3 {
+	return vec3{a[0] * s, a[1] * s, a[2] * s}
+}
+
+func (a vec3) dot(b vec3) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func (a vec3) cross(b vec3) vec3 {
+	return vec3{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func (a vec3) norm() float64 {
+	return math.Sqrt(a.dot(a))
+}
+
+// mat4 is a column major 4x4 affine transform, as stored in the tileset.json files
+type mat4 [16]float64
+
+var identity = mat4{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
+
+func (m mat4) mul(o mat4) mat4 {
+	r := mat4{}
+	for c := 0; c < 4; c++ {
+		for row := 0; row < 4; row++ {
+			for k := 0; k < 4; k++ {
+				r[c*4+row] += m[k*4+row] * o[c*4+k]
+			}
+		}
+	}
+	return r
+}
+
+// point applies the transform to a point
+func (m mat4) point(p vec3) vec3 {
+	return m.vector(p).add(vec3{m[12], m[13], m[14]})
+}
+
+// vector applies the linear part of the transform to a vector
+func (m mat4) vector(p vec3) vec3 {
+	return vec3{
+		m[0]*p[0] + m[4]*p[1] + m[8]*p[2],
+		m[1]*p[0] + m[5]*p[1] + m[9]*p[2],
+		m[2]*p[0] + m[6]*p[1] + m[10]*p[2],
+	}
+}
+
+// maxScale returns the largest scale factor applied by the transform along its axes
+func (m mat4) maxScale() float64 {
+	return max(vec3{m[0], m[1], m[2]}.norm(), vec3{m[4], m[5], m[6]}.norm(), vec3{m[8], m[9], m[10]}.norm())
+}
+
+type volumeKind int
+
+const (
+	boxVolume volumeKind = iota
+	regionVolume
+	sphereVolume
+)
+
+// volume is a bounding volume expressed in the tileset global frame. Regions are not affected by the
+// tile transforms as they are always expressed in EPSG:4979 coordinates.
+type volume struct {
+	kind   volumeKind
+	center vec3
+	axes   [3]vec3
+	radius float64
+	// west, south, east, north, min height, max height
+	region [6]float64
+}
+
+func newVolume(bv *boundingVolumeJson, t mat4) (*volume, error) {
+	set := 0
+	for _, v := range [][]float64{bv.Box, bv.Region, bv.Sphere} {
+		if v != nil {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, errors.New("exactly one of box, region or sphere must be set")
+	}
+	switch {
+	case bv.Box != nil:
+		if len(bv.Box) != 12 {
+			return nil, fmt.Errorf("box must have 12 elements, got %d", len(bv.Box))
+		}
+		b := bv.Box
+		return &volume{
+			kind:   boxVolume,
+			center: t.point(vec3{b[0], b[1], b[2]}),
+			axes:   [3]vec3{t.vector(vec3{b[3], b[4], b[5]}), t.vector(vec3{b[6], b[7], b[8]}), t.vector(vec3{b[9], b[10], b[11]})},
+		}, nil
+	case bv.Region != nil:
+		if len(bv.Region) != 6 {
+			return nil, fmt.Errorf("region must have 6 elements, got %d", len(bv.Region))
+		}
+		r := bv.Region
+		if math.Abs(r[0]) > math.Pi || math.Abs(r[2]) > math.Pi || r[1] < -math.Pi/2 || r[3] > math.Pi/2 || r[1] > r[3] || r[4] > r[5] {
+			return nil, fmt.Errorf("invalid region %v", r)
+		}
+		return &volume{kind: regionVolume, region: [6]float64(r)}, nil
+	default:
+		if len(bv.Sphere) != 4 {
+			return nil, fmt.Errorf("sphere must have 4 elements, got %d", len(bv.Sphere))
+		}
+		s := bv.Sphere
+		if s[3] < 0 {
+			return nil, fmt.Errorf("negative sphere radius %g", s[3])
+		}
+		return &volume{kind: sphereVolume, center: t.point(vec3{s[0], s[1], s[2]}), radius: s[3] * t.maxScale()}, nil
+	}
+}
+
+// contains returns true if the volume contains the other one. Regions can only be compared with other regions,
+// hence mixed comparisons involving a region are skipped and considered successful.
+func (v *volume) contains(o *volume) bool {
+	if v.kind == regionVolume || o.kind == regionVolume {
+		if v.kind != o.kind {
+			return true
+		}
+		// regions crossing the antimeridian are not checked
+		if v.region[0] > v.region[2] || o.region[0] > o.region[2] {
+			return true
+		}
+		return o.region[0] >= v.region[0]-angleTolerance && o.region[1] >= v.region[1]-angleTolerance &&
+			o.region[2] <= v.region[2]+angleTolerance && o.region[3] <= v.region[3]+angleTolerance &&
+			o.region[4] >= v.region[4]-distanceTolerance && o.region[5] <= v.region[5]+distanceTolerance
+	}
+	if v.kind == sphereVolume && o.kind == sphereVolume {
+		return o.center.sub(v.center).norm()+o.radius <= v.radius+distanceTolerance
+	}
+	for _, p := range o.points(v) {
+		if !v.containsPoint(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// points returns the points that must be contained in the volume c to contain this volume:
+// the corners of a box or, for a sphere, its extreme points along the box axes of c
+func (v *volume) points(c *volume) []vec3 {
+	if v.kind == sphereVolume {
+		points := []vec3{v.center}
+		for _, a := range c.axes {
+			if n := a.norm(); n > 0 {
+				d := a.scale(v.radius / n)
+				points = append(points, v.center.add(d), v.center.sub(d))
+			}
+		}
+		return points
+	}
+	points := make([]vec3, 0, 8)
+	for i := 0; i < 8; i++ {
+		p := v.center
+		for j, a := range v.axes {
+			if i>>j&1 == 0 {
+				p = p.sub(a)
+			} else {
+				p = p.add(a)
+			}
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+func (v *volume) containsPoint(p vec3) bool {
+	d := p.sub(v.center)
+	if v.kind == sphereVolume {
+		return d.norm() <= v.radius+distanceTolerance
+	}
+	a := v.axes
+	norms := vec3{a[0].norm(), a[1].norm(), a[2].norm()}
+	var coords vec3
+	det := a[0].dot(a[1].cross(a[2]))
+	if math.Abs(det) > 1e-12*norms[0]*norms[1]*norms[2] && det != 0 {
+		// coordinates of the point in the box frame, solving the linear system with the Cramer's rule
+		coords = vec3{d.dot(a[1].cross(a[2])) / det, a[0].dot(d.cross(a[2])) / det, a[0].dot(a[1].cross(d)) / det}
+	} else {
+		// flat boxes: project on the non degenerate axes and check that nothing is left out
+		residual := d
+		for i := range a {
+			if norms[i] > 0 {
+				coords[i] = d.dot(a[i]) / (norms[i] * norms[i])
+				residual = residual.sub(a[i].scale(coords[i]))
+			}
+		}
+		if residual.norm() > distanceTolerance {
+			return false
+		}
+	}
+	for i := range coords {
+		if (math.Abs(coords[i])-1)*norms[i] > distanceTolerance {
+			return false
+		}
+	}
+	return true
+}